@@ -21,6 +21,7 @@ import (
 	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/srvtopo"
 	"vitess.io/vitess/go/vt/vtgate"
+	"vitess.io/vitess/go/vt/vtgate/balancer"
 
 	_ "vitess.io/vitess/go/vt/status"
 )
@@ -43,6 +44,9 @@ func addStatusParts(vtg *vtgate.VTGate) {
 	servenv.AddStatusPart("Gateway Status", vtgate.StatusTemplate, func() any {
 		return vtg.GetGatewayCacheStatus()
 	})
+	servenv.AddStatusPart("Tablet Balancer", balancer.StatusTemplate, func() any {
+		return vtg.Gateway().BalancerStatus()
+	})
 	servenv.AddStatusPart("Health Check Cache", discovery.HealthCheckTemplate, func() any {
 		return vtg.Gateway().TabletsCacheStatus()
 	})
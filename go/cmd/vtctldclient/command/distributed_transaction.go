@@ -0,0 +1,163 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// DistributedTransaction is a parent command for DistributedTransaction* sub commands.
+	DistributedTransaction = &cobra.Command{
+		Use:   "DistributedTransaction [command]",
+		Short: "Lists and resolves the distributed (2PC) transactions left unresolved by their coordinator.",
+		Long: `Lists and resolves the distributed (2PC) transactions left unresolved by their coordinator.
+
+The list command returns the unresolved transactions of a keyspace, with their
+state and participants. The resolve command commits or rolls back a transaction
+on all its participants, depending on the decision of its coordinator, then
+concludes it. The conclude command only deletes the metadata of a transaction,
+and needs --force unless it is already gone, for instance after its participants
+were repaired by hand.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+
+	// DistributedTransactionList makes a GetUnresolvedTransactions gRPC call to a vtctld.
+	DistributedTransactionList = &cobra.Command{
+		Use:                   "list --keyspace <keyspace> [--abandon-age <duration>]",
+		Short:                 "Lists the unresolved distributed transactions of a keyspace.",
+		Example:               `vtctldclient --server=localhost:15999 DistributedTransaction list --keyspace customer --abandon-age 5m`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"List"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandDistributedTransactionList,
+	}
+
+	// DistributedTransactionResolve makes a ResolveTransaction gRPC call to a vtctld.
+	DistributedTransactionResolve = &cobra.Command{
+		Use:                   "resolve <dtid>",
+		Short:                 "Commits or rolls back a distributed transaction on all its participants, then concludes it.",
+		Example:               `vtctldclient --server=localhost:15999 DistributedTransaction resolve customer:80-:1669207924893823001`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Resolve"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandDistributedTransactionResolve,
+	}
+
+	// DistributedTransactionConclude makes a ConcludeTransaction gRPC call to a vtctld.
+	DistributedTransactionConclude = &cobra.Command{
+		Use:   "conclude [--force] <dtid>",
+		Short: "Deletes the metadata of a distributed transaction, without resolving it.",
+		Long: `Deletes the metadata of a distributed transaction, without resolving it.
+
+As the metadata manager keeps a transaction until it is resolved on all its
+participants, concluding it fails unless --force is given. Only force it once
+the participants were committed or rolled back by hand, or they are left with
+a prepared transaction for good.`,
+		Example:               `vtctldclient --server=localhost:15999 DistributedTransaction conclude --force customer:80-:1669207924893823001`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Conclude"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandDistributedTransactionConclude,
+	}
+)
+
+var distributedTransactionListOptions = struct {
+	Keyspace   string
+	AbandonAge time.Duration
+}{}
+
+var distributedTransactionConcludeOptions = struct {
+	Force bool
+}{}
+
+func commandDistributedTransactionList(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.GetUnresolvedTransactions(commandCtx, &vtctldatapb.GetUnresolvedTransactionsRequest{
+		Keyspace:   distributedTransactionListOptions.Keyspace,
+		AbandonAge: int64(distributedTransactionListOptions.AbandonAge.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandDistributedTransactionResolve(cmd *cobra.Command, args []string) error {
+	dtid := cmd.Flags().Arg(0)
+
+	cli.FinishedParsing(cmd)
+
+	_, err := client.ResolveTransaction(commandCtx, &vtctldatapb.ResolveTransactionRequest{
+		Dtid: dtid,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully resolved transaction %v\n", dtid)
+
+	return nil
+}
+
+func commandDistributedTransactionConclude(cmd *cobra.Command, args []string) error {
+	dtid := cmd.Flags().Arg(0)
+
+	cli.FinishedParsing(cmd)
+
+	_, err := client.ConcludeTransaction(commandCtx, &vtctldatapb.ConcludeTransactionRequest{
+		Dtid:  dtid,
+		Force: distributedTransactionConcludeOptions.Force,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully concluded transaction %v\n", dtid)
+
+	return nil
+}
+
+func init() {
+	Root.AddCommand(DistributedTransaction)
+
+	DistributedTransactionList.Flags().StringVarP(&distributedTransactionListOptions.Keyspace, "keyspace", "k", "", "Keyspace of the transactions (required)")
+	DistributedTransactionList.MarkFlagRequired("keyspace")
+	DistributedTransactionList.Flags().DurationVar(&distributedTransactionListOptions.AbandonAge, "abandon-age", 0, "Only list the transactions older than this age, i.e. abandoned by their coordinator")
+	DistributedTransaction.AddCommand(DistributedTransactionList)
+
+	DistributedTransaction.AddCommand(DistributedTransactionResolve)
+	DistributedTransactionConclude.Flags().BoolVar(&distributedTransactionConcludeOptions.Force, "force", false, "Conclude the transaction even though it is not resolved")
+	DistributedTransaction.AddCommand(DistributedTransactionConclude)
+}
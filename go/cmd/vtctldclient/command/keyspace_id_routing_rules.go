@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/json2"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// ApplyKeyspaceIdRoutingRules makes an ApplyKeyspaceIdRoutingRules gRPC call to a vtctld.
+	ApplyKeyspaceIdRoutingRules = &cobra.Command{
+		Use:   "ApplyKeyspaceIdRoutingRules {--rules RULES | --rules-file RULES_FILE} [--cells=c1,c2,...] [--skip-rebuild] [--dry-run]",
+		Short: "Applies the provided keyspace id routing rules.",
+		Long: `Applies the provided keyspace id routing rules.
+
+Keyspace id routing rules pin a range of keyspace ids of a keyspace to a single
+shard, for example to isolate a hot tenant. VTGate sends the reads and writes of
+those keyspace ids to that shard instead of the shard that covers them.`,
+		Example:               `ApplyKeyspaceIdRoutingRules --rules '{"rules": [{"from_keyspace": "customer", "key_range": "40a0-40a1", "to_keyspace": "vip", "to_shard": "-"}]}'`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE:                  commandApplyKeyspaceIdRoutingRules,
+	}
+	// GetKeyspaceIdRoutingRules makes a GetKeyspaceIdRoutingRules gRPC call to a vtctld.
+	GetKeyspaceIdRoutingRules = &cobra.Command{
+		Use:                   "GetKeyspaceIdRoutingRules",
+		Short:                 "Displays the currently active keyspace id routing rules as a JSON document.",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+		RunE:                  commandGetKeyspaceIdRoutingRules,
+	}
+)
+
+var applyKeyspaceIdRoutingRulesOptions = struct {
+	Rules         string
+	RulesFilePath string
+	Cells         []string
+	SkipRebuild   bool
+	DryRun        bool
+}{}
+
+func commandApplyKeyspaceIdRoutingRules(cmd *cobra.Command, args []string) error {
+	if applyKeyspaceIdRoutingRulesOptions.Rules != "" && applyKeyspaceIdRoutingRulesOptions.RulesFilePath != "" {
+		return fmt.Errorf("cannot pass both --rules (=%s) and --rules-file (=%s)", applyKeyspaceIdRoutingRulesOptions.Rules, applyKeyspaceIdRoutingRulesOptions.RulesFilePath)
+	}
+
+	if applyKeyspaceIdRoutingRulesOptions.Rules == "" && applyKeyspaceIdRoutingRulesOptions.RulesFilePath == "" {
+		return errors.New("must pass exactly one of --rules or --rules-file")
+	}
+
+	cli.FinishedParsing(cmd)
+
+	var rulesBytes []byte
+	if applyKeyspaceIdRoutingRulesOptions.RulesFilePath != "" {
+		data, err := os.ReadFile(applyKeyspaceIdRoutingRulesOptions.RulesFilePath)
+		if err != nil {
+			return err
+		}
+
+		rulesBytes = data
+	} else {
+		rulesBytes = []byte(applyKeyspaceIdRoutingRulesOptions.Rules)
+	}
+
+	krr := &vschemapb.KeyspaceIdRoutingRules{}
+	if err := json2.Unmarshal(rulesBytes, &krr); err != nil {
+		return err
+	}
+	// Round-trip so when we display the result it's readable.
+	data, err := cli.MarshalJSON(krr)
+	if err != nil {
+		return err
+	}
+
+	if applyKeyspaceIdRoutingRulesOptions.DryRun {
+		if _, err := vindexes.BuildKeyspaceIDRoutingRules(krr); err != nil {
+			return err
+		}
+
+		fmt.Printf("[DRY RUN] Would have saved new KeyspaceIdRoutingRules object:\n%s\n", data)
+
+		if applyKeyspaceIdRoutingRulesOptions.SkipRebuild {
+			fmt.Println("[DRY RUN] Would not have rebuilt VSchema graph, would have required operator to run RebuildVSchemaGraph for changes to take effect.")
+		} else {
+			fmt.Print("[DRY RUN] Would have rebuilt the VSchema graph")
+			if len(applyKeyspaceIdRoutingRulesOptions.Cells) == 0 {
+				fmt.Print(" in all cells\n")
+			} else {
+				fmt.Printf(" in the following cells: %s.\n", strings.Join(applyKeyspaceIdRoutingRulesOptions.Cells, ", "))
+			}
+		}
+
+		return nil
+	}
+
+	_, err = client.ApplyKeyspaceIdRoutingRules(commandCtx, &vtctldatapb.ApplyKeyspaceIdRoutingRulesRequest{
+		KeyspaceIdRoutingRules: krr,
+		SkipRebuild:            applyKeyspaceIdRoutingRulesOptions.SkipRebuild,
+		RebuildCells:           applyKeyspaceIdRoutingRulesOptions.Cells,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("New KeyspaceIdRoutingRules object:\n%s\nIf this is not what you expected, check the input data (as JSON parsing will skip unexpected fields).\n", data)
+
+	if applyKeyspaceIdRoutingRulesOptions.SkipRebuild {
+		fmt.Println("Skipping rebuild of VSchema graph as requested, you will need to run RebuildVSchemaGraph for the changes to take effect.")
+	}
+
+	return nil
+}
+
+func commandGetKeyspaceIdRoutingRules(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.GetKeyspaceIdRoutingRules(commandCtx, &vtctldatapb.GetKeyspaceIdRoutingRulesRequest{})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp.KeyspaceIdRoutingRules)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func init() {
+	ApplyKeyspaceIdRoutingRules.Flags().StringVarP(&applyKeyspaceIdRoutingRulesOptions.Rules, "rules", "r", "", "Keyspace id routing rules, specified as a string")
+	ApplyKeyspaceIdRoutingRules.Flags().StringVarP(&applyKeyspaceIdRoutingRulesOptions.RulesFilePath, "rules-file", "f", "", "Path to a file containing keyspace id routing rules specified as JSON")
+	ApplyKeyspaceIdRoutingRules.Flags().StringSliceVarP(&applyKeyspaceIdRoutingRulesOptions.Cells, "cells", "c", nil, "Limit the VSchema graph rebuilding to the specified cells. Ignored if --skip-rebuild is specified.")
+	ApplyKeyspaceIdRoutingRules.Flags().BoolVar(&applyKeyspaceIdRoutingRulesOptions.SkipRebuild, "skip-rebuild", false, "Skip rebuilding the SrvVSchema objects.")
+	ApplyKeyspaceIdRoutingRules.Flags().BoolVarP(&applyKeyspaceIdRoutingRulesOptions.DryRun, "dry-run", "d", false, "Validate the specified keyspace id routing rules and note actions that would be taken, but do not actually apply the rules to the topo.")
+	Root.AddCommand(ApplyKeyspaceIdRoutingRules)
+
+	Root.AddCommand(GetKeyspaceIdRoutingRules)
+}
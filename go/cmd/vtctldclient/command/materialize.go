@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/json2"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// Materialize is a parent command for Materialize* sub commands.
+	Materialize = &cobra.Command{
+		Use:   "Materialize --target-keyspace <keyspace> --workflow <workflow> [command]",
+		Short: "Materializes the results of queries on a source keyspace into tables of a target keyspace.",
+		Long: `Materializes the results of queries on a source keyspace into tables of a target keyspace.
+
+The create command starts a workflow on the primaries of the target keyspace
+that copies the results of the queries and then keeps them up to date.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+
+	// MaterializeCreate makes a MaterializeCreate gRPC call to a vtctld.
+	MaterializeCreate = &cobra.Command{
+		Use:                   "create",
+		Short:                 "Creates a workflow materializing queries on the source keyspace into tables of the target keyspace.",
+		Example:               `vtctldclient --server=localhost:15999 Materialize --target-keyspace customer --workflow sales_by_sku create --source-keyspace commerce --table-settings '[{"target_table": "sales_by_sku", "source_expression": "select sku, count(*) as orders from corder group by sku", "create_ddl": "copy"}]'`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Create"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandMaterializeCreate,
+	}
+)
+
+var (
+	materializeOptions = struct {
+		TargetKeyspace string
+		Workflow       string
+	}{}
+	materializeCreateOptions = struct {
+		SourceKeyspace     string
+		TableSettings      string
+		Cells              []string
+		TabletTypes        []string
+		ExternalCluster    string
+		SourceShards       []string
+		OnDDL              string
+		StopAfterCopy      bool
+		DeferSecondaryKeys bool
+	}{}
+)
+
+func commandMaterializeCreate(cmd *cobra.Command, args []string) error {
+	settings := &vtctldatapb.MaterializeSettings{}
+	if err := json2.Unmarshal([]byte(fmt.Sprintf(`{"table_settings": %s}`, materializeCreateOptions.TableSettings)), settings); err != nil {
+		return fmt.Errorf("invalid table-settings value: %w", err)
+	}
+	if len(settings.TableSettings) == 0 {
+		return fmt.Errorf("table-settings must list at least one table")
+	}
+	if _, err := parseTabletTypes(materializeCreateOptions.TabletTypes); err != nil {
+		return err
+	}
+	if _, ok := binlogdatapb.OnDDLAction_value[strings.ToUpper(materializeCreateOptions.OnDDL)]; !ok {
+		return fmt.Errorf("invalid on-ddl value: %s", materializeCreateOptions.OnDDL)
+	}
+
+	cli.FinishedParsing(cmd)
+
+	settings.Workflow = materializeOptions.Workflow
+	settings.SourceKeyspace = materializeCreateOptions.SourceKeyspace
+	settings.TargetKeyspace = materializeOptions.TargetKeyspace
+	settings.Cell = strings.Join(materializeCreateOptions.Cells, ",")
+	settings.TabletTypes = strings.Join(materializeCreateOptions.TabletTypes, ",")
+	settings.ExternalCluster = materializeCreateOptions.ExternalCluster
+	settings.SourceShards = materializeCreateOptions.SourceShards
+	settings.OnDdl = strings.ToUpper(materializeCreateOptions.OnDDL)
+	settings.StopAfterCopy = materializeCreateOptions.StopAfterCopy
+	settings.DeferSecondaryKeys = materializeCreateOptions.DeferSecondaryKeys
+
+	resp, err := client.MaterializeCreate(commandCtx, &vtctldatapb.MaterializeCreateRequest{
+		Settings: settings,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func init() {
+	Materialize.PersistentFlags().StringVar(&materializeOptions.TargetKeyspace, "target-keyspace", "", "Keyspace the tables are materialized in (required)")
+	Materialize.MarkPersistentFlagRequired("target-keyspace")
+	Materialize.PersistentFlags().StringVarP(&materializeOptions.Workflow, "workflow", "w", "", "Name of the workflow (required)")
+	Materialize.MarkPersistentFlagRequired("workflow")
+	Root.AddCommand(Materialize)
+
+	MaterializeCreate.Flags().StringVar(&materializeCreateOptions.SourceKeyspace, "source-keyspace", "", "Keyspace the queries run on (required)")
+	MaterializeCreate.MarkFlagRequired("source-keyspace")
+	MaterializeCreate.Flags().StringVar(&materializeCreateOptions.TableSettings, "table-settings", "", "JSON array of the target_table, source_expression and create_ddl of each materialized table (required)")
+	MaterializeCreate.MarkFlagRequired("table-settings")
+	MaterializeCreate.Flags().StringSliceVarP(&materializeCreateOptions.Cells, "cells", "c", nil, "Cell(s) or CellAlias(es) (comma-separated) to replicate from")
+	MaterializeCreate.Flags().StringSliceVarP(&materializeCreateOptions.TabletTypes, "tablet-types", "t", nil, "Source tablet types to replicate from (e.g. PRIMARY,REPLICA,RDONLY)")
+	MaterializeCreate.Flags().StringVar(&materializeCreateOptions.ExternalCluster, "external-cluster", "", "Name of the mounted cluster that holds the source keyspace")
+	MaterializeCreate.Flags().StringSliceVar(&materializeCreateOptions.SourceShards, "source-shards", nil, "Only materialize the rows of the given source shards")
+	MaterializeCreate.Flags().StringVar(&materializeCreateOptions.OnDDL, "on-ddl", "IGNORE", "What to do when DDL is encountered in the VReplication stream. Possible values are IGNORE, STOP, EXEC, EXEC_IGNORE, and TRANSLATE")
+	MaterializeCreate.Flags().BoolVar(&materializeCreateOptions.StopAfterCopy, "stop-after-copy", false, "Stop the workflow once the tables are copied")
+	MaterializeCreate.Flags().BoolVar(&materializeCreateOptions.DeferSecondaryKeys, "defer-secondary-keys", false, "Create the secondary keys of the tables once they are copied")
+	Materialize.AddCommand(MaterializeCreate)
+}
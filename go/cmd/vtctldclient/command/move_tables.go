@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// MoveTables is a parent command for MoveTables* sub commands.
+	MoveTables = &cobra.Command{
+		Use:   "MoveTables --target-keyspace <keyspace> --workflow <workflow> [command]",
+		Short: "Moves tables from a source keyspace to a target keyspace.",
+		Long: `Moves tables from a source keyspace to a target keyspace.
+
+The create command starts a workflow on the primaries of the target keyspace
+that copies the tables from the source keyspace and then keeps them up to date.
+The traffic is then switched with "workflow switchtraffic", and the workflow is
+finalized with "workflow complete" or abandoned with "workflow cancel".`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+
+	// MoveTablesCreate makes a MoveTablesCreate gRPC call to a vtctld.
+	MoveTablesCreate = &cobra.Command{
+		Use:                   "create",
+		Short:                 "Creates a workflow copying tables from the source keyspace to the target keyspace.",
+		Example:               `vtctldclient --server=localhost:15999 MoveTables --target-keyspace customer --workflow commerce2customer create --source-keyspace commerce --tables customer,corder`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Create"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandMoveTablesCreate,
+	}
+)
+
+var (
+	moveTablesOptions = struct {
+		TargetKeyspace string
+		Workflow       string
+	}{}
+	moveTablesCreateOptions = struct {
+		SourceKeyspace      string
+		Cells               []string
+		TabletTypes         []string
+		Tables              []string
+		ExcludeTables       []string
+		AllTables           bool
+		SourceShards        []string
+		ExternalClusterName string
+		SourceTimeZone      string
+		OnDDL               string
+		StopAfterCopy       bool
+		DropForeignKeys     bool
+		DeferSecondaryKeys  bool
+		AutoStart           bool
+	}{}
+)
+
+func commandMoveTablesCreate(cmd *cobra.Command, args []string) error {
+	if moveTablesCreateOptions.AllTables == (len(moveTablesCreateOptions.Tables) > 0) {
+		return fmt.Errorf("exactly one of --all-tables and --tables must be specified")
+	}
+	tabletTypes, err := parseTabletTypes(moveTablesCreateOptions.TabletTypes)
+	if err != nil {
+		return err
+	}
+	onddl, ok := binlogdatapb.OnDDLAction_value[strings.ToUpper(moveTablesCreateOptions.OnDDL)]
+	if !ok {
+		return fmt.Errorf("invalid on-ddl value: %s", moveTablesCreateOptions.OnDDL)
+	}
+
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.MoveTablesCreate(commandCtx, &vtctldatapb.MoveTablesCreateRequest{
+		Workflow:            moveTablesOptions.Workflow,
+		SourceKeyspace:      moveTablesCreateOptions.SourceKeyspace,
+		TargetKeyspace:      moveTablesOptions.TargetKeyspace,
+		Cells:               moveTablesCreateOptions.Cells,
+		TabletTypes:         tabletTypes,
+		IncludeTables:       moveTablesCreateOptions.Tables,
+		ExcludeTables:       moveTablesCreateOptions.ExcludeTables,
+		AllTables:           moveTablesCreateOptions.AllTables,
+		SourceShards:        moveTablesCreateOptions.SourceShards,
+		ExternalClusterName: moveTablesCreateOptions.ExternalClusterName,
+		SourceTimeZone:      moveTablesCreateOptions.SourceTimeZone,
+		OnDdl:               binlogdatapb.OnDDLAction(onddl),
+		StopAfterCopy:       moveTablesCreateOptions.StopAfterCopy,
+		DropForeignKeys:     moveTablesCreateOptions.DropForeignKeys,
+		DeferSecondaryKeys:  moveTablesCreateOptions.DeferSecondaryKeys,
+		AutoStart:           moveTablesCreateOptions.AutoStart,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+// parseTabletTypes parses the values of a --tablet-types flag.
+func parseTabletTypes(values []string) ([]topodatapb.TabletType, error) {
+	tabletTypes := make([]topodatapb.TabletType, len(values))
+	for i, tabletType := range values {
+		tt, err := topoproto.ParseTabletType(strings.TrimSpace(tabletType))
+		if err != nil {
+			return nil, err
+		}
+		tabletTypes[i] = tt
+	}
+	return tabletTypes, nil
+}
+
+func init() {
+	MoveTables.PersistentFlags().StringVar(&moveTablesOptions.TargetKeyspace, "target-keyspace", "", "Keyspace the tables are moved to (required)")
+	MoveTables.MarkPersistentFlagRequired("target-keyspace")
+	MoveTables.PersistentFlags().StringVarP(&moveTablesOptions.Workflow, "workflow", "w", "", "Name of the workflow (required)")
+	MoveTables.MarkPersistentFlagRequired("workflow")
+	Root.AddCommand(MoveTables)
+
+	MoveTablesCreate.Flags().StringVar(&moveTablesCreateOptions.SourceKeyspace, "source-keyspace", "", "Keyspace the tables are moved from (required)")
+	MoveTablesCreate.MarkFlagRequired("source-keyspace")
+	MoveTablesCreate.Flags().StringSliceVarP(&moveTablesCreateOptions.Cells, "cells", "c", nil, "Cell(s) or CellAlias(es) (comma-separated) to replicate from")
+	MoveTablesCreate.Flags().StringSliceVarP(&moveTablesCreateOptions.TabletTypes, "tablet-types", "t", nil, "Source tablet types to replicate from (e.g. PRIMARY,REPLICA,RDONLY)")
+	MoveTablesCreate.Flags().StringSliceVar(&moveTablesCreateOptions.Tables, "tables", nil, "Tables to move")
+	MoveTablesCreate.Flags().StringSliceVar(&moveTablesCreateOptions.ExcludeTables, "exclude-tables", nil, "Tables to leave out when --all-tables is specified")
+	MoveTablesCreate.Flags().BoolVar(&moveTablesCreateOptions.AllTables, "all-tables", false, "Move all the tables of the source keyspace")
+	MoveTablesCreate.Flags().StringSliceVar(&moveTablesCreateOptions.SourceShards, "source-shards", nil, "Only move the rows of the given source shards, for a partial MoveTables between keyspaces sharded the same way")
+	MoveTablesCreate.Flags().StringVar(&moveTablesCreateOptions.ExternalClusterName, "external-cluster-name", "", "Name of the mounted cluster that holds the source keyspace")
+	MoveTablesCreate.Flags().StringVar(&moveTablesCreateOptions.SourceTimeZone, "source-time-zone", "", "Time zone the datetimes of the source are stored in, converted to UTC on the target")
+	MoveTablesCreate.Flags().StringVar(&moveTablesCreateOptions.OnDDL, "on-ddl", "IGNORE", "What to do when DDL is encountered in the VReplication stream. Possible values are IGNORE, STOP, EXEC, EXEC_IGNORE, and TRANSLATE")
+	MoveTablesCreate.Flags().BoolVar(&moveTablesCreateOptions.StopAfterCopy, "stop-after-copy", false, "Stop the workflow once the tables are copied")
+	MoveTablesCreate.Flags().BoolVar(&moveTablesCreateOptions.DropForeignKeys, "drop-foreign-keys", false, "Drop the foreign key constraints of the tables created on the target")
+	MoveTablesCreate.Flags().BoolVar(&moveTablesCreateOptions.DeferSecondaryKeys, "defer-secondary-keys", false, "Create the secondary keys of the tables once they are copied")
+	MoveTablesCreate.Flags().BoolVar(&moveTablesCreateOptions.AutoStart, "auto-start", true, "Start the workflow once it is created")
+	MoveTables.AddCommand(MoveTablesCreate)
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// MoveTenant is a parent command for MoveTenant* sub commands.
+	MoveTenant = &cobra.Command{
+		Use:   "MoveTenant --target-keyspace <keyspace> --workflow <workflow> [command]",
+		Short: "Moves the rows of a tenant, i.e. a range of keyspace ids, to a dedicated shard.",
+		Long: `Moves the rows of a tenant, i.e. a range of keyspace ids, to a dedicated shard.
+
+The create command starts a workflow on the target shard that copies the tenant
+rows from the source shards. Once it caught up, switchtraffic adds a keyspace id
+routing rule that sends the reads and writes of the tenant to the target shard,
+and deletes the tenant rows from the source shards. complete then deletes the
+workflow. Before traffic is switched, cancel deletes the workflow and the copied
+rows.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+
+	// MoveTenantCreate makes a MoveTenantCreate gRPC call to a vtctld.
+	MoveTenantCreate = &cobra.Command{
+		Use:                   "create",
+		Short:                 "Creates a workflow copying the rows of a tenant to the target shard.",
+		Example:               `vtctldclient --server=localhost:15999 MoveTenant --target-keyspace vip --workflow acme create --source-keyspace customer --key-range 40a0-40a1 --target-shard -`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Create"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandMoveTenantCreate,
+	}
+
+	// MoveTenantSwitchTraffic makes a MoveTenantSwitchTraffic gRPC call to a vtctld.
+	MoveTenantSwitchTraffic = &cobra.Command{
+		Use:                   "switchtraffic",
+		Short:                 "Routes the reads and writes of the tenant to the target shard, and deletes it from the source shards.",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"SwitchTraffic"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandMoveTenantSwitchTraffic,
+	}
+
+	// MoveTenantComplete makes a MoveTenantComplete gRPC call to a vtctld.
+	MoveTenantComplete = &cobra.Command{
+		Use:                   "complete",
+		Short:                 "Deletes the workflow, and the rows of the tenant left on the source shards, after traffic was switched.",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Complete"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandMoveTenantComplete,
+	}
+
+	// MoveTenantCancel makes a MoveTenantCancel gRPC call to a vtctld.
+	MoveTenantCancel = &cobra.Command{
+		Use:                   "cancel",
+		Short:                 "Deletes the workflow and the rows of the tenant copied to the target shard, before traffic is switched.",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Cancel"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandMoveTenantCancel,
+	}
+)
+
+var (
+	moveTenantOptions = struct {
+		TargetKeyspace string
+		Workflow       string
+	}{}
+	moveTenantCreateOptions = struct {
+		SourceKeyspace string
+		KeyRange       string
+		TargetShard    string
+		Tables         []string
+		Cells          []string
+		TabletTypes    []string
+		OnDDL          string
+	}{}
+	moveTenantSwitchTrafficOptions = struct {
+		MaxReplicationLagAllowed time.Duration
+		Timeout                  time.Duration
+		Cells                    []string
+		DryRun                   bool
+	}{}
+)
+
+func commandMoveTenantCreate(cmd *cobra.Command, args []string) error {
+	tabletTypes := make([]topodatapb.TabletType, len(moveTenantCreateOptions.TabletTypes))
+	for i, tabletType := range moveTenantCreateOptions.TabletTypes {
+		tt, err := topoproto.ParseTabletType(strings.TrimSpace(tabletType))
+		if err != nil {
+			return err
+		}
+		tabletTypes[i] = tt
+	}
+	onddl, ok := binlogdatapb.OnDDLAction_value[strings.ToUpper(moveTenantCreateOptions.OnDDL)]
+	if !ok {
+		return fmt.Errorf("invalid on-ddl value: %s", moveTenantCreateOptions.OnDDL)
+	}
+
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.MoveTenantCreate(commandCtx, &vtctldatapb.MoveTenantCreateRequest{
+		Workflow:       moveTenantOptions.Workflow,
+		SourceKeyspace: moveTenantCreateOptions.SourceKeyspace,
+		KeyRange:       moveTenantCreateOptions.KeyRange,
+		TargetKeyspace: moveTenantOptions.TargetKeyspace,
+		TargetShard:    moveTenantCreateOptions.TargetShard,
+		Tables:         moveTenantCreateOptions.Tables,
+		Cells:          moveTenantCreateOptions.Cells,
+		TabletTypes:    tabletTypes,
+		OnDdl:          binlogdatapb.OnDDLAction(onddl),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandMoveTenantSwitchTraffic(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.MoveTenantSwitchTraffic(commandCtx, &vtctldatapb.MoveTenantSwitchTrafficRequest{
+		TargetKeyspace:           moveTenantOptions.TargetKeyspace,
+		Workflow:                 moveTenantOptions.Workflow,
+		MaxReplicationLagAllowed: protoutil.DurationToProto(moveTenantSwitchTrafficOptions.MaxReplicationLagAllowed),
+		RebuildCells:             moveTenantSwitchTrafficOptions.Cells,
+		DryRun:                   moveTenantSwitchTrafficOptions.DryRun,
+		Timeout:                  protoutil.DurationToProto(moveTenantSwitchTrafficOptions.Timeout),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandMoveTenantComplete(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.MoveTenantComplete(commandCtx, &vtctldatapb.MoveTenantCompleteRequest{
+		TargetKeyspace: moveTenantOptions.TargetKeyspace,
+		Workflow:       moveTenantOptions.Workflow,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandMoveTenantCancel(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.MoveTenantCancel(commandCtx, &vtctldatapb.MoveTenantCancelRequest{
+		TargetKeyspace: moveTenantOptions.TargetKeyspace,
+		Workflow:       moveTenantOptions.Workflow,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func init() {
+	MoveTenant.PersistentFlags().StringVar(&moveTenantOptions.TargetKeyspace, "target-keyspace", "", "Keyspace of the shard the tenant is moved to (required)")
+	MoveTenant.MarkPersistentFlagRequired("target-keyspace")
+	MoveTenant.PersistentFlags().StringVarP(&moveTenantOptions.Workflow, "workflow", "w", "", "Name of the workflow (required)")
+	MoveTenant.MarkPersistentFlagRequired("workflow")
+	Root.AddCommand(MoveTenant)
+
+	MoveTenantCreate.Flags().StringVar(&moveTenantCreateOptions.SourceKeyspace, "source-keyspace", "", "Keyspace the tenant currently lives in (required)")
+	MoveTenantCreate.MarkFlagRequired("source-keyspace")
+	MoveTenantCreate.Flags().StringVar(&moveTenantCreateOptions.KeyRange, "key-range", "", "Range of keyspace ids of the tenant, in the same format as shard names, e.g. 40a0-40a1 (required)")
+	MoveTenantCreate.MarkFlagRequired("key-range")
+	MoveTenantCreate.Flags().StringVar(&moveTenantCreateOptions.TargetShard, "target-shard", "", "Shard of the target keyspace the tenant is moved to (required)")
+	MoveTenantCreate.MarkFlagRequired("target-shard")
+	MoveTenantCreate.Flags().StringSliceVar(&moveTenantCreateOptions.Tables, "tables", nil, "Tables to copy. Defaults to all the tables with a primary vindex in the source keyspace")
+	MoveTenantCreate.Flags().StringSliceVarP(&moveTenantCreateOptions.Cells, "cells", "c", nil, "Cell(s) or CellAlias(es) (comma-separated) to replicate from")
+	MoveTenantCreate.Flags().StringSliceVarP(&moveTenantCreateOptions.TabletTypes, "tablet-types", "t", nil, "Source tablet types to replicate from (e.g. PRIMARY,REPLICA,RDONLY)")
+	MoveTenantCreate.Flags().StringVar(&moveTenantCreateOptions.OnDDL, "on-ddl", "IGNORE", "What to do when DDL is encountered in the VReplication stream. Possible values are IGNORE, STOP, EXEC, EXEC_IGNORE, and TRANSLATE")
+	MoveTenant.AddCommand(MoveTenantCreate)
+
+	MoveTenantSwitchTraffic.Flags().DurationVar(&moveTenantSwitchTrafficOptions.MaxReplicationLagAllowed, "max-replication-lag-allowed", 30*time.Second, "Maximum time since the workflow streams last updated their position")
+	MoveTenantSwitchTraffic.Flags().DurationVar(&moveTenantSwitchTrafficOptions.Timeout, "timeout", 30*time.Second, "Specifies the maximum time to wait for the streams to catch up with the source shards once the writes to the tenant tables are stopped")
+	MoveTenantSwitchTraffic.Flags().StringSliceVarP(&moveTenantSwitchTrafficOptions.Cells, "cells", "c", nil, "Limit the VSchema graph rebuilding to the specified cells")
+	MoveTenantSwitchTraffic.Flags().BoolVarP(&moveTenantSwitchTrafficOptions.DryRun, "dry-run", "d", false, "Check that traffic can be switched and print the routing rule that would be added, without adding it")
+	MoveTenant.AddCommand(MoveTenantSwitchTraffic)
+
+	MoveTenant.AddCommand(MoveTenantComplete)
+	MoveTenant.AddCommand(MoveTenantCancel)
+}
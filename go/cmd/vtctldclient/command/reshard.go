@@ -0,0 +1,134 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// Reshard is a parent command for Reshard* sub commands.
+	Reshard = &cobra.Command{
+		Use:   "Reshard --target-keyspace <keyspace> --workflow <workflow> [command]",
+		Short: "Splits or merges the shards of a keyspace.",
+		Long: `Splits or merges the shards of a keyspace.
+
+The create command starts a workflow on the primaries of the target shards that
+copies the rows of the source shards and then keeps them up to date. The traffic
+is then switched with "workflow switchtraffic", and the workflow is finalized
+with "workflow complete" or abandoned with "workflow cancel".`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+
+	// ReshardCreate makes a ReshardCreate gRPC call to a vtctld.
+	ReshardCreate = &cobra.Command{
+		Use:                   "create",
+		Short:                 "Creates a workflow copying the rows of the source shards to the target shards.",
+		Example:               `vtctldclient --server=localhost:15999 Reshard --target-keyspace customer --workflow cust2cust create --source-shards 0 --target-shards -80,80-`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Create"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandReshardCreate,
+	}
+)
+
+var (
+	reshardOptions = struct {
+		TargetKeyspace string
+		Workflow       string
+	}{}
+	reshardCreateOptions = struct {
+		SourceShards       []string
+		TargetShards       []string
+		Cells              []string
+		TabletTypes        []string
+		SkipSchemaCopy     bool
+		OnDDL              string
+		StopAfterCopy      bool
+		DeferSecondaryKeys bool
+		AutoStart          bool
+	}{}
+)
+
+func commandReshardCreate(cmd *cobra.Command, args []string) error {
+	tabletTypes, err := parseTabletTypes(reshardCreateOptions.TabletTypes)
+	if err != nil {
+		return err
+	}
+	onddl, ok := binlogdatapb.OnDDLAction_value[strings.ToUpper(reshardCreateOptions.OnDDL)]
+	if !ok {
+		return fmt.Errorf("invalid on-ddl value: %s", reshardCreateOptions.OnDDL)
+	}
+
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.ReshardCreate(commandCtx, &vtctldatapb.ReshardCreateRequest{
+		Workflow:           reshardOptions.Workflow,
+		Keyspace:           reshardOptions.TargetKeyspace,
+		SourceShards:       reshardCreateOptions.SourceShards,
+		TargetShards:       reshardCreateOptions.TargetShards,
+		Cells:              reshardCreateOptions.Cells,
+		TabletTypes:        tabletTypes,
+		SkipSchemaCopy:     reshardCreateOptions.SkipSchemaCopy,
+		OnDdl:              binlogdatapb.OnDDLAction(onddl),
+		StopAfterCopy:      reshardCreateOptions.StopAfterCopy,
+		DeferSecondaryKeys: reshardCreateOptions.DeferSecondaryKeys,
+		AutoStart:          reshardCreateOptions.AutoStart,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func init() {
+	Reshard.PersistentFlags().StringVar(&reshardOptions.TargetKeyspace, "target-keyspace", "", "Keyspace whose shards are split or merged (required)")
+	Reshard.MarkPersistentFlagRequired("target-keyspace")
+	Reshard.PersistentFlags().StringVarP(&reshardOptions.Workflow, "workflow", "w", "", "Name of the workflow (required)")
+	Reshard.MarkPersistentFlagRequired("workflow")
+	Root.AddCommand(Reshard)
+
+	ReshardCreate.Flags().StringSliceVar(&reshardCreateOptions.SourceShards, "source-shards", nil, "Shards to copy the rows from (required)")
+	ReshardCreate.MarkFlagRequired("source-shards")
+	ReshardCreate.Flags().StringSliceVar(&reshardCreateOptions.TargetShards, "target-shards", nil, "Shards to copy the rows to (required)")
+	ReshardCreate.MarkFlagRequired("target-shards")
+	ReshardCreate.Flags().StringSliceVarP(&reshardCreateOptions.Cells, "cells", "c", nil, "Cell(s) or CellAlias(es) (comma-separated) to replicate from")
+	ReshardCreate.Flags().StringSliceVarP(&reshardCreateOptions.TabletTypes, "tablet-types", "t", nil, "Source tablet types to replicate from (e.g. PRIMARY,REPLICA,RDONLY)")
+	ReshardCreate.Flags().BoolVar(&reshardCreateOptions.SkipSchemaCopy, "skip-schema-copy", false, "Do not copy the schema of the source shards to the target shards")
+	ReshardCreate.Flags().StringVar(&reshardCreateOptions.OnDDL, "on-ddl", "IGNORE", "What to do when DDL is encountered in the VReplication stream. Possible values are IGNORE, STOP, EXEC, EXEC_IGNORE, and TRANSLATE")
+	ReshardCreate.Flags().BoolVar(&reshardCreateOptions.StopAfterCopy, "stop-after-copy", false, "Stop the workflow once the rows are copied")
+	ReshardCreate.Flags().BoolVar(&reshardCreateOptions.DeferSecondaryKeys, "defer-secondary-keys", false, "Create the secondary keys of the tables once they are copied")
+	ReshardCreate.Flags().BoolVar(&reshardCreateOptions.AutoStart, "auto-start", true, "Start the workflow once it is created")
+	Reshard.AddCommand(ReshardCreate)
+}
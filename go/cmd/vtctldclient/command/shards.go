@@ -17,6 +17,7 @@ limitations under the License.
 package command
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strconv"
 
@@ -85,6 +86,63 @@ that shard.`,
 			skipClientCreationKey: "true",
 		},
 	}
+	// GenerateLegacyShardRanges outputs the shard ranges, and the keyspace id
+	// of each legacy shard, that reproduce an application-level N-shard scheme
+	// with the murmur3, crc32 and jump_consistent_hash vindexes.
+	GenerateLegacyShardRanges = &cobra.Command{
+		Use:   "GenerateLegacyShardRanges <num-shards>",
+		Short: "Print the shard ranges that reproduce a legacy N-shard scheme with the murmur3, crc32 and jump_consistent_hash vindexes.",
+		Long: `Print the shard ranges that reproduce a legacy N-shard scheme with the murmur3, crc32 and jump_consistent_hash vindexes.
+
+When one of these vindexes has its "shards" param set to N, it maps every row that
+the legacy scheme placed in shard i (e.g. crc32(id) % N == i) to the keyspace id
+listed for legacy shard i. Splitting the keyspace into the listed shards places
+each legacy shard in its own Vitess shard.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := strconv.Atoi(cmd.Flags().Arg(0))
+			if err != nil {
+				return err
+			}
+
+			cli.FinishedParsing(cmd)
+
+			shards, err := key.GenerateShardRanges(n)
+			if err != nil {
+				return err
+			}
+			starts, err := key.GenerateShardRangeStarts(n)
+			if err != nil {
+				return err
+			}
+
+			type legacyShard struct {
+				LegacyShard int    `json:"legacy_shard"`
+				Shard       string `json:"shard"`
+				KeyspaceID  string `json:"keyspace_id"`
+			}
+			legacyShards := make([]legacyShard, 0, n)
+			for i, shard := range shards {
+				legacyShards = append(legacyShards, legacyShard{
+					LegacyShard: i,
+					Shard:       shard,
+					KeyspaceID:  hex.EncodeToString(starts[i]),
+				})
+			}
+
+			data, err := cli.MarshalJSON(legacyShards)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s\n", data)
+			return nil
+		},
+		Annotations: map[string]string{
+			skipClientCreationKey: "true",
+		},
+	}
 	// GetShard makes a GetShard gRPC request to a vtctld.
 	GetShard = &cobra.Command{
 		Use:                   "GetShard <keyspace/shard>",
@@ -625,6 +683,7 @@ func init() {
 
 	Root.AddCommand(GetShard)
 	Root.AddCommand(GenerateShardRanges)
+	Root.AddCommand(GenerateLegacyShardRanges)
 
 	RemoveShardCell.Flags().BoolVarP(&removeShardCellOptions.Force, "force", "f", false, "Proceed even if the cell's topology server cannot be reached. The assumption is that you turned down the entire cell, and just need to update the global topo data.")
 	RemoveShardCell.Flags().BoolVarP(&removeShardCellOptions.Recursive, "recursive", "r", false, "Also delete all tablets in that cell beloning to the specified shard.")
@@ -0,0 +1,304 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vdiff"
+
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	// VDiff is a parent command for VDiff* sub commands.
+	VDiff = &cobra.Command{
+		Use:   "VDiff --target-keyspace <keyspace> --workflow <workflow> [command]",
+		Short: "Compares the tables of a vreplication workflow on its source and target.",
+		Long: `Compares the tables of a vreplication workflow on its source and target.
+
+The create command starts a vdiff on the primaries of the target shards, and
+show prints the state and the per table reports of the vdiffs of the workflow,
+by shard.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+
+	// VDiffCreate makes a VDiff gRPC call to a vtctld with the create action.
+	VDiffCreate = &cobra.Command{
+		Use:                   "create [<uuid>]",
+		Short:                 "Starts a vdiff of the workflow.",
+		Example:               `vtctldclient --server=localhost:15999 VDiff --target-keyspace customer --workflow commerce2customer create --checksum`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Create"},
+		Args:                  cobra.MaximumNArgs(1),
+		RunE:                  commandVDiffCreate,
+	}
+
+	// VDiffShow makes a VDiff gRPC call to a vtctld with the show action.
+	VDiffShow = &cobra.Command{
+		Use:                   "show {last | all | <uuid>}",
+		Short:                 "Prints the state and the reports of the vdiffs of the workflow.",
+		Example:               `vtctldclient --server=localhost:15999 VDiff --target-keyspace customer --workflow commerce2customer show last`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Show"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandVDiffShow,
+	}
+
+	// VDiffStop makes a VDiff gRPC call to a vtctld with the stop action.
+	VDiffStop = &cobra.Command{
+		Use:                   "stop <uuid>",
+		Short:                 "Stops a running vdiff.",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Stop"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandVDiffStop,
+	}
+
+	// VDiffResume makes a VDiff gRPC call to a vtctld with the resume action.
+	VDiffResume = &cobra.Command{
+		Use:                   "resume <uuid>",
+		Short:                 "Resumes a stopped or completed vdiff.",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Resume"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandVDiffResume,
+	}
+
+	// VDiffDelete makes a VDiff gRPC call to a vtctld with the delete action.
+	VDiffDelete = &cobra.Command{
+		Use:                   "delete {all | <uuid>}",
+		Short:                 "Deletes the state of a vdiff, or of all the vdiffs of the workflow.",
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Delete"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandVDiffDelete,
+	}
+)
+
+var (
+	vdiffOptions = struct {
+		TargetKeyspace string
+		Workflow       string
+	}{}
+	vdiffCreateOptions = struct {
+		SourceCells           []string
+		TargetCells           []string
+		TabletTypes           []string
+		Tables                []string
+		Limit                 int64
+		FilteredReplWaitTime  time.Duration
+		DebugQuery            bool
+		OnlyPKs               bool
+		UpdateTableStats      bool
+		MaxExtraRowsToCompare int64
+		AutoRetry             bool
+		Checksum              bool
+	}{}
+)
+
+func commandVDiffCreate(cmd *cobra.Command, args []string) error {
+	id := uuid.New()
+	if len(args) == 1 {
+		var err error
+		if id, err = uuid.Parse(args[0]); err != nil {
+			return fmt.Errorf("invalid UUID %q: %v", args[0], err)
+		}
+	}
+	if vdiffCreateOptions.Limit <= 0 {
+		return fmt.Errorf("invalid --limit value (%d), maximum number of rows to compare needs to be greater than 0", vdiffCreateOptions.Limit)
+	}
+
+	cli.FinishedParsing(cmd)
+
+	options := &tabletmanagerdatapb.VDiffOptions{
+		PickerOptions: &tabletmanagerdatapb.VDiffPickerOptions{
+			TabletTypes: strings.Join(vdiffCreateOptions.TabletTypes, ","),
+			SourceCell:  strings.Join(vdiffCreateOptions.SourceCells, ","),
+			TargetCell:  strings.Join(vdiffCreateOptions.TargetCells, ","),
+		},
+		CoreOptions: &tabletmanagerdatapb.VDiffCoreOptions{
+			Tables:                strings.Join(vdiffCreateOptions.Tables, ","),
+			AutoRetry:             vdiffCreateOptions.AutoRetry,
+			MaxRows:               vdiffCreateOptions.Limit,
+			Checksum:              vdiffCreateOptions.Checksum,
+			SamplePct:             100,
+			TimeoutSeconds:        int64(vdiffCreateOptions.FilteredReplWaitTime.Seconds()),
+			MaxExtraRowsToCompare: vdiffCreateOptions.MaxExtraRowsToCompare,
+			UpdateTableStats:      vdiffCreateOptions.UpdateTableStats,
+		},
+		ReportOptions: &tabletmanagerdatapb.VDiffReportOptions{
+			OnlyPks:    vdiffCreateOptions.OnlyPKs,
+			DebugQuery: vdiffCreateOptions.DebugQuery,
+			Format:     "json",
+		},
+	}
+	if _, err := client.VDiff(commandCtx, &vtctldatapb.VDiffRequest{
+		TargetKeyspace: vdiffOptions.TargetKeyspace,
+		Workflow:       vdiffOptions.Workflow,
+		Action:         string(vdiff.CreateAction),
+		Uuid:           id.String(),
+		Options:        options,
+	}); err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(map[string]string{"UUID": id.String()})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandVDiffShow(cmd *cobra.Command, args []string) error {
+	arg := strings.ToLower(args[0])
+	switch arg {
+	case vdiff.AllActionArg, vdiff.LastActionArg:
+	default:
+		if _, err := uuid.Parse(arg); err != nil {
+			return fmt.Errorf("can only show a specific vdiff, please provide a valid UUID, last or all")
+		}
+	}
+
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.VDiff(commandCtx, &vtctldatapb.VDiffRequest{
+		TargetKeyspace: vdiffOptions.TargetKeyspace,
+		Workflow:       vdiffOptions.Workflow,
+		Action:         string(vdiff.ShowAction),
+		ActionArg:      arg,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The tablets return the vdiffs, and the reports of their tables, as
+	// query results. Print their rows by shard, with the JSON reports, such
+	// as the ones saying why a table was not checksummed, left as JSON.
+	rowsByShard := make(map[string][]map[string]any, len(resp.TabletResponses))
+	for shard, tabletResp := range resp.TabletResponses {
+		if tabletResp.Output == nil {
+			continue
+		}
+		qr := sqltypes.Proto3ToResult(tabletResp.Output)
+		rows := make([]map[string]any, 0, len(qr.Rows))
+		for _, row := range qr.Rows {
+			values := make(map[string]any, len(qr.Fields))
+			for i, field := range qr.Fields {
+				val := row[i].ToString()
+				if field.Name == "report" && json.Valid([]byte(val)) {
+					values[field.Name] = json.RawMessage(val)
+					continue
+				}
+				values[field.Name] = val
+			}
+			rows = append(rows, values)
+		}
+		rowsByShard[shard] = rows
+	}
+
+	data, err := cli.MarshalJSON(rowsByShard)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandVDiffStop(cmd *cobra.Command, args []string) error {
+	return vdiffAction(cmd, vdiff.StopAction, args[0], false)
+}
+
+func commandVDiffResume(cmd *cobra.Command, args []string) error {
+	return vdiffAction(cmd, vdiff.ResumeAction, args[0], false)
+}
+
+func commandVDiffDelete(cmd *cobra.Command, args []string) error {
+	return vdiffAction(cmd, vdiff.DeleteAction, args[0], true)
+}
+
+// vdiffAction performs an action on a single vdiff, or on all the vdiffs of
+// the workflow if allowAll is set and arg is all.
+func vdiffAction(cmd *cobra.Command, action vdiff.VDiffAction, arg string, allowAll bool) error {
+	arg = strings.ToLower(arg)
+	if !allowAll || arg != vdiff.AllActionArg {
+		if _, err := uuid.Parse(arg); err != nil {
+			return fmt.Errorf("can only %s a specific vdiff, please provide a valid UUID", action)
+		}
+	}
+
+	cli.FinishedParsing(cmd)
+
+	req := &vtctldatapb.VDiffRequest{
+		TargetKeyspace: vdiffOptions.TargetKeyspace,
+		Workflow:       vdiffOptions.Workflow,
+		Action:         string(action),
+		ActionArg:      arg,
+	}
+	if arg != vdiff.AllActionArg {
+		req.Uuid = arg
+	}
+	if _, err := client.VDiff(commandCtx, req); err != nil {
+		return err
+	}
+
+	fmt.Printf("VDiff %s of %s.%s succeeded\n", action, vdiffOptions.TargetKeyspace, vdiffOptions.Workflow)
+
+	return nil
+}
+
+func init() {
+	VDiff.PersistentFlags().StringVar(&vdiffOptions.TargetKeyspace, "target-keyspace", "", "Target keyspace of the workflow (required)")
+	VDiff.MarkPersistentFlagRequired("target-keyspace")
+	VDiff.PersistentFlags().StringVarP(&vdiffOptions.Workflow, "workflow", "w", "", "Name of the workflow (required)")
+	VDiff.MarkPersistentFlagRequired("workflow")
+	Root.AddCommand(VDiff)
+
+	VDiffCreate.Flags().StringSliceVar(&vdiffCreateOptions.SourceCells, "source-cells", nil, "The source cell(s) to compare from; default is any available cell")
+	VDiffCreate.Flags().StringSliceVar(&vdiffCreateOptions.TargetCells, "target-cells", nil, "The target cell(s) to compare with; default is any available cell")
+	VDiffCreate.Flags().StringSliceVar(&vdiffCreateOptions.TabletTypes, "tablet-types", []string{"in_order:RDONLY", "REPLICA", "PRIMARY"}, "Tablet types to use on the source and target")
+	VDiffCreate.Flags().StringSliceVar(&vdiffCreateOptions.Tables, "tables", nil, "Only run vdiff for these tables in the workflow")
+	VDiffCreate.Flags().Int64Var(&vdiffCreateOptions.Limit, "limit", 1<<63-1, "Max rows to stop comparing after")
+	VDiffCreate.Flags().DurationVar(&vdiffCreateOptions.FilteredReplWaitTime, "filtered-replication-wait-time", 30*time.Second, "Specifies the maximum time to wait, in seconds, for replication to catch up when syncing tablet streams")
+	VDiffCreate.Flags().BoolVar(&vdiffCreateOptions.DebugQuery, "debug-query", false, "Adds a mysql query to the report that can be used for further debugging")
+	VDiffCreate.Flags().BoolVar(&vdiffCreateOptions.OnlyPKs, "only-pks", false, "When reporting missing rows, only show primary keys in the report")
+	VDiffCreate.Flags().BoolVar(&vdiffCreateOptions.UpdateTableStats, "update-table-stats", false, "Update the table statistics, using ANALYZE TABLE, on each table involved in the VDiff during initialization")
+	VDiffCreate.Flags().Int64Var(&vdiffCreateOptions.MaxExtraRowsToCompare, "max-extra-rows-to-compare", 1000, "If there are collation differences between the source and target, you can have rows that are identical but simply returned in a different order from MySQL. We will do a second pass to compare the rows for any actual differences in this case and this flag allows you to control the resources used for this operation")
+	VDiffCreate.Flags().BoolVar(&vdiffCreateOptions.AutoRetry, "auto-retry", true, "Should this vdiff automatically retry and continue in case of recoverable errors")
+	VDiffCreate.Flags().BoolVar(&vdiffCreateOptions.Checksum, "checksum", false, "Compare the tables in primary key ranges using checksums computed on the source and target, and only stream the rows of the ranges which differ. Tables that cannot be checksummed are compared row by row, and their report says why")
+	VDiff.AddCommand(VDiffCreate)
+
+	VDiff.AddCommand(VDiffShow)
+	VDiff.AddCommand(VDiffStop)
+	VDiff.AddCommand(VDiffResume)
+	VDiff.AddCommand(VDiffDelete)
+}
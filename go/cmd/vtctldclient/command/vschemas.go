@@ -17,6 +17,7 @@ limitations under the License.
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -38,6 +39,28 @@ var (
 		Args:                  cobra.ExactArgs(1),
 		RunE:                  commandGetVSchema,
 	}
+	// AlterVindex makes an AlterVindex gRPC call to a vtctld.
+	AlterVindex = &cobra.Command{
+		Use:   "AlterVindex --table=<table> --vindex=<name> [--type=<vindex type>] [--params=k1=v1,k2=v2] [--owner=<table>] --columns=c1,c2,... [--primary [--target-keyspace=<keyspace>]] [--cells=c1,c2,...] [--dry-run] <keyspace>",
+		Short: "Adds a vindex to a table, or replaces its primary vindex, and applies the resulting VSchema.",
+		Long: `Adds a vindex to a table, or replaces its primary vindex, and applies the resulting VSchema.
+
+If the keyspace does not define the vindex yet, --type (and optionally --params and --owner) must be given to create it.
+
+A lookup vindex is added as write_only, and a workflow named after its lookup table with a _vdx suffix backfills the
+lookup table, as with CreateLookupVindex. Once the workflow is done, the vindex is used for reads after ExternalizeVindex.
+
+With --primary, the vindex replaces the primary vindex of the table. If every row of the table maps to the shard that
+currently holds it under the new vindex, the VSchema is simply applied. Otherwise the table is moved into the sharded
+--target-keyspace, with the new primary vindex, by a MoveTables workflow named <table>_alter_vindex, whose traffic is
+then switched as for any MoveTables workflow.
+
+The change can be undone with RollbackAlterVindex, or by cancelling the MoveTables workflow.`,
+		Example:               `vtctldclient --server localhost:15999 AlterVindex --table customer --vindex xxhash --type xxhash --columns email customer`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandAlterVindex,
+	}
 	// ApplyVSchema makes an ApplyVSchema gRPC call to a vtctld.
 	ApplyVSchema = &cobra.Command{
 		Use:                   "ApplyVSchema {--vschema=<vschema> || --vschema-file=<vschema file> || --sql=<sql> || --sql-file=<sql file>} [--cells=c1,c2,...] [--skip-rebuild] [--dry-run] <keyspace>",
@@ -46,8 +69,143 @@ var (
 		Args:                  cobra.ExactArgs(1),
 		RunE:                  commandApplyVSchema,
 	}
+	// RollbackAlterVindex makes a RollbackAlterVindex gRPC call to a vtctld.
+	RollbackAlterVindex = &cobra.Command{
+		Use:                   "RollbackAlterVindex --table=<table> [--cells=c1,c2,...] <keyspace>",
+		Short:                 "Restores the vindexes a table had before the last AlterVindex on it.",
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandRollbackAlterVindex,
+	}
+	// VSchema is a parent command for the commands that check a VSchema
+	// before it is applied.
+	VSchema = &cobra.Command{
+		Use:   "VSchema [command]",
+		Short: "Checks a proposed VSchema against the current VSchema and the schema of the tablets.",
+		Long: `Checks a proposed VSchema against the current VSchema and the schema of the tablets.
+
+The proposed VSchema is given the same way as to ApplyVSchema. Without any of the
+--vschema, --vschema-file, --sql or --sql-file flags, the current VSchema is checked.
+Nothing is saved.`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.NoArgs,
+	}
+	// VSchemaDiff makes a LintVSchema gRPC call to a vtctld and prints the
+	// changes to the VSchema.
+	VSchemaDiff = &cobra.Command{
+		Use:   "diff [--vschema=<vschema> || --vschema-file=<vschema file> || --sql=<sql> || --sql-file=<sql file>] [--query=<query> ...] [--query-plans-file=<file>] <keyspace>",
+		Short: "Prints the changes from the current VSchema of a keyspace to the proposed one, and the queries whose route changes.",
+		Long: `Prints the changes from the current VSchema of a keyspace to the proposed one, and the queries whose route changes.
+
+The queries are planned against both VSchemas. They are given with --query, or with --query-plans-file
+as the output of the /debug/query_plans page of a vtgate, to check the queries in its plan cache.`,
+		Example:               `vtctldclient --server localhost:15999 VSchema diff --sql "alter vschema on customer add vindex xxhash(email) using xxhash" --query-plans-file plans.json customer`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Diff"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandVSchemaDiff,
+	}
+	// VSchemaLint makes a LintVSchema gRPC call to a vtctld and prints the
+	// problems found in the VSchema.
+	VSchemaLint = &cobra.Command{
+		Use:   "lint [--vschema=<vschema> || --vschema-file=<vschema file> || --sql=<sql> || --sql-file=<sql file>] [--skip-tablet-schema] <keyspace>",
+		Short: "Prints the problems found in the proposed VSchema of a keyspace, and fails if any of them is an error.",
+		Long: `Prints the problems found in the proposed VSchema of a keyspace, and fails if any of them is an error.
+
+The checks report tables without a primary vindex, vindexes on columns of the wrong type, lookup vindexes
+without an owner, sequence tables in sharded keyspaces and routing rules that form a cycle. Unless
+--skip-tablet-schema is set, the VSchema is also checked against the schema of the primary tablet of the
+first shard of the keyspace.`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Lint"},
+		Args:                  cobra.ExactArgs(1),
+		RunE:                  commandVSchemaLint,
+	}
 )
 
+var alterVindexOptions = struct {
+	Table   string
+	Vindex  string
+	Type    string
+	Params  map[string]string
+	Columns []string
+	Owner   string
+	Primary bool
+	Cells   []string
+	DryRun  bool
+
+	TargetKeyspace string
+}{}
+
+func commandAlterVindex(cmd *cobra.Command, args []string) error {
+	req := &vtctldatapb.AlterVindexRequest{
+		Keyspace:   cmd.Flags().Arg(0),
+		Table:      alterVindexOptions.Table,
+		VindexName: alterVindexOptions.Vindex,
+		Columns:    alterVindexOptions.Columns,
+		Primary:    alterVindexOptions.Primary,
+		Cells:      alterVindexOptions.Cells,
+		DryRun:     alterVindexOptions.DryRun,
+
+		TargetKeyspace: alterVindexOptions.TargetKeyspace,
+	}
+	if alterVindexOptions.Type != "" {
+		req.Vindex = &vschemapb.Vindex{
+			Type:   alterVindexOptions.Type,
+			Params: alterVindexOptions.Params,
+			Owner:  alterVindexOptions.Owner,
+		}
+	} else if len(alterVindexOptions.Params) != 0 || alterVindexOptions.Owner != "" {
+		return fmt.Errorf("--params and --owner require --type")
+	}
+	if alterVindexOptions.TargetKeyspace != "" && !alterVindexOptions.Primary {
+		return fmt.Errorf("--target-keyspace requires --primary")
+	}
+
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.AlterVindex(commandCtx, req)
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+var rollbackAlterVindexOptions = struct {
+	Table string
+	Cells []string
+}{}
+
+func commandRollbackAlterVindex(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.RollbackAlterVindex(commandCtx, &vtctldatapb.RollbackAlterVindexRequest{
+		Keyspace: cmd.Flags().Arg(0),
+		Table:    rollbackAlterVindexOptions.Table,
+		Cells:    rollbackAlterVindexOptions.Cells,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp.VSchema)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
 var applyVSchemaOptions = struct {
 	VSchema     string
 	VSchemaFile string
@@ -121,6 +279,142 @@ func commandApplyVSchema(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+var vschemaOptions = struct {
+	VSchema          string
+	VSchemaFile      string
+	SQL              string
+	SQLFile          string
+	Queries          []string
+	QueryPlansFile   string
+	SkipTabletSchema bool
+}{}
+
+// readQueryPlans returns the queries of the plans in a file holding the
+// output of the /debug/query_plans page of a vtgate.
+func readQueryPlans(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []struct {
+		Key string
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("cannot parse query plans from %s: %w", path, err)
+	}
+
+	queries := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Key != "" {
+			queries = append(queries, item.Key)
+		}
+	}
+	return queries, nil
+}
+
+func lintVSchema(cmd *cobra.Command) (*vtctldatapb.LintVSchemaResponse, error) {
+	var set int
+	for _, flag := range []string{vschemaOptions.VSchema, vschemaOptions.VSchemaFile, vschemaOptions.SQL, vschemaOptions.SQLFile} {
+		if flag != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("only one of the sql, sql-file, vschema, or vschema-file flags may be specified")
+	}
+
+	req := &vtctldatapb.LintVSchemaRequest{
+		Keyspace:         cmd.Flags().Arg(0),
+		Sql:              vschemaOptions.SQL,
+		Queries:          vschemaOptions.Queries,
+		SkipTabletSchema: vschemaOptions.SkipTabletSchema,
+	}
+
+	if vschemaOptions.SQLFile != "" {
+		sqlBytes, err := os.ReadFile(vschemaOptions.SQLFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Sql = string(sqlBytes)
+	}
+
+	schema := []byte(vschemaOptions.VSchema)
+	if vschemaOptions.VSchemaFile != "" {
+		var err error
+		schema, err = os.ReadFile(vschemaOptions.VSchemaFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(schema) > 0 {
+		var vs vschemapb.Keyspace
+		if err := json2.Unmarshal(schema, &vs); err != nil {
+			return nil, err
+		}
+		req.VSchema = &vs
+	}
+
+	if vschemaOptions.QueryPlansFile != "" {
+		queries, err := readQueryPlans(vschemaOptions.QueryPlansFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Queries = append(req.Queries, queries...)
+	}
+
+	cli.FinishedParsing(cmd)
+
+	return client.LintVSchema(commandCtx, req)
+}
+
+func commandVSchemaDiff(cmd *cobra.Command, args []string) error {
+	resp, err := lintVSchema(cmd)
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(&vtctldatapb.LintVSchemaResponse{
+		Diff:        resp.Diff,
+		PlanChanges: resp.PlanChanges,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandVSchemaLint(cmd *cobra.Command, args []string) error {
+	resp, err := lintVSchema(cmd)
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(&vtctldatapb.LintVSchemaResponse{
+		Findings: resp.Findings,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	var errors int
+	for _, finding := range resp.Findings {
+		if finding.Severity == vtctldatapb.VSchemaLintFinding_ERROR {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("found %d error(s) in the VSchema of %s", errors, cmd.Flags().Arg(0))
+	}
+
+	return nil
+}
+
 func commandGetVSchema(cmd *cobra.Command, args []string) error {
 	cli.FinishedParsing(cmd)
 
@@ -144,6 +438,21 @@ func commandGetVSchema(cmd *cobra.Command, args []string) error {
 }
 
 func init() {
+	AlterVindex.Flags().StringVar(&alterVindexOptions.Table, "table", "", "The table whose vindexes to alter.")
+	AlterVindex.MarkFlagRequired("table")
+	AlterVindex.Flags().StringVar(&alterVindexOptions.Vindex, "vindex", "", "The name of the vindex to add to the table.")
+	AlterVindex.MarkFlagRequired("vindex")
+	AlterVindex.Flags().StringVar(&alterVindexOptions.Type, "type", "", "The type of the vindex. Required if the keyspace does not define the vindex yet.")
+	AlterVindex.Flags().StringToStringVar(&alterVindexOptions.Params, "params", nil, "The params of the vindex, if it is created.")
+	AlterVindex.Flags().StringVar(&alterVindexOptions.Owner, "owner", "", "The table owning the lookup vindex, if it is created.")
+	AlterVindex.Flags().StringSliceVar(&alterVindexOptions.Columns, "columns", nil, "The table columns the vindex maps.")
+	AlterVindex.MarkFlagRequired("columns")
+	AlterVindex.Flags().BoolVar(&alterVindexOptions.Primary, "primary", false, "Replace the primary vindex of the table instead of adding a secondary vindex.")
+	AlterVindex.Flags().StringVar(&alterVindexOptions.TargetKeyspace, "target-keyspace", "", "The sharded keyspace to move the table into with MoveTables, if the new primary vindex maps some rows to another shard.")
+	AlterVindex.Flags().StringSliceVar(&alterVindexOptions.Cells, "cells", nil, "Limits the SrvVSchema rebuild to the specified cells.")
+	AlterVindex.Flags().BoolVar(&alterVindexOptions.DryRun, "dry-run", false, "Validate the change and print the resulting VSchema without saving it.")
+	Root.AddCommand(AlterVindex)
+
 	ApplyVSchema.Flags().StringVar(&applyVSchemaOptions.VSchema, "vschema", "", "VSchema to apply, in JSON form.")
 	ApplyVSchema.Flags().StringVar(&applyVSchemaOptions.VSchemaFile, "vschema-file", "", "Path to a file containing the vschema to apply, in JSON form.")
 	ApplyVSchema.Flags().StringVar(&applyVSchemaOptions.SQL, "sql", "", "A VSchema DDL SQL statement, e.g. `alter table t add vindex hash(id)`.")
@@ -154,4 +463,20 @@ func init() {
 	Root.AddCommand(ApplyVSchema)
 
 	Root.AddCommand(GetVSchema)
+
+	RollbackAlterVindex.Flags().StringVar(&rollbackAlterVindexOptions.Table, "table", "", "The table whose vindexes to restore.")
+	RollbackAlterVindex.MarkFlagRequired("table")
+	RollbackAlterVindex.Flags().StringSliceVar(&rollbackAlterVindexOptions.Cells, "cells", nil, "Limits the SrvVSchema rebuild to the specified cells.")
+	Root.AddCommand(RollbackAlterVindex)
+
+	VSchema.PersistentFlags().StringVar(&vschemaOptions.VSchema, "vschema", "", "The proposed VSchema, in JSON form.")
+	VSchema.PersistentFlags().StringVar(&vschemaOptions.VSchemaFile, "vschema-file", "", "Path to a file containing the proposed VSchema, in JSON form.")
+	VSchema.PersistentFlags().StringVar(&vschemaOptions.SQL, "sql", "", "A VSchema DDL SQL statement to apply to the current VSchema, e.g. `alter table t add vindex hash(id)`.")
+	VSchema.PersistentFlags().StringVar(&vschemaOptions.SQLFile, "sql-file", "", "Path to a file containing a VSchema DDL SQL.")
+	VSchema.PersistentFlags().BoolVar(&vschemaOptions.SkipTabletSchema, "skip-tablet-schema", false, "Do not check the VSchema against the schema of the tablets.")
+	VSchemaDiff.Flags().StringArrayVar(&vschemaOptions.Queries, "query", nil, "A query to plan against both VSchemas. May be repeated.")
+	VSchemaDiff.Flags().StringVar(&vschemaOptions.QueryPlansFile, "query-plans-file", "", "Path to a file containing the output of the /debug/query_plans page of a vtgate, whose queries to plan against both VSchemas.")
+	VSchema.AddCommand(VSchemaDiff)
+	VSchema.AddCommand(VSchemaLint)
+	Root.AddCommand(VSchema)
 }
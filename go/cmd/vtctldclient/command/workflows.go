@@ -20,10 +20,12 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"vitess.io/vitess/go/cmd/vtctldclient/cli"
+	"vitess.io/vitess/go/protoutil"
 	"vitess.io/vitess/go/textutil"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 
@@ -52,6 +54,18 @@ var (
 		RunE:                  commandGetWorkflows,
 	}
 
+	// WorkflowShow makes a GetWorkflows gRPC call to a vtctld, for a single
+	// workflow.
+	WorkflowShow = &cobra.Command{
+		Use:                   "show",
+		Short:                 "Shows the streams of a VReplication workflow, and the estimated progress of its copy phase",
+		Example:               `vtctldclient --server=localhost:15999 workflow --keyspace=customer show --workflow=commerce2customer`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Show"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandWorkflowShow,
+	}
+
 	// WorkflowUpdate makes a WorkflowUpdate gRPC call to a vtctld.
 	WorkflowUpdate = &cobra.Command{
 		Use:                   "update",
@@ -94,6 +108,51 @@ var (
 		},
 		RunE: commandWorkflowUpdate,
 	}
+
+	// WorkflowCancel makes a WorkflowCancel gRPC call to a vtctld.
+	WorkflowCancel = &cobra.Command{
+		Use:                   "cancel",
+		Short:                 "Deletes a MoveTables or Reshard workflow and the data it copied, before any traffic is switched",
+		Example:               `vtctldclient --server=localhost:15999 workflow --keyspace=customer cancel --workflow=commerce2customer`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Cancel"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandWorkflowCancel,
+	}
+
+	// WorkflowComplete makes a WorkflowComplete gRPC call to a vtctld.
+	WorkflowComplete = &cobra.Command{
+		Use:                   "complete",
+		Short:                 "Deletes a MoveTables or Reshard workflow and the moved tables from the source keyspace, once all traffic is switched",
+		Example:               `vtctldclient --server=localhost:15999 workflow --keyspace=customer complete --workflow=commerce2customer`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"Complete"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandWorkflowComplete,
+	}
+
+	// WorkflowSwitchTraffic makes a WorkflowSwitchTraffic gRPC call to a vtctld.
+	WorkflowSwitchTraffic = &cobra.Command{
+		Use:                   "switchtraffic",
+		Short:                 "Switches the reads and writes of a MoveTables or Reshard workflow to the target",
+		Example:               `vtctldclient --server=localhost:15999 workflow --keyspace=customer switchtraffic --workflow=commerce2customer --tablet-types "replica,rdonly"`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"SwitchTraffic"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandWorkflowSwitchTraffic,
+	}
+
+	// WorkflowReverseTraffic makes a WorkflowSwitchTraffic gRPC call to a
+	// vtctld, switching the traffic back to the source.
+	WorkflowReverseTraffic = &cobra.Command{
+		Use:                   "reversetraffic",
+		Short:                 "Switches the reads and writes of a MoveTables or Reshard workflow back to the source",
+		Example:               `vtctldclient --server=localhost:15999 workflow --keyspace=customer reversetraffic --workflow=commerce2customer`,
+		DisableFlagsInUseLine: true,
+		Aliases:               []string{"ReverseTraffic"},
+		Args:                  cobra.NoArgs,
+		RunE:                  commandWorkflowSwitchTraffic,
+	}
 )
 
 var getWorkflowsOptions = struct {
@@ -128,6 +187,28 @@ var (
 	workflowOptions = struct {
 		Keyspace string
 	}{}
+	workflowCancelOptions = struct {
+		Workflow         string
+		KeepData         bool
+		KeepRoutingRules bool
+	}{}
+	workflowCompleteOptions = struct {
+		Workflow         string
+		KeepData         bool
+		KeepRoutingRules bool
+		RenameTables     bool
+	}{}
+	workflowSwitchTrafficOptions = struct {
+		Workflow                 string
+		Cells                    []string
+		TabletTypes              []string
+		MaxReplicationLagAllowed time.Duration
+		EnableReverseReplication bool
+		Timeout                  time.Duration
+	}{}
+	workflowShowOptions = struct {
+		Workflow string
+	}{}
 	workflowUpdateOptions = struct {
 		Workflow    string
 		Cells       []string
@@ -136,6 +217,113 @@ var (
 	}{}
 )
 
+func commandWorkflowCancel(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.WorkflowCancel(commandCtx, &vtctldatapb.WorkflowCancelRequest{
+		Keyspace:         workflowOptions.Keyspace,
+		Workflow:         workflowCancelOptions.Workflow,
+		KeepData:         workflowCancelOptions.KeepData,
+		KeepRoutingRules: workflowCancelOptions.KeepRoutingRules,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandWorkflowComplete(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.WorkflowComplete(commandCtx, &vtctldatapb.WorkflowCompleteRequest{
+		Keyspace:         workflowOptions.Keyspace,
+		Workflow:         workflowCompleteOptions.Workflow,
+		KeepData:         workflowCompleteOptions.KeepData,
+		KeepRoutingRules: workflowCompleteOptions.KeepRoutingRules,
+		RenameTables:     workflowCompleteOptions.RenameTables,
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandWorkflowShow(cmd *cobra.Command, args []string) error {
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.GetWorkflows(commandCtx, &vtctldatapb.GetWorkflowsRequest{
+		Keyspace: workflowOptions.Keyspace,
+		Workflow: workflowShowOptions.Workflow,
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Workflows) == 0 {
+		return fmt.Errorf("workflow %s not found in keyspace %s", workflowShowOptions.Workflow, workflowOptions.Keyspace)
+	}
+
+	data, err := cli.MarshalJSON(resp.Workflows[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
+func commandWorkflowSwitchTraffic(cmd *cobra.Command, args []string) error {
+	tabletTypes, err := parseTabletTypes(workflowSwitchTrafficOptions.TabletTypes)
+	if err != nil {
+		return err
+	}
+	direction := int32(0) // Forward
+	if cmd.Name() == "reversetraffic" {
+		direction = 1 // Backward
+	}
+
+	cli.FinishedParsing(cmd)
+
+	resp, err := client.WorkflowSwitchTraffic(commandCtx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:                 workflowOptions.Keyspace,
+		Workflow:                 workflowSwitchTrafficOptions.Workflow,
+		Cells:                    workflowSwitchTrafficOptions.Cells,
+		TabletTypes:              tabletTypes,
+		MaxReplicationLagAllowed: protoutil.DurationToProto(workflowSwitchTrafficOptions.MaxReplicationLagAllowed),
+		EnableReverseReplication: workflowSwitchTrafficOptions.EnableReverseReplication,
+		Direction:                direction,
+		Timeout:                  protoutil.DurationToProto(workflowSwitchTrafficOptions.Timeout),
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := cli.MarshalJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", data)
+
+	return nil
+}
+
 func commandWorkflowUpdate(cmd *cobra.Command, args []string) error {
 	cli.FinishedParsing(cmd)
 
@@ -184,10 +372,37 @@ func init() {
 	Workflow.PersistentFlags().StringVarP(&workflowOptions.Keyspace, "keyspace", "k", "", "Keyspace context for the workflow (required)")
 	Workflow.MarkPersistentFlagRequired("keyspace")
 	Root.AddCommand(Workflow)
+	WorkflowShow.Flags().StringVarP(&workflowShowOptions.Workflow, "workflow", "w", "", "The workflow you want to show (required)")
+	WorkflowShow.MarkFlagRequired("workflow")
+	Workflow.AddCommand(WorkflowShow)
 	WorkflowUpdate.Flags().StringVarP(&workflowUpdateOptions.Workflow, "workflow", "w", "", "The workflow you want to update (required)")
 	WorkflowUpdate.MarkFlagRequired("workflow")
 	WorkflowUpdate.Flags().StringSliceVarP(&workflowUpdateOptions.Cells, "cells", "c", nil, "New Cell(s) or CellAlias(es) (comma-separated) to replicate from")
 	WorkflowUpdate.Flags().StringSliceVarP(&workflowUpdateOptions.TabletTypes, "tablet-types", "t", nil, "New source tablet types to replicate from (e.g. PRIMARY,REPLICA,RDONLY)")
-	WorkflowUpdate.Flags().StringVar(&workflowUpdateOptions.OnDDL, "on-ddl", "", "New instruction on what to do when DDL is encountered in the VReplication stream. Possible values are IGNORE, STOP, EXEC, and EXEC_IGNORE")
+	WorkflowUpdate.Flags().StringVar(&workflowUpdateOptions.OnDDL, "on-ddl", "", "New instruction on what to do when DDL is encountered in the VReplication stream. Possible values are IGNORE, STOP, EXEC, EXEC_IGNORE, and TRANSLATE")
 	Workflow.AddCommand(WorkflowUpdate)
+
+	WorkflowCancel.Flags().StringVarP(&workflowCancelOptions.Workflow, "workflow", "w", "", "The workflow you want to cancel (required)")
+	WorkflowCancel.MarkFlagRequired("workflow")
+	WorkflowCancel.Flags().BoolVar(&workflowCancelOptions.KeepData, "keep-data", false, "Keep the tables copied to the target keyspace of a MoveTables workflow")
+	WorkflowCancel.Flags().BoolVar(&workflowCancelOptions.KeepRoutingRules, "keep-routing-rules", false, "Keep the routing rules of a MoveTables workflow")
+	Workflow.AddCommand(WorkflowCancel)
+
+	WorkflowComplete.Flags().StringVarP(&workflowCompleteOptions.Workflow, "workflow", "w", "", "The workflow you want to complete (required)")
+	WorkflowComplete.MarkFlagRequired("workflow")
+	WorkflowComplete.Flags().BoolVar(&workflowCompleteOptions.KeepData, "keep-data", false, "Keep the moved tables in the source keyspace of a MoveTables workflow")
+	WorkflowComplete.Flags().BoolVar(&workflowCompleteOptions.KeepRoutingRules, "keep-routing-rules", false, "Keep the routing rules of a MoveTables workflow")
+	WorkflowComplete.Flags().BoolVar(&workflowCompleteOptions.RenameTables, "rename-tables", false, "Rename the moved tables in the source keyspace of a MoveTables workflow instead of dropping them")
+	Workflow.AddCommand(WorkflowComplete)
+
+	for _, cmd := range []*cobra.Command{WorkflowSwitchTraffic, WorkflowReverseTraffic} {
+		cmd.Flags().StringVarP(&workflowSwitchTrafficOptions.Workflow, "workflow", "w", "", "The workflow you want to switch traffic for (required)")
+		cmd.MarkFlagRequired("workflow")
+		cmd.Flags().StringSliceVarP(&workflowSwitchTrafficOptions.Cells, "cells", "c", nil, "Cell(s) or CellAlias(es) (comma-separated) to switch the reads in. Defaults to all cells")
+		cmd.Flags().StringSliceVarP(&workflowSwitchTrafficOptions.TabletTypes, "tablet-types", "t", nil, "Tablet types to switch (e.g. PRIMARY,REPLICA,RDONLY), PRIMARY meaning the writes. Defaults to all of them")
+		cmd.Flags().DurationVar(&workflowSwitchTrafficOptions.MaxReplicationLagAllowed, "max-replication-lag-allowed", 30*time.Second, "Maximum time since the workflow streams last updated their position for the writes to be switched")
+		cmd.Flags().BoolVar(&workflowSwitchTrafficOptions.EnableReverseReplication, "enable-reverse-replication", true, "Start the reverse workflow once the writes are switched")
+		cmd.Flags().DurationVar(&workflowSwitchTrafficOptions.Timeout, "timeout", 30*time.Second, "How long to wait for the streams to catch up once the writes on the source are stopped")
+		Workflow.AddCommand(cmd)
+	}
 }
@@ -0,0 +1,171 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/exit"
+	"vitess.io/vitess/go/vt/grpccommon"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vtgate/vtgateconn"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+
+	// Import and register the gRPC vtgateconn client
+	_ "vitess.io/vitess/go/vt/vtgate/grpcvtgateconn"
+)
+
+/*
+
+  Vtdebezium streams the changes of one or more keyspaces from a vtgate,
+  and writes them as Debezium change events, one JSON document per line.
+
+  Copy the tables of a keyspace, then stream its changes to stdout:
+  vtdebezium \
+        --server vtgate-host.my.domain:15991 \
+        --keyspace commerce
+
+  Stream the changes of two tables from the current position to rotating
+  files, resuming from a local checkpoint after a restart:
+  vtdebezium \
+        --server vtgate-host.my.domain:15991 \
+        --keyspace commerce \
+        --tables customer,corder \
+        --position current \
+        --output /var/lib/vtdebezium/commerce \
+        --checkpoint-file /var/lib/vtdebezium/commerce.pos
+
+*/
+
+var (
+	server         string
+	keyspaces      []string
+	tables         = []string{"/.*"}
+	position       string
+	tabletType           = "replica"
+	name                 = "vitess"
+	output               = "-"
+	maxFileSize    int64 = 64 * 1024 * 1024
+	checkpointFile string
+	minimizeSkew   bool
+)
+
+func initFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&server, "server", server, "vtgate server to connect to")
+	fs.StringSliceVar(&keyspaces, "keyspace", keyspaces, "keyspaces to stream, all of their shards are streamed")
+	fs.StringSliceVar(&tables, "tables", tables, "tables to stream, as table names or /regular expressions/")
+	fs.StringVar(&position, "position", position, "position to start streaming from when there is no checkpoint: empty to copy the tables first, or 'current'")
+	fs.StringVar(&tabletType, "tablet-type", tabletType, "type of the tablets to stream from")
+	fs.StringVar(&name, "name", name, "logical name of the source, used as the prefix of the topics")
+	fs.StringVar(&output, "output", output, "'-' to write to stdout, or a directory to write rotating files to")
+	fs.Int64Var(&maxFileSize, "max-file-size", maxFileSize, "size at which a new output file is started, 0 for no rotation")
+	fs.StringVar(&checkpointFile, "checkpoint-file", checkpointFile, "file storing the position of the stream, which is resumed from it")
+	fs.BoolVar(&minimizeSkew, "minimize-skew", minimizeSkew, "minimize the time skew between the events of the shards")
+
+	grpccommon.RegisterFlags(fs)
+	log.RegisterFlags(fs)
+	logutil.RegisterFlags(fs)
+	acl.RegisterFlags(fs)
+}
+
+func main() {
+	servenv.OnParseFor("vtdebezium", func(fs *pflag.FlagSet) {
+		logger := logutil.NewConsoleLogger()
+		fs.SetOutput(logutil.NewLoggerWriter(logger))
+
+		initFlags(fs)
+		_ = fs.Set("logtostderr", "true")
+	})
+
+	servenv.ParseFlags("vtdebezium")
+
+	defer exit.Recover()
+	defer logutil.Flush()
+
+	if server == "" {
+		log.Exitf("must specify server")
+	}
+	if len(keyspaces) == 0 {
+		log.Exitf("must specify at least one keyspace")
+	}
+	tt, err := topoproto.ParseTabletType(tabletType)
+	if err != nil {
+		log.Exitf("invalid tablet type %s: %v", tabletType, err)
+	}
+
+	vgtid := &binlogdatapb.VGtid{}
+	for _, keyspace := range keyspaces {
+		vgtid.ShardGtids = append(vgtid.ShardGtids, &binlogdatapb.ShardGtid{
+			Keyspace: keyspace,
+			Gtid:     position,
+		})
+	}
+	filter := &binlogdatapb.Filter{}
+	for _, table := range tables {
+		filter.Rules = append(filter.Rules, &binlogdatapb.Rule{Match: table})
+	}
+
+	var sink vtgateconn.DebeziumSink
+	if output == "-" {
+		sink = vtgateconn.NewDebeziumWriterSink(os.Stdout)
+	} else {
+		sink, err = vtgateconn.NewDebeziumFileSink(output, maxFileSize)
+		if err != nil {
+			log.Exitf("cannot open output %s: %v", output, err)
+		}
+	}
+	var checkpointer vtgateconn.DebeziumCheckpointer
+	if checkpointFile != "" {
+		checkpointer = vtgateconn.NewDebeziumFileCheckpointer(checkpointFile)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	conn, err := vtgateconn.Dial(ctx, server)
+	if err != nil {
+		log.Exitf("cannot connect to %s: %v", server, err)
+	}
+	defer conn.Close()
+
+	err = conn.DebeziumStream(ctx, &vtgateconn.DebeziumStreamOptions{
+		Name:         name,
+		TabletType:   tt,
+		VGtid:        vgtid,
+		Filter:       filter,
+		Flags:        &vtgatepb.VStreamFlags{MinimizeSkew: minimizeSkew},
+		Sink:         sink,
+		Checkpointer: checkpointer,
+	})
+	if closeErr := sink.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Exitf("stream failed: %v", err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"vitess.io/vitess/go/vt/srvtopo"
 	_ "vitess.io/vitess/go/vt/status"
 	"vitess.io/vitess/go/vt/vtgate"
+	"vitess.io/vitess/go/vt/vtgate/balancer"
 )
 
 func addStatusParts(vtg *vtgate.VTGate) {
@@ -38,6 +39,9 @@ func addStatusParts(vtg *vtgate.VTGate) {
 	servenv.AddStatusPart("Gateway Status", vtgate.StatusTemplate, func() any {
 		return vtg.GetGatewayCacheStatus()
 	})
+	servenv.AddStatusPart("Tablet Balancer", balancer.StatusTemplate, func() any {
+		return vtg.Gateway().BalancerStatus()
+	})
 	servenv.AddStatusPart("Health Check Cache", discovery.HealthCheckTemplate, func() any {
 		return vtg.Gateway().TabletsCacheStatus()
 	})
@@ -98,15 +98,45 @@ func (gtid filePosGTID) ContainsGTID(other GTID) bool {
 	if !ok {
 		return false
 	}
-	if filePosOther.file < gtid.file {
-		return true
-	}
-	if filePosOther.file > gtid.file {
-		return false
+	if cmp := compareBinlogFiles(filePosOther.file, gtid.file); cmp != 0 {
+		return cmp < 0
 	}
 	return filePosOther.pos <= gtid.pos
 }
 
+// compareBinlogFiles orders two binary log file names. Files that share
+// a base name are ordered by their numeric extension rather than
+// lexicographically, because the extension outgrows its zero padding
+// once the server has rotated through mysql-bin.999999.
+func compareBinlogFiles(a, b string) int {
+	aBase, aSeq, aOK := splitBinlogFile(a)
+	bBase, bSeq, bOK := splitBinlogFile(b)
+	if !aOK || !bOK || aBase != bBase {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+// splitBinlogFile splits a binary log file name into its base name and
+// its sequence number.
+func splitBinlogFile(file string) (string, uint64, bool) {
+	i := strings.LastIndexByte(file, '.')
+	if i < 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(file[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return file[:i], seq, true
+}
+
 // Contains implements GTIDSet.Contains().
 func (gtid filePosGTID) Contains(other GTIDSet) bool {
 	if other == nil {
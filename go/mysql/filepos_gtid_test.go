@@ -87,6 +87,24 @@ func Test_filePosGTID_ContainsGTID(t *testing.T) {
 			args{other: filePosGTID{file: "testfile", pos: 103939867}},
 			false,
 		},
+		{
+			"returns true when the other file is older",
+			fields{file: "binlog.000002", pos: 4},
+			args{other: filePosGTID{file: "binlog.000001", pos: 1234}},
+			true,
+		},
+		{
+			"returns false when the other file is newer",
+			fields{file: "binlog.000001", pos: 1234},
+			args{other: filePosGTID{file: "binlog.000002", pos: 4}},
+			false,
+		},
+		{
+			"it orders files by sequence number once it outgrows its padding",
+			fields{file: "binlog.1000000", pos: 4},
+			args{other: filePosGTID{file: "binlog.999999", pos: 1234}},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -78,3 +78,50 @@ func TestFilePosShouldGetPosition(t *testing.T) {
 	assert.Equalf(t, got.FilePosition.GTIDSet, want.FilePosition.GTIDSet, "got FilePosition: %v; want FilePosition: %v", got.FilePosition.GTIDSet, want.FilePosition.GTIDSet)
 	assert.Equalf(t, got.Position.GTIDSet, got.FilePosition.GTIDSet, "FilePosition and Position don't match when they should for the FilePos flavor")
 }
+
+func TestFilePosReadBinlogEventAcrossRotate(t *testing.T) {
+	listener, sConn, cConn := createSocketPair(t)
+	defer func() {
+		listener.Close()
+		sConn.Close()
+		cConn.Close()
+	}()
+
+	f := NewMySQL56BinlogFormat()
+	s := NewFakeBinlogStream()
+	var events []BinlogEvent
+	add := func(pos uint32, ev func() BinlogEvent) {
+		s.LogPosition = pos
+		events = append(events, ev())
+	}
+	add(0, func() BinlogEvent { return NewFakeRotateEvent(f, s, "binlog.999999") })
+	add(120, func() BinlogEvent { return NewFormatDescriptionEvent(f, s) })
+	add(1307, func() BinlogEvent { return NewXIDEvent(f, s) })
+	add(1350, func() BinlogEvent { return NewRotateEvent(f, s, 4, "binlog.1000000") })
+	add(0, func() BinlogEvent { return NewFakeRotateEvent(f, s, "binlog.1000000") })
+	add(120, func() BinlogEvent { return NewFormatDescriptionEvent(f, s) })
+	add(245, func() BinlogEvent { return NewXIDEvent(f, s) })
+	for _, ev := range events {
+		data := make([]byte, packetHeaderSize+1+len(ev.Bytes()))
+		copy(data[packetHeaderSize+1:], ev.Bytes())
+		require.NoError(t, sConn.writePacket(data))
+	}
+
+	flv := &filePosFlavor{}
+	var positions []GTID
+	for len(positions) < 2 {
+		ev, err := flv.readBinlogEvent(cConn)
+		require.NoError(t, err)
+		if ev.IsGTID() {
+			gtid, _, err := ev.GTID(f)
+			require.NoError(t, err)
+			positions = append(positions, gtid)
+		}
+	}
+	want := []GTID{
+		filePosGTID{file: "binlog.999999", pos: 1307},
+		filePosGTID{file: "binlog.1000000", pos: 245},
+	}
+	assert.Equal(t, want, positions)
+	assert.True(t, positions[1].GTIDSet().Contains(positions[0].GTIDSet()))
+}
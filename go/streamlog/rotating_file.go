@@ -0,0 +1,173 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package streamlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/vt/log"
+)
+
+// RotatingFile is an io.WriteCloser that appends to a file. When the file
+// would grow over its maximum size, it is renamed with the time as a suffix
+// and a new file is started, keeping the given number of the renamed files.
+// Each Write goes to a single file, so that a record is never split.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu sync.Mutex
+	// file is nil when the file could not be reopened after a rotation.
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// NewRotatingFile returns a RotatingFile writing to path. A maxSize of 0
+// never rotates the file, and a maxBackups of 0 keeps all the rotated files.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// Write is part of the io.Writer interface.
+func (rf *RotatingFile) Write(data []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.closed {
+		return 0, fmt.Errorf("log file %s is closed", rf.path)
+	}
+	if rf.file == nil {
+		// A previous rotation could not reopen the file.
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(data)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			if rf.file == nil {
+				return 0, err
+			}
+			log.Errorf("Error rotating log file %s, writing on to it: %v", rf.path, err)
+		}
+	}
+	n, err := rf.file.Write(data)
+	rf.size += int64(n)
+	return n, err
+}
+
+// backupTimeFormat is the format of the time suffix of the renamed files.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// rotate renames the file, starts a new one and removes the oldest renamed
+// files over maxBackups. If the file cannot be renamed, it is reopened, so
+// that the writes go on to it. It is called with mu held.
+func (rf *RotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err == nil {
+		err = os.Rename(rf.path, rf.path+"."+time.Now().UTC().Format(backupTimeFormat))
+	}
+	if openErr := rf.open(); openErr != nil {
+		return openErr
+	}
+	if err != nil {
+		return err
+	}
+	if rf.maxBackups == 0 {
+		return nil
+	}
+
+	backups, err := rf.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > rf.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backups returns the paths of the renamed files, the oldest first. Only the
+// files with a time suffix are returned, so that the other files sharing the
+// prefix of the path are left alone.
+func (rf *RotatingFile) backups() ([]string, error) {
+	dir, prefix := filepath.Dir(rf.path), filepath.Base(rf.path)+"."
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, name[len(prefix):]); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// The time suffixes sort the oldest first.
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Close is part of the io.Closer interface.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	rf.closed = true
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package streamlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotating.log")
+	rf, err := NewRotatingFile(path, 100, 2)
+	require.NoError(t, err)
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	for i := 0; i < 10; i++ {
+		n, err := rf.Write(line)
+		require.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+
+	// Each file holds two lines, the last one the 9th and 10th.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, data, 2*len(line))
+	backups, err := rf.backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	require.NoError(t, rf.Close())
+	_, err = rf.Write(line)
+	assert.EqualError(t, err, "log file "+path+" is closed")
+}
+
+func TestRotatingFileKeepsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotating.log")
+	other := path + ".1.gz"
+	require.NoError(t, os.WriteFile(other, nil, 0600))
+	rf, err := NewRotatingFile(path, 10, 1)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := rf.Write([]byte("0123456789"))
+		require.NoError(t, err)
+	}
+	backups, err := rf.backups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+	assert.FileExists(t, other)
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotating.log")
+	rf, err := NewRotatingFile(path, 10, 1)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	_, err = rf.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	// The file cannot be renamed once it is gone, so it is reopened and the
+	// writes go on to it.
+	require.NoError(t, os.Remove(path))
+	_, err = rf.Write([]byte("abc"))
+	require.NoError(t, err)
+	_, err = rf.Write([]byte("def"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(data))
+}
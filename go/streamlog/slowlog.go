@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package streamlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/log"
+)
+
+var (
+	slowQueryLogFile       string
+	slowQueryLogThreshold  = time.Second
+	slowQueryLogMaxSizeMB  = 100
+	slowQueryLogMaxBackups = 10
+
+	slowQueriesCount = stats.NewCountersWithSingleLabel("SlowQueryLogQueries", "Queries written to the slow query log", "logger_names")
+)
+
+func registerSlowQueryLogFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&slowQueryLogFile, "slow_query_log_file", slowQueryLogFile, "Write the queries that take at least --slow_query_log_threshold to this file, in the MySQL slow query log format")
+	fs.DurationVar(&slowQueryLogThreshold, "slow_query_log_threshold", slowQueryLogThreshold, "Minimum duration of the queries written to --slow_query_log_file")
+	fs.IntVar(&slowQueryLogMaxSizeMB, "slow_query_log_max_size", slowQueryLogMaxSizeMB, "Size in megabytes at which --slow_query_log_file is rotated; 0 never rotates it")
+	fs.IntVar(&slowQueryLogMaxBackups, "slow_query_log_max_backups", slowQueryLogMaxBackups, "Number of rotated slow query log files to keep; 0 keeps all of them")
+}
+
+// SlowLogField is a Vitess specific attribute of a slow query log entry. Its
+// name is prefixed with Vitess_ in the log.
+type SlowLogField struct {
+	Name  string
+	Value string
+}
+
+// SlowLogEntry is a query of the slow query log.
+type SlowLogEntry struct {
+	// Time is the time the query ended.
+	Time time.Time
+	// User is the user running the query, and Host the address it connected
+	// from.
+	User string
+	Host string
+	// QueryTime is the time it took to run the query.
+	QueryTime time.Duration
+	// LockTime is the time the query waited for a lock or a connection. It
+	// is only logged if HasLockTime is set, as vtgate does not wait for
+	// either.
+	LockTime     time.Duration
+	HasLockTime  bool
+	RowsSent     uint64
+	RowsExamined uint64
+	RowsAffected uint64
+	// Database is the keyspace or the database of the query, if any.
+	Database string
+	// Fields are the Vitess specific attributes of the query. The fields
+	// without a value are not logged.
+	Fields []SlowLogField
+	SQL    string
+}
+
+// SlowQuery is implemented by the log messages that are queries, so that
+// they can be written to the slow query log.
+type SlowQuery interface {
+	// TotalTime returns the time it took to run the query.
+	TotalTime() time.Duration
+	// SlowLogEntry returns the entry of the query in the slow query log.
+	SlowLogEntry() *SlowLogEntry
+}
+
+// Format writes the entry in the format of the MySQL slow query log, which
+// tools like pt-query-digest parse:
+//
+//	# Time: 2023-03-01T12:00:00.123456Z
+//	# User@Host: app[app] @  [10.0.0.1]
+//	# Query_time: 1.234567  Lock_time: 0.000000  Rows_sent: 1  Rows_examined: 0  Rows_affected: 0
+//	# Vitess_plan_type: Scatter  Vitess_shard_queries: 4
+//	use commerce;
+//	SET timestamp=1677672000;
+//	select * from customer where email = 'alice@example.com';
+func (e *SlowLogEntry) Format(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Time: %s\n", e.Time.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	fmt.Fprintf(&buf, "# User@Host: %s[%s] @  [%s]\n", e.User, e.User, hostWithoutPort(e.Host))
+	fmt.Fprintf(&buf, "# Query_time: %.6f", e.QueryTime.Seconds())
+	if e.HasLockTime {
+		fmt.Fprintf(&buf, "  Lock_time: %.6f", e.LockTime.Seconds())
+	}
+	fmt.Fprintf(&buf, "  Rows_sent: %d  Rows_examined: %d  Rows_affected: %d\n", e.RowsSent, e.RowsExamined, e.RowsAffected)
+
+	var fields []string
+	for _, field := range e.Fields {
+		if field.Value == "" {
+			continue
+		}
+		// The tools split the attributes on spaces.
+		value := strings.Join(strings.Fields(field.Value), "_")
+		fields = append(fields, fmt.Sprintf("Vitess_%s: %s", field.Name, value))
+	}
+	if len(fields) > 0 {
+		fmt.Fprintf(&buf, "# %s\n", strings.Join(fields, "  "))
+	}
+
+	if e.Database != "" {
+		fmt.Fprintf(&buf, "use %s;\n", e.Database)
+	}
+	fmt.Fprintf(&buf, "SET timestamp=%d;\n", e.Time.Unix())
+	buf.WriteString(strings.TrimSuffix(strings.TrimSpace(e.SQL), ";"))
+	buf.WriteString(";\n")
+
+	// A single Write, so that a RotatingFile never splits an entry.
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// hostWithoutPort returns the host of an address, or the address if it has
+// no port.
+func hostWithoutPort(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// LogSlowQueries writes the messages of the logger that are queries taking at
+// least threshold to w, in the MySQL slow query log format.
+//
+// Returns the channel used for the subscription.
+func LogSlowQueries[T SlowQuery](logger *StreamLogger[T], w io.Writer, threshold time.Duration) chan T {
+	ch := logger.Subscribe("SlowQueryLog")
+	go func() {
+		for message := range ch {
+			if message.TotalTime() < threshold {
+				continue
+			}
+			slowQueriesCount.Add(logger.Name(), 1)
+			if err := message.SlowLogEntry().Format(w); err != nil {
+				log.Errorf("Error writing to the slow query log: %v", err)
+			}
+		}
+	}()
+	return ch
+}
+
+// LogSlowQueriesFromFlags starts writing the slow queries of the logger to
+// --slow_query_log_file, if it is set.
+func LogSlowQueriesFromFlags[T SlowQuery](logger *StreamLogger[T]) (chan T, error) {
+	if slowQueryLogFile == "" {
+		return nil, nil
+	}
+	file, err := NewRotatingFile(slowQueryLogFile, int64(slowQueryLogMaxSizeMB)*1024*1024, slowQueryLogMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Logging the queries of %s taking at least %v to %s", logger.Name(), slowQueryLogThreshold, slowQueryLogFile)
+	return LogSlowQueries(logger, file, slowQueryLogThreshold), nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package streamlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowLogEntryFormat(t *testing.T) {
+	entry := &SlowLogEntry{
+		Time:         time.Date(2023, time.March, 1, 12, 0, 0, 123456000, time.UTC),
+		User:         "app",
+		Host:         "10.0.0.1:51234",
+		QueryTime:    1234567 * time.Microsecond,
+		LockTime:     250 * time.Millisecond,
+		HasLockTime:  true,
+		RowsSent:     1,
+		RowsAffected: 2,
+		Database:     "commerce",
+		Fields: []SlowLogField{
+			{Name: "plan_type", Value: "Scatter"},
+			{Name: "tables", Value: ""},
+			{Name: "shard_queries", Value: "4"},
+			{Name: "session_uuid", Value: "a b"},
+		},
+		SQL: "select * from customer where email = 'alice@example.com';",
+	}
+	var sb strings.Builder
+	require.NoError(t, entry.Format(&sb))
+	want := `# Time: 2023-03-01T12:00:00.123456Z
+# User@Host: app[app] @  [10.0.0.1]
+# Query_time: 1.234567  Lock_time: 0.250000  Rows_sent: 1  Rows_examined: 0  Rows_affected: 2
+# Vitess_plan_type: Scatter  Vitess_shard_queries: 4  Vitess_session_uuid: a_b
+use commerce;
+SET timestamp=1677672000;
+select * from customer where email = 'alice@example.com';
+`
+	assert.Equal(t, want, sb.String())
+
+	// Without a lock time, a database nor fields.
+	entry = &SlowLogEntry{Time: entry.Time, Host: "localhost", SQL: "select 1"}
+	sb.Reset()
+	require.NoError(t, entry.Format(&sb))
+	want = `# Time: 2023-03-01T12:00:00.123456Z
+# User@Host: [] @  [localhost]
+# Query_time: 0.000000  Rows_sent: 0  Rows_examined: 0  Rows_affected: 0
+SET timestamp=1677672000;
+select 1;
+`
+	assert.Equal(t, want, sb.String())
+}
+
+type slowQuery struct {
+	sql       string
+	totalTime time.Duration
+}
+
+func (sq *slowQuery) TotalTime() time.Duration {
+	return sq.totalTime
+}
+
+func (sq *slowQuery) SlowLogEntry() *SlowLogEntry {
+	return &SlowLogEntry{QueryTime: sq.totalTime, SQL: sq.sql}
+}
+
+func TestLogSlowQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slow.log")
+	file, err := NewRotatingFile(path, 0, 0)
+	require.NoError(t, err)
+	defer file.Close()
+
+	logger := New[*slowQuery]("slow", 10)
+	ch := LogSlowQueries(logger, file, time.Second)
+	defer logger.Unsubscribe(ch)
+
+	logger.Send(&slowQuery{sql: "select fast", totalTime: time.Millisecond})
+	logger.Send(&slowQuery{sql: "select slow", totalTime: 2 * time.Second})
+	logger.Send(&slowQuery{sql: "select threshold", totalTime: time.Second})
+
+	var data []byte
+	require.Eventually(t, func() bool {
+		data, err = os.ReadFile(path)
+		require.NoError(t, err)
+		return strings.Contains(string(data), "select threshold;")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, string(data), "# Query_time: 2.000000 ")
+	assert.Contains(t, string(data), "select slow;")
+	assert.NotContains(t, string(data), "select fast")
+}
@@ -243,6 +243,17 @@ func (vtgate *VtgateProcess) TearDown() error {
 	}
 }
 
+// Kill kills the running vtgate process without a graceful shutdown.
+func (vtgate *VtgateProcess) Kill() error {
+	if vtgate.proc == nil || vtgate.exit == nil {
+		return nil
+	}
+	vtgate.proc.Process.Kill()
+	err := <-vtgate.exit
+	vtgate.proc = nil
+	return err
+}
+
 // VtgateProcessInstance returns a Vtgate handle for vtgate process
 // configured with the given Config.
 // The process must be manually started by calling setup()
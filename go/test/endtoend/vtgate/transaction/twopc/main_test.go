@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package twopc tests that the distributed transactions whose commit was
+// interrupted at every step are resolved. The vtgate binary must be built
+// with the debug2PC tag, which fails the commits of the sessions whose user is
+// <step>_FailNow after that step.
+package twopc
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/json2"
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/test/endtoend/cluster"
+	"vitess.io/vitess/go/test/endtoend/utils"
+
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+var (
+	clusterInstance *cluster.LocalProcessCluster
+	vtParams        mysql.ConnParams
+	keyspaceName    = "ks"
+	cell            = "zone1"
+	hostname        = "localhost"
+
+	//go:embed schema.sql
+	SchemaSQL string
+
+	//go:embed vschema.json
+	VSchema string
+)
+
+func TestMain(m *testing.M) {
+	defer cluster.PanicHandler(nil)
+	flag.Parse()
+
+	exitcode, err := func() (int, error) {
+		clusterInstance = cluster.NewCluster(cell, hostname)
+		defer clusterInstance.Teardown()
+
+		// Reserve vtGate port in order to pass it to vtTablet
+		clusterInstance.VtgateGrpcPort = clusterInstance.GetAndReservePort()
+		// The watchdog of the vttablets does not resolve the transactions
+		// within the tests: the recovery of vtgate does.
+		clusterInstance.VtTabletExtraArgs = []string{
+			"--twopc_enable",
+			"--twopc_coordinator_address", fmt.Sprintf("localhost:%d", clusterInstance.VtgateGrpcPort),
+			"--twopc_abandon_age", "3600",
+			"--queryserver-config-transaction-timeout", "5",
+		}
+		clusterInstance.VtGateExtraArgs = []string{
+			"--transaction_mode", "TWOPC",
+			"--twopc_recovery_interval", "1s",
+			"--twopc_recovery_abandon_age", "10s",
+		}
+
+		// Start topo server
+		if err := clusterInstance.StartTopo(); err != nil {
+			return 1, err
+		}
+
+		// Start keyspace
+		keyspace := &cluster.Keyspace{
+			Name:      keyspaceName,
+			SchemaSQL: SchemaSQL,
+			VSchema:   VSchema,
+		}
+		if err := clusterInstance.StartKeyspace(*keyspace, []string{"-80", "80-"}, 0, false); err != nil {
+			return 1, err
+		}
+
+		if err := clusterInstance.StartVtgate(); err != nil {
+			return 1, err
+		}
+		vtParams = clusterInstance.GetVTParams(keyspaceName)
+
+		return m.Run(), nil
+	}()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	} else {
+		os.Exit(exitcode)
+	}
+}
+
+// start deletes the rows of the previous test, and returns a connection of
+// the given user.
+func start(t *testing.T, user string) (*mysql.Conn, func()) {
+	ctx := context.Background()
+	deleteAll := func() {
+		conn, err := mysql.Connect(ctx, &vtParams)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, _ = utils.ExecAllowError(t, conn, "delete from twopc_t1")
+	}
+	deleteAll()
+
+	params := vtParams
+	params.Uname = user
+	conn, err := mysql.Connect(ctx, &params)
+	require.NoError(t, err)
+
+	return conn, func() {
+		conn.Close()
+		deleteAll()
+		cluster.PanicHandler(t)
+	}
+}
+
+// unresolvedTransactions lists the unresolved transactions with vtctldclient.
+func unresolvedTransactions(t *testing.T) []string {
+	output, err := clusterInstance.VtctldClientProcess.ExecuteCommandWithOutput("DistributedTransaction", "list", "--keyspace", keyspaceName)
+	require.NoError(t, err, output)
+	var resp vtctldatapb.GetUnresolvedTransactionsResponse
+	require.NoError(t, json2.Unmarshal([]byte(output), &resp))
+	var dtids []string
+	for _, transaction := range resp.Transactions {
+		dtids = append(dtids, transaction.Dtid)
+	}
+	return dtids
+}
+
+// waitForResolved waits until all the distributed transactions are resolved.
+func waitForResolved(t *testing.T) {
+	require.Eventually(t, func() bool {
+		return len(unresolvedTransactions(t)) == 0
+	}, time.Minute, 500*time.Millisecond, "the distributed transactions were not resolved")
+}
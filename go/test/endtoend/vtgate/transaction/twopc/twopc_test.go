@@ -0,0 +1,182 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twopc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/test/endtoend/utils"
+)
+
+// The rows 1 and 4 are in the -80 and 80- shards.
+const insertBothShards = "insert into twopc_t1(id, col) values (1, 1), (4, 4)"
+
+// failCommit runs a distributed transaction whose commit fails after step.
+func failCommit(t *testing.T, step string) func() {
+	conn, closer := start(t, step+"_FailNow")
+	utils.Exec(t, conn, "begin")
+	utils.Exec(t, conn, insertBothShards)
+	_, err := utils.ExecAllowError(t, conn, "commit")
+	require.ErrorContains(t, err, "Fail After "+step)
+	return closer
+}
+
+func assertCommitted(t *testing.T, committed bool) {
+	conn, err := mysql.Connect(context.Background(), &vtParams)
+	require.NoError(t, err)
+	defer conn.Close()
+	if committed {
+		utils.AssertMatches(t, conn, "select id, col from twopc_t1 order by id", `[[INT64(1) INT64(1)] [INT64(4) INT64(4)]]`)
+	} else {
+		utils.AssertMatches(t, conn, "select id, col from twopc_t1 order by id", `[]`)
+	}
+}
+
+// TestRecovery tests that vtgate resolves the transactions whose commit
+// stopped at every step: the transactions that were not committed on the
+// metadata manager yet are rolled back, the others are committed.
+func TestRecovery(t *testing.T) {
+	for _, tc := range []struct {
+		step      string
+		committed bool
+	}{
+		{step: "TRCreated"},
+		{step: "RMPrepared"},
+		{step: "MMCommitted", committed: true},
+		{step: "RMCommitted", committed: true},
+	} {
+		t.Run(tc.step, func(t *testing.T) {
+			closer := failCommit(t, tc.step)
+			defer closer()
+
+			waitForResolved(t)
+			assertCommitted(t, tc.committed)
+		})
+	}
+}
+
+// TestRecoveryAfterVTGateRestart tests that another vtgate resolves the
+// transactions of a vtgate that died.
+func TestRecoveryAfterVTGateRestart(t *testing.T) {
+	closer := failCommit(t, "MMCommitted")
+	defer closer()
+
+	require.NoError(t, clusterInstance.VtgateProcess.TearDown())
+	require.NoError(t, clusterInstance.VtgateProcess.Setup())
+	require.NoError(t, clusterInstance.WaitForTabletsToHealthyInVtgate())
+
+	waitForResolved(t)
+	assertCommitted(t, true)
+}
+
+// TestRecoveryAfterTabletRestart tests that a transaction is resolved after
+// the primary of one of its shards restarted: a participant restores its
+// prepared transaction from its redo log, so that it can be committed.
+func TestRecoveryAfterTabletRestart(t *testing.T) {
+	closer := failCommit(t, "MMCommitted")
+	defer closer()
+
+	for _, shard := range clusterInstance.Keyspaces[0].Shards {
+		if shard.Name != "80-" {
+			continue
+		}
+		tablet := shard.PrimaryTablet()
+		require.NoError(t, tablet.VttabletProcess.TearDown())
+		tablet.VttabletProcess.ServingStatus = "SERVING"
+		require.NoError(t, tablet.VttabletProcess.Setup())
+	}
+	require.NoError(t, clusterInstance.WaitForTabletsToHealthyInVtgate())
+
+	waitForResolved(t)
+	assertCommitted(t, true)
+}
+
+// hangCommit starts a distributed transaction whose commit hangs after step,
+// and waits until the transaction is created. The returned channel receives
+// the result of the commit.
+func hangCommit(t *testing.T, step string) (<-chan error, func()) {
+	conn, closer := start(t, step+"_Hang")
+	utils.Exec(t, conn, "begin")
+	utils.Exec(t, conn, insertBothShards)
+	committed := make(chan error, 1)
+	go func() {
+		_, err := conn.ExecuteFetch("commit", 1, false)
+		committed <- err
+	}()
+	require.Eventually(t, func() bool {
+		return len(unresolvedTransactions(t)) == 1
+	}, 5*time.Second, 100*time.Millisecond, "the distributed transaction was not created")
+	return committed, closer
+}
+
+// TestVTGateKilledDuringCommit tests that the transaction of a vtgate killed
+// after committing it on the metadata manager, but before committing it on
+// the other participant, is committed by the recovery of the next vtgate.
+func TestVTGateKilledDuringCommit(t *testing.T) {
+	committed, closer := hangCommit(t, "MMCommitted")
+	defer closer()
+
+	_ = clusterInstance.VtgateProcess.Kill()
+	require.Error(t, <-committed)
+	require.NoError(t, clusterInstance.VtgateProcess.Setup())
+	require.NoError(t, clusterInstance.WaitForTabletsToHealthyInVtgate())
+
+	waitForResolved(t)
+	assertCommitted(t, true)
+}
+
+// TestTabletKilledDuringCommit tests that a transaction prepared on a
+// participant whose vttablet is killed during the commit is committed once
+// the vttablet restarted, from its redo log.
+func TestTabletKilledDuringCommit(t *testing.T) {
+	committed, closer := hangCommit(t, "MMCommitted")
+	defer closer()
+
+	for _, shard := range clusterInstance.Keyspaces[0].Shards {
+		if shard.Name != "80-" {
+			continue
+		}
+		tablet := shard.PrimaryTablet()
+		_ = tablet.VttabletProcess.Kill()
+		tablet.VttabletProcess.ServingStatus = "SERVING"
+		require.NoError(t, tablet.VttabletProcess.Setup())
+	}
+	require.NoError(t, clusterInstance.WaitForTabletsToHealthyInVtgate())
+
+	require.ErrorContains(t, <-committed, "Fail After MMCommitted")
+	waitForResolved(t)
+	assertCommitted(t, true)
+}
+
+// TestResolveWithVtctld tests that vtctld resolves a transaction before the
+// recovery of vtgate does.
+func TestResolveWithVtctld(t *testing.T) {
+	closer := failCommit(t, "RMPrepared")
+	defer closer()
+
+	dtids := unresolvedTransactions(t)
+	require.Len(t, dtids, 1)
+	err := clusterInstance.VtctldClientProcess.ExecuteCommand("DistributedTransaction", "resolve", dtids[0])
+	require.NoError(t, err)
+	require.Empty(t, unresolvedTransactions(t))
+	assertCommitted(t, false)
+}
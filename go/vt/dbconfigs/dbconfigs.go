@@ -311,6 +311,18 @@ func (dbcfgs *DBConfigs) Clone() *DBConfigs {
 	return &result
 }
 
+// CloneWithFlavor returns a clone of the DBConfig whose connection
+// parameters use the specified flavor, like a flavor set before
+// InitWithSocket would.
+func (dbcfgs *DBConfigs) CloneWithFlavor(flavor string) *DBConfigs {
+	result := dbcfgs.Clone()
+	result.Flavor = flavor
+	for _, cp := range []*mysql.ConnParams{&result.appParams, &result.dbaParams, &result.filteredParams, &result.replParams, &result.appdebugParams, &result.allprivsParams} {
+		cp.Flavor = flavor
+	}
+	return result
+}
+
 // InitWithSocket will initialize all the necessary connection parameters.
 // Precedence is as follows: if UserConfig settings are set,
 // they supersede all other settings.
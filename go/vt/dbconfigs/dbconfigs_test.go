@@ -258,6 +258,19 @@ func TestAccessors(t *testing.T) {
 	}
 }
 
+func TestCloneWithFlavor(t *testing.T) {
+	dbc := NewTestDBConfigs(mysql.ConnParams{Host: "h"}, mysql.ConnParams{Host: "h"}, "db")
+	clone := dbc.CloneWithFlavor(mysql.FilePosFlavorID)
+	assert.Equal(t, mysql.FilePosFlavorID, clone.Flavor)
+	for _, cp := range []Connector{clone.AppWithDB(), clone.AppDebugWithDB(), clone.AllPrivsWithDB(), clone.DbaWithDB(), clone.FilteredWithDB(), clone.ReplConnector()} {
+		assert.Equal(t, mysql.FilePosFlavorID, cp.connParams.Flavor)
+		assert.Equal(t, "h", cp.connParams.Host)
+	}
+	// The original is unchanged.
+	assert.Equal(t, "", dbc.Flavor)
+	assert.Equal(t, "", dbc.DbaWithDB().connParams.Flavor)
+}
+
 func TestCredentialsFileHUP(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "credentials.json")
 	if err != nil {
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dtids
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/sandboxconn"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	participants := []*querypb.Target{{
+		Keyspace:   "ks",
+		Shard:      "80-",
+		TabletType: topodatapb.TabletType_PRIMARY,
+	}}
+
+	tcases := []struct {
+		state                                                   querypb.TransactionState
+		setRollback, rollbackPrepared, commitPrepared, conclude int64
+	}{{
+		state:            querypb.TransactionState_PREPARE,
+		setRollback:      1,
+		rollbackPrepared: 1,
+		conclude:         1,
+	}, {
+		state:            querypb.TransactionState_ROLLBACK,
+		rollbackPrepared: 1,
+		conclude:         1,
+	}, {
+		state:          querypb.TransactionState_COMMIT,
+		commitPrepared: 1,
+		conclude:       1,
+	}}
+	for _, tcase := range tcases {
+		t.Run(tcase.state.String(), func(t *testing.T) {
+			// The sandbox conn plays both the metadata manager and the participant.
+			sbc := sandboxconn.NewSandboxConn(&topodatapb.Tablet{})
+			sbc.ReadTransactionResults = []*querypb.TransactionMetadata{{
+				Dtid:         "ks:-80:1234",
+				State:        tcase.state,
+				Participants: participants,
+			}}
+			require.NoError(t, Resolve(ctx, sbc, "ks:-80:1234"))
+			assert.EqualValues(t, tcase.setRollback, sbc.SetRollbackCount.Load(), "SetRollbackCount")
+			assert.EqualValues(t, tcase.rollbackPrepared, sbc.RollbackPreparedCount.Load(), "RollbackPreparedCount")
+			assert.EqualValues(t, tcase.commitPrepared, sbc.CommitPreparedCount.Load(), "CommitPreparedCount")
+			assert.EqualValues(t, tcase.conclude, sbc.ConcludeTransactionCount.Load(), "ConcludeTransactionCount")
+		})
+	}
+
+	// An already resolved transaction is left alone.
+	sbc := sandboxconn.NewSandboxConn(&topodatapb.Tablet{})
+	require.NoError(t, Resolve(ctx, sbc, "ks:-80:1234"))
+	assert.EqualValues(t, 0, sbc.ConcludeTransactionCount.Load(), "ConcludeTransactionCount")
+
+	_, err := ShardSession("bad")
+	require.Error(t, err)
+	require.EqualError(t, Resolve(ctx, sbc, "bad"), err.Error())
+}
+
+func TestMultiGoTargets(t *testing.T) {
+	input := []*querypb.Target{{
+		Keyspace: "0",
+	}}
+	err := runTargets(input, func(t *querypb.Target) error {
+		return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "err %s", t.Keyspace)
+	})
+	want := "err 0"
+	require.EqualError(t, err, want, "runTargets(1)")
+
+	input = []*querypb.Target{{
+		Keyspace: "0",
+	}, {
+		Keyspace: "1",
+	}}
+	err = runTargets(input, func(t *querypb.Target) error {
+		return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "err %s", t.Keyspace)
+	})
+	want = "err 0\nerr 1"
+	require.EqualError(t, err, want, "runTargets(2)")
+	wantCode := vtrpcpb.Code_INTERNAL
+	assert.Equal(t, wantCode, vterrors.Code(err), "error code")
+
+	err = runTargets(input, func(t *querypb.Target) error {
+		return nil
+	})
+	require.NoError(t, err)
+}
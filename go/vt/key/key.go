@@ -435,3 +435,25 @@ func GenerateShardRanges(shards int) ([]string, error) {
 
 	return shardRanges, nil
 }
+
+// GenerateShardRangeStarts returns, for each of the shard ranges returned by
+// GenerateShardRanges(shards), the 8 byte keyspace id at the start of that range.
+// Vindexes that reproduce a legacy N-shard scheme map all rows of legacy
+// shard i to the i'th keyspace id, so that they land in the i'th Vitess shard.
+func GenerateShardRangeStarts(shards int) ([][]byte, error) {
+	shardRanges, err := GenerateShardRanges(shards)
+	if err != nil {
+		return nil, err
+	}
+	starts := make([][]byte, 0, len(shardRanges))
+	for _, shardRange := range shardRanges {
+		start := make([]byte, 8)
+		if prefix := strings.Split(shardRange, "-")[0]; prefix != "" {
+			if _, err := hex.Decode(start, []byte(prefix)); err != nil {
+				return nil, err
+			}
+		}
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
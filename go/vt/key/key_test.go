@@ -1587,6 +1587,29 @@ func TestShardCalculatorForShardsGreaterThan512(t *testing.T) {
 	assert.Equal(t, want, got[511], "Invalid mapping for a 512-shard keyspace. Expected %v, got %v", want, got[511])
 }
 
+func TestGenerateShardRangeStarts(t *testing.T) {
+	starts, err := GenerateShardRangeStarts(3)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{0x55, 0, 0, 0, 0, 0, 0, 0},
+		{0xaa, 0, 0, 0, 0, 0, 0, 0},
+	}, starts)
+
+	shardRanges, err := GenerateShardRanges(1000)
+	require.NoError(t, err)
+	starts, err = GenerateShardRangeStarts(1000)
+	require.NoError(t, err)
+	for i, shardRange := range shardRanges {
+		kr, err := ParseShardingSpec(shardRange)
+		require.NoError(t, err)
+		assert.True(t, KeyRangeContains(kr[0], starts[i]), "%x not in %s", starts[i], shardRange)
+	}
+
+	_, err = GenerateShardRangeStarts(0)
+	assert.Error(t, err)
+}
+
 func stringToKeyRange(spec string) *topodatapb.KeyRange {
 	if spec == "" {
 		return nil
@@ -2143,6 +2143,18 @@ func (c *CreateTableEntity) Apply(diff EntityDiff) (Entity, error) {
 	return dup, nil
 }
 
+// ApplyAlterTable applies the given ALTER TABLE statement onto the table defined by this entity. The statement is
+// subject to the same restrictions as the diffs accepted by Apply: each ADD COLUMN clause adds a single column, and
+// CHANGE COLUMN or RENAME INDEX are not supported. This entity is unmodified. If successful, a new CREATE TABLE entity
+// is returned.
+func (c *CreateTableEntity) ApplyAlterTable(alterTable *sqlparser.AlterTable) (*CreateTableEntity, error) {
+	applied, err := c.Apply(&AlterTableEntityDiff{from: c, alterTable: alterTable})
+	if err != nil {
+		return nil, err
+	}
+	return applied.(*CreateTableEntity), nil
+}
+
 // postApplyNormalize runs at the end of apply() and to reorganize/edit things that
 // a MySQL will do implicitly:
 //   - edit or remove keys if referenced columns are dropped
@@ -2132,6 +2132,37 @@ func TestNormalize(t *testing.T) {
 	}
 }
 
+func TestApplyAlterTable(t *testing.T) {
+	newEntity := func(query string) *CreateTableEntity {
+		stmt, err := sqlparser.ParseStrictDDL(query)
+		require.NoError(t, err)
+		c, err := NewCreateTableEntity(stmt.(*sqlparser.CreateTable))
+		require.NoError(t, err)
+		return c
+	}
+	parseAlter := func(query string) *sqlparser.AlterTable {
+		stmt, err := sqlparser.ParseStrictDDL(query)
+		require.NoError(t, err)
+		return stmt.(*sqlparser.AlterTable)
+	}
+	hints := DiffHints{}
+
+	from := newEntity("create table t (id int primary key, i int, key i_idx (i))")
+	applied, err := from.ApplyAlterTable(parseAlter("alter table t add column v varchar(10), drop key i_idx"))
+	require.NoError(t, err)
+	diff, err := applied.Diff(newEntity("create table t (id int primary key, i int, v varchar(10))"), &hints)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	// The entity itself is unmodified.
+	diff, err = from.Diff(newEntity("create table t (id int primary key, i int, key i_idx (i))"), &hints)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	_, err = from.ApplyAlterTable(parseAlter("alter table t drop column x"))
+	assert.EqualError(t, err, (&ApplyColumnNotFoundError{Table: "t", Column: "x"}).Error())
+}
+
 func TestIndexesCoveringForeignKeyColumns(t *testing.T) {
 	sql := `
 		create table t (
@@ -62,6 +62,7 @@ const (
 	StmtPrepare
 	StmtExecute
 	StmtDeallocate
+	StmtKill
 )
 
 // ASTToStatementType returns a StatementType from an AST stmt
@@ -123,6 +124,8 @@ func ASTToStatementType(stmt Statement) StatementType {
 		return StmtExecute
 	case *DeallocateStmt:
 		return StmtDeallocate
+	case *Kill:
+		return StmtKill
 	default:
 		return StmtUnknown
 	}
@@ -248,6 +251,8 @@ func Preview(sql string) StatementType {
 		return StmtPriv
 	case "release":
 		return StmtRelease
+	case "kill":
+		return StmtKill
 	case "rollback":
 		return StmtSRollback
 	}
@@ -314,6 +319,8 @@ func (s StatementType) String() string {
 		return "EXECUTE"
 	case StmtDeallocate:
 		return "DEALLOCATE PREPARE"
+	case StmtKill:
+		return "KILL"
 	default:
 		return "UNKNOWN"
 	}
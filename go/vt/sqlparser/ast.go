@@ -674,6 +674,16 @@ type (
 		Name     IdentifierCI
 	}
 
+	// KillType is an enum for the types of KILL statements
+	KillType int8
+
+	// Kill represents a KILL statement.
+	// More info available on https://dev.mysql.com/doc/refman/8.0/en/kill.html
+	Kill struct {
+		Type          KillType
+		ProcesslistID uint64
+	}
+
 	// IntervalTypes is an enum to get types of intervals
 	IntervalTypes int8
 
@@ -743,6 +753,7 @@ func (*ExplainTab) iStatement()          {}
 func (*PrepareStmt) iStatement()         {}
 func (*ExecuteStmt) iStatement()         {}
 func (*DeallocateStmt) iStatement()      {}
+func (*Kill) iStatement()                {}
 func (*PurgeBinaryLogs) iStatement()     {}
 
 func (*CreateView) iDDLStatement()    {}
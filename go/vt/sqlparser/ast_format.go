@@ -1050,6 +1050,11 @@ func (node *DeallocateStmt) Format(buf *TrackedBuffer) {
 	buf.astPrintf(node, "deallocate %vprepare %v", node.Comments, node.Name)
 }
 
+// Format formats the node.
+func (node *Kill) Format(buf *TrackedBuffer) {
+	buf.astPrintf(node, "kill %s %d", node.Type.ToString(), node.ProcesslistID)
+}
+
 // Format formats the node.
 func (node *CallProc) Format(buf *TrackedBuffer) {
 	buf.astPrintf(node, "call %v(%v)", node.Name, node.Params)
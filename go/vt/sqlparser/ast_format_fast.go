@@ -1390,6 +1390,14 @@ func (node *DeallocateStmt) formatFast(buf *TrackedBuffer) {
 	node.Name.formatFast(buf)
 }
 
+// formatFast formats the node.
+func (node *Kill) formatFast(buf *TrackedBuffer) {
+	buf.WriteString("kill ")
+	buf.WriteString(node.Type.ToString())
+	buf.WriteByte(' ')
+	buf.WriteString(fmt.Sprintf("%d", node.ProcesslistID))
+}
+
 // formatFast formats the node.
 func (node *CallProc) formatFast(buf *TrackedBuffer) {
 	buf.WriteString("call ")
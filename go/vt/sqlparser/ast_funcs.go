@@ -1806,6 +1806,18 @@ func (ty LockType) ToString() string {
 	}
 }
 
+// ToString returns the KillType as a string
+func (ty KillType) ToString() string {
+	switch ty {
+	case ConnectionType:
+		return ConnectionStr
+	case QueryType:
+		return QueryStr
+	default:
+		return "Unknown KillType"
+	}
+}
+
 // ToString returns ShowCommandType as a string
 func (ty ShowCommandType) ToString() string {
 	switch ty {
@@ -1845,6 +1857,8 @@ func (ty ShowCommandType) ToString() string {
 		return OpenTableStr
 	case Plugins:
 		return PluginsStr
+	case Processlist:
+		return ProcesslistStr
 	case Privilege:
 		return PrivilegeStr
 	case ProcedureC:
@@ -1869,6 +1883,8 @@ func (ty ShowCommandType) ToString() string {
 		return VGtidExecGlobalStr
 	case VitessMigrations:
 		return VitessMigrationsStr
+	case VitessQueryDigests:
+		return VitessQueryDigestsStr
 	case VitessReplicationStatus:
 		return VitessReplicationStatusStr
 	case VitessShards:
@@ -2195,6 +2211,11 @@ func convertStringToInt(integer string) int {
 	return val
 }
 
+func convertStringToUInt64(integer string) uint64 {
+	val, _ := strconv.ParseUint(integer, 10, 64)
+	return val
+}
+
 // SplitAndExpression breaks up the Expr into AND-separated conditions
 // and appends them to filters. Outer parenthesis are removed. Precedence
 // should be taken into account if expressions are recombined.
@@ -546,6 +546,7 @@ func (er *astRewriter) sysVarRewrite(cursor *Cursor, node *Variable) {
 		sysvars.Version.Name,
 		sysvars.VersionComment.Name,
 		sysvars.QueryTimeout.Name,
+		sysvars.MaxReplicationLag.Name,
 		sysvars.Workload.Name:
 		found = true
 	}
@@ -19,6 +19,7 @@ package sqlparser
 import (
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
@@ -56,6 +57,9 @@ const (
 	// DirectivePriority specifies the priority of a workload. It should be an integer between 0 and MaxPriorityValue,
 	// where 0 is the highest priority, and MaxPriorityValue is the lowest one.
 	DirectivePriority = "PRIORITY"
+	// DirectiveMaxReplicationLag specifies the maximum replication lag of the replicas that may serve a read,
+	// either as a duration like 5s or as a number of seconds.
+	DirectiveMaxReplicationLag = "MAX_REPLICATION_LAG"
 
 	// MaxPriorityValue specifies the maximum value allowed for the priority query directive. Valid priority values are
 	// between zero and MaxPriorityValue.
@@ -411,6 +415,38 @@ func GetPriorityFromStatement(statement Statement) (string, error) {
 	return priority, nil
 }
 
+// GetMaxReplicationLagFromStatement gets the maximum replication lag from the provided Statement, using
+// DirectiveMaxReplicationLag. It returns 0 when the directive is not set.
+func GetMaxReplicationLagFromStatement(statement Statement) (time.Duration, error) {
+	commentedStatement, ok := statement.(Commented)
+	if !ok {
+		return 0, nil
+	}
+
+	directives := commentedStatement.GetParsedComments().Directives()
+	lag, ok := directives.GetString(DirectiveMaxReplicationLag, "")
+	if !ok || lag == "" {
+		return 0, nil
+	}
+	return ParseMaxReplicationLag(lag)
+}
+
+// ParseMaxReplicationLag parses a maximum replication lag, given either as a duration like 5s or 500ms,
+// or as a number of seconds.
+func ParseMaxReplicationLag(lag string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(lag, 64); err == nil {
+		if seconds < 0 {
+			return 0, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid max replication lag: %s", lag)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(lag)
+	if err != nil || d < 0 {
+		return 0, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid max replication lag: %s", lag)
+	}
+	return d, nil
+}
+
 // Consolidator returns the consolidator option.
 func Consolidator(stmt Statement) querypb.ExecuteOptions_Consolidator {
 	var comments *ParsedComments
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -561,3 +562,45 @@ func TestGetPriorityFromStatement(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMaxReplicationLagFromStatement(t *testing.T) {
+	testCases := []struct {
+		query       string
+		expectedLag time.Duration
+		expectedErr string
+	}{{
+		query: "select * from a_table",
+	}, {
+		query:       "select /*vt+ MAX_REPLICATION_LAG=5s */ * from a_table",
+		expectedLag: 5 * time.Second,
+	}, {
+		query:       "select /*vt+ MAX_REPLICATION_LAG=500ms */ * from a_table",
+		expectedLag: 500 * time.Millisecond,
+	}, {
+		query:       "select /*vt+ MAX_REPLICATION_LAG=10 */ * from a_table",
+		expectedLag: 10 * time.Second,
+	}, {
+		query:       "select /*vt+ MAX_REPLICATION_LAG=1.5 */ * from a_table",
+		expectedLag: 1500 * time.Millisecond,
+	}, {
+		query:       "select /*vt+ MAX_REPLICATION_LAG=-1 */ * from a_table",
+		expectedErr: "invalid max replication lag: -1",
+	}, {
+		query:       "select /*vt+ MAX_REPLICATION_LAG=soon */ * from a_table",
+		expectedErr: "invalid max replication lag: soon",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			stmt, err := Parse(tc.query)
+			require.NoError(t, err)
+			lag, err := GetMaxReplicationLagFromStatement(stmt)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLag, lag)
+		})
+	}
+}
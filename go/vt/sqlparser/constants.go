@@ -267,6 +267,10 @@ const (
 	WriteStr            = "write"
 	LowPriorityWriteStr = "low_priority write"
 
+	// Kill Types
+	ConnectionStr = "connection"
+	QueryStr      = "query"
+
 	// ShowCommand Types
 	CharsetStr                 = " charset"
 	CollationStr               = " collation"
@@ -286,6 +290,7 @@ const (
 	IndexStr                   = " indexes"
 	OpenTableStr               = " open tables"
 	PluginsStr                 = " plugins"
+	ProcesslistStr             = " processlist"
 	PrivilegeStr               = " privileges"
 	ProcedureCStr              = " procedure code"
 	ProcedureStr               = " procedure status"
@@ -299,6 +304,7 @@ const (
 	VGtidExecGlobalStr         = " global vgtid_executed"
 	KeyspaceStr                = " keyspaces"
 	VitessMigrationsStr        = " vitess_migrations"
+	VitessQueryDigestsStr      = " vitess_query_digests"
 	VitessReplicationStatusStr = " vitess_replication_status"
 	VitessShardsStr            = " vitess_shards"
 	VitessTabletsStr           = " vitess_tablets"
@@ -822,6 +828,12 @@ const (
 	LowPriorityWrite
 )
 
+// KillType constants
+const (
+	ConnectionType KillType = iota
+	QueryType
+)
+
 // ShowCommandType constants
 const (
 	UnknownCommandType ShowCommandType = iota
@@ -843,6 +855,7 @@ const (
 	Index
 	OpenTable
 	Plugins
+	Processlist
 	Privilege
 	ProcedureC
 	Procedure
@@ -855,6 +868,7 @@ const (
 	VariableSession
 	VGtidExecGlobal
 	VitessMigrations
+	VitessQueryDigests
 	VitessReplicationStatus
 	VitessShards
 	VitessTablets
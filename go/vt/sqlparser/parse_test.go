@@ -2229,7 +2229,7 @@ var (
 		output: "show processlist",
 	}, {
 		input:  "show full processlist",
-		output: "show processlist",
+		output: "show full processlist",
 	}, {
 		input:  "show profile cpu for query 1",
 		output: "show profile",
@@ -2333,6 +2333,10 @@ var (
 		output: "show keyspaces like '%'",
 	}, {
 		input: "show vitess_metadata variables",
+	}, {
+		input: "show vitess_query_digests",
+	}, {
+		input: "show vitess_query_digests like '%orders%'",
 	}, {
 		input: "show vitess_replication_status",
 	}, {
@@ -2851,6 +2855,18 @@ var (
 	}, {
 		input:  "DROP /* comment */ PREPARE stmt1",
 		output: "deallocate /* comment */ prepare stmt1",
+	}, {
+		input:  "KILL 123",
+		output: "kill connection 123",
+	}, {
+		input:  "kill connection 123",
+		output: "kill connection 123",
+	}, {
+		input:  "kill query 123",
+		output: "kill query 123",
+	}, {
+		input:  "select kill from t",
+		output: "select `kill` from t",
 	}, {
 		input:  `SELECT JSON_PRETTY('{"a":"10","b":"15","x":"25"}')`,
 		output: `select json_pretty('{\"a\":\"10\",\"b\":\"15\",\"x\":\"25\"}') from dual`,
@@ -175,6 +175,7 @@ func markBindVariable(yylex yyLexer, bvar string) {
   vexplainType 	  VExplainType
   intervalType	  IntervalTypes
   lockType LockType
+  killType KillType
   referenceDefinition *ReferenceDefinition
   txAccessModes []TxAccessMode
   txAccessMode TxAccessMode
@@ -290,7 +291,7 @@ func markBindVariable(yylex yyLexer, bvar string) {
 %token <empty> JSON_EXTRACT_OP JSON_UNQUOTE_EXTRACT_OP
 
 // DDL Tokens
-%token <str> CREATE ALTER DROP RENAME ANALYZE ADD FLUSH CHANGE MODIFY DEALLOCATE
+%token <str> CREATE ALTER DROP RENAME ANALYZE ADD FLUSH CHANGE MODIFY DEALLOCATE KILL
 %token <str> REVERT QUERIES
 %token <str> SCHEMA TABLE INDEX VIEW TO IGNORE IF PRIMARY COLUMN SPATIAL FULLTEXT KEY_BLOCK_SIZE CHECK INDEXES
 %token <str> ACTION CASCADE CONSTRAINT FOREIGN NO REFERENCES RESTRICT
@@ -328,7 +329,7 @@ func markBindVariable(yylex yyLexer, bvar string) {
 // SHOW tokens
 %token <str> CODE COLLATION COLUMNS DATABASES ENGINES EVENT EXTENDED FIELDS FULL FUNCTION GTID_EXECUTED
 %token <str> KEYSPACES OPEN PLUGINS PRIVILEGES PROCESSLIST SCHEMAS TABLES TRIGGERS USER
-%token <str> VGTID_EXECUTED VITESS_KEYSPACES VITESS_METADATA VITESS_MIGRATIONS VITESS_REPLICATION_STATUS VITESS_SHARDS VITESS_TABLETS VITESS_TARGET VSCHEMA VITESS_THROTTLED_APPS
+%token <str> VGTID_EXECUTED VITESS_KEYSPACES VITESS_METADATA VITESS_MIGRATIONS VITESS_QUERY_DIGESTS VITESS_REPLICATION_STATUS VITESS_SHARDS VITESS_TABLETS VITESS_TARGET VSCHEMA VITESS_THROTTLED_APPS
 
 // SET tokens
 %token <str> NAMES GLOBAL SESSION ISOLATION LEVEL READ WRITE ONLY REPEATABLE COMMITTED UNCOMMITTED SERIALIZABLE
@@ -421,7 +422,7 @@ func markBindVariable(yylex yyLexer, bvar string) {
 %type <boolean> default_optional first_opt linear_opt jt_exists_opt jt_path_opt partition_storage_opt
 %type <statement> analyze_statement show_statement use_statement purge_statement other_statement
 %type <statement> begin_statement commit_statement rollback_statement savepoint_statement release_statement load_statement
-%type <statement> lock_statement unlock_statement call_statement
+%type <statement> lock_statement unlock_statement call_statement kill_statement
 %type <statement> revert_statement
 %type <strs> comment_opt comment_list
 %type <str> wild_opt check_option_opt cascade_or_local_opt restrict_or_cascade_opt
@@ -578,6 +579,7 @@ func markBindVariable(yylex yyLexer, bvar string) {
 %type <tableAndLockTypes> lock_table_list
 %type <tableAndLockType> lock_table
 %type <lockType> lock_type
+%type <killType> kill_type_opt
 %type <empty> session_or_local_opt
 %type <columnStorage> column_storage
 %type <columnFormat> column_format
@@ -649,6 +651,7 @@ command:
 | prepare_statement
 | execute_statement
 | deallocate_statement
+| kill_statement
 | /*empty*/
 {
   setParseTree(yylex, nil)
@@ -4101,6 +4104,10 @@ show_statement:
   {
     $$ = &ShowThrottledApps{}
   }
+| SHOW VITESS_QUERY_DIGESTS like_opt
+  {
+    $$ = &Show{&ShowBasic{Command: VitessQueryDigests, Filter: $3}}
+  }
 | SHOW VITESS_REPLICATION_STATUS like_opt
   {
     $$ = &Show{&ShowBasic{Command: VitessReplicationStatus, Filter: $3}}
@@ -4170,7 +4177,7 @@ show_statement:
   }
 | SHOW full_opt PROCESSLIST from_database_opt like_or_where_opt
   {
-    $$ = &Show{&ShowOther{Command: string($3)}}
+    $$ = &Show{&ShowBasic{Command: Processlist, Full: $2}}
   }
 | SHOW STORAGE ddl_skip_to_end
   {
@@ -4760,6 +4767,26 @@ deallocate_statement:
     $$ = &DeallocateStmt{Comments: Comments($2).Parsed(), Name: $4}
   }
 
+kill_statement:
+  KILL kill_type_opt INTEGRAL
+  {
+    $$ = &Kill{Type: $2, ProcesslistID: convertStringToUInt64($3)}
+  }
+
+kill_type_opt:
+  /* empty */
+  {
+    $$ = ConnectionType
+  }
+| CONNECTION
+  {
+    $$ = ConnectionType
+  }
+| QUERY
+  {
+    $$ = QueryType
+  }
+
 select_expression_list_opt:
   {
     $$ = nil
@@ -8112,6 +8139,7 @@ non_reserved_keyword:
 | KEY_BLOCK_SIZE
 | KEYS
 | KEYSPACES
+| KILL
 | LANGUAGE
 | LAST
 | LAST_INSERT_ID
@@ -8362,6 +8390,7 @@ non_reserved_keyword:
 | VITESS_METADATA
 | VITESS_MIGRATION
 | VITESS_MIGRATIONS
+| VITESS_QUERY_DIGESTS
 | VITESS_REPLICATION_STATUS
 | VITESS_SHARDS
 | VITESS_TABLETS
@@ -153,10 +153,39 @@ func (ts *Server) RebuildSrvVSchema(ctx context.Context, cells []string) error {
 		}
 	}
 
+	srvVSchema, err := ts.BuildSrvVSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	// now save the SrvVSchema in all cells in parallel
+	wg := sync.WaitGroup{}
+	mu := sync.Mutex{}
+	var finalErr error
+	for _, cell := range cells {
+		wg.Add(1)
+		go func(cell string) {
+			defer wg.Done()
+			if err := ts.UpdateSrvVSchema(ctx, cell, srvVSchema); err != nil {
+				log.Errorf("%v: UpdateSrvVSchema(%v) failed", err, cell)
+				mu.Lock()
+				finalErr = err
+				mu.Unlock()
+			}
+		}(cell)
+	}
+	wg.Wait()
+
+	return finalErr
+}
+
+// BuildSrvVSchema builds the SrvVSchema from the VSchemas of all the
+// keyspaces and the routing rules, without saving it in any cell.
+func (ts *Server) BuildSrvVSchema(ctx context.Context) (*vschemapb.SrvVSchema, error) {
 	// get the keyspaces
 	keyspaces, err := ts.GetKeyspaces(ctx)
 	if err != nil {
-		return fmt.Errorf("GetKeyspaces failed: %v", err)
+		return nil, fmt.Errorf("GetKeyspaces failed: %v", err)
 	}
 
 	// build the SrvVSchema in parallel, protected by mu
@@ -189,35 +218,26 @@ func (ts *Server) RebuildSrvVSchema(ctx context.Context, cells []string) error {
 	}
 	wg.Wait()
 	if finalErr != nil {
-		return finalErr
+		return nil, finalErr
 	}
 
 	rr, err := ts.GetRoutingRules(ctx)
 	if err != nil {
-		return fmt.Errorf("GetRoutingRules failed: %v", err)
+		return nil, fmt.Errorf("GetRoutingRules failed: %v", err)
 	}
 	srvVSchema.RoutingRules = rr
 
 	srr, err := ts.GetShardRoutingRules(ctx)
 	if err != nil {
-		return fmt.Errorf("GetShardRoutingRules failed: %v", err)
+		return nil, fmt.Errorf("GetShardRoutingRules failed: %v", err)
 	}
 	srvVSchema.ShardRoutingRules = srr
 
-	// now save the SrvVSchema in all cells in parallel
-	for _, cell := range cells {
-		wg.Add(1)
-		go func(cell string) {
-			defer wg.Done()
-			if err := ts.UpdateSrvVSchema(ctx, cell, srvVSchema); err != nil {
-				log.Errorf("%v: UpdateSrvVSchema(%v) failed", err, cell)
-				mu.Lock()
-				finalErr = err
-				mu.Unlock()
-			}
-		}(cell)
+	krr, err := ts.GetKeyspaceIdRoutingRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetKeyspaceIdRoutingRules failed: %v", err)
 	}
-	wg.Wait()
+	srvVSchema.KeyspaceIdRoutingRules = krr
 
-	return finalErr
+	return srvVSchema, nil
 }
@@ -165,3 +165,43 @@ func (ts *Server) GetShardRoutingRules(ctx context.Context) (*vschemapb.ShardRou
 	}
 	return srr, nil
 }
+
+// SaveKeyspaceIdRoutingRules first validates the keyspace id routing rules,
+// then saves them into the topo.
+func (ts *Server) SaveKeyspaceIdRoutingRules(ctx context.Context, keyspaceIDRoutingRules *vschemapb.KeyspaceIdRoutingRules) error {
+	if _, err := vindexes.BuildKeyspaceIDRoutingRules(keyspaceIDRoutingRules); err != nil {
+		return err
+	}
+
+	data, err := keyspaceIDRoutingRules.MarshalVT()
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		if err := ts.globalCell.Delete(ctx, KeyspaceIdRoutingRulesFile, nil); err != nil && !IsErrType(err, NoNode) {
+			return err
+		}
+		return nil
+	}
+
+	_, err = ts.globalCell.Update(ctx, KeyspaceIdRoutingRulesFile, data, nil)
+	return err
+}
+
+// GetKeyspaceIdRoutingRules fetches the keyspace id routing rules from the topo.
+func (ts *Server) GetKeyspaceIdRoutingRules(ctx context.Context) (*vschemapb.KeyspaceIdRoutingRules, error) {
+	krr := &vschemapb.KeyspaceIdRoutingRules{}
+	data, _, err := ts.globalCell.Get(ctx, KeyspaceIdRoutingRulesFile)
+	if err != nil {
+		if IsErrType(err, NoNode) {
+			return krr, nil
+		}
+		return nil, err
+	}
+	err = krr.UnmarshalVT(data)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid keyspace id routing rules: %q", data)
+	}
+	return krr, nil
+}
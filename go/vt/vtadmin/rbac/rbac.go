@@ -83,6 +83,11 @@ const (
 	ManageTabletReplicationAction        Action = "manage_tablet_replication" // Start/Stop Replication
 	ManageTabletWritabilityAction        Action = "manage_tablet_writability" // SetRead{Only,Write}
 	RefreshTabletReplicationSourceAction Action = "refresh_tablet_replication_source"
+
+	/* distributed transaction-specific actions */
+
+	ConcludeTransactionAction Action = "conclude_transaction"
+	ResolveTransactionAction  Action = "resolve_transaction"
 )
 
 // Resource is an enum representing all resources managed by vtadmin.
@@ -115,6 +120,7 @@ const (
 	/* misc resources */
 
 	BackupResource                   Resource = "Backup"
+	DistributedTransactionResource   Resource = "DistributedTransaction"
 	SchemaResource                   Resource = "Schema"
 	ShardReplicationPositionResource Resource = "ShardReplicationPosition"
 	WorkflowResource                 Resource = "Workflow"
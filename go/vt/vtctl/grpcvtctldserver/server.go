@@ -41,6 +41,8 @@ import (
 	"vitess.io/vitess/go/trace"
 	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/concurrency"
+	"vitess.io/vitess/go/vt/dtids"
+	"vitess.io/vitess/go/vt/grpcclient"
 	hk "vitess.io/vitess/go/vt/hook"
 	"vitess.io/vitess/go/vt/key"
 	"vitess.io/vitess/go/vt/log"
@@ -58,8 +60,11 @@ import (
 	"vitess.io/vitess/go/vt/topotools/events"
 	"vitess.io/vitess/go/vt/vtctl/reparentutil"
 	"vitess.io/vitess/go/vt/vtctl/schematools"
+	"vitess.io/vitess/go/vt/vtctl/vschemalint"
 	"vitess.io/vitess/go/vt/vtctl/workflow"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/queryservice"
+	"vitess.io/vitess/go/vt/vttablet/tabletconn"
 	"vitess.io/vitess/go/vt/vttablet/tmclient"
 
 	logutilpb "vitess.io/vitess/go/vt/proto/logutil"
@@ -159,6 +164,50 @@ func (s *VtctldServer) AddCellsAlias(ctx context.Context, req *vtctldatapb.AddCe
 	return &vtctldatapb.AddCellsAliasResponse{}, nil
 }
 
+// AlterVindex is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) AlterVindex(ctx context.Context, req *vtctldatapb.AlterVindexRequest) (resp *vtctldatapb.AlterVindexResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.AlterVindex")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("table", req.Table)
+	span.Annotate("vindex_name", req.VindexName)
+	span.Annotate("primary", req.Primary)
+	span.Annotate("cells", strings.Join(req.Cells, ","))
+	span.Annotate("dry_run", req.DryRun)
+
+	resp, err = s.ws.AlterVindex(ctx, req)
+	return resp, err
+}
+
+// ApplyKeyspaceIdRoutingRules is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) ApplyKeyspaceIdRoutingRules(ctx context.Context, req *vtctldatapb.ApplyKeyspaceIdRoutingRulesRequest) (*vtctldatapb.ApplyKeyspaceIdRoutingRulesResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.ApplyKeyspaceIdRoutingRules")
+	defer span.Finish()
+
+	span.Annotate("skip_rebuild", req.SkipRebuild)
+	span.Annotate("rebuild_cells", strings.Join(req.RebuildCells, ","))
+
+	if err := s.ts.SaveKeyspaceIdRoutingRules(ctx, req.KeyspaceIdRoutingRules); err != nil {
+		return nil, err
+	}
+
+	resp := &vtctldatapb.ApplyKeyspaceIdRoutingRulesResponse{}
+
+	if req.SkipRebuild {
+		log.Warningf("Skipping rebuild of SrvVSchema as requested, you will need to run RebuildVSchemaGraph for changes to take effect")
+		return resp, nil
+	}
+
+	if err := s.ts.RebuildSrvVSchema(ctx, req.RebuildCells); err != nil {
+		return nil, vterrors.Wrapf(err, "RebuildSrvVSchema(%v) failed: %v", req.RebuildCells, err)
+	}
+
+	return resp, nil
+}
+
 // ApplyRoutingRules is part of the vtctlservicepb.VtctldServer interface.
 func (s *VtctldServer) ApplyRoutingRules(ctx context.Context, req *vtctldatapb.ApplyRoutingRulesRequest) (resp *vtctldatapb.ApplyRoutingRulesResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.ApplyRoutingRules")
@@ -607,6 +656,47 @@ func (s *VtctldServer) ChangeTabletType(ctx context.Context, req *vtctldatapb.Ch
 	}, nil
 }
 
+// ConcludeTransaction is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) ConcludeTransaction(ctx context.Context, req *vtctldatapb.ConcludeTransactionRequest) (resp *vtctldatapb.ConcludeTransactionResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.ConcludeTransaction")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("dtid", req.Dtid)
+	span.Annotate("force", req.Force)
+
+	mmShard, err := dtids.ShardSession(req.Dtid)
+	if err != nil {
+		return nil, err
+	}
+	qs, err := s.primaryQueryService(ctx, mmShard.Target.Keyspace, mmShard.Target.Shard)
+	if err != nil {
+		return nil, err
+	}
+	defer qs.Close(ctx)
+
+	target := primaryTarget(mmShard.Target)
+	transaction, err := qs.ReadTransaction(ctx, target, req.Dtid)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil || transaction.Dtid == "" {
+		// It was already concluded.
+		return &vtctldatapb.ConcludeTransactionResponse{}, nil
+	}
+	// The metadata manager keeps a transaction until it is resolved on all
+	// its participants, so concluding it may leave them prepared for good.
+	if !req.Force {
+		return nil, vterrors.Errorf(vtrpc.Code_FAILED_PRECONDITION, "transaction %s in state %v is not resolved, resolve it, or force concluding it if its participants were resolved by hand", req.Dtid, transaction.State)
+	}
+	span.Annotate("state", transaction.State.String())
+	if err := qs.ConcludeTransaction(ctx, target, req.Dtid); err != nil {
+		return nil, err
+	}
+	return &vtctldatapb.ConcludeTransactionResponse{}, nil
+}
+
 // CreateKeyspace is part of the vtctlservicepb.VtctldServer interface.
 func (s *VtctldServer) CreateKeyspace(ctx context.Context, req *vtctldatapb.CreateKeyspaceRequest) (resp *vtctldatapb.CreateKeyspaceResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.CreateKeyspace")
@@ -1302,6 +1392,21 @@ func (s *VtctldServer) GetFullStatus(ctx context.Context, req *vtctldatapb.GetFu
 	}, nil
 }
 
+// GetKeyspaceIdRoutingRules is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) GetKeyspaceIdRoutingRules(ctx context.Context, req *vtctldatapb.GetKeyspaceIdRoutingRulesRequest) (*vtctldatapb.GetKeyspaceIdRoutingRulesResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.GetKeyspaceIdRoutingRules")
+	defer span.Finish()
+
+	krr, err := s.ts.GetKeyspaceIdRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vtctldatapb.GetKeyspaceIdRoutingRulesResponse{
+		KeyspaceIdRoutingRules: krr,
+	}, nil
+}
+
 // GetKeyspace is part of the vtctlservicepb.VtctldServer interface.
 func (s *VtctldServer) GetKeyspace(ctx context.Context, req *vtctldatapb.GetKeyspaceRequest) (resp *vtctldatapb.GetKeyspaceResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.GetKeyspace")
@@ -1924,6 +2029,52 @@ func (s *VtctldServer) GetTopologyPath(ctx context.Context, req *vtctldatapb.Get
 	}, nil
 }
 
+// GetUnresolvedTransactions is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) GetUnresolvedTransactions(ctx context.Context, req *vtctldatapb.GetUnresolvedTransactionsRequest) (resp *vtctldatapb.GetUnresolvedTransactionsResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.GetUnresolvedTransactions")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("abandon_age", req.AbandonAge)
+
+	shards, err := s.ts.FindAllShardsInKeyspace(ctx, req.Keyspace)
+	if err != nil {
+		return nil, err
+	}
+	shardNames := make([]string, 0, len(shards))
+	for shard := range shards {
+		shardNames = append(shardNames, shard)
+	}
+	sort.Strings(shardNames)
+
+	abandonAge := time.Duration(req.AbandonAge) * time.Second
+	resp = &vtctldatapb.GetUnresolvedTransactionsResponse{}
+	for _, shard := range shardNames {
+		transactions, err := s.unresolvedTransactions(ctx, req.Keyspace, shard, abandonAge)
+		if err != nil {
+			return nil, err
+		}
+		resp.Transactions = append(resp.Transactions, transactions...)
+	}
+	return resp, nil
+}
+
+func (s *VtctldServer) unresolvedTransactions(ctx context.Context, keyspace, shard string, abandonAge time.Duration) ([]*querypb.TransactionMetadata, error) {
+	qs, err := s.primaryQueryService(ctx, keyspace, shard)
+	if err != nil {
+		return nil, err
+	}
+	defer qs.Close(ctx)
+
+	return qs.UnresolvedTransactions(ctx, &querypb.Target{
+		Keyspace:   keyspace,
+		Shard:      shard,
+		TabletType: topodatapb.TabletType_PRIMARY,
+	}, abandonAge)
+}
+
 // GetVersion returns the version of a tablet from its debug vars
 func (s *VtctldServer) GetVersion(ctx context.Context, req *vtctldatapb.GetVersionRequest) (resp *vtctldatapb.GetVersionResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.GetVersion")
@@ -1973,6 +2124,7 @@ func (s *VtctldServer) GetWorkflows(ctx context.Context, req *vtctldatapb.GetWor
 
 	span.Annotate("keyspace", req.Keyspace)
 	span.Annotate("active_only", req.ActiveOnly)
+	span.Annotate("workflow", req.Workflow)
 
 	resp, err = s.ws.GetWorkflows(ctx, req)
 	return resp, err
@@ -2256,6 +2408,198 @@ func (s *VtctldServer) InitShardPrimaryLocked(
 	return nil
 }
 
+// LintVSchema is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) LintVSchema(ctx context.Context, req *vtctldatapb.LintVSchemaRequest) (resp *vtctldatapb.LintVSchemaResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.LintVSchema")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("skip_tablet_schema", req.SkipTabletSchema)
+	span.Annotate("queries", len(req.Queries))
+
+	if req.Sql != "" && req.VSchema != nil {
+		err = vterrors.New(vtrpc.Code_INVALID_ARGUMENT, "cannot pass both req.VSchema and req.Sql")
+		return nil, err
+	}
+
+	current, err := s.ts.BuildSrvVSchema(ctx)
+	if err != nil {
+		err = vterrors.Wrapf(err, "BuildSrvVSchema")
+		return nil, err
+	}
+	currentVS, ok := current.Keyspaces[req.Keyspace]
+	if !ok {
+		err = vterrors.Errorf(vtrpc.Code_NOT_FOUND, "keyspace(%s) doesn't exist, check if the keyspace is initialized", req.Keyspace)
+		return nil, err
+	}
+
+	vs := currentVS
+	switch {
+	case req.VSchema != nil:
+		vs = req.VSchema
+	case req.Sql != "":
+		var stmt sqlparser.Statement
+		stmt, err = sqlparser.Parse(req.Sql)
+		if err != nil {
+			err = vterrors.Wrapf(err, "Parse(%s)", req.Sql)
+			return nil, err
+		}
+		ddl, ok := stmt.(*sqlparser.AlterVschema)
+		if !ok {
+			err = vterrors.Errorf(vtrpc.Code_INVALID_ARGUMENT, "error parsing VSchema DDL statement `%s`", req.Sql)
+			return nil, err
+		}
+
+		vs, err = topotools.ApplyVSchemaDDL(req.Keyspace, proto.Clone(currentVS).(*vschemapb.Keyspace), ddl)
+		if err != nil {
+			err = vterrors.Wrapf(err, "ApplyVSchemaDDL(%s,%v)", req.Keyspace, ddl)
+			return nil, err
+		}
+	}
+
+	proposed := proto.Clone(current).(*vschemapb.SrvVSchema)
+	proposed.Keyspaces[req.Keyspace] = vs
+
+	var tables []*tabletmanagerdatapb.TableDefinition
+	if !req.SkipTabletSchema {
+		tables, err = s.getKeyspaceTableDefinitions(ctx, req.Keyspace)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &vtctldatapb.LintVSchemaResponse{
+		VSchema:     vs,
+		Diff:        vschemalint.Diff(currentVS, vs),
+		Findings:    vschemalint.Lint(req.Keyspace, proposed, tables),
+		PlanChanges: vschemalint.PlanChanges(req.Keyspace, current, proposed, req.Queries),
+	}, nil
+}
+
+// getKeyspaceTableDefinitions returns the table definitions of a keyspace,
+// as found on the primary tablet of its first shard. The tables of a keyspace
+// are the same on all its shards.
+func (s *VtctldServer) getKeyspaceTableDefinitions(ctx context.Context, keyspace string) ([]*tabletmanagerdatapb.TableDefinition, error) {
+	shards, err := s.ts.GetShardNames(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetShardNames(%s)", keyspace)
+	}
+	if len(shards) == 0 {
+		return nil, vterrors.Errorf(vtrpc.Code_FAILED_PRECONDITION, "keyspace %s has no shards", keyspace)
+	}
+	sort.Strings(shards)
+
+	si, err := s.ts.GetShard(ctx, keyspace, shards[0])
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetShard(%s/%s)", keyspace, shards[0])
+	}
+	if si.PrimaryAlias == nil {
+		return nil, vterrors.Errorf(vtrpc.Code_FAILED_PRECONDITION, "shard %s/%s has no primary, skip the tablet schema checks to lint the VSchema alone", keyspace, shards[0])
+	}
+
+	sd, err := schematools.GetSchema(ctx, s.ts, s.tmc, si.PrimaryAlias, &tabletmanagerdatapb.GetSchemaRequest{
+		TableSchemaOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sd.TableDefinitions, nil
+}
+
+// MaterializeCreate is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) MaterializeCreate(ctx context.Context, req *vtctldatapb.MaterializeCreateRequest) (resp *vtctldatapb.MaterializeCreateResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.MaterializeCreate")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("workflow", req.Settings.GetWorkflow())
+	span.Annotate("source_keyspace", req.Settings.GetSourceKeyspace())
+	span.Annotate("target_keyspace", req.Settings.GetTargetKeyspace())
+
+	resp, err = s.ws.MaterializeCreate(ctx, req)
+	return resp, err
+}
+
+// MoveTablesCreate is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) MoveTablesCreate(ctx context.Context, req *vtctldatapb.MoveTablesCreateRequest) (resp *vtctldatapb.MoveTablesCreateResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.MoveTablesCreate")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("source_keyspace", req.SourceKeyspace)
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("all_tables", req.AllTables)
+	span.Annotate("auto_start", req.AutoStart)
+
+	resp, err = s.ws.MoveTablesCreate(ctx, req)
+	return resp, err
+}
+
+// MoveTenantCancel is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) MoveTenantCancel(ctx context.Context, req *vtctldatapb.MoveTenantCancelRequest) (resp *vtctldatapb.MoveTenantCancelResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.MoveTenantCancel")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+
+	resp, err = s.ws.MoveTenantCancel(ctx, req)
+	return resp, err
+}
+
+// MoveTenantComplete is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) MoveTenantComplete(ctx context.Context, req *vtctldatapb.MoveTenantCompleteRequest) (resp *vtctldatapb.MoveTenantCompleteResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.MoveTenantComplete")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+
+	resp, err = s.ws.MoveTenantComplete(ctx, req)
+	return resp, err
+}
+
+// MoveTenantCreate is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) MoveTenantCreate(ctx context.Context, req *vtctldatapb.MoveTenantCreateRequest) (resp *vtctldatapb.MoveTenantCreateResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.MoveTenantCreate")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("source_keyspace", req.SourceKeyspace)
+	span.Annotate("key_range", req.KeyRange)
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("target_shard", req.TargetShard)
+
+	resp, err = s.ws.MoveTenantCreate(ctx, req)
+	return resp, err
+}
+
+// MoveTenantSwitchTraffic is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) MoveTenantSwitchTraffic(ctx context.Context, req *vtctldatapb.MoveTenantSwitchTrafficRequest) (resp *vtctldatapb.MoveTenantSwitchTrafficResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.MoveTenantSwitchTraffic")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("dry_run", req.DryRun)
+
+	resp, err = s.ws.MoveTenantSwitchTraffic(ctx, req)
+	return resp, err
+}
+
 // PingTablet is part of the vtctlservicepb.VtctldServer interface.
 func (s *VtctldServer) PingTablet(ctx context.Context, req *vtctldatapb.PingTabletRequest) (resp *vtctldatapb.PingTabletResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.PingTablet")
@@ -2715,6 +3059,39 @@ func (s *VtctldServer) ReparentTablet(ctx context.Context, req *vtctldatapb.Repa
 	}, nil
 }
 
+// ReshardCreate is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) ReshardCreate(ctx context.Context, req *vtctldatapb.ReshardCreateRequest) (resp *vtctldatapb.ReshardCreateResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.ReshardCreate")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("source_shards", strings.Join(req.SourceShards, ","))
+	span.Annotate("target_shards", strings.Join(req.TargetShards, ","))
+
+	resp, err = s.ws.ReshardCreate(ctx, req)
+	return resp, err
+}
+
+// ResolveTransaction is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) ResolveTransaction(ctx context.Context, req *vtctldatapb.ResolveTransactionRequest) (resp *vtctldatapb.ResolveTransactionResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.ResolveTransaction")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("dtid", req.Dtid)
+
+	// This follows the same resolution as the vtgate coordinator, with every
+	// call sent to the primary of the shard of its target.
+	if err := dtids.Resolve(ctx, s.primariesQueryService(), req.Dtid); err != nil {
+		return nil, err
+	}
+	return &vtctldatapb.ResolveTransactionResponse{}, nil
+}
+
 func (s *VtctldServer) RestoreFromBackup(req *vtctldatapb.RestoreFromBackupRequest, stream vtctlservicepb.Vtctld_RestoreFromBackupServer) (err error) {
 	span, ctx := trace.NewSpan(stream.Context(), "VtctldServer.RestoreFromBackup")
 	defer span.Finish()
@@ -2791,6 +3168,21 @@ func (s *VtctldServer) RestoreFromBackup(req *vtctldatapb.RestoreFromBackupReque
 	}
 }
 
+// RollbackAlterVindex is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) RollbackAlterVindex(ctx context.Context, req *vtctldatapb.RollbackAlterVindexRequest) (resp *vtctldatapb.RollbackAlterVindexResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.RollbackAlterVindex")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("table", req.Table)
+	span.Annotate("cells", strings.Join(req.Cells, ","))
+
+	resp, err = s.ws.RollbackAlterVindex(ctx, req)
+	return resp, err
+}
+
 // RunHealthCheck is part of the vtctlservicepb.VtctldServer interface.
 func (s *VtctldServer) RunHealthCheck(ctx context.Context, req *vtctldatapb.RunHealthCheckRequest) (resp *vtctldatapb.RunHealthCheckResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.RunHealthCheck")
@@ -4355,6 +4747,72 @@ func (s *VtctldServer) ValidateVSchema(ctx context.Context, req *vtctldatapb.Val
 	return resp, err
 }
 
+// VDiff is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) VDiff(ctx context.Context, req *vtctldatapb.VDiffRequest) (resp *vtctldatapb.VDiffResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.VDiff")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("action", req.Action)
+	span.Annotate("action_arg", req.ActionArg)
+	span.Annotate("uuid", req.Uuid)
+
+	resp, err = s.ws.VDiff(ctx, req)
+	return resp, err
+}
+
+// WorkflowCancel is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) WorkflowCancel(ctx context.Context, req *vtctldatapb.WorkflowCancelRequest) (resp *vtctldatapb.WorkflowCancelResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.WorkflowCancel")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("keep_data", req.KeepData)
+	span.Annotate("keep_routing_rules", req.KeepRoutingRules)
+
+	resp, err = s.ws.WorkflowCancel(ctx, req)
+	return resp, err
+}
+
+// WorkflowComplete is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) WorkflowComplete(ctx context.Context, req *vtctldatapb.WorkflowCompleteRequest) (resp *vtctldatapb.WorkflowCompleteResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.WorkflowComplete")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("keep_data", req.KeepData)
+	span.Annotate("keep_routing_rules", req.KeepRoutingRules)
+	span.Annotate("rename_tables", req.RenameTables)
+
+	resp, err = s.ws.WorkflowComplete(ctx, req)
+	return resp, err
+}
+
+// WorkflowSwitchTraffic is part of the vtctlservicepb.VtctldServer interface.
+func (s *VtctldServer) WorkflowSwitchTraffic(ctx context.Context, req *vtctldatapb.WorkflowSwitchTrafficRequest) (resp *vtctldatapb.WorkflowSwitchTrafficResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "VtctldServer.WorkflowSwitchTraffic")
+	defer span.Finish()
+
+	defer panicHandler(&err)
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("cells", strings.Join(req.Cells, ","))
+	span.Annotate("direction", req.Direction)
+
+	resp, err = s.ws.WorkflowSwitchTraffic(ctx, req)
+	return resp, err
+}
+
 // WorkflowUpdate is part of the vtctlservicepb.VtctldServer interface.
 func (s *VtctldServer) WorkflowUpdate(ctx context.Context, req *vtctldatapb.WorkflowUpdateRequest) (resp *vtctldatapb.WorkflowUpdateResponse, err error) {
 	span, ctx := trace.NewSpan(ctx, "VtctldServer.WorkflowUpdate")
@@ -4426,6 +4884,50 @@ func (s *VtctldServer) getTopologyCell(ctx context.Context, cellPath string) (*v
 }
 
 // Helper function to get version of a tablet from its debug vars
+// primaryQueryService dials the query service of the primary of a shard.
+func (s *VtctldServer) primaryQueryService(ctx context.Context, keyspace, shard string) (queryservice.QueryService, error) {
+	si, err := s.ts.GetShard(ctx, keyspace, shard)
+	if err != nil {
+		return nil, err
+	}
+	if !si.HasPrimary() {
+		return nil, vterrors.Errorf(vtrpc.Code_FAILED_PRECONDITION, "shard %v/%v has no primary", keyspace, shard)
+	}
+	primary, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+	if err != nil {
+		return nil, err
+	}
+	return tabletconn.GetDialer()(primary.Tablet, grpcclient.FailFast(false))
+}
+
+// primariesQueryService returns a query service that sends every call to the
+// primary of the shard of its target, dialing it for the call.
+func (s *VtctldServer) primariesQueryService() queryservice.QueryService {
+	return queryservice.Wrap(nil, func(ctx context.Context, target *querypb.Target, conn queryservice.QueryService, name string, inTransaction bool, inner func(context.Context, *querypb.Target, queryservice.QueryService) (bool, error)) error {
+		if target == nil {
+			// Close and StreamHealth have no shard to dial.
+			return nil
+		}
+		qs, err := s.primaryQueryService(ctx, target.Keyspace, target.Shard)
+		if err != nil {
+			return err
+		}
+		defer qs.Close(ctx)
+
+		_, err = inner(ctx, primaryTarget(target), qs)
+		return err
+	})
+}
+
+// primaryTarget returns the target of the primary of the shard of a target.
+func primaryTarget(target *querypb.Target) *querypb.Target {
+	return &querypb.Target{
+		Keyspace:   target.Keyspace,
+		Shard:      target.Shard,
+		TabletType: topodatapb.TabletType_PRIMARY,
+	}
+}
+
 var getVersionFromTabletDebugVars = func(tabletAddr string) (string, error) {
 	resp, err := http.Get("http://" + tabletAddr + "/debug/vars")
 	if err != nil {
@@ -23,6 +23,7 @@ import (
 	"io"
 	"os"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"vitess.io/vitess/go/protoutil"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/grpcclient"
 	hk "vitess.io/vitess/go/vt/hook"
 	"vitess.io/vitess/go/vt/logutil"
 	"vitess.io/vitess/go/vt/mysqlctl/backupstorage"
@@ -44,6 +46,10 @@ import (
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver/testutil"
 	"vitess.io/vitess/go/vt/vtctl/localvtctldclient"
+	"vitess.io/vitess/go/vt/vttablet/queryservice"
+	"vitess.io/vitess/go/vt/vttablet/sandboxconn"
+	"vitess.io/vitess/go/vt/vttablet/tabletconn"
+	"vitess.io/vitess/go/vt/vttablet/tabletconntest"
 	"vitess.io/vitess/go/vt/vttablet/tmclient"
 	"vitess.io/vitess/go/vt/vttablet/tmclienttest"
 
@@ -73,6 +79,50 @@ func init() {
 	tmclient.RegisterTabletManagerClientFactory("grpcvtctldserver.test", func() tmclient.TabletManagerClient {
 		return nil
 	})
+
+	// The distributed transaction RPCs dial the query service of the
+	// primaries, which are the sandbox conns of queryServices.
+	tabletconntest.SetProtocol("go.vt.vtctl.grpcvtctldserver", "grpcvtctldserver.test")
+	tabletconn.RegisterDialer("grpcvtctldserver.test", func(tablet *topodatapb.Tablet, failFast grpcclient.FailFast) (queryservice.QueryService, error) {
+		queryServicesMu.Lock()
+		defer queryServicesMu.Unlock()
+		if qs, ok := queryServices[tablet.Alias.Uid]; ok {
+			return qs, nil
+		}
+		return nil, fmt.Errorf("tablet %d not found", tablet.Alias.Uid)
+	})
+}
+
+var (
+	queryServicesMu sync.Mutex
+	queryServices   = map[uint32]*sandboxconn.SandboxConn{}
+)
+
+// addTransactionPrimaries adds the primaries of the -80 and 80- shards of
+// testkeyspace to the topo, and returns their query services.
+func addTransactionPrimaries(ctx context.Context, t *testing.T, ts *topo.Server, uid uint32) (sbc0, sbc1 *sandboxconn.SandboxConn) {
+	t.Helper()
+	var sbcs []*sandboxconn.SandboxConn
+	for i, shard := range []string{"-80", "80-"} {
+		tablet := &topodatapb.Tablet{
+			Alias: &topodatapb.TabletAlias{
+				Cell: "zone1",
+				Uid:  uid + uint32(i),
+			},
+			Keyspace: "testkeyspace",
+			Shard:    shard,
+			Type:     topodatapb.TabletType_PRIMARY,
+		}
+		testutil.AddTablet(ctx, t, ts, tablet, &testutil.AddTabletOptions{
+			AlsoSetShardPrimary: true,
+		})
+		sbc := sandboxconn.NewSandboxConn(tablet)
+		queryServicesMu.Lock()
+		queryServices[tablet.Alias.Uid] = sbc
+		queryServicesMu.Unlock()
+		sbcs = append(sbcs, sbc)
+	}
+	return sbcs[0], sbcs[1]
 }
 
 func TestPanicHandler(t *testing.T) {
@@ -500,6 +550,9 @@ func TestApplyVSchema(t *testing.T) {
 					ShardRoutingRules: &vschemapb.ShardRoutingRules{
 						Rules: []*vschemapb.ShardRoutingRule{},
 					},
+					KeyspaceIdRoutingRules: &vschemapb.KeyspaceIdRoutingRules{
+						Rules: []*vschemapb.KeyspaceIdRoutingRule{},
+					},
 				}
 				utils.MustMatch(t, changedSrvVSchema, finalSrvVSchema)
 			}
@@ -1308,6 +1361,49 @@ func TestChangeTabletType(t *testing.T) {
 	})
 }
 
+func TestConcludeTransaction(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("zone1")
+	vtctld := testutil.NewVtctldServerWithTabletManagerClient(t, ts, nil, func(ts *topo.Server) vtctlservicepb.VtctldServer {
+		return NewVtctldServer(ts)
+	})
+	sbc0, sbc1 := addTransactionPrimaries(ctx, t, ts, 100)
+	dtid := "testkeyspace:80-:1234"
+	transaction := &querypb.TransactionMetadata{
+		Dtid:  dtid,
+		State: querypb.TransactionState_PREPARE,
+	}
+
+	// The transaction is not resolved.
+	sbc1.ReadTransactionResults = []*querypb.TransactionMetadata{transaction}
+	_, err := vtctld.ConcludeTransaction(ctx, &vtctldatapb.ConcludeTransactionRequest{
+		Dtid: dtid,
+	})
+	assert.EqualError(t, err, "transaction testkeyspace:80-:1234 in state PREPARE is not resolved, resolve it, or force concluding it if its participants were resolved by hand")
+	assert.EqualValues(t, 0, sbc1.ConcludeTransactionCount.Load())
+
+	sbc1.ReadTransactionResults = []*querypb.TransactionMetadata{transaction}
+	_, err = vtctld.ConcludeTransaction(ctx, &vtctldatapb.ConcludeTransactionRequest{
+		Dtid:  dtid,
+		Force: true,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, sbc0.ConcludeTransactionCount.Load())
+	assert.EqualValues(t, 1, sbc1.ConcludeTransactionCount.Load())
+
+	// The transaction was already concluded.
+	_, err = vtctld.ConcludeTransaction(ctx, &vtctldatapb.ConcludeTransactionRequest{
+		Dtid: dtid,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, sbc1.ConcludeTransactionCount.Load())
+
+	_, err = vtctld.ConcludeTransaction(ctx, &vtctldatapb.ConcludeTransactionRequest{
+		Dtid: "testkeyspace:c0-:1234",
+	})
+	assert.Error(t, err)
+}
+
 func TestCreateKeyspace(t *testing.T) {
 	t.Parallel()
 
@@ -6048,6 +6144,47 @@ func TestGetTopologyPath(t *testing.T) {
 	}
 }
 
+func TestGetUnresolvedTransactions(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("zone1")
+	vtctld := testutil.NewVtctldServerWithTabletManagerClient(t, ts, nil, func(ts *topo.Server) vtctlservicepb.VtctldServer {
+		return NewVtctldServer(ts)
+	})
+	sbc0, sbc1 := addTransactionPrimaries(ctx, t, ts, 200)
+
+	transactions := []*querypb.TransactionMetadata{{
+		Dtid:  "testkeyspace:-80:1234",
+		State: querypb.TransactionState_PREPARE,
+		Participants: []*querypb.Target{{
+			Keyspace:   "testkeyspace",
+			Shard:      "80-",
+			TabletType: topodatapb.TabletType_PRIMARY,
+		}},
+	}, {
+		Dtid:  "testkeyspace:80-:1235",
+		State: querypb.TransactionState_COMMIT,
+		Participants: []*querypb.Target{{
+			Keyspace:   "testkeyspace",
+			Shard:      "-80",
+			TabletType: topodatapb.TabletType_PRIMARY,
+		}},
+	}}
+	sbc0.UnresolvedTransactionsResults = transactions[:1]
+	sbc1.UnresolvedTransactionsResults = transactions[1:]
+
+	resp, err := vtctld.GetUnresolvedTransactions(ctx, &vtctldatapb.GetUnresolvedTransactionsRequest{
+		Keyspace:   "testkeyspace",
+		AbandonAge: 60,
+	})
+	require.NoError(t, err)
+	utils.MustMatch(t, transactions, resp.Transactions)
+
+	_, err = vtctld.GetUnresolvedTransactions(ctx, &vtctldatapb.GetUnresolvedTransactionsRequest{
+		Keyspace: "unknown",
+	})
+	assert.Error(t, err)
+}
+
 func TestGetVSchema(t *testing.T) {
 	t.Parallel()
 
@@ -6098,6 +6235,92 @@ func TestGetVSchema(t *testing.T) {
 	})
 }
 
+func TestLintVSchema(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := memorytopo.NewServer("zone1")
+	tmc := &testutil.TabletManagerClient{
+		GetSchemaResults: map[string]struct {
+			Schema *tabletmanagerdatapb.SchemaDefinition
+			Error  error
+		}{
+			"zone1-0000000100": {
+				Schema: &tabletmanagerdatapb.SchemaDefinition{
+					TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+						Name:    "t1",
+						Columns: []string{"id", "name"},
+						Fields: []*querypb.Field{
+							{Name: "id", Type: querypb.Type_INT64},
+							{Name: "name", Type: querypb.Type_VARCHAR},
+						},
+					}, {
+						Name:    "t2",
+						Columns: []string{"id"},
+						Fields:  []*querypb.Field{{Name: "id", Type: querypb.Type_INT64}},
+					}},
+				},
+			},
+		},
+	}
+	vtctld := testutil.NewVtctldServerWithTabletManagerClient(t, ts, tmc, func(ts *topo.Server) vtctlservicepb.VtctldServer {
+		return NewVtctldServer(ts)
+	})
+
+	testutil.AddTablets(ctx, t, ts, &testutil.AddTabletOptions{
+		AlsoSetShardPrimary: true,
+	}, &topodatapb.Tablet{
+		Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 100},
+		Keyspace: "testkeyspace",
+		Shard:    "-",
+		Type:     topodatapb.TabletType_PRIMARY,
+	})
+	err := ts.SaveVSchema(ctx, "testkeyspace", &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "hash"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := vtctld.LintVSchema(ctx, &vtctldatapb.LintVSchemaRequest{
+		Keyspace: "testkeyspace",
+		Sql:      "alter vschema on t1 add vindex name_hash(name) using hash",
+		Queries:  []string{"select * from t1 where name = 'a'"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+ vindex name_hash hash", "~ table t1: column_vindexes hash(id) -> hash(id), name_hash(name)"}, resp.Diff)
+	require.Len(t, resp.Findings, 2)
+	assert.Equal(t, "t1", resp.Findings[0].Table)
+	assert.Equal(t, "vindex_column_type", resp.Findings[0].Check)
+	assert.Equal(t, "t2", resp.Findings[1].Table)
+	assert.Equal(t, "missing_primary_vindex", resp.Findings[1].Check)
+	require.Len(t, resp.PlanChanges, 1)
+	assert.Equal(t, "Route(Scatter testkeyspace)", resp.PlanChanges[0].Before)
+	assert.Equal(t, "Route(EqualUnique testkeyspace name_hash)", resp.PlanChanges[0].After)
+
+	// Nothing is saved.
+	vs, err := ts.GetVSchema(ctx, "testkeyspace")
+	require.NoError(t, err)
+	assert.Len(t, vs.Tables["t1"].ColumnVindexes, 1)
+
+	resp, err = vtctld.LintVSchema(ctx, &vtctldatapb.LintVSchemaRequest{
+		Keyspace:         "testkeyspace",
+		SkipTabletSchema: true,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Diff)
+	assert.Empty(t, resp.Findings)
+
+	_, err = vtctld.LintVSchema(ctx, &vtctldatapb.LintVSchemaRequest{
+		Keyspace: "nonexistent",
+	})
+	assert.Error(t, err)
+}
+
 func TestPingTablet(t *testing.T) {
 	t.Parallel()
 
@@ -8258,6 +8481,54 @@ func TestReparentTablet(t *testing.T) {
 	}
 }
 
+func TestResolveTransaction(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("zone1")
+	vtctld := testutil.NewVtctldServerWithTabletManagerClient(t, ts, nil, func(ts *topo.Server) vtctlservicepb.VtctldServer {
+		return NewVtctldServer(ts)
+	})
+	sbc0, sbc1 := addTransactionPrimaries(ctx, t, ts, 300)
+
+	participants := []*querypb.Target{{
+		Keyspace:   "testkeyspace",
+		Shard:      "80-",
+		TabletType: topodatapb.TabletType_PRIMARY,
+	}}
+	dtid := "testkeyspace:-80:1234"
+
+	// The coordinator decided to commit.
+	sbc0.ReadTransactionResults = []*querypb.TransactionMetadata{{
+		Dtid:         dtid,
+		State:        querypb.TransactionState_COMMIT,
+		Participants: participants,
+	}}
+	_, err := vtctld.ResolveTransaction(ctx, &vtctldatapb.ResolveTransactionRequest{Dtid: dtid})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, sbc1.CommitPreparedCount.Load())
+	assert.EqualValues(t, 0, sbc1.RollbackPreparedCount.Load())
+	assert.EqualValues(t, 1, sbc0.ConcludeTransactionCount.Load())
+
+	// The coordinator died before its decision: the transaction is rolled back.
+	sbc0.ReadTransactionResults = []*querypb.TransactionMetadata{{
+		Dtid:         dtid,
+		State:        querypb.TransactionState_PREPARE,
+		Participants: participants,
+	}}
+	_, err = vtctld.ResolveTransaction(ctx, &vtctldatapb.ResolveTransactionRequest{Dtid: dtid})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, sbc0.SetRollbackCount.Load())
+	assert.EqualValues(t, 1, sbc1.RollbackPreparedCount.Load())
+	assert.EqualValues(t, 2, sbc0.ConcludeTransactionCount.Load())
+
+	// The transaction was already resolved.
+	_, err = vtctld.ResolveTransaction(ctx, &vtctldatapb.ResolveTransactionRequest{Dtid: dtid})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, sbc0.ConcludeTransactionCount.Load())
+
+	_, err = vtctld.ResolveTransaction(ctx, &vtctldatapb.ResolveTransactionRequest{Dtid: "abcd"})
+	assert.EqualError(t, err, "invalid parts in dtid: abcd")
+}
+
 func TestRestoreFromBackup(t *testing.T) {
 	ctx := context.Background()
 	tests := []struct {
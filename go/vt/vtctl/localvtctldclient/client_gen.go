@@ -39,6 +39,16 @@ func (client *localVtctldClient) AddCellsAlias(ctx context.Context, in *vtctldat
 	return client.s.AddCellsAlias(ctx, in)
 }
 
+// AlterVindex is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) AlterVindex(ctx context.Context, in *vtctldatapb.AlterVindexRequest, opts ...grpc.CallOption) (*vtctldatapb.AlterVindexResponse, error) {
+	return client.s.AlterVindex(ctx, in)
+}
+
+// ApplyKeyspaceIdRoutingRules is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) ApplyKeyspaceIdRoutingRules(ctx context.Context, in *vtctldatapb.ApplyKeyspaceIdRoutingRulesRequest, opts ...grpc.CallOption) (*vtctldatapb.ApplyKeyspaceIdRoutingRulesResponse, error) {
+	return client.s.ApplyKeyspaceIdRoutingRules(ctx, in)
+}
+
 // ApplyRoutingRules is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) ApplyRoutingRules(ctx context.Context, in *vtctldatapb.ApplyRoutingRulesRequest, opts ...grpc.CallOption) (*vtctldatapb.ApplyRoutingRulesResponse, error) {
 	return client.s.ApplyRoutingRules(ctx, in)
@@ -166,6 +176,11 @@ func (client *localVtctldClient) ChangeTabletType(ctx context.Context, in *vtctl
 	return client.s.ChangeTabletType(ctx, in)
 }
 
+// ConcludeTransaction is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) ConcludeTransaction(ctx context.Context, in *vtctldatapb.ConcludeTransactionRequest, opts ...grpc.CallOption) (*vtctldatapb.ConcludeTransactionResponse, error) {
+	return client.s.ConcludeTransaction(ctx, in)
+}
+
 // CreateKeyspace is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) CreateKeyspace(ctx context.Context, in *vtctldatapb.CreateKeyspaceRequest, opts ...grpc.CallOption) (*vtctldatapb.CreateKeyspaceResponse, error) {
 	return client.s.CreateKeyspace(ctx, in)
@@ -261,6 +276,11 @@ func (client *localVtctldClient) GetKeyspace(ctx context.Context, in *vtctldatap
 	return client.s.GetKeyspace(ctx, in)
 }
 
+// GetKeyspaceIdRoutingRules is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) GetKeyspaceIdRoutingRules(ctx context.Context, in *vtctldatapb.GetKeyspaceIdRoutingRulesRequest, opts ...grpc.CallOption) (*vtctldatapb.GetKeyspaceIdRoutingRulesResponse, error) {
+	return client.s.GetKeyspaceIdRoutingRules(ctx, in)
+}
+
 // GetKeyspaces is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) GetKeyspaces(ctx context.Context, in *vtctldatapb.GetKeyspacesRequest, opts ...grpc.CallOption) (*vtctldatapb.GetKeyspacesResponse, error) {
 	return client.s.GetKeyspaces(ctx, in)
@@ -326,6 +346,11 @@ func (client *localVtctldClient) GetTopologyPath(ctx context.Context, in *vtctld
 	return client.s.GetTopologyPath(ctx, in)
 }
 
+// GetUnresolvedTransactions is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) GetUnresolvedTransactions(ctx context.Context, in *vtctldatapb.GetUnresolvedTransactionsRequest, opts ...grpc.CallOption) (*vtctldatapb.GetUnresolvedTransactionsResponse, error) {
+	return client.s.GetUnresolvedTransactions(ctx, in)
+}
+
 // GetVSchema is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) GetVSchema(ctx context.Context, in *vtctldatapb.GetVSchemaRequest, opts ...grpc.CallOption) (*vtctldatapb.GetVSchemaResponse, error) {
 	return client.s.GetVSchema(ctx, in)
@@ -346,6 +371,41 @@ func (client *localVtctldClient) InitShardPrimary(ctx context.Context, in *vtctl
 	return client.s.InitShardPrimary(ctx, in)
 }
 
+// LintVSchema is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) LintVSchema(ctx context.Context, in *vtctldatapb.LintVSchemaRequest, opts ...grpc.CallOption) (*vtctldatapb.LintVSchemaResponse, error) {
+	return client.s.LintVSchema(ctx, in)
+}
+
+// MaterializeCreate is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) MaterializeCreate(ctx context.Context, in *vtctldatapb.MaterializeCreateRequest, opts ...grpc.CallOption) (*vtctldatapb.MaterializeCreateResponse, error) {
+	return client.s.MaterializeCreate(ctx, in)
+}
+
+// MoveTablesCreate is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) MoveTablesCreate(ctx context.Context, in *vtctldatapb.MoveTablesCreateRequest, opts ...grpc.CallOption) (*vtctldatapb.MoveTablesCreateResponse, error) {
+	return client.s.MoveTablesCreate(ctx, in)
+}
+
+// MoveTenantCancel is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) MoveTenantCancel(ctx context.Context, in *vtctldatapb.MoveTenantCancelRequest, opts ...grpc.CallOption) (*vtctldatapb.MoveTenantCancelResponse, error) {
+	return client.s.MoveTenantCancel(ctx, in)
+}
+
+// MoveTenantComplete is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) MoveTenantComplete(ctx context.Context, in *vtctldatapb.MoveTenantCompleteRequest, opts ...grpc.CallOption) (*vtctldatapb.MoveTenantCompleteResponse, error) {
+	return client.s.MoveTenantComplete(ctx, in)
+}
+
+// MoveTenantCreate is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) MoveTenantCreate(ctx context.Context, in *vtctldatapb.MoveTenantCreateRequest, opts ...grpc.CallOption) (*vtctldatapb.MoveTenantCreateResponse, error) {
+	return client.s.MoveTenantCreate(ctx, in)
+}
+
+// MoveTenantSwitchTraffic is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) MoveTenantSwitchTraffic(ctx context.Context, in *vtctldatapb.MoveTenantSwitchTrafficRequest, opts ...grpc.CallOption) (*vtctldatapb.MoveTenantSwitchTrafficResponse, error) {
+	return client.s.MoveTenantSwitchTraffic(ctx, in)
+}
+
 // PingTablet is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) PingTablet(ctx context.Context, in *vtctldatapb.PingTabletRequest, opts ...grpc.CallOption) (*vtctldatapb.PingTabletResponse, error) {
 	return client.s.PingTablet(ctx, in)
@@ -411,6 +471,16 @@ func (client *localVtctldClient) ReparentTablet(ctx context.Context, in *vtctlda
 	return client.s.ReparentTablet(ctx, in)
 }
 
+// ReshardCreate is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) ReshardCreate(ctx context.Context, in *vtctldatapb.ReshardCreateRequest, opts ...grpc.CallOption) (*vtctldatapb.ReshardCreateResponse, error) {
+	return client.s.ReshardCreate(ctx, in)
+}
+
+// ResolveTransaction is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) ResolveTransaction(ctx context.Context, in *vtctldatapb.ResolveTransactionRequest, opts ...grpc.CallOption) (*vtctldatapb.ResolveTransactionResponse, error) {
+	return client.s.ResolveTransaction(ctx, in)
+}
+
 type restoreFromBackupStreamAdapter struct {
 	*grpcshim.BidiStream
 	ch chan *vtctldatapb.RestoreFromBackupResponse
@@ -462,6 +532,11 @@ func (client *localVtctldClient) RestoreFromBackup(ctx context.Context, in *vtct
 	return stream, nil
 }
 
+// RollbackAlterVindex is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) RollbackAlterVindex(ctx context.Context, in *vtctldatapb.RollbackAlterVindexRequest, opts ...grpc.CallOption) (*vtctldatapb.RollbackAlterVindexResponse, error) {
+	return client.s.RollbackAlterVindex(ctx, in)
+}
+
 // RunHealthCheck is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) RunHealthCheck(ctx context.Context, in *vtctldatapb.RunHealthCheckRequest, opts ...grpc.CallOption) (*vtctldatapb.RunHealthCheckResponse, error) {
 	return client.s.RunHealthCheck(ctx, in)
@@ -552,6 +627,11 @@ func (client *localVtctldClient) UpdateThrottlerConfig(ctx context.Context, in *
 	return client.s.UpdateThrottlerConfig(ctx, in)
 }
 
+// VDiff is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) VDiff(ctx context.Context, in *vtctldatapb.VDiffRequest, opts ...grpc.CallOption) (*vtctldatapb.VDiffResponse, error) {
+	return client.s.VDiff(ctx, in)
+}
+
 // Validate is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) Validate(ctx context.Context, in *vtctldatapb.ValidateRequest, opts ...grpc.CallOption) (*vtctldatapb.ValidateResponse, error) {
 	return client.s.Validate(ctx, in)
@@ -587,6 +667,21 @@ func (client *localVtctldClient) ValidateVersionShard(ctx context.Context, in *v
 	return client.s.ValidateVersionShard(ctx, in)
 }
 
+// WorkflowCancel is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) WorkflowCancel(ctx context.Context, in *vtctldatapb.WorkflowCancelRequest, opts ...grpc.CallOption) (*vtctldatapb.WorkflowCancelResponse, error) {
+	return client.s.WorkflowCancel(ctx, in)
+}
+
+// WorkflowComplete is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) WorkflowComplete(ctx context.Context, in *vtctldatapb.WorkflowCompleteRequest, opts ...grpc.CallOption) (*vtctldatapb.WorkflowCompleteResponse, error) {
+	return client.s.WorkflowComplete(ctx, in)
+}
+
+// WorkflowSwitchTraffic is part of the vtctlservicepb.VtctldClient interface.
+func (client *localVtctldClient) WorkflowSwitchTraffic(ctx context.Context, in *vtctldatapb.WorkflowSwitchTrafficRequest, opts ...grpc.CallOption) (*vtctldatapb.WorkflowSwitchTrafficResponse, error) {
+	return client.s.WorkflowSwitchTraffic(ctx, in)
+}
+
 // WorkflowUpdate is part of the vtctlservicepb.VtctldClient interface.
 func (client *localVtctldClient) WorkflowUpdate(ctx context.Context, in *vtctldatapb.WorkflowUpdateRequest, opts ...grpc.CallOption) (*vtctldatapb.WorkflowUpdateResponse, error) {
 	return client.s.WorkflowUpdate(ctx, in)
@@ -0,0 +1,144 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vschemalint
+
+import (
+	"fmt"
+	"strings"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+// Diff describes the changes from one VSchema of a keyspace to another, one
+// line per change. Lines start with "+" for added vindexes and tables, "-"
+// for removed ones and "~" for changed ones.
+func Diff(before, after *vschemapb.Keyspace) []string {
+	if before == nil {
+		before = &vschemapb.Keyspace{}
+	}
+	if after == nil {
+		after = &vschemapb.Keyspace{}
+	}
+
+	var diff []string
+	if before.Sharded != after.Sharded {
+		diff = append(diff, fmt.Sprintf("~ sharded: %t -> %t", before.Sharded, after.Sharded))
+	}
+	if before.RequireExplicitRouting != after.RequireExplicitRouting {
+		diff = append(diff, fmt.Sprintf("~ require_explicit_routing: %t -> %t", before.RequireExplicitRouting, after.RequireExplicitRouting))
+	}
+
+	for _, name := range sortedKeys(before.Vindexes) {
+		if _, ok := after.Vindexes[name]; !ok {
+			diff = append(diff, fmt.Sprintf("- vindex %s %s", name, formatVindex(before.Vindexes[name])))
+		}
+	}
+	for _, name := range sortedKeys(after.Vindexes) {
+		v := formatVindex(after.Vindexes[name])
+		prev, ok := before.Vindexes[name]
+		switch {
+		case !ok:
+			diff = append(diff, fmt.Sprintf("+ vindex %s %s", name, v))
+		case formatVindex(prev) != v:
+			diff = append(diff, fmt.Sprintf("~ vindex %s: %s -> %s", name, formatVindex(prev), v))
+		}
+	}
+
+	for _, name := range sortedKeys(before.Tables) {
+		if _, ok := after.Tables[name]; !ok {
+			diff = append(diff, "- table "+name)
+		}
+	}
+	for _, name := range sortedKeys(after.Tables) {
+		prev, ok := before.Tables[name]
+		if !ok {
+			diff = append(diff, "+ table "+name)
+			continue
+		}
+		prevFields, fields := tableFields(prev), tableFields(after.Tables[name])
+		for i, field := range fields {
+			if field.value != prevFields[i].value {
+				diff = append(diff, fmt.Sprintf("~ table %s: %s %s -> %s", name, field.name, orNone(prevFields[i].value), orNone(field.value)))
+			}
+		}
+	}
+	return diff
+}
+
+func formatVindex(v *vschemapb.Vindex) string {
+	var params []string
+	for _, k := range sortedKeys(v.Params) {
+		params = append(params, k+"="+v.Params[k])
+	}
+	s := v.Type
+	if len(params) > 0 {
+		s += "(" + strings.Join(params, ", ") + ")"
+	}
+	if v.Owner != "" {
+		s += " owner=" + v.Owner
+	}
+	return s
+}
+
+type tableField struct {
+	name, value string
+}
+
+// tableFields formats the fields of a table, always in the same order.
+func tableFields(t *vschemapb.Table) []tableField {
+	var columnVindexes []string
+	for _, cv := range t.ColumnVindexes {
+		columns := cv.Columns
+		if cv.Column != "" {
+			columns = []string{cv.Column}
+		}
+		columnVindexes = append(columnVindexes, fmt.Sprintf("%s(%s)", cv.Name, strings.Join(columns, ", ")))
+	}
+
+	var autoIncrement string
+	if ai := t.AutoIncrement; ai != nil {
+		autoIncrement = ai.Column
+		if ai.Sequence != "" {
+			autoIncrement += " sequence=" + ai.Sequence
+		}
+		if ai.Generator != "" {
+			autoIncrement += " generator=" + ai.Generator
+		}
+	}
+
+	var columns []string
+	for _, c := range t.Columns {
+		columns = append(columns, c.Name+" "+c.Type.String())
+	}
+
+	return []tableField{
+		{"type", t.Type},
+		{"column_vindexes", strings.Join(columnVindexes, ", ")},
+		{"auto_increment", autoIncrement},
+		{"columns", strings.Join(columns, ", ")},
+		{"pinned", t.Pinned},
+		{"column_list_authoritative", fmt.Sprint(t.ColumnListAuthoritative)},
+		{"source", t.Source},
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
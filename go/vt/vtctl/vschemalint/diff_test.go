@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vschemalint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+func TestDiff(t *testing.T) {
+	before := &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash":   {Type: "hash"},
+			"lookup": {Type: "lookup_unique", Params: map[string]string{"table": "lu", "from": "c", "to": "ksid"}},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"customer": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "hash"}}},
+			"corder":   {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "hash"}}},
+		},
+	}
+	after := &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash":   {Type: "hash"},
+			"xxhash": {Type: "xxhash"},
+			"lookup": {Type: "lookup_unique", Params: map[string]string{"table": "lu", "from": "c", "to": "ksid"}, Owner: "corder"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"customer": {
+				ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "xxhash"}},
+				AutoIncrement:  &vschemapb.AutoIncrement{Column: "id", Sequence: "customer_seq"},
+			},
+			"product": {Type: "reference"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"~ vindex lookup: lookup_unique(from=c, table=lu, to=ksid) -> lookup_unique(from=c, table=lu, to=ksid) owner=corder",
+		"+ vindex xxhash xxhash",
+		"- table corder",
+		"~ table customer: column_vindexes hash(id) -> xxhash(id)",
+		"~ table customer: auto_increment (none) -> id sequence=customer_seq",
+		"+ table product",
+	}, Diff(before, after))
+
+	assert.Empty(t, Diff(before, before))
+	assert.Equal(t, []string{"~ sharded: true -> false", "- vindex hash hash", "- vindex lookup lookup_unique(from=c, table=lu, to=ksid)", "- table corder", "- table customer"}, Diff(before, nil))
+}
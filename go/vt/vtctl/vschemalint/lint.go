@@ -0,0 +1,350 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package vschemalint checks a proposed VSchema before it is applied. It reports
+what changes from the current VSchema, the problems the VSchema has on its own
+or against the schema of the tablets, and the queries whose route changes.
+*/
+package vschemalint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+// Names of the checks, as reported in VSchemaLintFinding.Check.
+const (
+	CheckInvalidVSchema          = "invalid_vschema"
+	CheckMissingPrimaryVindex    = "missing_primary_vindex"
+	CheckSequenceInSharded       = "sequence_in_sharded_keyspace"
+	CheckTableNotOnTablets       = "table_not_on_tablets"
+	CheckVindexColumn            = "vindex_column"
+	CheckVindexColumnType        = "vindex_column_type"
+	CheckColumnType              = "column_type"
+	CheckUnownedLookupVindex     = "unowned_lookup_vindex"
+	CheckLookupTableNotInVSchema = "lookup_table_not_in_vschema"
+	CheckRoutingRuleCycle        = "routing_rule_cycle"
+)
+
+// integralVindexTypes are the vindex types that only map integral values.
+var integralVindexTypes = map[string]bool{
+	"hash":               true,
+	"numeric":            true,
+	"numeric_static_map": true,
+	"reverse_bits":       true,
+}
+
+type linter struct {
+	keyspace   string
+	srvVSchema *vschemapb.SrvVSchema
+	vs         *vschemapb.Keyspace
+	// tables are the table definitions of the tablets, by name. It is nil if
+	// the schema of the tablets is unknown.
+	tables   map[string]*tabletmanagerdatapb.TableDefinition
+	findings []*vtctldatapb.VSchemaLintFinding
+}
+
+// Lint checks the VSchema of a keyspace, as found in the given SrvVSchema.
+// If tables is not nil, the VSchema is also checked against these table
+// definitions of the keyspace's tablets. The findings are sorted by table.
+func Lint(keyspace string, srvVSchema *vschemapb.SrvVSchema, tables []*tabletmanagerdatapb.TableDefinition) []*vtctldatapb.VSchemaLintFinding {
+	l := &linter{
+		keyspace:   keyspace,
+		srvVSchema: srvVSchema,
+		vs:         srvVSchema.Keyspaces[keyspace],
+	}
+	if l.vs == nil {
+		l.vs = &vschemapb.Keyspace{}
+	}
+	if tables != nil {
+		l.tables = make(map[string]*tabletmanagerdatapb.TableDefinition, len(tables))
+		for _, td := range tables {
+			l.tables[td.Name] = td
+		}
+	}
+
+	l.checkBuild()
+	l.checkTables()
+	l.checkVindexColumns()
+	l.checkLookupVindexes()
+	l.checkRoutingRuleCycles()
+
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Check < b.Check
+	})
+	return l.findings
+}
+
+func (l *linter) add(severity vtctldatapb.VSchemaLintFinding_Severity, check, table, format string, args ...any) {
+	l.findings = append(l.findings, &vtctldatapb.VSchemaLintFinding{
+		Severity: severity,
+		Check:    check,
+		Keyspace: l.keyspace,
+		Table:    table,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) checkBuild() {
+	vschema := vindexes.BuildVSchema(l.srvVSchema)
+	if ks, ok := vschema.Keyspaces[l.keyspace]; ok && ks.Error != nil {
+		l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckInvalidVSchema, "", "%v", ks.Error)
+	}
+}
+
+// checkTables checks that every table of a sharded keyspace has a primary
+// vindex, including the tables that only exist on the tablets, and that
+// sequence tables are in unsharded keyspaces or pinned.
+func (l *linter) checkTables() {
+	for _, name := range sortedKeys(l.vs.Tables) {
+		table := l.vs.Tables[name]
+		switch table.Type {
+		case vindexes.TypeSequence:
+			if l.vs.Sharded && table.Pinned == "" {
+				l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckSequenceInSharded, name, "sequence table %s must be in an unsharded keyspace", name)
+			}
+		case "":
+			if l.vs.Sharded && len(table.ColumnVindexes) == 0 {
+				l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckMissingPrimaryVindex, name, "table %s has no primary vindex", name)
+			}
+		}
+		if l.tables != nil && table.Type != vindexes.TypeReference {
+			if _, ok := l.tables[name]; !ok {
+				l.add(vtctldatapb.VSchemaLintFinding_WARNING, CheckTableNotOnTablets, name, "table %s is not in the schema of the tablets", name)
+			}
+		}
+	}
+
+	if !l.vs.Sharded {
+		return
+	}
+	for _, name := range sortedKeys(l.tables) {
+		if _, ok := l.vs.Tables[name]; ok || schema.IsInternalOperationTableName(name) {
+			continue
+		}
+		l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckMissingPrimaryVindex, name, "table %s exists on the tablets but not in the VSchema, so it has no primary vindex", name)
+	}
+}
+
+// vindexColumn is a column a vindex is applied to.
+type vindexColumn struct {
+	table, column string
+	typ           querypb.Type
+}
+
+// checkVindexColumns checks that the columns of the column vindexes exist
+// on the tablets, have a type their vindex can map, and have the same kind
+// of type for all the tables that use the same vindex. It also checks the
+// column types declared in the VSchema.
+func (l *linter) checkVindexColumns() {
+	if l.tables == nil {
+		return
+	}
+
+	usages := make(map[string][]vindexColumn)
+	for _, name := range sortedKeys(l.vs.Tables) {
+		table := l.vs.Tables[name]
+		td, ok := l.tables[name]
+		if !ok {
+			continue
+		}
+		types := make(map[string]querypb.Type, len(td.Fields))
+		for _, field := range td.Fields {
+			types[strings.ToLower(field.Name)] = field.Type
+		}
+
+		for _, cv := range table.ColumnVindexes {
+			columns := cv.Columns
+			if cv.Column != "" {
+				columns = []string{cv.Column}
+			}
+			for i, column := range columns {
+				typ, ok := types[strings.ToLower(column)]
+				if !ok {
+					l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckVindexColumn, name, "column %s of vindex %s does not exist in table %s", column, cv.Name, name)
+					continue
+				}
+				if i != 0 {
+					continue
+				}
+				usages[cv.Name] = append(usages[cv.Name], vindexColumn{table: name, column: column, typ: typ})
+				if vindex, ok := l.vs.Vindexes[cv.Name]; ok && integralVindexTypes[vindex.Type] && !sqltypes.IsIntegral(typ) {
+					l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckVindexColumnType, name, "vindex %s of type %s maps integral values, but column %s.%s is %s", cv.Name, vindex.Type, name, column, typ)
+				}
+			}
+		}
+
+		for _, column := range table.Columns {
+			typ, ok := types[strings.ToLower(column.Name)]
+			if ok && column.Type != sqltypes.Null && column.Type != typ {
+				l.add(vtctldatapb.VSchemaLintFinding_WARNING, CheckColumnType, name, "column %s.%s is declared as %s in the VSchema, but is %s on the tablets", name, column.Name, column.Type, typ)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(usages) {
+		columns := usages[name]
+		for _, column := range columns[1:] {
+			if typeKind(column.typ) != typeKind(columns[0].typ) {
+				l.add(vtctldatapb.VSchemaLintFinding_WARNING, CheckVindexColumnType, column.table,
+					"vindex %s is applied to %s.%s of type %s and to %s.%s of type %s, equal values may not map to the same keyspace id",
+					name, columns[0].table, columns[0].column, columns[0].typ, column.table, column.column, column.typ)
+			}
+		}
+	}
+}
+
+// typeKind groups the types whose values a vindex maps the same way.
+func typeKind(typ querypb.Type) string {
+	switch {
+	case sqltypes.IsIntegral(typ):
+		return "integral"
+	case sqltypes.IsFloat(typ) || typ == sqltypes.Decimal:
+		return "fractional"
+	case sqltypes.IsText(typ):
+		return "text"
+	case sqltypes.IsBinary(typ):
+		return "binary"
+	case sqltypes.IsDateOrTime(typ):
+		return "temporal"
+	}
+	return typ.String()
+}
+
+// checkLookupVindexes checks that lookup vindexes have an owner, so that
+// vtgate maintains their lookup table, and that the lookup table is in the
+// VSchema.
+func (l *linter) checkLookupVindexes() {
+	for _, name := range sortedKeys(l.vs.Vindexes) {
+		v := l.vs.Vindexes[name]
+		vindex, err := vindexes.CreateVindex(v.Type, name, v.Params)
+		if err != nil {
+			// Reported by checkBuild.
+			continue
+		}
+		if _, ok := vindex.(vindexes.Lookup); !ok {
+			continue
+		}
+		lookupTable := v.Params["table"]
+		if v.Owner == "" {
+			l.add(vtctldatapb.VSchemaLintFinding_WARNING, CheckUnownedLookupVindex, "", "lookup vindex %s has no owner, so vtgate does not maintain its lookup table %s", name, lookupTable)
+		}
+
+		ks, table, ok := strings.Cut(lookupTable, ".")
+		if !ok {
+			continue
+		}
+		if lookupKs, ok := l.srvVSchema.Keyspaces[ks]; !ok || lookupKs.Tables[table] == nil {
+			l.add(vtctldatapb.VSchemaLintFinding_WARNING, CheckLookupTableNotInVSchema, "", "lookup table %s of vindex %s is not in the VSchema", lookupTable, name)
+		}
+	}
+}
+
+// checkRoutingRuleCycles reports the routing rules that, followed from one
+// table to the next, lead back to where they started. Rules that route a
+// table to itself are not cycles.
+func (l *linter) checkRoutingRuleCycles() {
+	// Rules for different tablet types are followed separately.
+	graphs := make(map[string]map[string]string)
+	for _, rule := range l.srvVSchema.GetRoutingRules().GetRules() {
+		if len(rule.ToTables) != 1 {
+			continue
+		}
+		from, tabletType, _ := strings.Cut(rule.FromTable, "@")
+		if graphs[tabletType] == nil {
+			graphs[tabletType] = make(map[string]string)
+		}
+		graphs[tabletType][from] = rule.ToTables[0]
+	}
+
+	reported := make(map[string]bool)
+	for _, tabletType := range sortedKeys(graphs) {
+		graph := graphs[tabletType]
+		for _, start := range sortedKeys(graph) {
+			path := []string{start}
+			seen := map[string]bool{start: true}
+			for node := start; ; {
+				next, ok := graph[node]
+				if !ok || next == node {
+					break
+				}
+				path = append(path, next)
+				if seen[next] {
+					cycle := path[indexOf(path, next):]
+					if key := tabletType + ":" + canonicalCycle(cycle); !reported[key] {
+						reported[key] = true
+						if tabletType != "" {
+							for i := range cycle {
+								cycle[i] += "@" + tabletType
+							}
+						}
+						l.add(vtctldatapb.VSchemaLintFinding_ERROR, CheckRoutingRuleCycle, "", "routing rules form a cycle: %s", strings.Join(cycle, " -> "))
+					}
+					break
+				}
+				seen[next] = true
+				node = next
+			}
+		}
+	}
+}
+
+func indexOf(s []string, v string) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// canonicalCycle returns the same string for all the rotations of a cycle,
+// whose last element repeats the first one.
+func canonicalCycle(cycle []string) string {
+	nodes := cycle[:len(cycle)-1]
+	min := 0
+	for i := range nodes {
+		if nodes[i] < nodes[min] {
+			min = i
+		}
+	}
+	rotated := append(append([]string{}, nodes[min:]...), nodes[:min]...)
+	return strings.Join(rotated, ",")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vschemalint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vitess.io/vitess/go/sqltypes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+func tableDefinition(name string, columns ...string) *tabletmanagerdatapb.TableDefinition {
+	td := &tabletmanagerdatapb.TableDefinition{Name: name}
+	for i := 0; i < len(columns); i += 2 {
+		td.Columns = append(td.Columns, columns[i])
+		td.Fields = append(td.Fields, &querypb.Field{Name: columns[i], Type: querypb.Type(querypb.Type_value[columns[i+1]])})
+	}
+	return td
+}
+
+// findings formats the findings as "SEVERITY check table" for comparison.
+func findings(fs []*vtctldatapb.VSchemaLintFinding) []string {
+	var out []string
+	for _, f := range fs {
+		out = append(out, f.Severity.String()+" "+f.Check+" "+f.Table)
+	}
+	return out
+}
+
+func TestLint(t *testing.T) {
+	srvVSchema := &vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{
+			"ks": {
+				Sharded: true,
+				Vindexes: map[string]*vschemapb.Vindex{
+					"hash":   {Type: "hash"},
+					"xxhash": {Type: "xxhash"},
+					"email_lookup": {
+						Type:   "consistent_lookup_unique",
+						Params: map[string]string{"table": "unsharded.email_lookup", "from": "email", "to": "keyspace_id"},
+					},
+				},
+				Tables: map[string]*vschemapb.Table{
+					"customer": {
+						ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "hash"}, {Column: "email", Name: "email_lookup"}},
+						Columns:        []*vschemapb.Column{{Name: "email", Type: sqltypes.Int64}},
+					},
+					"corder": {
+						ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "sku", Name: "hash"}},
+					},
+					"product": {
+						ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "missing", Name: "xxhash"}},
+					},
+					"no_vindex": {},
+					"seq":       {Type: "sequence"},
+					"ref":       {Type: "reference"},
+				},
+			},
+			"unsharded": {},
+		},
+	}
+	tables := []*tabletmanagerdatapb.TableDefinition{
+		tableDefinition("customer", "id", "INT64", "email", "VARCHAR"),
+		tableDefinition("corder", "sku", "VARBINARY"),
+		tableDefinition("product", "id", "INT64"),
+		tableDefinition("no_vindex", "id", "INT64"),
+		tableDefinition("seq", "id", "INT64"),
+		tableDefinition("not_in_vschema", "id", "INT64"),
+		tableDefinition("_vt_HOLD_6ace8bcef73211ea87e9f875a4d24e90_20200915120410"),
+	}
+
+	got := Lint("ks", srvVSchema, tables)
+	assert.Equal(t, []string{
+		"ERROR invalid_vschema ",
+		"WARNING lookup_table_not_in_vschema ",
+		"WARNING unowned_lookup_vindex ",
+		"ERROR vindex_column_type corder",
+		"WARNING column_type customer",
+		"WARNING vindex_column_type customer",
+		"ERROR missing_primary_vindex no_vindex",
+		"ERROR missing_primary_vindex not_in_vschema",
+		"ERROR vindex_column product",
+		"ERROR sequence_in_sharded_keyspace seq",
+	}, findings(got))
+
+	// Without the tablet schema, only the VSchema itself is checked.
+	got = Lint("ks", srvVSchema, nil)
+	assert.Equal(t, []string{
+		"ERROR invalid_vschema ",
+		"WARNING lookup_table_not_in_vschema ",
+		"WARNING unowned_lookup_vindex ",
+		"ERROR missing_primary_vindex no_vindex",
+		"ERROR sequence_in_sharded_keyspace seq",
+	}, findings(got))
+}
+
+func TestLintInvalidVSchema(t *testing.T) {
+	srvVSchema := &vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{
+			"ks": {
+				Sharded: true,
+				Tables: map[string]*vschemapb.Table{
+					"t": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "nonexistent"}}},
+				},
+			},
+		},
+	}
+	got := Lint("ks", srvVSchema, nil)
+	assert.Equal(t, []string{"ERROR invalid_vschema "}, findings(got))
+}
+
+func TestLintRoutingRuleCycles(t *testing.T) {
+	srvVSchema := &vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{"ks": {}},
+		RoutingRules: &vschemapb.RoutingRules{
+			Rules: []*vschemapb.RoutingRule{
+				{FromTable: "a", ToTables: []string{"ks.b"}},
+				{FromTable: "ks.b", ToTables: []string{"ks.c"}},
+				{FromTable: "ks.c", ToTables: []string{"a"}},
+				// Tables routed to themselves, as MoveTables does, are not cycles.
+				{FromTable: "ks.t", ToTables: []string{"ks.t"}},
+				{FromTable: "x@replica", ToTables: []string{"y"}},
+				{FromTable: "y@replica", ToTables: []string{"x"}},
+				// A cycle across tablet types is not followed.
+				{FromTable: "y", ToTables: []string{"z"}},
+			},
+		},
+	}
+	got := Lint("ks", srvVSchema, nil)
+	var messages []string
+	for _, f := range got {
+		assert.Equal(t, CheckRoutingRuleCycle, f.Check)
+		messages = append(messages, f.Message)
+	}
+	assert.Equal(t, []string{
+		"routing rules form a cycle: a -> ks.b -> ks.c -> a",
+		"routing rules form a cycle: x@replica -> y@replica -> x@replica",
+	}, messages)
+}
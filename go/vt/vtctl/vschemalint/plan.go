@@ -0,0 +1,335 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vschemalint
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder"
+	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/semantics"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+// PlanChanges plans the queries against both SrvVSchemas, the way vtgate
+// would for a session that targets the given keyspace, and returns the
+// queries whose route changes.
+func PlanChanges(keyspace string, before, after *vschemapb.SrvVSchema, queries []string) []*vtctldatapb.VSchemaPlanChange {
+	beforeVSchema := newPlanVSchema(keyspace, before)
+	afterVSchema := newPlanVSchema(keyspace, after)
+
+	var changes []*vtctldatapb.VSchemaPlanChange
+	for _, query := range queries {
+		b, a := describeRoute(query, beforeVSchema), describeRoute(query, afterVSchema)
+		if a != b {
+			changes = append(changes, &vtctldatapb.VSchemaPlanChange{
+				Query:  query,
+				Before: b,
+				After:  a,
+			})
+		}
+	}
+	return changes
+}
+
+// describeRoute plans the query and describes where its plan sends it.
+func describeRoute(query string, vschema *planVSchema) string {
+	stmt, reserved, err := sqlparser.Parse2(query)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	result, err := sqlparser.RewriteAST(stmt, vschema.keyspaceName, sqlparser.SQLSelectLimitUnset, "", nil, vschema)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	reservedVars := sqlparser.NewReservedVars("vtg", reserved)
+	plan, err := planbuilder.BuildFromStmt(context.Background(), query, result.AST, reservedVars, vschema, result.BindVarNeeds, true, true)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if plan.Instructions == nil {
+		return ""
+	}
+
+	var routes []string
+	var walk func(pd engine.PrimitiveDescription)
+	walk = func(pd engine.PrimitiveDescription) {
+		if pd.Keyspace != nil {
+			route := fmt.Sprintf("%s(%s %s", pd.OperatorType, pd.Variant, pd.Keyspace.Name)
+			if vindex, ok := pd.Other["Vindex"]; ok {
+				route += fmt.Sprintf(" %v", vindex)
+			}
+			routes = append(routes, route+")")
+		}
+		for _, input := range pd.Inputs {
+			walk(input)
+		}
+	}
+	walk(engine.PrimitiveToPlanDescription(plan.Instructions))
+	return strings.Join(routes, ", ")
+}
+
+var _ plancontext.VSchema = (*planVSchema)(nil)
+
+// planVSchema is the VSchema of a session that targets a keyspace on its
+// primary tablets, without any session state.
+type planVSchema struct {
+	srvVSchema   *vschemapb.SrvVSchema
+	v            *vindexes.VSchema
+	keyspaceName string
+	version      plancontext.PlannerVersion
+}
+
+func newPlanVSchema(keyspace string, srvVSchema *vschemapb.SrvVSchema) *planVSchema {
+	return &planVSchema{
+		srvVSchema:   srvVSchema,
+		v:            vindexes.BuildVSchema(srvVSchema),
+		keyspaceName: keyspace,
+		version:      querypb.ExecuteOptions_Gen4,
+	}
+}
+
+func (vs *planVSchema) keyspace() *vindexes.Keyspace {
+	if ks, ok := vs.v.Keyspaces[vs.keyspaceName]; ok {
+		return ks.Keyspace
+	}
+	return nil
+}
+
+// FindTable implements the VSchema interface.
+func (vs *planVSchema) FindTable(tab sqlparser.TableName) (*vindexes.Table, string, topodatapb.TabletType, key.Destination, error) {
+	destKeyspace, destTabletType, destTarget, err := topoproto.ParseDestination(tab.Qualifier.String(), topodatapb.TabletType_PRIMARY)
+	if err != nil {
+		return nil, destKeyspace, destTabletType, destTarget, err
+	}
+	if destKeyspace == "" {
+		destKeyspace = vs.keyspaceName
+	}
+	table, err := vs.v.FindTable(destKeyspace, tab.Name.String())
+	if err != nil {
+		return nil, destKeyspace, destTabletType, destTarget, err
+	}
+	return table, destKeyspace, destTabletType, destTarget, nil
+}
+
+// FindView implements the VSchema interface.
+func (vs *planVSchema) FindView(tab sqlparser.TableName) sqlparser.SelectStatement {
+	destKeyspace, _, _, err := topoproto.ParseDestination(tab.Qualifier.String(), topodatapb.TabletType_PRIMARY)
+	if err != nil {
+		return nil
+	}
+	if destKeyspace == "" {
+		destKeyspace = vs.keyspaceName
+	}
+	return vs.v.FindView(destKeyspace, tab.Name.String())
+}
+
+// FindTableOrVindex implements the VSchema interface.
+func (vs *planVSchema) FindTableOrVindex(tab sqlparser.TableName) (*vindexes.Table, vindexes.Vindex, string, topodatapb.TabletType, key.Destination, error) {
+	destKeyspace, destTabletType, destTarget, err := topoproto.ParseDestination(tab.Qualifier.String(), topodatapb.TabletType_PRIMARY)
+	if err != nil {
+		return nil, nil, destKeyspace, destTabletType, destTarget, err
+	}
+	if destKeyspace == "" {
+		destKeyspace = vs.keyspaceName
+	}
+	if tab.Qualifier.IsEmpty() && tab.Name.String() == "dual" {
+		return &vindexes.Table{
+			Name:     sqlparser.NewIdentifierCS("dual"),
+			Keyspace: vs.keyspace(),
+			Type:     vindexes.TypeReference,
+		}, nil, destKeyspace, destTabletType, destTarget, nil
+	}
+	table, vindex, err := vs.v.FindTableOrVindex(destKeyspace, tab.Name.String(), destTabletType)
+	if err != nil {
+		return nil, nil, destKeyspace, destTabletType, destTarget, err
+	}
+	return table, vindex, destKeyspace, destTabletType, destTarget, nil
+}
+
+// DefaultKeyspace implements the VSchema interface.
+func (vs *planVSchema) DefaultKeyspace() (*vindexes.Keyspace, error) {
+	if ks := vs.keyspace(); ks != nil {
+		return ks, nil
+	}
+	return nil, vterrors.VT05003(vs.keyspaceName)
+}
+
+// TargetString implements the VSchema interface.
+func (vs *planVSchema) TargetString() string {
+	return vs.keyspaceName
+}
+
+// Destination implements the VSchema interface.
+func (vs *planVSchema) Destination() key.Destination {
+	return nil
+}
+
+// TabletType implements the VSchema interface.
+func (vs *planVSchema) TabletType() topodatapb.TabletType {
+	return topodatapb.TabletType_PRIMARY
+}
+
+// TargetDestination implements the VSchema interface.
+func (vs *planVSchema) TargetDestination(qualifier string) (key.Destination, *vindexes.Keyspace, topodatapb.TabletType, error) {
+	keyspaceName := vs.keyspaceName
+	if qualifier != "" {
+		keyspaceName = qualifier
+	}
+	ks, ok := vs.v.Keyspaces[keyspaceName]
+	if !ok {
+		return nil, nil, 0, vterrors.VT05003(keyspaceName)
+	}
+	return nil, ks.Keyspace, topodatapb.TabletType_PRIMARY, nil
+}
+
+// AnyKeyspace implements the VSchema interface.
+func (vs *planVSchema) AnyKeyspace() (*vindexes.Keyspace, error) {
+	return vs.DefaultKeyspace()
+}
+
+// FirstSortedKeyspace implements the VSchema interface.
+func (vs *planVSchema) FirstSortedKeyspace() (*vindexes.Keyspace, error) {
+	return vs.DefaultKeyspace()
+}
+
+// SysVarSetEnabled implements the VSchema interface.
+func (vs *planVSchema) SysVarSetEnabled() bool {
+	return true
+}
+
+// KeyspaceExists implements the VSchema interface.
+func (vs *planVSchema) KeyspaceExists(keyspace string) bool {
+	_, ok := vs.v.Keyspaces[keyspace]
+	return ok
+}
+
+// AllKeyspace implements the VSchema interface.
+func (vs *planVSchema) AllKeyspace() ([]*vindexes.Keyspace, error) {
+	var keyspaces []*vindexes.Keyspace
+	for _, name := range sortedKeys(vs.v.Keyspaces) {
+		keyspaces = append(keyspaces, vs.v.Keyspaces[name].Keyspace)
+	}
+	return keyspaces, nil
+}
+
+// FindKeyspace implements the VSchema interface.
+func (vs *planVSchema) FindKeyspace(keyspace string) (*vindexes.Keyspace, error) {
+	if ks, ok := vs.v.Keyspaces[keyspace]; ok {
+		return ks.Keyspace, nil
+	}
+	return nil, nil
+}
+
+// GetSemTable implements the VSchema interface.
+func (vs *planVSchema) GetSemTable() *semantics.SemTable {
+	return nil
+}
+
+// Planner implements the VSchema interface.
+func (vs *planVSchema) Planner() plancontext.PlannerVersion {
+	return vs.version
+}
+
+// SetPlannerVersion implements the VSchema interface.
+func (vs *planVSchema) SetPlannerVersion(v plancontext.PlannerVersion) {
+	vs.version = v
+}
+
+// ConnCollation implements the VSchema interface.
+func (vs *planVSchema) ConnCollation() collations.ID {
+	return collations.Default()
+}
+
+// ErrorIfShardedF implements the VSchema interface.
+func (vs *planVSchema) ErrorIfShardedF(keyspace *vindexes.Keyspace, _, errFmt string, params ...any) error {
+	if keyspace.Sharded {
+		return fmt.Errorf(errFmt, params...)
+	}
+	return nil
+}
+
+// WarnUnshardedOnly implements the VSchema interface.
+func (vs *planVSchema) WarnUnshardedOnly(string, ...any) {}
+
+// PlannerWarning implements the VSchema interface.
+func (vs *planVSchema) PlannerWarning(string) {}
+
+// ForeignKeyMode implements the VSchema interface.
+func (vs *planVSchema) ForeignKeyMode() string {
+	return "allow"
+}
+
+// GetVSchema implements the VSchema interface.
+func (vs *planVSchema) GetVSchema() *vindexes.VSchema {
+	return vs.v
+}
+
+// GetSrvVschema implements the VSchema interface.
+func (vs *planVSchema) GetSrvVschema() *vschemapb.SrvVSchema {
+	return vs.srvVSchema
+}
+
+// FindRoutedShard implements the VSchema interface.
+func (vs *planVSchema) FindRoutedShard(_, shard string) (string, error) {
+	return shard, nil
+}
+
+// IsShardRoutingEnabled implements the VSchema interface.
+func (vs *planVSchema) IsShardRoutingEnabled() bool {
+	return false
+}
+
+// IsViewsEnabled implements the VSchema interface.
+func (vs *planVSchema) IsViewsEnabled() bool {
+	return false
+}
+
+// GetUDV implements the VSchema interface.
+func (vs *planVSchema) GetUDV(string) *querypb.BindVariable {
+	return nil
+}
+
+// PlanPrepareStatement implements the VSchema interface.
+func (vs *planVSchema) PlanPrepareStatement(context.Context, string) (*engine.Plan, sqlparser.Statement, error) {
+	return nil, nil, vterrors.VT12001("prepared statements when linting a VSchema")
+}
+
+// ClearPrepareData implements the VSchema interface.
+func (vs *planVSchema) ClearPrepareData(string) {}
+
+// GetPrepareData implements the VSchema interface.
+func (vs *planVSchema) GetPrepareData(string) *vtgatepb.PrepareData {
+	return nil
+}
+
+// StorePrepareData implements the VSchema interface.
+func (vs *planVSchema) StorePrepareData(string, *vtgatepb.PrepareData) {}
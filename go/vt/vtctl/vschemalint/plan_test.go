@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vschemalint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+func TestPlanChanges(t *testing.T) {
+	before := &vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{
+			"ks": {
+				Sharded: true,
+				Vindexes: map[string]*vschemapb.Vindex{
+					"hash": {Type: "hash"},
+				},
+				Tables: map[string]*vschemapb.Table{
+					"customer": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "id", Name: "hash"}}},
+				},
+			},
+		},
+	}
+	after := proto.Clone(before).(*vschemapb.SrvVSchema)
+	after.Keyspaces["ks"].Vindexes["xxhash"] = &vschemapb.Vindex{Type: "xxhash"}
+	after.Keyspaces["ks"].Tables["customer"].ColumnVindexes = []*vschemapb.ColumnVindex{{Column: "email", Name: "xxhash"}}
+
+	changes := PlanChanges("ks", before, after, []string{
+		"select * from customer where id = 1",
+		"select * from customer where email = 'a@b.c'",
+		"select 1 from dual",
+	})
+	require.Len(t, changes, 2)
+
+	assert.Equal(t, "select * from customer where id = 1", changes[0].Query)
+	assert.Equal(t, "Route(EqualUnique ks hash)", changes[0].Before)
+	assert.Equal(t, "Route(Scatter ks)", changes[0].After)
+
+	assert.Equal(t, "select * from customer where email = 'a@b.c'", changes[1].Query)
+	assert.Equal(t, "Route(Scatter ks)", changes[1].Before)
+	assert.Equal(t, "Route(EqualUnique ks xxhash)", changes[1].After)
+}
+
+func TestPlanChangesError(t *testing.T) {
+	before := &vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{
+			"ks": {Tables: map[string]*vschemapb.Table{"t": {}}},
+		},
+	}
+	after := &vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{
+			"ks": {Sharded: true},
+		},
+	}
+
+	changes := PlanChanges("ks", before, after, []string{"select * from t"})
+	require.Len(t, changes, 1)
+	assert.Equal(t, "Route(Unsharded ks)", changes[0].Before)
+	assert.Contains(t, changes[0].After, "error: ")
+}
@@ -0,0 +1,654 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// vindexAlterationsPath is the global topo directory that holds one
+// VindexAlteration record per altered table, keyed by keyspace/table.
+const vindexAlterationsPath = "vindex_alterations"
+
+// AlterVindex is part of the vtctlservicepb.VtctldServer interface.
+//
+// It adds a vindex to a table, or replaces the table's primary vindex, and
+// saves the resulting VSchema while holding the keyspace lock:
+//   - A functional vindex computes keyspace ids from the column values
+//     alone, so it is added right away.
+//   - A lookup vindex is added as write_only, and a workflow is created to
+//     backfill its lookup table, as CreateLookupVindex does. It is used for
+//     reads once externalized with ExternalizeVindex.
+//   - A new primary vindex replaces the current one if every row already
+//     lives on the shard the new vindex maps it to. Otherwise the table is
+//     moved into the target keyspace of the request, sharded by the new
+//     vindex, with a MoveTables workflow whose traffic is then switched as
+//     usual. See alterPrimaryVindex.
+//
+// The previous vindexes of the table are recorded in the topo, and can be
+// restored with RollbackAlterVindex. A move into the target keyspace is
+// rolled back by cancelling its workflow instead.
+func (s *Server) AlterVindex(ctx context.Context, req *vtctldatapb.AlterVindexRequest) (resp *vtctldatapb.AlterVindexResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.AlterVindex")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("table", req.Table)
+	span.Annotate("vindex_name", req.VindexName)
+	span.Annotate("primary", req.Primary)
+	span.Annotate("dry_run", req.DryRun)
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+
+	if req.Table == "" || req.VindexName == "" || len(req.Columns) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "table, vindex name and columns are required")
+	}
+	if req.Primary {
+		return s.alterPrimaryVindex(ctx, req)
+	}
+
+	// The lock is held from the read of the VSchema to its save, so that no
+	// other vschema change or workflow on the keyspace can race with them.
+	ctx, unlock, lockErr := s.ts.LockKeyspace(ctx, req.Keyspace, "AlterVindex")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	vs, alteration, vindex, err := s.getAlteredVSchema(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if vindex.NeedsVCursor() {
+		return s.alterLookupVindex(ctx, req, vs, alteration)
+	}
+
+	resp = &vtctldatapb.AlterVindexResponse{VSchema: vs}
+	if req.DryRun {
+		return resp, nil
+	}
+	if err = s.saveAlteredVSchema(ctx, req, vs, alteration); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// alterPrimaryVindex replaces the primary vindex of a table, or moves the
+// table into the target keyspace of the request if some of its rows live on
+// another shard than the new vindex maps them to.
+//
+// The writes to the table are denied on the primaries of its keyspace from
+// before the scan of its rows until the VSchema is saved, so that no row can
+// be written to a shard the new vindex does not map it to in between. The
+// keyspace is only locked to deny and allow the writes, and to save the
+// VSchema, not during the scan. A dry run scans the rows without denying the
+// writes.
+func (s *Server) alterPrimaryVindex(ctx context.Context, req *vtctldatapb.AlterVindexRequest) (resp *vtctldatapb.AlterVindexResponse, err error) {
+	vs, _, vindex, err := s.getAlteredVSchema(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp = &vtctldatapb.AlterVindexResponse{VSchema: vs}
+	if req.DryRun {
+		resp.RowsVerified, resp.RowsToMove, err = s.verifyVindexPlacement(ctx, req.Keyspace, req.Table, req.Columns, vindex)
+		if err != nil {
+			return nil, err
+		}
+		if resp.RowsToMove > 0 {
+			return s.moveTableByVindex(ctx, req, vs, resp)
+		}
+		return resp, nil
+	}
+
+	shardMap, err := s.ts.FindAllShardsInKeyspace(ctx, req.Keyspace)
+	if err != nil {
+		return nil, err
+	}
+	shards := make([]*topo.ShardInfo, 0, len(shardMap))
+	for _, si := range shardMap {
+		shards = append(shards, si)
+	}
+	denied, err := s.changeTableWrites(ctx, req.Keyspace, req.Table, shards, disallowWrites)
+	// The writes are allowed again once the VSchema is saved, or when the
+	// alteration fails.
+	defer func() {
+		if _, allowErr := s.changeTableWrites(ctx, req.Keyspace, req.Table, denied, allowWrites); allowErr != nil {
+			allowErr = vterrors.Wrapf(allowErr, "failed to allow the writes to table %s again", req.Table)
+			log.Error(allowErr)
+			if err == nil {
+				resp, err = nil, allowErr
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.RowsVerified, resp.RowsToMove, err = s.verifyVindexPlacement(ctx, req.Keyspace, req.Table, req.Columns, vindex)
+	if err != nil {
+		return nil, err
+	}
+
+	lctx, unlock, lockErr := s.ts.LockKeyspace(ctx, req.Keyspace, "AlterVindex")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	// The VSchema is read again, as it may have changed during the scan.
+	current, alteration, _, err := s.getAlteredVSchema(lctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !proto.Equal(current.Tables[req.Table], vs.Tables[req.Table]) || !proto.Equal(current.Vindexes[req.VindexName], vs.Vindexes[req.VindexName]) {
+		return nil, vterrors.Errorf(vtrpcpb.Code_ABORTED, "the vindexes of table %s changed while its rows were verified", req.Table)
+	}
+	resp.VSchema = current
+	if resp.RowsToMove > 0 {
+		return s.moveTableByVindex(lctx, req, current, resp)
+	}
+	if err = s.saveAlteredVSchema(lctx, req, current, alteration); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// getAlteredVSchema returns the keyspace vschema with the vindex of the
+// request added to the table, the record needed to roll the change back,
+// and the vindex.
+func (s *Server) getAlteredVSchema(ctx context.Context, req *vtctldatapb.AlterVindexRequest) (*vschemapb.Keyspace, *vtctldatapb.VindexAlteration, vindexes.Vindex, error) {
+	vs, err := s.ts.GetVSchema(ctx, req.Keyspace)
+	if err != nil {
+		return nil, nil, nil, vterrors.Wrapf(err, "GetVSchema(%s)", req.Keyspace)
+	}
+
+	vs, alteration, err := alterTableVindexes(vs, req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ksschema, err := vindexes.BuildKeyspaceSchema(vs, req.Keyspace)
+	if err != nil {
+		return nil, nil, nil, vterrors.Wrapf(err, "invalid vschema after altering vindexes of table %s", req.Table)
+	}
+	vindex := ksschema.Vindexes[req.VindexName]
+	if _, ok := vindex.(vindexes.SingleColumn); ok && len(req.Columns) != 1 {
+		return nil, nil, nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "vindex %s takes a single column, got %d", req.VindexName, len(req.Columns))
+	}
+	if vindex.NeedsVCursor() && req.Primary {
+		return nil, nil, nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "lookup vindex %s cannot be the primary vindex of table %s", req.VindexName, req.Table)
+	}
+	return vs, alteration, vindex, nil
+}
+
+// saveAlteredVSchema saves the altered keyspace vschema. The keyspace must be
+// locked.
+func (s *Server) saveAlteredVSchema(ctx context.Context, req *vtctldatapb.AlterVindexRequest, vs *vschemapb.Keyspace, alteration *vtctldatapb.VindexAlteration) error {
+	// The alteration is recorded first, so that a saved VSchema can always
+	// be rolled back.
+	if err := s.saveVindexAlteration(ctx, alteration); err != nil {
+		return err
+	}
+	if err := s.ts.SaveVSchema(ctx, req.Keyspace, vs); err != nil {
+		return vterrors.Wrapf(err, "SaveVSchema(%s)", req.Keyspace)
+	}
+	if err := s.ts.RebuildSrvVSchema(ctx, req.Cells); err != nil {
+		return vterrors.Wrapf(err, "RebuildSrvVSchema")
+	}
+	return nil
+}
+
+// changeTableWrites denies or allows the writes to a table on the primaries
+// of the given shards of its keyspace, while holding the keyspace lock, and
+// returns the shards it changed.
+func (s *Server) changeTableWrites(ctx context.Context, keyspace, table string, shards []*topo.ShardInfo, access accessType) (changed []*topo.ShardInfo, err error) {
+	if len(shards) == 0 {
+		return nil, nil
+	}
+	ctx, unlock, lockErr := s.ts.LockKeyspace(ctx, keyspace, "AlterVindex")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	return s.changePrimaryWrites(ctx, keyspace, []string{table}, shards, access)
+}
+
+// alterLookupVindex adds a lookup vindex to a table as write_only, and
+// creates the workflow that backfills its lookup table. The keyspace of the
+// table must be locked.
+func (s *Server) alterLookupVindex(ctx context.Context, req *vtctldatapb.AlterVindexRequest, vs *vschemapb.Keyspace, alteration *vtctldatapb.VindexAlteration) (resp *vtctldatapb.AlterVindexResponse, err error) {
+	vindex := proto.Clone(vs.Vindexes[req.VindexName]).(*vschemapb.Vindex)
+	lookupKeyspace, _, err := sqlparser.ParseTable(vindex.Params["table"])
+	if err != nil || lookupKeyspace == "" {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "vindex table name must be in the form <keyspace>.<table>, got: %v", vindex.Params["table"])
+	}
+	if lookupKeyspace != req.Keyspace {
+		lctx, unlock, lockErr := s.ts.LockKeyspace(ctx, lookupKeyspace, "AlterVindex")
+		if lockErr != nil {
+			return nil, lockErr
+		}
+		ctx = lctx
+		defer unlock(&err)
+	}
+
+	specs := &vschemapb.Keyspace{
+		Vindexes: map[string]*vschemapb.Vindex{req.VindexName: vindex},
+		Tables: map[string]*vschemapb.Table{
+			req.Table: {ColumnVindexes: []*vschemapb.ColumnVindex{{Name: req.VindexName, Columns: req.Columns}}},
+		},
+	}
+	ms, sourceVSchema, targetVSchema, err := s.PrepareCreateLookup(ctx, req.Keyspace, specs, false)
+	if err != nil {
+		return nil, err
+	}
+	ms.Cell = strings.Join(req.Cells, ",")
+
+	resp = &vtctldatapb.AlterVindexResponse{VSchema: sourceVSchema, Workflow: ms.Workflow}
+	if req.DryRun {
+		return resp, nil
+	}
+
+	alteration.ColumnVindexes = sourceVSchema.Tables[req.Table].ColumnVindexes
+	alteration.Workflow = ms.Workflow
+	alteration.WorkflowKeyspace = ms.TargetKeyspace
+	if err = s.saveVindexAlteration(ctx, alteration); err != nil {
+		return nil, err
+	}
+	// The lookup table is created and backfilled before the vindex is added
+	// to the table, as CreateLookupVindex does.
+	if lookupKeyspace != req.Keyspace {
+		if err = s.ts.SaveVSchema(ctx, lookupKeyspace, targetVSchema); err != nil {
+			return nil, vterrors.Wrapf(err, "SaveVSchema(%s)", lookupKeyspace)
+		}
+	}
+	mz, err := s.prepareMaterializerStreams(ctx, ms, s.ts)
+	if err != nil {
+		return nil, err
+	}
+	if err = mz.startStreams(ctx); err != nil {
+		return nil, err
+	}
+	if err = s.ts.SaveVSchema(ctx, req.Keyspace, sourceVSchema); err != nil {
+		return nil, vterrors.Wrapf(err, "SaveVSchema(%s)", req.Keyspace)
+	}
+	if err = s.ts.RebuildSrvVSchema(ctx, req.Cells); err != nil {
+		return nil, vterrors.Wrapf(err, "RebuildSrvVSchema")
+	}
+
+	return resp, nil
+}
+
+// moveTableByVindex moves a table into the target keyspace of the request
+// with a MoveTables workflow, with the vindexes it has in the altered
+// vschema of its keyspace. The keyspace of the table must be locked, unless
+// it is a dry run.
+func (s *Server) moveTableByVindex(ctx context.Context, req *vtctldatapb.AlterVindexRequest, vs *vschemapb.Keyspace, resp *vtctldatapb.AlterVindexResponse) (_ *vtctldatapb.AlterVindexResponse, err error) {
+	if req.TargetKeyspace == "" || req.TargetKeyspace == req.Keyspace {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "%d of the %d rows of table %s map to another shard under vindex %s, a target keyspace is required to move the table with MoveTables",
+			resp.RowsToMove, resp.RowsVerified, req.Table, req.VindexName)
+	}
+
+	ctx, unlock, lockErr := s.ts.LockKeyspace(ctx, req.TargetKeyspace, "AlterVindex")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	targetVSchema, err := s.ts.GetVSchema(ctx, req.TargetKeyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetVSchema(%s)", req.TargetKeyspace)
+	}
+	if !targetVSchema.Sharded {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "keyspace %s is not sharded", req.TargetKeyspace)
+	}
+	if _, ok := targetVSchema.Tables[req.Table]; ok {
+		return nil, vterrors.Errorf(vtrpcpb.Code_ALREADY_EXISTS, "table %s already exists in the %s keyspace vschema", req.Table, req.TargetKeyspace)
+	}
+	table := proto.Clone(vs.Tables[req.Table]).(*vschemapb.Table)
+	if targetVSchema.Vindexes == nil {
+		targetVSchema.Vindexes = make(map[string]*vschemapb.Vindex)
+	}
+	for _, cv := range table.ColumnVindexes {
+		vindex := vs.Vindexes[cv.Name]
+		if existing, ok := targetVSchema.Vindexes[cv.Name]; ok {
+			if !proto.Equal(existing, vindex) {
+				return nil, vterrors.Errorf(vtrpcpb.Code_ALREADY_EXISTS, "vindex %s already exists in keyspace %s with a different definition", cv.Name, req.TargetKeyspace)
+			}
+			continue
+		}
+		targetVSchema.Vindexes[cv.Name] = vindex
+	}
+	if targetVSchema.Tables == nil {
+		targetVSchema.Tables = make(map[string]*vschemapb.Table)
+	}
+	targetVSchema.Tables[req.Table] = table
+	if _, err := vindexes.BuildKeyspaceSchema(targetVSchema, req.TargetKeyspace); err != nil {
+		return nil, vterrors.Wrapf(err, "invalid vschema after adding table %s to keyspace %s", req.Table, req.TargetKeyspace)
+	}
+
+	resp.VSchema = targetVSchema
+	resp.Workflow = req.Table + "_alter_vindex"
+	if req.DryRun {
+		return resp, nil
+	}
+
+	if err = s.ts.SaveVSchema(ctx, req.TargetKeyspace, targetVSchema); err != nil {
+		return nil, vterrors.Wrapf(err, "SaveVSchema(%s)", req.TargetKeyspace)
+	}
+	if _, err = s.MoveTablesCreate(ctx, &vtctldatapb.MoveTablesCreateRequest{
+		Workflow:       resp.Workflow,
+		SourceKeyspace: req.Keyspace,
+		TargetKeyspace: req.TargetKeyspace,
+		IncludeTables:  []string{req.Table},
+		AutoStart:      true,
+	}); err != nil {
+		return nil, vterrors.Wrapf(err, "failed to create the %s workflow moving table %s to keyspace %s", resp.Workflow, req.Table, req.TargetKeyspace)
+	}
+
+	return resp, nil
+}
+
+// RollbackAlterVindex is part of the vtctlservicepb.VtctldServer interface.
+// It restores the vindexes a table had before the last AlterVindex on it,
+// provided they were not changed since, and drops the vindex the alteration
+// created if no other table uses it. The streams backfilling a lookup vindex
+// are deleted, but its lookup table is kept.
+func (s *Server) RollbackAlterVindex(ctx context.Context, req *vtctldatapb.RollbackAlterVindexRequest) (resp *vtctldatapb.RollbackAlterVindexResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.RollbackAlterVindex")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("table", req.Table)
+
+	ctx, unlock, lockErr := s.ts.LockKeyspace(ctx, req.Keyspace, "RollbackAlterVindex")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	alteration, err := s.getVindexAlteration(ctx, req.Keyspace, req.Table)
+	if err != nil {
+		if topo.IsErrType(err, topo.NoNode) {
+			return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "no vindex alteration to roll back for table %s in the %s keyspace", req.Table, req.Keyspace)
+		}
+		return nil, err
+	}
+
+	vs, err := s.ts.GetVSchema(ctx, req.Keyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetVSchema(%s)", req.Keyspace)
+	}
+
+	vs, err = rollbackTableVindexes(vs, alteration)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.ts.SaveVSchema(ctx, req.Keyspace, vs); err != nil {
+		return nil, vterrors.Wrapf(err, "SaveVSchema(%s)", req.Keyspace)
+	}
+	if alteration.Workflow != "" {
+		if err = s.deleteBackfillStreams(ctx, alteration.WorkflowKeyspace, alteration.Workflow); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.deleteVindexAlteration(ctx, req.Keyspace, req.Table); err != nil {
+		return nil, err
+	}
+	if err = s.ts.RebuildSrvVSchema(ctx, req.Cells); err != nil {
+		return nil, vterrors.Wrapf(err, "RebuildSrvVSchema")
+	}
+
+	return &vtctldatapb.RollbackAlterVindexResponse{VSchema: vs}, nil
+}
+
+// alterTableVindexes returns a copy of the keyspace vschema with the vindex
+// of the request added to the table, along with the record needed to roll
+// the change back.
+func alterTableVindexes(vs *vschemapb.Keyspace, req *vtctldatapb.AlterVindexRequest) (*vschemapb.Keyspace, *vtctldatapb.VindexAlteration, error) {
+	if !vs.Sharded {
+		return nil, nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "keyspace %s is not sharded", req.Keyspace)
+	}
+	vs = proto.Clone(vs).(*vschemapb.Keyspace)
+	table, ok := vs.Tables[req.Table]
+	if !ok {
+		return nil, nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "table %s not found in the %s keyspace vschema", req.Table, req.Keyspace)
+	}
+
+	alteration := &vtctldatapb.VindexAlteration{
+		Keyspace:               req.Keyspace,
+		Table:                  req.Table,
+		PreviousColumnVindexes: table.ColumnVindexes,
+		TimeCreated:            protoutil.TimeToProto(time.Now()),
+	}
+
+	if existing, ok := vs.Vindexes[req.VindexName]; ok {
+		if req.Vindex != nil && !proto.Equal(existing, req.Vindex) {
+			return nil, nil, vterrors.Errorf(vtrpcpb.Code_ALREADY_EXISTS, "vindex %s already exists in keyspace %s with a different definition", req.VindexName, req.Keyspace)
+		}
+	} else {
+		if req.Vindex == nil {
+			return nil, nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "vindex %s does not exist in keyspace %s and no definition was provided", req.VindexName, req.Keyspace)
+		}
+		if vs.Vindexes == nil {
+			vs.Vindexes = make(map[string]*vschemapb.Vindex)
+		}
+		vs.Vindexes[req.VindexName] = req.Vindex
+		alteration.CreatedVindex = req.VindexName
+	}
+
+	columnVindex := &vschemapb.ColumnVindex{
+		Name:    req.VindexName,
+		Columns: req.Columns,
+	}
+	columnVindexes := make([]*vschemapb.ColumnVindex, 0, len(table.ColumnVindexes)+1)
+	if req.Primary {
+		columnVindexes = append(columnVindexes, columnVindex)
+	}
+	for i, cv := range table.ColumnVindexes {
+		if cv.Name == req.VindexName {
+			// Promoting an existing secondary vindex drops its old entry.
+			if !req.Primary || i == 0 {
+				return nil, nil, vterrors.Errorf(vtrpcpb.Code_ALREADY_EXISTS, "vindex %s already defined on table %s", req.VindexName, req.Table)
+			}
+			continue
+		}
+		if req.Primary && i == 0 {
+			continue
+		}
+		columnVindexes = append(columnVindexes, cv)
+	}
+	if !req.Primary {
+		if len(columnVindexes) == 0 {
+			return nil, nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "table %s has no primary vindex", req.Table)
+		}
+		columnVindexes = append(columnVindexes, columnVindex)
+	}
+	table.ColumnVindexes = columnVindexes
+	alteration.ColumnVindexes = columnVindexes
+
+	return vs, alteration, nil
+}
+
+// rollbackTableVindexes returns a copy of the keyspace vschema with the
+// alteration undone.
+func rollbackTableVindexes(vs *vschemapb.Keyspace, alteration *vtctldatapb.VindexAlteration) (*vschemapb.Keyspace, error) {
+	vs = proto.Clone(vs).(*vschemapb.Keyspace)
+	table, ok := vs.Tables[alteration.Table]
+	if !ok {
+		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "table %s not found in the %s keyspace vschema", alteration.Table, alteration.Keyspace)
+	}
+	if !columnVindexesEqual(table.ColumnVindexes, alteration.ColumnVindexes) {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "the vindexes of table %s changed since they were altered, refusing to roll back", alteration.Table)
+	}
+	table.ColumnVindexes = alteration.PreviousColumnVindexes
+
+	if name := alteration.CreatedVindex; name != "" && !vindexInUse(vs, name) {
+		delete(vs.Vindexes, name)
+	}
+
+	if err := vindexes.ValidateKeyspace(vs); err != nil {
+		return nil, vterrors.Wrapf(err, "invalid vschema after rolling back vindexes of table %s", alteration.Table)
+	}
+	return vs, nil
+}
+
+func columnVindexesEqual(a, b []*vschemapb.ColumnVindex) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func vindexInUse(vs *vschemapb.Keyspace, name string) bool {
+	for _, table := range vs.Tables {
+		for _, cv := range table.ColumnVindexes {
+			if cv.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyVindexPlacement checks, on the primary of every shard in the
+// keyspace, whether each row of the table maps to a keyspace id within the
+// shard's key range under the given vindex. It returns the number of rows
+// checked, and the number of those that map elsewhere.
+func (s *Server) verifyVindexPlacement(ctx context.Context, keyspace, table string, columns []string, vindex vindexes.Vindex) (verified, toMove uint64, err error) {
+	shards, err := s.ts.FindAllShardsInKeyspace(ctx, keyspace)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, si := range shards {
+		if si.PrimaryAlias == nil {
+			return 0, 0, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", keyspace, si.ShardName())
+		}
+		tablet, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		err = s.scanVindexColumns(ctx, tablet.Tablet, table, columns, vindex, func(batch *vindexScanBatch) error {
+			for i, ksid := range batch.ksids {
+				if ksid == nil {
+					return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "row with %v in shard %s/%s does not map to a single keyspace id under vindex %s", batch.values[i], keyspace, si.ShardName(), vindex.String())
+				}
+				if !key.KeyRangeContains(si.KeyRange, ksid) {
+					toMove++
+				}
+			}
+			verified += uint64(len(batch.ksids))
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	log.Infof("Verified the placement of %d rows of table %s.%s under vindex %s, %d of them map to another shard", verified, keyspace, table, vindex.String(), toMove)
+	return verified, toMove, nil
+}
+
+// deleteBackfillStreams deletes the streams of the workflow backfilling the
+// lookup table of a lookup vindex from the primaries of its keyspace.
+func (s *Server) deleteBackfillStreams(ctx context.Context, keyspace, workflow string) error {
+	shards, err := s.ts.FindAllShardsInKeyspace(ctx, keyspace)
+	if err != nil {
+		return err
+	}
+	for _, si := range shards {
+		if si.PrimaryAlias == nil {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", keyspace, si.ShardName())
+		}
+		tablet, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return err
+		}
+		query := fmt.Sprintf(sqlDeleteWorkflow, encodeString(tablet.DbName()), encodeString(workflow))
+		if _, err := s.tmc.VReplicationExec(ctx, tablet.Tablet, query); err != nil {
+			return vterrors.Wrapf(err, "failed to delete the streams of the %s workflow on tablet %s", workflow, tablet.AliasString())
+		}
+	}
+	return nil
+}
+
+func vindexAlterationPath(keyspace, table string) string {
+	return path.Join(vindexAlterationsPath, keyspace, table)
+}
+
+func (s *Server) saveVindexAlteration(ctx context.Context, alteration *vtctldatapb.VindexAlteration) error {
+	conn, err := s.ts.ConnForCell(ctx, topo.GlobalCell)
+	if err != nil {
+		return err
+	}
+	data, err := alteration.MarshalVT()
+	if err != nil {
+		return err
+	}
+	_, err = conn.Update(ctx, vindexAlterationPath(alteration.Keyspace, alteration.Table), data, nil)
+	return err
+}
+
+func (s *Server) getVindexAlteration(ctx context.Context, keyspace, table string) (*vtctldatapb.VindexAlteration, error) {
+	conn, err := s.ts.ConnForCell(ctx, topo.GlobalCell)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := conn.Get(ctx, vindexAlterationPath(keyspace, table))
+	if err != nil {
+		return nil, err
+	}
+	alteration := &vtctldatapb.VindexAlteration{}
+	if err := alteration.UnmarshalVT(data); err != nil {
+		return nil, vterrors.Wrapf(err, "bad vindex alteration data: %q", data)
+	}
+	return alteration, nil
+}
+
+func (s *Server) deleteVindexAlteration(ctx context.Context, keyspace, table string) error {
+	conn, err := s.ts.ConnForCell(ctx, topo.GlobalCell)
+	if err != nil {
+		return err
+	}
+	return conn.Delete(ctx, vindexAlterationPath(keyspace, table), nil)
+}
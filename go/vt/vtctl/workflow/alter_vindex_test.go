@@ -0,0 +1,485 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver/testutil"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+func newAlterVindexTestServer(ctx context.Context, t *testing.T, tmc *fakeTMC) *Server {
+	t.Helper()
+
+	ts := memorytopo.NewServer("zone1")
+	testutil.AddTablets(ctx, t, ts, &testutil.AddTabletOptions{AlsoSetShardPrimary: true},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 100},
+			Keyspace: "ks",
+			Shard:    "-80",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 200},
+			Keyspace: "ks",
+			Shard:    "80-",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+	)
+	err := ts.SaveVSchema(ctx, "ks", &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				ColumnVindexes: []*vschemapb.ColumnVindex{{Name: "hash", Columns: []string{"id"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	// ks2 is the keyspace tables are moved into when their rows move.
+	require.NoError(t, ts.CreateKeyspace(ctx, "ks2", &topodatapb.Keyspace{}))
+	require.NoError(t, ts.SaveVSchema(ctx, "ks2", &vschemapb.Keyspace{Sharded: true}))
+	require.NoError(t, ts.RebuildSrvVSchema(ctx, nil))
+
+	return NewServer(ts, tmc)
+}
+
+// alterVindexTableSchemas is the schema of t1, whose primary key is id.
+func alterVindexTableSchemas() map[string]*tabletmanagerdatapb.TableDefinition {
+	return map[string]*tabletmanagerdatapb.TableDefinition{
+		"t1": {
+			Name:              "t1",
+			Schema:            "CREATE TABLE `t1` (\n  `id` bigint NOT NULL,\n  `email` varchar(128) DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB",
+			Columns:           []string{"id", "email"},
+			PrimaryKeyColumns: []string{"id"},
+			Fields: []*querypb.Field{
+				{Name: "id", Type: querypb.Type_INT64},
+				{Name: "email", Type: querypb.Type_VARCHAR},
+			},
+		},
+	}
+}
+
+func TestAlterVindex(t *testing.T) {
+	ctx := context.Background()
+	ws := newAlterVindexTestServer(ctx, t, &fakeTMC{})
+
+	resp, err := ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+		Keyspace:   "ks",
+		Table:      "t1",
+		VindexName: "xxhash",
+		Vindex:     &vschemapb.Vindex{Type: "xxhash"},
+		Columns:    []string{"email"},
+	})
+	require.NoError(t, err)
+
+	want := []*vschemapb.ColumnVindex{
+		{Name: "hash", Columns: []string{"id"}},
+		{Name: "xxhash", Columns: []string{"email"}},
+	}
+	utils.MustMatch(t, want, resp.VSchema.Tables["t1"].ColumnVindexes)
+
+	srvVSchema, err := ws.ts.GetSrvVSchema(ctx, "zone1")
+	require.NoError(t, err)
+	utils.MustMatch(t, want, srvVSchema.Keyspaces["ks"].Tables["t1"].ColumnVindexes)
+	assert.Contains(t, srvVSchema.Keyspaces["ks"].Vindexes, "xxhash")
+
+	_, err = ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+		Keyspace:   "ks",
+		Table:      "t1",
+		VindexName: "xxhash",
+		Columns:    []string{"name"},
+	})
+	assert.ErrorContains(t, err, "vindex xxhash already defined on table t1")
+
+	rollback, err := ws.RollbackAlterVindex(ctx, &vtctldatapb.RollbackAlterVindexRequest{
+		Keyspace: "ks",
+		Table:    "t1",
+	})
+	require.NoError(t, err)
+	utils.MustMatch(t, want[:1], rollback.VSchema.Tables["t1"].ColumnVindexes)
+	assert.NotContains(t, rollback.VSchema.Vindexes, "xxhash")
+
+	srvVSchema, err = ws.ts.GetSrvVSchema(ctx, "zone1")
+	require.NoError(t, err)
+	utils.MustMatch(t, want[:1], srvVSchema.Keyspaces["ks"].Tables["t1"].ColumnVindexes)
+
+	_, err = ws.RollbackAlterVindex(ctx, &vtctldatapb.RollbackAlterVindexRequest{
+		Keyspace: "ks",
+		Table:    "t1",
+	})
+	assert.ErrorContains(t, err, "no vindex alteration to roll back")
+}
+
+func TestAlterVindexDryRun(t *testing.T) {
+	ctx := context.Background()
+	ws := newAlterVindexTestServer(ctx, t, &fakeTMC{})
+
+	resp, err := ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+		Keyspace:   "ks",
+		Table:      "t1",
+		VindexName: "xxhash",
+		Vindex:     &vschemapb.Vindex{Type: "xxhash"},
+		Columns:    []string{"email"},
+		DryRun:     true,
+	})
+	require.NoError(t, err)
+	assert.Len(t, resp.VSchema.Tables["t1"].ColumnVindexes, 2)
+
+	vs, err := ws.ts.GetVSchema(ctx, "ks")
+	require.NoError(t, err)
+	assert.Len(t, vs.Tables["t1"].ColumnVindexes, 1)
+
+	_, err = ws.getVindexAlteration(ctx, "ks", "t1")
+	assert.True(t, topo.IsErrType(err, topo.NoNode), "expected no alteration record, got %v", err)
+}
+
+func TestAlterVindexErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *vtctldatapb.AlterVindexRequest
+		wantErr string
+	}{
+		{
+			name: "primary lookup vindex",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t1",
+				VindexName: "lookup",
+				Vindex: &vschemapb.Vindex{
+					Type:   "lookup_unique",
+					Params: map[string]string{"table": "ks.lkp", "from": "c1", "to": "keyspace_id"},
+				},
+				Columns: []string{"c1"},
+				Primary: true,
+			},
+			wantErr: "lookup vindex lookup cannot be the primary vindex of table t1",
+		},
+		{
+			name: "unknown table",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t2",
+				VindexName: "hash",
+				Columns:    []string{"id"},
+			},
+			wantErr: "table t2 not found",
+		},
+		{
+			name: "missing definition",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t1",
+				VindexName: "xxhash",
+				Columns:    []string{"email"},
+			},
+			wantErr: "no definition was provided",
+		},
+		{
+			name: "conflicting definition",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t1",
+				VindexName: "hash",
+				Vindex:     &vschemapb.Vindex{Type: "xxhash"},
+				Columns:    []string{"email"},
+			},
+			wantErr: "different definition",
+		},
+		{
+			name: "unknown vindex type",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t1",
+				VindexName: "bad",
+				Vindex:     &vschemapb.Vindex{Type: "nosuchtype"},
+				Columns:    []string{"email"},
+			},
+			wantErr: "invalid vschema",
+		},
+		{
+			name: "too many columns",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t1",
+				VindexName: "xxhash",
+				Vindex:     &vschemapb.Vindex{Type: "xxhash"},
+				Columns:    []string{"c1", "c2"},
+			},
+			wantErr: "takes a single column",
+		},
+		{
+			name: "missing columns",
+			req: &vtctldatapb.AlterVindexRequest{
+				Keyspace:   "ks",
+				Table:      "t1",
+				VindexName: "hash",
+			},
+			wantErr: "columns are required",
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newAlterVindexTestServer(ctx, t, &fakeTMC{})
+			_, err := ws.AlterVindex(ctx, tt.req)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestAlterPrimaryVindex(t *testing.T) {
+	fields := sqltypes.MakeTestFields("id|id", "uint64|uint64")
+	query := "select `id`, `id` from `t1` order by `id` limit 10000"
+
+	tests := []struct {
+		name           string
+		results        map[string]*querypb.QueryResult
+		targetKeyspace string
+		wantVerified   uint64
+		wantToMove     uint64
+		wantErr        string
+	}{
+		{
+			name: "rows stay in place",
+			results: map[string]*querypb.QueryResult{
+				"zone1-0000000100": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|1", "2|2")),
+				"zone1-0000000200": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "9223372036854775809|9223372036854775809")),
+			},
+			wantVerified: 3,
+		},
+		{
+			name: "rows would move",
+			results: map[string]*querypb.QueryResult{
+				"zone1-0000000100": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|1")),
+				"zone1-0000000200": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "5|5")),
+			},
+			wantErr: "1 of the 2 rows of table t1 map to another shard under vindex numeric, a target keyspace is required to move the table with MoveTables",
+		},
+		{
+			name: "null key column",
+			results: map[string]*querypb.QueryResult{
+				"zone1-0000000100": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|null")),
+				"zone1-0000000200": {},
+			},
+			wantErr: "does not map to a single keyspace id under vindex numeric",
+		},
+		{
+			name: "rows move to the target keyspace",
+			results: map[string]*querypb.QueryResult{
+				"zone1-0000000100": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|1")),
+				"zone1-0000000200": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "5|5")),
+			},
+			targetKeyspace: "ks2",
+			wantVerified:   2,
+			wantToMove:     1,
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmc := &fakeTMC{
+				dbaQueriesByTablet: map[string]map[string]*querypb.QueryResult{},
+				tableSchemas:       alterVindexTableSchemas(),
+			}
+			for alias, result := range tt.results {
+				tmc.dbaQueriesByTablet[alias] = map[string]*querypb.QueryResult{query: result}
+			}
+			ws := newAlterVindexTestServer(ctx, t, tmc)
+			// The creation of the MoveTables workflow is not tested here.
+			dryRun := tt.targetKeyspace != ""
+			// The writes to the table are denied while its rows are scanned,
+			// unless it is a dry run.
+			tmc.onExecuteFetchAsDba = func(tablet *topodatapb.Tablet) {
+				assert.Equal(t, !dryRun, deniedTables(ctx, t, ws.ts, tablet.Shard) != nil, "writes to t1 denied on shard %s", tablet.Shard)
+			}
+
+			resp, err := ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+				Keyspace:       "ks",
+				Table:          "t1",
+				VindexName:     "numeric",
+				Vindex:         &vschemapb.Vindex{Type: "numeric"},
+				Columns:        []string{"id"},
+				Primary:        true,
+				TargetKeyspace: tt.targetKeyspace,
+				DryRun:         dryRun,
+			})
+			// The writes are allowed again once the alteration is done.
+			for _, shard := range []string{"-80", "80-"} {
+				assert.Nil(t, deniedTables(ctx, t, ws.ts, shard), "writes to t1 denied on shard %s", shard)
+			}
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				vs, err := ws.ts.GetVSchema(ctx, "ks")
+				require.NoError(t, err)
+				assert.Equal(t, "hash", vs.Tables["t1"].ColumnVindexes[0].Name)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVerified, resp.RowsVerified)
+			assert.Equal(t, tt.wantToMove, resp.RowsToMove)
+			utils.MustMatch(t, []*vschemapb.ColumnVindex{{Name: "numeric", Columns: []string{"id"}}}, resp.VSchema.Tables["t1"].ColumnVindexes)
+
+			if tt.targetKeyspace != "" {
+				// The table is added to the vschema of the target keyspace with
+				// its new primary vindex, for a MoveTables workflow to move it.
+				assert.Equal(t, "t1_alter_vindex", resp.Workflow)
+				assert.Contains(t, resp.VSchema.Vindexes, "numeric")
+				vs, err := ws.ts.GetVSchema(ctx, tt.targetKeyspace)
+				require.NoError(t, err)
+				assert.Empty(t, vs.Tables)
+				return
+			}
+
+			rollback, err := ws.RollbackAlterVindex(ctx, &vtctldatapb.RollbackAlterVindexRequest{Keyspace: "ks", Table: "t1"})
+			require.NoError(t, err)
+			utils.MustMatch(t, []*vschemapb.ColumnVindex{{Name: "hash", Columns: []string{"id"}}}, rollback.VSchema.Tables["t1"].ColumnVindexes)
+		})
+	}
+}
+
+func TestAlterPrimaryVindexChangedDuringScan(t *testing.T) {
+	ctx := context.Background()
+	fields := sqltypes.MakeTestFields("id|id", "uint64|uint64")
+	query := "select `id`, `id` from `t1` order by `id` limit 10000"
+	tmc := &fakeTMC{
+		dbaQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+			"zone1-0000000100": {query: sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|1"))},
+			"zone1-0000000200": {query: sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "9223372036854775809|9223372036854775809"))},
+		},
+		tableSchemas: alterVindexTableSchemas(),
+	}
+	ws := newAlterVindexTestServer(ctx, t, tmc)
+
+	// Another change to the vindexes of the table lands during the scan.
+	changed := &vschemapb.Keyspace{
+		Sharded:  true,
+		Vindexes: map[string]*vschemapb.Vindex{"hash": {Type: "hash"}, "xxhash": {Type: "xxhash"}},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{
+				{Name: "hash", Columns: []string{"id"}},
+				{Name: "xxhash", Columns: []string{"email"}},
+			}},
+		},
+	}
+	tmc.onExecuteFetchAsDba = func(tablet *topodatapb.Tablet) {
+		require.NoError(t, ws.ts.SaveVSchema(ctx, "ks", changed))
+	}
+
+	_, err := ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+		Keyspace:   "ks",
+		Table:      "t1",
+		VindexName: "numeric",
+		Vindex:     &vschemapb.Vindex{Type: "numeric"},
+		Columns:    []string{"id"},
+		Primary:    true,
+	})
+	assert.EqualError(t, err, "the vindexes of table t1 changed while its rows were verified")
+
+	vs, err := ws.ts.GetVSchema(ctx, "ks")
+	require.NoError(t, err)
+	utils.MustMatch(t, changed, vs)
+	for _, shard := range []string{"-80", "80-"} {
+		assert.Nil(t, deniedTables(ctx, t, ws.ts, shard), "writes to t1 denied on shard %s", shard)
+	}
+}
+
+// deniedTables returns the tables whose writes are denied on the primary of
+// the given shard of keyspace ks.
+func deniedTables(ctx context.Context, t *testing.T, ts *topo.Server, shard string) []string {
+	t.Helper()
+	si, err := ts.GetShard(ctx, "ks", shard)
+	require.NoError(t, err)
+	if tc := si.GetTabletControl(topodatapb.TabletType_PRIMARY); tc != nil {
+		return tc.DeniedTables
+	}
+	return nil
+}
+
+func TestAlterLookupVindexDryRun(t *testing.T) {
+	ctx := context.Background()
+	ws := newAlterVindexTestServer(ctx, t, &fakeTMC{tableSchemas: alterVindexTableSchemas()})
+
+	resp, err := ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+		Keyspace:   "ks",
+		Table:      "t1",
+		VindexName: "email_lookup",
+		Vindex: &vschemapb.Vindex{
+			Type:   "consistent_lookup_unique",
+			Params: map[string]string{"table": "ks.t1_email_lookup", "from": "email", "to": "keyspace_id"},
+			Owner:  "t1",
+		},
+		Columns: []string{"email"},
+		DryRun:  true,
+	})
+	require.NoError(t, err)
+
+	// The vindex is added as write_only until its lookup table is backfilled
+	// by the workflow, and the lookup table is added to the vschema.
+	assert.Equal(t, "t1_email_lookup_vdx", resp.Workflow)
+	assert.Equal(t, "true", resp.VSchema.Vindexes["email_lookup"].Params["write_only"])
+	utils.MustMatch(t, []*vschemapb.ColumnVindex{
+		{Name: "hash", Columns: []string{"id"}},
+		{Name: "email_lookup", Columns: []string{"email"}},
+	}, resp.VSchema.Tables["t1"].ColumnVindexes)
+	assert.Contains(t, resp.VSchema.Tables, "t1_email_lookup")
+
+	vs, err := ws.ts.GetVSchema(ctx, "ks")
+	require.NoError(t, err)
+	assert.NotContains(t, vs.Vindexes, "email_lookup")
+	assert.NotContains(t, vs.Tables, "t1_email_lookup")
+}
+
+func TestRollbackAlterVindexAfterChange(t *testing.T) {
+	ctx := context.Background()
+	ws := newAlterVindexTestServer(ctx, t, &fakeTMC{})
+
+	_, err := ws.AlterVindex(ctx, &vtctldatapb.AlterVindexRequest{
+		Keyspace:   "ks",
+		Table:      "t1",
+		VindexName: "xxhash",
+		Vindex:     &vschemapb.Vindex{Type: "xxhash"},
+		Columns:    []string{"email"},
+	})
+	require.NoError(t, err)
+
+	vs, err := ws.ts.GetVSchema(ctx, "ks")
+	require.NoError(t, err)
+	vs.Tables["t1"].ColumnVindexes = vs.Tables["t1"].ColumnVindexes[:1]
+	require.NoError(t, ws.ts.SaveVSchema(ctx, "ks", vs))
+
+	_, err = ws.RollbackAlterVindex(ctx, &vtctldatapb.RollbackAlterVindexRequest{Keyspace: "ks", Table: "t1"})
+	assert.ErrorContains(t, err, "changed since they were altered")
+}
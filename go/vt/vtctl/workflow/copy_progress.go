@@ -0,0 +1,445 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/sets"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/concurrency"
+	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	"vitess.io/vitess/go/vt/proto/vttime"
+)
+
+const (
+	// copyStartLogType is the type of the _vt.vreplication_log entries that
+	// a stream records when it starts its copy phase.
+	copyStartLogType = "Started Copy Phase"
+
+	sqlGetTableCopyMetrics    = "select table_name, table_rows, data_length from information_schema.tables where table_schema = %s and table_name in (%s)"
+	sqlGetAllTableCopyMetrics = "select table_name, table_rows, data_length from information_schema.tables where table_schema = %s and table_type = 'BASE TABLE'"
+
+	// maxSourceTables bounds the number of tables of a source database that
+	// the copy progress reads the estimates of.
+	maxSourceTables = 10000
+)
+
+// tableCopyMetrics holds the information_schema estimates of the number of
+// rows and of the size of a table.
+type tableCopyMetrics struct {
+	rows  int64
+	bytes int64
+}
+
+// tableCopyMetricsQuery lists the tables of a database to read the estimates
+// of, or all of its tables if tables is nil. The tablet is either set
+// directly, for a target, or is the primary of the keyspace and shard, for a
+// source.
+type tableCopyMetricsQuery struct {
+	alias           *topodatapb.TabletAlias
+	keyspace, shard string
+	dbName          string
+	tables          sets.Set[string]
+
+	// filters are the filters of the streams that copy from a source.
+	filters []*binlogdatapb.Filter
+	// metrics are the estimates read by the query, by table name.
+	metrics map[string]*tableCopyMetrics
+}
+
+// getWorkflowCopyProgress estimates how far along the copy phase of the given
+// workflow is. The rows copied are the rows_copied counters of the streams,
+// and the total is the information_schema estimate of the number of rows of
+// the tables that the streams copy from their source shards. The ETA is
+// extrapolated from the rate at which the streams have copied rows since their
+// copy phase started.
+//
+// Several target shards may copy from the same source shard, and some of them
+// may be done while others are still copying, so the rows copied and the
+// target estimates also include the streams which finished copying from the
+// source shards of the streams still copying.
+//
+// The progress of each table still being copied compares the estimates of
+// the size of the table on the targets to those of the source table it is
+// copied from, since the streams do not record the rows they copied by table.
+//
+// It returns nil if none of the streams of the workflow is copying. The
+// progress of the tables copied from an external MySQL has no total.
+func (s *Server) getWorkflowCopyProgress(ctx context.Context, workflow *vtctldatapb.Workflow) (*vtctldatapb.Workflow_CopyProgress, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.getWorkflowCopyProgress")
+	defer span.Finish()
+
+	span.Annotate("workflow", workflow.Name)
+
+	var (
+		now           = time.Now()
+		rowsCopied    int64
+		rowsPerSecond float64
+		copyingTables = sets.New[string]()
+		targetQuery   = make(map[string]*tableCopyMetricsQuery)
+		sourceQuery   = make(map[string]*tableCopyMetricsQuery)
+		finished      []*vtctldatapb.Workflow_Stream
+	)
+	for _, shardStream := range workflow.ShardStreams {
+		for _, stream := range shardStream.Streams {
+			if len(stream.CopyStates) == 0 {
+				finished = append(finished, stream)
+				continue
+			}
+			rowsCopied += stream.RowsCopied
+			if start, ok := copyStartTime(stream); ok {
+				if elapsed := now.Sub(start).Seconds(); elapsed > 0 {
+					rowsPerSecond += float64(stream.RowsCopied) / elapsed
+				}
+			}
+
+			target := topoproto.TabletAliasString(stream.Tablet)
+			tq, ok := targetQuery[target]
+			if !ok {
+				tq = &tableCopyMetricsQuery{alias: stream.Tablet, dbName: stream.DbName, tables: sets.New[string]()}
+				targetQuery[target] = tq
+			}
+			for _, copyState := range stream.CopyStates {
+				copyingTables.Insert(copyState.Table)
+				tq.tables.Insert(copyState.Table)
+			}
+
+			bls := stream.BinlogSource
+			if bls.ExternalMysql != "" {
+				continue
+			}
+			// Several target shards may copy from the same source shard, each
+			// its own key range of it, so the source tables only count once.
+			source := topoproto.KeyspaceShardString(bls.Keyspace, bls.Shard)
+			sq, ok := sourceQuery[source]
+			if !ok {
+				sq = &tableCopyMetricsQuery{keyspace: bls.Keyspace, shard: bls.Shard}
+				sourceQuery[source] = sq
+			}
+			sq.filters = append(sq.filters, bls.Filter)
+		}
+	}
+
+	if copyingTables.Len() == 0 {
+		return nil, nil
+	}
+
+	for _, stream := range finished {
+		bls := stream.BinlogSource
+		if bls.ExternalMysql != "" {
+			continue
+		}
+		if _, ok := sourceQuery[topoproto.KeyspaceShardString(bls.Keyspace, bls.Shard)]; !ok {
+			continue
+		}
+		rowsCopied += stream.RowsCopied
+		target := topoproto.TabletAliasString(stream.Tablet)
+		tq, ok := targetQuery[target]
+		if !ok {
+			tq = &tableCopyMetricsQuery{alias: stream.Tablet, dbName: stream.DbName, tables: sets.New[string]()}
+			targetQuery[target] = tq
+		}
+		for table := range copyingTables {
+			if copiesToTable(bls.Filter, table) {
+				tq.tables.Insert(table)
+			}
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		allErrors concurrency.AllErrorRecorder
+	)
+	getMetrics := func(query *tableCopyMetricsQuery) {
+		defer wg.Done()
+		if err := s.getTableCopyMetrics(ctx, query); err != nil {
+			allErrors.RecordError(err)
+		}
+	}
+	for _, tq := range targetQuery {
+		wg.Add(1)
+		go getMetrics(tq)
+	}
+	for _, sq := range sourceQuery {
+		wg.Add(1)
+		go getMetrics(sq)
+	}
+	wg.Wait()
+	if allErrors.HasErrors() {
+		return nil, allErrors.AggrError(vterrors.Aggregate)
+	}
+
+	copied := make(map[string]*tableCopyMetrics)
+	for _, tq := range targetQuery {
+		for table := range tq.tables {
+			if m, ok := tq.metrics[table]; ok {
+				addTableCopyMetrics(copied, table, m)
+			}
+		}
+	}
+	var rowsTotal int64
+	total := make(map[string]*tableCopyMetrics)
+	for _, sq := range sourceQuery {
+		for sourceTable, m := range sq.metrics {
+			if copiesFromTable(sq.filters, sourceTable) {
+				rowsTotal += m.rows
+			}
+		}
+		for table := range copyingTables {
+			for _, filter := range sq.filters {
+				if !copiesToTable(filter, table) {
+					continue
+				}
+				if m, ok := sq.metrics[copySourceTable(filter, table)]; ok {
+					addTableCopyMetrics(total, table, m)
+				}
+				break
+			}
+		}
+	}
+
+	progress := &vtctldatapb.Workflow_CopyProgress{
+		Tables:         make(map[string]*vtctldatapb.Workflow_TableCopyProgress, copyingTables.Len()),
+		RowsCopied:     rowsCopied,
+		RowsTotal:      rowsTotal,
+		RowsPercentage: copyPercentage(rowsCopied, rowsTotal),
+		RowsPerSecond:  rowsPerSecond,
+		Eta:            copyETA(rowsCopied, rowsTotal, rowsPerSecond),
+	}
+	for _, table := range sets.List(copyingTables) {
+		c, t := &tableCopyMetrics{}, &tableCopyMetrics{}
+		if m, ok := copied[table]; ok {
+			c = m
+		}
+		if m, ok := total[table]; ok {
+			t = m
+		}
+		progress.Tables[table] = &vtctldatapb.Workflow_TableCopyProgress{
+			RowsCopied:      c.rows,
+			RowsTotal:       t.rows,
+			RowsPercentage:  copyPercentage(c.rows, t.rows),
+			BytesCopied:     c.bytes,
+			BytesTotal:      t.bytes,
+			BytesPercentage: copyPercentage(c.bytes, t.bytes),
+		}
+	}
+
+	return progress, nil
+}
+
+// getTableCopyMetrics reads the estimates of the tables of the given query
+// into its metrics.
+func (s *Server) getTableCopyMetrics(ctx context.Context, query *tableCopyMetricsQuery) error {
+	if query.alias == nil {
+		si, err := s.ts.GetShard(ctx, query.keyspace, query.shard)
+		if err != nil {
+			return err
+		}
+		if si.PrimaryAlias == nil {
+			return fmt.Errorf("source shard %s/%s has no primary", query.keyspace, query.shard)
+		}
+		query.alias = si.PrimaryAlias
+	}
+	tablet, err := s.ts.GetTablet(ctx, query.alias)
+	if err != nil {
+		return err
+	}
+	dbName := query.dbName
+	if dbName == "" {
+		dbName = tablet.DbName()
+	}
+
+	var (
+		sql     string
+		maxRows uint64
+	)
+	if query.tables == nil {
+		sql = fmt.Sprintf(sqlGetAllTableCopyMetrics, encodeString(dbName))
+		maxRows = maxSourceTables
+	} else {
+		tables := sets.List(query.tables)
+		for i, table := range tables {
+			tables[i] = encodeString(table)
+		}
+		sql = fmt.Sprintf(sqlGetTableCopyMetrics, encodeString(dbName), strings.Join(tables, ","))
+		maxRows = uint64(len(tables))
+	}
+	p3qr, err := s.tmc.ExecuteFetchAsDba(ctx, tablet.Tablet, true, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{
+		Query:   []byte(sql),
+		MaxRows: maxRows,
+	})
+	if err != nil {
+		return err
+	}
+
+	query.metrics = make(map[string]*tableCopyMetrics)
+	for _, row := range sqltypes.Proto3ToResult(p3qr).Rows {
+		rows, err := evalengine.ToInt64(row[1])
+		if err != nil {
+			return err
+		}
+		bytes, err := evalengine.ToInt64(row[2])
+		if err != nil {
+			return err
+		}
+		query.metrics[row[0].ToString()] = &tableCopyMetrics{rows: rows, bytes: bytes}
+	}
+	return nil
+}
+
+// addTableCopyMetrics adds the estimates m to those of the table in metrics.
+func addTableCopyMetrics(metrics map[string]*tableCopyMetrics, table string, m *tableCopyMetrics) {
+	sum, ok := metrics[table]
+	if !ok {
+		sum = &tableCopyMetrics{}
+		metrics[table] = sum
+	}
+	sum.rows += m.rows
+	sum.bytes += m.bytes
+}
+
+// copiesFromTable returns true if any of the filters copies from the given
+// source table.
+func copiesFromTable(filters []*binlogdatapb.Filter, sourceTable string) bool {
+	if schema.IsInternalOperationTableName(sourceTable) {
+		return false
+	}
+	for _, filter := range filters {
+		for _, rule := range filter.GetRules() {
+			if strings.HasPrefix(rule.Match, "/") {
+				if matchesTableRegexp(rule.Match, sourceTable) {
+					return true
+				}
+				continue
+			}
+			if ruleSourceTable(rule) == sourceTable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copiesToTable returns true if a rule of the filter copies to the given
+// target table.
+func copiesToTable(filter *binlogdatapb.Filter, table string) bool {
+	for _, rule := range filter.GetRules() {
+		if rule.Match == table || (strings.HasPrefix(rule.Match, "/") && matchesTableRegexp(rule.Match, table)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesTableRegexp(match, table string) bool {
+	ok, err := regexp.MatchString(strings.Trim(match, "/"), table)
+	return err == nil && ok
+}
+
+// copyStartTime returns when the copy phase of the stream started, according
+// to its logs.
+func copyStartTime(stream *vtctldatapb.Workflow_Stream) (time.Time, bool) {
+	logs := make([]*vtctldatapb.Workflow_Stream_Log, 0, len(stream.Logs))
+	for _, l := range stream.Logs {
+		if l.Type == copyStartLogType && l.CreatedAt != nil {
+			logs = append(logs, l)
+		}
+	}
+	if len(logs) == 0 {
+		return time.Time{}, false
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].CreatedAt.Seconds < logs[j].CreatedAt.Seconds
+	})
+	return protoutil.TimeFromProto(logs[0].CreatedAt), true
+}
+
+// copySourceTable returns the name of the source table that the filter of a
+// stream copies the given table from. Tables matched by a rule that is not a
+// select from a single table, such as the key range rules of Reshard, are
+// copied from the table of the same name.
+func copySourceTable(filter *binlogdatapb.Filter, table string) string {
+	for _, rule := range filter.GetRules() {
+		if rule.Match == table {
+			return ruleSourceTable(rule)
+		}
+	}
+	return table
+}
+
+// ruleSourceTable returns the name of the source table that the rule copies
+// from, which is the table it matches unless its filter is a select from
+// another table.
+func ruleSourceTable(rule *binlogdatapb.Rule) string {
+	stmt, err := sqlparser.Parse(rule.Filter)
+	if err != nil {
+		return rule.Match
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return rule.Match
+	}
+	tableExpr, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return rule.Match
+	}
+	tableName, err := tableExpr.TableName()
+	if err != nil {
+		return rule.Match
+	}
+	return tableName.Name.String()
+}
+
+// copyPercentage returns copied as a percentage of total, or 0 if the total
+// is not known.
+func copyPercentage(copied, total int64) float32 {
+	if total <= 0 {
+		return 0
+	}
+	return float32(100 * float64(copied) / float64(total))
+}
+
+// copyETA estimates the time left to copy the remaining rows at the given
+// rate. It returns nil if either the total or the rate is not known.
+func copyETA(copied, total int64, rowsPerSecond float64) *vttime.Duration {
+	if total <= 0 || rowsPerSecond <= 0 {
+		return nil
+	}
+	remaining := total - copied
+	if remaining < 0 {
+		remaining = 0
+	}
+	return protoutil.DurationToProto(time.Duration(float64(remaining) / rowsPerSecond * float64(time.Second)))
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver/testutil"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+func TestGetWorkflowCopyProgress(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("zone1")
+	testutil.AddTablets(ctx, t, ts, &testutil.AddTabletOptions{AlsoSetShardPrimary: true},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 100},
+			Keyspace: "commerce",
+			Shard:    "0",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 200},
+			Keyspace: "customer",
+			Shard:    "-80",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 300},
+			Keyspace: "customer",
+			Shard:    "80-",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+	)
+
+	metrics := func(rows ...string) *querypb.QueryResult {
+		return sqltypes.ResultToProto3(sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("table_name|table_rows|data_length", "varchar|int64|int64"),
+			rows...,
+		))
+	}
+	tmc := &fakeTMC{
+		dbaQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+			"zone1-0000000100": {
+				"select table_name, table_rows, data_length from information_schema.tables where table_schema = 'vt_commerce' and table_name in ('t1','t2_src')": metrics("t1|600|6000", "t2_src|200|2000"),
+			},
+			"zone1-0000000200": {
+				"select table_name, table_rows, data_length from information_schema.tables where table_schema = 'vt_customer' and table_name in ('t1','t2')": metrics("t1|200|2000", "t2|50|500"),
+			},
+			"zone1-0000000300": {
+				"select table_name, table_rows, data_length from information_schema.tables where table_schema = 'vt_customer' and table_name in ('t1')": metrics("t1|100|1000"),
+			},
+		},
+	}
+	ws := NewServer(ts, tmc)
+
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "commerce",
+		Shard:    "0",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{
+				{Match: "t1", Filter: "select * from t1"},
+				{Match: "t2", Filter: "select id, val from t2_src"},
+			},
+		},
+	}
+	copyStarted := []*vtctldatapb.Workflow_Stream_Log{{
+		Type:      "Stream Created",
+		CreatedAt: protoutil.TimeToProto(time.Now().Add(-200 * time.Second)),
+	}, {
+		Type:      copyStartLogType,
+		CreatedAt: protoutil.TimeToProto(time.Now().Add(-100 * time.Second)),
+	}}
+	workflow := &vtctldatapb.Workflow{
+		Name: "commerce2customer",
+		ShardStreams: map[string]*vtctldatapb.Workflow_ShardStream{
+			"-80/zone1-0000000200": {
+				Streams: []*vtctldatapb.Workflow_Stream{{
+					Id:           1,
+					Tablet:       &topodatapb.TabletAlias{Cell: "zone1", Uid: 200},
+					BinlogSource: bls,
+					DbName:       "vt_customer",
+					RowsCopied:   300,
+					CopyStates:   []*vtctldatapb.Workflow_Stream_CopyState{{Table: "t1"}, {Table: "t2"}},
+					Logs:         copyStarted,
+				}},
+			},
+			"80-/zone1-0000000300": {
+				Streams: []*vtctldatapb.Workflow_Stream{{
+					Id:           1,
+					Tablet:       &topodatapb.TabletAlias{Cell: "zone1", Uid: 300},
+					BinlogSource: bls,
+					DbName:       "vt_customer",
+					RowsCopied:   100,
+					CopyStates:   []*vtctldatapb.Workflow_Stream_CopyState{{Table: "t1"}},
+					Logs:         copyStarted,
+				}},
+			},
+		},
+	}
+
+	progress, err := ws.getWorkflowCopyProgress(ctx, workflow)
+	require.NoError(t, err)
+	require.NotNil(t, progress)
+
+	utils.MustMatch(t, map[string]*vtctldatapb.Workflow_TableCopyProgress{
+		"t1": {RowsCopied: 300, RowsTotal: 600, RowsPercentage: 50, BytesCopied: 3000, BytesTotal: 6000, BytesPercentage: 50},
+		"t2": {RowsCopied: 50, RowsTotal: 200, RowsPercentage: 25, BytesCopied: 500, BytesTotal: 2000, BytesPercentage: 25},
+	}, progress.Tables, "unexpected table progress")
+	// The rows copied are those the streams counted, out of the rows of the
+	// source tables the workflow copies, which leaves t3 out.
+	assert.EqualValues(t, 400, progress.RowsCopied)
+	assert.EqualValues(t, 800, progress.RowsTotal)
+	assert.InDelta(t, 50, progress.RowsPercentage, 0.01)
+	// Both streams copied for 100 seconds, at 3 and 1 rows per second.
+	assert.InDelta(t, 4, progress.RowsPerSecond, 0.1)
+	eta, ok, err := protoutil.DurationFromProto(progress.Eta)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.InDelta(t, 100, eta.Seconds(), 3)
+
+	// Once the stream of 80- is done copying, its rows and tables still count
+	// towards the progress, as the stream of -80 copies from the same source
+	// shard.
+	tmc.dbaQueriesByTablet["zone1-0000000300"]["select table_name, table_rows, data_length from information_schema.tables where table_schema = 'vt_customer' and table_name in ('t1','t2')"] = metrics("t1|100|1000", "t2|150|1500")
+	stream := workflow.ShardStreams["80-/zone1-0000000300"].Streams[0]
+	stream.CopyStates = nil
+	stream.RowsCopied = 400
+	progress, err = ws.getWorkflowCopyProgress(ctx, workflow)
+	require.NoError(t, err)
+	require.NotNil(t, progress)
+
+	utils.MustMatch(t, map[string]*vtctldatapb.Workflow_TableCopyProgress{
+		"t1": {RowsCopied: 300, RowsTotal: 600, RowsPercentage: 50, BytesCopied: 3000, BytesTotal: 6000, BytesPercentage: 50},
+		"t2": {RowsCopied: 200, RowsTotal: 200, RowsPercentage: 100, BytesCopied: 2000, BytesTotal: 2000, BytesPercentage: 100},
+	}, progress.Tables, "unexpected table progress")
+	assert.EqualValues(t, 700, progress.RowsCopied)
+	assert.EqualValues(t, 800, progress.RowsTotal)
+	assert.InDelta(t, 87.5, progress.RowsPercentage, 0.01)
+	// Only the stream of -80 is still copying, at 3 rows per second.
+	assert.InDelta(t, 3, progress.RowsPerSecond, 0.1)
+	eta, ok, err = protoutil.DurationFromProto(progress.Eta)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.InDelta(t, 33, eta.Seconds(), 2)
+
+	// Once the copy phase is over, there is no progress to report.
+	for _, shardStream := range workflow.ShardStreams {
+		for _, stream := range shardStream.Streams {
+			stream.CopyStates = nil
+		}
+	}
+	progress, err = ws.getWorkflowCopyProgress(ctx, workflow)
+	require.NoError(t, err)
+	assert.Nil(t, progress)
+}
+
+func TestCopySourceTable(t *testing.T) {
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{
+			{Match: "t1", Filter: "select * from t1"},
+			{Match: "sales", Filter: "select sku, count(*) as orders from corder group by sku"},
+			{Match: "/.*", Filter: "-80"},
+		},
+	}
+	assert.Equal(t, "t1", copySourceTable(filter, "t1"))
+	assert.Equal(t, "corder", copySourceTable(filter, "sales"))
+	assert.Equal(t, "t3", copySourceTable(filter, "t3"))
+}
+
+func TestCopiesFromTable(t *testing.T) {
+	moveTables := []*binlogdatapb.Filter{{
+		Rules: []*binlogdatapb.Rule{
+			{Match: "t1", Filter: "select * from t1"},
+			{Match: "sales", Filter: "select sku, count(*) as orders from corder group by sku"},
+		},
+	}}
+	assert.True(t, copiesFromTable(moveTables, "t1"))
+	assert.True(t, copiesFromTable(moveTables, "corder"))
+	assert.False(t, copiesFromTable(moveTables, "sales"))
+
+	reshard := []*binlogdatapb.Filter{{
+		Rules: []*binlogdatapb.Rule{{Match: "/.*", Filter: "-80"}},
+	}}
+	assert.True(t, copiesFromTable(reshard, "t1"))
+	assert.False(t, copiesFromTable(reshard, "_vt_HOLD_6ace8bcef73211ea87e9f875a4d24e90_20200915120410"))
+	assert.True(t, copiesToTable(reshard[0], "t1"))
+	assert.False(t, copiesToTable(moveTables[0], "corder"))
+}
+
+func TestCopyETA(t *testing.T) {
+	assert.Nil(t, copyETA(10, 0, 1), "unknown total")
+	assert.Nil(t, copyETA(10, 100, 0), "unknown rate")
+	assert.Equal(t, protoutil.DurationToProto(45*time.Second), copyETA(10, 100, 2))
+	assert.Equal(t, protoutil.DurationToProto(0), copyETA(120, 100, 2), "estimates past the total")
+}
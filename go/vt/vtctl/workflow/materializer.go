@@ -0,0 +1,1017 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/concurrency"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/mysqlctl/tmutils"
+	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/topotools"
+	"vitess.io/vitess/go/vt/vtctl/schematools"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/vstreamer"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+const (
+	createDDLAsCopy                = "copy"
+	createDDLAsCopyDropConstraint  = "copy:drop_constraint"
+	createDDLAsCopyDropForeignKeys = "copy:drop_foreign_keys"
+)
+
+// materializer creates the streams of a MoveTables or Materialize workflow
+// on the primaries of the target shards.
+type materializer struct {
+	ts            *topo.Server
+	sourceTs      *topo.Server
+	tmc           tmclient.TabletManagerClient
+	ms            *vtctldatapb.MaterializeSettings
+	targetVSchema *vindexes.KeyspaceSchema
+	sourceShards  []*topo.ShardInfo
+	targetShards  []*topo.ShardInfo
+	isPartial     bool
+}
+
+// MaterializeCreate is part of the vtctlservicepb.VtctldServer interface.
+// It creates the target tables that do not exist yet from their CreateDdl,
+// creates the streams of the workflow on the target primaries and starts
+// them.
+func (s *Server) MaterializeCreate(ctx context.Context, req *vtctldatapb.MaterializeCreateRequest) (*vtctldatapb.MaterializeCreateResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.MaterializeCreate")
+	defer span.Finish()
+
+	ms := req.Settings
+	if ms == nil || ms.Workflow == "" || ms.SourceKeyspace == "" || ms.TargetKeyspace == "" {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "workflow, source keyspace and target keyspace are required")
+	}
+	if len(ms.TableSettings) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "no tables to materialize")
+	}
+
+	span.Annotate("workflow", ms.Workflow)
+	span.Annotate("source_keyspace", ms.SourceKeyspace)
+	span.Annotate("target_keyspace", ms.TargetKeyspace)
+
+	ms.MaterializationIntent = vtctldatapb.MaterializationIntent_CUSTOM
+	sourceTs, err := s.sourceTopo(ctx, ms.ExternalCluster)
+	if err != nil {
+		return nil, err
+	}
+	mz, err := s.prepareMaterializerStreams(ctx, ms, sourceTs)
+	if err != nil {
+		return nil, err
+	}
+	if err := mz.startStreams(ctx); err != nil {
+		return nil, err
+	}
+
+	return &vtctldatapb.MaterializeCreateResponse{
+		Summary: fmt.Sprintf("Successfully created the %s workflow, materializing %d tables from the %s keyspace on %d shards of the %s keyspace",
+			ms.Workflow, len(ms.TableSettings), ms.SourceKeyspace, len(mz.targetShards), ms.TargetKeyspace),
+	}, nil
+}
+
+// MoveTablesCreate is part of the vtctlservicepb.VtctldServer interface.
+//
+// It adds the tables to the vschema of the target keyspace if it is not
+// sharded, sets up the routing rules that keep sending their traffic to the
+// source keyspace, copies their schema to the target shards, and creates the
+// streams of the workflow on the target primaries.
+func (s *Server) MoveTablesCreate(ctx context.Context, req *vtctldatapb.MoveTablesCreateRequest) (*vtctldatapb.MoveTablesCreateResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.MoveTablesCreate")
+	defer span.Finish()
+
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("source_keyspace", req.SourceKeyspace)
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("all_tables", req.AllTables)
+	span.Annotate("external_cluster_name", req.ExternalClusterName)
+
+	if req.Workflow == "" || req.SourceKeyspace == "" || req.TargetKeyspace == "" {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "workflow, source keyspace and target keyspace are required")
+	}
+	if req.AllTables == (len(req.IncludeTables) > 0) {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "exactly one of all tables or include tables must be specified")
+	}
+
+	sourceTs, err := s.sourceTopo(ctx, req.ExternalClusterName)
+	if err != nil {
+		return nil, err
+	}
+	vschema, err := s.ts.GetVSchema(ctx, req.TargetKeyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetVSchema(%s)", req.TargetKeyspace)
+	}
+
+	ksTables, err := s.getKeyspaceTables(ctx, sourceTs, req.SourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	tables := req.IncludeTables
+	if req.AllTables {
+		tables = ksTables
+	} else if err := validateSourceTablesExist(req.SourceKeyspace, ksTables, tables); err != nil {
+		return nil, err
+	}
+	if err := validateSourceTablesExist(req.SourceKeyspace, ksTables, req.ExcludeTables); err != nil {
+		return nil, err
+	}
+	var included []string
+	for _, table := range tables {
+		if shouldInclude(table, req.ExcludeTables) {
+			included = append(included, table)
+		}
+	}
+	tables = included
+	if len(tables) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "no tables to move")
+	}
+	log.Infof("Found tables to move: %s", strings.Join(tables, ","))
+
+	if !vschema.Sharded {
+		if err := s.addTablesToVSchema(ctx, req.SourceKeyspace, vschema, tables, req.ExternalClusterName == ""); err != nil {
+			return nil, err
+		}
+	}
+	if req.ExternalClusterName == "" {
+		// Save the routing rules before the vschema. If the vschema was saved
+		// first and saving the routing rules failed, the tables would be
+		// ambiguous.
+		rules, err := topotools.GetRoutingRules(ctx, s.ts)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			toSource := []string{req.SourceKeyspace + "." + table}
+			for _, suffix := range []string{"", "@replica", "@rdonly"} {
+				rules[table+suffix] = toSource
+				rules[req.TargetKeyspace+"."+table+suffix] = toSource
+				rules[req.SourceKeyspace+"."+table+suffix] = toSource
+			}
+			// The source keyspace itself routes to the source keyspace without
+			// a rule.
+			delete(rules, req.SourceKeyspace+"."+table)
+		}
+		if err := topotools.SaveRoutingRules(ctx, s.ts, rules); err != nil {
+			return nil, err
+		}
+		if err := s.ts.SaveVSchema(ctx, req.TargetKeyspace, vschema); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.ts.RebuildSrvVSchema(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:              req.Workflow,
+		MaterializationIntent: vtctldatapb.MaterializationIntent_MOVETABLES,
+		SourceKeyspace:        req.SourceKeyspace,
+		TargetKeyspace:        req.TargetKeyspace,
+		Cell:                  strings.Join(req.Cells, ","),
+		TabletTypes:           strings.Join(topoproto.MakeStringTypeList(req.TabletTypes), ","),
+		StopAfterCopy:         req.StopAfterCopy,
+		ExternalCluster:       req.ExternalClusterName,
+		SourceShards:          req.SourceShards,
+		OnDdl:                 req.OnDdl.String(),
+		DeferSecondaryKeys:    req.DeferSecondaryKeys,
+	}
+	if req.SourceTimeZone != "" {
+		ms.SourceTimeZone = req.SourceTimeZone
+		ms.TargetTimeZone = "UTC"
+	}
+	createDDLMode := createDDLAsCopy
+	if req.DropForeignKeys {
+		createDDLMode = createDDLAsCopyDropForeignKeys
+	}
+	for _, table := range tables {
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("select * from %v", sqlparser.NewIdentifierCS(table))
+		ms.TableSettings = append(ms.TableSettings, &vtctldatapb.TableMaterializeSettings{
+			TargetTable:      table,
+			SourceExpression: buf.String(),
+			CreateDdl:        createDDLMode,
+		})
+	}
+
+	mz, err := s.prepareMaterializerStreams(ctx, ms, sourceTs)
+	if err != nil {
+		return nil, err
+	}
+	if req.SourceTimeZone != "" {
+		if err := mz.checkTZConversion(ctx, req.SourceTimeZone); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.ExternalClusterName == "" {
+		// A journal left by a previous workflow with the same streams would
+		// make the target streams stop as soon as they start.
+		migrationID, err := mz.migrationID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tablets, err := mz.previousJournals(ctx, s, migrationID)
+		if err != nil {
+			return nil, err
+		}
+		if len(tablets) > 0 {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "found an entry from a previous run for migration id %d in _vt.resharding_journal of tablets %s, please review and delete it before starting the %s workflow",
+				migrationID, strings.Join(tablets, ","), req.Workflow)
+		}
+	}
+
+	started := ""
+	if req.AutoStart {
+		if err := mz.startStreams(ctx); err != nil {
+			return nil, err
+		}
+		started = " and started"
+	}
+
+	return &vtctldatapb.MoveTablesCreateResponse{
+		Summary: fmt.Sprintf("Successfully created%s the %s workflow, moving %d tables from the %s keyspace to %d shards of the %s keyspace",
+			started, req.Workflow, len(tables), req.SourceKeyspace, len(mz.targetShards), req.TargetKeyspace),
+	}, nil
+}
+
+// sourceTopo returns the topo server of the source keyspace of a workflow:
+// the one of the mounted external cluster if there is one, or the local one.
+func (s *Server) sourceTopo(ctx context.Context, externalCluster string) (*topo.Server, error) {
+	if externalCluster == "" {
+		return s.ts, nil
+	}
+	sourceTs, err := s.ts.OpenExternalVitessClusterServer(ctx, externalCluster)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "failed to open the topo of the %s external cluster", externalCluster)
+	}
+	return sourceTs, nil
+}
+
+// addTablesToVSchema adds tables to an unsharded vschema. If copyAttributes
+// is set, the sequences of the tables are copied from the vschema of the
+// source keyspace.
+func (s *Server) addTablesToVSchema(ctx context.Context, sourceKeyspace string, targetVSchema *vschemapb.Keyspace, tables []string, copyAttributes bool) error {
+	if targetVSchema.Tables == nil {
+		targetVSchema.Tables = make(map[string]*vschemapb.Table)
+	}
+	for _, table := range tables {
+		targetVSchema.Tables[table] = &vschemapb.Table{}
+	}
+	if !copyAttributes {
+		return nil
+	}
+	srcVSchema, err := s.ts.GetVSchema(ctx, sourceKeyspace)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if srcTable, ok := srcVSchema.Tables[table]; ok {
+			targetVSchema.Tables[table].AutoIncrement = srcTable.AutoIncrement
+		}
+	}
+	return nil
+}
+
+// getKeyspaceTables returns the names of the tables of a keyspace, as found
+// on the primary of its first serving shard.
+func (s *Server) getKeyspaceTables(ctx context.Context, ts *topo.Server, keyspace string) ([]string, error) {
+	shards, err := ts.GetServingShards(ctx, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if len(shards) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "keyspace %s has no shards", keyspace)
+	}
+	primary := shards[0].PrimaryAlias
+	if primary == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", keyspace, shards[0].ShardName())
+	}
+	ti, err := ts.GetTablet(ctx, primary)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := s.tmc.GetSchema(ctx, ti.Tablet, &tabletmanagerdatapb.GetSchemaRequest{Tables: []string{"/.*/"}})
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(sd.TableDefinitions))
+	for _, td := range sd.TableDefinitions {
+		tables = append(tables, td.Name)
+	}
+	return tables, nil
+}
+
+// validateNewWorkflow checks that the workflow does not exist yet in the
+// keyspace, and that no frozen workflow was left behind on its primaries.
+func (s *Server) validateNewWorkflow(ctx context.Context, keyspace, workflow string) error {
+	shards, err := s.ts.FindAllShardsInKeyspace(ctx, keyspace)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	allErrors := &concurrency.AllErrorRecorder{}
+	for _, si := range shards {
+		if si.PrimaryAlias == nil {
+			allErrors.RecordError(vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", keyspace, si.ShardName()))
+			continue
+		}
+		wg.Add(1)
+		go func(si *topo.ShardInfo) {
+			defer wg.Done()
+
+			primary, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+			if err != nil {
+				allErrors.RecordError(err)
+				return
+			}
+			validations := []struct {
+				query string
+				msg   string
+			}{{
+				fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and workflow=%s", encodeString(primary.DbName()), encodeString(workflow)),
+				fmt.Sprintf("workflow %s already exists in keyspace %s on tablet %s", workflow, keyspace, primary.AliasString()),
+			}, {
+				fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s and message='%s' and workflow_sub_type != %d", encodeString(primary.DbName()), Frozen, binlogdatapb.VReplicationWorkflowSubType_Partial),
+				fmt.Sprintf("found a previous frozen workflow on tablet %s, please review and delete it before creating a new workflow", primary.AliasString()),
+			}}
+			for _, validation := range validations {
+				p3qr, err := s.tmc.VReplicationExec(ctx, primary.Tablet, validation.query)
+				if err != nil {
+					allErrors.RecordError(err)
+					return
+				}
+				if len(p3qr.Rows) != 0 {
+					allErrors.RecordError(vterrors.New(vtrpcpb.Code_ALREADY_EXISTS, validation.msg))
+					return
+				}
+			}
+		}(si)
+	}
+	wg.Wait()
+	return allErrors.AggrError(vterrors.Aggregate)
+}
+
+func validateSourceTablesExist(keyspace string, ksTables, tables []string) error {
+	var missing []string
+	for _, table := range tables {
+		if schema.IsInternalOperationTableName(table) {
+			continue
+		}
+		found := false
+		for _, ksTable := range ksTables {
+			if table == ksTable {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "table(s) not found in source keyspace %s: %s", keyspace, strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// shouldInclude filters out the excluded tables, and the internal tables
+// that automation may have listed explicitly.
+func shouldInclude(table string, excludes []string) bool {
+	if schema.IsInternalOperationTableName(table) {
+		return false
+	}
+	for _, t := range excludes {
+		if t == table {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) prepareMaterializerStreams(ctx context.Context, ms *vtctldatapb.MaterializeSettings, sourceTs *topo.Server) (*materializer, error) {
+	if err := s.validateNewWorkflow(ctx, ms.TargetKeyspace, ms.Workflow); err != nil {
+		return nil, err
+	}
+	mz, err := s.buildMaterializer(ctx, ms, sourceTs)
+	if err != nil {
+		return nil, err
+	}
+	if mz.isPartial {
+		if err := mz.createDefaultShardRoutingRules(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := mz.deploySchema(ctx); err != nil {
+		return nil, err
+	}
+	insertMap := make(map[string]string, len(mz.targetShards))
+	for _, targetShard := range mz.targetShards {
+		inserts, err := mz.generateInserts(targetShard)
+		if err != nil {
+			return nil, err
+		}
+		insertMap[targetShard.ShardName()] = inserts
+	}
+	if err := mz.createStreams(ctx, insertMap); err != nil {
+		return nil, err
+	}
+	return mz, nil
+}
+
+func (s *Server) buildMaterializer(ctx context.Context, ms *vtctldatapb.MaterializeSettings, sourceTs *topo.Server) (*materializer, error) {
+	vschema, err := s.ts.GetVSchema(ctx, ms.TargetKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	targetVSchema, err := vindexes.BuildKeyspaceSchema(vschema, ms.TargetKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	if targetVSchema.Keyspace.Sharded {
+		for _, ts := range ms.TableSettings {
+			if targetVSchema.Tables[ts.TargetTable] == nil {
+				return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "table %s not found in vschema for keyspace %s", ts.TargetTable, ms.TargetKeyspace)
+			}
+		}
+	}
+	for _, ts := range ms.TableSettings {
+		if err := ValidateSourceExpression(ts.SourceExpression); err != nil {
+			return nil, err
+		}
+	}
+
+	sourceShards, err := sourceTs.GetServingShards(ctx, ms.SourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	targetShards, err := s.ts.GetServingShards(ctx, ms.TargetKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	isPartial := len(ms.SourceShards) > 0
+	if isPartial {
+		sourceShards = filterShards(sourceShards, ms.SourceShards)
+		targetShards = filterShards(targetShards, ms.SourceShards)
+	}
+	if len(sourceShards) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "no source shards specified for workflow %s", ms.Workflow)
+	}
+	if len(targetShards) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "no target shards specified for workflow %s", ms.Workflow)
+	}
+	if len(sourceShards) > 1 {
+		if err := ValidateSourceLocality(ctx, sourceTs, ms); err != nil {
+			return nil, err
+		}
+	}
+
+	return &materializer{
+		ts:            s.ts,
+		sourceTs:      sourceTs,
+		tmc:           s.tmc,
+		ms:            ms,
+		targetVSchema: targetVSchema,
+		sourceShards:  sourceShards,
+		targetShards:  targetShards,
+		isPartial:     isPartial,
+	}, nil
+}
+
+func filterShards(shards []*topo.ShardInfo, names []string) []*topo.ShardInfo {
+	var filtered []*topo.ShardInfo
+	for _, si := range shards {
+		for _, name := range names {
+			if si.ShardName() == name {
+				filtered = append(filtered, si)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ValidateSourceExpression checks that the where clause of a source
+// expression can be evaluated by the source vstreamers, so that a bad
+// filter fails the creation of the workflow instead of its streams.
+//
+// It is exported for the Materialize workflows of package wrangler.
+func ValidateSourceExpression(sourceExpression string) error {
+	if sourceExpression == "" {
+		return nil
+	}
+	stmt, err := sqlparser.Parse(sourceExpression)
+	if err != nil {
+		return err
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "unrecognized statement: %s", sourceExpression)
+	}
+	if len(sel.From) == 1 {
+		if _, ok := sel.From[0].(*sqlparser.JoinTableExpr); ok {
+			return vreplication.ValidateJoin(sourceExpression)
+		}
+	}
+	return vstreamer.ValidateWhere(sel.Where)
+}
+
+// ValidateSourceLocality checks that the source expressions of a workflow
+// reading more than one source shard only recompute rows of a single shard.
+//
+// It is exported for the Materialize workflows of package wrangler.
+func ValidateSourceLocality(ctx context.Context, sourceTs *topo.Server, ms *vtctldatapb.MaterializeSettings) error {
+	vschema, err := sourceTs.GetVSchema(ctx, ms.SourceKeyspace)
+	if topo.IsErrType(err, topo.NoNode) {
+		vschema = &vschemapb.Keyspace{}
+	} else if err != nil {
+		return err
+	}
+	sourceVSchema, err := vindexes.BuildKeyspaceSchema(vschema, ms.SourceKeyspace)
+	if err != nil {
+		return err
+	}
+	for _, ts := range ms.TableSettings {
+		if ts.SourceExpression == "" {
+			continue
+		}
+		if err := vreplication.ValidateFilterLocality(ts.SourceExpression, sourceVSchema); err != nil {
+			return vterrors.Wrapf(err, "invalid source expression for table %s", ts.TargetTable)
+		}
+	}
+	return nil
+}
+
+// createDefaultShardRoutingRules creates a rule routing each shard of a
+// partial MoveTables to the source keyspace, unless there is a rule for the
+// shard already.
+func (mz *materializer) createDefaultShardRoutingRules(ctx context.Context) error {
+	srr, err := topotools.GetShardRoutingRules(ctx, mz.ts)
+	if err != nil {
+		return err
+	}
+	allShards, err := mz.sourceTs.GetServingShards(ctx, mz.ms.SourceKeyspace)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, si := range allShards {
+		fromSource := fmt.Sprintf("%s.%s", mz.ms.SourceKeyspace, si.ShardName())
+		fromTarget := fmt.Sprintf("%s.%s", mz.ms.TargetKeyspace, si.ShardName())
+		if srr[fromSource] == "" && srr[fromTarget] == "" {
+			srr[fromTarget] = mz.ms.SourceKeyspace
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := topotools.SaveShardRoutingRules(ctx, mz.ts, srr); err != nil {
+		return err
+	}
+	return mz.ts.RebuildSrvVSchema(ctx, nil)
+}
+
+func (mz *materializer) getSourceTableDDLs(ctx context.Context) (map[string]string, error) {
+	sourcePrimary := mz.sourceShards[0].PrimaryAlias
+	if sourcePrimary == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "source shard must have a primary for copying schema: %v", mz.sourceShards[0].ShardName())
+	}
+	ti, err := mz.sourceTs.GetTablet(ctx, sourcePrimary)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := mz.tmc.GetSchema(ctx, ti.Tablet, &tabletmanagerdatapb.GetSchemaRequest{Tables: []string{"/.*/"}})
+	if err != nil {
+		return nil, err
+	}
+	ddls := make(map[string]string, len(sd.TableDefinitions))
+	for _, td := range sd.TableDefinitions {
+		ddls[td.Name] = td.Schema
+	}
+	return ddls, nil
+}
+
+// deploySchema creates the target tables that do not exist yet on the
+// target shards.
+func (mz *materializer) deploySchema(ctx context.Context) error {
+	var (
+		sourceDDLs map[string]string
+		mu         sync.Mutex
+	)
+	return mz.forAllTargets(func(target *topo.ShardInfo) error {
+		targetSchema, err := schematools.GetSchema(ctx, mz.ts, mz.tmc, target.PrimaryAlias, &tabletmanagerdatapb.GetSchemaRequest{Tables: []string{"/.*/"}})
+		if err != nil {
+			return err
+		}
+		hasTargetTable := make(map[string]bool, len(targetSchema.TableDefinitions))
+		for _, td := range targetSchema.TableDefinitions {
+			hasTargetTable[td.Name] = true
+		}
+
+		var applyDDLs []string
+		for _, ts := range mz.ms.TableSettings {
+			if hasTargetTable[ts.TargetTable] {
+				continue
+			}
+			if ts.CreateDdl == "" {
+				return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "target table %v does not exist and there is no create ddl defined", ts.TargetTable)
+			}
+
+			createDDL := ts.CreateDdl
+			if createDDL == createDDLAsCopy || createDDL == createDDLAsCopyDropConstraint || createDDL == createDDLAsCopyDropForeignKeys {
+				mu.Lock()
+				if sourceDDLs == nil {
+					// The source schema is only read, once, if a table is
+					// copied: the source keyspace may have no primary.
+					sourceDDLs, err = mz.getSourceTableDDLs(ctx)
+				}
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+				if ts.SourceExpression != "" {
+					sourceTableName, err := sqlparser.TableFromStatement(ts.SourceExpression)
+					if err != nil {
+						return err
+					}
+					if sourceTableName.Name.String() != ts.TargetTable {
+						return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "source and target table names must match for copying schema: %v vs %v", sqlparser.String(sourceTableName), ts.TargetTable)
+					}
+				}
+				ddl, ok := sourceDDLs[ts.TargetTable]
+				if !ok {
+					return vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "source table %v does not exist", ts.TargetTable)
+				}
+				switch createDDL {
+				case createDDLAsCopyDropConstraint:
+					ddl, err = stripTableConstraints(ddl)
+				case createDDLAsCopyDropForeignKeys:
+					ddl, err = stripTableForeignKeys(ddl)
+				}
+				if err != nil {
+					return err
+				}
+				createDDL = ddl
+			}
+			applyDDLs = append(applyDDLs, createDDL)
+		}
+		if len(applyDDLs) == 0 {
+			return nil
+		}
+
+		targetTablet, err := mz.ts.GetTablet(ctx, target.PrimaryAlias)
+		if err != nil {
+			return err
+		}
+		_, err = mz.tmc.ApplySchema(ctx, targetTablet.Tablet, &tmutils.SchemaChange{
+			SQL:              strings.Join(applyDDLs, ";\n"),
+			AllowReplication: true,
+			SQLMode:          vreplication.SQLMode,
+		})
+		return err
+	})
+}
+
+func stripTableForeignKeys(ddl string) (string, error) {
+	ast, err := sqlparser.ParseStrictDDL(ddl)
+	if err != nil {
+		return "", err
+	}
+	stripped := sqlparser.Rewrite(ast, func(cursor *sqlparser.Cursor) bool {
+		if node, ok := cursor.Node().(sqlparser.DDLStatement); ok && node.GetTableSpec() != nil {
+			var constraints []*sqlparser.ConstraintDefinition
+			for _, constraint := range node.GetTableSpec().Constraints {
+				if _, ok := constraint.Details.(*sqlparser.ForeignKeyDefinition); constraint.Details != nil && !ok {
+					constraints = append(constraints, constraint)
+				}
+			}
+			node.GetTableSpec().Constraints = constraints
+		}
+		return true
+	}, nil)
+	return sqlparser.String(stripped), nil
+}
+
+func stripTableConstraints(ddl string) (string, error) {
+	ast, err := sqlparser.ParseStrictDDL(ddl)
+	if err != nil {
+		return "", err
+	}
+	stripped := sqlparser.Rewrite(ast, func(cursor *sqlparser.Cursor) bool {
+		if node, ok := cursor.Node().(sqlparser.DDLStatement); ok && node.GetTableSpec() != nil {
+			node.GetTableSpec().Constraints = nil
+		}
+		return true
+	}, nil)
+	return sqlparser.String(stripped), nil
+}
+
+// generateInserts returns the template of the statement that creates the
+// streams of a target shard, to be filled with its key range and database
+// name.
+func (mz *materializer) generateInserts(targetShard *topo.ShardInfo) (string, error) {
+	ig := vreplication.NewInsertGenerator(binlogplayer.BlpStopped, "{{.dbname}}")
+
+	workflowSubType := binlogdatapb.VReplicationWorkflowSubType_None
+	if mz.isPartial {
+		workflowSubType = binlogdatapb.VReplicationWorkflowSubType_Partial
+	}
+	var workflowType binlogdatapb.VReplicationWorkflowType
+	switch mz.ms.MaterializationIntent {
+	case vtctldatapb.MaterializationIntent_CUSTOM:
+		workflowType = binlogdatapb.VReplicationWorkflowType_Materialize
+	case vtctldatapb.MaterializationIntent_MOVETABLES:
+		workflowType = binlogdatapb.VReplicationWorkflowType_MoveTables
+	case vtctldatapb.MaterializationIntent_CREATELOOKUPINDEX:
+		workflowType = binlogdatapb.VReplicationWorkflowType_CreateLookupIndex
+	}
+
+	for _, sourceShard := range mz.sourceShards {
+		// A MoveTables does not need streams from the sources that hold no
+		// data for the target shard. This does not hold for Materialize, as
+		// the target tables may be sharded by different columns.
+		if mz.ms.MaterializationIntent == vtctldatapb.MaterializationIntent_MOVETABLES &&
+			!key.KeyRangeIntersect(sourceShard.KeyRange, targetShard.KeyRange) {
+			continue
+		}
+		bls := &binlogdatapb.BinlogSource{
+			Keyspace:        mz.ms.SourceKeyspace,
+			Shard:           sourceShard.ShardName(),
+			Filter:          &binlogdatapb.Filter{},
+			StopAfterCopy:   mz.ms.StopAfterCopy,
+			ExternalCluster: mz.ms.ExternalCluster,
+			SourceTimeZone:  mz.ms.SourceTimeZone,
+			TargetTimeZone:  mz.ms.TargetTimeZone,
+			OnDdl:           binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[mz.ms.OnDdl]),
+		}
+		for _, ts := range mz.ms.TableSettings {
+			rule := &binlogdatapb.Rule{Match: ts.TargetTable}
+			if ts.SourceExpression == "" {
+				bls.Filter.Rules = append(bls.Filter.Rules, rule)
+				continue
+			}
+			stmt, err := sqlparser.Parse(ts.SourceExpression)
+			if err != nil {
+				return "", err
+			}
+			sel, ok := stmt.(*sqlparser.Select)
+			if !ok {
+				return "", vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "unrecognized statement: %s", ts.SourceExpression)
+			}
+			rule.Filter = ts.SourceExpression
+			if mz.targetVSchema.Keyspace.Sharded && mz.targetVSchema.Tables[ts.TargetTable].Type != vindexes.TypeReference {
+				inKeyRange, err := mz.inKeyRangeExpr(ts.TargetTable, sel)
+				if err != nil {
+					return "", err
+				}
+				if sel.Where != nil {
+					sel.Where = &sqlparser.Where{
+						Type: sqlparser.WhereClause,
+						Expr: &sqlparser.AndExpr{Left: inKeyRange, Right: sel.Where.Expr},
+					}
+				} else {
+					sel.Where = &sqlparser.Where{Type: sqlparser.WhereClause, Expr: inKeyRange}
+				}
+				rule.Filter = sqlparser.String(sel)
+			}
+			bls.Filter.Rules = append(bls.Filter.Rules, rule)
+		}
+		ig.AddRow(mz.ms.Workflow, bls, "", mz.ms.Cell, mz.ms.TabletTypes, workflowType, workflowSubType, mz.ms.DeferSecondaryKeys)
+	}
+	return ig.String(), nil
+}
+
+// inKeyRangeExpr returns the in_keyrange() expression that keeps the rows
+// of a target table that belong to the target shard, using its best vindex.
+func (mz *materializer) inKeyRangeExpr(table string, sel *sqlparser.Select) (sqlparser.Expr, error) {
+	cv, err := vindexes.FindBestColVindex(mz.targetVSchema.Tables[table])
+	if err != nil {
+		return nil, err
+	}
+	exprs := make(sqlparser.SelectExprs, 0, len(cv.Columns)+2)
+	for _, col := range cv.Columns {
+		colName, err := matchColInSelect(col, sel)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, &sqlparser.AliasedExpr{Expr: colName})
+	}
+	exprs = append(exprs, &sqlparser.AliasedExpr{Expr: sqlparser.NewStrLiteral(fmt.Sprintf("%s.%s", mz.ms.TargetKeyspace, cv.Name))})
+	exprs = append(exprs, &sqlparser.AliasedExpr{Expr: sqlparser.NewStrLiteral("{{.keyrange}}")})
+	return &sqlparser.FuncExpr{Name: sqlparser.NewIdentifierCI("in_keyrange"), Exprs: exprs}, nil
+}
+
+func matchColInSelect(col sqlparser.IdentifierCI, sel *sqlparser.Select) (*sqlparser.ColName, error) {
+	for _, selExpr := range sel.SelectExprs {
+		switch selExpr := selExpr.(type) {
+		case *sqlparser.StarExpr:
+			return &sqlparser.ColName{Name: col}, nil
+		case *sqlparser.AliasedExpr:
+			match := selExpr.As
+			if match.IsEmpty() {
+				colExpr, ok := selExpr.Expr.(*sqlparser.ColName)
+				if !ok {
+					// Cannot match against a complex expression.
+					continue
+				}
+				match = colExpr.Name
+			}
+			if match.Equal(col) {
+				colExpr, ok := selExpr.Expr.(*sqlparser.ColName)
+				if !ok {
+					return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "vindex column cannot be a complex expression: %v", sqlparser.String(selExpr))
+				}
+				return colExpr, nil
+			}
+		default:
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "unsupported select expression: %v", sqlparser.String(selExpr))
+		}
+	}
+	return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "could not find vindex column %v", sqlparser.String(col))
+}
+
+func (mz *materializer) createStreams(ctx context.Context, insertsMap map[string]string) error {
+	return mz.forAllTargets(func(target *topo.ShardInfo) error {
+		targetPrimary, err := mz.ts.GetTablet(ctx, target.PrimaryAlias)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetTablet(%v) failed", target.PrimaryAlias)
+		}
+		buf := &strings.Builder{}
+		t := template.Must(template.New("").Parse(insertsMap[target.ShardName()]))
+		input := map[string]string{
+			"keyrange": key.KeyRangeString(target.KeyRange),
+			"dbname":   targetPrimary.DbName(),
+		}
+		if err := t.Execute(buf, input); err != nil {
+			return err
+		}
+		_, err = mz.tmc.VReplicationExec(ctx, targetPrimary.Tablet, buf.String())
+		return err
+	})
+}
+
+func (mz *materializer) startStreams(ctx context.Context) error {
+	return mz.forAllTargets(func(target *topo.ShardInfo) error {
+		targetPrimary, err := mz.ts.GetTablet(ctx, target.PrimaryAlias)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetTablet(%v) failed", target.PrimaryAlias)
+		}
+		query := fmt.Sprintf("update _vt.vreplication set state='%s' where db_name=%s and workflow=%s",
+			binlogplayer.BlpRunning, encodeString(targetPrimary.DbName()), encodeString(mz.ms.Workflow))
+		if _, err := mz.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		return nil
+	})
+}
+
+// migrationID returns the id that SwitchTraffic will give to the journals
+// of the workflow, computed from its streams like HashStreams does.
+func (mz *materializer) migrationID(ctx context.Context) (int64, error) {
+	var (
+		mu      sync.Mutex
+		streams []string
+	)
+	err := mz.forAllTargets(func(target *topo.ShardInfo) error {
+		targetPrimary, err := mz.ts.GetTablet(ctx, target.PrimaryAlias)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetTablet(%v) failed", target.PrimaryAlias)
+		}
+		query := fmt.Sprintf("select id from _vt.vreplication where db_name=%s and workflow=%s", encodeString(targetPrimary.DbName()), encodeString(mz.ms.Workflow))
+		p3qr, err := mz.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query)
+		if err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		qr := sqltypes.Proto3ToResult(p3qr)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, row := range qr.Rows {
+			id, err := row[0].ToInt64()
+			if err != nil {
+				return err
+			}
+			streams = append(streams, fmt.Sprintf("%s:%d", target.ShardName(), id))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return hashStrings(mz.ms.TargetKeyspace, streams), nil
+}
+
+// previousJournals returns the source primaries that have a journal with
+// the given migration id.
+func (mz *materializer) previousJournals(ctx context.Context, s *Server, migrationID int64) ([]string, error) {
+	var (
+		mu      sync.Mutex
+		tablets []string
+	)
+	err := forAllShards(mz.sourceShards, func(si *topo.ShardInfo) error {
+		tablet, err := mz.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return err
+		}
+		_, exists, err := s.CheckReshardingJournalExistsOnTablet(ctx, tablet.Tablet, migrationID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			mu.Lock()
+			defer mu.Unlock()
+			tablets = append(tablets, tablet.AliasString())
+		}
+		return nil
+	})
+	return tablets, err
+}
+
+// checkTZConversion checks that the target primaries have the time zone
+// tables loaded, which the convert_tz() calls of the streams rely on.
+func (mz *materializer) checkTZConversion(ctx context.Context, tz string) error {
+	return mz.forAllTargets(func(target *topo.ShardInfo) error {
+		targetPrimary, err := mz.ts.GetTablet(ctx, target.PrimaryAlias)
+		if err != nil {
+			return vterrors.Wrapf(err, "GetTablet(%v) failed", target.PrimaryAlias)
+		}
+		testDateTime := "2006-01-02 15:04:05"
+		query := fmt.Sprintf("select convert_tz(%s, %s, 'UTC')", encodeString(testDateTime), encodeString(tz))
+		p3qr, err := mz.tmc.ExecuteFetchAsApp(ctx, targetPrimary.Tablet, false, &tabletmanagerdatapb.ExecuteFetchAsAppRequest{
+			Query:   []byte(query),
+			MaxRows: 1,
+		})
+		if err != nil {
+			return vterrors.Wrapf(err, "ExecuteFetchAsApp(%v, %s)", targetPrimary.Tablet, query)
+		}
+		qr := sqltypes.Proto3ToResult(p3qr)
+		if len(qr.Rows) == 0 {
+			return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "no result converting a datetime from %s to UTC on %s", tz, targetPrimary.AliasString())
+		}
+		if _, err := time.Parse(testDateTime, qr.Rows[0][0].ToString()); err != nil {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "unable to convert datetimes from %s to UTC, got %q: either the source time zone is invalid or the time zone tables are not loaded on %s",
+				tz, qr.Rows[0][0].ToString(), targetPrimary.AliasString())
+		}
+		return nil
+	})
+}
+
+func (mz *materializer) forAllTargets(f func(*topo.ShardInfo) error) error {
+	return forAllShards(mz.targetShards, f)
+}
+
+// forAllShards calls f for each shard concurrently, and aggregates the
+// errors.
+func forAllShards(shards []*topo.ShardInfo, f func(*topo.ShardInfo) error) error {
+	var wg sync.WaitGroup
+	allErrors := &concurrency.AllErrorRecorder{}
+	for _, si := range shards {
+		wg.Add(1)
+		go func(si *topo.ShardInfo) {
+			defer wg.Done()
+
+			if err := f(si); err != nil {
+				allErrors.RecordError(err)
+			}
+		}(si)
+	}
+	wg.Wait()
+	return allErrors.AggrError(vterrors.Aggregate)
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topotools"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+const (
+	mzUpdateQuery   = "update _vt.vreplication set state='Running' where db_name='vt_targetks' and workflow='workflow'"
+	mzSelectIDQuery = "select id from _vt.vreplication where db_name='vt_targetks' and workflow='workflow'"
+	mzCheckJournal  = "/select val from _vt.resharding_journal where id="
+)
+
+func TestStripTableForeignKeys(t *testing.T) {
+	tcs := []struct {
+		name string
+		ddl  string
+		want string
+	}{
+		{
+			name: "no constraints",
+			ddl:  "create table t1 (id int, primary key (id))",
+			want: "create table t1 (id int, primary key (id))",
+		},
+		{
+			name: "foreign key",
+			ddl:  "create table t1 (id int, pid int, primary key (id), constraint fk_1 foreign key (pid) references t2 (id))",
+			want: "create table t1 (id int, pid int, primary key (id))",
+		},
+		{
+			name: "check constraint is kept",
+			ddl:  "create table t1 (id int, pid int, primary key (id), constraint ck_1 check (pid > 0), constraint fk_1 foreign key (pid) references t2 (id))",
+			want: "create table t1 (id int, pid int, primary key (id), constraint ck_1 check (pid > 0))",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := stripTableForeignKeys(tc.ddl)
+			require.NoError(t, err)
+			want, err := sqlparser.ParseStrictDDL(tc.want)
+			require.NoError(t, err)
+			assert.Equal(t, sqlparser.String(want), got)
+		})
+	}
+
+	_, err := stripTableForeignKeys("create tabl t1")
+	assert.Error(t, err)
+}
+
+func TestShouldInclude(t *testing.T) {
+	assert.True(t, shouldInclude("t1", nil))
+	assert.False(t, shouldInclude("t1", []string{"t2", "t1"}))
+	assert.False(t, shouldInclude("_vt_HOLD_6ace8bcef73211ea87e9f875a4d24e90_20200915120410", nil))
+}
+
+func TestMaterializeCreate(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t1",
+			SourceExpression: "select * from t1",
+			CreateDdl:        "t1ddl",
+		}},
+	}
+	env := newTestMaterializerEnv(t, ms, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	ctx := context.Background()
+	err := env.topoServ.SaveVSchema(ctx, "targetks", &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "c1", Name: "hash"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	env.tmc.expectVRQuery(200, insertPrefix+`.*shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"select.*t1 where in_keyrange\(c1.*targetks\.hash.*-80.*'vt_targetks', 0, 0, false\)`+eol, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, insertPrefix+`.*shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"select.*t1 where in_keyrange\(c1.*targetks\.hash.*80-.*'vt_targetks', 0, 0, false\)`+eol, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, mzUpdateQuery, &sqltypes.Result{})
+	env.tmc.expectVRQuery(210, mzUpdateQuery, &sqltypes.Result{})
+
+	resp, err := env.ws.MaterializeCreate(ctx, &vtctldatapb.MaterializeCreateRequest{Settings: ms})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully created the workflow workflow, materializing 1 tables from the sourceks keyspace on 2 shards of the targetks keyspace", resp.Summary)
+	env.tmc.verifyQueries(t)
+}
+
+func TestMaterializeCreateErrors(t *testing.T) {
+	testcases := []struct {
+		name             string
+		sourceExpression string
+		sourceShards     []string
+		err              string
+	}{{
+		name:             "unsupported where clause",
+		sourceExpression: "select * from t1 where t1.c1 = 1",
+		sourceShards:     []string{"0"},
+		err:              "unsupported qualifier for column: t1.c1",
+	}, {
+		name:             "aggregate across source shards",
+		sourceExpression: "select c2, max(c3) as mx from t1 group by c2",
+		sourceShards:     []string{"-80", "80-"},
+		err:              "invalid source expression for table t1: min, max, avg and count(distinct) of a source keyspace with more than one shard require the primary vindex column c1 of table t1 to be grouped",
+	}}
+	for _, tcase := range testcases {
+		t.Run(tcase.name, func(t *testing.T) {
+			ms := &vtctldatapb.MaterializeSettings{
+				Workflow:       "workflow",
+				SourceKeyspace: "sourceks",
+				TargetKeyspace: "targetks",
+				TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+					TargetTable:      "t1",
+					SourceExpression: tcase.sourceExpression,
+					CreateDdl:        "t1ddl",
+				}},
+			}
+			env := newTestMaterializerEnv(t, ms, tcase.sourceShards, []string{"0"})
+			defer env.close()
+
+			ctx := context.Background()
+			err := env.topoServ.SaveVSchema(ctx, "targetks", &vschemapb.Keyspace{})
+			require.NoError(t, err)
+			err = env.topoServ.SaveVSchema(ctx, "sourceks", &vschemapb.Keyspace{
+				Sharded: true,
+				Vindexes: map[string]*vschemapb.Vindex{
+					"hash": {Type: "hash"},
+				},
+				Tables: map[string]*vschemapb.Table{
+					"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "c1", Name: "hash"}}},
+				},
+			})
+			require.NoError(t, err)
+
+			_, err = env.ws.MaterializeCreate(ctx, &vtctldatapb.MaterializeCreateRequest{Settings: ms})
+			require.ErrorContains(t, err, tcase.err)
+			env.tmc.verifyQueries(t)
+		})
+	}
+}
+
+func TestMoveTablesCreate(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t1",
+			SourceExpression: "select * from t1",
+		}},
+	}
+	env := newTestMaterializerEnv(t, ms, []string{"0"}, []string{"0"})
+	defer env.close()
+
+	ctx := context.Background()
+	err := env.topoServ.SaveVSchema(ctx, "targetks", &vschemapb.Keyspace{})
+	require.NoError(t, err)
+	err = env.topoServ.SaveVSchema(ctx, "sourceks", &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{
+			"t1": {AutoIncrement: &vschemapb.AutoIncrement{Column: "id", Sequence: "t1_seq"}},
+		},
+	})
+	require.NoError(t, err)
+
+	env.tmc.expectVRQuery(200, insertPrefix+`\('workflow', 'keyspace:\\"sourceks\\" shard:\\"0\\" filter:{rules:{match:\\"t1\\" filter:\\"select \* from t1\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_targetks', 1, 0, false\)`+eol, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, mzSelectIDQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1"))
+	env.tmc.expectVRQuery(100, mzCheckJournal, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, mzUpdateQuery, &sqltypes.Result{})
+
+	resp, err := env.ws.MoveTablesCreate(ctx, &vtctldatapb.MoveTablesCreateRequest{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		IncludeTables:  []string{"t1"},
+		AutoStart:      true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully created and started the workflow workflow, moving 1 tables from the sourceks keyspace to 1 shards of the targetks keyspace", resp.Summary)
+	env.tmc.verifyQueries(t)
+
+	vschema, err := env.topoServ.GetVSchema(ctx, "targetks")
+	require.NoError(t, err)
+	assert.Equal(t, "t1_seq", vschema.Tables["t1"].AutoIncrement.Sequence)
+
+	rules, err := topotools.GetRoutingRules(ctx, env.topoServ)
+	require.NoError(t, err)
+	want := map[string][]string{}
+	for _, suffix := range []string{"", "@replica", "@rdonly"} {
+		want["t1"+suffix] = []string{"sourceks.t1"}
+		want["targetks.t1"+suffix] = []string{"sourceks.t1"}
+		if suffix != "" {
+			want["sourceks.t1"+suffix] = []string{"sourceks.t1"}
+		}
+	}
+	assert.Equal(t, want, rules)
+}
+
+func TestMoveTablesCreateErrors(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t1",
+			SourceExpression: "select * from t1",
+		}},
+	}
+	env := newTestMaterializerEnv(t, ms, []string{"0"}, []string{"0"})
+	defer env.close()
+
+	ctx := context.Background()
+	err := env.topoServ.SaveVSchema(ctx, "targetks", &vschemapb.Keyspace{})
+	require.NoError(t, err)
+	err = env.topoServ.SaveVSchema(ctx, "sourceks", &vschemapb.Keyspace{})
+	require.NoError(t, err)
+
+	req := &vtctldatapb.MoveTablesCreateRequest{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		IncludeTables:  []string{"t1", "tyt", "txt"},
+	}
+	_, err = env.ws.MoveTablesCreate(ctx, req)
+	require.EqualError(t, err, "table(s) not found in source keyspace sourceks: tyt,txt")
+
+	req.IncludeTables = nil
+	req.AllTables = true
+	req.ExcludeTables = []string{"t1"}
+	_, err = env.ws.MoveTablesCreate(ctx, req)
+	require.EqualError(t, err, "no tables to move")
+
+	// A journal of a previous run with the same streams fails the workflow.
+	req.ExcludeTables = nil
+	env.tmc.expectVRQuery(200, insertPrefix, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, mzSelectIDQuery, sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1"))
+	env.tmc.expectVRQuery(100, mzCheckJournal, sqltypes.MakeTestResult(sqltypes.MakeTestFields("val", "varbinary"), ""))
+	_, err = env.ws.MoveTablesCreate(ctx, req)
+	require.ErrorContains(t, err, "found an entry from a previous run for migration id")
+	env.tmc.verifyQueries(t)
+}
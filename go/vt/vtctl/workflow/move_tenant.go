@@ -0,0 +1,831 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/sqlescape"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/topotools"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// defaultMoveTenantMaxReplicationLag is the maximum time since the streams of
+// a MoveTenant workflow last updated their position for SwitchTraffic to
+// proceed, if the request does not specify one.
+const defaultMoveTenantMaxReplicationLag = 30 * time.Second
+
+// tenantWorkflow is a MoveTenant workflow, as found on the primary of its
+// target shard.
+type tenantWorkflow struct {
+	name           string
+	targetKeyspace string
+	targetShard    string
+	primary        *topo.TabletInfo
+	sourceKeyspace string
+	keyRange       *topodatapb.KeyRange
+	tables         []string
+	streams        []*tenantStream
+}
+
+// tenantStream is one stream of a MoveTenant workflow, copying the tenant
+// rows of a single source shard.
+type tenantStream struct {
+	id          int64
+	sourceShard string
+	state       string
+	message     string
+	timeUpdated int64
+}
+
+// rule returns the keyspace id routing rule that pins the tenant to the
+// target shard of the workflow.
+func (tw *tenantWorkflow) rule() *vschemapb.KeyspaceIdRoutingRule {
+	return &vschemapb.KeyspaceIdRoutingRule{
+		FromKeyspace: tw.sourceKeyspace,
+		KeyRange:     key.KeyRangeString(tw.keyRange),
+		ToKeyspace:   tw.targetKeyspace,
+		ToShard:      tw.targetShard,
+	}
+}
+
+// MoveTenantCreate is part of the vtctlservicepb.VtctldServer interface.
+//
+// It creates a workflow on the primary of the target shard that copies the
+// rows of a tenant, i.e. the rows whose keyspace ids fall within a key range,
+// from every source shard that overlaps the range. The tables must already
+// exist on the target shard. Traffic keeps going to the source shards until
+// MoveTenantSwitchTraffic is called.
+func (s *Server) MoveTenantCreate(ctx context.Context, req *vtctldatapb.MoveTenantCreateRequest) (*vtctldatapb.MoveTenantCreateResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.MoveTenantCreate")
+	defer span.Finish()
+
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("source_keyspace", req.SourceKeyspace)
+	span.Annotate("key_range", req.KeyRange)
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("target_shard", req.TargetShard)
+
+	if req.Workflow == "" || req.SourceKeyspace == "" || req.TargetKeyspace == "" || req.TargetShard == "" {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "workflow, source keyspace, target keyspace and target shard are required")
+	}
+	keyRange, err := parseTenantKeyRange(req.KeyRange)
+	if err != nil {
+		return nil, err
+	}
+
+	vs, err := s.ts.GetVSchema(ctx, req.SourceKeyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetVSchema(%s)", req.SourceKeyspace)
+	}
+	if !vs.Sharded {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "keyspace %s is not sharded", req.SourceKeyspace)
+	}
+	tables := req.Tables
+	if len(tables) == 0 {
+		tables = tenantTables(vs)
+		if len(tables) == 0 {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "keyspace %s has no tables with a primary vindex", req.SourceKeyspace)
+		}
+	}
+	for _, table := range tables {
+		if t, ok := vs.Tables[table]; !ok || len(t.ColumnVindexes) == 0 {
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "table %s has no primary vindex in the %s keyspace vschema", table, req.SourceKeyspace)
+		}
+	}
+
+	rules, err := s.ts.GetKeyspaceIdRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if rule := findOverlappingTenantRule(rules, req.SourceKeyspace, keyRange); rule != nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "key range %s of keyspace %s is already routed to %s/%s", rule.KeyRange, req.SourceKeyspace, rule.ToKeyspace, rule.ToShard)
+	}
+
+	sourceShards, err := s.findTenantShards(ctx, req.SourceKeyspace, keyRange)
+	if err != nil {
+		return nil, err
+	}
+	targetShard, err := s.ts.GetShard(ctx, req.TargetKeyspace, req.TargetShard)
+	if err != nil {
+		return nil, err
+	}
+	// The tenant is deleted from its source shards once traffic is switched,
+	// so it cannot move to one of them.
+	if req.TargetKeyspace == req.SourceKeyspace {
+		for _, si := range sourceShards {
+			if key.KeyRangeIntersect(si.KeyRange, targetShard.KeyRange) {
+				return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "target shard %s/%s overlaps shard %s, which holds key range %s", req.TargetKeyspace, req.TargetShard, si.ShardName(), key.KeyRangeString(keyRange))
+			}
+		}
+	}
+	if targetShard.PrimaryAlias == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", req.TargetKeyspace, req.TargetShard)
+	}
+	primary, err := s.ts.GetTablet(ctx, targetShard.PrimaryAlias)
+	if err != nil {
+		return nil, err
+	}
+	dbName := topoproto.TabletDbName(primary.Tablet)
+
+	query := fmt.Sprintf("select id from _vt.vreplication where db_name=%s and workflow=%s", encodeString(dbName), encodeString(req.Workflow))
+	p3qr, err := s.tmc.VReplicationExec(ctx, primary.Tablet, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(p3qr.Rows) != 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_ALREADY_EXISTS, "workflow %s already exists on %s/%s", req.Workflow, req.TargetKeyspace, req.TargetShard)
+	}
+
+	keyRangeString := key.KeyRangeString(keyRange)
+	filter := &binlogdatapb.Filter{}
+	for _, table := range tables {
+		filter.Rules = append(filter.Rules, &binlogdatapb.Rule{
+			Match:  table,
+			Filter: tenantFilter(table, keyRangeString),
+		})
+	}
+	for _, si := range sourceShards {
+		bls := &binlogdatapb.BinlogSource{
+			Keyspace: req.SourceKeyspace,
+			Shard:    si.ShardName(),
+			Filter:   filter,
+			OnDdl:    req.OnDdl,
+		}
+		query := binlogplayer.CreateVReplicationState(req.Workflow, bls, "", binlogplayer.BlpStopped, dbName,
+			binlogdatapb.VReplicationWorkflowType_MoveTenant, binlogdatapb.VReplicationWorkflowSubType_None)
+		if _, err := s.tmc.VReplicationExec(ctx, primary.Tablet, query); err != nil {
+			return nil, vterrors.Wrapf(err, "failed to create the stream from %s/%s", req.SourceKeyspace, si.ShardName())
+		}
+	}
+
+	query = fmt.Sprintf("update _vt.vreplication set state='%s', cell=%s, tablet_types=%s where db_name=%s and workflow=%s",
+		binlogplayer.BlpRunning, encodeString(strings.Join(req.Cells, ",")),
+		encodeString(strings.Join(topoproto.MakeStringTypeList(req.TabletTypes), ",")),
+		encodeString(dbName), encodeString(req.Workflow))
+	if _, err := s.tmc.VReplicationExec(ctx, primary.Tablet, query); err != nil {
+		return nil, vterrors.Wrapf(err, "failed to start the %s workflow", req.Workflow)
+	}
+
+	return &vtctldatapb.MoveTenantCreateResponse{
+		Summary: fmt.Sprintf("Successfully created the %s workflow, copying %d tables of key range %s from %d shards of the %s keyspace to %s/%s",
+			req.Workflow, len(tables), keyRangeString, len(sourceShards), req.SourceKeyspace, req.TargetKeyspace, req.TargetShard),
+	}, nil
+}
+
+// MoveTenantSwitchTraffic is part of the vtctlservicepb.VtctldServer
+// interface. Once the workflow has copied the tenant and caught up, it cuts
+// the tenant over like SwitchTraffic cuts over the writes of a MoveTables
+// workflow: the writes to the tenant tables are denied on the source shards,
+// which vtgates buffer when buffering is enabled, the streams catch up with
+// the positions of the source primaries and are stopped, and only then is the
+// keyspace id routing rule added that sends the reads and writes of the
+// tenant to the target shard. As the source shards cannot deny the writes of
+// a single tenant, the writes of the other tenants of these shards to the
+// same tables are denied for the duration of the cutover too.
+//
+// Once the writes are allowed again, the rows of the tenant are deleted from
+// the source shards, which scatter queries on the source keyspace still go to
+// for the other tenants. The stopped streams do not replicate the deletes.
+func (s *Server) MoveTenantSwitchTraffic(ctx context.Context, req *vtctldatapb.MoveTenantSwitchTrafficRequest) (resp *vtctldatapb.MoveTenantSwitchTrafficResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.MoveTenantSwitchTraffic")
+	defer span.Finish()
+
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("dry_run", req.DryRun)
+
+	maxLag, ok, err := protoutil.DurationFromProto(req.MaxReplicationLagAllowed)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid max replication lag allowed")
+	}
+	if !ok {
+		maxLag = defaultMoveTenantMaxReplicationLag
+	}
+	timeout, ok, err := protoutil.DurationFromProto(req.Timeout)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid timeout")
+	}
+	if !ok {
+		timeout = defaultSwitchTrafficTimeout
+	}
+
+	tw, err := s.getTenantWorkflow(ctx, req.TargetKeyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTenantWorkflowCaughtUp(ctx, tw, maxLag); err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, lockErr := s.lockTenantKeyspaces(ctx, tw, "MoveTenantSwitchTraffic")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	rules, err := s.ts.GetKeyspaceIdRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rule := tw.rule()
+	if findTenantRule(rules, rule) != -1 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "traffic of the %s workflow was already switched", req.Workflow)
+	}
+	if other := findOverlappingTenantRule(rules, tw.sourceKeyspace, tw.keyRange); other != nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "key range %s of keyspace %s is already routed to %s/%s", other.KeyRange, tw.sourceKeyspace, other.ToKeyspace, other.ToShard)
+	}
+
+	resp = &vtctldatapb.MoveTenantSwitchTrafficResponse{Rule: rule}
+	if req.DryRun {
+		resp.Summary = fmt.Sprintf("Would route key range %s of the %s keyspace to %s/%s", rule.KeyRange, rule.FromKeyspace, rule.ToKeyspace, rule.ToShard)
+		return resp, nil
+	}
+
+	ksschema, err := s.getTenantKeyspaceSchema(ctx, tw.sourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	sourceShards, err := s.findTenantShards(ctx, tw.sourceKeyspace, tw.keyRange)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := s.changePrimaryWrites(ctx, tw.sourceKeyspace, tw.tables, sourceShards, disallowWrites)
+	// The writes are allowed again once the tenant is routed to the target
+	// shard, or when the cutover fails.
+	defer func() {
+		if _, allowErr := s.changePrimaryWrites(ctx, tw.sourceKeyspace, tw.tables, denied, allowWrites); allowErr != nil {
+			allowErr = vterrors.Wrapf(allowErr, "failed to allow the writes to the tables of the %s workflow on the source shards again", req.Workflow)
+			log.Error(allowErr)
+			if err == nil {
+				resp, err = nil, allowErr
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.stopTenantStreams(ctx, tw, sourceShards, timeout); err != nil {
+		s.startTenantStreams(ctx, tw)
+		return nil, err
+	}
+
+	rules.Rules = append(rules.Rules, rule)
+	if err = s.ts.SaveKeyspaceIdRoutingRules(ctx, rules); err != nil {
+		s.startTenantStreams(ctx, tw)
+		return nil, err
+	}
+	if err = s.ts.RebuildSrvVSchema(ctx, req.RebuildCells); err != nil {
+		return nil, vterrors.Wrapf(err, "RebuildSrvVSchema")
+	}
+
+	_, err = s.changePrimaryWrites(ctx, tw.sourceKeyspace, tw.tables, denied, allowWrites)
+	denied = nil
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "failed to allow the writes to the tables of the %s workflow on the source shards again", req.Workflow)
+	}
+	deleted, err := s.deleteTenantSourceRows(ctx, tw, ksschema, sourceShards)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "routed key range %s of the %s keyspace to %s/%s, but failed to delete it from the source shards, run complete to retry", rule.KeyRange, rule.FromKeyspace, rule.ToKeyspace, rule.ToShard)
+	}
+
+	resp.Summary = fmt.Sprintf("Successfully routed key range %s of the %s keyspace to %s/%s, deleting %d rows from %d source shards", rule.KeyRange, rule.FromKeyspace, rule.ToKeyspace, rule.ToShard, deleted, len(sourceShards))
+	return resp, nil
+}
+
+// MoveTenantComplete is part of the vtctlservicepb.VtctldServer interface.
+// After traffic was switched, it deletes the workflow streams, and then the
+// rows of the tenant that MoveTenantSwitchTraffic failed to delete from the
+// source shards, if any. The routing rule stays in place.
+func (s *Server) MoveTenantComplete(ctx context.Context, req *vtctldatapb.MoveTenantCompleteRequest) (resp *vtctldatapb.MoveTenantCompleteResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.MoveTenantComplete")
+	defer span.Finish()
+
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+
+	tw, err := s.getTenantWorkflow(ctx, req.TargetKeyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	ctx, unlock, lockErr := s.lockTenantKeyspaces(ctx, tw, "MoveTenantComplete")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	rules, err := s.ts.GetKeyspaceIdRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if findTenantRule(rules, tw.rule()) == -1 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "traffic of the %s workflow was not switched yet", req.Workflow)
+	}
+	ksschema, err := s.getTenantKeyspaceSchema(ctx, tw.sourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	// The streams are deleted first, so that deleting the tenant from the
+	// source shards does not replicate to the target shard.
+	if err := s.deleteTenantStreams(ctx, tw); err != nil {
+		return nil, err
+	}
+
+	sourceShards, err := s.findTenantShards(ctx, tw.sourceKeyspace, tw.keyRange)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := s.deleteTenantSourceRows(ctx, tw, ksschema, sourceShards)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vtctldatapb.MoveTenantCompleteResponse{
+		Summary:     fmt.Sprintf("Successfully completed the %s workflow, deleting %d rows of key range %s from %d shards of the %s keyspace", req.Workflow, deleted, key.KeyRangeString(tw.keyRange), len(sourceShards), tw.sourceKeyspace),
+		RowsDeleted: deleted,
+	}, nil
+}
+
+// MoveTenantCancel is part of the vtctlservicepb.VtctldServer interface.
+// Before traffic is switched, it deletes the workflow streams and then the
+// rows of the tenant copied so far from the target shard.
+func (s *Server) MoveTenantCancel(ctx context.Context, req *vtctldatapb.MoveTenantCancelRequest) (resp *vtctldatapb.MoveTenantCancelResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.MoveTenantCancel")
+	defer span.Finish()
+
+	span.Annotate("target_keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+
+	tw, err := s.getTenantWorkflow(ctx, req.TargetKeyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	ctx, unlock, lockErr := s.lockTenantKeyspaces(ctx, tw, "MoveTenantCancel")
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	defer unlock(&err)
+
+	rules, err := s.ts.GetKeyspaceIdRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if findTenantRule(rules, tw.rule()) != -1 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "traffic of the %s workflow was already switched, remove the keyspace id routing rule first", req.Workflow)
+	}
+	ksschema, err := s.getTenantKeyspaceSchema(ctx, tw.sourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.deleteTenantStreams(ctx, tw); err != nil {
+		return nil, err
+	}
+	deleted, err := s.deleteTenantRows(ctx, tw.primary.Tablet, ksschema, tw.tables, tw.keyRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vtctldatapb.MoveTenantCancelResponse{
+		Summary:     fmt.Sprintf("Successfully cancelled the %s workflow, deleting %d rows of key range %s from %s/%s", req.Workflow, deleted, key.KeyRangeString(tw.keyRange), tw.targetKeyspace, tw.targetShard),
+		RowsDeleted: deleted,
+	}, nil
+}
+
+// getTenantWorkflow finds the MoveTenant workflow with the given name on the
+// primaries of the target keyspace.
+func (s *Server) getTenantWorkflow(ctx context.Context, targetKeyspace, workflow string) (*tenantWorkflow, error) {
+	shards, err := s.ts.FindAllShardsInKeyspace(ctx, targetKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	shardNames := make([]string, 0, len(shards))
+	for name := range shards {
+		shardNames = append(shardNames, name)
+	}
+	sort.Strings(shardNames)
+
+	var tw *tenantWorkflow
+	for _, name := range shardNames {
+		si := shards[name]
+		if si.PrimaryAlias == nil {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", targetKeyspace, name)
+		}
+		primary, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, err
+		}
+		query := fmt.Sprintf("select id, source, state, message, time_updated from _vt.vreplication where db_name=%s and workflow=%s and workflow_type=%d",
+			encodeString(topoproto.TabletDbName(primary.Tablet)), encodeString(workflow), binlogdatapb.VReplicationWorkflowType_MoveTenant)
+		p3qr, err := s.tmc.VReplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return nil, err
+		}
+		qr := sqltypes.Proto3ToResult(p3qr)
+		if len(qr.Rows) == 0 {
+			continue
+		}
+		if tw != nil {
+			return nil, vterrors.Wrapf(ErrInvalidWorkflow, "MoveTenant workflow %s exists on more than one shard of the %s keyspace", workflow, targetKeyspace)
+		}
+
+		tw = &tenantWorkflow{
+			name:           workflow,
+			targetKeyspace: targetKeyspace,
+			targetShard:    name,
+			primary:        primary,
+		}
+		for _, row := range qr.Rows {
+			id, err := row[0].ToInt64()
+			if err != nil {
+				return nil, err
+			}
+			rowBytes, err := row[1].ToBytes()
+			if err != nil {
+				return nil, err
+			}
+			var bls binlogdatapb.BinlogSource
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return nil, err
+			}
+			timeUpdated, err := row[4].ToInt64()
+			if err != nil {
+				return nil, err
+			}
+			if len(bls.GetFilter().GetRules()) == 0 {
+				return nil, vterrors.Wrapf(ErrInvalidWorkflow, "stream %d of workflow %s has no filter rules", id, workflow)
+			}
+			if tw.keyRange == nil {
+				tw.sourceKeyspace = bls.Keyspace
+				if tw.keyRange, err = parseTenantFilter(bls.Filter.Rules[0].Filter); err != nil {
+					return nil, vterrors.Wrapf(err, "stream %d of workflow %s", id, workflow)
+				}
+				for _, rule := range bls.Filter.Rules {
+					tw.tables = append(tw.tables, rule.Match)
+				}
+			}
+			tw.streams = append(tw.streams, &tenantStream{
+				id:          id,
+				sourceShard: bls.Shard,
+				state:       row[2].ToString(),
+				message:     row[3].ToString(),
+				timeUpdated: timeUpdated,
+			})
+		}
+	}
+	if tw == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "the %s MoveTenant workflow does not exist in the %s keyspace", workflow, targetKeyspace)
+	}
+	return tw, nil
+}
+
+// lockTenantKeyspaces locks the source and the target keyspaces of the
+// workflow, like SwitchTraffic does for MoveTables workflows.
+func (s *Server) lockTenantKeyspaces(ctx context.Context, tw *tenantWorkflow, action string) (context.Context, func(*error), error) {
+	var unlocks []func(*error)
+	unlockAll := func(err *error) {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i](err)
+		}
+	}
+	keyspaces := []string{tw.sourceKeyspace}
+	if tw.targetKeyspace != tw.sourceKeyspace {
+		keyspaces = append(keyspaces, tw.targetKeyspace)
+	}
+	for _, keyspace := range keyspaces {
+		lctx, unlock, err := s.ts.LockKeyspace(ctx, keyspace, action)
+		if err != nil {
+			unlockAll(&err)
+			return nil, nil, err
+		}
+		ctx = lctx
+		unlocks = append(unlocks, unlock)
+	}
+	return ctx, unlockAll, nil
+}
+
+// checkTenantWorkflowCaughtUp checks that every stream of the workflow is
+// running, has finished copying, and updated its position recently enough.
+func (s *Server) checkTenantWorkflowCaughtUp(ctx context.Context, tw *tenantWorkflow, maxLag time.Duration) error {
+	ids := make([]string, len(tw.streams))
+	now := time.Now().Unix()
+	for i, stream := range tw.streams {
+		if stream.state != binlogplayer.BlpRunning {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "stream %d from %s/%s is %s: %s", stream.id, tw.sourceKeyspace, stream.sourceShard, stream.state, stream.message)
+		}
+		if lag := time.Duration(now-stream.timeUpdated) * time.Second; lag > maxLag {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "stream %d from %s/%s is lagging by %v, more than the %v allowed", stream.id, tw.sourceKeyspace, stream.sourceShard, lag, maxLag)
+		}
+		ids[i] = fmt.Sprintf("%d", stream.id)
+	}
+
+	query := fmt.Sprintf("select vrepl_id from _vt.copy_state where vrepl_id in (%s) limit 1", strings.Join(ids, ", "))
+	p3qr, err := s.tmc.VReplicationExec(ctx, tw.primary.Tablet, query)
+	if err != nil {
+		return err
+	}
+	if len(p3qr.Rows) != 0 {
+		return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "the %s workflow is still copying", tw.name)
+	}
+	return nil
+}
+
+// changePrimaryWrites denies or allows the writes to the given tables on the
+// primaries of the given shards of a keyspace, and returns the shards it
+// changed. Denying fails if a table is already denied on a shard, for example
+// by another workflow, so that allowing the writes again never lifts a deny
+// the caller did not add. The keyspace must be locked.
+func (s *Server) changePrimaryWrites(ctx context.Context, keyspace string, tables []string, shards []*topo.ShardInfo, access accessType) ([]*topo.ShardInfo, error) {
+	var changed []*topo.ShardInfo
+	for _, shard := range shards {
+		si, err := s.ts.UpdateShardFields(ctx, keyspace, shard.ShardName(), func(si *topo.ShardInfo) error {
+			return si.UpdateSourceDeniedTables(ctx, topodatapb.TabletType_PRIMARY, nil, access == allowWrites /* remove */, tables)
+		})
+		if err != nil {
+			return changed, vterrors.Wrapf(err, "failed to change the denied tables of %s/%s", keyspace, shard.ShardName())
+		}
+		changed = append(changed, si)
+
+		rtbsCtx, cancel := context.WithTimeout(ctx, shardTabletRefreshTimeout)
+		isPartial, partialDetails, err := topotools.RefreshTabletsByShard(rtbsCtx, s.ts, s.tmc, si, nil, logutil.NewConsoleLogger())
+		cancel()
+		if isPartial {
+			err = vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "failed to successfully refresh all tablets in the %s/%s shard (%v):\n  %v",
+				keyspace, si.ShardName(), err, partialDetails)
+		}
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// stopTenantStreams waits for every stream of the workflow to catch up with
+// the current position of the primary of its source shard, and stops it. The
+// writes to the tenant tables must be denied on the source shards.
+func (s *Server) stopTenantStreams(ctx context.Context, tw *tenantWorkflow, sourceShards []*topo.ShardInfo, timeout time.Duration) error {
+	positions := make(map[string]string, len(sourceShards))
+	for _, si := range sourceShards {
+		if si.PrimaryAlias == nil {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", tw.sourceKeyspace, si.ShardName())
+		}
+		tablet, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return err
+		}
+		if positions[si.ShardName()], err = s.tmc.PrimaryPosition(ctx, tablet.Tablet); err != nil {
+			return vterrors.Wrapf(err, "failed to read the position of %s/%s", tw.sourceKeyspace, si.ShardName())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, stream := range tw.streams {
+		pos, ok := positions[stream.sourceShard]
+		if !ok {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "stream %d copies from %s/%s, which does not overlap key range %s", stream.id, tw.sourceKeyspace, stream.sourceShard, key.KeyRangeString(tw.keyRange))
+		}
+		if err := s.tmc.VReplicationWaitForPos(ctx, tw.primary.Tablet, int32(stream.id), pos); err != nil {
+			return vterrors.Wrapf(err, "stream %d from %s/%s did not catch up with position %s", stream.id, tw.sourceKeyspace, stream.sourceShard, pos)
+		}
+		if _, err := s.tmc.VReplicationExec(ctx, tw.primary.Tablet, binlogplayer.StopVReplication(int32(stream.id), "stopped for cutover")); err != nil {
+			return vterrors.Wrapf(err, "failed to stop stream %d on %s for cutover", stream.id, tw.primary.AliasString())
+		}
+	}
+	return nil
+}
+
+// startTenantStreams restarts the streams of the workflow after a failed
+// cutover. Errors are only logged, the streams being left stopped.
+func (s *Server) startTenantStreams(ctx context.Context, tw *tenantWorkflow) {
+	for _, stream := range tw.streams {
+		if _, err := s.tmc.VReplicationExec(ctx, tw.primary.Tablet, binlogplayer.StartVReplication(int32(stream.id))); err != nil {
+			log.Errorf("Failed to restart stream %d of the %s workflow on %s: %v", stream.id, tw.name, tw.primary.AliasString(), err)
+		}
+	}
+}
+
+func (s *Server) deleteTenantStreams(ctx context.Context, tw *tenantWorkflow) error {
+	query := fmt.Sprintf("delete from _vt.vreplication where db_name=%s and workflow=%s",
+		encodeString(topoproto.TabletDbName(tw.primary.Tablet)), encodeString(tw.name))
+	if _, err := s.tmc.VReplicationExec(ctx, tw.primary.Tablet, query); err != nil {
+		return vterrors.Wrapf(err, "failed to delete the streams of the %s workflow", tw.name)
+	}
+	return nil
+}
+
+// deleteTenantRows deletes the rows of the given tables whose keyspace ids
+// fall within the key range from a tablet, and returns the number of rows
+// deleted. The keyspace ids are computed with the primary vindex of each
+// table in the source keyspace.
+func (s *Server) deleteTenantRows(ctx context.Context, tablet *topodatapb.Tablet, ksschema *vindexes.KeyspaceSchema, tables []string, keyRange *topodatapb.KeyRange) (uint64, error) {
+	var deleted uint64
+	for _, table := range tables {
+		t, ok := ksschema.Tables[table]
+		if !ok || len(t.ColumnVindexes) == 0 {
+			return deleted, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "table %s has no primary vindex in the %s keyspace vschema", table, ksschema.Keyspace.Name)
+		}
+		primaryVindex := t.ColumnVindexes[0]
+		columns := make([]string, len(primaryVindex.Columns))
+		for i, col := range primaryVindex.Columns {
+			columns[i] = col.String()
+		}
+
+		err := s.scanVindexColumns(ctx, tablet, table, columns, primaryVindex.Vindex, func(batch *vindexScanBatch) error {
+			var tenantRows [][]sqltypes.Value
+			for i, ksid := range batch.ksids {
+				if ksid != nil && key.KeyRangeContains(keyRange, ksid) {
+					tenantRows = append(tenantRows, batch.pks[i])
+				}
+			}
+			if len(tenantRows) == 0 {
+				return nil
+			}
+			qr, err := s.tmc.ExecuteFetchAsDba(ctx, tablet, true, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{
+				Query:  []byte(buildVindexDeleteQuery(table, batch.pkColumns, tenantRows)),
+				DbName: topoproto.TabletDbName(tablet),
+			})
+			if err != nil {
+				return vterrors.Wrapf(err, "failed to delete rows of %s on tablet %s", table, topoproto.TabletAliasString(tablet.Alias))
+			}
+			deleted += qr.RowsAffected
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// deleteTenantSourceRows deletes the rows of the tenant from the primaries of
+// the given source shards, and returns the number of rows deleted.
+func (s *Server) deleteTenantSourceRows(ctx context.Context, tw *tenantWorkflow, ksschema *vindexes.KeyspaceSchema, sourceShards []*topo.ShardInfo) (uint64, error) {
+	var deleted uint64
+	for _, si := range sourceShards {
+		if si.PrimaryAlias == nil {
+			return deleted, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "shard %s/%s has no primary", tw.sourceKeyspace, si.ShardName())
+		}
+		tablet, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return deleted, err
+		}
+		n, err := s.deleteTenantRows(ctx, tablet.Tablet, ksschema, tw.tables, tw.keyRange)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Server) getTenantKeyspaceSchema(ctx context.Context, keyspace string) (*vindexes.KeyspaceSchema, error) {
+	vs, err := s.ts.GetVSchema(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "GetVSchema(%s)", keyspace)
+	}
+	return vindexes.BuildKeyspaceSchema(vs, keyspace)
+}
+
+// findTenantShards returns the shards of the keyspace that overlap the key
+// range, sorted by name.
+func (s *Server) findTenantShards(ctx context.Context, keyspace string, keyRange *topodatapb.KeyRange) ([]*topo.ShardInfo, error) {
+	shards, err := s.ts.FindAllShardsInKeyspace(ctx, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	var result []*topo.ShardInfo
+	for _, si := range shards {
+		if key.KeyRangeIntersect(si.KeyRange, keyRange) {
+			result = append(result, si)
+		}
+	}
+	if len(result) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "no shard of keyspace %s overlaps key range %s", keyspace, key.KeyRangeString(keyRange))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ShardName() < result[j].ShardName()
+	})
+	return result, nil
+}
+
+// tenantTables returns the tables of the keyspace vschema that have a
+// primary vindex, sorted by name.
+func tenantTables(vs *vschemapb.Keyspace) []string {
+	var tables []string
+	for name, table := range vs.Tables {
+		if len(table.ColumnVindexes) != 0 {
+			tables = append(tables, name)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func parseTenantKeyRange(s string) (*topodatapb.KeyRange, error) {
+	keyRanges, err := key.ParseShardingSpec(s)
+	if err != nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid key range %q: %v", s, err)
+	}
+	if len(keyRanges) != 1 || key.KeyRangeIsComplete(keyRanges[0]) {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "key range %q must be a single partial key range", s)
+	}
+	return keyRanges[0], nil
+}
+
+// tenantFilter returns the filter of a stream copying the rows of a tenant
+// from a table.
+func tenantFilter(table, keyRange string) string {
+	return fmt.Sprintf("select * from %s where in_keyrange(%s)", sqlescape.EscapeID(table), encodeString(keyRange))
+}
+
+// parseTenantFilter returns the key range of a filter built by tenantFilter.
+func parseTenantFilter(filter string) (*topodatapb.KeyRange, error) {
+	stmt, err := sqlparser.Parse(filter)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || sel.Where == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected filter %q", filter)
+	}
+	fn, ok := sel.Where.Expr.(*sqlparser.FuncExpr)
+	if !ok || !fn.Name.EqualString("in_keyrange") || len(fn.Exprs) != 1 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected filter %q", filter)
+	}
+	aliased, ok := fn.Exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected filter %q", filter)
+	}
+	lit, ok := aliased.Expr.(*sqlparser.Literal)
+	if !ok {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected filter %q", filter)
+	}
+	return parseTenantKeyRange(lit.Val)
+}
+
+func findTenantRule(rules *vschemapb.KeyspaceIdRoutingRules, rule *vschemapb.KeyspaceIdRoutingRule) int {
+	for i, r := range rules.GetRules() {
+		if proto.Equal(r, rule) {
+			return i
+		}
+	}
+	return -1
+}
+
+func findOverlappingTenantRule(rules *vschemapb.KeyspaceIdRoutingRules, keyspace string, keyRange *topodatapb.KeyRange) *vschemapb.KeyspaceIdRoutingRule {
+	for _, r := range rules.GetRules() {
+		if r.FromKeyspace != keyspace {
+			continue
+		}
+		// Rules are validated when they are saved.
+		krs, err := key.ParseShardingSpec(r.KeyRange)
+		if err != nil || len(krs) != 1 {
+			continue
+		}
+		if key.KeyRangeIntersect(krs[0], keyRange) {
+			return r
+		}
+	}
+	return nil
+}
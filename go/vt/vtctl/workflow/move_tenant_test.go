@@ -0,0 +1,449 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/test/utils"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver/testutil"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// The tenant is the keyspace id of id 1 under the numeric vindex.
+const tenantKeyRange = "0000000000000001-0000000000000002"
+
+var tenantRule = &vschemapb.KeyspaceIdRoutingRule{
+	FromKeyspace: "ks",
+	KeyRange:     tenantKeyRange,
+	ToKeyspace:   "vip",
+	ToShard:      "-",
+}
+
+func newMoveTenantTestServer(ctx context.Context, t *testing.T, tmc *fakeTMC) *Server {
+	t.Helper()
+
+	ts := memorytopo.NewServer("zone1")
+	testutil.AddTablets(ctx, t, ts, &testutil.AddTabletOptions{AlsoSetShardPrimary: true},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 100},
+			Keyspace: "ks",
+			Shard:    "-80",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 200},
+			Keyspace: "ks",
+			Shard:    "80-",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 300},
+			Keyspace: "vip",
+			Shard:    "-",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+	)
+	err := ts.SaveVSchema(ctx, "ks", &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"numeric": {Type: "numeric"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {
+				ColumnVindexes: []*vschemapb.ColumnVindex{{Name: "numeric", Columns: []string{"id"}}},
+			},
+			"t2": {
+				ColumnVindexes: []*vschemapb.ColumnVindex{{Name: "numeric", Columns: []string{"id"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ts.SaveVSchema(ctx, "vip", &vschemapb.Keyspace{}))
+	require.NoError(t, ts.RebuildSrvVSchema(ctx, nil))
+
+	return NewServer(ts, tmc)
+}
+
+// tenantStreamsResult returns the streams of the acme workflow, as read by
+// getTenantWorkflow.
+func tenantStreamsResult(state string, timeUpdated int64) *querypb.QueryResult {
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: "ks",
+		Shard:    "-80",
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{
+				{Match: "t1", Filter: tenantFilter("t1", tenantKeyRange)},
+				{Match: "t2", Filter: tenantFilter("t2", tenantKeyRange)},
+			},
+		},
+	}
+	return sqltypes.ResultToProto3(sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id|source|state|message|time_updated", "int64|varbinary|varbinary|varbinary|int64"),
+		fmt.Sprintf("1|%s|%s||%d", bls.String(), state, timeUpdated),
+	))
+}
+
+const (
+	tenantStreamsQuery = "select id, source, state, message, time_updated from _vt.vreplication where db_name='vt_vip' and workflow='acme' and workflow_type=6"
+	tenantDeleteQuery  = "delete from _vt.vreplication where db_name='vt_vip' and workflow='acme'"
+	tenantStopQuery    = "update _vt.vreplication set state='Stopped', message='stopped for cutover' where id=1"
+	tenantStartQuery   = "update _vt.vreplication set state='Running', stop_pos=NULL where id=1"
+	tenantSourcePos    = "MySQL56/00000000-0000-0000-0000-000000000001:1-10"
+)
+
+// tenantSourceDeniedTables returns the tables whose writes are denied on the
+// primary of the source shard of the acme workflow.
+func tenantSourceDeniedTables(ctx context.Context, t *testing.T, ws *Server) []string {
+	t.Helper()
+	si, err := ws.ts.GetShard(ctx, "ks", "-80")
+	require.NoError(t, err)
+	return si.GetTabletControl(topodatapb.TabletType_PRIMARY).GetDeniedTables()
+}
+
+// tenantRowsQueries returns the queries that find and delete the row of the
+// tenant from t1 and t2 on a tablet holding ids 1 and 2, by primary key.
+func tenantRowsQueries() map[string]*querypb.QueryResult {
+	fields := sqltypes.MakeTestFields("id|id", "uint64|uint64")
+	return map[string]*querypb.QueryResult{
+		"select `id`, `id` from `t1` order by `id` limit 10000": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|1", "2|2")),
+		"select `id`, `id` from `t2` order by `id` limit 10000": sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "1|1", "2|2")),
+		"delete from `t1` where (`id`) in ((1))":                {RowsAffected: 1},
+		"delete from `t2` where (`id`) in ((1))":                {RowsAffected: 1},
+	}
+}
+
+// tenantTableSchemas are the schemas of t1 and t2, whose primary key is id.
+func tenantTableSchemas() map[string]*tabletmanagerdatapb.TableDefinition {
+	return map[string]*tabletmanagerdatapb.TableDefinition{
+		"t1": {Name: "t1", PrimaryKeyColumns: []string{"id"}},
+		"t2": {Name: "t2", PrimaryKeyColumns: []string{"id"}},
+	}
+}
+
+func TestMoveTenant(t *testing.T) {
+	ctx := context.Background()
+	tmc := &fakeTMC{
+		vrepQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+			"zone1-0000000300": {
+				"select id from _vt.vreplication where db_name='vt_vip' and workflow='acme'":                                                   {},
+				"/insert into _vt.vreplication.*'acme'.*shard:.*-80.*'Stopped', 'vt_vip', 6":                                                   {},
+				"update _vt.vreplication set state='Running', cell='zone1', tablet_types='replica' where db_name='vt_vip' and workflow='acme'": {},
+				tenantStreamsQuery: tenantStreamsResult("Running", time.Now().Unix()),
+				"select vrepl_id from _vt.copy_state where vrepl_id in (1) limit 1": {},
+				tenantStopQuery:   {RowsAffected: 1},
+				tenantDeleteQuery: {RowsAffected: 1},
+			},
+		},
+		dbaQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+			"zone1-0000000100": tenantRowsQueries(),
+		},
+		primaryPositions: map[string]string{"zone1-0000000100": tenantSourcePos},
+		tableSchemas:     tenantTableSchemas(),
+	}
+	ws := newMoveTenantTestServer(ctx, t, tmc)
+
+	create, err := ws.MoveTenantCreate(ctx, &vtctldatapb.MoveTenantCreateRequest{
+		Workflow:       "acme",
+		SourceKeyspace: "ks",
+		KeyRange:       tenantKeyRange,
+		TargetKeyspace: "vip",
+		TargetShard:    "-",
+		Cells:          []string{"zone1"},
+		TabletTypes:    []topodatapb.TabletType{topodatapb.TabletType_REPLICA},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, create.Summary, "copying 2 tables of key range "+tenantKeyRange+" from 1 shards")
+
+	dryRun, err := ws.MoveTenantSwitchTraffic(ctx, &vtctldatapb.MoveTenantSwitchTrafficRequest{
+		TargetKeyspace: "vip",
+		Workflow:       "acme",
+		DryRun:         true,
+	})
+	require.NoError(t, err)
+	utils.MustMatch(t, tenantRule, dryRun.Rule)
+	rules, err := ws.ts.GetKeyspaceIdRoutingRules(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rules.Rules)
+
+	_, err = ws.MoveTenantComplete(ctx, &vtctldatapb.MoveTenantCompleteRequest{TargetKeyspace: "vip", Workflow: "acme"})
+	assert.ErrorContains(t, err, "traffic of the acme workflow was not switched yet")
+
+	// The tenant is deleted from the source shard once it is routed to the
+	// target shard, and the writes of the other tenants are allowed again.
+	tmc.onExecuteFetchAsDba = func(tablet *topodatapb.Tablet) {
+		srvVSchema, err := ws.ts.GetSrvVSchema(ctx, "zone1")
+		require.NoError(t, err)
+		assert.NotNil(t, srvVSchema.KeyspaceIdRoutingRules, "tenant rows read from the source shard before the tenant was routed")
+		assert.Empty(t, tenantSourceDeniedTables(ctx, t, ws))
+	}
+	switched, err := ws.MoveTenantSwitchTraffic(ctx, &vtctldatapb.MoveTenantSwitchTrafficRequest{
+		TargetKeyspace: "vip",
+		Workflow:       "acme",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, switched.Summary, "deleting 2 rows from 1 source shards")
+	assert.Contains(t, tmc.dbaQueries, "delete from `t1` where (`id`) in ((1))")
+	assert.Contains(t, tmc.dbaQueries, "delete from `t2` where (`id`) in ((1))")
+	srvVSchema, err := ws.ts.GetSrvVSchema(ctx, "zone1")
+	require.NoError(t, err)
+	utils.MustMatch(t, []*vschemapb.KeyspaceIdRoutingRule{tenantRule}, srvVSchema.KeyspaceIdRoutingRules.Rules)
+	// The stream caught up with the source primary before it was stopped,
+	// and the writes denied for the cutover are allowed again.
+	assert.Equal(t, []string{"zone1-0000000300/1@" + tenantSourcePos}, tmc.waitedForPos)
+	assert.Contains(t, tmc.vrepQueries, tenantStopQuery)
+	assert.Empty(t, tenantSourceDeniedTables(ctx, t, ws))
+
+	_, err = ws.MoveTenantSwitchTraffic(ctx, &vtctldatapb.MoveTenantSwitchTrafficRequest{
+		TargetKeyspace: "vip",
+		Workflow:       "acme",
+	})
+	assert.ErrorContains(t, err, "traffic of the acme workflow was already switched")
+	_, err = ws.MoveTenantCancel(ctx, &vtctldatapb.MoveTenantCancelRequest{TargetKeyspace: "vip", Workflow: "acme"})
+	assert.ErrorContains(t, err, "traffic of the acme workflow was already switched")
+
+	// Only the rows of the other tenant are left on the source shard.
+	fields := sqltypes.MakeTestFields("id|id", "uint64|uint64")
+	for _, table := range []string{"t1", "t2"} {
+		tmc.dbaQueriesByTablet["zone1-0000000100"][fmt.Sprintf("select `id`, `id` from `%s` order by `id` limit 10000", table)] = sqltypes.ResultToProto3(sqltypes.MakeTestResult(fields, "2|2"))
+	}
+	complete, err := ws.MoveTenantComplete(ctx, &vtctldatapb.MoveTenantCompleteRequest{TargetKeyspace: "vip", Workflow: "acme"})
+	require.NoError(t, err)
+	assert.Zero(t, complete.RowsDeleted)
+	assert.Contains(t, tmc.vrepQueries, tenantDeleteQuery)
+}
+
+func TestMoveTenantCancel(t *testing.T) {
+	ctx := context.Background()
+	tmc := &fakeTMC{
+		vrepQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+			"zone1-0000000300": {
+				tenantStreamsQuery: tenantStreamsResult("Running", time.Now().Unix()),
+				tenantDeleteQuery:  {RowsAffected: 1},
+			},
+		},
+		dbaQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+			"zone1-0000000300": tenantRowsQueries(),
+		},
+		tableSchemas: tenantTableSchemas(),
+	}
+	ws := newMoveTenantTestServer(ctx, t, tmc)
+
+	resp, err := ws.MoveTenantCancel(ctx, &vtctldatapb.MoveTenantCancelRequest{TargetKeyspace: "vip", Workflow: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), resp.RowsDeleted)
+}
+
+func TestMoveTenantSwitchTrafficNotCaughtUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		streams   *querypb.QueryResult
+		copyState *querypb.QueryResult
+		wantErr   string
+	}{
+		{
+			name:    "stopped",
+			streams: tenantStreamsResult("Stopped", time.Now().Unix()),
+			wantErr: "stream 1 from ks/-80 is Stopped",
+		},
+		{
+			name:    "lagging",
+			streams: tenantStreamsResult("Running", time.Now().Add(-time.Hour).Unix()),
+			wantErr: "stream 1 from ks/-80 is lagging",
+		},
+		{
+			name:    "copying",
+			streams: tenantStreamsResult("Running", time.Now().Unix()),
+			copyState: sqltypes.ResultToProto3(sqltypes.MakeTestResult(
+				sqltypes.MakeTestFields("vrepl_id", "int64"), "1")),
+			wantErr: "the acme workflow is still copying",
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmc := &fakeTMC{
+				vrepQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+					"zone1-0000000300": {
+						tenantStreamsQuery: tt.streams,
+						"select vrepl_id from _vt.copy_state where vrepl_id in (1) limit 1": tt.copyState,
+					},
+				},
+			}
+			ws := newMoveTenantTestServer(ctx, t, tmc)
+
+			_, err := ws.MoveTenantSwitchTraffic(ctx, &vtctldatapb.MoveTenantSwitchTrafficRequest{
+				TargetKeyspace: "vip",
+				Workflow:       "acme",
+			})
+			assert.ErrorContains(t, err, tt.wantErr)
+
+			rules, err := ws.ts.GetKeyspaceIdRoutingRules(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, rules.Rules)
+		})
+	}
+}
+
+func TestMoveTenantSwitchTrafficRollback(t *testing.T) {
+	tests := []struct {
+		name          string
+		waitForPosErr error
+		deniedTables  []string
+		wantErr       string
+	}{
+		{
+			name:          "stream does not catch up",
+			waitForPosErr: fmt.Errorf("context deadline exceeded"),
+			wantErr:       "stream 1 from ks/-80 did not catch up with position " + tenantSourcePos,
+		},
+		{
+			name:         "table already denied",
+			deniedTables: []string{"t2"},
+			wantErr:      "failed to change the denied tables of ks/-80",
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmc := &fakeTMC{
+				vrepQueriesByTablet: map[string]map[string]*querypb.QueryResult{
+					"zone1-0000000300": {
+						tenantStreamsQuery: tenantStreamsResult("Running", time.Now().Unix()),
+						"select vrepl_id from _vt.copy_state where vrepl_id in (1) limit 1": {},
+						tenantStartQuery: {RowsAffected: 1},
+					},
+				},
+				primaryPositions: map[string]string{"zone1-0000000100": tenantSourcePos},
+				waitForPosErr:    tt.waitForPosErr,
+			}
+			ws := newMoveTenantTestServer(ctx, t, tmc)
+			if tt.deniedTables != nil {
+				lctx, unlock, err := ws.ts.LockKeyspace(ctx, "ks", "test")
+				require.NoError(t, err)
+				_, err = ws.ts.UpdateShardFields(lctx, "ks", "-80", func(si *topo.ShardInfo) error {
+					return si.UpdateSourceDeniedTables(lctx, topodatapb.TabletType_PRIMARY, nil, false, tt.deniedTables)
+				})
+				unlock(&err)
+				require.NoError(t, err)
+			}
+
+			_, err := ws.MoveTenantSwitchTraffic(ctx, &vtctldatapb.MoveTenantSwitchTrafficRequest{
+				TargetKeyspace: "vip",
+				Workflow:       "acme",
+			})
+			assert.ErrorContains(t, err, tt.wantErr)
+
+			// The tenant is not routed, the streams are running again, and
+			// only the writes denied before the cutover are still denied.
+			rules, err := ws.ts.GetKeyspaceIdRoutingRules(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, rules.Rules)
+			assert.Equal(t, tt.deniedTables, tenantSourceDeniedTables(ctx, t, ws))
+			if tt.waitForPosErr != nil {
+				assert.Contains(t, tmc.vrepQueries, tenantStartQuery)
+			}
+		})
+	}
+}
+
+func TestMoveTenantCreateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *vtctldatapb.MoveTenantCreateRequest
+		rules    []*vschemapb.KeyspaceIdRoutingRule
+		wantErr  string
+		wantCode vtrpcpb.Code
+	}{
+		{
+			name:    "missing workflow",
+			req:     &vtctldatapb.MoveTenantCreateRequest{SourceKeyspace: "ks", KeyRange: tenantKeyRange, TargetKeyspace: "vip", TargetShard: "-"},
+			wantErr: "workflow, source keyspace, target keyspace and target shard are required",
+		},
+		{
+			name:    "full key range",
+			req:     &vtctldatapb.MoveTenantCreateRequest{Workflow: "acme", SourceKeyspace: "ks", KeyRange: "-", TargetKeyspace: "vip", TargetShard: "-"},
+			wantErr: "must be a single partial key range",
+		},
+		{
+			name:    "unsharded source",
+			req:     &vtctldatapb.MoveTenantCreateRequest{Workflow: "acme", SourceKeyspace: "vip", KeyRange: tenantKeyRange, TargetKeyspace: "ks", TargetShard: "-80"},
+			wantErr: "keyspace vip is not sharded",
+		},
+		{
+			name:    "unknown table",
+			req:     &vtctldatapb.MoveTenantCreateRequest{Workflow: "acme", SourceKeyspace: "ks", KeyRange: tenantKeyRange, TargetKeyspace: "vip", TargetShard: "-", Tables: []string{"t3"}},
+			wantErr: "table t3 has no primary vindex",
+		},
+		{
+			name:     "target shard holds the tenant",
+			req:      &vtctldatapb.MoveTenantCreateRequest{Workflow: "acme", SourceKeyspace: "ks", KeyRange: tenantKeyRange, TargetKeyspace: "ks", TargetShard: "-80"},
+			wantErr:  "target shard ks/-80 overlaps shard -80, which holds key range " + tenantKeyRange,
+			wantCode: vtrpcpb.Code_INVALID_ARGUMENT,
+		},
+		{
+			name:    "already routed",
+			req:     &vtctldatapb.MoveTenantCreateRequest{Workflow: "acme", SourceKeyspace: "ks", KeyRange: "00-01", TargetKeyspace: "vip", TargetShard: "-"},
+			rules:   []*vschemapb.KeyspaceIdRoutingRule{tenantRule},
+			wantErr: "key range " + tenantKeyRange + " of keyspace ks is already routed to vip/-",
+		},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := newMoveTenantTestServer(ctx, t, &fakeTMC{})
+			if tt.rules != nil {
+				require.NoError(t, ws.ts.SaveKeyspaceIdRoutingRules(ctx, &vschemapb.KeyspaceIdRoutingRules{Rules: tt.rules}))
+			}
+
+			_, err := ws.MoveTenantCreate(ctx, tt.req)
+			assert.ErrorContains(t, err, tt.wantErr)
+			if tt.wantCode != vtrpcpb.Code_OK {
+				assert.Equal(t, tt.wantCode, vterrors.Code(err))
+			}
+		})
+	}
+}
+
+func TestParseTenantFilter(t *testing.T) {
+	keyRange, err := parseTenantFilter(tenantFilter("t1", tenantKeyRange))
+	require.NoError(t, err)
+	assert.Equal(t, tenantKeyRange, fmt.Sprintf("%x-%x", keyRange.Start, keyRange.End))
+
+	_, err = parseTenantFilter("select * from t1")
+	assert.ErrorContains(t, err, "unexpected filter")
+}
@@ -0,0 +1,428 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/prototext"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/mysqlctl/tmutils"
+	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/topotools"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// resharder creates the streams of a Reshard workflow on the primaries of
+// the target shards.
+type resharder struct {
+	ts                 *topo.Server
+	tmc                tmclient.TabletManagerClient
+	keyspace           string
+	workflow           string
+	sourceShards       []*topo.ShardInfo
+	sourcePrimaries    map[string]*topo.TabletInfo
+	targetShards       []*topo.ShardInfo
+	targetPrimaries    map[string]*topo.TabletInfo
+	vschema            *vschemapb.Keyspace
+	refStreams         map[string]*refStream
+	cell               string
+	tabletTypes        string
+	stopAfterCopy      bool
+	onDDL              string
+	deferSecondaryKeys bool
+}
+
+// refStream is a stream of the source shards that replicates reference
+// tables, and that has to be recreated on the target shards.
+type refStream struct {
+	workflow    string
+	bls         *binlogdatapb.BinlogSource
+	cell        string
+	tabletTypes string
+}
+
+// ReshardCreate is part of the vtctlservicepb.VtctldServer interface.
+// It copies the schema of the source shards to the target shards, unless
+// told not to, and creates the streams of the workflow on the target
+// primaries.
+func (s *Server) ReshardCreate(ctx context.Context, req *vtctldatapb.ReshardCreateRequest) (*vtctldatapb.ReshardCreateResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.ReshardCreate")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("source_shards", strings.Join(req.SourceShards, ","))
+	span.Annotate("target_shards", strings.Join(req.TargetShards, ","))
+
+	if req.Workflow == "" || req.Keyspace == "" {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "workflow and keyspace are required")
+	}
+	if len(req.SourceShards) == 0 || len(req.TargetShards) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "source and target shards are required")
+	}
+
+	cell := strings.Join(req.Cells, ",")
+	if err := s.validateNewWorkflow(ctx, req.Keyspace, req.Workflow); err != nil {
+		return nil, err
+	}
+	if err := s.ts.ValidateSrvKeyspace(ctx, req.Keyspace, cell); err != nil {
+		return nil, vterrors.Wrapf(err, "SrvKeyspace for keyspace %s is corrupt in cell %s", req.Keyspace, cell)
+	}
+
+	rs, err := s.buildResharder(ctx, req.Keyspace, req.Workflow, req.SourceShards, req.TargetShards, cell,
+		strings.Join(topoproto.MakeStringTypeList(req.TabletTypes), ","))
+	if err != nil {
+		return nil, vterrors.Wrap(err, "buildResharder")
+	}
+	rs.onDDL = req.OnDdl.String()
+	rs.stopAfterCopy = req.StopAfterCopy
+	rs.deferSecondaryKeys = req.DeferSecondaryKeys
+
+	if !req.SkipSchemaCopy {
+		if err := rs.copySchema(ctx); err != nil {
+			return nil, vterrors.Wrap(err, "copySchema")
+		}
+	}
+	if err := rs.createStreams(ctx); err != nil {
+		return nil, vterrors.Wrap(err, "createStreams")
+	}
+
+	started := ""
+	if req.AutoStart {
+		if err := rs.startStreams(ctx); err != nil {
+			return nil, vterrors.Wrap(err, "startStreams")
+		}
+		started = " and started"
+	}
+
+	return &vtctldatapb.ReshardCreateResponse{
+		Summary: fmt.Sprintf("Successfully created%s the %s workflow, resharding %s/%s into %s/%s",
+			started, req.Workflow, req.Keyspace, strings.Join(req.SourceShards, ","), req.Keyspace, strings.Join(req.TargetShards, ",")),
+	}, nil
+}
+
+func (s *Server) buildResharder(ctx context.Context, keyspace, workflow string, sources, targets []string, cell, tabletTypes string) (*resharder, error) {
+	rs := &resharder{
+		ts:              s.ts,
+		tmc:             s.tmc,
+		keyspace:        keyspace,
+		workflow:        workflow,
+		sourcePrimaries: make(map[string]*topo.TabletInfo),
+		targetPrimaries: make(map[string]*topo.TabletInfo),
+		cell:            cell,
+		tabletTypes:     tabletTypes,
+	}
+	for _, shard := range sources {
+		si, err := s.ts.GetShard(ctx, keyspace, shard)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if !si.IsPrimaryServing {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "source shard %v is not in serving state", shard)
+		}
+		rs.sourceShards = append(rs.sourceShards, si)
+		primary, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		rs.sourcePrimaries[si.ShardName()] = primary
+	}
+	for _, shard := range targets {
+		si, err := s.ts.GetShard(ctx, keyspace, shard)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetShard(%s) failed", shard)
+		}
+		if si.IsPrimaryServing {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "target shard %v is in serving state", shard)
+		}
+		rs.targetShards = append(rs.targetShards, si)
+		primary, err := s.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "GetTablet(%s) failed", si.PrimaryAlias)
+		}
+		rs.targetPrimaries[si.ShardName()] = primary
+	}
+	if err := topotools.ValidateForReshard(rs.sourceShards, rs.targetShards); err != nil {
+		return nil, vterrors.Wrap(err, "ValidateForReshard")
+	}
+	if err := rs.validateTargets(ctx); err != nil {
+		return nil, vterrors.Wrap(err, "validateTargets")
+	}
+
+	vschema, err := s.ts.GetVSchema(ctx, keyspace)
+	if err != nil {
+		return nil, vterrors.Wrap(err, "GetVSchema")
+	}
+	rs.vschema = vschema
+
+	if err := rs.readRefStreams(ctx); err != nil {
+		return nil, vterrors.Wrap(err, "readRefStreams")
+	}
+	return rs, nil
+}
+
+func (rs *resharder) validateTargets(ctx context.Context) error {
+	return forAllShards(rs.targetShards, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+		query := fmt.Sprintf("select 1 from _vt.vreplication where db_name=%s", encodeString(targetPrimary.DbName()))
+		p3qr, err := rs.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query)
+		if err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		if len(p3qr.Rows) != 0 {
+			return vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "some streams already exist in the target shards, please clean them up and retry the command")
+		}
+		return nil
+	})
+}
+
+// readRefStreams reads the streams of the source shards that replicate
+// reference tables. They must be the same on all the source shards.
+func (rs *resharder) readRefStreams(ctx context.Context) error {
+	var mu sync.Mutex
+	return forAllShards(rs.sourceShards, func(source *topo.ShardInfo) error {
+		sourcePrimary := rs.sourcePrimaries[source.ShardName()]
+
+		query := fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name=%s and message != '%s'", encodeString(sourcePrimary.DbName()), Frozen)
+		p3qr, err := rs.tmc.VReplicationExec(ctx, sourcePrimary.Tablet, query)
+		if err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", sourcePrimary.Tablet, query)
+		}
+		qr := sqltypes.Proto3ToResult(p3qr)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		mustCreate := false
+		var ref map[string]bool
+		if rs.refStreams == nil {
+			rs.refStreams = make(map[string]*refStream)
+			mustCreate = true
+		} else {
+			// Copy the ref streams for comparison.
+			ref = make(map[string]bool, len(rs.refStreams))
+			for k := range rs.refStreams {
+				ref[k] = true
+			}
+		}
+		for _, row := range qr.Rows {
+			workflow := row[0].ToString()
+			if workflow == "" {
+				return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "VReplication streams must have named workflows for migration: shard: %s:%s", source.Keyspace(), source.ShardName())
+			}
+			var bls binlogdatapb.BinlogSource
+			rowBytes, err := row[1].ToBytes()
+			if err != nil {
+				return err
+			}
+			if err := prototext.Unmarshal(rowBytes, &bls); err != nil {
+				return vterrors.Wrapf(err, "prototext.Unmarshal: %v", row)
+			}
+			isReference, err := rs.blsIsReference(&bls)
+			if err != nil {
+				return vterrors.Wrap(err, "blsIsReference")
+			}
+			if !isReference {
+				continue
+			}
+			key := fmt.Sprintf("%s:%s:%s", workflow, bls.Keyspace, bls.Shard)
+			if mustCreate {
+				rs.refStreams[key] = &refStream{
+					workflow:    workflow,
+					bls:         &bls,
+					cell:        row[2].ToString(),
+					tabletTypes: row[3].ToString(),
+				}
+				continue
+			}
+			if !ref[key] {
+				return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "streams are mismatched across source shards for workflow: %s", workflow)
+			}
+			delete(ref, key)
+		}
+		if len(ref) != 0 {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "streams are mismatched across source shards: %v", ref)
+		}
+		return nil
+	})
+}
+
+// blsIsReference tells whether a stream replicates reference tables only.
+// It is partially copied from StreamMigrator.templatize.
+func (rs *resharder) blsIsReference(bls *binlogdatapb.BinlogSource) (bool, error) {
+	streamType := StreamTypeUnknown
+	for _, rule := range bls.Filter.Rules {
+		typ, err := rs.identifyRuleType(rule)
+		if err != nil {
+			return false, err
+		}
+
+		switch typ {
+		case StreamTypeSharded:
+			if streamType == StreamTypeReference {
+				return false, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot reshard streams with a mix of reference and sharded tables: %v", bls)
+			}
+			streamType = StreamTypeSharded
+		case StreamTypeReference:
+			if streamType == StreamTypeSharded {
+				return false, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot reshard streams with a mix of reference and sharded tables: %v", bls)
+			}
+			streamType = StreamTypeReference
+		}
+	}
+	return streamType == StreamTypeReference, nil
+}
+
+func (rs *resharder) identifyRuleType(rule *binlogdatapb.Rule) (StreamType, error) {
+	vtable, ok := rs.vschema.Tables[rule.Match]
+	if !ok && !schema.IsInternalOperationTableName(rule.Match) {
+		return 0, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "table %v not found in vschema", rule.Match)
+	}
+	if vtable != nil && vtable.Type == vindexes.TypeReference {
+		return StreamTypeReference, nil
+	}
+	// In this case, 'sharded' means that it's not a reference
+	// table. We don't care about any other subtleties.
+	return StreamTypeSharded, nil
+}
+
+// copySchema creates the tables and views of the first source shard that
+// the target shards do not have yet.
+func (rs *resharder) copySchema(ctx context.Context) error {
+	req := &tabletmanagerdatapb.GetSchemaRequest{Tables: []string{"/.*/"}, IncludeViews: true}
+	sourceSchema, err := rs.tmc.GetSchema(ctx, rs.sourcePrimaries[rs.sourceShards[0].ShardName()].Tablet, req)
+	if err != nil {
+		return err
+	}
+	return forAllShards(rs.targetShards, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+		targetSchema, err := rs.tmc.GetSchema(ctx, targetPrimary.Tablet, req)
+		if err != nil {
+			return err
+		}
+		exists := make(map[string]bool, len(targetSchema.TableDefinitions))
+		for _, td := range targetSchema.TableDefinitions {
+			exists[td.Name] = true
+		}
+		var ddls []string
+		// Tables come before views, as views may depend on them.
+		for _, td := range sourceSchema.TableDefinitions {
+			if !exists[td.Name] && td.Type == tmutils.TableBaseTable {
+				ddls = append(ddls, td.Schema)
+			}
+		}
+		for _, td := range sourceSchema.TableDefinitions {
+			if !exists[td.Name] && td.Type == tmutils.TableView {
+				ddls = append(ddls, td.Schema)
+			}
+		}
+		if len(ddls) == 0 {
+			return nil
+		}
+		_, err = rs.tmc.ApplySchema(ctx, targetPrimary.Tablet, &tmutils.SchemaChange{
+			SQL:              strings.Join(ddls, ";\n"),
+			AllowReplication: true,
+			SQLMode:          vreplication.SQLMode,
+		})
+		return err
+	})
+}
+
+func (rs *resharder) createStreams(ctx context.Context) error {
+	var excludeRules []*binlogdatapb.Rule
+	for tableName, table := range rs.vschema.Tables {
+		if table.Type == vindexes.TypeReference {
+			excludeRules = append(excludeRules, &binlogdatapb.Rule{
+				Match:  tableName,
+				Filter: "exclude",
+			})
+		}
+	}
+
+	return forAllShards(rs.targetShards, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+
+		ig := vreplication.NewInsertGenerator(binlogplayer.BlpStopped, targetPrimary.DbName())
+
+		// Copy the exclude rules to prevent a data race.
+		copyExcludeRules := append([]*binlogdatapb.Rule(nil), excludeRules...)
+		for _, source := range rs.sourceShards {
+			if !key.KeyRangeIntersect(target.KeyRange, source.KeyRange) {
+				continue
+			}
+			filter := &binlogdatapb.Filter{
+				Rules: append(copyExcludeRules, &binlogdatapb.Rule{
+					Match:  "/.*",
+					Filter: key.KeyRangeString(target.KeyRange),
+				}),
+			}
+			bls := &binlogdatapb.BinlogSource{
+				Keyspace:      rs.keyspace,
+				Shard:         source.ShardName(),
+				Filter:        filter,
+				StopAfterCopy: rs.stopAfterCopy,
+				OnDdl:         binlogdatapb.OnDDLAction(binlogdatapb.OnDDLAction_value[rs.onDDL]),
+			}
+			ig.AddRow(rs.workflow, bls, "", rs.cell, rs.tabletTypes,
+				binlogdatapb.VReplicationWorkflowType_Reshard,
+				binlogdatapb.VReplicationWorkflowSubType_None,
+				rs.deferSecondaryKeys)
+		}
+
+		for _, rstream := range rs.refStreams {
+			ig.AddRow(rstream.workflow, rstream.bls, "", rstream.cell, rstream.tabletTypes,
+				binlogdatapb.VReplicationWorkflowType_Reshard,
+				binlogdatapb.VReplicationWorkflowSubType_None,
+				rs.deferSecondaryKeys)
+		}
+		query := ig.String()
+		if _, err := rs.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		return nil
+	})
+}
+
+func (rs *resharder) startStreams(ctx context.Context) error {
+	return forAllShards(rs.targetShards, func(target *topo.ShardInfo) error {
+		targetPrimary := rs.targetPrimaries[target.ShardName()]
+		query := fmt.Sprintf("update _vt.vreplication set state='%s' where db_name=%s", binlogplayer.BlpRunning, encodeString(targetPrimary.DbName()))
+		if _, err := rs.tmc.VReplicationExec(ctx, targetPrimary.Tablet, query); err != nil {
+			return vterrors.Wrapf(err, "VReplicationExec(%v, %s)", targetPrimary.Tablet, query)
+		}
+		return nil
+	})
+}
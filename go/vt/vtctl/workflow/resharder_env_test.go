@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+type testResharderEnv struct {
+	ws       *Server
+	keyspace string
+	workflow string
+	sources  []string
+	targets  []string
+	tablets  map[int]*topodatapb.Tablet
+	topoServ *topo.Server
+	cell     string
+	tmc      *testMaterializerTMClient
+}
+
+//----------------------------------------------
+// testResharderEnv
+
+func getPartition(t *testing.T, shards []string) *topodatapb.SrvKeyspace_KeyspacePartition {
+	t.Helper()
+	partition := &topodatapb.SrvKeyspace_KeyspacePartition{
+		ServedType:      topodatapb.TabletType_PRIMARY,
+		ShardReferences: []*topodatapb.ShardReference{},
+	}
+	for _, shard := range shards {
+		keyRange, err := key.ParseShardingSpec(shard)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(keyRange))
+		partition.ShardReferences = append(partition.ShardReferences, &topodatapb.ShardReference{
+			Name:     shard,
+			KeyRange: keyRange[0],
+		})
+	}
+	return partition
+}
+
+func initTopo(t *testing.T, ts *topo.Server, keyspace string, sources, targets, cells []string) {
+	t.Helper()
+	srvKeyspace := &topodatapb.SrvKeyspace{
+		Partitions: []*topodatapb.SrvKeyspace_KeyspacePartition{
+			getPartition(t, sources),
+			getPartition(t, targets),
+		},
+	}
+	for _, cell := range cells {
+		err := ts.UpdateSrvKeyspace(context.Background(), cell, keyspace, srvKeyspace)
+		require.NoError(t, err)
+	}
+}
+
+// newTestResharderEnv creates the primaries of the source shards of keyspace
+// ks, with tablet ids from 100, and then of its target shards, with tablet
+// ids from 200, so that only the source shards are serving. Every shard has
+// the schema of table t1.
+func newTestResharderEnv(t *testing.T, sources, targets []string) *testResharderEnv {
+	t.Helper()
+	env := &testResharderEnv{
+		keyspace: "ks",
+		workflow: "resharderTest",
+		sources:  sources,
+		targets:  targets,
+		tablets:  make(map[int]*topodatapb.Tablet),
+		topoServ: memorytopo.NewServer("cell"),
+		cell:     "cell",
+		tmc:      newTestMaterializerTMClient(),
+	}
+	env.ws = NewServer(env.topoServ, env.tmc)
+	initTopo(t, env.topoServ, env.keyspace, sources, targets, []string{env.cell})
+	tabletID := 100
+	for _, shard := range sources {
+		_ = env.addTablet(t, tabletID, env.keyspace, shard, topodatapb.TabletType_PRIMARY)
+		tabletID += 10
+	}
+	tabletID = 200
+	for _, shard := range targets {
+		_ = env.addTablet(t, tabletID, env.keyspace, shard, topodatapb.TabletType_PRIMARY)
+		tabletID += 10
+	}
+	err := env.topoServ.SaveVSchema(context.Background(), env.keyspace, &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "c1", Name: "hash"}}},
+		},
+	})
+	require.NoError(t, err)
+	env.tmc.schema[env.keyspace+".t1"] = &tabletmanagerdatapb.SchemaDefinition{
+		TableDefinitions: []*tabletmanagerdatapb.TableDefinition{{
+			Name:              "t1",
+			Columns:           []string{"c1", "c2"},
+			PrimaryKeyColumns: []string{"c1"},
+			Fields:            sqltypes.MakeTestFields("c1|c2", "int64|int64"),
+		}},
+	}
+	return env
+}
+
+// expectValidation expects the validation of the new workflow on all the
+// primaries of the keyspace, and the check that the target primaries have
+// no streams.
+func (env *testResharderEnv) expectValidation() {
+	for _, tablet := range env.tablets {
+		tabletID := int(tablet.Alias.Uid)
+		env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and workflow='%s'", env.keyspace, env.workflow), &sqltypes.Result{})
+		env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s' and message='FROZEN' and workflow_sub_type != 1", env.keyspace), &sqltypes.Result{})
+		if tabletID >= 200 {
+			env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select 1 from _vt.vreplication where db_name='vt_%s'", env.keyspace), &sqltypes.Result{})
+		}
+	}
+}
+
+// expectNoRefStream expects the source primaries to have no streams of
+// reference tables.
+func (env *testResharderEnv) expectNoRefStream() {
+	for _, tablet := range env.tablets {
+		tabletID := int(tablet.Alias.Uid)
+		if tabletID < 200 {
+			env.tmc.expectVRQuery(tabletID, fmt.Sprintf("select workflow, source, cell, tablet_types from _vt.vreplication where db_name='vt_%s' and message != 'FROZEN'", env.keyspace), &sqltypes.Result{})
+		}
+	}
+}
+
+func (env *testResharderEnv) close() {
+	for _, t := range env.tablets {
+		env.deleteTablet(t)
+	}
+}
+
+func (env *testResharderEnv) addTablet(t *testing.T, id int, keyspace, shard string, tabletType topodatapb.TabletType) *topodatapb.Tablet {
+	t.Helper()
+	tablet := &topodatapb.Tablet{
+		Alias: &topodatapb.TabletAlias{
+			Cell: env.cell,
+			Uid:  uint32(id),
+		},
+		Keyspace: keyspace,
+		Shard:    shard,
+		KeyRange: &topodatapb.KeyRange{},
+		Type:     tabletType,
+		PortMap: map[string]int32{
+			"test": int32(id),
+		},
+	}
+	env.tablets[id] = tablet
+	err := env.topoServ.InitTablet(context.Background(), tablet, false /* allowPrimaryOverride */, true /* createShardAndKeyspace */, false /* allowUpdate */)
+	require.NoError(t, err)
+	if tabletType == topodatapb.TabletType_PRIMARY {
+		_, err := env.topoServ.UpdateShardFields(context.Background(), keyspace, shard, func(si *topo.ShardInfo) error {
+			si.PrimaryAlias = tablet.Alias
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	return tablet
+}
+
+func (env *testResharderEnv) deleteTablet(tablet *topodatapb.Tablet) {
+	_ = env.topoServ.DeleteTablet(context.Background(), tablet.Alias)
+	delete(env.tablets, int(tablet.Alias.Uid))
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+)
+
+const rsUpdateQuery = "update _vt.vreplication set state='Running' where db_name='vt_ks'"
+
+func TestReshardCreateOneToMany(t *testing.T) {
+	testcases := []struct {
+		cells       []string
+		tabletTypes []topodatapb.TabletType
+		autoStart   bool
+	}{{
+		autoStart: true,
+	}, {
+		cells:       []string{"cell"},
+		tabletTypes: []topodatapb.TabletType{topodatapb.TabletType_PRIMARY},
+	}, {
+		cells:       []string{"cell"},
+		tabletTypes: []topodatapb.TabletType{topodatapb.TabletType_REPLICA, topodatapb.TabletType_PRIMARY},
+		autoStart:   true,
+	}, {
+		tabletTypes: []topodatapb.TabletType{topodatapb.TabletType_REPLICA, topodatapb.TabletType_RDONLY},
+	}}
+	for _, tcase := range testcases {
+		cells := strings.Join(tcase.cells, ",")
+		tabletTypes := strings.Join(topoproto.MakeStringTypeList(tcase.tabletTypes), ",")
+		t.Run(cells+"/"+tabletTypes, func(t *testing.T) {
+			env := newTestResharderEnv(t, []string{"0"}, []string{"-80", "80-"})
+			defer env.close()
+
+			env.expectValidation()
+			env.expectNoRefStream()
+			env.tmc.expectVRQuery(200, insertPrefix+
+				`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"/.*\\" filter:\\"-80\\"}}', '', [0-9]*, [0-9]*, '`+
+				cells+`', '`+tabletTypes+`', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol, &sqltypes.Result{})
+			env.tmc.expectVRQuery(210, insertPrefix+
+				`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"0\\" filter:{rules:{match:\\"/.*\\" filter:\\"80-\\"}}', '', [0-9]*, [0-9]*, '`+
+				cells+`', '`+tabletTypes+`', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol, &sqltypes.Result{})
+			if tcase.autoStart {
+				env.tmc.expectVRQuery(200, rsUpdateQuery, &sqltypes.Result{})
+				env.tmc.expectVRQuery(210, rsUpdateQuery, &sqltypes.Result{})
+			}
+
+			_, err := env.ws.ReshardCreate(context.Background(), &vtctldatapb.ReshardCreateRequest{
+				Workflow:     env.workflow,
+				Keyspace:     env.keyspace,
+				SourceShards: env.sources,
+				TargetShards: env.targets,
+				Cells:        tcase.cells,
+				TabletTypes:  tcase.tabletTypes,
+				AutoStart:    tcase.autoStart,
+			})
+			require.NoError(t, err)
+			env.tmc.verifyQueries(t)
+		})
+	}
+}
+
+func TestReshardCreateManyToOne(t *testing.T) {
+	env := newTestResharderEnv(t, []string{"-80", "80-"}, []string{"0"})
+	defer env.close()
+
+	env.expectValidation()
+	env.expectNoRefStream()
+	env.tmc.expectVRQuery(200, insertPrefix+
+		`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"-80\\" filter:{rules:{match:\\"/.*\\" filter:\\"-\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\).*`+
+		`\('resharderTest', 'keyspace:\\"ks\\" shard:\\"80-\\" filter:{rules:{match:\\"/.*\\" filter:\\"-\\"}}', '', [0-9]*, [0-9]*, '', '', [0-9]*, 0, 'Stopped', 'vt_ks', 4, 0, false\)`+eol, &sqltypes.Result{})
+	env.tmc.expectVRQuery(200, rsUpdateQuery, &sqltypes.Result{})
+
+	resp, err := env.ws.ReshardCreate(context.Background(), &vtctldatapb.ReshardCreateRequest{
+		Workflow:     env.workflow,
+		Keyspace:     env.keyspace,
+		SourceShards: env.sources,
+		TargetShards: env.targets,
+		AutoStart:    true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully created and started the resharderTest workflow, resharding ks/-80,80- into ks/0", resp.Summary)
+	env.tmc.verifyQueries(t)
+}
+
+func TestReshardCreateErrors(t *testing.T) {
+	env := newTestResharderEnv(t, []string{"0"}, []string{"-80", "80-"})
+	defer env.close()
+
+	// Streams left on a target shard fail the workflow.
+	env.expectValidation()
+	env.tmc.vrQueries[200][2].result = sqltypes.ResultToProto3(sqltypes.MakeTestResult(sqltypes.MakeTestFields("1", "int64"), "1"))
+	_, err := env.ws.ReshardCreate(context.Background(), &vtctldatapb.ReshardCreateRequest{
+		Workflow:     env.workflow,
+		Keyspace:     env.keyspace,
+		SourceShards: env.sources,
+		TargetShards: env.targets,
+	})
+	require.ErrorContains(t, err, "some streams already exist in the target shards, please clean them up and retry the command")
+
+	// The source shards must be serving and the target shards must not.
+	_, err = env.ws.buildResharder(context.Background(), env.keyspace, env.workflow, env.targets, env.sources, "", "")
+	require.ErrorContains(t, err, "source shard -80 is not in serving state")
+}
@@ -27,6 +27,7 @@ import (
 
 	"google.golang.org/protobuf/encoding/prototext"
 
+	"vitess.io/vitess/go/protoutil"
 	"vitess.io/vitess/go/sets"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/trace"
@@ -34,6 +35,7 @@ import (
 	"vitess.io/vitess/go/vt/key"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vtctl/workflow/vexec"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/evalengine"
@@ -43,6 +45,7 @@ import (
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/proto/vttime"
 )
 
@@ -277,6 +280,7 @@ func (s *Server) GetWorkflows(ctx context.Context, req *vtctldatapb.GetWorkflows
 
 	span.Annotate("keyspace", req.Keyspace)
 	span.Annotate("active_only", req.ActiveOnly)
+	span.Annotate("workflow", req.Workflow)
 
 	where := ""
 	if req.ActiveOnly {
@@ -298,14 +302,15 @@ func (s *Server) GetWorkflows(ctx context.Context, req *vtctldatapb.GetWorkflows
 			message,
 			tags,
 			workflow_type,
-			workflow_sub_type
+			workflow_sub_type,
+			rows_copied
 		FROM
 			_vt.vreplication
 		%s`,
 		where,
 	)
 
-	vx := vexec.NewVExec(req.Keyspace, "", s.ts, s.tmc)
+	vx := vexec.NewVExec(req.Keyspace, req.Workflow, s.ts, s.tmc)
 	results, err := vx.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -367,6 +372,11 @@ func (s *Server) GetWorkflows(ctx context.Context, req *vtctldatapb.GetWorkflows
 
 		message := row["message"].ToString()
 
+		rowsCopied, err := evalengine.ToInt64(row["rows_copied"])
+		if err != nil {
+			return err
+		}
+
 		tags := row["tags"].ToString()
 		var tagArray []string
 		if tags != "" {
@@ -389,8 +399,9 @@ func (s *Server) GetWorkflows(ctx context.Context, req *vtctldatapb.GetWorkflows
 			TimeUpdated: &vttime.Time{
 				Seconds: timeUpdatedSeconds,
 			},
-			Message: message,
-			Tags:    tagArray,
+			Message:    message,
+			Tags:       tagArray,
+			RowsCopied: rowsCopied,
 		}
 		workflow.WorkflowType = binlogdatapb.VReplicationWorkflowType_name[workflowType]
 		workflow.WorkflowSubType = binlogdatapb.VReplicationWorkflowSubType_name[workflowSubType]
@@ -709,6 +720,17 @@ ORDER BY
 		go func(ctx context.Context, workflow *vtctldatapb.Workflow) {
 			defer fetchLogsWG.Done()
 			fetchStreamLogs(ctx, workflow)
+
+			// The copy progress relies on the stream logs to tell when the
+			// copy phase of each stream started, so it has to wait for them.
+			copyProgress, err := s.getWorkflowCopyProgress(ctx, workflow)
+			if err != nil {
+				// The copy progress is only an estimate, so we don't fail the
+				// whole request if we cannot get it.
+				log.Warningf("Could not estimate the copy progress of workflow %s.%s: %v", req.Keyspace, workflow.Name, err)
+				return
+			}
+			workflow.CopyProgress = copyProgress
 		}(ctx, workflow)
 	}
 
@@ -798,3 +820,264 @@ func (s *Server) WorkflowUpdate(ctx context.Context, req *vtctldatapb.WorkflowUp
 	response.Details = details
 	return response, nil
 }
+
+// WorkflowSwitchTraffic is part of the vtctlservicepb.VtctldServer interface.
+// It switches the reads of the requested REPLICA and RDONLY tablet types,
+// and then the writes if PRIMARY is requested, of a MoveTables or Reshard
+// workflow. Traffic is switched back to the source keyspace when the
+// direction is backward, the writes with the reverse workflow.
+func (s *Server) WorkflowSwitchTraffic(ctx context.Context, req *vtctldatapb.WorkflowSwitchTrafficRequest) (*vtctldatapb.WorkflowSwitchTrafficResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.WorkflowSwitchTraffic")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("cells", strings.Join(req.Cells, ","))
+	span.Annotate("tablet_types", strings.Join(topoproto.MakeStringTypeList(req.TabletTypes), ","))
+	span.Annotate("direction", req.Direction)
+	span.Annotate("enable_reverse_replication", req.EnableReverseReplication)
+
+	direction := TrafficSwitchDirection(req.Direction)
+	if direction != DirectionForward && direction != DirectionBackward {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid direction %d", req.Direction)
+	}
+	timeout, ok, err := protoutil.DurationFromProto(req.Timeout)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid timeout")
+	}
+	if !ok {
+		timeout = defaultSwitchTrafficTimeout
+	}
+	maxLag, ok, err := protoutil.DurationFromProto(req.MaxReplicationLagAllowed)
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "invalid max replication lag allowed")
+	}
+	if !ok {
+		maxLag = defaultSwitchTrafficMaxReplicationLag
+	}
+
+	tabletTypes := req.TabletTypes
+	if len(tabletTypes) == 0 {
+		tabletTypes = []topodatapb.TabletType{topodatapb.TabletType_PRIMARY, topodatapb.TabletType_REPLICA, topodatapb.TabletType_RDONLY}
+	}
+	var (
+		readTypes    []topodatapb.TabletType
+		switchWrites bool
+	)
+	for _, tt := range tabletTypes {
+		switch tt {
+		case topodatapb.TabletType_PRIMARY:
+			switchWrites = true
+		case topodatapb.TabletType_REPLICA, topodatapb.TabletType_RDONLY:
+			readTypes = append(readTypes, tt)
+		default:
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid tablet type %v, must be one of PRIMARY, REPLICA or RDONLY", tt)
+		}
+	}
+
+	ts, startState, err := s.getWorkflowState(ctx, req.Keyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "the %s workflow does not exist in the %s keyspace", req.Workflow, req.Keyspace)
+	}
+	if ts.workflowType == binlogdatapb.VReplicationWorkflowType_Materialize || ts.externalCluster != "" {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "traffic cannot be switched for the %s workflow, which is not a MoveTables or Reshard workflow within the cluster", req.Workflow)
+	}
+	if direction == DirectionBackward {
+		for _, tt := range readTypes {
+			if tt == topodatapb.TabletType_REPLICA && len(startState.ReplicaCellsSwitched) == 0 ||
+				tt == topodatapb.TabletType_RDONLY && len(startState.RdonlyCellsSwitched) == 0 {
+				return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "requesting reversal of read traffic for %v tablets but their reads have not been switched", tt)
+			}
+		}
+		if switchWrites && !startState.WritesSwitched {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "requesting reversal of write traffic but writes have not been switched")
+		}
+	}
+
+	// The writes are switched back with the reverse workflow, which runs from
+	// the target keyspace to the source keyspace.
+	writeTs := ts
+	if direction == DirectionBackward && startState.WritesSwitched {
+		if writeTs, err = s.buildTrafficSwitcher(ctx, startState.SourceKeyspace, ts.reverseWorkflow); err != nil {
+			return nil, err
+		}
+	}
+	// Nothing needs to catch up if the writes were already switched in the
+	// requested direction.
+	if direction == DirectionForward && !startState.WritesSwitched || direction == DirectionBackward && startState.WritesSwitched {
+		reason, err := s.canSwitch(ctx, writeTs, maxLag)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot switch traffic for workflow %s at this time: %s", writeTs.workflow, reason)
+		}
+	}
+
+	if len(readTypes) > 0 {
+		if err := ts.switchReads(ctx, req.Cells, readTypes, direction); err != nil {
+			return nil, vterrors.Wrapf(err, "failed to switch reads")
+		}
+	}
+	if switchWrites {
+		if err := writeTs.switchWrites(ctx, timeout, req.EnableReverseReplication); err != nil {
+			return nil, vterrors.Wrapf(err, "failed to switch writes")
+		}
+	}
+
+	_, currentState, err := s.getWorkflowState(ctx, req.Keyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	resp := &vtctldatapb.WorkflowSwitchTrafficResponse{
+		StartState: stateSummary(startState),
+	}
+	if currentState != nil {
+		resp.CurrentState = stateSummary(currentState)
+	}
+	verb := "switched"
+	if direction == DirectionBackward {
+		verb = "reversed"
+	}
+	resp.Summary = fmt.Sprintf("Successfully %s traffic for the %s workflow in the %s keyspace", verb, req.Workflow, req.Keyspace)
+	return resp, nil
+}
+
+// WorkflowCancel is part of the vtctlservicepb.VtctldServer interface.
+// Before any traffic is switched, it deletes the streams of a workflow, and
+// for MoveTables the tables copied so far and the routing rules.
+func (s *Server) WorkflowCancel(ctx context.Context, req *vtctldatapb.WorkflowCancelRequest) (resp *vtctldatapb.WorkflowCancelResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.WorkflowCancel")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("keep_data", req.KeepData)
+	span.Annotate("keep_routing_rules", req.KeepRoutingRules)
+
+	ts, state, err := s.getWorkflowState(ctx, req.Keyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "the %s workflow does not exist in the %s keyspace", req.Workflow, req.Keyspace)
+	}
+	if state.WritesSwitched || len(state.ReplicaCellsSwitched) > 0 || len(state.RdonlyCellsSwitched) > 0 || len(state.ShardsAlreadySwitched) > 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot cancel the %s workflow because some or all of its read and write traffic was already switched", req.Workflow)
+	}
+	ts.keepRoutingRules = req.KeepRoutingRules || !ts.isMoveTables()
+
+	ctx, unlock, err := ts.lockKeyspaces(ctx, "WorkflowCancel")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(&err)
+
+	dropped := ""
+	if !req.KeepData && ts.migrationType == binlogdatapb.MigrationType_TABLES && ts.workflowType != binlogdatapb.VReplicationWorkflowType_Materialize {
+		if err = ts.removeTargetTables(ctx); err != nil {
+			return nil, err
+		}
+		if ts.externalCluster == "" {
+			if err = ts.dropSourceDeniedTables(ctx); err != nil {
+				return nil, err
+			}
+		}
+		dropped = fmt.Sprintf(", dropping %d tables from the %s keyspace", len(ts.tables), ts.targetKeyspace)
+	}
+	if err = ts.dropArtifacts(ctx); err != nil {
+		return nil, err
+	}
+	if err = s.ts.RebuildSrvVSchema(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return &vtctldatapb.WorkflowCancelResponse{
+		Summary: fmt.Sprintf("Successfully cancelled the %s workflow in the %s keyspace%s", req.Workflow, req.Keyspace, dropped),
+	}, nil
+}
+
+// WorkflowComplete is part of the vtctlservicepb.VtctldServer interface.
+// Once all the traffic of a workflow is switched, it deletes its streams and
+// those of its reverse workflow, and for MoveTables the tables left in the
+// source keyspace and the routing rules. The source shards of a Reshard are
+// left for DeleteShards to remove.
+func (s *Server) WorkflowComplete(ctx context.Context, req *vtctldatapb.WorkflowCompleteRequest) (resp *vtctldatapb.WorkflowCompleteResponse, err error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.WorkflowComplete")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.Keyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("keep_data", req.KeepData)
+	span.Annotate("keep_routing_rules", req.KeepRoutingRules)
+	span.Annotate("rename_tables", req.RenameTables)
+
+	ts, state, err := s.getWorkflowState(ctx, req.Keyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	if ts == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "the %s workflow does not exist in the %s keyspace", req.Workflow, req.Keyspace)
+	}
+	switchable := ts.workflowType != binlogdatapb.VReplicationWorkflowType_Materialize && ts.externalCluster == ""
+	if switchable {
+		switched := state.WritesSwitched && len(state.ReplicaCellsNotSwitched) == 0 && len(state.RdonlyCellsNotSwitched) == 0
+		if state.IsPartialMigration {
+			switched = len(state.ShardsAlreadySwitched) > 0 && len(state.ShardsNotYetSwitched) == 0
+		}
+		if !switched {
+			return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot complete the %s workflow because not all of its read and write traffic was switched", req.Workflow)
+		}
+	}
+	ts.keepRoutingRules = req.KeepRoutingRules || !ts.isMoveTables()
+
+	ctx, unlock, err := ts.lockKeyspaces(ctx, "WorkflowComplete")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(&err)
+
+	removed := ""
+	if switchable && !req.KeepData && ts.migrationType == binlogdatapb.MigrationType_TABLES {
+		removalType := DropTable
+		if req.RenameTables {
+			removalType = RenameTable
+		}
+		if err = ts.removeSourceTables(ctx, removalType); err != nil {
+			return nil, err
+		}
+		if err = ts.dropSourceDeniedTables(ctx); err != nil {
+			return nil, err
+		}
+		removed = fmt.Sprintf(", removing %d tables from the %s keyspace", len(ts.tables), ts.SourceKeyspaceName())
+	}
+	if ts.externalCluster != "" {
+		// The tables migrated from an external cluster are only added to the
+		// vschema of an unsharded target once the workflow is completed.
+		vschema, err := s.ts.GetVSchema(ctx, ts.targetKeyspace)
+		if err != nil {
+			return nil, err
+		}
+		if !vschema.Sharded {
+			if err := s.addTablesToVSchema(ctx, "", vschema, ts.tables, false); err != nil {
+				return nil, err
+			}
+			if err := s.ts.SaveVSchema(ctx, ts.targetKeyspace, vschema); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = ts.dropArtifacts(ctx); err != nil {
+		return nil, err
+	}
+	if err = s.ts.RebuildSrvVSchema(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return &vtctldatapb.WorkflowCompleteResponse{
+		Summary: fmt.Sprintf("Successfully completed the %s workflow in the %s keyspace%s", req.Workflow, req.Keyspace, removed),
+	}, nil
+}
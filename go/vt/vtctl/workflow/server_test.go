@@ -19,6 +19,9 @@ package workflow
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,15 +35,79 @@ import (
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
 
 type fakeTMC struct {
 	tmclient.TabletManagerClient
+
+	// mu protects the recorded calls, as the switching steps call the
+	// tablets of all the shards concurrently.
+	mu sync.Mutex
+
 	vrepQueriesByTablet map[string]map[string]*querypb.QueryResult
+	dbaQueriesByTablet  map[string]map[string]*querypb.QueryResult
+
+	// primaryPositions are the positions returned by PrimaryPosition, by
+	// tablet alias.
+	primaryPositions map[string]string
+	// waitForPosErr is returned by VReplicationWaitForPos.
+	waitForPosErr error
+	// waitedForPos records the VReplicationWaitForPos calls, as
+	// "<tablet alias>/<stream id>@<position>".
+	waitedForPos []string
+	// vrepQueries records the queries run by VReplicationExec.
+	vrepQueries []string
+	// dbaQueries records the queries run by ExecuteFetchAsDba.
+	dbaQueries []string
+	// onExecuteFetchAsDba, if set, is called by ExecuteFetchAsDba before it
+	// returns.
+	onExecuteFetchAsDba func(tablet *topodatapb.Tablet)
+	// tableSchemas are the table definitions returned by GetSchema, by
+	// table name.
+	tableSchemas map[string]*tabletmanagerdatapb.TableDefinition
+}
+
+func (fake *fakeTMC) GetSchema(ctx context.Context, tablet *topodatapb.Tablet, req *tabletmanagerdatapb.GetSchemaRequest) (*tabletmanagerdatapb.SchemaDefinition, error) {
+	sd := &tabletmanagerdatapb.SchemaDefinition{}
+	for _, table := range req.Tables {
+		if td, ok := fake.tableSchemas[table]; ok {
+			sd.TableDefinitions = append(sd.TableDefinitions, td)
+		}
+	}
+	return sd, nil
+}
+
+func (fake *fakeTMC) PrimaryPosition(ctx context.Context, tablet *topodatapb.Tablet) (string, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	alias := topoproto.TabletAliasString(tablet.Alias)
+	pos, ok := fake.primaryPositions[alias]
+	if !ok {
+		return "", fmt.Errorf("no primary position on fake for %s", alias)
+	}
+	return pos, nil
+}
+
+func (fake *fakeTMC) VReplicationWaitForPos(ctx context.Context, tablet *topodatapb.Tablet, id int32, pos string) error {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.waitedForPos = append(fake.waitedForPos, fmt.Sprintf("%s/%d@%s", topoproto.TabletAliasString(tablet.Alias), id, pos))
+	return fake.waitForPosErr
+}
+
+func (fake *fakeTMC) RefreshState(ctx context.Context, tablet *topodatapb.Tablet) error {
+	return nil
 }
 
 func (fake *fakeTMC) VReplicationExec(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.vrepQueries = append(fake.vrepQueries, query)
 	alias := topoproto.TabletAliasString(tablet.Alias)
 	tabletQueries, ok := fake.vrepQueriesByTablet[alias]
 	if !ok {
@@ -49,12 +116,41 @@ func (fake *fakeTMC) VReplicationExec(ctx context.Context, tablet *topodatapb.Ta
 
 	p3qr, ok := tabletQueries[query]
 	if !ok {
+		// Keys starting with a slash are regular expressions, for queries
+		// that are not fully deterministic.
+		for pattern, result := range tabletQueries {
+			if strings.HasPrefix(pattern, "/") && regexp.MustCompile(pattern[1:]).MatchString(query) {
+				return result, nil
+			}
+		}
 		return nil, fmt.Errorf("no result on fake for query %q on tablet %s", query, alias)
 	}
 
 	return p3qr, nil
 }
 
+func (fake *fakeTMC) ExecuteFetchAsDba(ctx context.Context, tablet *topodatapb.Tablet, usePool bool, req *tabletmanagerdatapb.ExecuteFetchAsDbaRequest) (*querypb.QueryResult, error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.dbaQueries = append(fake.dbaQueries, string(req.Query))
+	if fake.onExecuteFetchAsDba != nil {
+		fake.onExecuteFetchAsDba(tablet)
+	}
+	alias := topoproto.TabletAliasString(tablet.Alias)
+	tabletQueries, ok := fake.dbaQueriesByTablet[alias]
+	if !ok {
+		return nil, fmt.Errorf("no query map registered on fake for %s", alias)
+	}
+
+	p3qr, ok := tabletQueries[string(req.Query)]
+	if !ok {
+		return nil, fmt.Errorf("no result on fake for query %q on tablet %s", req.Query, alias)
+	}
+
+	return p3qr, nil
+}
+
 func TestCheckReshardingJournalExistsOnTablet(t *testing.T) {
 	t.Parallel()
 
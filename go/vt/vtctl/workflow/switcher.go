@@ -0,0 +1,1467 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/sqlescape"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/concurrency"
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/logutil"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/topotools"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication"
+	"vitess.io/vitess/go/vt/vttablet/tmclient"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// accessType specifies the type of access for a shard (allow/disallow writes).
+type accessType int
+
+const (
+	allowWrites = accessType(iota)
+	disallowWrites
+
+	// lockTablesCycles is the number of LOCK TABLES cycles to perform on the
+	// sources when switching writes.
+	lockTablesCycles = 2
+	// lockTablesCycleDelay is the time to wait between LOCK TABLES cycles on
+	// the sources when switching writes.
+	lockTablesCycleDelay = 100 * time.Millisecond
+
+	// shardTabletRefreshTimeout is how long to wait when refreshing the state
+	// of the tablets of a shard. It is shorter than the default lock TTL in
+	// etcd, so that the keyspace lock is not lost while refreshing.
+	shardTabletRefreshTimeout = 30 * time.Second
+
+	renameTableTemplate = "_%.59s_old" // limit table name to 64 characters
+
+	sqlDeleteWorkflow = "delete from _vt.vreplication where db_name = %s and workflow = %s"
+
+	// defaultSwitchTrafficTimeout is how long switching writes waits for the
+	// streams to catch up, when the request does not say.
+	defaultSwitchTrafficTimeout = 30 * time.Second
+	// defaultSwitchTrafficMaxReplicationLag is the maximum time since the
+	// streams last updated their position for traffic to be switched, when
+	// the request does not say.
+	defaultSwitchTrafficMaxReplicationLag = 30 * time.Second
+)
+
+// trafficSwitcher contains the metadata for switching the read and write
+// traffic of a MoveTables or Reshard workflow, and for cleaning it up once
+// it is completed or cancelled.
+type trafficSwitcher struct {
+	ts     *topo.Server
+	tmc    tmclient.TabletManagerClient
+	logger logutil.Logger
+
+	migrationType      binlogdatapb.MigrationType
+	isPartialMigration bool
+	workflow           string
+
+	// If frozen is true, the rest of the fields are not set.
+	frozen           bool
+	reverseWorkflow  string
+	id               int64
+	sources          map[string]*MigrationSource
+	targets          map[string]*MigrationTarget
+	sourceKeyspace   string
+	targetKeyspace   string
+	tables           []string
+	keepRoutingRules bool
+	sourceKSSchema   *vindexes.KeyspaceSchema
+	optCells         string // cells option passed to MoveTables/Reshard
+	optTabletTypes   string // tablet types option passed to MoveTables/Reshard
+	externalCluster  string
+	externalTopo     *topo.Server
+	sourceTimeZone   string
+	targetTimeZone   string
+	workflowType     binlogdatapb.VReplicationWorkflowType
+	workflowSubType  binlogdatapb.VReplicationWorkflowSubType
+}
+
+var _ ITrafficSwitcher = (*trafficSwitcher)(nil)
+
+func (ts *trafficSwitcher) TopoServer() *topo.Server                          { return ts.ts }
+func (ts *trafficSwitcher) TabletManagerClient() tmclient.TabletManagerClient { return ts.tmc }
+func (ts *trafficSwitcher) Logger() logutil.Logger                            { return ts.logger }
+func (ts *trafficSwitcher) VReplicationExec(ctx context.Context, alias *topodatapb.TabletAlias, query string) (*querypb.QueryResult, error) {
+	ti, err := ts.ts.GetTablet(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	return ts.tmc.VReplicationExec(ctx, ti.Tablet, query)
+}
+
+func (ts *trafficSwitcher) ExternalTopo() *topo.Server                { return ts.externalTopo }
+func (ts *trafficSwitcher) MigrationType() binlogdatapb.MigrationType { return ts.migrationType }
+func (ts *trafficSwitcher) ReverseWorkflowName() string               { return ts.reverseWorkflow }
+func (ts *trafficSwitcher) SourceKeyspaceName() string                { return ts.sourceKSSchema.Keyspace.Name }
+func (ts *trafficSwitcher) SourceKeyspaceSchema() *vindexes.KeyspaceSchema {
+	return ts.sourceKSSchema
+}
+func (ts *trafficSwitcher) Sources() map[string]*MigrationSource { return ts.sources }
+func (ts *trafficSwitcher) Tables() []string                     { return ts.tables }
+func (ts *trafficSwitcher) TargetKeyspaceName() string           { return ts.targetKeyspace }
+func (ts *trafficSwitcher) Targets() map[string]*MigrationTarget { return ts.targets }
+func (ts *trafficSwitcher) WorkflowName() string                 { return ts.workflow }
+func (ts *trafficSwitcher) SourceTimeZone() string               { return ts.sourceTimeZone }
+
+func (ts *trafficSwitcher) ForAllSources(f func(source *MigrationSource) error) error {
+	var wg sync.WaitGroup
+	allErrors := &concurrency.AllErrorRecorder{}
+	for _, source := range ts.sources {
+		wg.Add(1)
+		go func(source *MigrationSource) {
+			defer wg.Done()
+
+			if err := f(source); err != nil {
+				allErrors.RecordError(err)
+			}
+		}(source)
+	}
+	wg.Wait()
+	return allErrors.AggrError(vterrors.Aggregate)
+}
+
+func (ts *trafficSwitcher) ForAllTargets(f func(target *MigrationTarget) error) error {
+	var wg sync.WaitGroup
+	allErrors := &concurrency.AllErrorRecorder{}
+	for _, target := range ts.targets {
+		wg.Add(1)
+		go func(target *MigrationTarget) {
+			defer wg.Done()
+
+			if err := f(target); err != nil {
+				allErrors.RecordError(err)
+			}
+		}(target)
+	}
+	wg.Wait()
+	return allErrors.AggrError(vterrors.Aggregate)
+}
+
+func (ts *trafficSwitcher) ForAllUIDs(f func(target *MigrationTarget, uid int32) error) error {
+	var wg sync.WaitGroup
+	allErrors := &concurrency.AllErrorRecorder{}
+	for _, target := range ts.targets {
+		for uid := range target.Sources {
+			wg.Add(1)
+			go func(target *MigrationTarget, uid int32) {
+				defer wg.Done()
+
+				if err := f(target, uid); err != nil {
+					allErrors.RecordError(err)
+				}
+			}(target, uid)
+		}
+	}
+	wg.Wait()
+	return allErrors.AggrError(vterrors.Aggregate)
+}
+
+func (ts *trafficSwitcher) SourceShards() []*topo.ShardInfo {
+	shards := make([]*topo.ShardInfo, 0, len(ts.sources))
+	for _, source := range ts.sources {
+		shards = append(shards, source.GetShard())
+	}
+	return shards
+}
+
+func (ts *trafficSwitcher) TargetShards() []*topo.ShardInfo {
+	shards := make([]*topo.ShardInfo, 0, len(ts.targets))
+	for _, target := range ts.targets {
+		shards = append(shards, target.GetShard())
+	}
+	return shards
+}
+
+// buildTrafficSwitcher reads the streams of a workflow from the primaries of
+// the target keyspace, and the shards and primaries of its sources.
+func (s *Server) buildTrafficSwitcher(ctx context.Context, targetKeyspace, workflowName string) (*trafficSwitcher, error) {
+	tgtInfo, err := BuildTargets(ctx, s.ts, s.tmc, targetKeyspace, workflowName)
+	if err != nil {
+		return nil, err
+	}
+	targets := tgtInfo.Targets
+
+	ts := &trafficSwitcher{
+		ts:              s.ts,
+		tmc:             s.tmc,
+		logger:          logutil.NewConsoleLogger(),
+		workflow:        workflowName,
+		reverseWorkflow: ReverseWorkflowName(workflowName),
+		id:              HashStreams(targetKeyspace, targets),
+		targets:         targets,
+		sources:         make(map[string]*MigrationSource),
+		targetKeyspace:  targetKeyspace,
+		frozen:          tgtInfo.Frozen,
+		optCells:        tgtInfo.OptCells,
+		optTabletTypes:  tgtInfo.OptTabletTypes,
+		workflowType:    tgtInfo.WorkflowType,
+		workflowSubType: tgtInfo.WorkflowSubType,
+	}
+	log.Infof("Migration ID for workflow %s: %d", workflowName, ts.id)
+	sourceTopo := s.ts
+
+	for _, target := range targets {
+		for _, bls := range target.Sources {
+			if ts.sourceKeyspace == "" {
+				ts.sourceKeyspace = bls.Keyspace
+				ts.sourceTimeZone = bls.SourceTimeZone
+				ts.targetTimeZone = bls.TargetTimeZone
+				ts.externalCluster = bls.ExternalCluster
+				if ts.externalCluster != "" {
+					externalTopo, err := s.ts.OpenExternalVitessClusterServer(ctx, ts.externalCluster)
+					if err != nil {
+						return nil, err
+					}
+					sourceTopo = externalTopo
+					ts.externalTopo = externalTopo
+				}
+			} else if ts.sourceKeyspace != bls.Keyspace {
+				return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "source keyspaces are mismatched across streams: %v vs %v", ts.sourceKeyspace, bls.Keyspace)
+			}
+
+			var tables []string
+			for _, rule := range bls.Filter.Rules {
+				tables = append(tables, rule.Match)
+			}
+			sort.Strings(tables)
+			if ts.tables == nil {
+				ts.tables = tables
+			} else if !reflect.DeepEqual(ts.tables, tables) {
+				return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "table lists are mismatched across streams: %v vs %v", ts.tables, tables)
+			}
+
+			if _, ok := ts.sources[bls.Shard]; ok {
+				continue
+			}
+			sourcesi, err := sourceTopo.GetShard(ctx, bls.Keyspace, bls.Shard)
+			if err != nil {
+				return nil, err
+			}
+			sourcePrimary, err := sourceTopo.GetTablet(ctx, sourcesi.PrimaryAlias)
+			if err != nil {
+				return nil, err
+			}
+			ts.sources[bls.Shard] = NewMigrationSource(sourcesi, sourcePrimary)
+		}
+	}
+	if ts.sourceKeyspace != ts.targetKeyspace || ts.externalCluster != "" {
+		ts.migrationType = binlogdatapb.MigrationType_TABLES
+	} else {
+		ts.migrationType = binlogdatapb.MigrationType_SHARDS
+		for sourceShard := range ts.sources {
+			if _, ok := ts.targets[sourceShard]; ok {
+				// If shards are overlapping, then this is a table migration.
+				ts.migrationType = binlogdatapb.MigrationType_TABLES
+				break
+			}
+		}
+	}
+	vs, err := sourceTopo.GetVSchema(ctx, ts.sourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+	ts.sourceKSSchema, err = vindexes.BuildKeyspaceSchema(vs, ts.sourceKeyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceShards, targetShards []string
+	for _, si := range ts.SourceShards() {
+		sourceShards = append(sourceShards, si.ShardName())
+	}
+	for _, si := range ts.TargetShards() {
+		targetShards = append(targetShards, si.ShardName())
+	}
+	ts.isPartialMigration, err = ts.isPartialMoveTables(sourceShards, targetShards)
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// isMoveTables tells whether the workflow moves tables between keyspaces of
+// the cluster, and thus has routing rules.
+func (ts *trafficSwitcher) isMoveTables() bool {
+	return ts.migrationType == binlogdatapb.MigrationType_TABLES &&
+		ts.workflowType != binlogdatapb.VReplicationWorkflowType_Materialize &&
+		ts.externalCluster == ""
+}
+
+// lockKeyspaces locks the source and the target keyspaces of the workflow.
+// The source keyspace of a workflow from an external cluster is not locked.
+func (ts *trafficSwitcher) lockKeyspaces(ctx context.Context, action string) (context.Context, func(*error), error) {
+	var unlocks []func(*error)
+	unlockAll := func(err *error) {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i](err)
+		}
+	}
+	keyspaces := []string{ts.targetKeyspace}
+	if ts.externalCluster == "" && ts.sourceKeyspace != ts.targetKeyspace {
+		keyspaces = []string{ts.sourceKeyspace, ts.targetKeyspace}
+	}
+	for _, keyspace := range keyspaces {
+		lctx, unlock, err := ts.ts.LockKeyspace(ctx, keyspace, action)
+		if err != nil {
+			unlockAll(&err)
+			return nil, nil, err
+		}
+		ctx = lctx
+		unlocks = append(unlocks, unlock)
+	}
+	return ctx, unlockAll, nil
+}
+
+// isPartialMoveTables returns true if the workflow is a MoveTables between
+// the same number of shards with the same key ranges, which do not cover the
+// entire key range.
+func (ts *trafficSwitcher) isPartialMoveTables(sourceShards, targetShards []string) (bool, error) {
+	if ts.migrationType != binlogdatapb.MigrationType_TABLES {
+		return false, nil
+	}
+	skr, tkr, err := getSourceAndTargetKeyRanges(sourceShards, targetShards)
+	if err != nil {
+		return false, err
+	}
+	if key.KeyRangeIsComplete(skr) || key.KeyRangeIsComplete(tkr) || len(sourceShards) != len(targetShards) {
+		return false, nil
+	}
+	return key.KeyRangeEqual(skr, tkr), nil
+}
+
+func getSourceAndTargetKeyRanges(sourceShards, targetShards []string) (*topodatapb.KeyRange, *topodatapb.KeyRange, error) {
+	if len(sourceShards) == 0 || len(targetShards) == 0 {
+		return nil, nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "either source or target shards are missing")
+	}
+
+	getKeyRange := func(shard string) (*topodatapb.KeyRange, error) {
+		krs, err := key.ParseShardingSpec(shard)
+		if err != nil {
+			return nil, err
+		}
+		return krs[0], nil
+	}
+	// Sorting the shard names also sorts them in the ascending order of
+	// their key ranges.
+	getFullKeyRange := func(shards []string) (*topodatapb.KeyRange, error) {
+		sort.Strings(shards)
+		first, err := getKeyRange(shards[0])
+		if err != nil {
+			return nil, err
+		}
+		last, err := getKeyRange(shards[len(shards)-1])
+		if err != nil {
+			return nil, err
+		}
+		return &topodatapb.KeyRange{Start: first.Start, End: last.End}, nil
+	}
+
+	skr, err := getFullKeyRange(sourceShards)
+	if err != nil {
+		return nil, nil, err
+	}
+	tkr, err := getFullKeyRange(targetShards)
+	if err != nil {
+		return nil, nil, err
+	}
+	return skr, tkr, nil
+}
+
+// getWorkflowState returns the traffic switcher of a workflow and the state
+// of its traffic. It returns a nil switcher if the workflow does not exist.
+func (s *Server) getWorkflowState(ctx context.Context, targetKeyspace, workflowName string) (*trafficSwitcher, *State, error) {
+	ts, err := s.buildTrafficSwitcher(ctx, targetKeyspace, workflowName)
+	if err != nil {
+		if errors.Is(err, ErrNoStreams) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	state := &State{
+		Workflow:           workflowName,
+		SourceKeyspace:     ts.SourceKeyspaceName(),
+		TargetKeyspace:     targetKeyspace,
+		IsPartialMigration: ts.isPartialMigration,
+	}
+
+	// Writes are reversed with the _reverse workflow, whose source keyspace
+	// is the target keyspace of the forward workflow: the routing rules and
+	// the shards of that keyspace tell whether traffic was switched.
+	var (
+		reverse  bool
+		keyspace = targetKeyspace
+	)
+	if strings.HasSuffix(workflowName, reverseSuffix) {
+		reverse = true
+		keyspace = state.SourceKeyspace
+		workflowName = ReverseWorkflowName(workflowName)
+	}
+
+	if ts.migrationType == binlogdatapb.MigrationType_TABLES {
+		state.WorkflowType = TypeMoveTables
+
+		// We assume a consistent state, so only choose the routing rule of
+		// one table.
+		if len(ts.tables) == 0 {
+			return nil, nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "no tables in workflow %s.%s", keyspace, workflowName)
+		}
+		table := ts.tables[0]
+
+		if ts.isPartialMigration {
+			// Shard level traffic switching is all or nothing.
+			shardRoutingRules, err := s.ts.GetShardRoutingRules(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, rule := range shardRoutingRules.Rules {
+				if rule.ToKeyspace == ts.SourceKeyspaceName() {
+					state.ShardsNotYetSwitched = append(state.ShardsNotYetSwitched, rule.Shard)
+				} else {
+					state.ShardsAlreadySwitched = append(state.ShardsAlreadySwitched, rule.Shard)
+				}
+			}
+			return ts, state, nil
+		}
+
+		state.RdonlyCellsSwitched, state.RdonlyCellsNotSwitched, err = s.GetCellsWithTableReadsSwitched(ctx, keyspace, table, topodatapb.TabletType_RDONLY)
+		if err != nil {
+			return nil, nil, err
+		}
+		state.ReplicaCellsSwitched, state.ReplicaCellsNotSwitched, err = s.GetCellsWithTableReadsSwitched(ctx, keyspace, table, topodatapb.TabletType_REPLICA)
+		if err != nil {
+			return nil, nil, err
+		}
+		rules, err := topotools.GetRoutingRules(ctx, s.ts)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, table := range ts.tables {
+			// If a rule exists for the table and points to the target
+			// keyspace, writes have been switched.
+			rr := rules[table]
+			if len(rr) > 0 && rr[0] == fmt.Sprintf("%s.%s", keyspace, table) {
+				state.WritesSwitched = true
+				break
+			}
+		}
+		return ts, state, nil
+	}
+
+	state.WorkflowType = TypeReshard
+
+	// We assume a consistent state, so only choose one shard.
+	shard := ts.SourceShards()[0]
+	if reverse {
+		shard = ts.TargetShards()[0]
+	}
+	state.RdonlyCellsSwitched, state.RdonlyCellsNotSwitched, err = s.GetCellsWithShardReadsSwitched(ctx, keyspace, shard, topodatapb.TabletType_RDONLY)
+	if err != nil {
+		return nil, nil, err
+	}
+	state.ReplicaCellsSwitched, state.ReplicaCellsNotSwitched, err = s.GetCellsWithShardReadsSwitched(ctx, keyspace, shard, topodatapb.TabletType_REPLICA)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !shard.IsPrimaryServing {
+		state.WritesSwitched = true
+	}
+	return ts, state, nil
+}
+
+// stateSummary describes which traffic of a workflow was switched.
+func stateSummary(ws *State) string {
+	var stateInfo []string
+	if !ws.IsPartialMigration {
+		switch {
+		case len(ws.RdonlyCellsNotSwitched) == 0 && len(ws.ReplicaCellsNotSwitched) == 0 && len(ws.ReplicaCellsSwitched) > 0:
+			stateInfo = append(stateInfo, "All Reads Switched")
+		case len(ws.RdonlyCellsSwitched) == 0 && len(ws.ReplicaCellsSwitched) == 0:
+			stateInfo = append(stateInfo, "Reads Not Switched")
+		default:
+			stateInfo = append(stateInfo, "Reads partially switched")
+			switch {
+			case len(ws.ReplicaCellsNotSwitched) == 0:
+				stateInfo = append(stateInfo, "All Replica Reads Switched")
+			case len(ws.ReplicaCellsSwitched) == 0:
+				stateInfo = append(stateInfo, "Replica not switched")
+			default:
+				stateInfo = append(stateInfo, "Replica switched in cells: "+strings.Join(ws.ReplicaCellsSwitched, ","))
+			}
+			switch {
+			case len(ws.RdonlyCellsNotSwitched) == 0:
+				stateInfo = append(stateInfo, "All Rdonly Reads Switched")
+			case len(ws.RdonlyCellsSwitched) == 0:
+				stateInfo = append(stateInfo, "Rdonly not switched")
+			default:
+				stateInfo = append(stateInfo, "Rdonly switched in cells: "+strings.Join(ws.RdonlyCellsSwitched, ","))
+			}
+		}
+	}
+	switch {
+	case ws.WritesSwitched:
+		stateInfo = append(stateInfo, "Writes Switched")
+	case ws.IsPartialMigration:
+		// Reads and writes of a partial migration are switched together,
+		// shard by shard.
+		switch {
+		case len(ws.ShardsAlreadySwitched) > 0 && len(ws.ShardsNotYetSwitched) > 0:
+			shards := strings.Join(ws.ShardsAlreadySwitched, ",")
+			stateInfo = append(stateInfo, "Reads partially switched, for shards: "+shards, "Writes partially switched, for shards: "+shards)
+		case len(ws.ShardsAlreadySwitched) == 0:
+			stateInfo = append(stateInfo, "Reads Not Switched", "Writes Not Switched")
+		default:
+			stateInfo = append(stateInfo, "All Reads Switched", "All Writes Switched")
+		}
+	default:
+		stateInfo = append(stateInfo, "Writes Not Switched")
+	}
+	return strings.Join(stateInfo, ". ")
+}
+
+// canSwitch returns why the traffic of a workflow cannot be switched yet, or
+// an empty string if it can. Its streams must have finished copying and
+// caught up, and the tablets of both sides must refresh their state.
+func (s *Server) canSwitch(ctx context.Context, ts *trafficSwitcher, maxLag time.Duration) (string, error) {
+	var (
+		mu     sync.Mutex
+		reason string
+	)
+	setReason := func(r string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if reason == "" {
+			reason = r
+		}
+	}
+	now := time.Now().Unix()
+	err := ts.ForAllTargets(func(target *MigrationTarget) error {
+		primary := target.GetPrimary()
+		query := fmt.Sprintf("select id, state, message, time_updated from _vt.vreplication where db_name=%s and workflow=%s",
+			encodeString(primary.DbName()), encodeString(ts.workflow))
+		p3qr, err := s.tmc.VReplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return err
+		}
+		qr := sqltypes.Proto3ToResult(p3qr)
+		ids := make([]string, 0, len(qr.Rows))
+		for _, row := range qr.Rows {
+			if row[2].ToString() == Frozen {
+				setReason("workflow is frozen")
+				return nil
+			}
+			if row[1].ToString() == binlogplayer.BlpError {
+				setReason("workflow has errors")
+				return nil
+			}
+			timeUpdated, err := row[3].ToInt64()
+			if err != nil {
+				return err
+			}
+			if lag := time.Duration(now-timeUpdated) * time.Second; lag > maxLag {
+				setReason(fmt.Sprintf("replication lag %v is higher than allowed lag %v", lag, maxLag))
+				return nil
+			}
+			ids = append(ids, row[0].ToString())
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		query = fmt.Sprintf("select vrepl_id from _vt.copy_state where vrepl_id in (%s) limit 1", strings.Join(ids, ", "))
+		p3qr, err = s.tmc.VReplicationExec(ctx, primary.Tablet, query)
+		if err != nil {
+			return err
+		}
+		if len(p3qr.Rows) != 0 {
+			setReason("copy is still in progress")
+		}
+		return nil
+	})
+	if err != nil || reason != "" {
+		return reason, err
+	}
+
+	// Make sure the tablets on both sides are in good shape, as the same
+	// refresh is done while switching, and failing it then backs out.
+	rtbsCtx, cancel := context.WithTimeout(ctx, shardTabletRefreshTimeout)
+	defer cancel()
+	var refreshErrors []string
+	for _, side := range []struct {
+		name   string
+		shards []*topo.ShardInfo
+	}{{"source", ts.SourceShards()}, {"target", ts.TargetShards()}} {
+		for _, si := range side.shards {
+			if partial, partialDetails, err := topotools.RefreshTabletsByShard(rtbsCtx, s.ts, s.tmc, si, nil, ts.Logger()); err != nil || partial {
+				refreshErrors = append(refreshErrors, fmt.Sprintf("failed to successfully refresh all tablets in the %s/%s %s shard (%v):\n  %v",
+					si.Keyspace(), si.ShardName(), side.name, err, partialDetails))
+			}
+		}
+	}
+	if len(refreshErrors) > 0 {
+		return fmt.Sprintf("could not refresh all of the tablets involved in the operation:\n%s", strings.Join(refreshErrors, "\n")), nil
+	}
+	return "", nil
+}
+
+// validate checks that a MoveTables workflow covers all the shards of both
+// keyspaces and has no wildcard tables.
+func (ts *trafficSwitcher) validate(ctx context.Context) error {
+	if ts.migrationType != binlogdatapb.MigrationType_TABLES || ts.isPartialMigration {
+		return nil
+	}
+	sourceTopo := ts.ts
+	if ts.externalTopo != nil {
+		sourceTopo = ts.externalTopo
+	}
+	if err := CompareShards(ctx, ts.SourceKeyspaceName(), ts.SourceShards(), sourceTopo); err != nil {
+		return err
+	}
+	if err := CompareShards(ctx, ts.TargetKeyspaceName(), ts.TargetShards(), ts.ts); err != nil {
+		return err
+	}
+	for _, table := range ts.tables {
+		if strings.HasPrefix(table, "/") {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "cannot migrate streams with wild card table names: %v", table)
+		}
+	}
+	return nil
+}
+
+// switchReads switches the traffic of the given non-primary tablet types.
+func (ts *trafficSwitcher) switchReads(ctx context.Context, cells []string, servedTypes []topodatapb.TabletType, direction TrafficSwitchDirection) (err error) {
+	if err := ts.validate(ctx); err != nil {
+		return err
+	}
+
+	// If there are no rdonly tablets in the cells, switch them as well, so
+	// that the workflow does not look partially switched forever.
+	hasRdonly := false
+	for _, servedType := range servedTypes {
+		hasRdonly = hasRdonly || servedType == topodatapb.TabletType_RDONLY
+	}
+	if !hasRdonly {
+		rdonlyTabletsExist, err := topotools.DoCellsHaveRdonlyTablets(ctx, ts.ts, cells)
+		if err != nil {
+			return err
+		}
+		if !rdonlyTabletsExist {
+			servedTypes = append(servedTypes, topodatapb.TabletType_RDONLY)
+		}
+	}
+
+	// For reads, locking the source keyspace is sufficient.
+	ctx, unlock, lockErr := ts.ts.LockKeyspace(ctx, ts.SourceKeyspaceName(), "SwitchReads")
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock(&err)
+
+	if ts.migrationType == binlogdatapb.MigrationType_TABLES {
+		if ts.isPartialMigration {
+			// Partial migrations switch reads and writes of a shard together,
+			// with the shard routing rule created when switching writes.
+			return nil
+		}
+		return ts.switchTableReads(ctx, cells, servedTypes, direction)
+	}
+	if err := ts.switchShardReads(ctx, cells, servedTypes, direction); err != nil {
+		return err
+	}
+	if err := ts.ts.ValidateSrvKeyspace(ctx, ts.targetKeyspace, strings.Join(cells, ",")); err != nil {
+		return vterrors.Wrapf(err, "after switching shard reads, found SrvKeyspace for %s is corrupt in cell %s", ts.targetKeyspace, strings.Join(cells, ","))
+	}
+	return nil
+}
+
+func (ts *trafficSwitcher) switchTableReads(ctx context.Context, cells []string, servedTypes []topodatapb.TabletType, direction TrafficSwitchDirection) error {
+	rules, err := topotools.GetRoutingRules(ctx, ts.ts)
+	if err != nil {
+		return err
+	}
+	// The following rules were set up when the workflow was created:
+	//   table -> sourceKeyspace.table
+	//   targetKeyspace.table -> sourceKeyspace.table
+	// Tablet type specific rules redirect the traffic to the target when
+	// switching forward, and back to the source when switching backward.
+	toKeyspace := ts.TargetKeyspaceName()
+	if direction == DirectionBackward {
+		toKeyspace = ts.SourceKeyspaceName()
+	}
+	for _, servedType := range servedTypes {
+		tt := strings.ToLower(servedType.String())
+		for _, table := range ts.tables {
+			to := []string{toKeyspace + "." + table}
+			rules[table+"@"+tt] = to
+			rules[ts.TargetKeyspaceName()+"."+table+"@"+tt] = to
+			rules[ts.SourceKeyspaceName()+"."+table+"@"+tt] = to
+		}
+	}
+	if err := topotools.SaveRoutingRules(ctx, ts.ts, rules); err != nil {
+		return err
+	}
+	return ts.ts.RebuildSrvVSchema(ctx, cells)
+}
+
+func (ts *trafficSwitcher) switchShardReads(ctx context.Context, cells []string, servedTypes []topodatapb.TabletType, direction TrafficSwitchDirection) error {
+	fromShards, toShards := ts.SourceShards(), ts.TargetShards()
+	if direction == DirectionBackward {
+		fromShards, toShards = toShards, fromShards
+	}
+	if err := ts.ts.ValidateSrvKeyspace(ctx, ts.targetKeyspace, strings.Join(cells, ",")); err != nil {
+		return vterrors.Wrapf(err, "before switching shard reads, found SrvKeyspace for %s is corrupt in cell %s", ts.targetKeyspace, strings.Join(cells, ","))
+	}
+	for _, servedType := range servedTypes {
+		if err := topotools.UpdateShardRecords(ctx, ts.ts, ts.tmc, ts.SourceKeyspaceName(), fromShards, cells, servedType, true /* isFrom */, false /* clearSourceShards */, ts.Logger()); err != nil {
+			return err
+		}
+		if err := topotools.UpdateShardRecords(ctx, ts.ts, ts.tmc, ts.SourceKeyspaceName(), toShards, cells, servedType, false /* isFrom */, false /* clearSourceShards */, ts.Logger()); err != nil {
+			return err
+		}
+		if err := ts.ts.MigrateServedType(ctx, ts.SourceKeyspaceName(), toShards, fromShards, servedType, cells); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// switchWrites switches the primary traffic of the workflow. It stops the
+// writes on the sources, waits for the streams to catch up, creates the
+// reverse streams and the journals, and then routes the writes to the
+// targets. Once a journal exists, a failed switch can only be completed by
+// running it again.
+func (ts *trafficSwitcher) switchWrites(ctx context.Context, timeout time.Duration, reverseReplication bool) (err error) {
+	if ts.frozen {
+		ts.Logger().Warningf("Writes have already been switched for workflow %s, nothing to do here", ts.workflow)
+		return nil
+	}
+	if err := ts.validate(ctx); err != nil {
+		return err
+	}
+	if reverseReplication {
+		if err := ts.areTabletsAvailableToStreamFrom(ctx, ts.TargetKeyspaceName(), ts.TargetShards()); err != nil {
+			return err
+		}
+	}
+
+	// Both the source and the target keyspaces must be locked.
+	ctx, sourceUnlock, lockErr := ts.ts.LockKeyspace(ctx, ts.SourceKeyspaceName(), "SwitchWrites")
+	if lockErr != nil {
+		return lockErr
+	}
+	defer sourceUnlock(&err)
+	if ts.TargetKeyspaceName() != ts.SourceKeyspaceName() {
+		tctx, targetUnlock, lockErr := ts.ts.LockKeyspace(ctx, ts.TargetKeyspaceName(), "SwitchWrites")
+		if lockErr != nil {
+			return lockErr
+		}
+		ctx = tctx
+		defer targetUnlock(&err)
+	}
+
+	// If no journals exist, sourceWorkflows will be initialized by the
+	// stream migrator.
+	journalsExist, sourceWorkflows, err := ts.checkJournals(ctx)
+	if err != nil {
+		return err
+	}
+	if !journalsExist {
+		sm, err := BuildStreamMigrator(ctx, ts, false)
+		if err != nil {
+			return err
+		}
+		if sourceWorkflows, err = sm.StopStreams(ctx); err != nil {
+			ts.cancelMigration(ctx, sm)
+			return err
+		}
+		if err := ts.stopSourceWrites(ctx); err != nil {
+			ts.cancelMigration(ctx, sm)
+			return err
+		}
+		if ts.migrationType == binlogdatapb.MigrationType_TABLES {
+			// Locking the tables twice, with a pause in between, catches the
+			// writes that may have raced in between the deny list check of the
+			// tablets and the first lock. The locks are released as soon as
+			// they are acquired, when the connection is closed.
+			for cnt := 1; cnt <= lockTablesCycles; cnt++ {
+				if err := ts.executeLockTablesOnSource(ctx); err != nil {
+					ts.cancelMigration(ctx, sm)
+					return vterrors.Wrapf(err, "failed to execute LOCK TABLES (attempt %d of %d) on sources", cnt, lockTablesCycles)
+				}
+				time.Sleep(lockTablesCycleDelay)
+			}
+		}
+		if err := ts.waitForCatchup(ctx, timeout); err != nil {
+			ts.cancelMigration(ctx, sm)
+			return err
+		}
+		if err := sm.MigrateStreams(ctx); err != nil {
+			ts.cancelMigration(ctx, sm)
+			return err
+		}
+		if err := ts.createReverseVReplication(ctx); err != nil {
+			ts.cancelMigration(ctx, sm)
+			return err
+		}
+	} else {
+		ts.Logger().Infof("Journals were found. Completing the left over steps.")
+		// The positions are needed in case not all journals were created.
+		if err := ts.gatherPositions(ctx); err != nil {
+			return err
+		}
+	}
+
+	// This is the point of no return. Once a journal is created, traffic can
+	// be redirected to the target shards.
+	if err := ts.createJournals(ctx, sourceWorkflows); err != nil {
+		return err
+	}
+	if err := ts.allowTargetWrites(ctx); err != nil {
+		return err
+	}
+	if err := ts.changeRouting(ctx); err != nil {
+		return err
+	}
+	if err := StreamMigratorFinalize(ctx, ts, sourceWorkflows); err != nil {
+		return err
+	}
+	if reverseReplication {
+		if err := ts.startReverseVReplication(ctx); err != nil {
+			return err
+		}
+	}
+	return ts.freezeTargetVReplication(ctx)
+}
+
+// areTabletsAvailableToStreamFrom checks that every shard has a tablet that
+// the reverse streams can replicate from.
+func (ts *trafficSwitcher) areTabletsAvailableToStreamFrom(ctx context.Context, keyspace string, shards []*topo.ShardInfo) error {
+	var cells []string
+	if ts.optCells != "" {
+		cells = strings.Split(ts.optCells, ",")
+	}
+	tabletTypes := ts.optTabletTypes
+	if tabletTypes == "" {
+		tabletTypes = "PRIMARY,REPLICA"
+	}
+	return forAllShards(shards, func(si *topo.ShardInfo) error {
+		shardCells := cells
+		if shardCells == nil {
+			shardCells = []string{si.PrimaryAlias.Cell}
+		}
+		tp, err := discovery.NewTabletPicker(ctx, ts.ts, shardCells, si.PrimaryAlias.Cell, keyspace, si.ShardName(), tabletTypes, discovery.TabletPickerOptions{})
+		if err != nil {
+			return err
+		}
+		if len(tp.GetMatchingTablets(ctx)) == 0 {
+			return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "no tablet found to source data in keyspace %s, shard %s", keyspace, si.ShardName())
+		}
+		return nil
+	})
+}
+
+// checkJournals returns true if at least one journal has been created. If
+// so, it also returns the list of source workflows that need to be switched.
+func (ts *trafficSwitcher) checkJournals(ctx context.Context) (journalsExist bool, sourceWorkflows []string, err error) {
+	var (
+		ws = NewServer(ts.ts, ts.tmc)
+		mu sync.Mutex
+	)
+	err = ts.ForAllSources(func(source *MigrationSource) error {
+		mu.Lock()
+		defer mu.Unlock()
+		journal, exists, err := ws.CheckReshardingJournalExistsOnTablet(ctx, source.GetPrimary().Tablet, ts.id)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if journal.Id != 0 {
+				sourceWorkflows = journal.SourceWorkflows
+			}
+			source.Journaled = true
+			journalsExist = true
+		}
+		return nil
+	})
+	return journalsExist, sourceWorkflows, err
+}
+
+func (ts *trafficSwitcher) stopSourceWrites(ctx context.Context) error {
+	var err error
+	if ts.migrationType == binlogdatapb.MigrationType_TABLES {
+		err = ts.changeTableSourceWrites(ctx, disallowWrites)
+	} else {
+		err = ts.changeShardsAccess(ctx, ts.SourceKeyspaceName(), ts.SourceShards(), disallowWrites)
+	}
+	if err != nil {
+		return err
+	}
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		var err error
+		source.Position, err = ts.tmc.PrimaryPosition(ctx, source.GetPrimary().Tablet)
+		ts.Logger().Infof("Stopped source writes. Position for source %v:%v: %v", ts.SourceKeyspaceName(), source.GetShard().ShardName(), source.Position)
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) changeTableSourceWrites(ctx context.Context, access accessType) error {
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		if _, err := ts.ts.UpdateShardFields(ctx, ts.SourceKeyspaceName(), source.GetShard().ShardName(), func(si *topo.ShardInfo) error {
+			return si.UpdateSourceDeniedTables(ctx, topodatapb.TabletType_PRIMARY, nil, access == allowWrites /* remove */, ts.tables)
+		}); err != nil {
+			return err
+		}
+		rtbsCtx, cancel := context.WithTimeout(ctx, shardTabletRefreshTimeout)
+		defer cancel()
+		isPartial, partialDetails, err := topotools.RefreshTabletsByShard(rtbsCtx, ts.ts, ts.tmc, source.GetShard(), nil, ts.Logger())
+		if isPartial {
+			err = vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "failed to successfully refresh all tablets in the %s/%s source shard (%v):\n  %v",
+				source.GetShard().Keyspace(), source.GetShard().ShardName(), err, partialDetails)
+		}
+		return err
+	})
+}
+
+// executeLockTablesOnSource runs LOCK TABLES t1 READ, t2 READ, ... on the
+// primary of each source shard, with a non-pooled DBA connection. The
+// connection is closed once the statement returns, so the locks are released
+// right away.
+func (ts *trafficSwitcher) executeLockTablesOnSource(ctx context.Context) error {
+	if len(ts.tables) == 0 {
+		return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "no tables found in the source keyspace %v associated with the %s workflow", ts.SourceKeyspaceName(), ts.workflow)
+	}
+	locks := make([]string, len(ts.tables))
+	for i, table := range ts.tables {
+		locks[i] = sqlescape.EscapeID(table) + " READ"
+	}
+	lockStmt := "LOCK TABLES " + strings.Join(locks, ",")
+
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		primary := source.GetPrimary()
+		if primary == nil {
+			return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "no primary found for source shard %s", source.GetShard())
+		}
+		_, err := ts.executeFetchAsDba(ctx, primary.Tablet, lockStmt)
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) executeFetchAsDba(ctx context.Context, tablet *topodatapb.Tablet, query string) (*querypb.QueryResult, error) {
+	return ts.tmc.ExecuteFetchAsDba(ctx, tablet, false, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{
+		Query:        []byte(query),
+		DbName:       topoproto.TabletDbName(tablet),
+		MaxRows:      1,
+		ReloadSchema: true,
+	})
+}
+
+func (ts *trafficSwitcher) waitForCatchup(ctx context.Context, filteredReplicationWaitTime time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, filteredReplicationWaitTime)
+	defer cancel()
+	// The source writes are stopped: wait for all the target streams to
+	// catch up.
+	if err := ts.ForAllUIDs(func(target *MigrationTarget, uid int32) error {
+		bls := target.Sources[uid]
+		source := ts.sources[bls.Shard]
+		if err := ts.tmc.VReplicationWaitForPos(ctx, target.GetPrimary().Tablet, uid, source.Position); err != nil {
+			return err
+		}
+		if _, err := ts.tmc.VReplicationExec(ctx, target.GetPrimary().Tablet, binlogplayer.StopVReplication(uid, "stopped for cutover")); err != nil {
+			return vterrors.Wrapf(err, "failed to stop stream %d on %s for cutover", uid, target.GetPrimary().AliasString())
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	// All the targets have caught up: record their positions for setting up
+	// the reverse streams.
+	return ts.ForAllTargets(func(target *MigrationTarget) error {
+		var err error
+		target.Position, err = ts.tmc.PrimaryPosition(ctx, target.GetPrimary().Tablet)
+		return err
+	})
+}
+
+// cancelMigration undoes the steps of a switch of writes that failed before
+// the journals were created.
+func (ts *trafficSwitcher) cancelMigration(ctx context.Context, sm *StreamMigrator) {
+	var err error
+	if ts.migrationType == binlogdatapb.MigrationType_TABLES {
+		err = ts.changeTableSourceWrites(ctx, allowWrites)
+	} else {
+		err = ts.changeShardsAccess(ctx, ts.SourceKeyspaceName(), ts.SourceShards(), allowWrites)
+	}
+	if err != nil {
+		ts.Logger().Errorf("Cancel migration failed: %v", err)
+	}
+
+	sm.CancelMigration(ctx)
+
+	err = ts.ForAllTargets(func(target *MigrationTarget) error {
+		query := fmt.Sprintf("update _vt.vreplication set state='%s', message='' where db_name=%s and workflow=%s",
+			binlogplayer.BlpRunning, encodeString(target.GetPrimary().DbName()), encodeString(ts.workflow))
+		_, err := ts.tmc.VReplicationExec(ctx, target.GetPrimary().Tablet, query)
+		return err
+	})
+	if err != nil {
+		ts.Logger().Errorf("Cancel migration failed: could not restart vreplication: %v", err)
+	}
+
+	if err := ts.deleteReverseVReplication(ctx); err != nil {
+		ts.Logger().Errorf("Cancel migration failed: could not delete reverse vreplication entries: %v", err)
+	}
+}
+
+func (ts *trafficSwitcher) gatherPositions(ctx context.Context) error {
+	err := ts.ForAllSources(func(source *MigrationSource) error {
+		var err error
+		source.Position, err = ts.tmc.PrimaryPosition(ctx, source.GetPrimary().Tablet)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return ts.ForAllTargets(func(target *MigrationTarget) error {
+		var err error
+		target.Position, err = ts.tmc.PrimaryPosition(ctx, target.GetPrimary().Tablet)
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) createReverseVReplication(ctx context.Context) error {
+	if err := ts.deleteReverseVReplication(ctx); err != nil {
+		return err
+	}
+	return ts.ForAllUIDs(func(target *MigrationTarget, uid int32) error {
+		bls := target.Sources[uid]
+		source := ts.sources[bls.Shard]
+		reverseBls := &binlogdatapb.BinlogSource{
+			Keyspace:       ts.TargetKeyspaceName(),
+			Shard:          target.GetShard().ShardName(),
+			TabletType:     bls.TabletType,
+			Filter:         &binlogdatapb.Filter{},
+			OnDdl:          bls.OnDdl,
+			SourceTimeZone: bls.TargetTimeZone,
+			TargetTimeZone: bls.SourceTimeZone,
+		}
+
+		for _, rule := range bls.Filter.Rules {
+			if rule.Filter == "exclude" {
+				reverseBls.Filter.Rules = append(reverseBls.Filter.Rules, rule)
+				continue
+			}
+			var filter string
+			if strings.HasPrefix(rule.Match, "/") {
+				if ts.SourceKeyspaceSchema().Keyspace.Sharded {
+					filter = key.KeyRangeString(source.GetShard().KeyRange)
+				}
+			} else {
+				var inKeyrange string
+				if ts.SourceKeyspaceSchema().Keyspace.Sharded {
+					vtable, ok := ts.SourceKeyspaceSchema().Tables[rule.Match]
+					if !ok {
+						return vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "table %s not found in vschema", rule.Match)
+					}
+					// The primary vindex is assumed to be the best way to
+					// filter the rows, which may not always be true.
+					inKeyrange = fmt.Sprintf(" where in_keyrange(%s, '%s.%s', '%s')", sqlparser.String(vtable.ColumnVindexes[0].Columns[0]),
+						ts.SourceKeyspaceName(), vtable.ColumnVindexes[0].Name, key.KeyRangeString(source.GetShard().KeyRange))
+				}
+				filter = fmt.Sprintf("select * from %s%s", sqlescape.EscapeID(rule.Match), inKeyrange)
+			}
+			reverseBls.Filter.Rules = append(reverseBls.Filter.Rules, &binlogdatapb.Rule{
+				Match:  rule.Match,
+				Filter: filter,
+			})
+		}
+		_, err := ts.tmc.VReplicationExec(ctx, source.GetPrimary().Tablet,
+			binlogplayer.CreateVReplicationState(ts.reverseWorkflow, reverseBls, target.Position,
+				binlogplayer.BlpStopped, source.GetPrimary().DbName(), ts.workflowType, ts.workflowSubType))
+		if err != nil {
+			return err
+		}
+
+		// The reverse streams keep the cells and tablet types of the forward
+		// workflow, if it had any.
+		updateQuery := ts.getReverseVReplicationUpdateQuery(target.GetPrimary().Alias.Cell, source.GetPrimary().Alias.Cell, source.GetPrimary().DbName())
+		if updateQuery == "" {
+			return nil
+		}
+		_, err = ts.tmc.VReplicationExec(ctx, source.GetPrimary().Tablet, updateQuery)
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) getReverseVReplicationUpdateQuery(targetCell, sourceCell, dbname string) string {
+	// If the cell of the target is in the cells of the workflow, but the cell
+	// of the source is not, the reverse streams replicate from the source
+	// cell instead.
+	cells := ts.optCells
+	if cells != "" && targetCell != sourceCell && strings.Contains(cells+",", targetCell+",") &&
+		!strings.Contains(cells+",", sourceCell+",") {
+		cells = strings.Replace(cells, targetCell, sourceCell, 1)
+	}
+	if cells == "" && ts.optTabletTypes == "" {
+		return ""
+	}
+	return fmt.Sprintf("update _vt.vreplication set cell = %s, tablet_types = %s where workflow = %s and db_name = %s",
+		encodeString(cells), encodeString(ts.optTabletTypes), encodeString(ts.reverseWorkflow), encodeString(dbname))
+}
+
+func (ts *trafficSwitcher) deleteReverseVReplication(ctx context.Context) error {
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		return ts.deleteWorkflowStreams(ctx, source.GetPrimary().Tablet, ts.reverseWorkflow)
+	})
+}
+
+func (ts *trafficSwitcher) createJournals(ctx context.Context, sourceWorkflows []string) error {
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		if source.Journaled {
+			return nil
+		}
+		journal := &binlogdatapb.Journal{
+			Id:              ts.id,
+			MigrationType:   ts.migrationType,
+			Tables:          ts.tables,
+			LocalPosition:   source.Position,
+			SourceWorkflows: sourceWorkflows,
+		}
+		participantMap := make(map[string]bool)
+		for targetShard, target := range ts.targets {
+			for _, tsource := range target.Sources {
+				participantMap[tsource.Shard] = true
+			}
+			journal.ShardGtids = append(journal.ShardGtids, &binlogdatapb.ShardGtid{
+				Keyspace: ts.TargetKeyspaceName(),
+				Shard:    targetShard,
+				Gtid:     target.Position,
+			})
+		}
+		shards := make([]string, 0, len(participantMap))
+		for shard := range participantMap {
+			shards = append(shards, shard)
+		}
+		sort.Sort(vreplication.ShardSorter(shards))
+		for _, shard := range shards {
+			journal.Participants = append(journal.Participants, &binlogdatapb.KeyspaceShard{
+				Keyspace: source.GetShard().Keyspace(),
+				Shard:    shard,
+			})
+		}
+		ts.Logger().Infof("Creating journal: %v", journal)
+		statement := fmt.Sprintf("insert into _vt.resharding_journal (id, db_name, val) values (%v, %v, %v)",
+			ts.id, encodeString(source.GetPrimary().DbName()), encodeString(journal.String()))
+		_, err := ts.tmc.VReplicationExec(ctx, source.GetPrimary().Tablet, statement)
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) allowTargetWrites(ctx context.Context) error {
+	if ts.migrationType != binlogdatapb.MigrationType_TABLES {
+		return ts.changeShardsAccess(ctx, ts.TargetKeyspaceName(), ts.TargetShards(), allowWrites)
+	}
+	return ts.ForAllTargets(func(target *MigrationTarget) error {
+		if _, err := ts.ts.UpdateShardFields(ctx, ts.TargetKeyspaceName(), target.GetShard().ShardName(), func(si *topo.ShardInfo) error {
+			return si.UpdateSourceDeniedTables(ctx, topodatapb.TabletType_PRIMARY, nil, true, ts.tables)
+		}); err != nil {
+			return err
+		}
+		rtbsCtx, cancel := context.WithTimeout(ctx, shardTabletRefreshTimeout)
+		defer cancel()
+		_, _, err := topotools.RefreshTabletsByShard(rtbsCtx, ts.ts, ts.tmc, target.GetShard(), nil, ts.Logger())
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) changeRouting(ctx context.Context) error {
+	if ts.migrationType == binlogdatapb.MigrationType_TABLES {
+		return ts.changeWriteRoute(ctx)
+	}
+	return ts.changeShardRouting(ctx)
+}
+
+func (ts *trafficSwitcher) changeWriteRoute(ctx context.Context) error {
+	if ts.isPartialMigration {
+		srr, err := topotools.GetShardRoutingRules(ctx, ts.ts)
+		if err != nil {
+			return err
+		}
+		for _, si := range ts.SourceShards() {
+			delete(srr, fmt.Sprintf("%s.%s", ts.TargetKeyspaceName(), si.ShardName()))
+			srr[fmt.Sprintf("%s.%s", ts.SourceKeyspaceName(), si.ShardName())] = ts.TargetKeyspaceName()
+		}
+		if err := topotools.SaveShardRoutingRules(ctx, ts.ts, srr); err != nil {
+			return err
+		}
+	} else {
+		rules, err := topotools.GetRoutingRules(ctx, ts.ts)
+		if err != nil {
+			return err
+		}
+		for _, table := range ts.tables {
+			targetKsTable := fmt.Sprintf("%s.%s", ts.TargetKeyspaceName(), table)
+			sourceKsTable := fmt.Sprintf("%s.%s", ts.SourceKeyspaceName(), table)
+			delete(rules, targetKsTable)
+			rules[table] = []string{targetKsTable}
+			rules[sourceKsTable] = []string{targetKsTable}
+		}
+		if err := topotools.SaveRoutingRules(ctx, ts.ts, rules); err != nil {
+			return err
+		}
+	}
+	return ts.ts.RebuildSrvVSchema(ctx, nil)
+}
+
+func (ts *trafficSwitcher) changeShardRouting(ctx context.Context) error {
+	if err := ts.ts.ValidateSrvKeyspace(ctx, ts.TargetKeyspaceName(), ""); err != nil {
+		return vterrors.Wrapf(err, "before changing shard routes, found SrvKeyspace for %s is corrupt", ts.TargetKeyspaceName())
+	}
+	err := ts.ForAllSources(func(source *MigrationSource) error {
+		_, err := ts.ts.UpdateShardFields(ctx, ts.SourceKeyspaceName(), source.GetShard().ShardName(), func(si *topo.ShardInfo) error {
+			si.IsPrimaryServing = false
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = ts.ForAllTargets(func(target *MigrationTarget) error {
+		_, err := ts.ts.UpdateShardFields(ctx, ts.TargetKeyspaceName(), target.GetShard().ShardName(), func(si *topo.ShardInfo) error {
+			si.IsPrimaryServing = true
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := ts.ts.MigrateServedType(ctx, ts.TargetKeyspaceName(), ts.TargetShards(), ts.SourceShards(), topodatapb.TabletType_PRIMARY, nil); err != nil {
+		return err
+	}
+	if err := ts.ts.ValidateSrvKeyspace(ctx, ts.TargetKeyspaceName(), ""); err != nil {
+		return vterrors.Wrapf(err, "after changing shard routes, found SrvKeyspace for %s is corrupt", ts.TargetKeyspaceName())
+	}
+	return nil
+}
+
+func (ts *trafficSwitcher) startReverseVReplication(ctx context.Context) error {
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		query := fmt.Sprintf("update _vt.vreplication set state='%s', message='' where db_name=%s and workflow=%s",
+			binlogplayer.BlpRunning, encodeString(source.GetPrimary().DbName()), encodeString(ts.reverseWorkflow))
+		_, err := ts.tmc.VReplicationExec(ctx, source.GetPrimary().Tablet, query)
+		return err
+	})
+}
+
+// freezeTargetVReplication marks the target streams as frozen. If writes are
+// switched again after that, all the previous steps are skipped.
+func (ts *trafficSwitcher) freezeTargetVReplication(ctx context.Context) error {
+	return ts.ForAllTargets(func(target *MigrationTarget) error {
+		query := fmt.Sprintf("update _vt.vreplication set message = '%s' where db_name=%s and workflow=%s",
+			Frozen, encodeString(target.GetPrimary().DbName()), encodeString(ts.workflow))
+		_, err := ts.tmc.VReplicationExec(ctx, target.GetPrimary().Tablet, query)
+		return err
+	})
+}
+
+func (ts *trafficSwitcher) changeShardsAccess(ctx context.Context, keyspace string, shards []*topo.ShardInfo, access accessType) error {
+	if err := ts.ts.UpdateDisableQueryService(ctx, keyspace, shards, topodatapb.TabletType_PRIMARY, nil, access == disallowWrites /* disable */); err != nil {
+		return err
+	}
+	return forAllShards(shards, func(si *topo.ShardInfo) error {
+		ti, err := ts.ts.GetTablet(ctx, si.PrimaryAlias)
+		if err != nil {
+			return err
+		}
+		return ts.tmc.RefreshState(ctx, ti.Tablet)
+	})
+}
+
+// dropArtifacts deletes the streams of the workflow and of its reverse
+// workflow, and its routing rules unless they are kept.
+func (ts *trafficSwitcher) dropArtifacts(ctx context.Context) error {
+	if ts.externalCluster == "" {
+		if err := ts.ForAllSources(func(source *MigrationSource) error {
+			return ts.deleteWorkflowStreams(ctx, source.GetPrimary().Tablet, ts.reverseWorkflow)
+		}); err != nil {
+			return err
+		}
+	}
+	if err := ts.ForAllTargets(func(target *MigrationTarget) error {
+		return ts.deleteWorkflowStreams(ctx, target.GetPrimary().Tablet, ts.workflow)
+	}); err != nil {
+		return err
+	}
+	if ts.keepRoutingRules {
+		return nil
+	}
+	if err := ts.deleteRoutingRules(ctx); err != nil {
+		return err
+	}
+	return ts.deleteShardRoutingRules(ctx)
+}
+
+// deleteWorkflowStreams deletes the streams of a workflow from a tablet,
+// along with their vdiff data.
+func (ts *trafficSwitcher) deleteWorkflowStreams(ctx context.Context, tablet *topodatapb.Tablet, workflow string) error {
+	query := fmt.Sprintf(sqlDeleteWorkflow, encodeString(topoproto.TabletDbName(tablet)), encodeString(workflow))
+	if _, err := ts.tmc.VReplicationExec(ctx, tablet, query); err != nil {
+		return err
+	}
+
+	// The vdiff tables may not exist if no vdiff was ever run.
+	query = fmt.Sprintf(`delete from vd, vdt, vdl using _vt.vdiff as vd inner join _vt.vdiff_table as vdt on (vd.id = vdt.vdiff_id)
+		inner join _vt.vdiff_log as vdl on (vd.id = vdl.vdiff_id)
+		where vd.keyspace = %s and vd.workflow = %s`, encodeString(tablet.Keyspace), encodeString(workflow))
+	if _, err := ts.tmc.ExecuteFetchAsDba(ctx, tablet, false, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{
+		Query:   []byte(query),
+		MaxRows: 0,
+	}); err != nil {
+		if sqlErr, ok := err.(*mysql.SQLError); !ok || sqlErr.Num != mysql.ERNoSuchTable {
+			ts.Logger().Errorf("Error deleting vdiff data for %s.%s workflow: %v", tablet.Keyspace, workflow, err)
+		}
+	}
+	return nil
+}
+
+func (ts *trafficSwitcher) deleteRoutingRules(ctx context.Context) error {
+	rules, err := topotools.GetRoutingRules(ctx, ts.ts)
+	if err != nil {
+		return err
+	}
+	for _, table := range ts.tables {
+		for _, suffix := range []string{"", "@replica", "@rdonly"} {
+			delete(rules, table+suffix)
+			delete(rules, ts.TargetKeyspaceName()+"."+table+suffix)
+			delete(rules, ts.SourceKeyspaceName()+"."+table+suffix)
+		}
+	}
+	return topotools.SaveRoutingRules(ctx, ts.ts, rules)
+}
+
+func (ts *trafficSwitcher) deleteShardRoutingRules(ctx context.Context) error {
+	if !ts.isPartialMigration {
+		return nil
+	}
+	srr, err := topotools.GetShardRoutingRules(ctx, ts.ts)
+	if err != nil {
+		return err
+	}
+	for _, si := range ts.TargetShards() {
+		delete(srr, fmt.Sprintf("%s.%s", ts.targetKeyspace, si.ShardName()))
+	}
+	return topotools.SaveShardRoutingRules(ctx, ts.ts, srr)
+}
+
+func (ts *trafficSwitcher) dropSourceDeniedTables(ctx context.Context) error {
+	return ts.ForAllSources(func(source *MigrationSource) error {
+		if _, err := ts.ts.UpdateShardFields(ctx, ts.SourceKeyspaceName(), source.GetShard().ShardName(), func(si *topo.ShardInfo) error {
+			return si.UpdateSourceDeniedTables(ctx, topodatapb.TabletType_PRIMARY, nil, true, ts.tables)
+		}); err != nil {
+			return err
+		}
+		rtbsCtx, cancel := context.WithTimeout(ctx, shardTabletRefreshTimeout)
+		defer cancel()
+		_, _, err := topotools.RefreshTabletsByShard(rtbsCtx, ts.ts, ts.tmc, source.GetShard(), nil, ts.Logger())
+		return err
+	})
+}
+
+// removeSourceTables drops or renames the tables of the workflow on the
+// source primaries, and removes them from the source vschema.
+func (ts *trafficSwitcher) removeSourceTables(ctx context.Context, removalType TableRemovalType) error {
+	err := ts.ForAllSources(func(source *MigrationSource) error {
+		dbName := sqlescape.EscapeID(sqlescape.UnescapeID(source.GetPrimary().DbName()))
+		for _, tableName := range ts.tables {
+			query := fmt.Sprintf("drop table %s.%s", dbName, sqlescape.EscapeID(sqlescape.UnescapeID(tableName)))
+			if removalType == RenameTable {
+				query = fmt.Sprintf("rename table %s.%s TO %s.%s", dbName, sqlescape.EscapeID(sqlescape.UnescapeID(tableName)),
+					dbName, sqlescape.EscapeID(sqlescape.UnescapeID(fmt.Sprintf(renameTableTemplate, tableName))))
+			}
+			if _, err := ts.executeFetchAsDba(ctx, source.GetPrimary().Tablet, query); err != nil {
+				return vterrors.Wrapf(err, "%s: error removing table %s", source.GetPrimary().AliasString(), tableName)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ts.dropParticipatingTablesFromKeyspace(ctx, ts.SourceKeyspaceName())
+}
+
+// removeTargetTables drops the tables of the workflow on the target
+// primaries, and removes them from the target vschema.
+func (ts *trafficSwitcher) removeTargetTables(ctx context.Context) error {
+	err := ts.ForAllTargets(func(target *MigrationTarget) error {
+		dbName := sqlescape.EscapeID(sqlescape.UnescapeID(target.GetPrimary().DbName()))
+		for _, tableName := range ts.tables {
+			query := fmt.Sprintf("drop table %s.%s", dbName, sqlescape.EscapeID(sqlescape.UnescapeID(tableName)))
+			if _, err := ts.executeFetchAsDba(ctx, target.GetPrimary().Tablet, query); err != nil {
+				return vterrors.Wrapf(err, "%s: error removing table %s", target.GetPrimary().AliasString(), tableName)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return ts.dropParticipatingTablesFromKeyspace(ctx, ts.TargetKeyspaceName())
+}
+
+func (ts *trafficSwitcher) dropParticipatingTablesFromKeyspace(ctx context.Context, keyspace string) error {
+	vschema, err := ts.ts.GetVSchema(ctx, keyspace)
+	if err != nil {
+		return err
+	}
+	// The vschema entries of a sharded target keyspace are not created by
+	// the workflow, as it cannot know which vindexes to use, so they are not
+	// deleted either.
+	if vschema.Sharded && keyspace == ts.TargetKeyspaceName() {
+		return nil
+	}
+	for _, tableName := range ts.tables {
+		delete(vschema.Tables, tableName)
+	}
+	return ts.ts.SaveVSchema(ctx, keyspace, vschema)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateSummary(t *testing.T) {
+	tcs := []struct {
+		name  string
+		state *State
+		want  string
+	}{
+		{
+			name:  "nothing switched",
+			state: &State{ReplicaCellsNotSwitched: []string{"zone1"}, RdonlyCellsNotSwitched: []string{"zone1"}},
+			want:  "Reads Not Switched. Writes Not Switched",
+		},
+		{
+			name: "replica reads switched in one cell",
+			state: &State{
+				ReplicaCellsSwitched:    []string{"zone1"},
+				ReplicaCellsNotSwitched: []string{"zone2"},
+				RdonlyCellsNotSwitched:  []string{"zone1", "zone2"},
+			},
+			want: "Reads partially switched. Replica switched in cells: zone1. Rdonly not switched. Writes Not Switched",
+		},
+		{
+			name:  "all switched",
+			state: &State{ReplicaCellsSwitched: []string{"zone1"}, RdonlyCellsSwitched: []string{"zone1"}, WritesSwitched: true},
+			want:  "All Reads Switched. Writes Switched",
+		},
+		{
+			name: "partial migration",
+			state: &State{
+				IsPartialMigration:    true,
+				ShardsAlreadySwitched: []string{"-80"},
+				ShardsNotYetSwitched:  []string{"80-"},
+			},
+			want: "Reads partially switched, for shards: -80. Writes partially switched, for shards: -80",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, stateSummary(tc.state))
+		})
+	}
+}
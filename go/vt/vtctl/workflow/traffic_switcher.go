@@ -350,12 +350,18 @@ func HashStreams(targetKeyspace string, targets map[string]*MigrationTarget) int
 		}
 	}
 
-	sort.Strings(expanded)
+	return hashStrings(targetKeyspace, expanded)
+}
+
+// hashStrings hashes the "shard:id" strings of the streams of a workflow,
+// which it sorts in place.
+func hashStrings(targetKeyspace string, streams []string) int64 {
+	sort.Strings(streams)
 
 	hasher := fnv.New64()
 	hasher.Write([]byte(targetKeyspace))
 
-	for _, s := range expanded {
+	for _, s := range streams {
 		hasher.Write([]byte(s))
 	}
 
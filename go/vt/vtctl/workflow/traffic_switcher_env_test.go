@@ -0,0 +1,266 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/topotools"
+	"vitess.io/vitess/go/vt/vtctl/grpcvtctldserver/testutil"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+// The traffic switcher env moves table t1 of the unsharded sourceks keyspace
+// to the -80 and 80- shards of the targetks keyspace, with the wf workflow.
+const (
+	tsSourceKeyspace = "sourceks"
+	tsTargetKeyspace = "targetks"
+	tsWorkflow       = "wf"
+	tsReverse        = "wf_reverse"
+	tsSourcePrimary  = "zone1-0000000100"
+	tsPosition       = "MySQL56/00000000-0000-0000-0000-000000000001:1-10"
+)
+
+var tsTargetPrimaries = map[string]string{
+	"-80": "zone1-0000000200",
+	"80-": "zone1-0000000210",
+}
+
+type testTrafficSwitcherEnv struct {
+	ws  *Server
+	ts  *topo.Server
+	tmc *fakeTMC
+}
+
+// newTestTrafficSwitcherEnv creates the keyspaces of the wf workflow, with
+// its streams on the target primaries and the streams of its reverse
+// workflow on the source primary, and the routing rules left by
+// MoveTablesCreate. The tablets answer all the queries of switching the
+// traffic in either direction, and of cancelling or completing the workflow.
+func newTestTrafficSwitcherEnv(ctx context.Context, t *testing.T) *testTrafficSwitcherEnv {
+	t.Helper()
+
+	ts := memorytopo.NewServer("zone1")
+	testutil.AddTablets(ctx, t, ts, &testutil.AddTabletOptions{AlsoSetShardPrimary: true},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 100},
+			Keyspace: tsSourceKeyspace,
+			Shard:    "0",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 200},
+			Keyspace: tsTargetKeyspace,
+			Shard:    "-80",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+		&topodatapb.Tablet{
+			Alias:    &topodatapb.TabletAlias{Cell: "zone1", Uid: 210},
+			Keyspace: tsTargetKeyspace,
+			Shard:    "80-",
+			Type:     topodatapb.TabletType_PRIMARY,
+		},
+	)
+	require.NoError(t, ts.SaveVSchema(ctx, tsSourceKeyspace, &vschemapb.Keyspace{
+		Tables: map[string]*vschemapb.Table{"t1": {}},
+	}))
+	require.NoError(t, ts.SaveVSchema(ctx, tsTargetKeyspace, &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "c1", Name: "hash"}}},
+		},
+	}))
+
+	env := &testTrafficSwitcherEnv{
+		ts: ts,
+		tmc: &fakeTMC{
+			vrepQueriesByTablet: make(map[string]map[string]*querypb.QueryResult),
+			dbaQueriesByTablet:  make(map[string]map[string]*querypb.QueryResult),
+			primaryPositions:    make(map[string]string),
+		},
+	}
+	env.ws = NewServer(ts, env.tmc)
+
+	now := time.Now().Unix()
+	var reverseStreams []*binlogdatapb.BinlogSource
+	for _, shard := range []string{"-80", "80-"} {
+		alias := tsTargetPrimaries[shard]
+		env.addTabletQueries(alias, tsTargetKeyspace, tsWorkflow, now, []*binlogdatapb.BinlogSource{{
+			Keyspace: tsSourceKeyspace,
+			Shard:    "0",
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t1",
+					Filter: fmt.Sprintf("select * from t1 where in_keyrange(c1, '%s.hash', '%s')", tsTargetKeyspace, shard),
+				}},
+			},
+		}})
+		reverseStreams = append(reverseStreams, &binlogdatapb.BinlogSource{
+			Keyspace: tsTargetKeyspace,
+			Shard:    shard,
+			Filter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{Match: "t1", Filter: "select * from `t1`"}},
+			},
+		})
+	}
+	env.addTabletQueries(tsSourcePrimary, tsSourceKeyspace, tsReverse, now, reverseStreams)
+
+	env.saveRoutingRules(ctx, t, tsSourceKeyspace, tsSourceKeyspace)
+	return env
+}
+
+// addTabletQueries adds the queries of a primary holding the streams of a
+// workflow.
+func (env *testTrafficSwitcherEnv) addTabletQueries(alias, keyspace, workflow string, timeUpdated int64, streams []*binlogdatapb.BinlogSource) {
+	dbName := "vt_" + keyspace
+	// The tablet is the target of the streams of the workflow, and the
+	// source of the streams of the workflow in the opposite direction, which
+	// recreate the streams of the workflow on it.
+	queries := map[string]*querypb.QueryResult{
+		streamsQuery(keyspace, workflow): streamsResult("", streams),
+		fmt.Sprintf("update _vt.vreplication set message = 'FROZEN' where db_name='%s' and workflow='%s'", dbName, workflow):          {},
+		fmt.Sprintf("delete from _vt.vreplication where db_name = '%s' and workflow = '%s'", dbName, workflow):                        {},
+		fmt.Sprintf("update _vt.vreplication set state='Running', message='' where db_name='%s' and workflow='%s'", dbName, workflow): {},
+		fmt.Sprintf(`/insert into _vt.vreplication \(workflow.*values \('%s',`, workflow):                                             {},
+		"/select val from _vt.resharding_journal where id=":                                                                           {},
+		"/insert into _vt.resharding_journal":                                                                                         {},
+	}
+	var (
+		ids  []string
+		rows []string
+	)
+	for i := range streams {
+		id := int32(i + 1)
+		ids = append(ids, fmt.Sprint(id))
+		rows = append(rows, fmt.Sprintf("%d|Running||%d", id, timeUpdated))
+		queries[binlogplayer.StopVReplication(id, "stopped for cutover")] = &querypb.QueryResult{RowsAffected: 1}
+	}
+	queries[fmt.Sprintf("select id, state, message, time_updated from _vt.vreplication where db_name='%s' and workflow='%s'", dbName, workflow)] = sqltypes.ResultToProto3(
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|state|message|time_updated", "int64|varbinary|varbinary|int64"), rows...))
+	queries[fmt.Sprintf("select vrepl_id from _vt.copy_state where vrepl_id in (%s) limit 1", strings.Join(ids, ", "))] = &querypb.QueryResult{}
+	env.tmc.vrepQueriesByTablet[alias] = queries
+
+	env.tmc.dbaQueriesByTablet[alias] = map[string]*querypb.QueryResult{
+		"LOCK TABLES `t1` READ":                     {},
+		fmt.Sprintf("drop table `%s`.`t1`", dbName): {},
+	}
+	env.tmc.primaryPositions[alias] = tsPosition
+}
+
+// freeze marks the streams of a workflow as frozen, as switching its writes
+// does.
+func (env *testTrafficSwitcherEnv) freeze(alias, keyspace, workflow string) {
+	query := streamsQuery(keyspace, workflow)
+	qr := sqltypes.Proto3ToResult(env.tmc.vrepQueriesByTablet[alias][query])
+	for _, row := range qr.Rows {
+		row[2] = sqltypes.NewVarBinary(Frozen)
+	}
+	env.tmc.vrepQueriesByTablet[alias][query] = sqltypes.ResultToProto3(qr)
+}
+
+// saveRoutingRules saves the routing rules of table t1 with its reads and
+// its writes routed to the given keyspaces.
+func (env *testTrafficSwitcherEnv) saveRoutingRules(ctx context.Context, t *testing.T, readsTo, writesTo string) {
+	t.Helper()
+	require.NoError(t, topotools.SaveRoutingRules(ctx, env.ts, routingRulesTo(readsTo, writesTo)))
+	require.NoError(t, env.ts.RebuildSrvVSchema(ctx, nil))
+}
+
+// routingRulesTo returns the routing rules of table t1 that the workflow
+// saves when its reads and its writes are routed to the given keyspaces.
+func routingRulesTo(readsTo, writesTo string) map[string][]string {
+	rules := make(map[string][]string)
+	for _, tt := range []string{"@replica", "@rdonly"} {
+		for _, from := range []string{"t1", tsTargetKeyspace + ".t1", tsSourceKeyspace + ".t1"} {
+			rules[from+tt] = []string{readsTo + ".t1"}
+		}
+	}
+	// The keyspace the writes are routed to needs no rule of its own.
+	rules["t1"] = []string{writesTo + ".t1"}
+	if writesTo == tsSourceKeyspace {
+		rules[tsTargetKeyspace+".t1"] = []string{tsSourceKeyspace + ".t1"}
+	} else {
+		rules[tsSourceKeyspace+".t1"] = []string{tsTargetKeyspace + ".t1"}
+	}
+	return rules
+}
+
+func (env *testTrafficSwitcherEnv) routingRules(ctx context.Context, t *testing.T) map[string][]string {
+	t.Helper()
+	rules, err := topotools.GetRoutingRules(ctx, env.ts)
+	require.NoError(t, err)
+	return rules
+}
+
+// deniedTables returns the tables whose writes are denied on the primary of
+// a shard.
+func (env *testTrafficSwitcherEnv) deniedTables(ctx context.Context, t *testing.T, keyspace, shard string) []string {
+	t.Helper()
+	si, err := env.ts.GetShard(ctx, keyspace, shard)
+	require.NoError(t, err)
+	return si.GetTabletControl(topodatapb.TabletType_PRIMARY).GetDeniedTables()
+}
+
+// vrepQueryCount returns the number of queries run by VReplicationExec that
+// match a regular expression.
+func (env *testTrafficSwitcherEnv) vrepQueryCount(pattern string) int {
+	re := regexp.MustCompile(pattern)
+	count := 0
+	for _, query := range env.tmc.vrepQueries {
+		if re.MatchString(query) {
+			count++
+		}
+	}
+	return count
+}
+
+func streamsQuery(keyspace, workflow string) string {
+	return fmt.Sprintf("select id, source, message, cell, tablet_types, workflow_type, workflow_sub_type, defer_secondary_keys from _vt.vreplication where workflow='%s' and db_name='vt_%s'", workflow, keyspace)
+}
+
+// streamsResult returns the MoveTables streams read by BuildTargets, with
+// ids from 1.
+func streamsResult(message string, streams []*binlogdatapb.BinlogSource) *querypb.QueryResult {
+	var rows []string
+	for i, bls := range streams {
+		rows = append(rows, fmt.Sprintf("%d|%s|%s|||%d|%d|0", i+1, bls.String(), message,
+			binlogdatapb.VReplicationWorkflowType_MoveTables, binlogdatapb.VReplicationWorkflowSubType_None))
+	}
+	return sqltypes.ResultToProto3(sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id|source|message|cell|tablet_types|workflow_type|workflow_sub_type|defer_secondary_keys",
+			"int64|varbinary|varbinary|varbinary|varbinary|int64|int64|int64"),
+		rows...,
+	))
+}
@@ -17,11 +17,22 @@ limitations under the License.
 package workflow
 
 import (
+	"context"
+	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
 )
 
 type testTrafficSwitcher struct {
@@ -56,3 +67,182 @@ func TestReverseWorkflowName(t *testing.T) {
 		assert.Equal(t, test.out, got)
 	}
 }
+
+func TestWorkflowSwitchTraffic(t *testing.T) {
+	ctx := context.Background()
+	env := newTestTrafficSwitcherEnv(ctx, t)
+
+	resp, err := env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:    tsTargetKeyspace,
+		Workflow:    tsWorkflow,
+		TabletTypes: []topodatapb.TabletType{topodatapb.TabletType_REPLICA, topodatapb.TabletType_RDONLY},
+		Direction:   int32(DirectionForward),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Reads Not Switched. Writes Not Switched", resp.StartState)
+	assert.Equal(t, "All Reads Switched. Writes Not Switched", resp.CurrentState)
+	assert.Equal(t, routingRulesTo(tsTargetKeyspace, tsSourceKeyspace), env.routingRules(ctx, t))
+	assert.Empty(t, env.tmc.waitedForPos)
+
+	resp, err = env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:                 tsTargetKeyspace,
+		Workflow:                 tsWorkflow,
+		TabletTypes:              []topodatapb.TabletType{topodatapb.TabletType_PRIMARY},
+		Direction:                int32(DirectionForward),
+		EnableReverseReplication: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully switched traffic for the wf workflow in the targetks keyspace", resp.Summary)
+	assert.Equal(t, "All Reads Switched. Writes Switched", resp.CurrentState)
+	assert.Equal(t, routingRulesTo(tsTargetKeyspace, tsTargetKeyspace), env.routingRules(ctx, t))
+
+	// The target streams caught up with the source primary before they were
+	// stopped and frozen, and the reverse streams were created from the
+	// positions of the target primaries, journaled and started.
+	assert.ElementsMatch(t, []string{"zone1-0000000200/1@" + tsPosition, "zone1-0000000210/1@" + tsPosition}, env.tmc.waitedForPos)
+	assert.Equal(t, 2, env.vrepQueryCount(`^update _vt.vreplication set state='Stopped', message='stopped for cutover' where id=1$`))
+	assert.Equal(t, 2, env.vrepQueryCount(`^insert into _vt.vreplication \(workflow.*values \('wf_reverse', .*`+regexp.QuoteMeta(tsPosition)+`.*'Stopped', 'vt_sourceks', 1, 0\)$`))
+	assert.Equal(t, 1, env.vrepQueryCount(`^insert into _vt.resharding_journal .*'vt_sourceks'`))
+	assert.Equal(t, 1, env.vrepQueryCount(`^update _vt.vreplication set state='Running', message='' where db_name='vt_sourceks' and workflow='wf_reverse'$`))
+	assert.Equal(t, 2, env.vrepQueryCount(`^update _vt.vreplication set message = 'FROZEN' where db_name='vt_targetks' and workflow='wf'$`))
+	assert.Equal(t, 2, countQueries(env.tmc.dbaQueries, "LOCK TABLES `t1` READ"))
+
+	// The writes of the source stay denied, for the reverse streams to be
+	// the only writers.
+	assert.Equal(t, []string{"t1"}, env.deniedTables(ctx, t, tsSourceKeyspace, "0"))
+	assert.Empty(t, env.deniedTables(ctx, t, tsTargetKeyspace, "-80"))
+	assert.Empty(t, env.deniedTables(ctx, t, tsTargetKeyspace, "80-"))
+}
+
+func TestWorkflowSwitchTrafficBackward(t *testing.T) {
+	ctx := context.Background()
+	env := newTestTrafficSwitcherEnv(ctx, t)
+	env.saveRoutingRules(ctx, t, tsTargetKeyspace, tsTargetKeyspace)
+	for _, alias := range tsTargetPrimaries {
+		env.freeze(alias, tsTargetKeyspace, tsWorkflow)
+	}
+	_, err := env.ts.UpdateShardFields(ctx, tsSourceKeyspace, "0", func(si *topo.ShardInfo) error {
+		return si.UpdateSourceDeniedTables(ctx, topodatapb.TabletType_PRIMARY, nil, false, []string{"t1"})
+	})
+	require.NoError(t, err)
+
+	resp, err := env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:                 tsTargetKeyspace,
+		Workflow:                 tsWorkflow,
+		Direction:                int32(DirectionBackward),
+		EnableReverseReplication: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully reversed traffic for the wf workflow in the targetks keyspace", resp.Summary)
+	assert.Equal(t, "All Reads Switched. Writes Switched", resp.StartState)
+	assert.Equal(t, "Reads Not Switched. Writes Not Switched", resp.CurrentState)
+	assert.Equal(t, routingRulesTo(tsSourceKeyspace, tsSourceKeyspace), env.routingRules(ctx, t))
+
+	// The writes are switched back with the reverse workflow: its streams on
+	// the source primary catch up and are frozen, and the streams of the
+	// workflow are recreated on the target primaries and started.
+	assert.ElementsMatch(t, []string{"zone1-0000000100/1@" + tsPosition, "zone1-0000000100/2@" + tsPosition}, env.tmc.waitedForPos)
+	assert.Equal(t, 2, env.vrepQueryCount(`^insert into _vt.vreplication \(workflow.*values \('wf', .*in_keyrange.*'Stopped', 'vt_targetks', 1, 0\)$`))
+	assert.Equal(t, 2, env.vrepQueryCount(`^insert into _vt.resharding_journal .*'vt_targetks'`))
+	assert.Equal(t, 2, env.vrepQueryCount(`^update _vt.vreplication set state='Running', message='' where db_name='vt_targetks' and workflow='wf'$`))
+	assert.Equal(t, 1, env.vrepQueryCount(`^update _vt.vreplication set message = 'FROZEN' where db_name='vt_sourceks' and workflow='wf_reverse'$`))
+
+	assert.Empty(t, env.deniedTables(ctx, t, tsSourceKeyspace, "0"))
+	assert.Equal(t, []string{"t1"}, env.deniedTables(ctx, t, tsTargetKeyspace, "-80"))
+	assert.Equal(t, []string{"t1"}, env.deniedTables(ctx, t, tsTargetKeyspace, "80-"))
+}
+
+func TestWorkflowSwitchTrafficErrors(t *testing.T) {
+	ctx := context.Background()
+	env := newTestTrafficSwitcherEnv(ctx, t)
+
+	_, err := env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:  tsTargetKeyspace,
+		Workflow:  tsWorkflow,
+		Direction: int32(DirectionBackward),
+	})
+	assert.EqualError(t, err, "requesting reversal of read traffic for REPLICA tablets but their reads have not been switched")
+
+	for _, alias := range tsTargetPrimaries {
+		env.tmc.vrepQueriesByTablet[alias][streamsQuery(tsTargetKeyspace, "nope")] = &querypb.QueryResult{}
+	}
+	_, err = env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:  tsTargetKeyspace,
+		Workflow:  "nope",
+		Direction: int32(DirectionForward),
+	})
+	assert.EqualError(t, err, "the nope workflow does not exist in the targetks keyspace")
+
+	// A lagging stream keeps the traffic from being switched.
+	lagged := time.Now().Add(-time.Hour).Unix()
+	env.tmc.vrepQueriesByTablet[tsTargetPrimaries["-80"]]["select id, state, message, time_updated from _vt.vreplication where db_name='vt_targetks' and workflow='wf'"] = sqltypes.ResultToProto3(
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("id|state|message|time_updated", "int64|varbinary|varbinary|int64"), fmt.Sprintf("1|Running||%d", lagged)))
+	_, err = env.ws.WorkflowSwitchTraffic(ctx, &vtctldatapb.WorkflowSwitchTrafficRequest{
+		Keyspace:  tsTargetKeyspace,
+		Workflow:  tsWorkflow,
+		Direction: int32(DirectionForward),
+	})
+	assert.ErrorContains(t, err, "cannot switch traffic for workflow wf at this time: replication lag")
+	assert.Equal(t, routingRulesTo(tsSourceKeyspace, tsSourceKeyspace), env.routingRules(ctx, t))
+}
+
+func TestWorkflowCancel(t *testing.T) {
+	ctx := context.Background()
+	env := newTestTrafficSwitcherEnv(ctx, t)
+
+	env.saveRoutingRules(ctx, t, tsTargetKeyspace, tsSourceKeyspace)
+	_, err := env.ws.WorkflowCancel(ctx, &vtctldatapb.WorkflowCancelRequest{Keyspace: tsTargetKeyspace, Workflow: tsWorkflow})
+	assert.EqualError(t, err, "cannot cancel the wf workflow because some or all of its read and write traffic was already switched")
+
+	env.saveRoutingRules(ctx, t, tsSourceKeyspace, tsSourceKeyspace)
+	resp, err := env.ws.WorkflowCancel(ctx, &vtctldatapb.WorkflowCancelRequest{Keyspace: tsTargetKeyspace, Workflow: tsWorkflow})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully cancelled the wf workflow in the targetks keyspace, dropping 1 tables from the targetks keyspace", resp.Summary)
+
+	// The tables copied so far, the streams and the routing rules are
+	// deleted. The vschema of the sharded target keyspace is kept.
+	assert.Equal(t, 2, countQueries(env.tmc.dbaQueries, "drop table `vt_targetks`.`t1`"))
+	assert.Equal(t, 2, env.vrepQueryCount(`^delete from _vt.vreplication where db_name = 'vt_targetks' and workflow = 'wf'$`))
+	assert.Equal(t, 1, env.vrepQueryCount(`^delete from _vt.vreplication where db_name = 'vt_sourceks' and workflow = 'wf_reverse'$`))
+	assert.Empty(t, env.routingRules(ctx, t))
+	vschema, err := env.ts.GetVSchema(ctx, tsTargetKeyspace)
+	require.NoError(t, err)
+	assert.Contains(t, vschema.Tables, "t1")
+}
+
+func TestWorkflowComplete(t *testing.T) {
+	ctx := context.Background()
+	env := newTestTrafficSwitcherEnv(ctx, t)
+
+	env.saveRoutingRules(ctx, t, tsTargetKeyspace, tsSourceKeyspace)
+	_, err := env.ws.WorkflowComplete(ctx, &vtctldatapb.WorkflowCompleteRequest{Keyspace: tsTargetKeyspace, Workflow: tsWorkflow})
+	assert.EqualError(t, err, "cannot complete the wf workflow because not all of its read and write traffic was switched")
+
+	env.saveRoutingRules(ctx, t, tsTargetKeyspace, tsTargetKeyspace)
+	for _, alias := range tsTargetPrimaries {
+		env.freeze(alias, tsTargetKeyspace, tsWorkflow)
+	}
+	resp, err := env.ws.WorkflowComplete(ctx, &vtctldatapb.WorkflowCompleteRequest{Keyspace: tsTargetKeyspace, Workflow: tsWorkflow})
+	require.NoError(t, err)
+	assert.Equal(t, "Successfully completed the wf workflow in the targetks keyspace, removing 1 tables from the sourceks keyspace", resp.Summary)
+
+	// The source tables, the streams of both directions and the routing
+	// rules are deleted.
+	assert.Equal(t, 1, countQueries(env.tmc.dbaQueries, "drop table `vt_sourceks`.`t1`"))
+	assert.Equal(t, 2, env.vrepQueryCount(`^delete from _vt.vreplication where db_name = 'vt_targetks' and workflow = 'wf'$`))
+	assert.Equal(t, 1, env.vrepQueryCount(`^delete from _vt.vreplication where db_name = 'vt_sourceks' and workflow = 'wf_reverse'$`))
+	assert.Empty(t, env.routingRules(ctx, t))
+	vschema, err := env.ts.GetVSchema(ctx, tsSourceKeyspace)
+	require.NoError(t, err)
+	assert.NotContains(t, vschema.Tables, "t1")
+}
+
+func countQueries(queries []string, query string) int {
+	count := 0
+	for _, q := range queries {
+		if q == query {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"sync"
+
+	"vitess.io/vitess/go/trace"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/tabletmanager/vdiff"
+
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	vtctldatapb "vitess.io/vitess/go/vt/proto/vtctldata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// VDiff is part of the vtctlservicepb.VtctldServer interface. It performs
+// the vdiff action of the request on the primaries of the target shards of
+// the workflow, and returns their responses by shard.
+func (s *Server) VDiff(ctx context.Context, req *vtctldatapb.VDiffRequest) (*vtctldatapb.VDiffResponse, error) {
+	span, ctx := trace.NewSpan(ctx, "workflow.Server.VDiff")
+	defer span.Finish()
+
+	span.Annotate("keyspace", req.TargetKeyspace)
+	span.Annotate("workflow", req.Workflow)
+	span.Annotate("action", req.Action)
+	span.Annotate("action_arg", req.ActionArg)
+	span.Annotate("uuid", req.Uuid)
+
+	action := vdiff.VDiffAction(req.Action)
+	switch action {
+	case vdiff.CreateAction, vdiff.ShowAction, vdiff.StopAction, vdiff.ResumeAction, vdiff.DeleteAction:
+	default:
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid vdiff action %q", req.Action)
+	}
+
+	ts, err := s.buildTrafficSwitcher(ctx, req.TargetKeyspace, req.Workflow)
+	if err != nil {
+		return nil, err
+	}
+	if action == vdiff.CreateAction && ts.frozen {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "invalid VDiff run: writes have been already been switched for workflow %s.%s",
+			req.TargetKeyspace, req.Workflow)
+	}
+
+	tabletReq := &tabletmanagerdatapb.VDiffRequest{
+		Keyspace:  req.TargetKeyspace,
+		Workflow:  req.Workflow,
+		Action:    req.Action,
+		ActionArg: req.ActionArg,
+		VdiffUuid: req.Uuid,
+		Options:   req.Options,
+	}
+	resp := &vtctldatapb.VDiffResponse{
+		TabletResponses: make(map[string]*tabletmanagerdatapb.VDiffResponse, len(ts.targets)),
+	}
+	var mu sync.Mutex
+	err = ts.ForAllTargets(func(target *MigrationTarget) error {
+		tabletResp, err := s.tmc.VDiff(ctx, target.GetPrimary().Tablet, tabletReq)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		resp.TabletResponses[target.GetShard().ShardName()] = tabletResp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"vitess.io/vitess/go/sqlescape"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// vindexScanBatchSize is the number of rows fetched per query when scanning
+// the vindex columns of a table.
+const vindexScanBatchSize = 10000
+
+// vindexScanBatch is a batch of rows read by scanVindexColumns.
+type vindexScanBatch struct {
+	// pkColumns are the primary key columns of the table, and pks their
+	// values in each row.
+	pkColumns []string
+	pks       [][]sqltypes.Value
+	// values are the values of the vindex columns in each row, and ksids the
+	// keyspace ids the vindex maps them to. The keyspace id of values that do
+	// not map to a single keyspace id, such as NULLs, is nil.
+	values [][]sqltypes.Value
+	ksids  [][]byte
+}
+
+// scanVindexColumns reads the values of the given columns of all the rows
+// of a table on a tablet, in batches, and calls fn with each batch. The rows
+// are paginated on the primary key, which unlike the vindex columns is
+// unique and never NULL.
+func (s *Server) scanVindexColumns(ctx context.Context, tablet *topodatapb.Tablet, table string, columns []string, vindex vindexes.Vindex, fn func(batch *vindexScanBatch) error) error {
+	pkColumns, err := s.getPrimaryKeyColumns(ctx, tablet, table)
+	if err != nil {
+		return err
+	}
+	var last []sqltypes.Value
+	for {
+		query := buildVindexScanQuery(table, pkColumns, columns, last)
+		p3qr, err := s.tmc.ExecuteFetchAsDba(ctx, tablet, true, &tabletmanagerdatapb.ExecuteFetchAsDbaRequest{
+			Query:   []byte(query),
+			DbName:  topoproto.TabletDbName(tablet),
+			MaxRows: vindexScanBatchSize,
+		})
+		if err != nil {
+			return vterrors.Wrapf(err, "failed to read %s on tablet %s", table, topoproto.TabletAliasString(tablet.Alias))
+		}
+		qr := sqltypes.Proto3ToResult(p3qr)
+		if len(qr.Rows) == 0 {
+			return nil
+		}
+
+		batch := &vindexScanBatch{
+			pkColumns: pkColumns,
+			pks:       make([][]sqltypes.Value, len(qr.Rows)),
+			values:    make([][]sqltypes.Value, len(qr.Rows)),
+		}
+		for i, row := range qr.Rows {
+			batch.pks[i] = row[:len(pkColumns)]
+			batch.values[i] = row[len(pkColumns):]
+		}
+		destinations, err := vindexes.Map(ctx, vindex, nil, batch.values)
+		if err != nil {
+			return err
+		}
+		batch.ksids = make([][]byte, len(destinations))
+		for i, dest := range destinations {
+			if ksid, ok := dest.(key.DestinationKeyspaceID); ok {
+				batch.ksids[i] = ksid
+			}
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(qr.Rows) < vindexScanBatchSize {
+			return nil
+		}
+		last = batch.pks[len(batch.pks)-1]
+	}
+}
+
+// getPrimaryKeyColumns returns the primary key columns of a table on a
+// tablet.
+func (s *Server) getPrimaryKeyColumns(ctx context.Context, tablet *topodatapb.Tablet, table string) ([]string, error) {
+	sd, err := s.tmc.GetSchema(ctx, tablet, &tabletmanagerdatapb.GetSchemaRequest{Tables: []string{table}})
+	if err != nil {
+		return nil, vterrors.Wrapf(err, "failed to get the schema of %s on tablet %s", table, topoproto.TabletAliasString(tablet.Alias))
+	}
+	if len(sd.TableDefinitions) != 1 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "table %s not found on tablet %s", table, topoproto.TabletAliasString(tablet.Alias))
+	}
+	if len(sd.TableDefinitions[0].PrimaryKeyColumns) == 0 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "table %s has no primary key to scan it by", table)
+	}
+	return sd.TableDefinitions[0].PrimaryKeyColumns, nil
+}
+
+// buildVindexScanQuery returns the query that fetches the primary key and
+// the vindex columns of the next batch of rows after the primary key last.
+func buildVindexScanQuery(table string, pkColumns, columns []string, last []sqltypes.Value) string {
+	pks := strings.Join(sqlescape.EscapeIDs(pkColumns), ", ")
+
+	buf := &strings.Builder{}
+	buf.WriteString("select ")
+	buf.WriteString(pks)
+	buf.WriteString(", ")
+	buf.WriteString(strings.Join(sqlescape.EscapeIDs(columns), ", "))
+	buf.WriteString(" from ")
+	buf.WriteString(sqlescape.EscapeID(table))
+	if last != nil {
+		buf.WriteString(" where (")
+		buf.WriteString(pks)
+		buf.WriteString(") > ")
+		writeTuple(buf, last)
+	}
+	buf.WriteString(" order by ")
+	buf.WriteString(pks)
+	buf.WriteString(" limit ")
+	buf.WriteString(strconv.Itoa(vindexScanBatchSize))
+	return buf.String()
+}
+
+// buildVindexDeleteQuery returns the query that deletes the rows of a table
+// whose columns have one of the given values.
+func buildVindexDeleteQuery(table string, columns []string, rows [][]sqltypes.Value) string {
+	buf := &strings.Builder{}
+	buf.WriteString("delete from ")
+	buf.WriteString(sqlescape.EscapeID(table))
+	buf.WriteString(" where (")
+	buf.WriteString(strings.Join(sqlescape.EscapeIDs(columns), ", "))
+	buf.WriteString(") in (")
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		writeTuple(buf, row)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func writeTuple(buf *strings.Builder, values []sqltypes.Value) {
+	buf.WriteString("(")
+	for i, val := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		val.EncodeSQLStringBuilder(buf)
+	}
+	buf.WriteString(")")
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+func TestBuildVindexScanQuery(t *testing.T) {
+	assert.Equal(t,
+		"select `id1`, `id2`, `c1`, `c2` from `t1` order by `id1`, `id2` limit 10000",
+		buildVindexScanQuery("t1", []string{"id1", "id2"}, []string{"c1", "c2"}, nil))
+	assert.Equal(t,
+		"select `id1`, `id2`, `c1`, `c2` from `t1` where (`id1`, `id2`) > (1, 'a') order by `id1`, `id2` limit 10000",
+		buildVindexScanQuery("t1", []string{"id1", "id2"}, []string{"c1", "c2"}, []sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("a")}))
+}
+
+func TestBuildVindexDeleteQuery(t *testing.T) {
+	assert.Equal(t,
+		"delete from `t1` where (`c1`, `c2`) in ((1, 'a'), (2, 'b'))",
+		buildVindexDeleteQuery("t1", []string{"c1", "c2"}, [][]sqltypes.Value{
+			{sqltypes.NewInt64(1), sqltypes.NewVarChar("a")},
+			{sqltypes.NewInt64(2), sqltypes.NewVarChar("b")},
+		}))
+}
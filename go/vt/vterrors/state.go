@@ -65,6 +65,7 @@ const (
 	UnknownSystemVariable
 	UnknownTable
 	NoSuchSession
+	NoSuchThread
 
 	// already exists
 	DbCreateExists
@@ -81,6 +82,7 @@ const (
 
 	// permission denied
 	AccessDeniedError
+	KillDeniedError
 
 	// server not available
 	ServerNotAvailable
@@ -0,0 +1,237 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package audit implements the audit log of vtgate.
+
+The audit log records who ran which statements, for the statement types and
+users it is configured for: the callers, client address and session of the
+statement, its SQL, the keyspaces and shards it touched, and its result.
+The events are sent to sinks, which write them to a file, to syslog, or
+stream them to the gRPC clients of the Audit service. Each sink gets the
+events on its own buffered channel, and drops them if it does not keep up.
+*/
+package audit
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/protoutil"
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/streamlog"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+var eventsCount = stats.NewCountersWithSingleLabel("AuditEvents", "Statements recorded by the vtgate audit log, by statement type", "StmtType")
+
+// Filter selects the statements to audit.
+type Filter struct {
+	// StatementTypes are the statement types to audit, all of them if
+	// empty.
+	StatementTypes []string
+	// Users are the immediate callers to audit, all of them if empty.
+	Users []string
+}
+
+// Matches returns true if the filter selects the statements of that type
+// run by that user.
+func (f *Filter) Matches(stmtType, user string) bool {
+	if len(f.StatementTypes) > 0 && !containsFold(f.StatementTypes, stmtType) {
+		return false
+	}
+	if len(f.Users) > 0 && !contains(f.Users, user) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Logger records the audit events of the statements its filter selects, and
+// sends them to its sinks.
+type Logger struct {
+	filter Filter
+	redact bool
+	events *streamlog.StreamLogger[*vtgatepb.AuditEvent]
+
+	mu       sync.Mutex
+	sinks    []Sink
+	channels []chan *vtgatepb.AuditEvent
+}
+
+// NewLogger returns a Logger of the statements the filter selects, which
+// replaces the literals of their SQL with bind variables if redact is set.
+// It has no sink until AddSink is called.
+func NewLogger(filter Filter, redact bool, bufferSize int) *Logger {
+	return &Logger{
+		filter: filter,
+		redact: redact,
+		events: streamlog.New[*vtgatepb.AuditEvent]("VTGateAudit", bufferSize),
+	}
+}
+
+// AddSink sends the events to the sink, until the Logger is closed.
+func (l *Logger) AddSink(sink Sink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = append(l.sinks, sink)
+
+	ch := l.events.Subscribe(sink.Name())
+	l.channels = append(l.channels, ch)
+	go func() {
+		for event := range ch {
+			if err := sink.Write(event); err != nil {
+				log.Errorf("Error writing to the %s audit log: %v", sink.Name(), err)
+			}
+		}
+	}()
+}
+
+// Close stops sending the events to the sinks, and closes them.
+func (l *Logger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range l.channels {
+		l.events.Unsubscribe(ch)
+	}
+	l.channels = nil
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			log.Errorf("Error closing the %s audit log: %v", sink.Name(), err)
+		}
+	}
+	l.sinks = nil
+}
+
+// Record sends the event of an executed statement to the sinks, if the
+// filter selects it. sql is the statement as the client sent it. The
+// statements that were not planned have no statement type, and are not
+// recorded.
+func (l *Logger) Record(ctx context.Context, logStats *logstats.LogStats, sql string) {
+	if logStats.StmtType == "" {
+		return
+	}
+	user := logStats.ImmediateCaller()
+	if !l.filter.Matches(logStats.StmtType, user) {
+		return
+	}
+
+	if l.redact {
+		redacted, err := sqlparser.RedactSQLQuery(sql)
+		if err != nil {
+			redacted = "[could not redact the " + logStats.StmtType + " statement]"
+		}
+		sql = redacted
+	}
+	clientAddress, _ := logStats.RemoteAddrUsername()
+	event := &vtgatepb.AuditEvent{
+		Time:            protoutil.TimeToProto(logStats.EndTime),
+		ImmediateCaller: user,
+		EffectiveCaller: logStats.EffectiveCaller(),
+		ClientAddress:   clientAddress,
+		SessionUuid:     logStats.SessionUUID,
+		StatementType:   logStats.StmtType,
+		Sql:             sql,
+		Keyspaces:       keyspaces(logStats),
+		Shards:          shardsFromContext(ctx),
+		Error:           logStats.ErrorStr(),
+		RowsAffected:    logStats.RowsAffected,
+		RowsReturned:    logStats.RowsReturned,
+		Duration:        protoutil.DurationToProto(logStats.TotalTime()),
+	}
+	eventsCount.Add(logStats.StmtType, 1)
+	l.events.Send(event)
+}
+
+// keyspaces returns the keyspaces of the tables of a statement, or the
+// keyspace of its session if it has no table.
+func keyspaces(logStats *logstats.LogStats) []string {
+	var keyspaces []string
+	for _, table := range logStats.TablesUsed {
+		ks, _, ok := strings.Cut(table, ".")
+		if ok && !contains(keyspaces, ks) {
+			keyspaces = append(keyspaces, ks)
+		}
+	}
+	if len(keyspaces) == 0 && logStats.ActiveKeyspace != "" {
+		keyspaces = append(keyspaces, logStats.ActiveKeyspace)
+	}
+	return keyspaces
+}
+
+type shardsKey struct{}
+
+// shardSet is the set of shards a statement was sent to.
+type shardSet struct {
+	mu     sync.Mutex
+	shards []string
+}
+
+// NewContext returns a context that collects the shards the statement of
+// the context is sent to, for its audit event.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shardsKey{}, &shardSet{})
+}
+
+// AddShards records the shards a statement is sent to, if its context was
+// returned by NewContext.
+func AddShards(ctx context.Context, rss []*srvtopo.ResolvedShard) {
+	set, ok := ctx.Value(shardsKey{}).(*shardSet)
+	if !ok {
+		return
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	for _, rs := range rss {
+		shard := rs.Target.Keyspace + "/" + rs.Target.Shard
+		if !contains(set.shards, shard) {
+			set.shards = append(set.shards, shard)
+		}
+	}
+}
+
+func shardsFromContext(ctx context.Context) []string {
+	set, ok := ctx.Value(shardsKey{}).(*shardSet)
+	if !ok {
+		return nil
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	return append([]string(nil), set.shards...)
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+	vtgateservicepb "vitess.io/vitess/go/vt/proto/vtgateservice"
+)
+
+// memorySink keeps the events it gets.
+type memorySink struct {
+	events chan *vtgatepb.AuditEvent
+}
+
+func newMemorySink() *memorySink {
+	return &memorySink{events: make(chan *vtgatepb.AuditEvent, 10)}
+}
+
+func (ms *memorySink) Name() string {
+	return "memory"
+}
+
+func (ms *memorySink) Write(event *vtgatepb.AuditEvent) error {
+	ms.events <- event
+	return nil
+}
+
+func (ms *memorySink) Close() error {
+	return nil
+}
+
+func (ms *memorySink) next(t *testing.T) *vtgatepb.AuditEvent {
+	t.Helper()
+	select {
+	case event := <-ms.events:
+		return event
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no audit event")
+		return nil
+	}
+}
+
+func (ms *memorySink) assertEmpty(t *testing.T) {
+	t.Helper()
+	select {
+	case event := <-ms.events:
+		assert.Failf(t, "unexpected audit event", "%v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func newLogStats(ctx context.Context, user, stmtType, sql string) *logstats.LogStats {
+	ctx = callerid.NewContext(ctx, nil, callerid.NewImmediateCallerID(user))
+	logStats := logstats.NewLogStats(ctx, "Execute", sql, "uuid", nil)
+	logStats.StmtType = stmtType
+	logStats.TablesUsed = []string{"commerce.customer", "commerce.corder", "lookup.customer_lookup"}
+	logStats.RowsAffected = 2
+	logStats.SaveEndTime()
+	return logStats
+}
+
+func TestRecord(t *testing.T) {
+	l := NewLogger(Filter{StatementTypes: []string{"ddl", "UPDATE"}, Users: []string{"app", "dba"}}, false, 10)
+	defer l.Close()
+	sink := newMemorySink()
+	l.AddSink(sink)
+
+	ctx := NewContext(context.Background())
+	AddShards(ctx, []*srvtopo.ResolvedShard{
+		{Target: &querypb.Target{Keyspace: "commerce", Shard: "-80"}},
+		{Target: &querypb.Target{Keyspace: "commerce", Shard: "80-"}},
+	})
+	AddShards(ctx, []*srvtopo.ResolvedShard{{Target: &querypb.Target{Keyspace: "commerce", Shard: "-80"}}})
+	logStats := newLogStats(ctx, "app", "UPDATE", "update customer set email = 'alice@example.com' where id = 1")
+	logStats.Error = errors.New("deadlock")
+	l.Record(ctx, logStats, logStats.SQL)
+
+	event := sink.next(t)
+	assert.Equal(t, "app", event.ImmediateCaller)
+	assert.Equal(t, "uuid", event.SessionUuid)
+	assert.Equal(t, "UPDATE", event.StatementType)
+	assert.Equal(t, "update customer set email = 'alice@example.com' where id = 1", event.Sql)
+	assert.Equal(t, []string{"commerce", "lookup"}, event.Keyspaces)
+	assert.Equal(t, []string{"commerce/-80", "commerce/80-"}, event.Shards)
+	assert.Equal(t, "deadlock", event.Error)
+	assert.EqualValues(t, 2, event.RowsAffected)
+
+	// Other statement types, other users and unplanned statements are not
+	// recorded.
+	l.Record(ctx, newLogStats(ctx, "app", "SELECT", "select 1"), "select 1")
+	l.Record(ctx, newLogStats(ctx, "reporting", "UPDATE", "update customer set x = 1"), "update customer set x = 1")
+	l.Record(ctx, newLogStats(ctx, "app", "", "drop tabel customer"), "drop tabel customer")
+	sink.assertEmpty(t)
+}
+
+func TestRecordRedacted(t *testing.T) {
+	l := NewLogger(Filter{}, true, 10)
+	defer l.Close()
+	sink := newMemorySink()
+	l.AddSink(sink)
+
+	ctx := context.Background()
+	sql := "update customer set email = 'alice@example.com' where id = 1"
+	l.Record(ctx, newLogStats(ctx, "app", "UPDATE", sql), sql)
+	event := sink.next(t)
+	assert.Equal(t, "update customer set email = :email /* VARCHAR */ where id = :id /* INT64 */", event.Sql)
+	assert.Empty(t, event.Shards)
+}
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	fs, err := NewFileSink(path, 300, 2)
+	require.NoError(t, err)
+	defer fs.Close()
+
+	event := &vtgatepb.AuditEvent{ImmediateCaller: "app", StatementType: "DDL", Sql: "alter table customer add column x int"}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, fs.Write(event))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Equal(t, `{"immediateCaller":"app","statementType":"DDL","sql":"alter table customer add column x int"}`, lines[0])
+	assert.LessOrEqual(t, len(data), 300)
+
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	require.NoError(t, fs.Close())
+	assert.EqualError(t, fs.Write(event), "log file "+path+" is closed")
+}
+
+type fakeSyslogWriter struct {
+	messages []string
+}
+
+func (fw *fakeSyslogWriter) Info(msg string) error {
+	fw.messages = append(fw.messages, msg)
+	return nil
+}
+
+func (fw *fakeSyslogWriter) Close() error {
+	return nil
+}
+
+func TestSyslogSink(t *testing.T) {
+	writer := &fakeSyslogWriter{}
+	ss := &syslogSink{writer: writer}
+	require.NoError(t, ss.Write(&vtgatepb.AuditEvent{ImmediateCaller: "app", StatementType: "DDL"}))
+	assert.Equal(t, []string{`{"immediateCaller":"app","statementType":"DDL"}`}, writer.messages)
+}
+
+func TestGRPCSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	gs := newGRPCSinkServer()
+	vtgateservicepb.RegisterAuditServer(server, gs)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	cc, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := vtgateservicepb.NewAuditClient(cc).StreamAuditEvents(ctx, &vtgatepb.StreamAuditEventsRequest{StatementTypes: []string{"DDL"}})
+	require.NoError(t, err)
+
+	// Wait for the stream to be registered.
+	require.Eventually(t, func() bool {
+		gs.mu.Lock()
+		defer gs.mu.Unlock()
+		return len(gs.streams) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, gs.Write(&vtgatepb.AuditEvent{StatementType: "UPDATE", Sql: "update customer set x = 1"}))
+	require.NoError(t, gs.Write(&vtgatepb.AuditEvent{StatementType: "DDL", Sql: "drop table customer"}))
+	response, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "drop table customer", response.Event.Sql)
+}
+
+func TestGRPCSinkServenvInitOrder(t *testing.T) {
+	servenv.RegisterGRPCServerFlags()
+	fs := servenv.GetFlagSetFor("vtgate")
+	require.NoError(t, fs.Parse([]string{"--grpc_port=15999", "--audit_log_sinks=grpc"}))
+	defer func() {
+		require.NoError(t, fs.Parse([]string{"--grpc_port=0", "--audit_log_sinks="}))
+	}()
+
+	// vtgate.Init creates the audit log before the gRPC server exists.
+	require.Nil(t, servenv.GRPCServer)
+	l, err := NewLoggerFromFlags()
+	require.NoError(t, err)
+	defer l.Close()
+
+	// servenv.Run creates the gRPC server, then fires the OnRun hooks.
+	servenv.GRPCServer = grpc.NewServer()
+	defer func() { servenv.GRPCServer = nil }()
+	servenv.FireRunHooks()
+	assert.Contains(t, servenv.GRPCServer.GetServiceInfo(), "vtgateservice.Audit")
+}
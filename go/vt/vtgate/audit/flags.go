@@ -0,0 +1,78 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+var (
+	sinkNames      []string
+	statementTypes = []string{"DDL", "INSERT", "REPLACE", "UPDATE", "DELETE", "PRIV", "REVERT", "FLUSH"}
+	users          []string
+	redact         bool
+	bufferSize     = 10000
+
+	filePath       string
+	fileMaxSizeMB  = 100
+	fileMaxBackups = 10
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&sinkNames, "audit_log_sinks", sinkNames, fmt.Sprintf("Comma-separated list of the sinks of the audit log: %s, %s, %s (streamed to the clients of the Audit gRPC service). Empty disables the audit log.", FileSinkName, SyslogSinkName, GRPCSinkName))
+	fs.StringSliceVar(&statementTypes, "audit_log_statement_types", statementTypes, "Comma-separated list of the statement types the audit log records. Empty records all of them.")
+	fs.StringSliceVar(&users, "audit_log_users", users, "Comma-separated list of the users whose statements the audit log records. Empty records all of them.")
+	fs.BoolVar(&redact, "audit_log_redact", redact, "Replace the literals of the statements of the audit log with bind variables.")
+	fs.IntVar(&bufferSize, "audit_log_buffer_size", bufferSize, "Number of audit events buffered for each sink. Events are dropped when a sink falls behind by that many.")
+	fs.StringVar(&filePath, "audit_log_file", filePath, "Path of the file of the file audit sink.")
+	fs.IntVar(&fileMaxSizeMB, "audit_log_file_max_size", fileMaxSizeMB, "Size in megabytes over which the audit log file is rotated. 0 never rotates it.")
+	fs.IntVar(&fileMaxBackups, "audit_log_file_max_backups", fileMaxBackups, "Number of rotated audit log files to keep. 0 keeps all of them.")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewLoggerFromFlags returns a Logger with the filter and sinks set by the
+// command line flags, or nil if the audit log is disabled. The sinks are
+// closed when the process terminates.
+func NewLoggerFromFlags() (*Logger, error) {
+	if len(sinkNames) == 0 {
+		return nil, nil
+	}
+	l := NewLogger(Filter{StatementTypes: statementTypes, Users: users}, redact, bufferSize)
+	for _, name := range sinkNames {
+		factory, ok := sinkFactory(name)
+		if !ok {
+			l.Close()
+			return nil, fmt.Errorf("unknown audit sink %s", name)
+		}
+		sink, err := factory(l)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		l.AddSink(sink)
+	}
+	servenv.OnTerm(l.Close)
+	return l, nil
+}
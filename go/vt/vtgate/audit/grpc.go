@@ -0,0 +1,122 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"sync"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/servenv"
+
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+	vtgateservicepb "vitess.io/vitess/go/vt/proto/vtgateservice"
+)
+
+// grpcServiceName is the name of the Audit service in --service_map.
+const grpcServiceName = "vtgateaudit"
+
+// grpcStreamBufferSize is the number of events buffered for each client of
+// the Audit service.
+const grpcStreamBufferSize = 1000
+
+var grpcDroppedCount = stats.NewCounter("AuditGRPCDropped", "Audit events dropped because a client of the Audit gRPC service did not keep up")
+
+func init() {
+	servenv.InitServiceMap("grpc", grpcServiceName)
+}
+
+// grpcSink streams the events to the clients of the Audit gRPC service.
+type grpcSink struct {
+	vtgateservicepb.UnimplementedAuditServer
+
+	mu      sync.Mutex
+	streams map[chan *vtgatepb.AuditEvent]*Filter
+}
+
+func newGRPCSink(*Logger) (Sink, error) {
+	if !servenv.GRPCCheckServiceMap(grpcServiceName) {
+		return nil, fmt.Errorf("the %s audit sink needs the gRPC server and the grpc-%s service", GRPCSinkName, grpcServiceName)
+	}
+	gs := newGRPCSinkServer()
+	// The audit log is created by vtgate.Init, before servenv.Run creates
+	// the gRPC server, so the service is registered when servenv runs.
+	servenv.OnRun(func() {
+		vtgateservicepb.RegisterAuditServer(servenv.GRPCServer, gs)
+	})
+	return gs, nil
+}
+
+func newGRPCSinkServer() *grpcSink {
+	return &grpcSink{
+		streams: make(map[chan *vtgatepb.AuditEvent]*Filter),
+	}
+}
+
+// Name is part of the Sink interface.
+func (gs *grpcSink) Name() string {
+	return GRPCSinkName
+}
+
+// Write is part of the Sink interface.
+func (gs *grpcSink) Write(event *vtgatepb.AuditEvent) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for ch, filter := range gs.streams {
+		if !filter.Matches(event.StatementType, event.ImmediateCaller) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			grpcDroppedCount.Add(1)
+		}
+	}
+	return nil
+}
+
+// Close is part of the Sink interface.
+func (gs *grpcSink) Close() error {
+	return nil
+}
+
+// StreamAuditEvents is part of the vtgateservicepb.AuditServer interface.
+func (gs *grpcSink) StreamAuditEvents(request *vtgatepb.StreamAuditEventsRequest, stream vtgateservicepb.Audit_StreamAuditEventsServer) error {
+	ch := make(chan *vtgatepb.AuditEvent, grpcStreamBufferSize)
+	gs.mu.Lock()
+	gs.streams[ch] = &Filter{
+		StatementTypes: request.StatementTypes,
+		Users:          request.Users,
+	}
+	gs.mu.Unlock()
+	defer func() {
+		gs.mu.Lock()
+		delete(gs.streams, ch)
+		gs.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case event := <-ch:
+			if err := stream.Send(&vtgatepb.StreamAuditEventsResponse{Event: event}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"log/syslog"
+	"sync"
+
+	"vitess.io/vitess/go/json2"
+	"vitess.io/vitess/go/streamlog"
+
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+// Sink writes audit events somewhere.
+type Sink interface {
+	// Name returns the name of the sink.
+	Name() string
+	// Write writes an event.
+	Write(event *vtgatepb.AuditEvent) error
+	// Close releases the resources of the sink.
+	Close() error
+}
+
+// SinkFactory creates a Sink for a Logger.
+type SinkFactory func(l *Logger) (Sink, error)
+
+var (
+	sinkFactoriesMu sync.Mutex
+	sinkFactories   = make(map[string]SinkFactory)
+)
+
+// RegisterSink registers a sink under a name, for --audit_log_sinks.
+func RegisterSink(name string, factory SinkFactory) {
+	sinkFactoriesMu.Lock()
+	defer sinkFactoriesMu.Unlock()
+	if _, ok := sinkFactories[name]; ok {
+		panic(fmt.Sprintf("audit sink %s is already registered", name))
+	}
+	sinkFactories[name] = factory
+}
+
+func sinkFactory(name string) (SinkFactory, bool) {
+	sinkFactoriesMu.Lock()
+	defer sinkFactoriesMu.Unlock()
+	factory, ok := sinkFactories[name]
+	return factory, ok
+}
+
+// The names of the sinks of this package.
+const (
+	FileSinkName   = "file"
+	SyslogSinkName = "syslog"
+	GRPCSinkName   = "grpc"
+)
+
+func init() {
+	RegisterSink(FileSinkName, func(*Logger) (Sink, error) {
+		if filePath == "" {
+			return nil, fmt.Errorf("the %s audit sink needs --audit_log_file", FileSinkName)
+		}
+		return NewFileSink(filePath, int64(fileMaxSizeMB)*1024*1024, fileMaxBackups)
+	})
+	RegisterSink(SyslogSinkName, func(*Logger) (Sink, error) {
+		writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "vtgateaudit")
+		if err != nil {
+			return nil, err
+		}
+		return &syslogSink{writer: writer}, nil
+	})
+	RegisterSink(GRPCSinkName, newGRPCSink)
+}
+
+// FileSink writes the events to a file as JSON lines. When the file would
+// grow over its maximum size, it is renamed with the time as a suffix and a
+// new file is started, keeping the given number of the renamed files.
+type FileSink struct {
+	file *streamlog.RotatingFile
+}
+
+// NewFileSink returns a FileSink writing to path. A maxSize of 0 never
+// rotates the file, and a maxBackups of 0 keeps all the rotated files.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	file, err := streamlog.NewRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Name is part of the Sink interface.
+func (fs *FileSink) Name() string {
+	return FileSinkName
+}
+
+// Write is part of the Sink interface.
+func (fs *FileSink) Write(event *vtgatepb.AuditEvent) error {
+	data, err := json2.MarshalPB(event)
+	if err != nil {
+		return err
+	}
+	_, err = fs.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close is part of the Sink interface.
+func (fs *FileSink) Close() error {
+	return fs.file.Close()
+}
+
+// syslogWriter is an interface that wraps syslog.Writer, so it can be mocked in unit tests.
+type syslogWriter interface {
+	Info(string) error
+	Close() error
+}
+
+// syslogSink writes the events to syslog as JSON.
+type syslogSink struct {
+	writer syslogWriter
+}
+
+// Name is part of the Sink interface.
+func (ss *syslogSink) Name() string {
+	return SyslogSinkName
+}
+
+// Write is part of the Sink interface.
+func (ss *syslogSink) Write(event *vtgatepb.AuditEvent) error {
+	data, err := json2.MarshalPB(event)
+	if err != nil {
+		return err
+	}
+	return ss.writer.Info(string(data))
+}
+
+// Close is part of the Sink interface.
+func (ss *syslogSink) Close() error {
+	return ss.writer.Close()
+}
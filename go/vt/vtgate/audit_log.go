@@ -0,0 +1,41 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+
+	"vitess.io/vitess/go/vt/vtgate/audit"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+)
+
+// auditContext returns a context that collects the shards of the statement
+// for its audit event, if the audit log is enabled.
+func (e *Executor) auditContext(ctx context.Context) context.Context {
+	if e.auditLogger == nil {
+		return ctx
+	}
+	return audit.NewContext(ctx)
+}
+
+// recordAudit records the audit event of a statement, if the audit log is
+// enabled. ctx must have been returned by auditContext.
+func (e *Executor) recordAudit(ctx context.Context, logStats *logstats.LogStats, sql string) {
+	if e.auditLogger != nil {
+		e.auditLogger.Record(ctx, logStats, sql)
+	}
+}
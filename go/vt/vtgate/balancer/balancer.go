@@ -0,0 +1,388 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package balancer decides which of the healthy tablets of a target the
+TabletGateway sends a query to.
+
+The gateway asks the Balancer to sort the tablets by preference before each
+attempt, and tells it when a query starts and ends on a tablet. The Balancer
+uses the number of queries in flight and a moving average of the latency of
+each tablet, together with the replication lag the tablets report to the
+healthcheck, to order them according to the configured policy.
+*/
+package balancer
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+// The balancer policies.
+const (
+	// PolicyCell sends queries to a random tablet, preferring the local cell.
+	PolicyCell = "cell"
+	// PolicyLeastRequests sends queries to the tablet with the fewest
+	// queries in flight, preferring the local cell.
+	PolicyLeastRequests = "least_requests"
+	// PolicyLatency sends queries to the tablet with the lowest moving
+	// average of the latency, weighed by the queries in flight, preferring
+	// the local cell.
+	PolicyLatency = "latency"
+	// PolicyWeightedCells picks the cell at random, in proportion to the
+	// configured cell weights, and then the tablet with the fewest queries
+	// in flight in that cell.
+	PolicyWeightedCells = "weighted_cells"
+)
+
+// latencyDecay is the weight of the latest query in the moving average of
+// the latency of a tablet.
+const latencyDecay = 0.2
+
+// Config is the configuration of a Balancer.
+type Config struct {
+	// Policy is one of the Policy* constants.
+	Policy string
+	// CellWeights is the relative capacity of the cells, used by
+	// PolicyWeightedCells. Cells that are not listed have a weight of 1.
+	CellWeights map[string]int
+}
+
+// Balancer orders the tablets of a target by preference and tracks the
+// queries sent to each tablet.
+type Balancer struct {
+	policy      string
+	localCell   string
+	cellWeights map[string]int
+
+	// mu protects tablets.
+	mu sync.Mutex
+	// tablets is keyed by tablet alias.
+	tablets map[string]*tabletStats
+}
+
+// tabletStats are the selection stats of a tablet.
+type tabletStats struct {
+	tablet     *topodatapb.TabletAlias
+	alias      string
+	cell       string
+	keyspace   string
+	shard      string
+	tabletType topodatapb.TabletType
+
+	inFlight atomic.Int64
+	selected atomic.Uint64
+	errors   atomic.Uint64
+
+	// mu protects latency.
+	mu sync.Mutex
+	// latency is the moving average of the query latency, in milliseconds.
+	// It is 0 until the first query on the tablet ends.
+	latency float64
+}
+
+// New returns a Balancer for a gateway in the given cell.
+func New(cfg Config, localCell string) (*Balancer, error) {
+	switch cfg.Policy {
+	case PolicyCell, PolicyLeastRequests, PolicyLatency, PolicyWeightedCells:
+	default:
+		return nil, fmt.Errorf("unknown balancer policy %q", cfg.Policy)
+	}
+	for cell, weight := range cfg.CellWeights {
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of cell %s must not be negative: %d", cell, weight)
+		}
+	}
+	return &Balancer{
+		policy:      cfg.Policy,
+		localCell:   localCell,
+		cellWeights: cfg.CellWeights,
+		tablets:     make(map[string]*tabletStats),
+	}, nil
+}
+
+// Policy returns the policy of the Balancer.
+func (b *Balancer) Policy() string {
+	return b.policy
+}
+
+// Sort orders the tablets by preference. The gateway tries them in order.
+func (b *Balancer) Sort(tablets []*discovery.TabletHealth) {
+	switch b.policy {
+	case PolicyCell:
+		shuffleTablets(b.localCell, tablets)
+	case PolicyLeastRequests:
+		b.sortByScore(tablets, b.localCellRank, b.inFlight)
+	case PolicyLatency:
+		b.sortByScore(tablets, b.localCellRank, b.latencyScore(tablets))
+	case PolicyWeightedCells:
+		b.sortByScore(tablets, b.weightedCellRank(tablets), b.inFlight)
+	}
+}
+
+// Start records that a query is sent to a tablet. The returned function
+// must be called when the query ends.
+func (b *Balancer) Start(th *discovery.TabletHealth) func(elapsed time.Duration, err error) {
+	ts := b.stats(th)
+	ts.selected.Add(1)
+	ts.inFlight.Add(1)
+	return func(elapsed time.Duration, err error) {
+		ts.inFlight.Add(-1)
+		if err != nil {
+			ts.errors.Add(1)
+		}
+
+		ms := float64(elapsed) / float64(time.Millisecond)
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		if ts.latency == 0 {
+			ts.latency = ms
+		} else {
+			ts.latency = latencyDecay*ms + (1-latencyDecay)*ts.latency
+		}
+	}
+}
+
+func (b *Balancer) stats(th *discovery.TabletHealth) *tabletStats {
+	alias := topoproto.TabletAliasString(th.Tablet.Alias)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ts, ok := b.tablets[alias]
+	if !ok {
+		ts = &tabletStats{
+			tablet:     th.Tablet.Alias,
+			alias:      alias,
+			cell:       th.Tablet.Alias.Cell,
+			keyspace:   th.Target.Keyspace,
+			shard:      th.Target.Shard,
+			tabletType: th.Target.TabletType,
+		}
+		b.tablets[alias] = ts
+	}
+	return ts
+}
+
+// RemoveTablets forgets the stats of the tablets for which gone returns
+// true, such as the tablets that stopped serving or were removed from the
+// healthcheck, so that the Balancer does not keep every tablet it ever sent
+// a query to. A tablet that comes back starts with fresh stats.
+func (b *Balancer) RemoveTablets(gone func(alias *topodatapb.TabletAlias) bool) {
+	b.mu.Lock()
+	tablets := make([]*tabletStats, 0, len(b.tablets))
+	for _, ts := range b.tablets {
+		tablets = append(tablets, ts)
+	}
+	b.mu.Unlock()
+
+	for _, ts := range tablets {
+		if !gone(ts.tablet) {
+			continue
+		}
+		b.mu.Lock()
+		// The tablet may have been replaced since the check.
+		if b.tablets[ts.alias] == ts {
+			delete(b.tablets, ts.alias)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// sortByScore sorts the tablets by cell rank, then score, then replication
+// lag. Tablets that compare equal are in random order.
+func (b *Balancer) sortByScore(tablets []*discovery.TabletHealth, cellRank func(th *discovery.TabletHealth) int, score func(th *discovery.TabletHealth) float64) {
+	rand.Shuffle(len(tablets), func(i, j int) {
+		tablets[i], tablets[j] = tablets[j], tablets[i]
+	})
+
+	type rankedTablet struct {
+		th       *discovery.TabletHealth
+		cellRank int
+		score    float64
+		lag      uint32
+	}
+	ranked := make([]rankedTablet, len(tablets))
+	for i, th := range tablets {
+		ranked[i] = rankedTablet{
+			th:       th,
+			cellRank: cellRank(th),
+			score:    score(th),
+			lag:      th.Stats.GetReplicationLagSeconds(),
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.cellRank != b.cellRank {
+			return a.cellRank < b.cellRank
+		}
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		return a.lag < b.lag
+	})
+	for i, r := range ranked {
+		tablets[i] = r.th
+	}
+}
+
+func (b *Balancer) localCellRank(th *discovery.TabletHealth) int {
+	if th.Tablet.Alias.Cell == b.localCell {
+		return 0
+	}
+	return 1
+}
+
+// weightedCellRank orders the cells of the tablets at random, so that each
+// cell comes first with a probability proportional to its weight.
+func (b *Balancer) weightedCellRank(tablets []*discovery.TabletHealth) func(th *discovery.TabletHealth) int {
+	weights := make(map[string]int)
+	for _, th := range tablets {
+		weights[th.Tablet.Alias.Cell] = b.cellWeight(th.Tablet.Alias.Cell)
+	}
+
+	ranks := make(map[string]int, len(weights))
+	for len(ranks) < len(weights) {
+		total := 0
+		for cell, weight := range weights {
+			if _, ok := ranks[cell]; !ok {
+				total += weight
+			}
+		}
+		// Cells with a weight of 0 only come after all the others.
+		pick := -1
+		if total > 0 {
+			pick = rand.Intn(total)
+		}
+		for _, cell := range sortedCells(weights) {
+			if _, ok := ranks[cell]; ok {
+				continue
+			}
+			if pick < weights[cell] {
+				ranks[cell] = len(ranks)
+				break
+			}
+			pick -= weights[cell]
+		}
+	}
+	return func(th *discovery.TabletHealth) int {
+		return ranks[th.Tablet.Alias.Cell]
+	}
+}
+
+func (b *Balancer) cellWeight(cell string) int {
+	if weight, ok := b.cellWeights[cell]; ok {
+		return weight
+	}
+	return 1
+}
+
+func sortedCells(weights map[string]int) []string {
+	cells := make([]string, 0, len(weights))
+	for cell := range weights {
+		cells = append(cells, cell)
+	}
+	sort.Strings(cells)
+	return cells
+}
+
+func (b *Balancer) inFlight(th *discovery.TabletHealth) float64 {
+	return float64(b.stats(th).inFlight.Load())
+}
+
+// latencyScore scores the tablets by their average latency, multiplied by
+// the number of queries in flight plus the one to send. Tablets that did
+// not serve any query yet get the lowest latency of the others, so that
+// they are tried without being flooded.
+func (b *Balancer) latencyScore(tablets []*discovery.TabletHealth) func(th *discovery.TabletHealth) float64 {
+	latencies := make(map[*discovery.TabletHealth]float64, len(tablets))
+	lowest := 0.0
+	for _, th := range tablets {
+		ts := b.stats(th)
+		ts.mu.Lock()
+		latency := ts.latency
+		ts.mu.Unlock()
+
+		latencies[th] = latency
+		if latency > 0 && (lowest == 0 || latency < lowest) {
+			lowest = latency
+		}
+	}
+	return func(th *discovery.TabletHealth) float64 {
+		latency := latencies[th]
+		if latency == 0 {
+			latency = lowest
+		}
+		return latency * float64(b.stats(th).inFlight.Load()+1)
+	}
+}
+
+// shuffleTablets moves the tablets of the given cell to the front, and
+// shuffles the tablets within each group.
+func shuffleTablets(cell string, tablets []*discovery.TabletHealth) {
+	sameCell, diffCell, sameCellMax := 0, 0, -1
+	length := len(tablets)
+
+	// move all same cell tablets to the front, this is O(n)
+	for {
+		sameCellMax = diffCell - 1
+		sameCell = nextTablet(cell, tablets, sameCell, length, true)
+		diffCell = nextTablet(cell, tablets, diffCell, length, false)
+		// either no more diffs or no more same cells should stop the iteration
+		if sameCell < 0 || diffCell < 0 {
+			break
+		}
+
+		if sameCell < diffCell {
+			// fast forward the `sameCell` lookup to `diffCell + 1`, `diffCell` unchanged
+			sameCell = diffCell + 1
+		} else {
+			// sameCell > diffCell, swap needed
+			tablets[sameCell], tablets[diffCell] = tablets[diffCell], tablets[sameCell]
+			sameCell++
+			diffCell++
+		}
+	}
+
+	// shuffle in same cell tablets
+	for i := sameCellMax; i > 0; i-- {
+		swap := rand.Intn(i + 1)
+		tablets[i], tablets[swap] = tablets[swap], tablets[i]
+	}
+
+	// shuffle in diff cell tablets
+	for i, diffCellMin := length-1, sameCellMax+1; i > diffCellMin; i-- {
+		swap := rand.Intn(i-sameCellMax) + diffCellMin
+		tablets[i], tablets[swap] = tablets[swap], tablets[i]
+	}
+}
+
+func nextTablet(cell string, tablets []*discovery.TabletHealth, offset, length int, sameCell bool) int {
+	for ; offset < length; offset++ {
+		if (tablets[offset].Tablet.Alias.Cell == cell) == sameCell {
+			return offset
+		}
+	}
+	return -1
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package balancer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/topo"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+func newTabletHealth(uid uint32, cell string, lag uint32) *discovery.TabletHealth {
+	return &discovery.TabletHealth{
+		Tablet:  topo.NewTablet(uid, cell, "host"),
+		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
+		Serving: true,
+		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: lag},
+	}
+}
+
+func uids(tablets []*discovery.TabletHealth) []uint32 {
+	var out []uint32
+	for _, th := range tablets {
+		out = append(out, th.Tablet.Alias.Uid)
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Policy: "round_robin"}, "cell1")
+	assert.EqualError(t, err, `unknown balancer policy "round_robin"`)
+
+	_, err = New(Config{Policy: PolicyWeightedCells, CellWeights: map[string]int{"cell1": -1}}, "cell1")
+	assert.Error(t, err)
+
+	b, err := New(Config{Policy: PolicyLatency}, "cell1")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyLatency, b.Policy())
+}
+
+func TestSortLeastRequests(t *testing.T) {
+	b, err := New(Config{Policy: PolicyLeastRequests}, "cell1")
+	require.NoError(t, err)
+
+	ts1 := newTabletHealth(1, "cell1", 0)
+	ts2 := newTabletHealth(2, "cell1", 5)
+	ts3 := newTabletHealth(3, "cell1", 0)
+	ts4 := newTabletHealth(4, "cell2", 0)
+	tablets := []*discovery.TabletHealth{ts4, ts3, ts2, ts1}
+
+	// Two queries in flight on ts1, one on ts3. ts2 is idle but lags, it
+	// still comes first. The other cell comes last.
+	doneA := b.Start(ts1)
+	b.Start(ts1)
+	b.Start(ts3)
+	for i := 0; i < 10; i++ {
+		b.Sort(tablets)
+		assert.Equal(t, []uint32{2, 3, 1, 4}, uids(tablets))
+	}
+
+	// Equal in-flight queries are ordered by replication lag.
+	doneA(time.Millisecond, nil)
+	b.Start(ts2)
+	b.Sort(tablets)
+	assert.ElementsMatch(t, []uint32{1, 3}, uids(tablets[:2]))
+	assert.Equal(t, []uint32{2, 4}, uids(tablets[2:]))
+}
+
+func TestSortLatency(t *testing.T) {
+	b, err := New(Config{Policy: PolicyLatency}, "cell1")
+	require.NoError(t, err)
+
+	ts1 := newTabletHealth(1, "cell1", 0)
+	ts2 := newTabletHealth(2, "cell1", 0)
+	ts3 := newTabletHealth(3, "cell1", 0)
+	tablets := []*discovery.TabletHealth{ts1, ts2, ts3}
+
+	b.Start(ts1)(30*time.Millisecond, nil)
+	b.Start(ts2)(10*time.Millisecond, nil)
+	b.Sort(tablets)
+	// ts3 has no latency yet and gets the lowest one, it ties with ts2.
+	assert.ElementsMatch(t, []uint32{2, 3}, uids(tablets[:2]))
+	assert.Equal(t, uint32(1), tablets[2].Tablet.Alias.Uid)
+
+	// Three queries in flight at 10ms on ts2 are worse than one at 30ms.
+	b.Start(ts2)
+	b.Start(ts2)
+	b.Start(ts2)
+	b.Start(ts3)(50*time.Millisecond, nil)
+	b.Sort(tablets)
+	assert.Equal(t, []uint32{1, 2, 3}, uids(tablets))
+}
+
+func TestSortWeightedCells(t *testing.T) {
+	b, err := New(Config{Policy: PolicyWeightedCells, CellWeights: map[string]int{"cell1": 3, "cell3": 0}}, "cell1")
+	require.NoError(t, err)
+
+	tablets := []*discovery.TabletHealth{
+		newTabletHealth(1, "cell1", 0),
+		newTabletHealth(2, "cell2", 0),
+		newTabletHealth(3, "cell3", 0),
+	}
+	first := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		b.Sort(tablets)
+		first[tablets[0].Tablet.Alias.Cell]++
+		// A cell with a weight of 0 is only used when all others failed.
+		assert.Equal(t, "cell3", tablets[2].Tablet.Alias.Cell)
+	}
+	assert.InDelta(t, 3000, first["cell1"], 200)
+	assert.InDelta(t, 1000, first["cell2"], 200)
+}
+
+func TestStatus(t *testing.T) {
+	b, err := New(Config{Policy: PolicyCell}, "cell1")
+	require.NoError(t, err)
+
+	ts1 := newTabletHealth(1, "cell1", 0)
+	ts2 := newTabletHealth(2, "cell2", 0)
+	b.Start(ts2)(10*time.Millisecond, errors.New("failed"))
+	b.Start(ts2)(20*time.Millisecond, nil)
+	b.Start(ts1)
+
+	status := b.Status()
+	assert.Equal(t, PolicyCell, status.Policy)
+	require.Len(t, status.Tablets, 2)
+	assert.Equal(t, &TabletStatus{
+		Keyspace:   "k",
+		Shard:      "s",
+		TabletType: topodatapb.TabletType_REPLICA,
+		Alias:      "cell1-0000000001",
+		Cell:       "cell1",
+		Selected:   1,
+		InFlight:   1,
+	}, status.Tablets[0])
+	assert.Equal(t, &TabletStatus{
+		Keyspace:   "k",
+		Shard:      "s",
+		TabletType: topodatapb.TabletType_REPLICA,
+		Alias:      "cell2-0000000002",
+		Cell:       "cell2",
+		Selected:   2,
+		Errors:     1,
+		Latency:    12,
+	}, status.Tablets[1])
+	assert.Equal(t, "12.00", status.Tablets[1].FormattedLatency())
+}
+
+func TestShuffleTablets(t *testing.T) {
+	ts1 := &discovery.TabletHealth{
+		Tablet:  topo.NewTablet(1, "cell1", "host1"),
+		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
+		Serving: true,
+		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
+	}
+
+	ts2 := &discovery.TabletHealth{
+		Tablet:  topo.NewTablet(2, "cell1", "host2"),
+		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
+		Serving: true,
+		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
+	}
+
+	ts3 := &discovery.TabletHealth{
+		Tablet:  topo.NewTablet(3, "cell2", "host3"),
+		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
+		Serving: true,
+		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
+	}
+
+	ts4 := &discovery.TabletHealth{
+		Tablet:  topo.NewTablet(4, "cell2", "host4"),
+		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
+		Serving: true,
+		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
+	}
+
+	sameCellTablets := []*discovery.TabletHealth{ts1, ts2}
+	diffCellTablets := []*discovery.TabletHealth{ts3, ts4}
+	mixedTablets := []*discovery.TabletHealth{ts1, ts2, ts3, ts4}
+	// repeat shuffling 10 times and every time the same cell tablets should be in the front
+	for i := 0; i < 10; i++ {
+		shuffleTablets("cell1", sameCellTablets)
+		assert.Len(t, sameCellTablets, 2, "Wrong number of TabletHealth")
+		assert.Equal(t, sameCellTablets[0].Tablet.Alias.Cell, "cell1", "Wrong tablet cell")
+		assert.Equal(t, sameCellTablets[1].Tablet.Alias.Cell, "cell1", "Wrong tablet cell")
+
+		shuffleTablets("cell1", diffCellTablets)
+		assert.Len(t, diffCellTablets, 2, "should shuffle in only diff cell tablets")
+		assert.Contains(t, diffCellTablets, ts3, "diffCellTablets should contain %v", ts3)
+		assert.Contains(t, diffCellTablets, ts4, "diffCellTablets should contain %v", ts4)
+
+		shuffleTablets("cell1", mixedTablets)
+		assert.Len(t, mixedTablets, 4, "should have 4 tablets, got %+v", mixedTablets)
+
+		assert.Contains(t, mixedTablets[0:2], ts1, "should have same cell tablets in the front, got %+v", mixedTablets)
+		assert.Contains(t, mixedTablets[0:2], ts2, "should have same cell tablets in the front, got %+v", mixedTablets)
+
+		assert.Contains(t, mixedTablets[2:4], ts3, "should have diff cell tablets in the rear, got %+v", mixedTablets)
+		assert.Contains(t, mixedTablets[2:4], ts4, "should have diff cell tablets in the rear, got %+v", mixedTablets)
+	}
+}
+
+func TestRemoveTablets(t *testing.T) {
+	b, err := New(Config{Policy: PolicyLeastRequests}, "cell1")
+	require.NoError(t, err)
+
+	ts1 := newTabletHealth(1, "cell1", 0)
+	ts2 := newTabletHealth(2, "cell1", 0)
+	done := b.Start(ts1)
+	done(time.Millisecond, nil)
+	b.Start(ts2)
+
+	b.RemoveTablets(func(alias *topodatapb.TabletAlias) bool {
+		return alias.Uid == 2
+	})
+	status := b.Status()
+	require.Len(t, status.Tablets, 1)
+	assert.Equal(t, "cell1-0000000001", status.Tablets[0].Alias)
+
+	// A tablet that comes back starts over.
+	b.Start(ts2)
+	status = b.Status()
+	require.Len(t, status.Tablets, 2)
+	assert.EqualValues(t, 1, status.Tablets[1].Selected)
+}
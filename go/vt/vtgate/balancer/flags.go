@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package balancer
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+var (
+	balancerPolicy      = PolicyCell
+	balancerCellWeights map[string]int
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&balancerPolicy, "balancer_policy", PolicyCell, fmt.Sprintf("How the gateway picks the tablet a query is sent to. Allowed values: %s (random tablet, local cell first), %s (fewest in-flight queries, local cell first), %s (lowest latency moving average weighed by in-flight queries, local cell first), %s (cells picked at random in proportion to --balancer_cell_weights, then fewest in-flight queries).", PolicyCell, PolicyLeastRequests, PolicyLatency, PolicyWeightedCells))
+	fs.StringToIntVar(&balancerCellWeights, "balancer_cell_weights", nil, "Relative capacity of each cell for the weighted_cells balancer policy, e.g. cell1=3,cell2=1. Cells that are not listed have a weight of 1.")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewConfigFromFlags returns a Config built from the command line flags.
+func NewConfigFromFlags() Config {
+	return Config{
+		Policy:      balancerPolicy,
+		CellWeights: balancerCellWeights,
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package balancer
+
+import (
+	"fmt"
+	"sort"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+// StatusTemplate is the display part to use to show a Status.
+const StatusTemplate = `
+<style>
+  table {
+    border-collapse: collapse;
+  }
+  td, th {
+    border: 1px solid #999;
+    padding: 0.2rem;
+  }
+  table tr:nth-child(even) {
+    background-color: #eee;
+  }
+  table tr:nth-child(odd) {
+    background-color: #fff;
+  }
+</style>
+Policy: {{.Policy}}<br>
+<table class="refreshRequired">
+  <tr>
+    <th>Keyspace</th>
+    <th>Shard</th>
+    <th>TabletType</th>
+    <th>Tablet</th>
+    <th>Cell</th>
+    <th>Selected</th>
+    <th>Errors</th>
+    <th>In Flight</th>
+    <th>Latency (ms) (moving avg)</th>
+  </tr>
+  {{range $i, $tablet := .Tablets}}
+  <tr>
+    <td>{{$tablet.Keyspace}}</td>
+    <td>{{$tablet.Shard}}</td>
+    <td>{{$tablet.TabletType}}</td>
+    <td>{{$tablet.Alias}}</td>
+    <td>{{$tablet.Cell}}</td>
+    <td>{{$tablet.Selected}}</td>
+    <td>{{$tablet.Errors}}</td>
+    <td>{{$tablet.InFlight}}</td>
+    <td>{{$tablet.FormattedLatency}}</td>
+  </tr>
+  {{end}}
+</table>
+`
+
+// Status is the state of a Balancer, to display on the status page.
+type Status struct {
+	Policy  string
+	Tablets []*TabletStatus
+}
+
+// TabletStatus are the selection stats of a tablet.
+type TabletStatus struct {
+	Keyspace   string
+	Shard      string
+	TabletType topodatapb.TabletType
+	Alias      string
+	Cell       string
+
+	Selected uint64
+	Errors   uint64
+	InFlight int64
+	Latency  float64 // in milliseconds
+}
+
+// FormattedLatency shows a 2 digit rounded value of the latency.
+// Used in the HTML template above.
+func (ts *TabletStatus) FormattedLatency() string {
+	return fmt.Sprintf("%.2f", ts.Latency)
+}
+
+// Status returns the selection stats of the tablets the Balancer sent
+// queries to, sorted by keyspace, shard, tablet type and alias.
+func (b *Balancer) Status() *Status {
+	b.mu.Lock()
+	tablets := make([]*TabletStatus, 0, len(b.tablets))
+	for _, ts := range b.tablets {
+		ts.mu.Lock()
+		latency := ts.latency
+		ts.mu.Unlock()
+
+		tablets = append(tablets, &TabletStatus{
+			Keyspace:   ts.keyspace,
+			Shard:      ts.shard,
+			TabletType: ts.tabletType,
+			Alias:      ts.alias,
+			Cell:       ts.cell,
+			Selected:   ts.selected.Load(),
+			Errors:     ts.errors.Load(),
+			InFlight:   ts.inFlight.Load(),
+			Latency:    latency,
+		})
+	}
+	b.mu.Unlock()
+
+	sort.Slice(tablets, func(i, j int) bool {
+		a, b := tablets[i], tablets[j]
+		if a.Keyspace != b.Keyspace {
+			return a.Keyspace < b.Keyspace
+		}
+		if a.Shard != b.Shard {
+			return a.Shard < b.Shard
+		}
+		if a.TabletType != b.TabletType {
+			return a.TabletType < b.TabletType
+		}
+		return a.Alias < b.Alias
+	})
+	return &Status{
+		Policy:  b.policy,
+		Tablets: tablets,
+	}
+}
@@ -0,0 +1,56 @@
+//go:build debug2PC
+
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"time"
+
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// testHangTime is how long a 2PC commit hangs after a step, for the endtoend
+// tests to kill a vtgate or a vttablet during the commit.
+const testHangTime = 10 * time.Second
+
+// checkTestFailure fails a 2PC commit after the given step, leaving the
+// distributed transaction as a crash of vtgate would, if the user of the
+// session is <step>_FailNow. If the user is <step>_Hang, the commit first hangs
+// for testHangTime. The endtoend tests build vtgate with the debug2PC tag to
+// crash the commits at every step.
+func checkTestFailure(ctx context.Context, step string) error {
+	callerID := callerid.EffectiveCallerIDFromContext(ctx)
+	switch callerID.GetPrincipal() {
+	case step + "_FailNow":
+	case step + "_Hang":
+		log.Errorf("Hanging the 2PC commit after %s", step)
+		select {
+		case <-time.After(testHangTime):
+		case <-ctx.Done():
+		}
+	default:
+		return nil
+	}
+	log.Errorf("Failing the 2PC commit after %s", step)
+	return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "Fail After %s", step)
+}
@@ -89,6 +89,10 @@ func (t *noopVCursor) ReleaseLock(context.Context) error {
 	panic("implement me")
 }
 
+func (t *noopVCursor) Kill(ctx context.Context, processlistID uint64, killType sqlparser.KillType) error {
+	panic("implement me")
+}
+
 func (t *noopVCursor) SetExec(ctx context.Context, name string, value string) error {
 	panic("implement me")
 }
@@ -249,6 +253,9 @@ func (t *noopVCursor) GetQueryTimeout(queryTimeoutFromComments int) int {
 	return queryTimeoutFromComments
 }
 
+func (t *noopVCursor) SetMaxReplicationLag(time.Duration) {
+}
+
 func (t *noopVCursor) SetSkipQueryPlanCache(context.Context, bool) error {
 	panic("implement me")
 }
@@ -165,6 +165,9 @@ func NewInsert(
 type Generate struct {
 	Keyspace *vindexes.Keyspace
 	Query    string
+	// Generator, if set, produces the values instead of
+	// the sequence table. Keyspace and Query are then unused.
+	Generator vindexes.SequenceGenerator
 	// Values are the supplied values for the column, which
 	// will be stored as a list within the expression. New
 	// values will be generated based on how many were not
@@ -549,33 +552,20 @@ func (ins *Insert) processGenerateFromValues(
 	}
 
 	// If generation is needed, generate the requested number of values (as one call).
+	var generated []int64
 	if count != 0 {
-		rss, _, err := vcursor.ResolveDestinations(ctx, ins.Generate.Keyspace.Name, nil, []key.Destination{key.DestinationAnyShard{}})
-		if err != nil {
-			return 0, err
-		}
-		if len(rss) != 1 {
-			return 0, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "auto sequence generation can happen through single shard only, it is getting routed to %d shards", len(rss))
-		}
-		bindVars := map[string]*querypb.BindVariable{"n": sqltypes.Int64BindVariable(count)}
-		qr, err := vcursor.ExecuteStandalone(ctx, ins, ins.Generate.Query, bindVars, rss[0])
-		if err != nil {
-			return 0, err
-		}
-		// If no rows are returned, it's an internal error, and the code
-		// must panic, which will be caught and reported.
-		insertID, err = evalengine.ToInt64(qr.Rows[0][0])
+		generated, err = ins.generateValues(ctx, vcursor, count)
 		if err != nil {
 			return 0, err
 		}
+		insertID = generated[0]
 	}
 
 	// Fill the holes where no value was supplied.
-	cur := insertID
 	for i, v := range values {
 		if shouldGenerate(v) {
-			bindVars[SeqVarName+strconv.Itoa(i)] = sqltypes.Int64BindVariable(cur)
-			cur++
+			bindVars[SeqVarName+strconv.Itoa(i)] = sqltypes.Int64BindVariable(generated[0])
+			generated = generated[1:]
 		} else {
 			bindVars[SeqVarName+strconv.Itoa(i)] = sqltypes.ValueBindVariable(v)
 		}
@@ -612,39 +602,58 @@ func (ins *Insert) processGenerateFromRows(
 	}
 
 	// If generation is needed, generate the requested number of values (as one call).
-	rss, _, err := vcursor.ResolveDestinations(ctx, ins.Generate.Keyspace.Name, nil, []key.Destination{key.DestinationAnyShard{}})
+	generated, err := ins.generateValues(ctx, vcursor, count)
 	if err != nil {
 		return 0, err
 	}
+	insertID = generated[0]
+
+	for idx, val := range rows {
+		if genColPresent {
+			if val[offset].IsNull() {
+				val[offset] = sqltypes.NewInt64(generated[0])
+				generated = generated[1:]
+			}
+		} else {
+			rows[idx] = append(val, sqltypes.NewInt64(generated[0]))
+			generated = generated[1:]
+		}
+	}
+
+	return insertID, nil
+}
+
+// generateValues returns count new values for the auto-increment column,
+// in increasing order. The values come from the sequence generator if the
+// column has one, or else from a single call to the sequence table.
+func (ins *Insert) generateValues(ctx context.Context, vcursor VCursor, count int64) ([]int64, error) {
+	if ins.Generate.Generator != nil {
+		return ins.Generate.Generator.NextValues(count)
+	}
+
+	rss, _, err := vcursor.ResolveDestinations(ctx, ins.Generate.Keyspace.Name, nil, []key.Destination{key.DestinationAnyShard{}})
+	if err != nil {
+		return nil, err
+	}
 	if len(rss) != 1 {
-		return 0, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "auto sequence generation can happen through single shard only, it is getting routed to %d shards", len(rss))
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "auto sequence generation can happen through single shard only, it is getting routed to %d shards", len(rss))
 	}
 	bindVars := map[string]*querypb.BindVariable{"n": sqltypes.Int64BindVariable(count)}
 	qr, err := vcursor.ExecuteStandalone(ctx, ins, ins.Generate.Query, bindVars, rss[0])
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	// If no rows are returned, it's an internal error, and the code
 	// must panic, which will be caught and reported.
-	insertID, err = evalengine.ToInt64(qr.Rows[0][0])
+	first, err := evalengine.ToInt64(qr.Rows[0][0])
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	used := insertID
-	for idx, val := range rows {
-		if genColPresent {
-			if val[offset].IsNull() {
-				val[offset] = sqltypes.NewInt64(used)
-				used++
-			}
-		} else {
-			rows[idx] = append(val, sqltypes.NewInt64(used))
-			used++
-		}
+	values := make([]int64, count)
+	for i := range values {
+		values[i] = first + int64(i)
 	}
-
-	return insertID, nil
+	return values, nil
 }
 
 // getInsertShardedRoute performs all the vindex related work
@@ -977,7 +986,11 @@ func (ins *Insert) description() PrimitiveDescription {
 	}
 
 	if ins.Generate != nil && ins.Generate.Values == nil {
-		other["AutoIncrement"] = fmt.Sprintf("%s:%d", ins.Generate.Keyspace.Name, ins.Generate.Offset)
+		if ins.Generate.Generator != nil {
+			other["AutoIncrement"] = fmt.Sprintf("%s:%d", ins.Generate.Generator, ins.Generate.Offset)
+		} else {
+			other["AutoIncrement"] = fmt.Sprintf("%s:%d", ins.Generate.Keyspace.Name, ins.Generate.Offset)
+		}
 	}
 
 	if len(ins.VindexValueOffset) > 0 {
@@ -122,6 +122,63 @@ func TestInsertUnshardedGenerate(t *testing.T) {
 	expectResult(t, "Execute", result, &sqltypes.Result{InsertID: 4})
 }
 
+type fakeSequenceGenerator struct {
+	next int64
+}
+
+func (gen *fakeSequenceGenerator) String() string {
+	return "fake"
+}
+
+func (gen *fakeSequenceGenerator) NextValues(count int64) ([]int64, error) {
+	values := make([]int64, 0, count)
+	for i := int64(0); i < count; i++ {
+		values = append(values, gen.next)
+		// Leave gaps to verify values are not assumed to be contiguous.
+		gen.next += 10
+	}
+	return values, nil
+}
+
+func TestInsertUnshardedGenerator(t *testing.T) {
+	ins := NewQueryInsert(
+		InsertUnsharded,
+		&vindexes.Keyspace{
+			Name:    "ks",
+			Sharded: false,
+		},
+		"dummy_insert",
+	)
+	ins.Generate = &Generate{
+		Generator: &fakeSequenceGenerator{next: 100},
+		Values: evalengine.NewTupleExpr(
+			evalengine.NewLiteralInt(1),
+			evalengine.NullExpr,
+			evalengine.NewLiteralInt(2),
+			evalengine.NullExpr,
+			evalengine.NewLiteralInt(3),
+		),
+	}
+
+	vc := newDMLTestVCursor("0")
+	vc.results = []*sqltypes.Result{
+		{InsertID: 1},
+	}
+
+	result, err := ins.TryExecute(context.Background(), vc, map[string]*querypb.BindVariable{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vc.ExpectLog(t, []string{
+		// No sequence table is queried.
+		`ResolveDestinations ks [] Destinations:DestinationAllShards()`,
+		`ExecuteMultiShard ks.0: dummy_insert {__seq0: type:INT64 value:"1" __seq1: type:INT64 value:"100" __seq2: type:INT64 value:"2" __seq3: type:INT64 value:"110" __seq4: type:INT64 value:"3"} true true`,
+	})
+
+	// The insert id is the first generated value.
+	expectResult(t, "Execute", result, &sqltypes.Result{InsertID: 100})
+}
+
 func TestInsertUnshardedGenerate_Zeros(t *testing.T) {
 	ins := NewQueryInsert(
 		InsertUnsharded,
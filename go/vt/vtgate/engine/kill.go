@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"context"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+var _ Primitive = (*Kill)(nil)
+
+// Kill is the primitive of a KILL statement. It interrupts the statement
+// running on a connection of vtgate, and closes the connection for a KILL
+// CONNECTION.
+type Kill struct {
+	Type          sqlparser.KillType
+	ProcesslistID uint64
+
+	noInputs
+	noTxNeeded
+}
+
+// RouteType implements the Primitive interface
+func (k *Kill) RouteType() string {
+	return "Kill"
+}
+
+// GetKeyspaceName implements the Primitive interface
+func (k *Kill) GetKeyspaceName() string {
+	return ""
+}
+
+// GetTableName implements the Primitive interface
+func (k *Kill) GetTableName() string {
+	return ""
+}
+
+// TryExecute implements the Primitive interface
+func (k *Kill) TryExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool) (*sqltypes.Result, error) {
+	if err := vcursor.Kill(ctx, k.ProcesslistID, k.Type); err != nil {
+		return nil, err
+	}
+	return &sqltypes.Result{}, nil
+}
+
+// TryStreamExecute implements the Primitive interface
+func (k *Kill) TryStreamExecute(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable, wantfields bool, callback func(*sqltypes.Result) error) error {
+	qr, err := k.TryExecute(ctx, vcursor, bindVars, wantfields)
+	if err != nil {
+		return err
+	}
+	return callback(qr)
+}
+
+// GetFields implements the Primitive interface
+func (k *Kill) GetFields(ctx context.Context, vcursor VCursor, bindVars map[string]*querypb.BindVariable) (*sqltypes.Result, error) {
+	return &sqltypes.Result{}, nil
+}
+
+// description implements the Primitive interface
+func (k *Kill) description() PrimitiveDescription {
+	return PrimitiveDescription{
+		OperatorType: "Kill",
+		Variant:      k.Type.ToString(),
+		Other: map[string]any{
+			"ProcesslistID": k.ProcesslistID,
+		},
+	}
+}
@@ -109,6 +109,9 @@ type (
 
 		// ShowExec takes in show command and use executor to execute the query, they are used when topo access is involved.
 		ShowExec(ctx context.Context, command sqlparser.ShowCommandType, filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
+		// Kill interrupts the statement running on a connection of vtgate,
+		// and closes the connection for a KILL CONNECTION.
+		Kill(ctx context.Context, processlistID uint64, killType sqlparser.KillType) error
 		// SetExec takes in k,v pair and use executor to set them in topo metadata.
 		SetExec(ctx context.Context, name string, value string) error
 
@@ -196,6 +199,9 @@ type (
 		// SetQueryTimeout sets the query timeout
 		SetQueryTimeout(queryTimeout int64)
 
+		// SetMaxReplicationLag sets the maximum replication lag of the replicas that serve reads
+		SetMaxReplicationLag(maxReplicationLag time.Duration)
+
 		// InTransaction returns true if the session has already opened transaction or
 		// will start a transaction on the query execution.
 		InTransaction() bool
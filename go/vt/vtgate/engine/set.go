@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"vitess.io/vitess/go/vt/sysvars"
 
@@ -38,6 +39,7 @@ import (
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/schema"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 )
@@ -499,6 +501,12 @@ func (svss *SysVarSetAware) Execute(ctx context.Context, vcursor VCursor, env *e
 			return err
 		}
 		vcursor.Session().SetQueryTimeout(queryTimeout)
+	case sysvars.MaxReplicationLag.Name:
+		lag, err := svss.evalAsDuration(env)
+		if err != nil {
+			return err
+		}
+		vcursor.Session().SetMaxReplicationLag(lag)
 	case sysvars.SessionEnableSystemSettings.Name:
 		err = svss.setBoolSysVar(ctx, env, vcursor.Session().SetSessionEnableSystemSettings)
 	case sysvars.Charset.Name, sysvars.Names.Name:
@@ -589,6 +597,23 @@ func (svss *SysVarSetAware) evalAsString(env *evalengine.ExpressionEnv) (string,
 	return v.ToString(), nil
 }
 
+// evalAsDuration accepts either a number of seconds or a duration string like 5s.
+func (svss *SysVarSetAware) evalAsDuration(env *evalengine.ExpressionEnv) (time.Duration, error) {
+	value, err := env.Evaluate(svss.Expr)
+	if err != nil {
+		return 0, err
+	}
+	v := value.Value()
+	if !v.IsIntegral() && !v.IsFloat() && !v.IsDecimal() && !v.IsText() && !v.IsBinary() {
+		return 0, vterrors.NewErrorf(vtrpcpb.Code_INVALID_ARGUMENT, vterrors.WrongTypeForVar, "incorrect argument type to variable '%s': %s", svss.Name, v.Type().String())
+	}
+	d, err := sqlparser.ParseMaxReplicationLag(v.ToString())
+	if err != nil {
+		return 0, vterrors.NewErrorf(vtrpcpb.Code_INVALID_ARGUMENT, vterrors.WrongValueForVar, "variable '%s' can't be set to the value of '%s'", svss.Name, v.ToString())
+	}
+	return d, nil
+}
+
 func (svss *SysVarSetAware) setBoolSysVar(ctx context.Context, env *evalengine.ExpressionEnv, setter func(context.Context, bool) error) error {
 	value, err := env.Evaluate(svss.Expr)
 	if err != nil {
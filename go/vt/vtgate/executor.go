@@ -48,11 +48,15 @@ import (
 	"vitess.io/vitess/go/vt/sysvars"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/audit"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vtgate/evalengine"
 	"vitess.io/vitess/go/vt/vtgate/logstats"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+	"vitess.io/vitess/go/vt/vtgate/querylimiter"
+	"vitess.io/vitess/go/vt/vtgate/queryrules"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vtgate/vschemaacl"
 
@@ -118,6 +122,30 @@ type Executor struct {
 	// truncateErrorLen truncates errors sent to client if they are above this value
 	// (0 means do not truncate).
 	truncateErrorLen int
+
+	// queryLimiter limits the rate and concurrency of queries. It is nil when
+	// the queries are not limited.
+	queryLimiter *querylimiter.Limiter
+
+	// queryRules are the query rules enforced before the queries are sent
+	// to the tablets.
+	queryRules *queryrules.Map
+
+	// queryDigests are the statistics of the queries by fingerprint. It is
+	// nil when they are disabled.
+	queryDigests *querydigest.Table
+
+	// auditLogger records the audit log. It is nil when the audit log is
+	// disabled.
+	auditLogger *audit.Logger
+
+	// processList tracks the MySQL protocol connections, for SHOW
+	// PROCESSLIST and KILL.
+	processList *processList
+
+	// txReplay replays the transactions lost in a failover. It is nil when
+	// they are not replayed.
+	txReplay *txReplayer
 }
 
 var executorOnce sync.Once
@@ -125,6 +153,7 @@ var executorOnce sync.Once
 const pathQueryPlans = "/debug/query_plans"
 const pathScatterStats = "/debug/scatter_stats"
 const pathVSchema = "/debug/vschema"
+const pathQueryRules = "/debug/vtgate_query_rules"
 
 // NewExecutor creates a new Executor.
 func NewExecutor(
@@ -152,6 +181,8 @@ func NewExecutor(
 		schemaTracker:   schemaTracker,
 		allowScatter:    !noScatter,
 		pv:              pv,
+		queryRules:      queryrules.NewMap(),
+		processList:     newProcessList(processlistAdminUsers),
 	}
 
 	vschemaacl.Init()
@@ -186,6 +217,8 @@ func NewExecutor(
 		servenv.HTTPHandle(pathQueryPlans, e)
 		servenv.HTTPHandle(pathScatterStats, e)
 		servenv.HTTPHandle(pathVSchema, e)
+		servenv.HTTPHandle(pathQueryRules, e)
+		servenv.HTTPHandle(pathQueryDigests, e)
 	})
 	return e
 }
@@ -196,9 +229,20 @@ func (e *Executor) Execute(ctx context.Context, method string, safeSession *Safe
 	span.Annotate("method", method)
 	trace.AnnotateSQL(span, sqlparser.Preview(sql))
 	defer span.Finish()
+	ctx = e.auditContext(ctx)
 
 	logStats := logstats.NewLogStats(ctx, method, sql, safeSession.GetSessionUUID(), bindVars)
+	var txStmt *txStatement
+	if e.txReplay != nil {
+		txStmt = e.txReplay.before(safeSession, sql, bindVars)
+	}
 	stmtType, result, err := e.execute(ctx, safeSession, sql, bindVars, logStats)
+	if err != nil && txStmt.canReplay(err) {
+		stmtType, result, err = e.replayTransaction(ctx, safeSession, txStmt, logStats, err)
+	}
+	if e.txReplay != nil {
+		e.txReplay.after(safeSession, txStmt, result, err)
+	}
 	logStats.Error = err
 	if result == nil {
 		saveSessionStats(safeSession, stmtType, 0, 0, 0, err)
@@ -216,6 +260,8 @@ func (e *Executor) Execute(ctx context.Context, method string, safeSession *Safe
 
 	logStats.SaveEndTime()
 	QueryLogger.Send(logStats)
+	e.recordQueryDigest(logStats)
+	e.recordAudit(ctx, logStats, sql)
 	err = vterrors.TruncateError(err, truncateErrorLen)
 	return result, err
 }
@@ -254,8 +300,14 @@ func (e *Executor) StreamExecute(
 	span.Annotate("method", method)
 	trace.AnnotateSQL(span, sqlparser.Preview(sql))
 	defer span.Finish()
+	ctx = e.auditContext(ctx)
 
 	logStats := logstats.NewLogStats(ctx, method, sql, safeSession.GetSessionUUID(), bindVars)
+	if e.txReplay != nil {
+		// The streamed results cannot be journaled, so the transaction is
+		// not replayed.
+		e.txReplay.forget(safeSession)
+	}
 	srr := &streaminResultReceiver{callback: callback}
 	var err error
 
@@ -349,6 +401,8 @@ func (e *Executor) StreamExecute(
 
 	logStats.SaveEndTime()
 	QueryLogger.Send(logStats)
+	e.recordQueryDigest(logStats)
+	e.recordAudit(ctx, logStats, sql)
 	return vterrors.TruncateError(err, truncateErrorLen)
 
 }
@@ -398,6 +452,19 @@ func (e *Executor) execute(ctx context.Context, safeSession *SafeSession, sql st
 	return stmtType, qr, err
 }
 
+// acquireQueryLimit waits until the query is within the query limits of its
+// key, and returns the function to call when the query ends.
+func (e *Executor) acquireQueryLimit(ctx context.Context, safeSession *SafeSession, plan *engine.Plan) (func(), error) {
+	if e.queryLimiter == nil || !e.queryLimiter.Enabled() {
+		return func() {}, nil
+	}
+	workload := safeSession.GetOptions().GetWorkloadName()
+	if workload == "" {
+		workload = safeSession.GetOptions().GetWorkload().String()
+	}
+	return e.queryLimiter.Acquire(ctx, e.queryLimiter.Key(ctx, workload, plan.Original))
+}
+
 // addNeededBindVars adds bind vars that are needed by the plan
 func (e *Executor) addNeededBindVars(bindVarNeeds *sqlparser.BindVarNeeds, bindVars map[string]*querypb.BindVariable, session *SafeSession) error {
 	for _, funcName := range bindVarNeeds.NeedFunctionResult {
@@ -420,6 +487,8 @@ func (e *Executor) addNeededBindVars(bindVarNeeds *sqlparser.BindVarNeeds, bindV
 			bindVars[key] = sqltypes.BoolBindVariable(session.Autocommit)
 		case sysvars.QueryTimeout.Name:
 			bindVars[key] = sqltypes.Int64BindVariable(session.GetQueryTimeout())
+		case sysvars.MaxReplicationLag.Name:
+			bindVars[key] = sqltypes.StringBindVariable(session.GetMaxReplicationLag().String())
 		case sysvars.ClientFoundRows.Name:
 			var v bool
 			ifOptionsExist(session, func(options *querypb.ExecuteOptions) {
@@ -630,6 +699,9 @@ func (e *Executor) executeSPInAllSessions(ctx context.Context, safeSession *Safe
 // CloseSession releases the current connection, which rollbacks open transactions and closes reserved connections.
 // It is called then the MySQL servers closes the connection to its client.
 func (e *Executor) CloseSession(ctx context.Context, safeSession *SafeSession) error {
+	if e.txReplay != nil {
+		e.txReplay.forget(safeSession)
+	}
 	return e.txConn.ReleaseAll(ctx, safeSession)
 }
 
@@ -1137,6 +1209,10 @@ func (e *Executor) ServeHTTP(response http.ResponseWriter, request *http.Request
 		returnAsJSON(response, e.VSchema())
 	case pathScatterStats:
 		e.WriteScatterStats(response)
+	case pathQueryRules:
+		returnAsJSON(response, e.queryRules)
+	case pathQueryDigests:
+		e.serveQueryDigests(response, request)
 	default:
 		response.WriteHeader(http.StatusNotFound)
 	}
@@ -1262,7 +1338,7 @@ func (e *Executor) prepare(ctx context.Context, safeSession *SafeSession, sql st
 	case sqlparser.StmtSelect, sqlparser.StmtShow:
 		return e.handlePrepare(ctx, safeSession, sql, bindVars, logStats)
 	case sqlparser.StmtDDL, sqlparser.StmtBegin, sqlparser.StmtCommit, sqlparser.StmtRollback, sqlparser.StmtSet, sqlparser.StmtInsert, sqlparser.StmtReplace, sqlparser.StmtUpdate, sqlparser.StmtDelete,
-		sqlparser.StmtUse, sqlparser.StmtOther, sqlparser.StmtComment, sqlparser.StmtExplain, sqlparser.StmtFlush:
+		sqlparser.StmtUse, sqlparser.StmtOther, sqlparser.StmtComment, sqlparser.StmtExplain, sqlparser.StmtFlush, sqlparser.StmtKill:
 		return nil, nil
 	}
 	return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "[BUG] unrecognized prepare statement: %s", sql)
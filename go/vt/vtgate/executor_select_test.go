@@ -810,14 +810,16 @@ func TestSelectInitializedVitessAwareVariable(t *testing.T) {
 	primarySession.Autocommit = true
 	primarySession.EnableSystemSettings = true
 	primarySession.QueryTimeout = 75
+	primarySession.MaxReplicationLag = 5000
 
 	defer func() {
 		primarySession.Autocommit = false
 		primarySession.EnableSystemSettings = false
 		primarySession.QueryTimeout = 0
+		primarySession.MaxReplicationLag = 0
 	}()
 
-	sql := "select @@autocommit, @@enable_system_settings, @@query_timeout"
+	sql := "select @@autocommit, @@enable_system_settings, @@query_timeout, @@max_replication_lag"
 
 	result, err := executorExec(executor, sql, nil)
 	wantResult := &sqltypes.Result{
@@ -825,11 +827,13 @@ func TestSelectInitializedVitessAwareVariable(t *testing.T) {
 			{Name: "@@autocommit", Type: sqltypes.Int64},
 			{Name: "@@enable_system_settings", Type: sqltypes.Int64},
 			{Name: "@@query_timeout", Type: sqltypes.Int64},
+			{Name: "@@max_replication_lag", Type: sqltypes.VarChar},
 		},
 		Rows: [][]sqltypes.Value{{
 			sqltypes.NewInt64(1),
 			sqltypes.NewInt64(1),
 			sqltypes.NewInt64(75),
+			sqltypes.NewVarChar("5s"),
 		}},
 	}
 	require.NoError(t, err)
@@ -266,6 +266,15 @@ func TestExecutorSet(t *testing.T) {
 	}, {
 		in:  "set @@query_timeout = 50, query_timeout = 75",
 		out: &vtgatepb.Session{Autocommit: true, QueryTimeout: 75},
+	}, {
+		in:  "set @@max_replication_lag = '5s'",
+		out: &vtgatepb.Session{Autocommit: true, MaxReplicationLag: 5000},
+	}, {
+		in:  "set max_replication_lag = 2",
+		out: &vtgatepb.Session{Autocommit: true, MaxReplicationLag: 2000},
+	}, {
+		in:  "set @@max_replication_lag = 'soon'",
+		err: "variable 'max_replication_lag' can't be set to the value of 'soon'",
 	}}
 	for i, tcase := range testcases {
 		t.Run(fmt.Sprintf("%d-%s", i, tcase.in), func(t *testing.T) {
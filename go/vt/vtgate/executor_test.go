@@ -27,6 +27,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/safehtml/template"
 
@@ -46,6 +47,11 @@ import (
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/callerid"
 	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/audit"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+	"vitess.io/vitess/go/vt/vtgate/querylimiter"
+	"vitess.io/vitess/go/vt/vtgate/queryrules"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vtgate/vschemaacl"
 
@@ -2742,6 +2748,138 @@ func TestExecutorFlushStmt(t *testing.T) {
 	}
 }
 
+func TestExecutorQueryLimits(t *testing.T) {
+	executor, _, _, _ := createExecutorEnv()
+	queryLimiter, err := querylimiter.New(querylimiter.Config{Key: querylimiter.KeyWorkload, MaxQPS: 1})
+	require.NoError(t, err)
+	executor.queryLimiter = queryLimiter
+
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary"})
+	_, err = exec(executor, session, "select id from main1")
+	require.NoError(t, err)
+
+	// Transaction statements are not limited.
+	_, err = exec(executor, session, "begin")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "rollback")
+	require.NoError(t, err)
+
+	_, err = exec(executor, session, "select id from main1")
+	require.EqualError(t, err, "query limit exceeded for workload 'UNSPECIFIED': too many queries (limit: 1 queries per second)")
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+
+	// Each workload has its own limits.
+	_, err = exec(executor, session, "select /*vt+ WORKLOAD_NAME=batch */ id from main1")
+	require.NoError(t, err)
+}
+
+func TestExecutorQueryRules(t *testing.T) {
+	executor, _, _, _ := createExecutorEnv()
+	qrs, err := queryrules.Parse([]byte(`[{
+		"Name": "bounded_user_scans",
+		"Tables": ["user"],
+		"Action": "MAX_SHARDS",
+		"MaxShards": 2
+	}, {
+		"Name": "no_reports",
+		"Description": "reports run elsewhere",
+		"Comment": "/\\* report \\*/",
+		"Action": "FAIL"
+	}]`))
+	require.NoError(t, err)
+	executor.queryRules.Set(queryrules.FileSource, qrs)
+
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary"})
+	_, err = exec(executor, session, "select id from user")
+	require.EqualError(t, err, "query would touch 8 shards, more than the 2 allowed by query rule bounded_user_scans")
+	_, err = exec(executor, session, "select id from user where id = 1")
+	require.NoError(t, err)
+	// The shards of all the routes of a query count against the limit.
+	_, err = exec(executor, session, "select u1.id from user u1, user u2 where u1.id = 1 and u2.id = 3")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "select u1.id from user u1, user u2, user u3 where u1.id = 1 and u2.id = 3 and u3.id = 5")
+	require.EqualError(t, err, "query would touch 3 shards, more than the 2 allowed by query rule bounded_user_scans")
+
+	_, err = exec(executor, session, "/* report */ select id from music where id = 1")
+	require.EqualError(t, err, "disallowed due to query rule no_reports: reports run elsewhere")
+	_, err = exec(executor, session, "select /* report */ id from music where id = 1")
+	require.EqualError(t, err, "disallowed due to query rule no_reports: reports run elsewhere")
+	_, err = exec(executor, session, "select id from music where id = 1")
+	require.NoError(t, err)
+}
+
+func TestExecutorQueryDigests(t *testing.T) {
+	executor, _, _, _ := createExecutorEnv()
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary"})
+
+	_, err := exec(executor, session, "show vitess_query_digests")
+	require.EqualError(t, err, "query digests are disabled, see --query_digests_size")
+
+	executor.normalize = true
+	executor.queryDigests = querydigest.NewTable(10)
+	for _, id := range []string{"1", "2", "3"} {
+		_, err = exec(executor, session, "select id from music where id = "+id)
+		require.NoError(t, err)
+	}
+	_, err = exec(executor, session, "select id from user where id = 1")
+	require.NoError(t, err)
+
+	qr, err := exec(executor, session, "show vitess_query_digests like '%from music where%'")
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	row := qr.Named().Rows[0]
+	assert.Equal(t, "select id from music where id = :id /* INT64 */", row.AsString("Query", ""))
+	assert.Equal(t, "SELECT", row.AsString("StmtType", ""))
+	assert.Equal(t, "3", row.AsString("Count", ""))
+	assert.Equal(t, "0", row.AsString("Errors", ""))
+	assert.Equal(t, "3", row.AsString("RowsExamined", ""))
+	assert.Equal(t, "3", row.AsString("ShardQueries", ""))
+
+	qr, err = exec(executor, session, "show vitess_query_digests like '%from `user` where%'")
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	assert.Equal(t, "1", qr.Named().Rows[0].AsString("Count", ""))
+
+	// The SHOW queries are recorded as well.
+	qr, err = exec(executor, session, "show vitess_query_digests like 'show%'")
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 2)
+}
+
+// auditSink keeps the audit events it gets.
+type auditSink chan *vtgatepb.AuditEvent
+
+func (as auditSink) Name() string                           { return "test" }
+func (as auditSink) Write(event *vtgatepb.AuditEvent) error { as <- event; return nil }
+func (as auditSink) Close() error                           { return nil }
+
+func TestExecutorAuditLog(t *testing.T) {
+	executor, _, _, _ := createExecutorEnv()
+	executor.auditLogger = audit.NewLogger(audit.Filter{StatementTypes: []string{"UPDATE", "DDL"}}, false, 10)
+	defer executor.auditLogger.Close()
+	sink := make(auditSink, 10)
+	executor.auditLogger.AddSink(sink)
+
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "session-uuid"})
+	_, err := exec(executor, session, "select id from user where id = 1")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "update user set a = 2 where id = 1")
+	require.NoError(t, err)
+
+	select {
+	case event := <-sink:
+		assert.Equal(t, "UPDATE", event.StatementType)
+		assert.Equal(t, "update user set a = 2 where id = 1", event.Sql)
+		assert.Equal(t, "session-uuid", event.SessionUuid)
+		assert.Equal(t, []string{"TestExecutor"}, event.Keyspaces)
+		assert.Equal(t, []string{"TestExecutor/-20"}, event.Shards)
+		assert.Empty(t, event.Error)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no audit event")
+	}
+	assert.Empty(t, sink)
+}
+
 func exec(executor *Executor, session *SafeSession, sql string) (*sqltypes.Result, error) {
 	return executor.Execute(context.Background(), "TestExecute", session, sql, nil)
 }
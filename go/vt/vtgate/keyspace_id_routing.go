@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+// resolveFunc resolves destinations of a keyspace, along with the ids
+// that produced them, like srvtopo.Resolver.ResolveDestinations does.
+type resolveFunc[T any] func(keyspace string, ids []T, destinations []key.Destination) ([]*srvtopo.ResolvedShard, [][]T, error)
+
+// resolveWithKeyspaceIDRoutingRules resolves destinations of a keyspace,
+// honoring its keyspace id routing rules. A keyspace id that falls within a
+// rule goes to the shard of the rule instead of its regular shard. Scatter
+// destinations also include the shards of the rules that point to another
+// keyspace, since those shards are not part of the keyspace. Key range
+// destinations include the shards of the rules they intersect, and skip the
+// regular shards if a single rule covers them, as the keyspace ids of a rule
+// no longer live on their regular shards. MoveTenant deletes them from there
+// when it switches traffic.
+func resolveWithKeyspaceIDRoutingRules[T any](keyspace string, rules []*vindexes.KeyspaceIDRoutingRule, ids []T, destinations []key.Destination, resolve resolveFunc[T]) ([]*srvtopo.ResolvedShard, [][]T, error) {
+	if len(rules) == 0 {
+		return resolve(keyspace, ids, destinations)
+	}
+
+	var (
+		regularIDs          []T
+		regularDestinations []key.Destination
+		pinnedIDs           = make(map[*vindexes.KeyspaceIDRoutingRule][]T)
+		pinnedCount         = make(map[*vindexes.KeyspaceIDRoutingRule]int)
+	)
+	for i, destination := range destinations {
+		pinned, regular := splitDestination(keyspace, rules, destination)
+		if regular != nil {
+			regularDestinations = append(regularDestinations, regular)
+			if ids != nil {
+				regularIDs = append(regularIDs, ids[i])
+			}
+		}
+		for _, rule := range pinned {
+			pinnedCount[rule]++
+			if ids != nil {
+				pinnedIDs[rule] = append(pinnedIDs[rule], ids[i])
+			}
+		}
+	}
+
+	var (
+		rss    []*srvtopo.ResolvedShard
+		values [][]T
+		err    error
+	)
+	if len(regularDestinations) > 0 {
+		rss, values, err = resolve(keyspace, regularIDs, regularDestinations)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Iterate over the rules rather than the maps, to keep the order of the
+	// resolved shards stable.
+	for _, rule := range rules {
+		count, ok := pinnedCount[rule]
+		if !ok {
+			continue
+		}
+		pinnedDestinations := make([]key.Destination, count)
+		for i := range pinnedDestinations {
+			pinnedDestinations[i] = key.DestinationShard(rule.ToShard)
+		}
+		prss, pvalues, err := resolve(rule.ToKeyspace, pinnedIDs[rule], pinnedDestinations)
+		if err != nil {
+			return nil, nil, err
+		}
+		rss, values = mergeResolvedShards(rss, values, prss, pvalues)
+	}
+	return rss, values, nil
+}
+
+// splitDestination returns the rules whose shards the destination must be
+// sent to, and what is left of the destination for the regular shards of
+// the keyspace, or nil if nothing is left.
+func splitDestination(keyspace string, rules []*vindexes.KeyspaceIDRoutingRule, destination key.Destination) ([]*vindexes.KeyspaceIDRoutingRule, key.Destination) {
+	switch d := destination.(type) {
+	case key.DestinationKeyspaceID:
+		if rule := findKeyspaceIDRoutingRule(rules, d); rule != nil {
+			return []*vindexes.KeyspaceIDRoutingRule{rule}, nil
+		}
+		return nil, destination
+	case key.DestinationKeyspaceIDs:
+		var (
+			pinned  []*vindexes.KeyspaceIDRoutingRule
+			regular key.DestinationKeyspaceIDs
+		)
+		for _, ksid := range d {
+			rule := findKeyspaceIDRoutingRule(rules, ksid)
+			if rule == nil {
+				regular = append(regular, ksid)
+				continue
+			}
+			if !containsRule(pinned, rule) {
+				pinned = append(pinned, rule)
+			}
+		}
+		if len(regular) == 0 {
+			return pinned, nil
+		}
+		return pinned, regular
+	case key.DestinationAllShards:
+		return otherKeyspaceRules(keyspace, rules), destination
+	case key.DestinationKeyRange:
+		return splitKeyRange(rules, d.KeyRange, destination)
+	case key.DestinationExactKeyRange:
+		return splitKeyRange(rules, d.KeyRange, destination)
+	}
+	return nil, destination
+}
+
+// splitKeyRange returns the rules whose key range intersects the key range of
+// a destination, and the destination itself unless one of them covers it.
+func splitKeyRange(rules []*vindexes.KeyspaceIDRoutingRule, keyRange *topodatapb.KeyRange, destination key.Destination) ([]*vindexes.KeyspaceIDRoutingRule, key.Destination) {
+	var pinned []*vindexes.KeyspaceIDRoutingRule
+	for _, rule := range rules {
+		if !key.KeyRangeIntersect(keyRange, rule.KeyRange) {
+			continue
+		}
+		if key.KeyRangeContainsKeyRange(rule.KeyRange, keyRange) {
+			return []*vindexes.KeyspaceIDRoutingRule{rule}, nil
+		}
+		pinned = append(pinned, rule)
+	}
+	return pinned, destination
+}
+
+func findKeyspaceIDRoutingRule(rules []*vindexes.KeyspaceIDRoutingRule, ksid []byte) *vindexes.KeyspaceIDRoutingRule {
+	for _, rule := range rules {
+		if key.KeyRangeContains(rule.KeyRange, ksid) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// otherKeyspaceRules returns the rules that point to another keyspace.
+func otherKeyspaceRules(keyspace string, rules []*vindexes.KeyspaceIDRoutingRule) []*vindexes.KeyspaceIDRoutingRule {
+	var result []*vindexes.KeyspaceIDRoutingRule
+	for _, rule := range rules {
+		if rule.ToKeyspace != keyspace {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+func containsRule(rules []*vindexes.KeyspaceIDRoutingRule, rule *vindexes.KeyspaceIDRoutingRule) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeResolvedShards appends the resolved shards of b to a. The values of a
+// shard that is already in a are appended to its existing values.
+func mergeResolvedShards[T any](rss []*srvtopo.ResolvedShard, values [][]T, brss []*srvtopo.ResolvedShard, bvalues [][]T) ([]*srvtopo.ResolvedShard, [][]T) {
+outer:
+	for i, brs := range brss {
+		for j, rs := range rss {
+			if rs.Target.Keyspace == brs.Target.Keyspace && rs.Target.Shard == brs.Target.Shard {
+				if bvalues != nil {
+					values[j] = append(values[j], bvalues[i]...)
+				}
+				continue outer
+			}
+		}
+		rss = append(rss, brs)
+		if bvalues != nil {
+			values = append(values, bvalues[i])
+		}
+	}
+	return rss, values
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+// fakeShardResolve resolves destinations against keyspaces that all have
+// the -80 and 80- shards.
+func fakeShardResolve(keyspace string, ids []string, destinations []key.Destination) ([]*srvtopo.ResolvedShard, [][]string, error) {
+	var (
+		rss    []*srvtopo.ResolvedShard
+		values [][]string
+	)
+	add := func(shard string, i int) {
+		for j, rs := range rss {
+			if rs.Target.Shard == shard {
+				if ids != nil {
+					values[j] = append(values[j], ids[i])
+				}
+				return
+			}
+		}
+		rss = append(rss, &srvtopo.ResolvedShard{Target: &querypb.Target{Keyspace: keyspace, Shard: shard}})
+		if ids != nil {
+			values = append(values, []string{ids[i]})
+		}
+	}
+	for i, destination := range destinations {
+		switch d := destination.(type) {
+		case key.DestinationShard:
+			add(string(d), i)
+		case key.DestinationKeyspaceID:
+			if d[0] < 0x80 {
+				add("-80", i)
+			} else {
+				add("80-", i)
+			}
+		case key.DestinationKeyspaceIDs:
+			for _, ksid := range d {
+				if ksid[0] < 0x80 {
+					add("-80", i)
+				} else {
+					add("80-", i)
+				}
+			}
+		case key.DestinationAllShards:
+			add("-80", i)
+			add("80-", i)
+		case key.DestinationKeyRange:
+			if key.KeyRangeIntersect(d.KeyRange, &topodatapb.KeyRange{End: []byte{0x80}}) {
+				add("-80", i)
+			}
+			if key.KeyRangeIntersect(d.KeyRange, &topodatapb.KeyRange{Start: []byte{0x80}}) {
+				add("80-", i)
+			}
+		}
+	}
+	return rss, values, nil
+}
+
+func resolvedTargets(rss []*srvtopo.ResolvedShard) []string {
+	targets := make([]string, len(rss))
+	for i, rs := range rss {
+		targets[i] = rs.Target.Keyspace + "/" + rs.Target.Shard
+	}
+	return targets
+}
+
+func TestResolveWithKeyspaceIDRoutingRules(t *testing.T) {
+	rules := []*vindexes.KeyspaceIDRoutingRule{{
+		KeyRange:   &topodatapb.KeyRange{Start: []byte{0x40}, End: []byte{0x41}},
+		ToKeyspace: "vip",
+		ToShard:    "-",
+	}, {
+		KeyRange:   &topodatapb.KeyRange{Start: []byte{0x90}, End: []byte{0x91}},
+		ToKeyspace: "ks",
+		ToShard:    "-80",
+	}}
+
+	testcases := []struct {
+		name         string
+		ids          []string
+		destinations []key.Destination
+		wantTargets  []string
+		wantValues   [][]string
+	}{{
+		name:         "regular keyspace id",
+		ids:          []string{"a"},
+		destinations: []key.Destination{key.DestinationKeyspaceID([]byte{0x10})},
+		wantTargets:  []string{"ks/-80"},
+		wantValues:   [][]string{{"a"}},
+	}, {
+		name:         "pinned keyspace id",
+		ids:          []string{"a"},
+		destinations: []key.Destination{key.DestinationKeyspaceID([]byte{0x40, 0x01})},
+		wantTargets:  []string{"vip/-"},
+		wantValues:   [][]string{{"a"}},
+	}, {
+		name:         "pinned within the keyspace",
+		ids:          []string{"a"},
+		destinations: []key.Destination{key.DestinationKeyspaceID([]byte{0x90, 0x01})},
+		wantTargets:  []string{"ks/-80"},
+		wantValues:   [][]string{{"a"}},
+	}, {
+		name: "mixed keyspace ids",
+		ids:  []string{"a", "b", "c"},
+		destinations: []key.Destination{
+			key.DestinationKeyspaceID([]byte{0x10}),
+			key.DestinationKeyspaceID([]byte{0x40, 0x01}),
+			key.DestinationKeyspaceID([]byte{0x90, 0x01}),
+		},
+		wantTargets: []string{"ks/-80", "vip/-"},
+		wantValues:  [][]string{{"a", "c"}, {"b"}},
+	}, {
+		name: "keyspace ids split across rules",
+		destinations: []key.Destination{
+			key.DestinationKeyspaceIDs{[]byte{0xa0}, []byte{0x40, 0x01}},
+		},
+		wantTargets: []string{"ks/80-", "vip/-"},
+	}, {
+		name:         "all shards include the pinned shards of other keyspaces",
+		destinations: []key.Destination{key.DestinationAllShards{}},
+		wantTargets:  []string{"ks/-80", "ks/80-", "vip/-"},
+	}, {
+		name:         "key range within a rule only goes to its shard",
+		destinations: []key.Destination{key.DestinationKeyRange{KeyRange: &topodatapb.KeyRange{Start: []byte{0x40, 0x10}, End: []byte{0x40, 0x20}}}},
+		wantTargets:  []string{"vip/-"},
+	}, {
+		name:         "key range within a rule of the keyspace only goes to its shard",
+		destinations: []key.Destination{key.DestinationKeyRange{KeyRange: &topodatapb.KeyRange{Start: []byte{0x90}, End: []byte{0x91}}}},
+		wantTargets:  []string{"ks/-80"},
+	}, {
+		name:         "key range overlapping rules includes their shards",
+		destinations: []key.Destination{key.DestinationKeyRange{KeyRange: &topodatapb.KeyRange{Start: []byte{0x88}, End: []byte{0xa0}}}},
+		wantTargets:  []string{"ks/80-", "ks/-80"},
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			rss, values, err := resolveWithKeyspaceIDRoutingRules("ks", rules, tc.ids, tc.destinations, fakeShardResolve)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantTargets, resolvedTargets(rss))
+			assert.Equal(t, tc.wantValues, values)
+		})
+	}
+}
+
+func TestResolveWithoutKeyspaceIDRoutingRules(t *testing.T) {
+	rss, _, err := resolveWithKeyspaceIDRoutingRules[string]("ks", nil, nil, []key.Destination{key.DestinationAllShards{}}, fakeShardResolve)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ks/-80", "ks/80-"}, resolvedTargets(rss))
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/safehtml"
@@ -42,13 +44,16 @@ type LogStats struct {
 	Method         string
 	TabletType     string
 	StmtType       string
+	PlanType       string // PlanType is the route type of the plan, e.g. Scatter
 	SQL            string
+	NormalizedSQL  string // NormalizedSQL is the query as planned, without its margin comments
 	BindVariables  map[string]*querypb.BindVariable
 	StartTime      time.Time
 	EndTime        time.Time
 	ShardQueries   uint64
 	RowsAffected   uint64
 	RowsReturned   uint64
+	RowsExamined   uint64 // RowsExamined is the rows the shards returned to vtgate
 	PlanTime       time.Duration
 	ExecuteTime    time.Duration
 	CommitTime     time.Duration
@@ -121,6 +126,35 @@ func (stats *LogStats) RemoteAddrUsername() (string, string) {
 	return ci.RemoteAddr(), ci.Username()
 }
 
+// SlowLogEntry returns the entry of the query in the slow query log.
+func (stats *LogStats) SlowLogEntry() *streamlog.SlowLogEntry {
+	remoteAddr, _ := stats.RemoteAddrUsername()
+	return &streamlog.SlowLogEntry{
+		Time:         stats.EndTime,
+		User:         stats.ImmediateCaller(),
+		Host:         remoteAddr,
+		QueryTime:    stats.TotalTime(),
+		RowsSent:     stats.RowsReturned,
+		RowsExamined: stats.RowsExamined,
+		RowsAffected: stats.RowsAffected,
+		Database:     stats.ActiveKeyspace,
+		Fields: []streamlog.SlowLogField{
+			{Name: "method", Value: stats.Method},
+			{Name: "stmt_type", Value: stats.StmtType},
+			{Name: "plan_type", Value: stats.PlanType},
+			{Name: "tablet_type", Value: stats.TabletType},
+			{Name: "shard_queries", Value: strconv.FormatUint(stats.ShardQueries, 10)},
+			{Name: "tables", Value: strings.Join(stats.TablesUsed, ",")},
+			{Name: "plan_time", Value: fmt.Sprintf("%.6f", stats.PlanTime.Seconds())},
+			{Name: "execute_time", Value: fmt.Sprintf("%.6f", stats.ExecuteTime.Seconds())},
+			{Name: "commit_time", Value: fmt.Sprintf("%.6f", stats.CommitTime.Seconds())},
+			{Name: "cached_plan", Value: strconv.FormatBool(stats.CachedPlan)},
+			{Name: "session_uuid", Value: stats.SessionUUID},
+		},
+		SQL: stats.SQL,
+	}
+}
+
 // Logf formats the log record to the given writer, either as
 // tab-separated list of logged fields or as JSON.
 func (stats *LogStats) Logf(w io.Writer, params url.Values) error {
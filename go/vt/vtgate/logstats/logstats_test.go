@@ -242,3 +242,30 @@ func TestLogStatsRemoteAddrUsername(t *testing.T) {
 		t.Fatalf("expected to get username: %s, but got: %s", username, user)
 	}
 }
+
+func TestLogStatsSlowLogEntry(t *testing.T) {
+	callInfo := &fakecallinfo.FakeCallInfo{Remote: "10.0.0.1:51234", User: "app"}
+	ctx := callinfo.NewContext(context.Background(), callInfo)
+	logStats := NewLogStats(ctx, "Execute", "select * from user", "suuid", nil)
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 5, 500000000, time.UTC)
+	logStats.StmtType = "SELECT"
+	logStats.PlanType = "Scatter"
+	logStats.TabletType = "REPLICA"
+	logStats.ShardQueries = 4
+	logStats.RowsReturned = 10
+	logStats.RowsExamined = 40
+	logStats.TablesUsed = []string{"ks.user"}
+	logStats.ActiveKeyspace = "ks"
+
+	var b bytes.Buffer
+	require.NoError(t, logStats.SlowLogEntry().Format(&b))
+	want := "# Time: 2017-01-01T01:02:05.500000Z\n" +
+		"# User@Host: [] @  [10.0.0.1]\n" +
+		"# Query_time: 2.500000  Rows_sent: 10  Rows_examined: 40  Rows_affected: 0\n" +
+		"# Vitess_method: Execute  Vitess_stmt_type: SELECT  Vitess_plan_type: Scatter  Vitess_tablet_type: REPLICA  Vitess_shard_queries: 4  Vitess_tables: ks.user  Vitess_plan_time: 0.000000  Vitess_execute_time: 0.000000  Vitess_commit_time: 0.000000  Vitess_cached_plan: false  Vitess_session_uuid: suuid\n" +
+		"use ks;\n" +
+		"SET timestamp=1483232525;\n" +
+		"select * from user;\n"
+	assert.Equal(t, want, b.String())
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// The fallbacks for reads with a maximum replication lag, when no replica
+// is within the lag.
+const (
+	// MaxReplicationLagFallbackPrimary sends the read to the primary.
+	MaxReplicationLagFallbackPrimary = "primary"
+	// MaxReplicationLagFallbackError fails the read.
+	MaxReplicationLagFallbackError = "error"
+)
+
+var maxReplicationLagFallback = MaxReplicationLagFallbackPrimary
+
+func init() {
+	servenv.OnParseFor("vtgate", func(fs *pflag.FlagSet) {
+		fs.StringVar(&maxReplicationLagFallback, "max_replication_lag_fallback", MaxReplicationLagFallbackPrimary, fmt.Sprintf("What to do with a read bounded by max_replication_lag when no replica is within the lag. Allowed values: %s (send the read to the primary), %s (fail the read).", MaxReplicationLagFallbackPrimary, MaxReplicationLagFallbackError))
+	})
+}
+
+type maxReplicationLagKey struct{}
+
+// contextWithMaxReplicationLag returns a context that bounds the replication
+// lag of the replicas that serve the reads of the statement. The
+// MAX_REPLICATION_LAG comment directive takes precedence over the
+// max_replication_lag session variable.
+func contextWithMaxReplicationLag(ctx context.Context, stmt sqlparser.Statement, session *SafeSession) (context.Context, error) {
+	lag, err := sqlparser.GetMaxReplicationLagFromStatement(stmt)
+	if err != nil {
+		return nil, err
+	}
+	if lag == 0 {
+		lag = session.GetMaxReplicationLag()
+	}
+	if lag == 0 {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, maxReplicationLagKey{}, lag), nil
+}
+
+// maxReplicationLagFromContext returns the maximum replication lag set on the
+// context, if any.
+func maxReplicationLagFromContext(ctx context.Context) (time.Duration, bool) {
+	lag, ok := ctx.Value(maxReplicationLagKey{}).(time.Duration)
+	return lag, ok
+}
+
+// filterByReplicationLag returns the tablets whose replication lag is within
+// the given maximum.
+func filterByReplicationLag(tablets []*discovery.TabletHealth, maxLag time.Duration) []*discovery.TabletHealth {
+	var filtered []*discovery.TabletHealth
+	for _, th := range tablets {
+		if th.Stats == nil {
+			continue
+		}
+		if time.Duration(th.Stats.ReplicationLagSeconds)*time.Second <= maxLag {
+			filtered = append(filtered, th)
+		}
+	}
+	return filtered
+}
+
+// replicasWithinLag narrows the tablets of a replica target down to the ones
+// within the maximum replication lag of the context. When none of them is,
+// it falls back to the primary, or fails, depending on
+// --max_replication_lag_fallback. It returns the target the tablets serve.
+//
+// A call that opens a transaction or a reserved connection never falls back,
+// as the session records the replica target for the connection.
+func (gw *TabletGateway) replicasWithinLag(ctx context.Context, target *querypb.Target, tablets []*discovery.TabletHealth, opensConn bool) (*querypb.Target, []*discovery.TabletHealth, error) {
+	maxLag, ok := maxReplicationLagFromContext(ctx)
+	if !ok || target.TabletType == topodatapb.TabletType_PRIMARY {
+		return target, tablets, nil
+	}
+	if filtered := filterByReplicationLag(tablets, maxLag); len(filtered) > 0 {
+		return target, filtered, nil
+	}
+
+	if opensConn {
+		return target, nil, vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "no healthy tablet with a replication lag of at most %v available for '%s', and a transaction or reserved connection cannot fall back to the primary", maxLag, target.String())
+	}
+	if gw.maxReplicationLagFallback == MaxReplicationLagFallbackError {
+		return target, nil, vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "no healthy tablet with a replication lag of at most %v available for '%s'", maxLag, target.String())
+	}
+	primaryTarget := &querypb.Target{
+		Keyspace:   target.Keyspace,
+		Shard:      target.Shard,
+		TabletType: topodatapb.TabletType_PRIMARY,
+		Cell:       target.Cell,
+	}
+	tablets = gw.hc.GetHealthyTabletStats(primaryTarget)
+	if len(tablets) == 0 {
+		return target, nil, vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "no healthy tablet with a replication lag of at most %v available for '%s', and no healthy primary to fall back to", maxLag, target.String())
+	}
+	return primaryTarget, tablets, nil
+}
+
+// opensDedicatedConn returns whether the query service call of the given name
+// opens a transaction or a reserved connection.
+func opensDedicatedConn(name string) bool {
+	return strings.HasPrefix(name, "Begin") || strings.HasPrefix(name, "Reserve")
+}
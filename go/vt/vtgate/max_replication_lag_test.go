@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+func TestContextWithMaxReplicationLag(t *testing.T) {
+	testCases := []struct {
+		query      string
+		sessionLag int64
+		wantLag    time.Duration
+		wantErr    string
+	}{{
+		query: "select 1 from t",
+	}, {
+		query:      "select 1 from t",
+		sessionLag: 3000,
+		wantLag:    3 * time.Second,
+	}, {
+		query:      "select /*vt+ MAX_REPLICATION_LAG=500ms */ 1 from t",
+		sessionLag: 3000,
+		wantLag:    500 * time.Millisecond,
+	}, {
+		query:   "select /*vt+ MAX_REPLICATION_LAG=10 */ 1 from t",
+		wantLag: 10 * time.Second,
+	}, {
+		query:   "select /*vt+ MAX_REPLICATION_LAG=later */ 1 from t",
+		wantErr: "invalid max replication lag: later",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			stmt, err := sqlparser.Parse(tc.query)
+			require.NoError(t, err)
+			session := NewSafeSession(&vtgatepb.Session{MaxReplicationLag: tc.sessionLag})
+
+			ctx, err := contextWithMaxReplicationLag(context.Background(), stmt, session)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			lag, ok := maxReplicationLagFromContext(ctx)
+			assert.Equal(t, tc.wantLag != 0, ok)
+			assert.Equal(t, tc.wantLag, lag)
+		})
+	}
+}
@@ -99,6 +99,25 @@ func (e *Executor) newExecute(
 		return err
 	}
 
+	ctx, err = contextWithMaxReplicationLag(ctx, stmt, safeSession)
+	if err != nil {
+		logStats.Error = err
+		return err
+	}
+
+	ctx, err = e.applyQueryRules(ctx, safeSession, vcursor, plan, stmt, comments)
+	if err != nil {
+		logStats.Error = err
+		return err
+	}
+
+	release, err := e.acquireQueryLimit(ctx, safeSession, plan)
+	if err != nil {
+		logStats.Error = err
+		return err
+	}
+	defer release()
+
 	if plan.Instructions.NeedsTransaction() {
 		return e.insideTransaction(ctx, safeSession, logStats,
 			func() error {
@@ -290,6 +309,10 @@ func (e *Executor) logPlanningFinished(logStats *logstats.LogStats, plan *engine
 	execStart := time.Now()
 	if plan != nil {
 		logStats.StmtType = plan.Type.String()
+		logStats.NormalizedSQL = plan.Original
+		if plan.Instructions != nil {
+			logStats.PlanType = plan.Instructions.RouteType()
+		}
 	}
 	logStats.PlanTime = execStart.Sub(logStats.StartTime)
 	return execStart
@@ -284,6 +284,8 @@ func createInstructionFor(ctx context.Context, query string, stmt sqlparser.Stat
 		return dropPreparedStatement(vschema, stmt)
 	case *sqlparser.ExecuteStmt:
 		return buildExecuteStmtPlan(ctx, vschema, stmt)
+	case *sqlparser.Kill:
+		return buildKillPlan(stmt)
 	case *sqlparser.CommentOnly:
 		// There is only a comment in the input.
 		// This is essentially a No-op
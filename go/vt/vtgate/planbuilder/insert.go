@@ -391,9 +391,13 @@ func modifyForAutoinc(ins *sqlparser.Insert, eins *engine.Insert) error {
 		return nil
 	}
 	colNum := findOrAddColumn(ins, eins.Table.AutoIncrement.Column)
-	eins.Generate = &engine.Generate{
-		Keyspace: eins.Table.AutoIncrement.Sequence.Keyspace,
-		Query:    fmt.Sprintf("select next :n values from %s", sqlparser.String(eins.Table.AutoIncrement.Sequence.Name)),
+	if generator := eins.Table.AutoIncrement.Generator; generator != nil {
+		eins.Generate = &engine.Generate{Generator: generator}
+	} else {
+		eins.Generate = &engine.Generate{
+			Keyspace: eins.Table.AutoIncrement.Sequence.Keyspace,
+			Query:    fmt.Sprintf("select next :n values from %s", sqlparser.String(eins.Table.AutoIncrement.Sequence.Name)),
+		}
 	}
 	switch rows := ins.Rows.(type) {
 	case sqlparser.SelectStatement:
@@ -0,0 +1,29 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package planbuilder
+
+import (
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+)
+
+// buildKillPlan builds the plan of a KILL statement, which vtgate runs on
+// its own connections.
+func buildKillPlan(stmt *sqlparser.Kill) (*planResult, error) {
+	prim := &engine.Kill{Type: stmt.Type, ProcesslistID: stmt.ProcesslistID}
+	return newPlanResult(prim), nil
+}
@@ -121,6 +121,7 @@ func (vh *vtgateHandler) NewConnection(c *mysql.Conn) {
 	vh.mu.Lock()
 	defer vh.mu.Unlock()
 	vh.connections[c] = true
+	vh.vtg.executor.processList.add(uint64(c.ConnectionID), c.Close)
 }
 
 func (vh *vtgateHandler) numConnections() int {
@@ -147,6 +148,7 @@ func (vh *vtgateHandler) ConnectionClosed(c *mysql.Conn) {
 		vh.mu.Lock()
 		defer vh.mu.Unlock()
 		delete(vh.connections, c)
+		vh.vtg.executor.processList.remove(uint64(c.ConnectionID))
 	}()
 
 	var ctx context.Context
@@ -236,11 +238,13 @@ func (vh *vtgateHandler) ComQuery(c *mysql.Conn, query string, callback func(*sq
 		}
 	}()
 
+	ctx, finish := vh.startQuery(ctx, c, session, query)
 	if session.Options.Workload == querypb.ExecuteOptions_OLAP {
-		err := vh.vtg.StreamExecute(ctx, session, query, make(map[string]*querypb.BindVariable), callback)
+		err := finish(vh.vtg.StreamExecute(ctx, session, query, make(map[string]*querypb.BindVariable), callback))
 		return mysql.NewSQLErrorFromError(err)
 	}
 	session, result, err := vh.vtg.Execute(ctx, session, query, make(map[string]*querypb.BindVariable))
+	err = finish(err)
 
 	if err := mysql.NewSQLErrorFromError(err); err != nil {
 		return err
@@ -249,6 +253,14 @@ func (vh *vtgateHandler) ComQuery(c *mysql.Conn, query string, callback func(*sq
 	return callback(result)
 }
 
+// startQuery records the statement of the connection in the process list,
+// for SHOW PROCESSLIST and KILL. The returned function must be called with
+// the result of the statement.
+func (vh *vtgateHandler) startQuery(ctx context.Context, c *mysql.Conn, session *vtgatepb.Session, query string) (context.Context, func(error) error) {
+	user := callerid.GetUsername(callerid.ImmediateCallerIDFromContext(ctx))
+	return vh.vtg.executor.processList.startQuery(ctx, uint64(c.ConnectionID), user, c.RemoteAddr().String(), session.TargetString, query)
+}
+
 func fillInTxStatusFlags(c *mysql.Conn, session *vtgatepb.Session) {
 	if session.InTransaction {
 		c.StatusFlags |= mysql.ServerStatusInTrans
@@ -339,11 +351,13 @@ func (vh *vtgateHandler) ComStmtExecute(c *mysql.Conn, prepare *mysql.PrepareDat
 		}
 	}()
 
+	ctx, finish := vh.startQuery(ctx, c, session, prepare.PrepareStmt)
 	if session.Options.Workload == querypb.ExecuteOptions_OLAP {
-		err := vh.vtg.StreamExecute(ctx, session, prepare.PrepareStmt, prepare.BindVars, callback)
+		err := finish(vh.vtg.StreamExecute(ctx, session, prepare.PrepareStmt, prepare.BindVars, callback))
 		return mysql.NewSQLErrorFromError(err)
 	}
 	_, qr, err := vh.vtg.Execute(ctx, session, prepare.PrepareStmt, prepare.BindVars)
+	err = finish(err)
 	if err != nil {
 		err = mysql.NewSQLErrorFromError(err)
 		return err
@@ -0,0 +1,263 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+var killCount = stats.NewCountersWithSingleLabel("VtgateKills", "KILL statements run on the connections of vtgate, by type", "Type")
+
+// processList tracks the MySQL protocol connections of vtgate and the
+// statements they run, for SHOW PROCESSLIST and KILL.
+type processList struct {
+	// adminUsers can see and kill the connections of all the users. The
+	// other users only see and kill their own connections.
+	adminUsers []string
+
+	mu        sync.Mutex
+	processes map[uint64]*process
+}
+
+// process is a MySQL protocol connection of vtgate.
+type process struct {
+	id        uint64
+	closeConn func()
+
+	mu sync.Mutex
+	// user and host are only known once the connection ran a statement.
+	user  string
+	host  string
+	db    string
+	query string
+	// since is the time the connection started its statement, or the time
+	// it became idle.
+	since  time.Time
+	cancel context.CancelFunc
+	killed bool
+	shards []string
+}
+
+func newProcessList(adminUsers []string) *processList {
+	return &processList{
+		adminUsers: adminUsers,
+		processes:  make(map[uint64]*process),
+	}
+}
+
+// add registers a connection. closeConn closes it, for KILL CONNECTION.
+func (pl *processList) add(id uint64, closeConn func()) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.processes[id] = &process{
+		id:        id,
+		closeConn: closeConn,
+		since:     time.Now(),
+	}
+}
+
+// remove unregisters a closed connection.
+func (pl *processList) remove(id uint64) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	delete(pl.processes, id)
+}
+
+func (pl *processList) get(id uint64) *process {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.processes[id]
+}
+
+type processKey struct{}
+
+// startQuery records that the connection runs a statement. It returns the
+// context of the statement, which KILL cancels, and the function to call with
+// the result of the statement once it is done. That function returns the
+// error to send to the client, which is ER_QUERY_INTERRUPTED if the statement
+// was killed.
+func (pl *processList) startQuery(ctx context.Context, id uint64, user, host, db, query string) (context.Context, func(error) error) {
+	p := pl.get(id)
+	if p == nil {
+		return ctx, func(err error) error { return err }
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	ctx = context.WithValue(ctx, processKey{}, p)
+
+	p.mu.Lock()
+	p.user = user
+	p.host = host
+	p.db = db
+	p.query = query
+	p.since = time.Now()
+	p.cancel = cancel
+	p.killed = false
+	p.shards = nil
+	p.mu.Unlock()
+
+	return ctx, func(err error) error {
+		cancel()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		killed := p.killed
+		p.query = ""
+		p.since = time.Now()
+		p.cancel = nil
+		p.killed = false
+		p.shards = nil
+		if killed {
+			return vterrors.NewErrorf(vtrpcpb.Code_CANCELED, vterrors.QueryInterrupted, "Query execution was interrupted")
+		}
+		return err
+	}
+}
+
+// addProcessShards records the shards the statement of the context is sent
+// to, for SHOW PROCESSLIST.
+func addProcessShards(ctx context.Context, rss []*srvtopo.ResolvedShard) {
+	p, ok := ctx.Value(processKey{}).(*process)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, rs := range rss {
+		shard := rs.Target.Keyspace + "/" + rs.Target.Shard
+		if !slices.Contains(p.shards, shard) {
+			p.shards = append(p.shards, shard)
+		}
+	}
+}
+
+// canManage returns true if the caller of the context can see and kill the
+// connections of the user.
+func (pl *processList) canManage(ctx context.Context, user string) bool {
+	caller := callerid.GetUsername(callerid.ImmediateCallerIDFromContext(ctx))
+	return caller == user || slices.Contains(pl.adminUsers, caller)
+}
+
+// kill interrupts the statement running on a connection, and closes the
+// connection for a KILL CONNECTION. Cancelling the context of the statement
+// cancels its queries to the tablets, which kill them in MySQL.
+func (pl *processList) kill(ctx context.Context, id uint64, killType sqlparser.KillType) error {
+	p := pl.get(id)
+	if p == nil {
+		return vterrors.VT05008(id)
+	}
+	p.mu.Lock()
+	if !pl.canManage(ctx, p.user) {
+		p.mu.Unlock()
+		return vterrors.VT07001(id)
+	}
+	if p.cancel != nil {
+		p.killed = true
+		p.cancel()
+	}
+	p.mu.Unlock()
+
+	killCount.Add(strings.ToUpper(killType.ToString()), 1)
+	if killType == sqlparser.ConnectionType {
+		p.closeConn()
+	}
+	return nil
+}
+
+var processListFields = []*querypb.Field{
+	{Name: "Id", Type: sqltypes.Uint64},
+	{Name: "User", Type: sqltypes.VarChar},
+	{Name: "Host", Type: sqltypes.VarChar},
+	{Name: "db", Type: sqltypes.VarChar},
+	{Name: "Command", Type: sqltypes.VarChar},
+	{Name: "Time", Type: sqltypes.Int64},
+	{Name: "State", Type: sqltypes.VarChar},
+	{Name: "Info", Type: sqltypes.VarChar},
+	{Name: "Shards", Type: sqltypes.VarChar},
+}
+
+// show returns the connections the caller of the context can see, in the
+// format of SHOW PROCESSLIST, with the shards their statement was sent to.
+// The connections that never ran a statement have an unauthenticated user,
+// like in MySQL.
+func (pl *processList) show(ctx context.Context) *sqltypes.Result {
+	pl.mu.Lock()
+	processes := make([]*process, 0, len(pl.processes))
+	for _, p := range pl.processes {
+		processes = append(processes, p)
+	}
+	pl.mu.Unlock()
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].id < processes[j].id
+	})
+
+	now := time.Now()
+	rows := [][]sqltypes.Value{}
+	for _, p := range processes {
+		p.mu.Lock()
+		if p.user == "" || pl.canManage(ctx, p.user) {
+			user := p.user
+			if user == "" {
+				user = "unauthenticated user"
+			}
+			command, state, info := "Sleep", "", sqltypes.NULL
+			if p.cancel != nil {
+				command, state, info = "Query", "executing", sqltypes.NewVarChar(p.query)
+			}
+			rows = append(rows, []sqltypes.Value{
+				sqltypes.NewUint64(p.id),
+				sqltypes.NewVarChar(user),
+				sqltypes.NewVarChar(p.host),
+				sqltypes.NewVarChar(p.db),
+				sqltypes.NewVarChar(command),
+				sqltypes.NewInt64(int64(now.Sub(p.since) / time.Second)),
+				sqltypes.NewVarChar(state),
+				info,
+				sqltypes.NewVarChar(strings.Join(p.shards, ",")),
+			})
+		}
+		p.mu.Unlock()
+	}
+	return &sqltypes.Result{
+		Fields: processListFields,
+		Rows:   rows,
+	}
+}
+
+// showProcessList returns the connections of vtgate for SHOW PROCESSLIST.
+func (e *Executor) showProcessList(ctx context.Context) *sqltypes.Result {
+	return e.processList.show(ctx)
+}
+
+// kill runs a KILL statement on the connections of vtgate.
+func (e *Executor) kill(ctx context.Context, id uint64, killType sqlparser.KillType) error {
+	return e.processList.kill(ctx, id, killType)
+}
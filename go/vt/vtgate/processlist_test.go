@@ -0,0 +1,104 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+func callerContext(user string) context.Context {
+	return callerid.NewContext(context.Background(), nil, callerid.NewImmediateCallerID(user))
+}
+
+func TestProcessListShow(t *testing.T) {
+	pl := newProcessList([]string{"dba"})
+	pl.add(1, func() {})
+	pl.add(2, func() {})
+	pl.add(3, func() {})
+
+	ctx, finish := pl.startQuery(callerContext("app"), 1, "app", "10.0.0.1:1234", "commerce", "select * from customer")
+	addProcessShards(ctx, []*srvtopo.ResolvedShard{
+		{Target: &querypb.Target{Keyspace: "commerce", Shard: "-80"}},
+		{Target: &querypb.Target{Keyspace: "commerce", Shard: "80-"}},
+	})
+	addProcessShards(ctx, []*srvtopo.ResolvedShard{{Target: &querypb.Target{Keyspace: "commerce", Shard: "-80"}}})
+	_, finish2 := pl.startQuery(callerContext("reporting"), 2, "reporting", "10.0.0.2:1234", "commerce", "select 1")
+	require.NoError(t, finish2(nil))
+
+	// An admin user sees all the connections.
+	result := pl.show(callerContext("dba"))
+	require.Len(t, result.Rows, 3)
+	assert.Equal(t, `[UINT64(1) VARCHAR("app") VARCHAR("10.0.0.1:1234") VARCHAR("commerce") VARCHAR("Query") INT64(0) VARCHAR("executing") VARCHAR("select * from customer") VARCHAR("commerce/-80,commerce/80-")]`, fmt.Sprintf("%v", result.Rows[0]))
+	assert.Equal(t, `[UINT64(2) VARCHAR("reporting") VARCHAR("10.0.0.2:1234") VARCHAR("commerce") VARCHAR("Sleep") INT64(0) VARCHAR("") NULL VARCHAR("")]`, fmt.Sprintf("%v", result.Rows[1]))
+	assert.Equal(t, `[UINT64(3) VARCHAR("unauthenticated user") VARCHAR("") VARCHAR("") VARCHAR("Sleep") INT64(0) VARCHAR("") NULL VARCHAR("")]`, fmt.Sprintf("%v", result.Rows[2]))
+
+	// The other users only see their own connections.
+	result = pl.show(callerContext("app"))
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, "app", result.Rows[0][1].ToString())
+	assert.Equal(t, "unauthenticated user", result.Rows[1][1].ToString())
+
+	require.NoError(t, finish(nil))
+	pl.remove(1)
+	assert.Len(t, pl.show(callerContext("dba")).Rows, 2)
+}
+
+func TestProcessListKill(t *testing.T) {
+	pl := newProcessList([]string{"dba"})
+	closed := false
+	pl.add(1, func() { closed = true })
+
+	ctx, finish := pl.startQuery(callerContext("app"), 1, "app", "10.0.0.1:1234", "commerce", "select sleep(100)")
+
+	err := pl.kill(callerContext("reporting"), 1, sqlparser.QueryType)
+	assert.EqualError(t, err, "VT07001: you are not owner of thread 1")
+	assert.NoError(t, ctx.Err())
+	err = pl.kill(callerContext("dba"), 2, sqlparser.QueryType)
+	assert.EqualError(t, err, "VT05008: unknown thread id: 2")
+
+	before := killCount.Counts()["QUERY"]
+	require.NoError(t, pl.kill(callerContext("app"), 1, sqlparser.QueryType))
+	assert.Equal(t, before+1, killCount.Counts()["QUERY"])
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.False(t, closed)
+	err = finish(errors.New("context canceled"))
+	assert.EqualError(t, err, "Query execution was interrupted")
+	assert.Equal(t, vtrpcpb.Code_CANCELED, vterrors.Code(err))
+	assert.Equal(t, vterrors.QueryInterrupted, vterrors.ErrState(err))
+
+	// The next statement of the connection is not interrupted.
+	_, finish = pl.startQuery(callerContext("app"), 1, "app", "10.0.0.1:1234", "commerce", "select 1")
+	assert.NoError(t, finish(nil))
+
+	// KILL CONNECTION of an idle connection closes it.
+	require.NoError(t, pl.kill(callerContext("dba"), 1, sqlparser.ConnectionType))
+	assert.True(t, closed)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+const pathQueryDigests = "/debug/query_digests"
+
+// recordQueryDigest adds a query to its digest, if the digests are enabled.
+func (e *Executor) recordQueryDigest(logStats *logstats.LogStats) {
+	if e.queryDigests != nil {
+		e.queryDigests.Record(logStats)
+	}
+}
+
+// showQueryDigests returns the query digests, the longest total latency
+// first. The LIKE filter applies to the query of the digests.
+func (e *Executor) showQueryDigests(filter *sqlparser.ShowFilter) (*sqltypes.Result, error) {
+	if e.queryDigests == nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "query digests are disabled, see --query_digests_size")
+	}
+
+	rows := [][]sqltypes.Value{}
+	for _, d := range e.queryDigests.Top(0) {
+		if filter != nil && filter.Like != "" && !sqlparser.LikeToRegexp(filter.Like).MatchString(d.Query) {
+			continue
+		}
+		rows = append(rows, buildVarCharRow(
+			d.Fingerprint,
+			d.Query,
+			d.StmtType,
+			strings.Join(d.Tables, ","),
+			strconv.FormatUint(d.Count, 10),
+			strconv.FormatUint(d.Errors, 10),
+			formatSeconds(d.TotalTime),
+			formatSeconds(d.AvgTime()),
+			formatSeconds(d.MinTime),
+			formatSeconds(d.MaxTime),
+			formatSeconds(d.Percentile(50)),
+			formatSeconds(d.Percentile(95)),
+			formatSeconds(d.Percentile(99)),
+			strconv.FormatUint(d.RowsReturned, 10),
+			strconv.FormatUint(d.RowsAffected, 10),
+			strconv.FormatUint(d.RowsExamined, 10),
+			strconv.FormatUint(d.ShardQueries, 10),
+			d.FirstSeen.UTC().Format(time.RFC3339),
+			d.LastSeen.UTC().Format(time.RFC3339),
+		))
+	}
+	return &sqltypes.Result{
+		Fields: buildVarCharFields("Fingerprint", "Query", "StmtType", "Tables", "Count", "Errors", "TotalTime", "AvgTime", "MinTime", "MaxTime", "P50Time", "P95Time", "P99Time", "RowsReturned", "RowsAffected", "RowsExamined", "ShardQueries", "FirstSeen", "LastSeen"),
+		Rows:   rows,
+	}, nil
+}
+
+// formatSeconds formats a duration as seconds, with a microsecond precision.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
+
+// serveQueryDigests serves the query digests as JSON, the longest total
+// latency first, at most the limit parameter of them if set. A POST resets
+// them.
+func (e *Executor) serveQueryDigests(response http.ResponseWriter, request *http.Request) {
+	if e.queryDigests == nil {
+		http.Error(response, "query digests are disabled, see --query_digests_size", http.StatusNotFound)
+		return
+	}
+	if request.Method == http.MethodPost {
+		if err := acl.CheckAccessHTTP(request, acl.ADMIN); err != nil {
+			acl.SendError(response, err)
+			return
+		}
+		e.queryDigests.Reset()
+		returnAsJSON(response, []any{})
+		return
+	}
+
+	limit := 0
+	if l := request.FormValue("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			http.Error(response, fmt.Sprintf("invalid limit %q: %v", l, err), http.StatusBadRequest)
+			return
+		}
+	}
+	returnAsJSON(response, e.queryDigests.Top(limit))
+}
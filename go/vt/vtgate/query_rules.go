@@ -0,0 +1,158 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/queryrules"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// applyQueryRules enforces the query rules the query matches. It fails or
+// delays the query, bounds the number of shards it may touch through the
+// returned context, and forces the tablet type of the vcursor.
+func (e *Executor) applyQueryRules(ctx context.Context, safeSession *SafeSession, vcursor *vcursorImpl, plan *engine.Plan, stmt sqlparser.Statement, comments sqlparser.MarginComments) (context.Context, error) {
+	if e.queryRules == nil || e.queryRules.Empty() {
+		return ctx, nil
+	}
+
+	effects := e.queryRules.Match(&queryrules.Query{
+		SQL:      plan.Original,
+		Tables:   queryTables(plan, stmt, vcursor.keyspace),
+		PlanType: plan.Type,
+		User:     callerid.GetUsername(callerid.ImmediateCallerIDFromContext(ctx)),
+		Comments: queryComments(stmt, comments),
+	})
+	if effects.Err != nil {
+		return nil, effects.Err
+	}
+	if effects.Delay > 0 {
+		timer := time.NewTimer(effects.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, vterrors.Errorf(vtrpcpb.Code_CANCELED, "query delayed by query rules: %v", ctx.Err())
+		}
+	}
+	if effects.MaxShards > 0 {
+		ctx = context.WithValue(ctx, maxShardsKey{}, &maxShards{limit: effects.MaxShards, rule: effects.MaxShardsRule})
+	}
+	if effects.TabletType != topodatapb.TabletType_UNKNOWN && plan.Type == sqlparser.StmtSelect && !safeSession.InTransaction() {
+		vcursor.tabletType = effects.TabletType
+	}
+	return ctx, nil
+}
+
+// queryTables returns the tables of the plan. The plans of the V3 planner do
+// not list their tables, so the tables of the statement are used for them,
+// qualified with the keyspace of the session when they are not.
+func queryTables(plan *engine.Plan, stmt sqlparser.Statement, keyspace string) []string {
+	if len(plan.TablesUsed) > 0 {
+		return plan.TablesUsed
+	}
+	var tables []string
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if tn, ok := node.(sqlparser.TableName); ok && !tn.Name.IsEmpty() {
+			ks := tn.Qualifier.String()
+			if ks == "" {
+				ks = keyspace
+			}
+			if ks == "" {
+				tables = append(tables, tn.Name.String())
+			} else {
+				tables = append(tables, ks+"."+tn.Name.String())
+			}
+		}
+		return true, nil
+	}, stmt)
+	return tables
+}
+
+// queryComments returns the margin comments of a query and the comments of
+// its statement.
+func queryComments(stmt sqlparser.Statement, comments sqlparser.MarginComments) []string {
+	var out []string
+	if c := strings.TrimSpace(comments.Leading); c != "" {
+		out = append(out, c)
+	}
+	if commented, ok := stmt.(sqlparser.Commented); ok {
+		if parsed := commented.GetParsedComments(); parsed.Length() > 0 {
+			out = append(out, strings.TrimSpace(sqlparser.String(parsed)))
+		}
+	}
+	if c := strings.TrimSpace(comments.Trailing); c != "" {
+		out = append(out, c)
+	}
+	return out
+}
+
+type maxShardsKey struct{}
+
+// maxShards is the maximum number of shards a query may touch, and the
+// query rule that set it. It also records the shards the routes of the query
+// touched so far, so that the limit applies to the whole query rather than
+// to each of its routes.
+type maxShards struct {
+	limit int
+	rule  string
+
+	mu     sync.Mutex
+	shards map[string]bool
+}
+
+// checkMaxShards fails if the query rules of the context do not allow a
+// query to touch the given shards on top of those its previous routes
+// touched.
+func checkMaxShards(ctx context.Context, rss []*srvtopo.ResolvedShard) error {
+	ms, ok := ctx.Value(maxShardsKey{}).(*maxShards)
+	if !ok {
+		return nil
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	added := make(map[string]bool)
+	for _, rs := range rss {
+		key := rs.Target.Keyspace + "/" + rs.Target.Shard
+		if !ms.shards[key] {
+			added[key] = true
+		}
+	}
+	count := len(ms.shards) + len(added)
+	if count <= ms.limit {
+		if ms.shards == nil {
+			ms.shards = make(map[string]bool)
+		}
+		for key := range added {
+			ms.shards[key] = true
+		}
+		return nil
+	}
+	return vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "query would touch %d shards, more than the %d allowed by query rule %s", count, ms.limit, ms.rule)
+}
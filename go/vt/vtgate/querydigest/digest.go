@@ -0,0 +1,339 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package querydigest keeps statistics of the queries vtgate executes, by
+fingerprint, like the events_statements_summary_by_digest table of the MySQL
+performance_schema.
+
+The fingerprint of a query is a hash of the query as vtgate plans it, which
+is the normalized query when the queries are normalized. Each digest counts
+the executions and the errors of its queries, and sums their latencies, the
+rows they return, affect or examine and the queries they send to the shards.
+It also keeps a histogram of the latencies, to estimate their percentiles, and
+a history by minute of the last 60 minutes with queries, to follow the error
+rates and the rows examined over time.
+
+The table holds a bounded number of digests. Once it is full, the queries of
+new fingerprints are summed in a single digest without a fingerprint, until
+the table is reset.
+*/
+package querydigest
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+)
+
+// latencyCutoffs are the upper bounds of the buckets of the latency
+// histograms. The last bucket has no upper bound.
+var latencyCutoffs = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+const (
+	// historyInterval is the length of the intervals of the history of a
+	// digest.
+	historyInterval = time.Minute
+	// historySize is the number of intervals a digest keeps.
+	historySize = 60
+)
+
+// Interval is the statistics of the queries of a digest that ended within an
+// interval of time.
+type Interval struct {
+	Start        time.Time
+	Count        uint64
+	Errors       uint64
+	TotalTime    time.Duration
+	RowsExamined uint64
+}
+
+// MarshalJSON marshals the Interval with its error rate.
+func (iv Interval) MarshalJSON() ([]byte, error) {
+	type noMethods Interval
+	return json.Marshal(struct {
+		noMethods
+		ErrorRate float64
+	}{
+		noMethods: noMethods(iv),
+		ErrorRate: iv.ErrorRate(),
+	})
+}
+
+// ErrorRate returns the fraction of the queries of the interval that failed.
+func (iv Interval) ErrorRate() float64 {
+	if iv.Count == 0 {
+		return 0
+	}
+	return float64(iv.Errors) / float64(iv.Count)
+}
+
+// Digest is the statistics of the queries of a fingerprint.
+type Digest struct {
+	Fingerprint  string
+	Query        string
+	StmtType     string
+	Tables       []string `json:",omitempty"`
+	Count        uint64
+	Errors       uint64
+	TotalTime    time.Duration
+	MinTime      time.Duration
+	MaxTime      time.Duration
+	RowsReturned uint64
+	RowsAffected uint64
+	RowsExamined uint64
+	ShardQueries uint64
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	// History holds the statistics of the last historySize intervals in
+	// which the queries ran, the oldest first.
+	History []Interval `json:",omitempty"`
+
+	// buckets counts the queries by latency, buckets[i] being the queries
+	// that took at most latencyCutoffs[i], and the last one those that took
+	// longer than all of them.
+	buckets []uint64
+}
+
+func newDigest(fingerprint, query, stmtType string, tables []string) *Digest {
+	return &Digest{
+		Fingerprint: fingerprint,
+		Query:       query,
+		StmtType:    stmtType,
+		Tables:      tables,
+		buckets:     make([]uint64, len(latencyCutoffs)+1),
+	}
+}
+
+func (d *Digest) copy() *Digest {
+	dcopy := *d
+	dcopy.buckets = append([]uint64(nil), d.buckets...)
+	dcopy.History = append([]Interval(nil), d.History...)
+	return &dcopy
+}
+
+// MarshalJSON marshals the Digest with its average latency, latency
+// percentiles and error rate.
+func (d *Digest) MarshalJSON() ([]byte, error) {
+	type noMethods Digest
+	return json.Marshal(struct {
+		*noMethods
+		AvgTime   time.Duration
+		P50Time   time.Duration
+		P95Time   time.Duration
+		P99Time   time.Duration
+		ErrorRate float64
+	}{
+		noMethods: (*noMethods)(d),
+		AvgTime:   d.AvgTime(),
+		P50Time:   d.Percentile(50),
+		P95Time:   d.Percentile(95),
+		P99Time:   d.Percentile(99),
+		ErrorRate: d.ErrorRate(),
+	})
+}
+
+// AvgTime returns the average latency of the queries.
+func (d *Digest) AvgTime() time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.TotalTime / time.Duration(d.Count)
+}
+
+// ErrorRate returns the fraction of the queries that failed.
+func (d *Digest) ErrorRate() float64 {
+	if d.Count == 0 {
+		return 0
+	}
+	return float64(d.Errors) / float64(d.Count)
+}
+
+// Percentile estimates the latency under which the given percentage of the
+// queries ran. It returns the upper bound of the histogram bucket of that
+// percentile, capped by the maximum latency.
+func (d *Digest) Percentile(p float64) time.Duration {
+	if d.Count == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(d.Count))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range d.buckets {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyCutoffs) && latencyCutoffs[i] < d.MaxTime {
+				return latencyCutoffs[i]
+			}
+			return d.MaxTime
+		}
+	}
+	return d.MaxTime
+}
+
+func (d *Digest) add(logStats *logstats.LogStats) {
+	latency := logStats.TotalTime()
+	if d.Count == 0 || latency < d.MinTime {
+		d.MinTime = latency
+	}
+	if latency > d.MaxTime {
+		d.MaxTime = latency
+	}
+	if d.FirstSeen.IsZero() {
+		d.FirstSeen = logStats.EndTime
+	}
+	d.LastSeen = logStats.EndTime
+	d.Count++
+	if logStats.Error != nil {
+		d.Errors++
+	}
+	d.TotalTime += latency
+	d.RowsReturned += logStats.RowsReturned
+	d.RowsAffected += logStats.RowsAffected
+	d.RowsExamined += logStats.RowsExamined
+	d.ShardQueries += logStats.ShardQueries
+	d.buckets[sort.Search(len(latencyCutoffs), func(i int) bool { return latency <= latencyCutoffs[i] })]++
+
+	if iv := d.interval(logStats.EndTime); iv != nil {
+		iv.Count++
+		if logStats.Error != nil {
+			iv.Errors++
+		}
+		iv.TotalTime += latency
+		iv.RowsExamined += logStats.RowsExamined
+	}
+}
+
+// interval returns the interval of the history that contains the given
+// time, adding it if needed and then dropping the oldest interval if the
+// history is full. It returns nil if the time is older than the history.
+func (d *Digest) interval(t time.Time) *Interval {
+	start := t.Truncate(historyInterval)
+	i := sort.Search(len(d.History), func(i int) bool { return !d.History[i].Start.Before(start) })
+	if i < len(d.History) && d.History[i].Start.Equal(start) {
+		return &d.History[i]
+	}
+	if i == 0 && len(d.History) == historySize {
+		return nil
+	}
+	d.History = append(d.History, Interval{})
+	copy(d.History[i+1:], d.History[i:])
+	d.History[i] = Interval{Start: start}
+	if len(d.History) > historySize {
+		d.History = append(d.History[:0], d.History[1:]...)
+		i--
+	}
+	return &d.History[i]
+}
+
+// Table is a bounded table of digests.
+type Table struct {
+	size int
+
+	mu       sync.Mutex
+	digests  map[string]*Digest
+	overflow *Digest
+}
+
+// NewTable returns a Table of at most size digests.
+func NewTable(size int) *Table {
+	return &Table{
+		size:    size,
+		digests: make(map[string]*Digest),
+	}
+}
+
+// Record adds a query to the digest of its fingerprint. The queries that
+// were not planned have no normalized query, and are not recorded.
+func (t *Table) Record(logStats *logstats.LogStats) {
+	if logStats.NormalizedSQL == "" {
+		return
+	}
+	fingerprint := sqlparser.Fingerprint(logStats.NormalizedSQL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.digests[fingerprint]
+	if !ok {
+		if len(t.digests) >= t.size {
+			if t.overflow == nil {
+				t.overflow = newDigest("", "", "", nil)
+			}
+			t.overflow.add(logStats)
+			return
+		}
+		d = newDigest(fingerprint, logStats.NormalizedSQL, logStats.StmtType, logStats.TablesUsed)
+		t.digests[fingerprint] = d
+	}
+	d.add(logStats)
+}
+
+// Top returns copies of the n digests of the longest total latency, with
+// the longest first. n <= 0 returns all of them.
+func (t *Table) Top(n int) []*Digest {
+	t.mu.Lock()
+	digests := make([]*Digest, 0, len(t.digests)+1)
+	for _, d := range t.digests {
+		digests = append(digests, d.copy())
+	}
+	if t.overflow != nil {
+		digests = append(digests, t.overflow.copy())
+	}
+	t.mu.Unlock()
+
+	sort.Slice(digests, func(i, j int) bool {
+		if digests[i].TotalTime != digests[j].TotalTime {
+			return digests[i].TotalTime > digests[j].TotalTime
+		}
+		return digests[i].Fingerprint < digests[j].Fingerprint
+	})
+	if n > 0 && len(digests) > n {
+		digests = digests[:n]
+	}
+	return digests
+}
+
+// Reset removes all the digests.
+func (t *Table) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.digests = make(map[string]*Digest)
+	t.overflow = nil
+}
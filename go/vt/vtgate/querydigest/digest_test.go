@@ -0,0 +1,136 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package querydigest
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+)
+
+func newLogStats(query string, latency time.Duration, err error) *logstats.LogStats {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	return &logstats.LogStats{
+		SQL:           query,
+		NormalizedSQL: query,
+		StmtType:      "SELECT",
+		TablesUsed:    []string{"commerce.customer"},
+		StartTime:     start,
+		EndTime:       start.Add(latency),
+		RowsReturned:  2,
+		RowsExamined:  8,
+		ShardQueries:  4,
+		Error:         err,
+	}
+}
+
+func TestRecord(t *testing.T) {
+	table := NewTable(2)
+	for i := 0; i < 98; i++ {
+		table.Record(newLogStats("select * from customer where id = :id", 2*time.Millisecond, nil))
+	}
+	table.Record(newLogStats("select * from customer where id = :id", 40*time.Millisecond, nil))
+	table.Record(newLogStats("select * from customer where id = :id", 3*time.Second, errors.New("timeout")))
+	table.Record(newLogStats("select * from corder", 10*time.Second, nil))
+	// Not planned.
+	table.Record(&logstats.LogStats{SQL: "selec 1"})
+
+	digests := table.Top(0)
+	require.Len(t, digests, 2)
+	d := digests[1]
+	assert.Equal(t, sqlparser.Fingerprint("select * from customer where id = :id"), d.Fingerprint)
+	assert.Equal(t, "select * from customer where id = :id", d.Query)
+	assert.Equal(t, []string{"commerce.customer"}, d.Tables)
+	assert.EqualValues(t, 100, d.Count)
+	assert.EqualValues(t, 1, d.Errors)
+	assert.Equal(t, 0.01, d.ErrorRate())
+	assert.Equal(t, 98*2*time.Millisecond+40*time.Millisecond+3*time.Second, d.TotalTime)
+	assert.Equal(t, 2*time.Millisecond, d.MinTime)
+	assert.Equal(t, 3*time.Second, d.MaxTime)
+	assert.Equal(t, d.TotalTime/100, d.AvgTime())
+	assert.Equal(t, 2500*time.Microsecond, d.Percentile(50))
+	assert.Equal(t, 2500*time.Microsecond, d.Percentile(95))
+	assert.Equal(t, 50*time.Millisecond, d.Percentile(99))
+	assert.Equal(t, 3*time.Second, d.Percentile(100))
+	assert.EqualValues(t, 200, d.RowsReturned)
+	assert.EqualValues(t, 800, d.RowsExamined)
+	assert.EqualValues(t, 400, d.ShardQueries)
+	require.Len(t, d.History, 1)
+	assert.EqualValues(t, 100, d.History[0].Count)
+	assert.EqualValues(t, 800, d.History[0].RowsExamined)
+	assert.Equal(t, 0.01, d.History[0].ErrorRate())
+
+	// The longest total latency comes first.
+	assert.Equal(t, "select * from corder", digests[0].Query)
+	assert.Len(t, table.Top(1), 1)
+
+	// The table is full, further fingerprints go to the overflow digest.
+	table.Record(newLogStats("select * from product", time.Millisecond, nil))
+	table.Record(newLogStats("select * from orders", time.Millisecond, nil))
+	digests = table.Top(0)
+	require.Len(t, digests, 3)
+	assert.Equal(t, "", digests[2].Fingerprint)
+	assert.EqualValues(t, 2, digests[2].Count)
+
+	data, err := json.Marshal(digests[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Count":100,"Errors":1,`)
+	assert.Contains(t, string(data), `"P99Time":50000000,"ErrorRate":0.01}`)
+
+	assert.Contains(t, string(data), `"History":[{"Start":"2023-05-01T12:00:00Z","Count":100,"Errors":1,`)
+
+	table.Reset()
+	assert.Empty(t, table.Top(0))
+}
+
+func TestHistory(t *testing.T) {
+	d := newDigest("", "", "", nil)
+	at := func(minutes int, err error) *logstats.LogStats {
+		logStats := newLogStats("select 1", time.Millisecond, err)
+		logStats.EndTime = logStats.EndTime.Add(time.Duration(minutes) * time.Minute)
+		return logStats
+	}
+	d.add(at(1, nil))
+	d.add(at(1, errors.New("timeout")))
+	d.add(at(3, errors.New("timeout")))
+	// Queries may end out of order.
+	d.add(at(2, nil))
+	d.add(at(1, nil))
+	require.Len(t, d.History, 3)
+	assert.EqualValues(t, 3, d.History[0].Count)
+	assert.InDelta(t, 1.0/3, d.History[0].ErrorRate(), 0.001)
+	assert.EqualValues(t, 1, d.History[1].Count)
+	assert.Equal(t, 1.0, d.History[2].ErrorRate())
+	assert.EqualValues(t, 24, d.History[0].RowsExamined)
+
+	// Only the last intervals are kept.
+	for i := 0; i < historySize; i++ {
+		d.add(at(10+i, nil))
+	}
+	require.Len(t, d.History, historySize)
+	assert.Equal(t, at(10, nil).EndTime.Truncate(time.Minute), d.History[0].Start)
+	d.add(at(1, nil))
+	assert.Equal(t, at(10, nil).EndTime.Truncate(time.Minute), d.History[0].Start)
+	assert.EqualValues(t, 6+historySize, d.Count)
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package querydigest
+
+import (
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+var tableSize = 1000
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&tableSize, "query_digests_size", tableSize, "Maximum number of query fingerprints vtgate keeps statistics of, shown by SHOW vitess_query_digests and /debug/query_digests. The queries of further fingerprints are summed in a single digest. 0 disables the query digests.")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewTableFromFlags returns a Table of the size set by the command line
+// flags, or nil if the query digests are disabled.
+func NewTableFromFlags() *Table {
+	if tableSize <= 0 {
+		return nil
+	}
+	return NewTable(tableSize)
+}
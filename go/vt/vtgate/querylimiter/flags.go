@@ -0,0 +1,61 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package querylimiter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+var (
+	limitKey       string
+	maxQPS         int
+	maxConcurrency int
+	queueTimeout   = time.Second
+	maxQueueSize   int
+	statsKeys      []string
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&limitKey, "query_limit_key", "", fmt.Sprintf("What the query limits of --query_limit_max_qps and --query_limit_max_concurrency apply to, each key having its own limits. Allowed values: %s, %s, %s (the WORKLOAD_NAME comment directive if set, otherwise the session workload), %s (the normalized query). Empty disables the query limits.", KeyImmediateCaller, KeyEffectiveCaller, KeyWorkload, KeyFingerprint))
+	fs.IntVar(&maxQPS, "query_limit_max_qps", 0, "Maximum number of queries per second for each key of --query_limit_key. 0 means no limit.")
+	fs.IntVar(&maxConcurrency, "query_limit_max_concurrency", 0, "Maximum number of concurrent queries for each key of --query_limit_key. 0 means no limit.")
+	fs.DurationVar(&queueTimeout, "query_limit_queue_timeout", time.Second, "How long a query over the limits of its key waits for its turn before it fails. 0 fails it right away.")
+	fs.IntVar(&maxQueueSize, "query_limit_max_queue_size", 0, "Maximum number of queries waiting for their turn for each key of --query_limit_key. Queries beyond it fail right away. 0 means no limit.")
+	fs.StringSliceVar(&statsKeys, "query_limit_stats_keys", nil, "Comma-separated list of keys of --query_limit_key that the query limiter stats break down. The queries of the other keys are counted under \"other\".")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// NewConfigFromFlags returns a Config built from the command line flags.
+func NewConfigFromFlags() Config {
+	return Config{
+		Key:            limitKey,
+		MaxQPS:         maxQPS,
+		MaxConcurrency: maxConcurrency,
+		QueueTimeout:   queueTimeout,
+		MaxQueueSize:   maxQueueSize,
+		StatsKeys:      statsKeys,
+	}
+}
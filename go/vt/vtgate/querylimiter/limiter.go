@@ -0,0 +1,317 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package querylimiter limits the rate and the concurrency of the queries vtgate
+executes, separately for each immediate caller, effective caller, workload or
+query fingerprint.
+
+A query over the limits of its key waits in a queue for its turn, up to a
+timeout, and then fails with a RESOURCE_EXHAUSTED error, which MySQL clients
+see as ER_TOO_MANY_USER_CONNECTIONS.
+
+The Limiter forgets the keys that have been idle for a while, and its stats
+only break down the keys listed in the configuration, so that neither grows
+with the number of distinct callers or queries.
+*/
+package querylimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// The keys the limits apply to.
+const (
+	// KeyImmediateCaller is the username of the immediate caller.
+	KeyImmediateCaller = "immediate_caller"
+	// KeyEffectiveCaller is the principal of the effective caller.
+	KeyEffectiveCaller = "effective_caller"
+	// KeyWorkload is the workload name of the query, or the workload of the
+	// session when the query does not have one.
+	KeyWorkload = "workload"
+	// KeyFingerprint is a hash of the normalized query.
+	KeyFingerprint = "fingerprint"
+)
+
+// The reasons a query is rejected.
+const (
+	reasonQPS         = "qps"
+	reasonConcurrency = "concurrency"
+	reasonQueueFull   = "queue_full"
+)
+
+// otherStatsKey is the stats label of the keys that are not listed in
+// Config.StatsKeys.
+const otherStatsKey = "other"
+
+// idleKeyTimeout is how long the Limiter keeps the state of a key that has
+// no query in flight or waiting for its turn.
+var idleKeyTimeout = time.Minute
+
+var (
+	queuedCount   = stats.NewCountersWithSingleLabel("QueryLimiterQueued", "Queries that waited for their turn, by limit key", "Key")
+	rejectedCount = stats.NewCountersWithMultiLabels("QueryLimiterRejected", "Queries rejected because they were over the limits, by limit key and reason", []string{"Key", "Reason"})
+	inFlight      = stats.NewGaugesWithSingleLabel("QueryLimiterInFlight", "Queries in flight, by limit key", "Key")
+	waitTimings   = stats.NewTimings("QueryLimiterWait", "Time the queries that waited for their turn spent in the queue, by limit key", "Key")
+)
+
+// Config is the configuration of a Limiter.
+type Config struct {
+	// Key is one of the Key* constants, or empty to disable the limits.
+	Key string
+	// MaxQPS is the maximum number of queries per second of each key.
+	// 0 means no limit.
+	MaxQPS int
+	// MaxConcurrency is the maximum number of concurrent queries of each
+	// key. 0 means no limit.
+	MaxConcurrency int
+	// QueueTimeout is how long a query over the limits waits for its turn.
+	QueueTimeout time.Duration
+	// MaxQueueSize is the maximum number of queries of each key waiting for
+	// their turn. 0 means no limit.
+	MaxQueueSize int
+	// StatsKeys are the keys that the stats break down. The queries of the
+	// other keys are counted under "other".
+	StatsKeys []string
+}
+
+// Limiter enforces the limits of a Config.
+type Limiter struct {
+	cfg       Config
+	statsKeys map[string]bool
+
+	// mu protects keys, lastSweep and the users and lastUsed fields of the
+	// keyLimiters.
+	mu        sync.Mutex
+	keys      map[string]*keyLimiter
+	lastSweep time.Time
+}
+
+// keyLimiter enforces the limits of a single key.
+type keyLimiter struct {
+	// rate is nil when there is no QPS limit. It is a token bucket rather
+	// than a go/ratelimiter.RateLimiter, whose fixed window can only reject
+	// a query, while a query over the QPS limit waits for its turn.
+	rate *rate.Limiter
+	// slots is nil when there is no concurrency limit. A query holds a slot
+	// by sending to it, and releases it by receiving from it.
+	slots  chan struct{}
+	queued atomic.Int64
+
+	// users is the number of queries in flight or waiting for their turn.
+	users int
+	// lastUsed is when the last of these queries ended.
+	lastUsed time.Time
+}
+
+// New returns a Limiter for the given Config.
+func New(cfg Config) (*Limiter, error) {
+	switch cfg.Key {
+	case "", KeyImmediateCaller, KeyEffectiveCaller, KeyWorkload, KeyFingerprint:
+	default:
+		return nil, fmt.Errorf("unknown query limit key %q", cfg.Key)
+	}
+	if cfg.MaxQPS < 0 || cfg.MaxConcurrency < 0 || cfg.MaxQueueSize < 0 {
+		return nil, fmt.Errorf("query limits must not be negative")
+	}
+	statsKeys := make(map[string]bool, len(cfg.StatsKeys))
+	for _, key := range cfg.StatsKeys {
+		statsKeys[key] = true
+	}
+	return &Limiter{
+		cfg:       cfg,
+		statsKeys: statsKeys,
+		keys:      make(map[string]*keyLimiter),
+		lastSweep: time.Now(),
+	}, nil
+}
+
+// Enabled returns true if the Limiter limits any query.
+func (l *Limiter) Enabled() bool {
+	return l.cfg.Key != "" && (l.cfg.MaxQPS > 0 || l.cfg.MaxConcurrency > 0)
+}
+
+// Key returns the key the limits of a query are tracked under.
+func (l *Limiter) Key(ctx context.Context, workload, normalizedQuery string) string {
+	switch l.cfg.Key {
+	case KeyImmediateCaller:
+		return callerid.GetUsername(callerid.ImmediateCallerIDFromContext(ctx))
+	case KeyEffectiveCaller:
+		return callerid.GetPrincipal(callerid.EffectiveCallerIDFromContext(ctx))
+	case KeyWorkload:
+		return workload
+	case KeyFingerprint:
+		return sqlparser.Fingerprint(normalizedQuery)
+	}
+	return ""
+}
+
+// Acquire waits until a query of the given key is within the limits, and
+// returns the function to call when the query ends. It fails if the query
+// is still over the limits after the queue timeout, or if the queue of the
+// key is full.
+func (l *Limiter) Acquire(ctx context.Context, key string) (func(), error) {
+	if !l.Enabled() {
+		return func() {}, nil
+	}
+	kl := l.acquireKey(key)
+	statsKey := l.statsKey(key)
+
+	start := time.Now()
+	queued, acquired := false, false
+	defer func() {
+		if queued {
+			kl.queued.Add(-1)
+			waitTimings.Record(statsKey, start)
+		}
+		if !acquired {
+			l.releaseKey(kl)
+		}
+	}()
+	enqueue := func() error {
+		if queued {
+			return nil
+		}
+		if l.cfg.QueueTimeout <= 0 {
+			return errLimitExceeded
+		}
+		if l.cfg.MaxQueueSize > 0 && kl.queued.Load() >= int64(l.cfg.MaxQueueSize) {
+			return errQueueFull
+		}
+		queued = true
+		kl.queued.Add(1)
+		queuedCount.Add(statsKey, 1)
+		return nil
+	}
+
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, l.cfg.QueueTimeout)
+	defer cancel()
+
+	if kl.rate != nil && !kl.rate.Allow() {
+		if err := enqueue(); err != nil {
+			return nil, l.reject(key, reasonQPS, err)
+		}
+		// Wait fails right away if the query would not get its turn before
+		// the queue timeout.
+		if err := kl.rate.Wait(ctx); err != nil {
+			return nil, l.reject(key, reasonQPS, errLimitExceeded)
+		}
+	}
+
+	if kl.slots != nil {
+		select {
+		case kl.slots <- struct{}{}:
+		default:
+			if err := enqueue(); err != nil {
+				return nil, l.reject(key, reasonConcurrency, err)
+			}
+			select {
+			case kl.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, l.reject(key, reasonConcurrency, errLimitExceeded)
+			}
+		}
+	}
+
+	acquired = true
+	inFlight.Add(statsKey, 1)
+	return func() {
+		inFlight.Add(statsKey, -1)
+		if kl.slots != nil {
+			<-kl.slots
+		}
+		l.releaseKey(kl)
+	}, nil
+}
+
+var (
+	errLimitExceeded = fmt.Errorf("too many queries")
+	errQueueFull     = fmt.Errorf("too many queries waiting for their turn")
+)
+
+func (l *Limiter) reject(key, reason string, err error) error {
+	limit := fmt.Sprintf("%d queries per second", l.cfg.MaxQPS)
+	if reason == reasonConcurrency {
+		limit = fmt.Sprintf("%d concurrent queries", l.cfg.MaxConcurrency)
+	}
+	if err == errQueueFull {
+		reason = reasonQueueFull
+	}
+	rejectedCount.Add([]string{l.statsKey(key), reason}, 1)
+	return vterrors.Errorf(vtrpcpb.Code_RESOURCE_EXHAUSTED, "query limit exceeded for %s '%s': %v (limit: %s)", l.cfg.Key, key, err, limit)
+}
+
+// statsKey returns the stats label of a key.
+func (l *Limiter) statsKey(key string) string {
+	if l.statsKeys[key] {
+		return key
+	}
+	return otherStatsKey
+}
+
+// acquireKey returns the keyLimiter of a key, creating it if needed, for a
+// query that must call releaseKey when it ends. It also forgets the keys that
+// have been idle for longer than idleKeyTimeout, once per idleKeyTimeout.
+func (l *Limiter) acquireKey(key string) *keyLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= idleKeyTimeout {
+		l.lastSweep = now
+		for k, kl := range l.keys {
+			if kl.users == 0 && now.Sub(kl.lastUsed) >= idleKeyTimeout {
+				delete(l.keys, k)
+			}
+		}
+	}
+
+	kl, ok := l.keys[key]
+	if !ok {
+		kl = &keyLimiter{}
+		if l.cfg.MaxQPS > 0 {
+			kl.rate = rate.NewLimiter(rate.Limit(l.cfg.MaxQPS), l.cfg.MaxQPS)
+		}
+		if l.cfg.MaxConcurrency > 0 {
+			kl.slots = make(chan struct{}, l.cfg.MaxConcurrency)
+		}
+		l.keys[key] = kl
+	}
+	kl.users++
+	return kl
+}
+
+// releaseKey records that a query of the keyLimiter ended.
+func (l *Limiter) releaseKey(kl *keyLimiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kl.users--
+	kl.lastUsed = time.Now()
+}
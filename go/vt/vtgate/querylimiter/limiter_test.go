@@ -0,0 +1,225 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package querylimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/callerid"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Key: "tenant"})
+	assert.EqualError(t, err, `unknown query limit key "tenant"`)
+	_, err = New(Config{Key: KeyWorkload, MaxQPS: -1})
+	assert.EqualError(t, err, "query limits must not be negative")
+
+	l, err := New(Config{})
+	require.NoError(t, err)
+	assert.False(t, l.Enabled())
+	l, err = New(Config{Key: KeyWorkload})
+	require.NoError(t, err)
+	assert.False(t, l.Enabled())
+	l, err = New(Config{Key: KeyWorkload, MaxConcurrency: 1})
+	require.NoError(t, err)
+	assert.True(t, l.Enabled())
+}
+
+func TestKey(t *testing.T) {
+	ctx := callerid.NewContext(context.Background(), callerid.NewEffectiveCallerID("principal", "", ""), callerid.NewImmediateCallerID("user"))
+
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{KeyImmediateCaller, "user"},
+		{KeyEffectiveCaller, "principal"},
+		{KeyWorkload, "batch"},
+		{KeyFingerprint, sqlparser.Fingerprint("select * from t where id = :id")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			l, err := New(Config{Key: tc.key})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, l.Key(ctx, "batch", "select * from t where id = :id"))
+		})
+	}
+}
+
+func TestAcquireConcurrency(t *testing.T) {
+	rejectedCount.ResetAll()
+	l, err := New(Config{Key: KeyWorkload, MaxConcurrency: 2, QueueTimeout: 50 * time.Millisecond, StatsKeys: []string{"a"}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	release1, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+	release2, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, inFlight.Counts()["a"])
+
+	// Other keys have their own limits.
+	releaseB, err := l.Acquire(ctx, "b")
+	require.NoError(t, err)
+	releaseB()
+
+	// The third query times out in the queue.
+	_, err = l.Acquire(ctx, "a")
+	assert.EqualError(t, err, "query limit exceeded for workload 'a': too many queries (limit: 2 concurrent queries)")
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+	assert.EqualValues(t, 1, rejectedCount.Counts()["a.concurrency"])
+
+	// The fourth one gets the slot released while it waits.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release1()
+	}()
+	release3, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+
+	release2()
+	release3()
+	assert.EqualValues(t, 0, inFlight.Counts()["a"])
+}
+
+func TestAcquireQueueFull(t *testing.T) {
+	rejectedCount.ResetAll()
+	l, err := New(Config{Key: KeyWorkload, MaxConcurrency: 1, QueueTimeout: time.Second, MaxQueueSize: 1, StatsKeys: []string{"a"}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	release, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+
+	queued := make(chan error)
+	go func() {
+		release, err := l.Acquire(ctx, "a")
+		if err == nil {
+			release()
+		}
+		queued <- err
+	}()
+	assert.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.keys["a"].queued.Load() == 1
+	}, time.Second, time.Millisecond)
+
+	_, err = l.Acquire(ctx, "a")
+	assert.EqualError(t, err, "query limit exceeded for workload 'a': too many queries waiting for their turn (limit: 1 concurrent queries)")
+	assert.EqualValues(t, 1, rejectedCount.Counts()["a.queue_full"])
+
+	release()
+	assert.NoError(t, <-queued)
+}
+
+func TestAcquireQPS(t *testing.T) {
+	rejectedCount.ResetAll()
+	l, err := New(Config{Key: KeyWorkload, MaxQPS: 2, StatsKeys: []string{"a"}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(ctx, "a")
+		require.NoError(t, err)
+		release()
+	}
+	// Without a queue timeout, queries over the limit fail right away.
+	_, err = l.Acquire(ctx, "a")
+	assert.EqualError(t, err, "query limit exceeded for workload 'a': too many queries (limit: 2 queries per second)")
+	assert.EqualValues(t, 1, rejectedCount.Counts()["a.qps"])
+
+	// With a queue timeout, they wait for their turn.
+	l.cfg.QueueTimeout = 2 * time.Second
+	release, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+	release()
+
+	// Unless their turn comes after the queue timeout, in which case they
+	// fail without waiting.
+	l.cfg.QueueTimeout = 10 * time.Millisecond
+	start := time.Now()
+	_, err = l.Acquire(ctx, "a")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestStatsKeys(t *testing.T) {
+	rejectedCount.ResetAll()
+	l, err := New(Config{Key: KeyWorkload, MaxConcurrency: 1, StatsKeys: []string{"a"}})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	releaseA, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+	defer releaseA()
+	releaseB, err := l.Acquire(ctx, "b")
+	require.NoError(t, err)
+	defer releaseB()
+	releaseC, err := l.Acquire(ctx, "c")
+	require.NoError(t, err)
+	defer releaseC()
+
+	_, err = l.Acquire(ctx, "b")
+	assert.EqualError(t, err, "query limit exceeded for workload 'b': too many queries (limit: 1 concurrent queries)")
+	assert.EqualValues(t, 1, inFlight.Counts()["a"])
+	assert.EqualValues(t, 2, inFlight.Counts()[otherStatsKey])
+	assert.NotContains(t, inFlight.Counts(), "b")
+	assert.EqualValues(t, 1, rejectedCount.Counts()["other.concurrency"])
+}
+
+func TestIdleKeys(t *testing.T) {
+	defer func(timeout time.Duration) {
+		idleKeyTimeout = timeout
+	}(idleKeyTimeout)
+	idleKeyTimeout = 10 * time.Millisecond
+
+	l, err := New(Config{Key: KeyWorkload, MaxConcurrency: 1})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	releaseA, err := l.Acquire(ctx, "a")
+	require.NoError(t, err)
+	releaseB, err := l.Acquire(ctx, "b")
+	require.NoError(t, err)
+	releaseB()
+
+	// b is forgotten once idle, but not a, which still has a query in flight.
+	time.Sleep(2 * idleKeyTimeout)
+	releaseC, err := l.Acquire(ctx, "c")
+	require.NoError(t, err)
+	releaseC()
+	l.mu.Lock()
+	assert.Contains(t, l.keys, "a")
+	assert.NotContains(t, l.keys, "b")
+	assert.Contains(t, l.keys, "c")
+	l.mu.Unlock()
+
+	// The concurrency limit of a still holds.
+	_, err = l.Acquire(ctx, "a")
+	assert.Error(t, err)
+	releaseA()
+}
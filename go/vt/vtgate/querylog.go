@@ -67,5 +67,9 @@ func initQueryLogger(vtg *VTGate) error {
 		}
 	}
 
+	if _, err := streamlog.LogSlowQueriesFromFlags(QueryLogger); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"os"
+	"path"
+
+	"github.com/fsnotify/fsnotify"
+
+	"vitess.io/vitess/go/vt/log"
+)
+
+// FileSource is the name of the source of the rules read from a file.
+const FileSource = "FILE"
+
+// loadFile reads the rules of a file into the Map. On error, the Map keeps
+// the rules it had.
+func loadFile(m *Map, filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	qrs, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	m.Set(FileSource, qrs)
+	return nil
+}
+
+// watchFile loads the rules of a file into the Map, and reloads them every
+// time the file changes, until the returned function is called.
+func watchFile(m *Map, filePath string) (func(), error) {
+	if err := loadFile(m, filePath); err != nil {
+		return nil, err
+	}
+	log.Infof("Query rules loaded from file %s", filePath)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the directory rather than the file, so that the rules are
+	// reloaded when the file is replaced, e.g. by a ConfigMap update.
+	if err := watcher.Add(path.Dir(filePath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	fileName := path.Base(filePath)
+	go func() {
+		for {
+			select {
+			case evt, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if path.Base(evt.Name) != fileName {
+					continue
+				}
+				if err := loadFile(m, filePath); err != nil {
+					log.Errorf("Failed to reload query rules from file %s, keeping the previous ones: %v", filePath, err)
+				} else {
+					log.Infof("Query rules reloaded from file %s", filePath)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Errorf("Error watching query rules file %s: %v", filePath, err)
+			}
+		}
+	}()
+	return func() { watcher.Close() }, nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ruleNames(qrs *Rules) []string {
+	if qrs == nil {
+		return nil
+	}
+	var names []string
+	for _, r := range qrs.Rules() {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestWatchFile(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "rules.json")
+	m := NewMap()
+
+	_, err := watchFile(m, filePath)
+	require.ErrorContains(t, err, "no such file or directory")
+
+	require.NoError(t, os.WriteFile(filePath, []byte(`[{"Name": "r1", "Action": "FAIL"}]`), 0o644))
+	stop, err := watchFile(m, filePath)
+	require.NoError(t, err)
+	defer stop()
+	assert.Equal(t, []string{"r1"}, ruleNames(m.Get(FileSource)))
+
+	// The rules are reloaded when the file changes.
+	require.NoError(t, os.WriteFile(filePath, []byte(`[{"Name": "r2", "Action": "FAIL"}]`), 0o644))
+	assert.Eventually(t, func() bool {
+		names := ruleNames(m.Get(FileSource))
+		return len(names) == 1 && names[0] == "r2"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Invalid rules are skipped.
+	require.NoError(t, os.WriteFile(filePath, []byte(`[{"Name": "r3"}]`), 0o644))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []string{"r2"}, ruleNames(m.Get(FileSource)))
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+	"vitess.io/vitess/go/vt/topo"
+)
+
+var (
+	rulesFile     string
+	rulesTopoPath string
+)
+
+func registerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&rulesFile, "query_rules_file", rulesFile, "JSON file of the query rules vtgate enforces. The rules are reloaded when the file changes.")
+	fs.StringVar(&rulesTopoPath, "query_rules_topo_path", rulesTopoPath, "Path in the global topo of a JSON file of query rules vtgate enforces, in addition to those of --query_rules_file. The rules are reloaded when the file changes. Disabled if empty.")
+}
+
+func init() {
+	servenv.OnParseFor("vtgate", registerFlags)
+	servenv.OnParseFor("vtcombo", registerFlags)
+}
+
+// StartFromFlags loads the query rules of the sources set by the command
+// line flags into the Map, and keeps them up to date until the process
+// terminates.
+func StartFromFlags(ctx context.Context, ts *topo.Server, m *Map) error {
+	if rulesFile != "" {
+		stop, err := watchFile(m, rulesFile)
+		if err != nil {
+			return err
+		}
+		servenv.OnTerm(stop)
+	}
+	if rulesTopoPath != "" {
+		stop, err := watchTopo(ctx, ts, m, rulesTopoPath)
+		if err != nil {
+			return err
+		}
+		servenv.OnTerm(stop)
+	}
+	return nil
+}
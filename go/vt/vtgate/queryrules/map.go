@@ -0,0 +1,91 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Map holds the rules of each source, e.g. a file and the topo. The rules
+// of all the sources apply, in the order of the source names.
+type Map struct {
+	mu      sync.RWMutex
+	sources map[string]*Rules
+}
+
+// NewMap returns an empty Map.
+func NewMap() *Map {
+	return &Map{sources: make(map[string]*Rules)}
+}
+
+// Set replaces the rules of a source.
+func (m *Map) Set(source string, qrs *Rules) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sources[source] = qrs
+}
+
+// Get returns the rules of a source, or nil if it has none.
+func (m *Map) Get(source string) *Rules {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sources[source]
+}
+
+// Empty returns true if no source has any rule.
+func (m *Map) Empty() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, qrs := range m.sources {
+		if len(qrs.rules) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Match returns the combined effects of the rules the query matches.
+func (m *Map) Match(q *Query) *Effects {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	effects := &Effects{}
+	for _, source := range m.sourceNames() {
+		m.sources[source].apply(q, effects)
+		if effects.Err != nil {
+			break
+		}
+	}
+	return effects
+}
+
+func (m *Map) sourceNames() []string {
+	names := make([]string, 0, len(m.sources))
+	for source := range m.sources {
+		names = append(names, source)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MarshalJSON marshals the rules by source.
+func (m *Map) MarshalJSON() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return json.Marshal(m.sources)
+}
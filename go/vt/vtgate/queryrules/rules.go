@@ -0,0 +1,372 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package queryrules implements the query rules vtgate enforces before it sends
+a query to the tablets.
+
+Unlike the tablet query rules of vttablet/tabletserver/rules, they see the
+original query as the client sent it, the vtgate user and the plan, and they
+can stop a scatter query before it fans out to the shards. A rule matches on
+the normalized query, the tables, the plan type, the user and the comments of
+the query, and then fails the query, delays it, caps the number of shards it
+may touch, or forces the tablet type it reads from.
+
+The rules are JSON lists like:
+
+	[{
+	  "Name": "no_big_scatters",
+	  "Description": "scatters on orders must be bounded",
+	  "Tables": ["commerce.orders"],
+	  "Plans": ["SELECT"],
+	  "Action": "MAX_SHARDS",
+	  "MaxShards": 4
+	}]
+*/
+package queryrules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// Action is what a Rule does to the queries it matches.
+type Action string
+
+// These are the actions.
+const (
+	// ActionFail fails the query.
+	ActionFail = Action("FAIL")
+	// ActionDelay delays the query by the Delay of the rule.
+	ActionDelay = Action("DELAY")
+	// ActionMaxShards fails the query if it would touch more shards than
+	// the MaxShards of the rule, before it is sent to any of them.
+	ActionMaxShards = Action("MAX_SHARDS")
+	// ActionTabletType sends the query to the TabletType of the rule. It only
+	// applies to SELECT queries outside of transactions.
+	ActionTabletType = Action("TABLET_TYPE")
+)
+
+var matchedCount = stats.NewCountersWithMultiLabels("QueryRulesMatched", "Queries matched by the vtgate query rules, by rule and action", []string{"Rule", "Action"})
+
+// Query is what the rules match against.
+type Query struct {
+	// SQL is the normalized query.
+	SQL string
+	// Tables are the keyspace-qualified tables the query uses.
+	Tables []string
+	// PlanType is the type of the statement.
+	PlanType sqlparser.StatementType
+	// User is the immediate caller.
+	User string
+	// Comments are the comments of the query, each matched separately.
+	Comments []string
+}
+
+// Effects are the combined actions of the rules a query matches.
+type Effects struct {
+	// Err is the error of the first matching FAIL rule.
+	Err error
+	// Delay is the longest delay of the matching DELAY rules.
+	Delay time.Duration
+	// MaxShards is the lowest maximum of the matching MAX_SHARDS rules, and
+	// MaxShardsRule the name of that rule. MaxShards is 0 if none matches.
+	MaxShards     int
+	MaxShardsRule string
+	// TabletType is the tablet type of the first matching TABLET_TYPE rule,
+	// or UNKNOWN if none matches.
+	TabletType topodatapb.TabletType
+}
+
+// Rule is a condition on queries and the action to take on the queries
+// that meet it. All the conditions that are set must match for the rule to
+// match.
+type Rule struct {
+	Name        string
+	Description string
+
+	// query, user and comment must match the whole value.
+	query, user, comment *regexp.Regexp
+	// tables match either a keyspace-qualified table or, if they are not
+	// qualified, a table of any keyspace. Any match is enough.
+	tables []string
+	// Any matching plan type is enough.
+	plans []sqlparser.StatementType
+
+	Action     Action
+	Delay      time.Duration
+	MaxShards  int
+	TabletType topodatapb.TabletType
+}
+
+// ruleJSON is the JSON representation of a Rule.
+type ruleJSON struct {
+	Name        string
+	Description string   `json:",omitempty"`
+	Query       string   `json:",omitempty"`
+	User        string   `json:",omitempty"`
+	Comment     string   `json:",omitempty"`
+	Tables      []string `json:",omitempty"`
+	Plans       []string `json:",omitempty"`
+	Action      Action
+	Delay       string `json:",omitempty"`
+	MaxShards   int    `json:",omitempty"`
+	TabletType  string `json:",omitempty"`
+}
+
+// planTypes are the plan types by name.
+var planTypes = func() map[string]sqlparser.StatementType {
+	m := make(map[string]sqlparser.StatementType)
+	for st := sqlparser.StmtSelect; st <= sqlparser.StmtDeallocate; st++ {
+		m[st.String()] = st
+	}
+	return m
+}()
+
+// Matches returns true if the query meets all the conditions of the rule.
+func (r *Rule) Matches(q *Query) bool {
+	if r.query != nil && !r.query.MatchString(q.SQL) {
+		return false
+	}
+	if r.user != nil && !r.user.MatchString(q.User) {
+		return false
+	}
+	if r.comment != nil && !anyMatch(r.comment, q.Comments) {
+		return false
+	}
+	if len(r.tables) > 0 && !tablesMatch(r.tables, q.Tables) {
+		return false
+	}
+	if len(r.plans) > 0 && !planMatch(r.plans, q.PlanType) {
+		return false
+	}
+	return true
+}
+
+func anyMatch(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func tablesMatch(ruleTables, tables []string) bool {
+	for _, rt := range ruleTables {
+		for _, t := range tables {
+			if rt == t {
+				return true
+			}
+			if !strings.Contains(rt, ".") {
+				if _, name, ok := strings.Cut(t, "."); ok && name == rt {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func planMatch(plans []sqlparser.StatementType, plan sqlparser.StatementType) bool {
+	for _, p := range plans {
+		if p == plan {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON marshals a Rule in the format UnmarshalJSON reads.
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	rj := ruleJSON{
+		Name:        r.Name,
+		Description: r.Description,
+		Query:       patternOf(r.query),
+		User:        patternOf(r.user),
+		Comment:     patternOf(r.comment),
+		Tables:      r.tables,
+		Action:      r.Action,
+		MaxShards:   r.MaxShards,
+	}
+	for _, p := range r.plans {
+		rj.Plans = append(rj.Plans, p.String())
+	}
+	if r.Delay != 0 {
+		rj.Delay = r.Delay.String()
+	}
+	if r.TabletType != topodatapb.TabletType_UNKNOWN {
+		rj.TabletType = topoproto.TabletTypeLString(r.TabletType)
+	}
+	return json.Marshal(rj)
+}
+
+// UnmarshalJSON unmarshals and validates a Rule.
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var rj ruleJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rj); err != nil {
+		return err
+	}
+	rule, err := newRule(&rj)
+	if err != nil {
+		return err
+	}
+	*r = *rule
+	return nil
+}
+
+func newRule(rj *ruleJSON) (*Rule, error) {
+	if rj.Name == "" {
+		return nil, fmt.Errorf("query rule without a name")
+	}
+	r := &Rule{
+		Name:        rj.Name,
+		Description: rj.Description,
+		tables:      rj.Tables,
+		Action:      rj.Action,
+	}
+	var err error
+	if r.query, err = compileExact(rj.Query); err != nil {
+		return nil, fmt.Errorf("query rule %s: invalid Query: %v", rj.Name, err)
+	}
+	if r.user, err = compileExact(rj.User); err != nil {
+		return nil, fmt.Errorf("query rule %s: invalid User: %v", rj.Name, err)
+	}
+	if r.comment, err = compileExact(rj.Comment); err != nil {
+		return nil, fmt.Errorf("query rule %s: invalid Comment: %v", rj.Name, err)
+	}
+	for _, name := range rj.Plans {
+		pt, ok := planTypes[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("query rule %s: invalid plan type %s", rj.Name, name)
+		}
+		r.plans = append(r.plans, pt)
+	}
+
+	switch rj.Action {
+	case ActionFail:
+	case ActionDelay:
+		if r.Delay, err = time.ParseDuration(rj.Delay); err != nil || r.Delay <= 0 {
+			return nil, fmt.Errorf("query rule %s: DELAY needs a positive Delay, got %q", rj.Name, rj.Delay)
+		}
+	case ActionMaxShards:
+		if rj.MaxShards <= 0 {
+			return nil, fmt.Errorf("query rule %s: MAX_SHARDS needs a positive MaxShards, got %d", rj.Name, rj.MaxShards)
+		}
+		r.MaxShards = rj.MaxShards
+	case ActionTabletType:
+		if r.TabletType, err = topoproto.ParseTabletType(rj.TabletType); err != nil || r.TabletType == topodatapb.TabletType_UNKNOWN {
+			return nil, fmt.Errorf("query rule %s: TABLET_TYPE needs a valid TabletType, got %q", rj.Name, rj.TabletType)
+		}
+	default:
+		return nil, fmt.Errorf("query rule %s: invalid Action %q", rj.Name, rj.Action)
+	}
+	return r, nil
+}
+
+func compileExact(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^" + pattern + "$")
+}
+
+func patternOf(re *regexp.Regexp) string {
+	if re == nil {
+		return ""
+	}
+	s := re.String()
+	return s[1 : len(s)-1]
+}
+
+// Rules is an ordered list of rules.
+type Rules struct {
+	rules []*Rule
+}
+
+// New returns an empty Rules.
+func New() *Rules {
+	return &Rules{}
+}
+
+// Parse parses a JSON list of rules.
+func Parse(data []byte) (*Rules, error) {
+	qrs := New()
+	if err := json.Unmarshal(data, &qrs.rules); err != nil {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "%v", err)
+	}
+	return qrs, nil
+}
+
+// Rules returns the rules, in order.
+func (qrs *Rules) Rules() []*Rule {
+	return qrs.rules
+}
+
+// MarshalJSON marshals the rules as a JSON list.
+func (qrs *Rules) MarshalJSON() ([]byte, error) {
+	if qrs.rules == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(qrs.rules)
+}
+
+// apply adds the actions of the rules the query matches to the effects.
+// It stops at the first matching FAIL rule.
+func (qrs *Rules) apply(q *Query, effects *Effects) {
+	for _, r := range qrs.rules {
+		if !r.Matches(q) {
+			continue
+		}
+		matchedCount.Add([]string{r.Name, string(r.Action)}, 1)
+		switch r.Action {
+		case ActionFail:
+			if r.Description == "" {
+				effects.Err = vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "disallowed due to query rule %s", r.Name)
+			} else {
+				effects.Err = vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "disallowed due to query rule %s: %s", r.Name, r.Description)
+			}
+			return
+		case ActionDelay:
+			if r.Delay > effects.Delay {
+				effects.Delay = r.Delay
+			}
+		case ActionMaxShards:
+			if effects.MaxShards == 0 || r.MaxShards < effects.MaxShards {
+				effects.MaxShards = r.MaxShards
+				effects.MaxShardsRule = r.Name
+			}
+		case ActionTabletType:
+			if effects.TabletType == topodatapb.TabletType_UNKNOWN {
+				effects.TabletType = r.TabletType
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+const testRules = `[{
+	"Name": "deny_drop",
+	"Description": "no drops from the app",
+	"User": "app",
+	"Plans": ["DDL"],
+	"Action": "FAIL"
+}, {
+	"Name": "slow_reports",
+	"Comment": "/\\* report \\*/",
+	"Action": "DELAY",
+	"Delay": "50ms"
+}, {
+	"Name": "bounded_orders",
+	"Tables": ["orders"],
+	"Plans": ["select"],
+	"Action": "MAX_SHARDS",
+	"MaxShards": 4
+}, {
+	"Name": "tight_orders",
+	"Query": "select \\* from orders.*",
+	"Action": "MAX_SHARDS",
+	"MaxShards": 2
+}, {
+	"Name": "customer_reads",
+	"Tables": ["commerce.customer"],
+	"Action": "TABLET_TYPE",
+	"TabletType": "rdonly"
+}]`
+
+func TestParse(t *testing.T) {
+	qrs, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+	require.Len(t, qrs.Rules(), 5)
+	assert.Equal(t, "deny_drop", qrs.Rules()[0].Name)
+	assert.Equal(t, 50*time.Millisecond, qrs.Rules()[1].Delay)
+	assert.Equal(t, 4, qrs.Rules()[2].MaxShards)
+	assert.Equal(t, topodatapb.TabletType_RDONLY, qrs.Rules()[4].TabletType)
+
+	// The rules marshal back to the same rules.
+	data, err := json.Marshal(qrs)
+	require.NoError(t, err)
+	again, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, qrs, again)
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		rules string
+		want  string
+	}{{
+		rules: `{}`,
+		want:  "json: cannot unmarshal object into Go value of type []*queryrules.Rule",
+	}, {
+		rules: `[{"Action": "FAIL"}]`,
+		want:  "query rule without a name",
+	}, {
+		rules: `[{"Name": "r", "Action": "FAIL", "Tablez": ["t"]}]`,
+		want:  `json: unknown field "Tablez"`,
+	}, {
+		rules: `[{"Name": "r", "Action": "REWRITE"}]`,
+		want:  `query rule r: invalid Action "REWRITE"`,
+	}, {
+		rules: `[{"Name": "r", "Action": "FAIL", "Query": "("}]`,
+		want:  "query rule r: invalid Query: error parsing regexp: missing closing ): `^($`",
+	}, {
+		rules: `[{"Name": "r", "Action": "FAIL", "Plans": ["MERGE"]}]`,
+		want:  "query rule r: invalid plan type MERGE",
+	}, {
+		rules: `[{"Name": "r", "Action": "DELAY"}]`,
+		want:  `query rule r: DELAY needs a positive Delay, got ""`,
+	}, {
+		rules: `[{"Name": "r", "Action": "MAX_SHARDS"}]`,
+		want:  "query rule r: MAX_SHARDS needs a positive MaxShards, got 0",
+	}, {
+		rules: `[{"Name": "r", "Action": "TABLET_TYPE", "TabletType": "nonsense"}]`,
+		want:  `query rule r: TABLET_TYPE needs a valid TabletType, got "nonsense"`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.rules, func(t *testing.T) {
+			_, err := Parse([]byte(tc.rules))
+			assert.EqualError(t, err, tc.want)
+			assert.Equal(t, vtrpcpb.Code_INVALID_ARGUMENT, vterrors.Code(err))
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	qrs, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+	m := NewMap()
+	assert.True(t, m.Empty())
+	m.Set(FileSource, qrs)
+	assert.False(t, m.Empty())
+
+	testCases := []struct {
+		name    string
+		query   *Query
+		want    *Effects
+		wantErr string
+	}{{
+		name:  "no match",
+		query: &Query{SQL: "select * from customer", Tables: []string{"commerce.customer2"}, PlanType: sqlparser.StmtSelect},
+		want:  &Effects{},
+	}, {
+		name:    "fail",
+		query:   &Query{SQL: "drop table customer", PlanType: sqlparser.StmtDDL, User: "app"},
+		wantErr: "disallowed due to query rule deny_drop: no drops from the app",
+	}, {
+		name:  "other user",
+		query: &Query{SQL: "drop table customer", PlanType: sqlparser.StmtDDL, User: "dba"},
+		want:  &Effects{},
+	}, {
+		name:  "delay",
+		query: &Query{SQL: "select 1 from dual", PlanType: sqlparser.StmtSelect, Comments: []string{"/* report */"}},
+		want:  &Effects{Delay: 50 * time.Millisecond},
+	}, {
+		name:  "lowest max shards",
+		query: &Query{SQL: "select * from orders where id > :id", Tables: []string{"commerce.orders"}, PlanType: sqlparser.StmtSelect},
+		want:  &Effects{MaxShards: 2, MaxShardsRule: "tight_orders"},
+	}, {
+		name:  "unqualified table",
+		query: &Query{SQL: "select id from orders", Tables: []string{"commerce.orders"}, PlanType: sqlparser.StmtSelect},
+		want:  &Effects{MaxShards: 4, MaxShardsRule: "bounded_orders"},
+	}, {
+		name:  "plan type",
+		query: &Query{SQL: "update orders set x = 1", Tables: []string{"commerce.orders"}, PlanType: sqlparser.StmtUpdate},
+		want:  &Effects{},
+	}, {
+		name: "combined",
+		query: &Query{
+			SQL:      "select id from orders join customer",
+			Tables:   []string{"commerce.orders", "commerce.customer"},
+			PlanType: sqlparser.StmtSelect,
+			Comments: []string{"/* report */"},
+		},
+		want: &Effects{Delay: 50 * time.Millisecond, MaxShards: 4, MaxShardsRule: "bounded_orders", TabletType: topodatapb.TabletType_RDONLY},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			effects := m.Match(tc.query)
+			if tc.wantErr != "" {
+				assert.EqualError(t, effects.Err, tc.wantErr)
+				assert.Equal(t, vtrpcpb.Code_FAILED_PRECONDITION, vterrors.Code(effects.Err))
+				return
+			}
+			assert.Equal(t, tc.want, effects)
+		})
+	}
+
+	// The rules of all the sources apply.
+	topoRules, err := Parse([]byte(`[{"Name": "no_customer", "Tables": ["customer"], "Action": "FAIL"}]`))
+	require.NoError(t, err)
+	m.Set(TopoSource, topoRules)
+	effects := m.Match(&Query{SQL: "select 1 from customer", Tables: []string{"commerce.customer"}, PlanType: sqlparser.StmtSelect})
+	assert.EqualError(t, effects.Err, "disallowed due to query rule no_customer")
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"TOPO":[{"Name":"no_customer","Tables":["customer"],"Action":"FAIL"}]`)
+}
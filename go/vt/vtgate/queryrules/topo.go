@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/topo"
+)
+
+// TopoSource is the name of the source of the rules read from the topo.
+const TopoSource = "TOPO"
+
+// sleepDuringTopoFailure is how long to sleep before retrying in case of error.
+// (it's a var not a const so the test can change the value).
+var sleepDuringTopoFailure = 30 * time.Second
+
+// topoWatcher keeps the rules of a topo file in a Map.
+type topoWatcher struct {
+	m        *Map
+	conn     topo.Conn
+	filePath string
+
+	// mu protects the following variables.
+	mu sync.Mutex
+	// cancel is the function to call to cancel the current watch, if any.
+	cancel func()
+	// stopped is set when stop() is called.
+	stopped bool
+}
+
+// watchTopo keeps the rules of a file of the global topo in the Map, until
+// the returned function is called.
+func watchTopo(ctx context.Context, ts *topo.Server, m *Map, filePath string) (func(), error) {
+	conn, err := ts.ConnForCell(ctx, topo.GlobalCell)
+	if err != nil {
+		return nil, err
+	}
+	tw := &topoWatcher{
+		m:        m,
+		conn:     conn,
+		filePath: filePath,
+	}
+	go func() {
+		for {
+			if err := tw.oneWatch(); err != nil {
+				log.Warningf("Background watch of the query rules in the topo failed: %v", err)
+			}
+
+			tw.mu.Lock()
+			stopped := tw.stopped
+			tw.mu.Unlock()
+			if stopped {
+				return
+			}
+
+			log.Warningf("Sleeping for %v before trying again", sleepDuringTopoFailure)
+			time.Sleep(sleepDuringTopoFailure)
+		}
+	}()
+	return tw.stop, nil
+}
+
+func (tw *topoWatcher) stop() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.cancel != nil {
+		tw.cancel()
+	}
+	tw.stopped = true
+}
+
+func (tw *topoWatcher) apply(wd *topo.WatchData) error {
+	qrs, err := Parse(wd.Contents)
+	if err != nil {
+		return fmt.Errorf("error parsing query rules version %v: %v", wd.Version, err)
+	}
+	tw.m.Set(TopoSource, qrs)
+	log.Infof("Query rules version %v loaded from the topo", wd.Version)
+	return nil
+}
+
+func (tw *topoWatcher) oneWatch() error {
+	defer func() {
+		// Whatever happens, cancel() won't be valid after this function exits.
+		tw.mu.Lock()
+		tw.cancel = nil
+		tw.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	current, wdChannel, err := tw.conn.Watch(ctx, tw.filePath)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	tw.mu.Lock()
+	if tw.stopped {
+		// We're not interested in the result any more.
+		tw.mu.Unlock()
+		cancel()
+		for range wdChannel {
+		}
+		return topo.NewError(topo.Interrupted, "watch")
+	}
+	tw.cancel = cancel
+	tw.mu.Unlock()
+
+	// Rules that do not parse are skipped, and the previous rules stay in
+	// place until a valid version shows up.
+	if err := tw.apply(current); err != nil {
+		log.Errorf("Keeping the previous query rules: %v", err)
+	}
+	for wd := range wdChannel {
+		if wd.Err != nil {
+			// Last error value, we're done.
+			// wdChannel will be closed right after
+			// this, no need to do anything.
+			return wd.Err
+		}
+		if err := tw.apply(wd); err != nil {
+			log.Errorf("Keeping the previous query rules: %v", err)
+		}
+	}
+	return fmt.Errorf("watch terminated with no error")
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package queryrules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/topo"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+)
+
+func TestWatchTopo(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("cell1")
+	filePath := "/vtgate/query_rules"
+	sleepDuringTopoFailure = time.Millisecond
+	m := NewMap()
+
+	// The watch waits for the file to be created.
+	stop, err := watchTopo(ctx, ts, m, filePath)
+	require.NoError(t, err)
+	defer stop()
+
+	waitForRules := func(want ...string) {
+		t.Helper()
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual(want, ruleNames(m.Get(TopoSource)))
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	conn, err := ts.ConnForCell(ctx, topo.GlobalCell)
+	require.NoError(t, err)
+	_, err = conn.Create(ctx, filePath, []byte(`[{"Name": "r1", "Action": "FAIL"}]`))
+	require.NoError(t, err)
+	waitForRules("r1")
+
+	_, err = conn.Update(ctx, filePath, []byte(`[{"Name": "r1", "Action": "FAIL"}, {"Name": "r2", "Action": "FAIL"}]`), nil)
+	require.NoError(t, err)
+	waitForRules("r1", "r2")
+
+	// Invalid rules are skipped, and the next valid ones are loaded.
+	_, err = conn.Update(ctx, filePath, []byte(`[{"Name": "r3"}]`), nil)
+	require.NoError(t, err)
+	_, err = conn.Update(ctx, filePath, []byte(`[{"Name": "r4", "Action": "FAIL"}]`), nil)
+	require.NoError(t, err)
+	waitForRules("r4")
+}
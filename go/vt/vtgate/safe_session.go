@@ -216,6 +216,20 @@ func (session *SafeSession) GetQueryTimeout() int64 {
 	return session.QueryTimeout
 }
 
+// SetMaxReplicationLag sets the maximum replication lag of the replicas that serve reads
+func (session *SafeSession) SetMaxReplicationLag(maxReplicationLag time.Duration) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.MaxReplicationLag = maxReplicationLag.Milliseconds()
+}
+
+// GetMaxReplicationLag gets the maximum replication lag of the replicas that serve reads
+func (session *SafeSession) GetMaxReplicationLag() time.Duration {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return time.Duration(session.MaxReplicationLag) * time.Millisecond
+}
+
 // SavePoints returns the save points of the session. It's safe to use concurrently
 func (session *SafeSession) SavePoints() []string {
 	session.mu.Lock()
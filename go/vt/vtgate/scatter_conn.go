@@ -35,6 +35,7 @@ import (
 	"vitess.io/vitess/go/vt/srvtopo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/audit"
 	"vitess.io/vitess/go/vt/vtgate/engine"
 	"vitess.io/vitess/go/vt/vttablet/queryservice"
 
@@ -149,6 +150,11 @@ func (stc *ScatterConn) ExecuteMultiShard(
 	if len(rss) != len(queries) {
 		return nil, []error{vterrors.Errorf(vtrpcpb.Code_INTERNAL, "[BUG] got mismatched number of queries and shards")}
 	}
+	if err := checkMaxShards(ctx, rss); err != nil {
+		return nil, []error{err}
+	}
+	audit.AddShards(ctx, rss)
+	addProcessShards(ctx, rss)
 
 	// mu protects qr
 	var mu sync.Mutex
@@ -353,6 +359,11 @@ func (stc *ScatterConn) StreamExecuteMulti(
 	autocommit bool,
 	callback func(reply *sqltypes.Result) error,
 ) []error {
+	if err := checkMaxShards(ctx, rss); err != nil {
+		return []error{err}
+	}
+	audit.AddShards(ctx, rss)
+	addProcessShards(ctx, rss)
 	if session.InLockSession() && session.TriggerLockHeartBeat() {
 		go stc.runLockQuery(ctx, session)
 	}
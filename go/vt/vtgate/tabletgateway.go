@@ -19,7 +19,6 @@ package vtgate
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -35,6 +34,7 @@ import (
 	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/balancer"
 	"vitess.io/vitess/go/vt/vtgate/buffer"
 	"vitess.io/vitess/go/vt/vttablet/queryservice"
 
@@ -50,6 +50,9 @@ var (
 
 	bufferImplementation = "keyspace_events"
 	initialTabletTimeout = 30 * time.Second
+	// balancerCleanupInterval is how often the balancer forgets the tablets
+	// that are no longer healthy.
+	balancerCleanupInterval = time.Minute
 	// retryCount is the number of times a query will be retried on error
 	retryCount = 2
 )
@@ -74,6 +77,10 @@ type TabletGateway struct {
 	retryCount           int
 	defaultConnCollation uint32
 
+	// maxReplicationLagFallback is what to do with a read bounded by a
+	// maximum replication lag when no replica is within the lag.
+	maxReplicationLagFallback string
+
 	// mu protects the fields of this group.
 	mu sync.Mutex
 	// statusAggregators is a map indexed by the key
@@ -82,6 +89,9 @@ type TabletGateway struct {
 
 	// buffer, if enabled, buffers requests during a detected PRIMARY failover.
 	buffer *buffer.Buffer
+
+	// balancer picks the tablet each query is sent to.
+	balancer *balancer.Balancer
 }
 
 func createHealthCheck(ctx context.Context, retryDelay, timeout time.Duration, ts *topo.Server, cell, cellsToWatch string) discovery.HealthCheck {
@@ -108,8 +118,16 @@ func NewTabletGateway(ctx context.Context, hc discovery.HealthCheck, serv srvtop
 		localCell:         localCell,
 		retryCount:        retryCount,
 		statusAggregators: make(map[string]*TabletStatusAggregator),
+
+		maxReplicationLagFallback: maxReplicationLagFallback,
+	}
+	switch gw.maxReplicationLagFallback {
+	case MaxReplicationLagFallbackPrimary, MaxReplicationLagFallbackError:
+	default:
+		log.Exitf("Unknown --max_replication_lag_fallback %q", gw.maxReplicationLagFallback)
 	}
 	gw.setupBuffering(ctx)
+	gw.setupBalancer(ctx)
 	gw.QueryService = queryservice.Wrap(nil, gw.withRetry)
 	return gw
 }
@@ -169,6 +187,34 @@ func (gw *TabletGateway) setupBuffering(ctx context.Context) {
 	}
 }
 
+func (gw *TabletGateway) setupBalancer(ctx context.Context) {
+	b, err := balancer.New(balancer.NewConfigFromFlags(), gw.localCell)
+	if err != nil {
+		log.Exitf("Unable to create the balancer of the TabletGateway: %v", err)
+	}
+	gw.balancer = b
+
+	go func() {
+		ticker := time.NewTicker(balancerCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				b.RemoveTablets(gw.tabletGone)
+			}
+		}
+	}()
+}
+
+// tabletGone returns true if the tablet was removed from the healthcheck or
+// is not serving.
+func (gw *TabletGateway) tabletGone(alias *topodatapb.TabletAlias) bool {
+	th, err := gw.hc.GetTabletHealthByAlias(alias)
+	return err != nil || !th.Serving
+}
+
 // QueryServiceByAlias satisfies the Gateway interface
 func (gw *TabletGateway) QueryServiceByAlias(alias *topodatapb.TabletAlias, target *querypb.Target) (queryservice.QueryService, error) {
 	qs, err := gw.hc.TabletConnection(alias, target)
@@ -243,7 +289,7 @@ func (gw *TabletGateway) CacheStatus() TabletCacheStatusList {
 // withRetry also adds shard information to errors returned from the inner QueryService, so
 // withShardError should not be combined with withRetry.
 func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target, _ queryservice.QueryService,
-	_ string, inTransaction bool, inner func(ctx context.Context, target *querypb.Target, conn queryservice.QueryService) (bool, error)) error {
+	name string, inTransaction bool, inner func(ctx context.Context, target *querypb.Target, conn queryservice.QueryService) (bool, error)) error {
 	// for transactions, we connect to a specific tablet instead of letting gateway choose one
 	if inTransaction && target.TabletType != topodatapb.TabletType_PRIMARY {
 		return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "tabletGateway's query service can only be used for non-transactional queries on replicas")
@@ -317,7 +363,13 @@ func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target,
 			err = vterrors.Errorf(vtrpcpb.Code_UNAVAILABLE, "no healthy tablet available for '%s'", target.String())
 			break
 		}
-		gw.shuffleTablets(gw.localCell, tablets)
+		var lagErr error
+		target, tablets, lagErr = gw.replicasWithinLag(ctx, target, tablets, opensDedicatedConn(name))
+		if lagErr != nil {
+			err = lagErr
+			break
+		}
+		gw.balancer.Sort(tablets)
 
 		var th *discovery.TabletHealth
 		// skip tablets we tried before
@@ -346,8 +398,10 @@ func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target,
 		gw.updateDefaultConnCollation(tabletLastUsed)
 
 		startTime := time.Now()
+		done := gw.balancer.Start(th)
 		var canRetry bool
 		canRetry, err = inner(ctx, target, th.Conn)
+		done(time.Since(startTime), err)
 		gw.updateStats(target, startTime, err)
 		if canRetry {
 			invalidTablets[topoproto.TabletAliasString(tabletLastUsed.Alias)] = true
@@ -358,6 +412,14 @@ func (gw *TabletGateway) withRetry(ctx context.Context, target *querypb.Target,
 	return NewShardError(err, target)
 }
 
+// waitForFailoverEnd blocks while the requests to the primary of the target
+// are buffered for a failover, which err may show is in progress. Like for a
+// buffered request, the returned RetryDoneFunc must be called once the
+// caller retried.
+func (gw *TabletGateway) waitForFailoverEnd(ctx context.Context, target *querypb.Target, err error) (buffer.RetryDoneFunc, error) {
+	return gw.buffer.WaitForFailoverEnd(ctx, target.Keyspace, target.Shard, err)
+}
+
 // withShardError adds shard information to errors returned from the inner QueryService.
 func (gw *TabletGateway) withShardError(ctx context.Context, target *querypb.Target, conn queryservice.QueryService,
 	_ string, _ bool, inner func(ctx context.Context, target *querypb.Target, conn queryservice.QueryService) (bool, error)) error {
@@ -387,51 +449,9 @@ func (gw *TabletGateway) getStatsAggregator(target *querypb.Target) *TabletStatu
 	return aggr
 }
 
-func (gw *TabletGateway) shuffleTablets(cell string, tablets []*discovery.TabletHealth) {
-	sameCell, diffCell, sameCellMax := 0, 0, -1
-	length := len(tablets)
-
-	// move all same cell tablets to the front, this is O(n)
-	for {
-		sameCellMax = diffCell - 1
-		sameCell = gw.nextTablet(cell, tablets, sameCell, length, true)
-		diffCell = gw.nextTablet(cell, tablets, diffCell, length, false)
-		// either no more diffs or no more same cells should stop the iteration
-		if sameCell < 0 || diffCell < 0 {
-			break
-		}
-
-		if sameCell < diffCell {
-			// fast forward the `sameCell` lookup to `diffCell + 1`, `diffCell` unchanged
-			sameCell = diffCell + 1
-		} else {
-			// sameCell > diffCell, swap needed
-			tablets[sameCell], tablets[diffCell] = tablets[diffCell], tablets[sameCell]
-			sameCell++
-			diffCell++
-		}
-	}
-
-	// shuffle in same cell tablets
-	for i := sameCellMax; i > 0; i-- {
-		swap := rand.Intn(i + 1)
-		tablets[i], tablets[swap] = tablets[swap], tablets[i]
-	}
-
-	// shuffle in diff cell tablets
-	for i, diffCellMin := length-1, sameCellMax+1; i > diffCellMin; i-- {
-		swap := rand.Intn(i-sameCellMax) + diffCellMin
-		tablets[i], tablets[swap] = tablets[swap], tablets[i]
-	}
-}
-
-func (gw *TabletGateway) nextTablet(cell string, tablets []*discovery.TabletHealth, offset, length int, sameCell bool) int {
-	for ; offset < length; offset++ {
-		if (tablets[offset].Tablet.Alias.Cell == cell) == sameCell {
-			return offset
-		}
-	}
-	return -1
+// BalancerStatus returns the selection stats of the tablets, for the status page.
+func (gw *TabletGateway) BalancerStatus() *balancer.Status {
+	return gw.balancer.Status()
 }
 
 // TabletsCacheStatus returns a displayable version of the health check cache.
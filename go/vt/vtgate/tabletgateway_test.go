@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,7 +31,6 @@ import (
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
-	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/vterrors"
 )
 
@@ -82,62 +82,70 @@ func TestTabletGatewayBeginExecute(t *testing.T) {
 	})
 }
 
-func TestTabletGatewayShuffleTablets(t *testing.T) {
+func TestTabletGatewayBalancerStatus(t *testing.T) {
+	target := &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
 	hc := discovery.NewFakeHealthCheck(nil)
-	tg := NewTabletGateway(context.Background(), hc, nil, "local")
+	tg := NewTabletGateway(context.Background(), hc, nil, "cell")
 
-	ts1 := &discovery.TabletHealth{
-		Tablet:  topo.NewTablet(1, "cell1", "host1"),
-		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
-		Serving: true,
-		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
-	}
+	sc := hc.AddTestTablet("cell", "1.1.1.1", 1001, "ks", "0", topodatapb.TabletType_REPLICA, true, 10, nil)
+	_, err := tg.Execute(context.Background(), target, "query", nil, 0, 0, nil)
+	require.NoError(t, err)
+	sc.MustFailCodes[vtrpcpb.Code_INVALID_ARGUMENT] = 1
+	_, err = tg.Execute(context.Background(), target, "query", nil, 0, 0, nil)
+	require.Error(t, err)
 
-	ts2 := &discovery.TabletHealth{
-		Tablet:  topo.NewTablet(2, "cell1", "host2"),
-		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
-		Serving: true,
-		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
-	}
+	status := tg.BalancerStatus()
+	assert.Equal(t, "cell", status.Policy)
+	require.Len(t, status.Tablets, 1)
+	assert.Equal(t, "ks", status.Tablets[0].Keyspace)
+	assert.Equal(t, "cell-0000000001", status.Tablets[0].Alias)
+	assert.EqualValues(t, 2, status.Tablets[0].Selected)
+	assert.EqualValues(t, 1, status.Tablets[0].Errors)
+	assert.EqualValues(t, 0, status.Tablets[0].InFlight)
+}
 
-	ts3 := &discovery.TabletHealth{
-		Tablet:  topo.NewTablet(3, "cell2", "host3"),
-		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
-		Serving: true,
-		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
-	}
+func TestTabletGatewayMaxReplicationLag(t *testing.T) {
+	target := &querypb.Target{Keyspace: "ks", Shard: "0", TabletType: topodatapb.TabletType_REPLICA}
+	hc := discovery.NewFakeHealthCheck(nil)
+	tg := NewTabletGateway(context.Background(), hc, nil, "cell")
 
-	ts4 := &discovery.TabletHealth{
-		Tablet:  topo.NewTablet(4, "cell2", "host4"),
-		Target:  &querypb.Target{Keyspace: "k", Shard: "s", TabletType: topodatapb.TabletType_REPLICA},
-		Serving: true,
-		Stats:   &querypb.RealtimeStats{ReplicationLagSeconds: 1, CpuUsage: 0.2},
+	primary := hc.AddTestTablet("cell", "1.1.1.1", 1001, "ks", "0", topodatapb.TabletType_PRIMARY, true, 10, nil)
+	lagging := hc.AddTestTablet("cell", "1.1.1.2", 1002, "ks", "0", topodatapb.TabletType_REPLICA, true, 10, nil)
+	fresh := hc.AddTestTablet("cell", "1.1.1.3", 1003, "ks", "0", topodatapb.TabletType_REPLICA, true, 10, nil)
+	for _, th := range hc.GetHealthyTabletStats(target) {
+		th.Stats.ReplicationLagSeconds = 2
+		if th.Tablet.Alias.Uid == 2 {
+			th.Stats.ReplicationLagSeconds = 10
+		}
+		hc.UpdateHealth(th)
 	}
 
-	sameCellTablets := []*discovery.TabletHealth{ts1, ts2}
-	diffCellTablets := []*discovery.TabletHealth{ts3, ts4}
-	mixedTablets := []*discovery.TabletHealth{ts1, ts2, ts3, ts4}
-	// repeat shuffling 10 times and every time the same cell tablets should be in the front
+	// Only the replica within the lag serves the reads.
+	ctx := context.WithValue(context.Background(), maxReplicationLagKey{}, 5*time.Second)
 	for i := 0; i < 10; i++ {
-		tg.shuffleTablets("cell1", sameCellTablets)
-		assert.Len(t, sameCellTablets, 2, "Wrong number of TabletHealth")
-		assert.Equal(t, sameCellTablets[0].Tablet.Alias.Cell, "cell1", "Wrong tablet cell")
-		assert.Equal(t, sameCellTablets[1].Tablet.Alias.Cell, "cell1", "Wrong tablet cell")
-
-		tg.shuffleTablets("cell1", diffCellTablets)
-		assert.Len(t, diffCellTablets, 2, "should shuffle in only diff cell tablets")
-		assert.Contains(t, diffCellTablets, ts3, "diffCellTablets should contain %v", ts3)
-		assert.Contains(t, diffCellTablets, ts4, "diffCellTablets should contain %v", ts4)
-
-		tg.shuffleTablets("cell1", mixedTablets)
-		assert.Len(t, mixedTablets, 4, "should have 4 tablets, got %+v", mixedTablets)
-
-		assert.Contains(t, mixedTablets[0:2], ts1, "should have same cell tablets in the front, got %+v", mixedTablets)
-		assert.Contains(t, mixedTablets[0:2], ts2, "should have same cell tablets in the front, got %+v", mixedTablets)
-
-		assert.Contains(t, mixedTablets[2:4], ts3, "should have diff cell tablets in the rear, got %+v", mixedTablets)
-		assert.Contains(t, mixedTablets[2:4], ts4, "should have diff cell tablets in the rear, got %+v", mixedTablets)
+		_, err := tg.Execute(ctx, target, "query", nil, 0, 0, nil)
+		require.NoError(t, err)
 	}
+	assert.EqualValues(t, 0, lagging.ExecCount.Load())
+	assert.EqualValues(t, 10, fresh.ExecCount.Load())
+
+	// No replica is within the lag, the read falls back to the primary.
+	ctx = context.WithValue(context.Background(), maxReplicationLagKey{}, time.Second)
+	_, err := tg.Execute(ctx, target, "query", nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, primary.ExecCount.Load())
+
+	// A transaction does not fall back, as the session records its target.
+	_, _, err = tg.BeginExecute(ctx, target, nil, "query", nil, 0, nil)
+	verifyContainsError(t, err, "a transaction or reserved connection cannot fall back to the primary", vtrpcpb.Code_UNAVAILABLE)
+	_, _, err = tg.ReserveExecute(ctx, target, nil, "query", nil, 0, nil)
+	verifyContainsError(t, err, "a transaction or reserved connection cannot fall back to the primary", vtrpcpb.Code_UNAVAILABLE)
+	assert.EqualValues(t, 1, primary.ExecCount.Load())
+
+	tg.maxReplicationLagFallback = MaxReplicationLagFallbackError
+	_, err = tg.Execute(ctx, target, "query", nil, 0, 0, nil)
+	verifyContainsError(t, err, "no healthy tablet with a replication lag of at most 1s available", vtrpcpb.Code_UNAVAILABLE)
+	assert.EqualValues(t, 1, primary.ExecCount.Load())
 }
 
 func TestTabletGatewayReplicaTransactionError(t *testing.T) {
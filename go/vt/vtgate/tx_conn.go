@@ -174,6 +174,9 @@ func (txc *TxConn) commit2PC(ctx context.Context, session *SafeSession) error {
 		_ = txc.Rollback(ctx, session)
 		return err
 	}
+	if err := checkTestFailure(ctx, "TRCreated"); err != nil {
+		return err
+	}
 
 	err = txc.runSessions(ctx, session.ShardSessions[1:], session.logging, func(ctx context.Context, s *vtgatepb.Session_ShardSession, logging *executeLogger) error {
 		return txc.tabletGateway.Prepare(ctx, s.Target, s.TransactionId, dtid)
@@ -187,11 +190,17 @@ func (txc *TxConn) commit2PC(ctx context.Context, session *SafeSession) error {
 		// Return the original error even if the previous operation fails.
 		return err
 	}
+	if err := checkTestFailure(ctx, "RMPrepared"); err != nil {
+		return err
+	}
 
 	err = txc.tabletGateway.StartCommit(ctx, mmShard.Target, mmShard.TransactionId, dtid)
 	if err != nil {
 		return err
 	}
+	if err := checkTestFailure(ctx, "MMCommitted"); err != nil {
+		return err
+	}
 
 	err = txc.runSessions(ctx, session.ShardSessions[1:], session.logging, func(ctx context.Context, s *vtgatepb.Session_ShardSession, logging *executeLogger) error {
 		return txc.tabletGateway.CommitPrepared(ctx, s.Target, dtid)
@@ -199,6 +208,9 @@ func (txc *TxConn) commit2PC(ctx context.Context, session *SafeSession) error {
 	if err != nil {
 		return err
 	}
+	if err := checkTestFailure(ctx, "RMCommitted"); err != nil {
+		return err
+	}
 
 	return txc.tabletGateway.ConcludeTransaction(ctx, mmShard.Target, dtid)
 }
@@ -319,64 +331,7 @@ func (txc *TxConn) ReleaseAll(ctx context.Context, session *SafeSession) error {
 
 // Resolve resolves the specified 2PC transaction.
 func (txc *TxConn) Resolve(ctx context.Context, dtid string) error {
-	mmShard, err := dtids.ShardSession(dtid)
-	if err != nil {
-		return err
-	}
-
-	transaction, err := txc.tabletGateway.ReadTransaction(ctx, mmShard.Target, dtid)
-	if err != nil {
-		return err
-	}
-	if transaction == nil || transaction.Dtid == "" {
-		// It was already resolved.
-		return nil
-	}
-	switch transaction.State {
-	case querypb.TransactionState_PREPARE:
-		// If state is PREPARE, make a decision to rollback and
-		// fallthrough to the rollback workflow.
-		qs, err := txc.queryService(mmShard.TabletAlias)
-		if err != nil {
-			return err
-		}
-		if err := qs.SetRollback(ctx, mmShard.Target, transaction.Dtid, mmShard.TransactionId); err != nil {
-			return err
-		}
-		fallthrough
-	case querypb.TransactionState_ROLLBACK:
-		if err := txc.resumeRollback(ctx, mmShard.Target, transaction); err != nil {
-			return err
-		}
-	case querypb.TransactionState_COMMIT:
-		if err := txc.resumeCommit(ctx, mmShard.Target, transaction); err != nil {
-			return err
-		}
-	default:
-		// Should never happen.
-		return vterrors.Errorf(vtrpcpb.Code_INTERNAL, "invalid state: %v", transaction.State)
-	}
-	return nil
-}
-
-func (txc *TxConn) resumeRollback(ctx context.Context, target *querypb.Target, transaction *querypb.TransactionMetadata) error {
-	err := txc.runTargets(transaction.Participants, func(t *querypb.Target) error {
-		return txc.tabletGateway.RollbackPrepared(ctx, t, transaction.Dtid, 0)
-	})
-	if err != nil {
-		return err
-	}
-	return txc.tabletGateway.ConcludeTransaction(ctx, target, transaction.Dtid)
-}
-
-func (txc *TxConn) resumeCommit(ctx context.Context, target *querypb.Target, transaction *querypb.TransactionMetadata) error {
-	err := txc.runTargets(transaction.Participants, func(t *querypb.Target) error {
-		return txc.tabletGateway.CommitPrepared(ctx, t, transaction.Dtid)
-	})
-	if err != nil {
-		return err
-	}
-	return txc.tabletGateway.ConcludeTransaction(ctx, target, transaction.Dtid)
+	return dtids.Resolve(ctx, txc.tabletGateway, dtid)
 }
 
 // runSessions executes the action for all shardSessions in parallel and returns a consolidated error.
@@ -400,24 +355,3 @@ func (txc *TxConn) runSessions(ctx context.Context, shardSessions []*vtgatepb.Se
 	wg.Wait()
 	return allErrors.AggrError(vterrors.Aggregate)
 }
-
-// runTargets executes the action for all targets in parallel and returns a consolildated error.
-// Flow is identical to runSessions.
-func (txc *TxConn) runTargets(targets []*querypb.Target, action func(*querypb.Target) error) error {
-	if len(targets) == 1 {
-		return action(targets[0])
-	}
-	allErrors := new(concurrency.AllErrorRecorder)
-	var wg sync.WaitGroup
-	for _, t := range targets {
-		wg.Add(1)
-		go func(t *querypb.Target) {
-			defer wg.Done()
-			if err := action(t); err != nil {
-				allErrors.RecordError(err)
-			}
-		}(t)
-	}
-	wg.Wait()
-	return allErrors.AggrError(vterrors.Aggregate)
-}
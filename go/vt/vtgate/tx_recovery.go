@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/timer"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/srvtopo"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+var twopcRecoveries = stats.NewCountersWithSingleLabel("TwoPCRecoveries", "Unresolved distributed transactions found by the 2PC recovery of vtgate, by result", "Result")
+
+// txRecovery periodically resolves the distributed transactions whose
+// coordinator died before concluding them. It asks the primary of every shard
+// for the transactions it is the metadata manager of, and which are older
+// than the abandon age, and resolves them like ResolveTransaction does.
+// Resolving is idempotent, so several vtgates and the watchdog of the
+// vttablets can recover the same transaction. At most concurrency
+// transactions are resolved at the same time.
+type txRecovery struct {
+	resolver    *srvtopo.Resolver
+	txConn      *TxConn
+	interval    time.Duration
+	abandonAge  time.Duration
+	concurrency int
+	ticks       *timer.Timer
+}
+
+func newTxRecovery(resolver *srvtopo.Resolver, txConn *TxConn, interval, abandonAge time.Duration, concurrency int) *txRecovery {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &txRecovery{
+		resolver:    resolver,
+		txConn:      txConn,
+		interval:    interval,
+		abandonAge:  abandonAge,
+		concurrency: concurrency,
+		ticks:       timer.NewTimer(interval),
+	}
+}
+
+// Open starts the recovery.
+func (tr *txRecovery) Open() {
+	tr.ticks.Start(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tr.interval)
+		defer cancel()
+		tr.recover(ctx)
+	})
+}
+
+// Close stops the recovery.
+func (tr *txRecovery) Close() {
+	tr.ticks.Stop()
+}
+
+// recover resolves the unresolved transactions of all the shards, with a pool
+// of concurrency workers.
+func (tr *txRecovery) recover(ctx context.Context) {
+	pending := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < tr.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dtid := range pending {
+				if err := tr.txConn.Resolve(ctx, dtid); err != nil {
+					twopcRecoveries.Add("Failed", 1)
+					log.Warningf("2PC recovery could not resolve %s: %v", dtid, err)
+					continue
+				}
+				twopcRecoveries.Add("Resolved", 1)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(pending)
+
+	keyspaces, err := tr.resolver.GetAllKeyspaces(ctx)
+	if err != nil {
+		twopcRecoveries.Add("ListFailed", 1)
+		log.Warningf("2PC recovery could not list the keyspaces: %v", err)
+		return
+	}
+	for _, keyspace := range keyspaces {
+		shards, _, err := tr.resolver.GetAllShards(ctx, keyspace, topodatapb.TabletType_PRIMARY)
+		if err != nil {
+			twopcRecoveries.Add("ListFailed", 1)
+			log.Warningf("2PC recovery could not list the shards of %s: %v", keyspace, err)
+			continue
+		}
+		for _, rs := range shards {
+			transactions, err := tr.txConn.tabletGateway.UnresolvedTransactions(ctx, rs.Target, tr.abandonAge)
+			if err != nil {
+				twopcRecoveries.Add("ListFailed", 1)
+				log.Warningf("2PC recovery could not read the unresolved transactions of %s/%s: %v", rs.Target.Keyspace, rs.Target.Shard, err)
+				continue
+			}
+			for _, transaction := range transactions {
+				select {
+				case pending <- transaction.Dtid:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"vitess.io/vitess/go/vt/discovery"
+	"vitess.io/vitess/go/vt/srvtopo"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+func TestTxRecovery(t *testing.T) {
+	createSandbox("TestTxRecovery").ShardSpec = "-80-"
+	hc := discovery.NewFakeHealthCheck(nil)
+	sc := newTestScatterConn(hc, newSandboxForCells([]string{"aa"}), "aa")
+	sbc0 := hc.AddTestTablet("aa", "0", 1, "TestTxRecovery", "-80", topodatapb.TabletType_PRIMARY, true, 1, nil)
+	sbc1 := hc.AddTestTablet("aa", "1", 1, "TestTxRecovery", "80-", topodatapb.TabletType_PRIMARY, true, 1, nil)
+	res := srvtopo.NewResolver(newSandboxForCells([]string{"aa"}), sc.gateway, "aa")
+	tr := newTxRecovery(res, sc.txConn, time.Minute, time.Minute, 2)
+
+	// The coordinator died after deciding to commit.
+	dtid := "TestTxRecovery:-80:1234"
+	transaction := &querypb.TransactionMetadata{
+		Dtid:  dtid,
+		State: querypb.TransactionState_COMMIT,
+		Participants: []*querypb.Target{{
+			Keyspace:   "TestTxRecovery",
+			Shard:      "80-",
+			TabletType: topodatapb.TabletType_PRIMARY,
+		}},
+	}
+	sbc0.UnresolvedTransactionsResults = []*querypb.TransactionMetadata{transaction}
+	sbc0.ReadTransactionResults = []*querypb.TransactionMetadata{transaction}
+
+	before := twopcRecoveries.Counts()["Resolved"]
+	tr.recover(ctx)
+	assert.EqualValues(t, 1, sbc0.UnresolvedTransactionsCount.Load(), "sbc0.UnresolvedTransactionsCount")
+	assert.EqualValues(t, 1, sbc1.UnresolvedTransactionsCount.Load(), "sbc1.UnresolvedTransactionsCount")
+	assert.EqualValues(t, 1, sbc1.CommitPreparedCount.Load(), "sbc1.CommitPreparedCount")
+	assert.EqualValues(t, 1, sbc0.ConcludeTransactionCount.Load(), "sbc0.ConcludeTransactionCount")
+	assert.Equal(t, before+1, twopcRecoveries.Counts()["Resolved"])
+
+	// The next recovery finds nothing to resolve.
+	sbc0.UnresolvedTransactionsResults = nil
+	tr.recover(ctx)
+	assert.EqualValues(t, 1, sbc1.CommitPreparedCount.Load(), "sbc1.CommitPreparedCount")
+	assert.Equal(t, before+1, twopcRecoveries.Counts()["Resolved"])
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/stats"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo/topoproto"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/buffer"
+	"vitess.io/vitess/go/vt/vtgate/logstats"
+
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+var txReplays = stats.NewCountersWithSingleLabel("TransactionReplays", "Transactions lost in a failover that vtgate tried to replay, by result", "Result")
+
+// txReplayer journals the statements of the transactions of the MySQL
+// protocol sessions, so that a transaction lost because its primary failed
+// over can be replayed on the new primary. A transaction is only replayed if
+// its journal is complete and the buffer saw the failover of its shard, and
+// the replay is aborted as soon as a statement returns a different result
+// than it returned to the client.
+type txReplayer struct {
+	// maxStatements and maxBytes bound the journal of a transaction. The
+	// transactions that grow larger are not replayed.
+	maxStatements int
+	maxBytes      int
+
+	mu sync.Mutex
+	// journals are the journals of the open transactions, by session UUID.
+	journals map[string]*txJournal
+}
+
+// txJournal is the journal of a transaction.
+type txJournal struct {
+	// lastInsertID, foundRows and rowCount are the stats of the session
+	// before the transaction, which its statements may use.
+	lastInsertID uint64
+	foundRows    uint64
+	rowCount     int64
+
+	statements []*journaledStatement
+	size       int
+	// unreplayable is set once the transaction grew too large, or ran a
+	// statement which cannot be replayed.
+	unreplayable bool
+}
+
+// journaledStatement is a statement of a transaction, with the digest of the
+// result it returned.
+type journaledStatement struct {
+	sql      string
+	bindVars map[string]*querypb.BindVariable
+	digest   [sha256.Size]byte
+}
+
+// txStatement is a statement the replayer watches, with the state of its
+// session before it ran.
+type txStatement struct {
+	sql      string
+	bindVars map[string]*querypb.BindVariable
+	stmtType sqlparser.StatementType
+
+	// inTransaction is set if the session was in a transaction, journaled
+	// by journal if it can be replayed.
+	inTransaction bool
+	journal       *txJournal
+	// next is the journal of the transaction the statement starts, if any.
+	next *txJournal
+	// targets are the shards of the transaction.
+	targets []*querypb.Target
+}
+
+func newTxReplayer(maxStatements, maxBytes int) *txReplayer {
+	return &txReplayer{
+		maxStatements: maxStatements,
+		maxBytes:      maxBytes,
+		journals:      make(map[string]*txJournal),
+	}
+}
+
+// before returns the statement to watch, or nil if the session is not
+// journaled. Only the MySQL protocol sessions, which have a UUID and do not
+// use reserved connections, are.
+func (r *txReplayer) before(safeSession *SafeSession, sql string, bindVars map[string]*querypb.BindVariable) *txStatement {
+	uuid := safeSession.GetSessionUUID()
+	if uuid == "" {
+		return nil
+	}
+	if safeSession.InReservedConn() {
+		r.forget(safeSession)
+		return nil
+	}
+
+	st := &txStatement{
+		sql:           sql,
+		bindVars:      copyBindVars(bindVars),
+		stmtType:      sqlparser.Preview(sql),
+		inTransaction: safeSession.InTransaction(),
+		next: &txJournal{
+			lastInsertID: safeSession.GetLastInsertId(),
+			foundRows:    safeSession.FoundRows,
+			rowCount:     safeSession.RowCount,
+		},
+	}
+	if st.inTransaction {
+		r.mu.Lock()
+		st.journal = r.journals[uuid]
+		r.mu.Unlock()
+		for _, shardSession := range safeSession.ShardSessions {
+			st.targets = append(st.targets, shardSession.Target)
+		}
+	}
+	return st
+}
+
+// after journals the statement once it ran.
+func (r *txReplayer) after(safeSession *SafeSession, st *txStatement, result *sqltypes.Result, err error) {
+	if st == nil {
+		return
+	}
+	if !safeSession.InTransaction() {
+		r.forget(safeSession)
+		return
+	}
+
+	journal := st.journal
+	if !st.inTransaction || st.stmtType == sqlparser.StmtBegin {
+		// The statement started a transaction. With autocommit disabled,
+		// it is its first statement.
+		journal = st.next
+		r.mu.Lock()
+		r.journals[safeSession.GetSessionUUID()] = journal
+		r.mu.Unlock()
+	}
+	// A failed statement rolled back its changes, so it does not need to be
+	// replayed.
+	if journal == nil || journal.unreplayable || err != nil {
+		return
+	}
+
+	journal.size += len(st.sql)
+	if !canReplayStatement(st.stmtType) || len(journal.statements) >= r.maxStatements || journal.size > r.maxBytes {
+		journal.unreplayable = true
+		journal.statements = nil
+		return
+	}
+	journal.statements = append(journal.statements, &journaledStatement{
+		sql:      st.sql,
+		bindVars: st.bindVars,
+		digest:   resultDigest(result),
+	})
+}
+
+// forget drops the journal of the session, whose transaction is over or
+// cannot be replayed.
+func (r *txReplayer) forget(safeSession *SafeSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.journals, safeSession.GetSessionUUID())
+}
+
+// canReplay returns true if the statement failed because its transaction was
+// lost, and the transaction can be replayed. A failed COMMIT is only
+// replayed if it certainly did not commit anything: if the transaction is on
+// a single shard, whose tablet rejected the COMMIT because it is no longer
+// the primary.
+func (st *txStatement) canReplay(err error) bool {
+	if st == nil || st.journal == nil || st.journal.unreplayable || !lostTransaction(err) {
+		return false
+	}
+	if st.stmtType == sqlparser.StmtCommit {
+		return len(st.targets) == 1
+	}
+	return st.stmtType != sqlparser.StmtBegin && canReplayStatement(st.stmtType)
+}
+
+// canReplayStatement returns true for the statements that only change the
+// data of the transaction, not the session.
+func canReplayStatement(stmtType sqlparser.StatementType) bool {
+	switch stmtType {
+	case sqlparser.StmtBegin, sqlparser.StmtSelect, sqlparser.StmtInsert, sqlparser.StmtReplace,
+		sqlparser.StmtUpdate, sqlparser.StmtDelete, sqlparser.StmtSavepoint, sqlparser.StmtSRollback,
+		sqlparser.StmtRelease:
+		return true
+	}
+	return false
+}
+
+// lostTransaction returns true if the error is one the buffer takes for a
+// failover. A transaction the tablet closed, e.g. because it exceeded its
+// timeout, is never replayed, even if another shard failed over at the same
+// time.
+func lostTransaction(err error) bool {
+	return buffer.CausedByFailover(err) && !vterrors.TxClosed.MatchString(err.Error())
+}
+
+// failedOn returns true if the error comes from the given target.
+func failedOn(err error, target *querypb.Target) bool {
+	return strings.Contains(err.Error(), fmt.Sprintf("target: %s.%s.%s", target.Keyspace, target.Shard, topoproto.TabletTypeLString(target.TabletType)))
+}
+
+// resultDigest returns a digest of what a result returns to the client.
+func resultDigest(qr *sqltypes.Result) [sha256.Size]byte {
+	h := sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	writeUint := func(v uint64) {
+		h.Write(buf[:binary.PutUvarint(buf[:], v)])
+	}
+	if qr != nil {
+		writeUint(qr.RowsAffected)
+		writeUint(qr.InsertID)
+		writeUint(uint64(len(qr.Rows)))
+		for _, row := range qr.Rows {
+			for _, value := range row {
+				writeUint(uint64(value.Type()))
+				writeUint(uint64(value.Len()))
+				h.Write(value.Raw())
+			}
+		}
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	return digest
+}
+
+func copyBindVars(bindVars map[string]*querypb.BindVariable) map[string]*querypb.BindVariable {
+	bv := make(map[string]*querypb.BindVariable, len(bindVars))
+	for k, v := range bindVars {
+		bv[k] = v
+	}
+	return bv
+}
+
+// replayTransaction replays the transaction of a statement which failed
+// because it was lost in a failover, then runs the statement again. It
+// waits for the buffering of the failover to end, so that the transaction is
+// replayed on the new primary, and returns the error as is if the buffer did
+// not buffer the failover of the shard the statement failed on. If the replay
+// fails, the transaction is rolled back, and an ABORTED error tells the client
+// to retry it.
+func (e *Executor) replayTransaction(ctx context.Context, safeSession *SafeSession, st *txStatement, logStats *logstats.LogStats, lostErr error) (sqlparser.StatementType, *sqltypes.Result, error) {
+	abort := func(result string, err error) (sqlparser.StatementType, *sqltypes.Result, error) {
+		txReplays.Add(result, 1)
+		log.Infof("Could not replay the transaction of session %s lost in a failover: %v", safeSession.GetSessionUUID(), err)
+		_ = e.txConn.Rollback(ctx, safeSession)
+		return st.stmtType, nil, vterrors.Errorf(vtrpcpb.Code_ABORTED, "transaction rolled back after it was lost in a failover: %v", lostErr)
+	}
+
+	buffered := false
+	for _, target := range st.targets {
+		if target.TabletType != topodatapb.TabletType_PRIMARY || !failedOn(lostErr, target) {
+			continue
+		}
+		retryDone, err := e.scatterConn.gateway.waitForFailoverEnd(ctx, target, lostErr)
+		if retryDone != nil {
+			defer retryDone()
+			buffered = true
+		}
+		if err != nil {
+			return abort("Failed", err)
+		}
+	}
+	if !buffered {
+		txReplays.Add("NotBuffered", 1)
+		return st.stmtType, nil, lostErr
+	}
+
+	_ = e.txConn.Rollback(ctx, safeSession)
+	safeSession.LastInsertId = st.journal.lastInsertID
+	safeSession.FoundRows = st.journal.foundRows
+	safeSession.RowCount = st.journal.rowCount
+	for _, stmt := range st.journal.statements {
+		replayStats := logstats.NewLogStats(ctx, "TransactionReplay", stmt.sql, safeSession.GetSessionUUID(), stmt.bindVars)
+		stmtType, qr, err := e.execute(ctx, safeSession, stmt.sql, copyBindVars(stmt.bindVars), replayStats)
+		if err != nil {
+			return abort("Failed", err)
+		}
+		if resultDigest(qr) != stmt.digest {
+			return abort("Mismatch", vterrors.Errorf(vtrpcpb.Code_ABORTED, "%s returned a different result", stmt.sql))
+		}
+		saveSessionStats(safeSession, stmtType, qr.RowsAffected, qr.InsertID, len(qr.Rows), nil)
+	}
+	txReplays.Add("Replayed", 1)
+	return e.execute(ctx, safeSession, st.sql, copyBindVars(st.bindVars), logStats)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/buffer"
+
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// enableBuffer enables the buffer of the gateway of the executor, with
+// failovers short enough for the buffered requests to be retried quickly.
+func enableBuffer(t *testing.T, executor *Executor) {
+	cfg := buffer.NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.MaxFailoverDuration = 10 * time.Millisecond
+	cfg.MinTimeBetweenFailovers = 0
+	b := buffer.New(cfg)
+	t.Cleanup(b.Shutdown)
+	executor.scatterConn.gateway.buffer = b
+}
+
+func TestTransactionReplay(t *testing.T) {
+	executor, _, _, sbclookup := createExecutorEnv()
+	executor.txReplay = newTxReplayer(10, 1024)
+	enableBuffer(t, executor)
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "suuid"})
+
+	_, err := exec(executor, session, "begin")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "select id from main1")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "update main1 set id = 2")
+	require.NoError(t, err)
+
+	// The primary fails over: the transaction is replayed, then the
+	// statement runs again.
+	before := txReplays.Counts()["Replayed"]
+	sbclookup.Queries = nil
+	sbclookup.MustFailCodes[vtrpcpb.Code_CLUSTER_EVENT] = 1
+	_, err = exec(executor, session, "delete from main1")
+	require.NoError(t, err)
+	assert.Equal(t, before+1, txReplays.Counts()["Replayed"])
+	assert.True(t, session.InTransaction())
+	var queries []string
+	for _, query := range sbclookup.Queries {
+		queries = append(queries, query.Sql)
+	}
+	assert.Equal(t, []string{"delete from main1", "select id from main1", "update main1 set id = 2", "delete from main1"}, queries)
+
+	// A single shard COMMIT the old primary rejected is replayed too.
+	sbclookup.MustFailCodes[vtrpcpb.Code_CLUSTER_EVENT] = 1
+	_, err = exec(executor, session, "commit")
+	require.NoError(t, err)
+	assert.Equal(t, before+2, txReplays.Counts()["Replayed"])
+	assert.False(t, session.InTransaction())
+	assert.Empty(t, executor.txReplay.journals)
+}
+
+func TestTransactionReplayNotBuffered(t *testing.T) {
+	executor, _, _, sbclookup := createExecutorEnv()
+	executor.txReplay = newTxReplayer(10, 1024)
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "suuid"})
+
+	_, err := exec(executor, session, "begin")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "update main1 set id = 2")
+	require.NoError(t, err)
+
+	// The buffer is disabled, so it did not wait for the end of a failover.
+	before := txReplays.Counts()["NotBuffered"]
+	sbclookup.MustFailCodes[vtrpcpb.Code_CLUSTER_EVENT] = 1
+	_, err = exec(executor, session, "delete from main1")
+	assert.Equal(t, vtrpcpb.Code_CLUSTER_EVENT, vterrors.Code(err))
+	assert.Equal(t, before+1, txReplays.Counts()["NotBuffered"])
+}
+
+func TestTransactionReplayMismatch(t *testing.T) {
+	executor, _, _, sbclookup := createExecutorEnv()
+	executor.txReplay = newTxReplayer(10, 1024)
+	enableBuffer(t, executor)
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "suuid"})
+
+	_, err := exec(executor, session, "begin")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "select id from main1")
+	require.NoError(t, err)
+
+	// The select returns another row on the new primary.
+	before := txReplays.Counts()["Mismatch"]
+	sbclookup.MustFailCodes[vtrpcpb.Code_CLUSTER_EVENT] = 1
+	sbclookup.SetResults([]*sqltypes.Result{sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "2")})
+	_, err = exec(executor, session, "update main1 set id = 2")
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_ABORTED, vterrors.Code(err))
+	assert.Contains(t, err.Error(), "transaction rolled back after it was lost in a failover")
+	assert.Equal(t, before+1, txReplays.Counts()["Mismatch"])
+	assert.False(t, session.InTransaction())
+}
+
+func TestTransactionReplayNotJournaled(t *testing.T) {
+	executor, _, _, sbclookup := createExecutorEnv()
+	executor.txReplay = newTxReplayer(2, 1024)
+	enableBuffer(t, executor)
+	session := NewSafeSession(&vtgatepb.Session{TargetString: "@primary", SessionUUID: "suuid"})
+
+	// The transaction is larger than the journal.
+	_, err := exec(executor, session, "begin")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "select id from main1")
+	require.NoError(t, err)
+	_, err = exec(executor, session, "update main1 set id = 2")
+	require.NoError(t, err)
+	sbclookup.MustFailCodes[vtrpcpb.Code_CLUSTER_EVENT] = 1
+	_, err = exec(executor, session, "delete from main1")
+	assert.Equal(t, vtrpcpb.Code_CLUSTER_EVENT, vterrors.Code(err))
+	_, err = exec(executor, session, "rollback")
+	require.NoError(t, err)
+
+	// The errors unrelated to a failover are returned.
+	_, err = exec(executor, session, "begin")
+	require.NoError(t, err)
+	sbclookup.MustFailCodes[vtrpcpb.Code_ALREADY_EXISTS] = 1
+	_, err = exec(executor, session, "insert into main1(id) values (1)")
+	assert.Equal(t, vtrpcpb.Code_ALREADY_EXISTS, vterrors.Code(err))
+
+	// The transactions the tablet killed are not replayed either.
+	_, err = exec(executor, session, "update main1 set id = 2")
+	require.NoError(t, err)
+	sbclookup.EphemeralShardErr = vterrors.Errorf(vtrpcpb.Code_ABORTED, "transaction 1234: ended at 2023-05-01 12:00:00.000 UTC (exceeded timeout: 30s)")
+	_, err = exec(executor, session, "commit")
+	assert.Equal(t, vtrpcpb.Code_ABORTED, vterrors.Code(err))
+	assert.Contains(t, err.Error(), "exceeded timeout: 30s")
+	assert.NotContains(t, err.Error(), "lost in a failover")
+}
@@ -75,6 +75,9 @@ type iExecute interface {
 	showVitessReplicationStatus(ctx context.Context, filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
 	showShards(ctx context.Context, filter *sqlparser.ShowFilter, destTabletType topodatapb.TabletType) (*sqltypes.Result, error)
 	showTablets(filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
+	showQueryDigests(filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
+	showProcessList(ctx context.Context) *sqltypes.Result
+	kill(ctx context.Context, id uint64, killType sqlparser.KillType) error
 	showVitessMetadata(ctx context.Context, filter *sqlparser.ShowFilter) (*sqltypes.Result, error)
 	setVitessMetadata(ctx context.Context, name, value string) error
 
@@ -533,6 +536,7 @@ func (vc *vcursorImpl) ExecuteMultiShard(ctx context.Context, primitive engine.P
 	}
 
 	qr, errs := vc.executor.ExecuteMultiShard(ctx, primitive, rss, commentedShardQueries(queries, vc.marginComments), vc.safeSession, canAutocommit, vc.ignoreMaxMemoryRows)
+	vc.addRowsExamined(qr)
 	vc.setRollbackOnPartialExecIfRequired(len(errs) != len(rss), rollbackOnError)
 
 	return qr, errs
@@ -547,7 +551,10 @@ func (vc *vcursorImpl) StreamExecuteMulti(ctx context.Context, primitive engine.
 		return []error{err}
 	}
 
-	errs := vc.executor.StreamExecuteMulti(ctx, primitive, vc.marginComments.Leading+query+vc.marginComments.Trailing, rss, bindVars, vc.safeSession, autocommit, callback)
+	errs := vc.executor.StreamExecuteMulti(ctx, primitive, vc.marginComments.Leading+query+vc.marginComments.Trailing, rss, bindVars, vc.safeSession, autocommit, func(reply *sqltypes.Result) error {
+		vc.addRowsExamined(reply)
+		return callback(reply)
+	})
 	vc.setRollbackOnPartialExecIfRequired(len(errs) != len(rss), rollbackOnError)
 
 	return errs
@@ -571,9 +578,18 @@ func (vc *vcursorImpl) ExecuteStandalone(ctx context.Context, primitive engine.P
 	// The autocommit flag is always set to false because we currently don't
 	// execute DMLs through ExecuteStandalone.
 	qr, errs := vc.executor.ExecuteMultiShard(ctx, primitive, rss, bqs, NewAutocommitSession(vc.safeSession.Session), false /* autocommit */, vc.ignoreMaxMemoryRows)
+	vc.addRowsExamined(qr)
 	return qr, vterrors.Aggregate(errs)
 }
 
+// addRowsExamined counts the rows the shards returned to vtgate in the
+// stats of the query.
+func (vc *vcursorImpl) addRowsExamined(qr *sqltypes.Result) {
+	if qr != nil {
+		atomic.AddUint64(&vc.logStats.RowsExamined, uint64(len(qr.Rows)))
+	}
+}
+
 // ExecuteKeyspaceID is part of the engine.VCursor interface.
 func (vc *vcursorImpl) ExecuteKeyspaceID(ctx context.Context, keyspace string, ksid []byte, query string, bindVars map[string]*querypb.BindVariable, rollbackOnError, autocommit bool) (*sqltypes.Result, error) {
 	atomic.AddUint64(&vc.logStats.ShardQueries, 1)
@@ -655,7 +671,10 @@ func (vc *vcursorImpl) fixupPartiallyMovedShards(rss []*srvtopo.ResolvedShard) (
 }
 
 func (vc *vcursorImpl) ResolveDestinations(ctx context.Context, keyspace string, ids []*querypb.Value, destinations []key.Destination) ([]*srvtopo.ResolvedShard, [][]*querypb.Value, error) {
-	rss, values, err := vc.resolver.ResolveDestinations(ctx, keyspace, vc.tabletType, ids, destinations)
+	resolve := func(keyspace string, ids []*querypb.Value, destinations []key.Destination) ([]*srvtopo.ResolvedShard, [][]*querypb.Value, error) {
+		return vc.resolver.ResolveDestinations(ctx, keyspace, vc.tabletType, ids, destinations)
+	}
+	rss, values, err := resolveWithKeyspaceIDRoutingRules(keyspace, vc.vschema.KeyspaceIDRoutingRules[keyspace], ids, destinations, resolve)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -669,7 +688,10 @@ func (vc *vcursorImpl) ResolveDestinations(ctx context.Context, keyspace string,
 }
 
 func (vc *vcursorImpl) ResolveDestinationsMultiCol(ctx context.Context, keyspace string, ids [][]sqltypes.Value, destinations []key.Destination) ([]*srvtopo.ResolvedShard, [][][]sqltypes.Value, error) {
-	rss, values, err := vc.resolver.ResolveDestinationsMultiCol(ctx, keyspace, vc.tabletType, ids, destinations)
+	resolve := func(keyspace string, ids [][]sqltypes.Value, destinations []key.Destination) ([]*srvtopo.ResolvedShard, [][][]sqltypes.Value, error) {
+		return vc.resolver.ResolveDestinationsMultiCol(ctx, keyspace, vc.tabletType, ids, destinations)
+	}
+	rss, values, err := resolveWithKeyspaceIDRoutingRules(keyspace, vc.vschema.KeyspaceIDRoutingRules[keyspace], ids, destinations, resolve)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -815,6 +837,11 @@ func (vc *vcursorImpl) GetQueryTimeout(queryTimeoutFromComments int) int {
 	return queryTimeout
 }
 
+// SetMaxReplicationLag implements the SessionActions interface
+func (vc *vcursorImpl) SetMaxReplicationLag(maxReplicationLag time.Duration) {
+	vc.safeSession.SetMaxReplicationLag(maxReplicationLag)
+}
+
 // SetClientFoundRows implements the SessionActions interface
 func (vc *vcursorImpl) SetClientFoundRows(_ context.Context, clientFoundRows bool) error {
 	vc.safeSession.GetOrCreateOptions().ClientFoundRows = clientFoundRows
@@ -1099,6 +1126,10 @@ func (vc *vcursorImpl) VStream(ctx context.Context, rss []*srvtopo.ResolvedShard
 
 func (vc *vcursorImpl) ShowExec(ctx context.Context, command sqlparser.ShowCommandType, filter *sqlparser.ShowFilter) (*sqltypes.Result, error) {
 	switch command {
+	case sqlparser.Processlist:
+		return vc.executor.showProcessList(ctx), nil
+	case sqlparser.VitessQueryDigests:
+		return vc.executor.showQueryDigests(filter)
 	case sqlparser.VitessReplicationStatus:
 		return vc.executor.showVitessReplicationStatus(ctx, filter)
 	case sqlparser.VitessShards:
@@ -1120,6 +1151,11 @@ func (vc *vcursorImpl) GetSrvVschema() *vschemapb.SrvVSchema {
 	return vc.vm.GetCurrentSrvVschema()
 }
 
+// Kill implements the VCursor interface.
+func (vc *vcursorImpl) Kill(ctx context.Context, processlistID uint64, killType sqlparser.KillType) error {
+	return vc.executor.kill(ctx, processlistID, killType)
+}
+
 func (vc *vcursorImpl) SetExec(ctx context.Context, name string, value string) error {
 	return vc.executor.setVitessMetadata(ctx, name, value)
 }
@@ -35,12 +35,16 @@ func (cached *AutoIncrement) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(48)
+		size += int64(64)
 	}
 	// field Column vitess.io/vitess/go/vt/sqlparser.IdentifierCI
 	size += cached.Column.CachedSize(false)
 	// field Sequence *vitess.io/vitess/go/vt/vtgate/vindexes.Table
 	size += cached.Sequence.CachedSize(true)
+	// field Generator vitess.io/vitess/go/vt/vtgate/vindexes.SequenceGenerator
+	if cc, ok := cached.Generator.(cachedObject); ok {
+		size += cc.CachedSize(true)
+	}
 	return size
 }
 func (cached *Binary) CachedSize(alloc bool) int64 {
@@ -81,6 +85,27 @@ func (cached *CFC) CachedSize(alloc bool) int64 {
 	size += cached.prefixCFC.CachedSize(true)
 	return size
 }
+func (cached *CRC32) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	// field name string
+	size += hack.RuntimeAllocSize(int64(len(cached.name)))
+	// field buckets vitess.io/vitess/go/vt/vtgate/vindexes.shardBuckets
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.buckets)) * int64(24))
+		for _, elem := range cached.buckets {
+			{
+				size += hack.RuntimeAllocSize(int64(cap(elem)))
+			}
+		}
+	}
+	return size
+}
 func (cached *Column) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -156,6 +181,27 @@ func (cached *Hash) CachedSize(alloc bool) int64 {
 	size += hack.RuntimeAllocSize(int64(len(cached.name)))
 	return size
 }
+func (cached *JumpHash) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	// field name string
+	size += hack.RuntimeAllocSize(int64(len(cached.name)))
+	// field buckets vitess.io/vitess/go/vt/vtgate/vindexes.shardBuckets
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.buckets)) * int64(24))
+		for _, elem := range cached.buckets {
+			{
+				size += hack.RuntimeAllocSize(int64(cap(elem)))
+			}
+		}
+	}
+	return size
+}
 func (cached *Keyspace) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -293,6 +339,27 @@ func (cached *MultiCol) CachedSize(alloc bool) int64 {
 	}
 	return size
 }
+func (cached *Murmur3) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	// field name string
+	size += hack.RuntimeAllocSize(int64(len(cached.name)))
+	// field buckets vitess.io/vitess/go/vt/vtgate/vindexes.shardBuckets
+	{
+		size += hack.RuntimeAllocSize(int64(cap(cached.buckets)) * int64(24))
+		for _, elem := range cached.buckets {
+			{
+				size += hack.RuntimeAllocSize(int64(cap(elem)))
+			}
+		}
+	}
+	return size
+}
 func (cached *Null) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
@@ -398,6 +465,16 @@ func (cached *ReverseBits) CachedSize(alloc bool) int64 {
 	size += hack.RuntimeAllocSize(int64(len(cached.name)))
 	return size
 }
+func (cached *Snowflake) CachedSize(alloc bool) int64 {
+	if cached == nil {
+		return int64(0)
+	}
+	size := int64(0)
+	if alloc {
+		size += int64(48)
+	}
+	return size
+}
 func (cached *Source) CachedSize(alloc bool) int64 {
 	if cached == nil {
 		return int64(0)
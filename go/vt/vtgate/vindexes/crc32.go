@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+)
+
+var (
+	_ SingleColumn = (*CRC32)(nil)
+	_ Hashing      = (*CRC32)(nil)
+)
+
+// CRC32 defines vindex that hashes any sql types to a KeyspaceId
+// by using the IEEE CRC-32 checksum, which is also what MySQL's CRC32()
+// function computes. It's Unique and meant to reproduce the sharding of
+// applications that used crc32.
+//
+// The value is hashed as bytes, with numbers in their decimal text form.
+// If the "shards" param is set to N, the keyspace id is derived from the
+// legacy shard number crc32 % N instead of from the checksum itself.
+// See shardBuckets.
+type CRC32 struct {
+	name    string
+	buckets shardBuckets
+}
+
+// NewCRC32 creates a new CRC32.
+func NewCRC32(name string, params map[string]string) (Vindex, error) {
+	buckets, err := newShardBuckets(params)
+	if err != nil {
+		return nil, fmt.Errorf("crc32: %v", err)
+	}
+	return &CRC32{name: name, buckets: buckets}, nil
+}
+
+// String returns the name of the vindex.
+func (vind *CRC32) String() string {
+	return vind.name
+}
+
+// Cost returns the cost of this index as 1.
+func (vind *CRC32) Cost() int {
+	return 1
+}
+
+// IsUnique returns true since the Vindex is unique.
+func (vind *CRC32) IsUnique() bool {
+	return true
+}
+
+// NeedsVCursor satisfies the Vindex interface.
+func (vind *CRC32) NeedsVCursor() bool {
+	return false
+}
+
+// Map can map ids to key.Destination objects.
+func (vind *CRC32) Map(ctx context.Context, vcursor VCursor, ids []sqltypes.Value) ([]key.Destination, error) {
+	out := make([]key.Destination, 0, len(ids))
+	for _, id := range ids {
+		ksid, err := vind.Hash(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key.DestinationKeyspaceID(ksid))
+	}
+	return out, nil
+}
+
+// Verify returns true if ids maps to ksids.
+func (vind *CRC32) Verify(ctx context.Context, vcursor VCursor, ids []sqltypes.Value, ksids [][]byte) ([]bool, error) {
+	out := make([]bool, 0, len(ids))
+	for i, id := range ids {
+		ksid, err := vind.Hash(id)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, bytes.Equal(ksid, ksids[i]))
+	}
+	return out, nil
+}
+
+func (vind *CRC32) Hash(id sqltypes.Value) ([]byte, error) {
+	idBytes, err := id.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	sum := crc32.ChecksumIEEE(idBytes)
+	if vind.buckets != nil {
+		return vind.buckets.keyspaceID(uint64(sum) % vind.buckets.count()), nil
+	}
+	var hashed [4]byte
+	binary.BigEndian.PutUint32(hashed[:], sum)
+	return hashed[:], nil
+}
+
+func init() {
+	Register("crc32", NewCRC32)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+)
+
+func TestCRC32Info(t *testing.T) {
+	vind, err := CreateVindex("crc32", "crc32_name", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, vind.Cost())
+	assert.Equal(t, "crc32_name", vind.String())
+	assert.True(t, vind.IsUnique())
+	assert.False(t, vind.NeedsVCursor())
+}
+
+func TestCRC32Map(t *testing.T) {
+	tcases := []struct {
+		params map[string]string
+		in     sqltypes.Value
+		out    []byte
+	}{{
+		// Same as MySQL's CRC32('hello').
+		in:  sqltypes.NewVarChar("hello"),
+		out: []byte{0x36, 0x10, 0xa6, 0x86},
+	}, {
+		// Same as MySQL's CRC32(1).
+		in:  sqltypes.NewInt64(1),
+		out: []byte{0x83, 0xdc, 0xef, 0xb7},
+	}, {
+		// 0x83dcefb7 % 4 = 3.
+		params: map[string]string{"shards": "4"},
+		in:     sqltypes.NewInt64(1),
+		out:    []byte{0xc0, 0, 0, 0, 0, 0, 0, 0},
+	}, {
+		// 0x3610a686 % 3 = 1, which starts shard 55-aa.
+		params: map[string]string{"shards": "3"},
+		in:     sqltypes.NewVarChar("hello"),
+		out:    []byte{0x55, 0, 0, 0, 0, 0, 0, 0},
+	}}
+	for _, tcase := range tcases {
+		vind, err := CreateVindex("crc32", "crc32", tcase.params)
+		require.NoError(t, err)
+		got, err := vind.(SingleColumn).Map(context.Background(), nil, []sqltypes.Value{tcase.in})
+		require.NoError(t, err)
+		assert.Equal(t, []key.Destination{key.DestinationKeyspaceID(tcase.out)}, got, "Map(%v) with %v", tcase.in, tcase.params)
+
+		ok, err := vind.(SingleColumn).Verify(context.Background(), nil, []sqltypes.Value{tcase.in}, [][]byte{tcase.out})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true}, ok)
+	}
+}
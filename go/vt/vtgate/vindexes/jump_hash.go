@@ -0,0 +1,137 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+)
+
+var (
+	_ SingleColumn = (*JumpHash)(nil)
+	_ Hashing      = (*JumpHash)(nil)
+)
+
+// JumpHash defines vindex that maps an integer to a KeyspaceId by
+// using Lamping and Veach's jump consistent hash over the number of
+// shards given by the required "shards" param. It's Unique and meant
+// to reproduce the sharding of applications that used jump hash.
+// Negative numbers are hashed as their two's complement uint64.
+// See shardBuckets for how legacy shards map to keyspace ids.
+type JumpHash struct {
+	name    string
+	buckets shardBuckets
+}
+
+// NewJumpHash creates a new JumpHash.
+func NewJumpHash(name string, params map[string]string) (Vindex, error) {
+	buckets, err := newShardBuckets(params)
+	if err != nil {
+		return nil, fmt.Errorf("jump_consistent_hash: %v", err)
+	}
+	if buckets == nil {
+		return nil, errors.New("jump_consistent_hash: missing shards param")
+	}
+	return &JumpHash{name: name, buckets: buckets}, nil
+}
+
+// String returns the name of the vindex.
+func (vind *JumpHash) String() string {
+	return vind.name
+}
+
+// Cost returns the cost of this index as 1.
+func (vind *JumpHash) Cost() int {
+	return 1
+}
+
+// IsUnique returns true since the Vindex is unique.
+func (vind *JumpHash) IsUnique() bool {
+	return true
+}
+
+// NeedsVCursor satisfies the Vindex interface.
+func (vind *JumpHash) NeedsVCursor() bool {
+	return false
+}
+
+// Map can map ids to key.Destination objects.
+func (vind *JumpHash) Map(ctx context.Context, vcursor VCursor, ids []sqltypes.Value) ([]key.Destination, error) {
+	out := make([]key.Destination, len(ids))
+	for i, id := range ids {
+		ksid, err := vind.Hash(id)
+		if err != nil {
+			out[i] = key.DestinationNone{}
+			continue
+		}
+		out[i] = key.DestinationKeyspaceID(ksid)
+	}
+	return out, nil
+}
+
+// Verify returns true if ids maps to ksids.
+func (vind *JumpHash) Verify(ctx context.Context, vcursor VCursor, ids []sqltypes.Value, ksids [][]byte) ([]bool, error) {
+	out := make([]bool, 0, len(ids))
+	for i, id := range ids {
+		ksid, err := vind.Hash(id)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, bytes.Equal(ksid, ksids[i]))
+	}
+	return out, nil
+}
+
+func (vind *JumpHash) Hash(id sqltypes.Value) ([]byte, error) {
+	var num uint64
+	var err error
+
+	if id.IsSigned() {
+		var ival int64
+		ival, err = strconv.ParseInt(id.ToString(), 10, 64)
+		num = uint64(ival)
+	} else {
+		num, err = evalengine.ToUint64(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vind.buckets.keyspaceID(uint64(jumpConsistentHash(num, int64(vind.buckets.count())))), nil
+}
+
+func init() {
+	Register("jump_consistent_hash", NewJumpHash)
+}
+
+// jumpConsistentHash returns the bucket in [0, buckets) for key, as
+// described in https://arxiv.org/abs/1406.2294.
+func jumpConsistentHash(key uint64, buckets int64) int64 {
+	var b, j int64 = -1, 0
+	for j < buckets {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+)
+
+func TestJumpHashInfo(t *testing.T) {
+	vind, err := CreateVindex("jump_consistent_hash", "jump_name", map[string]string{"shards": "8"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, vind.Cost())
+	assert.Equal(t, "jump_name", vind.String())
+	assert.True(t, vind.IsUnique())
+	assert.False(t, vind.NeedsVCursor())
+
+	_, err = CreateVindex("jump_consistent_hash", "jump_name", nil)
+	assert.EqualError(t, err, "jump_consistent_hash: missing shards param")
+}
+
+func TestJumpConsistentHash(t *testing.T) {
+	tcases := []struct {
+		key     uint64
+		buckets int64
+		out     int64
+	}{
+		{1, 1, 0},
+		{42, 57, 43},
+		{0xdead10cc, 1, 0},
+		{0xdead10cc, 666, 361},
+		{256, 1024, 520},
+	}
+	for _, tcase := range tcases {
+		assert.Equal(t, tcase.out, jumpConsistentHash(tcase.key, tcase.buckets), "jump(%d, %d)", tcase.key, tcase.buckets)
+	}
+
+	// Growing from n to n+1 buckets only moves keys to the new bucket.
+	for k := uint64(0); k < 1000; k++ {
+		for n := int64(1); n < 20; n++ {
+			before, after := jumpConsistentHash(k, n), jumpConsistentHash(k, n+1)
+			assert.True(t, after == before || after == n, "key %d moved from %d to %d", k, before, after)
+		}
+	}
+}
+
+func TestJumpHashMap(t *testing.T) {
+	vind, err := CreateVindex("jump_consistent_hash", "jump", map[string]string{"shards": "1024"})
+	require.NoError(t, err)
+	got, err := vind.(SingleColumn).Map(context.Background(), nil, []sqltypes.Value{
+		sqltypes.NewInt64(256),
+		sqltypes.NewUint64(256),
+		sqltypes.NewVarChar("256"),
+	})
+	require.NoError(t, err)
+	// jump(256, 1024) = 520, which starts shard 8200-8240.
+	ksid := []byte{0x82, 0x00, 0, 0, 0, 0, 0, 0}
+	assert.Equal(t, []key.Destination{
+		key.DestinationKeyspaceID(ksid),
+		key.DestinationKeyspaceID(ksid),
+		key.DestinationKeyspaceID(ksid),
+	}, got)
+
+	// An id that is not a number maps to no shard, as with hash.
+	got, err = vind.(SingleColumn).Map(context.Background(), nil, []sqltypes.Value{
+		sqltypes.NewVarChar("abcd"),
+		sqltypes.NewFloat64(1.5),
+		sqltypes.NewInt64(256),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []key.Destination{
+		key.DestinationNone{},
+		key.DestinationNone{},
+		key.DestinationKeyspaceID(ksid),
+	}, got)
+
+	ok, err := vind.(SingleColumn).Verify(context.Background(), nil, []sqltypes.Value{sqltypes.NewInt64(256)}, [][]byte{ksid})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true}, ok)
+}
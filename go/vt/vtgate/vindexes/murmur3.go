@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+)
+
+var (
+	_ SingleColumn = (*Murmur3)(nil)
+	_ Hashing      = (*Murmur3)(nil)
+)
+
+// Murmur3 defines vindex that hashes any sql types to a KeyspaceId
+// by using the 32-bit x86 variant of MurmurHash3. It's Unique and
+// meant to reproduce the sharding of applications that used murmur3.
+//
+// The value is hashed as bytes, with numbers in their decimal text form.
+// The optional "seed" param sets the hash seed. If the "shards" param
+// is set to N, the keyspace id is derived from the legacy shard number
+// hash % N instead of from the hash itself. See shardBuckets.
+type Murmur3 struct {
+	name    string
+	seed    uint32
+	buckets shardBuckets
+}
+
+// NewMurmur3 creates a new Murmur3.
+func NewMurmur3(name string, params map[string]string) (Vindex, error) {
+	vind := &Murmur3{name: name}
+	if s, ok := params["seed"]; ok {
+		seed, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("murmur3: invalid seed %q: %v", s, err)
+		}
+		vind.seed = uint32(seed)
+	}
+	buckets, err := newShardBuckets(params)
+	if err != nil {
+		return nil, fmt.Errorf("murmur3: %v", err)
+	}
+	vind.buckets = buckets
+	return vind, nil
+}
+
+// String returns the name of the vindex.
+func (vind *Murmur3) String() string {
+	return vind.name
+}
+
+// Cost returns the cost of this index as 1.
+func (vind *Murmur3) Cost() int {
+	return 1
+}
+
+// IsUnique returns true since the Vindex is unique.
+func (vind *Murmur3) IsUnique() bool {
+	return true
+}
+
+// NeedsVCursor satisfies the Vindex interface.
+func (vind *Murmur3) NeedsVCursor() bool {
+	return false
+}
+
+// Map can map ids to key.Destination objects.
+func (vind *Murmur3) Map(ctx context.Context, vcursor VCursor, ids []sqltypes.Value) ([]key.Destination, error) {
+	out := make([]key.Destination, 0, len(ids))
+	for _, id := range ids {
+		ksid, err := vind.Hash(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key.DestinationKeyspaceID(ksid))
+	}
+	return out, nil
+}
+
+// Verify returns true if ids maps to ksids.
+func (vind *Murmur3) Verify(ctx context.Context, vcursor VCursor, ids []sqltypes.Value, ksids [][]byte) ([]bool, error) {
+	out := make([]bool, 0, len(ids))
+	for i, id := range ids {
+		ksid, err := vind.Hash(id)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, bytes.Equal(ksid, ksids[i]))
+	}
+	return out, nil
+}
+
+func (vind *Murmur3) Hash(id sqltypes.Value) ([]byte, error) {
+	idBytes, err := id.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	h := murmur3Sum32(idBytes, vind.seed)
+	if vind.buckets != nil {
+		return vind.buckets.keyspaceID(uint64(h) % vind.buckets.count()), nil
+	}
+	var hashed [4]byte
+	binary.BigEndian.PutUint32(hashed[:], h)
+	return hashed[:], nil
+}
+
+func init() {
+	Register("murmur3", NewMurmur3)
+}
+
+// murmur3Sum32 returns the 32-bit x86 MurmurHash3 of data.
+func murmur3Sum32(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	nblocks := len(data) / 4
+	for i := 0; i < nblocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	tail := data[nblocks*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
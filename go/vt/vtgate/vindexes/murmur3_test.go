@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+)
+
+func TestMurmur3Info(t *testing.T) {
+	vind, err := CreateVindex("murmur3", "murmur3_name", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, vind.Cost())
+	assert.Equal(t, "murmur3_name", vind.String())
+	assert.True(t, vind.IsUnique())
+	assert.False(t, vind.NeedsVCursor())
+}
+
+func TestMurmur3Sum32(t *testing.T) {
+	tcases := []struct {
+		in   string
+		seed uint32
+		out  uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"", 0xffffffff, 0x81f16f39},
+		{"\x00\x00\x00\x00", 0, 0x2362f9de},
+		{"aaaa", 0x9747b28c, 0x5a97808a},
+		{"Hello, world!", 0x9747b28c, 0x24884cba},
+		{"The quick brown fox jumps over the lazy dog", 0x9747b28c, 0x2fa826cd},
+	}
+	for _, tcase := range tcases {
+		assert.Equal(t, tcase.out, murmur3Sum32([]byte(tcase.in), tcase.seed), "murmur3(%q, %d)", tcase.in, tcase.seed)
+	}
+}
+
+func TestMurmur3Map(t *testing.T) {
+	tcases := []struct {
+		params map[string]string
+		in     sqltypes.Value
+		out    []byte
+	}{{
+		in:  sqltypes.NewVarChar("aaaa"),
+		out: []byte{0x7e, 0xee, 0xd9, 0x87},
+	}, {
+		params: map[string]string{"seed": "2538058380"},
+		in:     sqltypes.NewVarChar("aaaa"),
+		out:    []byte{0x5a, 0x97, 0x80, 0x8a},
+	}, {
+		// Numbers are hashed in their text form.
+		params: map[string]string{"seed": "2538058380"},
+		in:     sqltypes.NewInt64(1),
+		out:    []byte{0x74, 0xac, 0x53, 0xbe},
+	}, {
+		// 0x5a97808a % 4 = 2.
+		params: map[string]string{"seed": "2538058380", "shards": "4"},
+		in:     sqltypes.NewVarChar("aaaa"),
+		out:    []byte{0x80, 0, 0, 0, 0, 0, 0, 0},
+	}}
+	for _, tcase := range tcases {
+		vind, err := CreateVindex("murmur3", "murmur3", tcase.params)
+		require.NoError(t, err)
+		got, err := vind.(SingleColumn).Map(context.Background(), nil, []sqltypes.Value{tcase.in})
+		require.NoError(t, err)
+		assert.Equal(t, []key.Destination{key.DestinationKeyspaceID(tcase.out)}, got, "Map(%v) with %v", tcase.in, tcase.params)
+
+		ok, err := vind.(SingleColumn).Verify(context.Background(), nil, []sqltypes.Value{tcase.in}, [][]byte{tcase.out})
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true}, ok)
+	}
+}
+
+func TestMurmur3BadParams(t *testing.T) {
+	_, err := CreateVindex("murmur3", "murmur3", map[string]string{"seed": "-1"})
+	assert.ErrorContains(t, err, "murmur3: invalid seed \"-1\"")
+	_, err = CreateVindex("murmur3", "murmur3", map[string]string{"shards": "0"})
+	assert.ErrorContains(t, err, "murmur3: invalid shards param \"0\"")
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"fmt"
+)
+
+// SequenceGenerator produces values for an auto-increment column
+// without going through a sequence table. Implementations must be
+// safe for concurrent use.
+type SequenceGenerator interface {
+	// String returns the name of the generator type.
+	String() string
+	// NextValues returns count new values, in strictly increasing order.
+	NextValues(count int64) ([]int64, error)
+}
+
+// NewSequenceGeneratorFunc creates a SequenceGenerator from the
+// params of an auto_increment definition.
+type NewSequenceGeneratorFunc func(params map[string]string) (SequenceGenerator, error)
+
+var generatorRegistry = make(map[string]NewSequenceGeneratorFunc)
+
+// RegisterSequenceGenerator registers a sequence generator type.
+// It panics if the type was already registered.
+func RegisterSequenceGenerator(generatorType string, newGeneratorFunc NewSequenceGeneratorFunc) {
+	if _, ok := generatorRegistry[generatorType]; ok {
+		panic(fmt.Sprintf("%s is already registered", generatorType))
+	}
+	generatorRegistry[generatorType] = newGeneratorFunc
+}
+
+// CreateSequenceGenerator creates a sequence generator of the specified
+// type using the supplied params. The type must have been previously
+// registered.
+func CreateSequenceGenerator(generatorType string, params map[string]string) (SequenceGenerator, error) {
+	f, ok := generatorRegistry[generatorType]
+	if !ok {
+		return nil, fmt.Errorf("sequence generator %q not found", generatorType)
+	}
+	return f(params)
+}
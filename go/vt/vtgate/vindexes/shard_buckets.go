@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"fmt"
+	"strconv"
+
+	"vitess.io/vitess/go/vt/key"
+)
+
+// shardBuckets maps the shard numbers of a legacy application-level
+// N-shard scheme to keyspace ids. The keyspace id of legacy shard i is
+// the start of the i'th range returned by key.GenerateShardRanges(N),
+// so a keyspace split that way reproduces the legacy assignment.
+type shardBuckets [][]byte
+
+// newShardBuckets builds the shardBuckets for the "shards" vindex param.
+// It returns nil if the param is not set.
+func newShardBuckets(params map[string]string) (shardBuckets, error) {
+	s, ok := params["shards"]
+	if !ok {
+		return nil, nil
+	}
+	shards, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shards param %q: %v", s, err)
+	}
+	starts, err := key.GenerateShardRangeStarts(shards)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shards param %q: %v", s, err)
+	}
+	return starts, nil
+}
+
+// keyspaceID returns the keyspace id for the legacy shard number.
+func (sb shardBuckets) keyspaceID(shard uint64) []byte {
+	return sb[shard]
+}
+
+// count returns the number of legacy shards.
+func (sb shardBuckets) count() uint64 {
+	return uint64(len(sb))
+}
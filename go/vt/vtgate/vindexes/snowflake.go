@@ -0,0 +1,173 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeTimeBits     = 63 - snowflakeNodeBits - snowflakeSequenceBits
+
+	// MaxSnowflakeNodeID is the largest node id a snowflake generator accepts.
+	MaxSnowflakeNodeID = 1<<snowflakeNodeBits - 1
+
+	snowflakeMaxSequence = 1<<snowflakeSequenceBits - 1
+	snowflakeMaxTime     = 1<<snowflakeTimeBits - 1
+
+	// defaultSnowflakeEpoch is 2020-01-01T00:00:00Z in milliseconds.
+	defaultSnowflakeEpoch = 1577836800000
+)
+
+var (
+	_ SequenceGenerator = (*Snowflake)(nil)
+
+	// snowflakeNodeID is -1 until SetSnowflakeNodeID is called.
+	snowflakeNodeID atomic.Int64
+
+	// snowflakes holds one generator per epoch for the lifetime of the
+	// process, so that rebuilding the VSchema does not reset the last
+	// issued timestamp and break monotonicity.
+	snowflakesMu sync.Mutex
+	snowflakes   = make(map[int64]*Snowflake)
+)
+
+// SetSnowflakeNodeID sets the node id that snowflake generators
+// embed in the values they produce. Every process that generates
+// values for the same table must use a distinct node id, so there is
+// no default: generators fail until the node id is set.
+func SetSnowflakeNodeID(id int64) error {
+	if id < 0 || id > MaxSnowflakeNodeID {
+		return fmt.Errorf("snowflake node id %d out of range [0, %d]", id, MaxSnowflakeNodeID)
+	}
+	snowflakeNodeID.Store(id)
+	return nil
+}
+
+// Snowflake generates time-based 63-bit ids laid out as
+// | 41 bits of milliseconds since epoch | 10 bits of node id | 12 bits of sequence |.
+//
+// Values produced by one generator are strictly increasing, even if the
+// wall clock moves backwards: the generator keeps issuing values from the
+// last timestamp it used, and borrows the next millisecond once the
+// 4096 values of a millisecond are exhausted. Values produced by
+// generators with distinct node ids never collide, and are ordered by
+// time across nodes to within the clock skew between them.
+//
+// The generator state only lives in memory. A process that restarts
+// after its clock went backwards, or after borrowing ahead of the wall
+// clock, may reissue values it produced before the restart.
+type Snowflake struct {
+	epoch int64
+	now   func() time.Time
+
+	mu       sync.Mutex
+	lastTime int64
+	sequence int64
+}
+
+// NewSnowflake creates a snowflake generator. The optional "epoch"
+// param is the start of the generator time in milliseconds since the
+// Unix epoch. It defaults to 2020-01-01T00:00:00Z.
+func NewSnowflake(params map[string]string) (SequenceGenerator, error) {
+	epoch := int64(defaultSnowflakeEpoch)
+	if s, ok := params["epoch"]; ok {
+		var err error
+		epoch, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("snowflake: invalid epoch %q: %v", s, err)
+		}
+		if epoch < 0 || epoch > time.Now().UnixMilli() {
+			return nil, fmt.Errorf("snowflake: epoch %d must not be negative or in the future", epoch)
+		}
+	}
+	for k := range params {
+		if k != "epoch" {
+			return nil, fmt.Errorf("snowflake: unknown param %q", k)
+		}
+	}
+
+	snowflakesMu.Lock()
+	defer snowflakesMu.Unlock()
+	if sf, ok := snowflakes[epoch]; ok {
+		return sf, nil
+	}
+	sf := &Snowflake{epoch: epoch, now: time.Now}
+	snowflakes[epoch] = sf
+	return sf, nil
+}
+
+// String returns the name of the generator type.
+func (sf *Snowflake) String() string {
+	return "snowflake"
+}
+
+// NextValues returns count new values in strictly increasing order.
+func (sf *Snowflake) NextValues(count int64) ([]int64, error) {
+	node := snowflakeNodeID.Load()
+	if node < 0 {
+		return nil, errors.New("snowflake: node id is not set, set --snowflake-node-id on vtgate")
+	}
+	values := make([]int64, 0, count)
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	for i := int64(0); i < count; i++ {
+		ts := sf.now().UnixMilli() - sf.epoch
+		switch {
+		case ts > sf.lastTime:
+			sf.lastTime = ts
+			sf.sequence = 0
+		case sf.sequence < snowflakeMaxSequence:
+			sf.sequence++
+		default:
+			sf.lastTime++
+			sf.sequence = 0
+		}
+		if sf.lastTime > snowflakeMaxTime {
+			return nil, fmt.Errorf("snowflake: timestamp overflow for epoch %d", sf.epoch)
+		}
+		values = append(values, sf.lastTime<<(snowflakeNodeBits+snowflakeSequenceBits)|node<<snowflakeSequenceBits|sf.sequence)
+	}
+	return values, nil
+}
+
+// MarshalJSON returns a JSON representation of the generator.
+func (sf *Snowflake) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string `json:"type"`
+		Epoch  int64  `json:"epoch"`
+		NodeID int64  `json:"node_id"`
+	}{
+		Type:   sf.String(),
+		Epoch:  sf.epoch,
+		NodeID: snowflakeNodeID.Load(),
+	})
+}
+
+func init() {
+	snowflakeNodeID.Store(-1)
+	RegisterSequenceGenerator("snowflake", NewSnowflake)
+}
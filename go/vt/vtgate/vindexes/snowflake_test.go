@@ -0,0 +1,141 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vindexes
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSnowflake(now func() time.Time) *Snowflake {
+	return &Snowflake{epoch: defaultSnowflakeEpoch, now: now}
+}
+
+// setTestSnowflakeNodeID sets the node id for the duration of the test.
+func setTestSnowflakeNodeID(t *testing.T, id int64) {
+	t.Helper()
+	require.NoError(t, SetSnowflakeNodeID(id))
+	t.Cleanup(func() { snowflakeNodeID.Store(-1) })
+}
+
+func TestSnowflakeLayout(t *testing.T) {
+	setTestSnowflakeNodeID(t, 5)
+
+	ts := time.UnixMilli(defaultSnowflakeEpoch + 1000)
+	sf := newTestSnowflake(func() time.Time { return ts })
+	values, err := sf.NextValues(3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{
+		1000<<22 | 5<<12 | 0,
+		1000<<22 | 5<<12 | 1,
+		1000<<22 | 5<<12 | 2,
+	}, values)
+}
+
+func TestSnowflakeMonotonic(t *testing.T) {
+	setTestSnowflakeNodeID(t, 0)
+	ts := time.UnixMilli(defaultSnowflakeEpoch + 1000)
+	sf := newTestSnowflake(func() time.Time { return ts })
+
+	var last int64
+	check := func(count int64) {
+		t.Helper()
+		values, err := sf.NextValues(count)
+		require.NoError(t, err)
+		require.Len(t, values, int(count))
+		for _, v := range values {
+			require.Greater(t, v, last)
+			last = v
+		}
+	}
+
+	// Exhausting the sequence of a millisecond borrows the next one.
+	check(snowflakeMaxSequence + 10)
+	assert.EqualValues(t, 1001, last>>22)
+
+	// A clock moving backwards does not produce smaller values.
+	ts = ts.Add(-time.Second)
+	check(10)
+	assert.EqualValues(t, 1001, last>>22)
+
+	// Once the clock catches up, the wall clock is used again.
+	ts = ts.Add(time.Minute)
+	check(1)
+	assert.EqualValues(t, 60000, last>>22)
+	assert.EqualValues(t, 0, last&snowflakeMaxSequence)
+}
+
+func TestSnowflakeConcurrent(t *testing.T) {
+	setTestSnowflakeNodeID(t, 0)
+	sf := newTestSnowflake(time.Now)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int64]bool)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last int64
+			for j := 0; j < 100; j++ {
+				values, err := sf.NextValues(10)
+				assert.NoError(t, err)
+				mu.Lock()
+				for _, v := range values {
+					assert.Greater(t, v, last)
+					assert.False(t, seen[v], "duplicate value %d", v)
+					seen[v] = true
+					last = v
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 8000)
+}
+
+func TestNewSnowflake(t *testing.T) {
+	a, err := NewSnowflake(nil)
+	require.NoError(t, err)
+	b, err := NewSnowflake(map[string]string{"epoch": "1577836800000"})
+	require.NoError(t, err)
+	// Generators with the same epoch share their state.
+	assert.Same(t, a, b)
+
+	_, err = NewSnowflake(map[string]string{"epoch": "-1"})
+	assert.EqualError(t, err, "snowflake: epoch -1 must not be negative or in the future")
+	_, err = NewSnowflake(map[string]string{"node": "1"})
+	assert.EqualError(t, err, `snowflake: unknown param "node"`)
+}
+
+func TestSnowflakeNodeIDUnset(t *testing.T) {
+	sf := newTestSnowflake(time.Now)
+	_, err := sf.NextValues(1)
+	assert.EqualError(t, err, "snowflake: node id is not set, set --snowflake-node-id on vtgate")
+}
+
+func TestSetSnowflakeNodeID(t *testing.T) {
+	setTestSnowflakeNodeID(t, MaxSnowflakeNodeID)
+	assert.EqualError(t, SetSnowflakeNodeID(MaxSnowflakeNodeID+1), "snowflake node id 1024 out of range [0, 1023]")
+	assert.EqualError(t, SetSnowflakeNodeID(-1), "snowflake node id -1 out of range [0, 1023]")
+}
@@ -25,6 +25,8 @@ import (
 	"strings"
 
 	"vitess.io/vitess/go/sqlescape"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/log"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/vterrors"
 
@@ -69,6 +71,30 @@ type VSchema struct {
 	uniqueVindexes    map[string]Vindex
 	Keyspaces         map[string]*KeyspaceSchema `json:"keyspaces"`
 	ShardRoutingRules map[string]string          `json:"shard_routing_rules"`
+	// KeyspaceIDRoutingRules maps a keyspace name to the rules that pin
+	// ranges of its keyspace ids to specific shards.
+	KeyspaceIDRoutingRules map[string][]*KeyspaceIDRoutingRule `json:"keyspace_id_routing_rules,omitempty"`
+}
+
+// KeyspaceIDRoutingRule routes the keyspace ids within KeyRange to
+// ToShard of ToKeyspace.
+type KeyspaceIDRoutingRule struct {
+	KeyRange   *topodatapb.KeyRange
+	ToKeyspace string
+	ToShard    string
+}
+
+// MarshalJSON returns a JSON representation of KeyspaceIDRoutingRule.
+func (rule *KeyspaceIDRoutingRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		KeyRange   string `json:"key_range"`
+		ToKeyspace string `json:"to_keyspace"`
+		ToShard    string `json:"to_shard"`
+	}{
+		KeyRange:   key.KeyRangeString(rule.KeyRange),
+		ToKeyspace: rule.ToKeyspace,
+		ToShard:    rule.ToShard,
+	})
 }
 
 // RoutingRule represents one routing rule.
@@ -228,9 +254,11 @@ func (ks *KeyspaceSchema) MarshalJSON() ([]byte, error) {
 }
 
 // AutoIncrement contains the auto-inc information for a table.
+// Exactly one of Sequence or Generator is set.
 type AutoIncrement struct {
-	Column   sqlparser.IdentifierCI `json:"column"`
-	Sequence *Table                 `json:"sequence"`
+	Column    sqlparser.IdentifierCI `json:"column"`
+	Sequence  *Table                 `json:"sequence,omitempty"`
+	Generator SequenceGenerator      `json:"generator,omitempty"`
 }
 
 type Source struct {
@@ -259,6 +287,7 @@ func BuildVSchema(source *vschemapb.SrvVSchema) (vschema *VSchema) {
 	resolveAutoIncrement(source, vschema)
 	buildRoutingRule(source, vschema)
 	buildShardRoutingRule(source, vschema)
+	buildKeyspaceIDRoutingRules(source, vschema)
 	return vschema
 }
 
@@ -576,6 +605,31 @@ func buildTables(ks *vschemapb.Keyspace, vschema *VSchema, ksvschema *KeyspaceSc
 			t.Columns = append(t.Columns, Column{Name: name, Type: col.Type})
 		}
 
+		// Initialize generator-backed AutoIncrement. Sequence-backed ones
+		// are resolved by resolveAutoIncrement once all keyspaces are built.
+		if ai := table.AutoIncrement; ai != nil && ai.Generator != "" {
+			if ai.Sequence != "" {
+				return vterrors.Errorf(
+					vtrpcpb.Code_INVALID_ARGUMENT,
+					"auto_increment for table %s cannot specify both a sequence and a generator",
+					tname,
+				)
+			}
+			generator, err := CreateSequenceGenerator(ai.Generator, ai.Params)
+			if err != nil {
+				return vterrors.Errorf(
+					vtrpcpb.Code_INVALID_ARGUMENT,
+					"invalid auto_increment generator for table %s: %v",
+					tname,
+					err,
+				)
+			}
+			t.AutoIncrement = &AutoIncrement{
+				Column:    sqlparser.NewIdentifierCI(ai.Column),
+				Generator: generator,
+			}
+		}
+
 		// Initialize ColumnVindexes.
 		for i, ind := range table.ColumnVindexes {
 			vindexInfo, ok := ks.Vindexes[ind.Name]
@@ -710,7 +764,7 @@ func resolveAutoIncrement(source *vschemapb.SrvVSchema, vschema *VSchema) {
 		ksvschema := vschema.Keyspaces[ksname]
 		for tname, table := range ks.Tables {
 			t := ksvschema.Tables[tname]
-			if t == nil || table.AutoIncrement == nil {
+			if t == nil || table.AutoIncrement == nil || table.AutoIncrement.Generator != "" {
 				continue
 			}
 			seqks, seqtab, err := sqlparser.ParseTable(table.AutoIncrement.Sequence)
@@ -869,6 +923,51 @@ func buildShardRoutingRule(source *vschemapb.SrvVSchema, vschema *VSchema) {
 	}
 }
 
+func buildKeyspaceIDRoutingRules(source *vschemapb.SrvVSchema, vschema *VSchema) {
+	if source.KeyspaceIdRoutingRules == nil || len(source.KeyspaceIdRoutingRules.Rules) == 0 {
+		return
+	}
+	rules, err := BuildKeyspaceIDRoutingRules(source.KeyspaceIdRoutingRules)
+	if err != nil {
+		// ApplyKeyspaceIdRoutingRules rejects invalid rules, so this can
+		// only happen if the topo was edited by hand. Routing the keyspace
+		// ids of a bad rule to their regular shard is the best we can do.
+		log.Errorf("ignoring invalid keyspace id routing rules: %v", err)
+		return
+	}
+	vschema.KeyspaceIDRoutingRules = rules
+}
+
+// BuildKeyspaceIDRoutingRules validates the keyspace id routing rules and
+// returns them grouped by the keyspace they apply to. Each rule must have a
+// single key range, and the key ranges of a keyspace must not overlap.
+func BuildKeyspaceIDRoutingRules(source *vschemapb.KeyspaceIdRoutingRules) (map[string][]*KeyspaceIDRoutingRule, error) {
+	rules := make(map[string][]*KeyspaceIDRoutingRule)
+	for _, rule := range source.GetRules() {
+		if rule.FromKeyspace == "" || rule.ToKeyspace == "" || rule.ToShard == "" {
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "keyspace id routing rule %v must specify from_keyspace, to_keyspace and to_shard", rule)
+		}
+		keyRanges, err := key.ParseShardingSpec(rule.KeyRange)
+		if err != nil {
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid key range in keyspace id routing rule %v: %v", rule, err)
+		}
+		if len(keyRanges) != 1 || key.KeyRangeIsComplete(keyRanges[0]) {
+			return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "keyspace id routing rule %v must have a single partial key range", rule)
+		}
+		for _, other := range rules[rule.FromKeyspace] {
+			if key.KeyRangeIntersect(other.KeyRange, keyRanges[0]) {
+				return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "keyspace id routing rules for keyspace %s overlap: %s and %s", rule.FromKeyspace, key.KeyRangeString(other.KeyRange), rule.KeyRange)
+			}
+		}
+		rules[rule.FromKeyspace] = append(rules[rule.FromKeyspace], &KeyspaceIDRoutingRule{
+			KeyRange:   keyRanges[0],
+			ToKeyspace: rule.ToKeyspace,
+			ToShard:    rule.ToShard,
+		})
+	}
+	return rules, nil
+}
+
 // FindTable returns a pointer to the Table. If a keyspace is specified, only tables
 // from that keyspace are searched. If the specified keyspace is unsharded
 // and no tables matched, it's considered valid: FindTable will construct a table
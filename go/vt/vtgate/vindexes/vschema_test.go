@@ -683,6 +683,85 @@ func TestVSchemaRoutingRules(t *testing.T) {
 	assert.Equal(t, string(wantb), string(gotb), string(gotb))
 }
 
+func TestBuildKeyspaceIDRoutingRules(t *testing.T) {
+	testcases := []struct {
+		name    string
+		rules   []*vschemapb.KeyspaceIdRoutingRule
+		want    map[string][]*KeyspaceIDRoutingRule
+		wantErr string
+	}{{
+		name: "valid",
+		rules: []*vschemapb.KeyspaceIdRoutingRule{
+			{FromKeyspace: "ks1", KeyRange: "40a0-40a1", ToKeyspace: "vip", ToShard: "-"},
+			{FromKeyspace: "ks1", KeyRange: "40a1-40a2", ToKeyspace: "ks1", ToShard: "80-"},
+			{FromKeyspace: "ks2", KeyRange: "40a0-40a1", ToKeyspace: "vip", ToShard: "-"},
+		},
+		want: map[string][]*KeyspaceIDRoutingRule{
+			"ks1": {
+				{KeyRange: &topodatapb.KeyRange{Start: []byte{0x40, 0xa0}, End: []byte{0x40, 0xa1}}, ToKeyspace: "vip", ToShard: "-"},
+				{KeyRange: &topodatapb.KeyRange{Start: []byte{0x40, 0xa1}, End: []byte{0x40, 0xa2}}, ToKeyspace: "ks1", ToShard: "80-"},
+			},
+			"ks2": {
+				{KeyRange: &topodatapb.KeyRange{Start: []byte{0x40, 0xa0}, End: []byte{0x40, 0xa1}}, ToKeyspace: "vip", ToShard: "-"},
+			},
+		},
+	}, {
+		name: "missing shard",
+		rules: []*vschemapb.KeyspaceIdRoutingRule{
+			{FromKeyspace: "ks1", KeyRange: "40a0-40a1", ToKeyspace: "vip"},
+		},
+		wantErr: "must specify from_keyspace, to_keyspace and to_shard",
+	}, {
+		name: "bad key range",
+		rules: []*vschemapb.KeyspaceIdRoutingRule{
+			{FromKeyspace: "ks1", KeyRange: "40a0-zz", ToKeyspace: "vip", ToShard: "-"},
+		},
+		wantErr: "invalid key range",
+	}, {
+		name: "full key range",
+		rules: []*vschemapb.KeyspaceIdRoutingRule{
+			{FromKeyspace: "ks1", KeyRange: "-", ToKeyspace: "vip", ToShard: "-"},
+		},
+		wantErr: "must have a single partial key range",
+	}, {
+		name: "multiple key ranges",
+		rules: []*vschemapb.KeyspaceIdRoutingRule{
+			{FromKeyspace: "ks1", KeyRange: "40-50-60", ToKeyspace: "vip", ToShard: "-"},
+		},
+		wantErr: "must have a single partial key range",
+	}, {
+		name: "overlap",
+		rules: []*vschemapb.KeyspaceIdRoutingRule{
+			{FromKeyspace: "ks1", KeyRange: "40-50", ToKeyspace: "vip", ToShard: "-"},
+			{FromKeyspace: "ks1", KeyRange: "4fff-60", ToKeyspace: "vip", ToShard: "-"},
+		},
+		wantErr: "keyspace id routing rules for keyspace ks1 overlap: 40-50 and 4fff-60",
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := BuildKeyspaceIDRoutingRules(&vschemapb.KeyspaceIdRoutingRules{Rules: tc.rules})
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			utils.MustMatch(t, tc.want, got)
+		})
+	}
+}
+
+func TestBuildVSchemaIgnoresInvalidKeyspaceIDRoutingRules(t *testing.T) {
+	got := BuildVSchema(&vschemapb.SrvVSchema{
+		KeyspaceIdRoutingRules: &vschemapb.KeyspaceIdRoutingRules{
+			Rules: []*vschemapb.KeyspaceIdRoutingRule{
+				{FromKeyspace: "ks1", KeyRange: "40-50", ToKeyspace: "vip", ToShard: "-"},
+				{FromKeyspace: "ks1", KeyRange: "48-60", ToKeyspace: "vip", ToShard: "-"},
+			},
+		},
+	})
+	assert.Empty(t, got.KeyspaceIDRoutingRules)
+}
+
 func TestChooseVindexForType(t *testing.T) {
 	testcases := []struct {
 		in  querypb.Type
@@ -2154,6 +2233,71 @@ func TestBadShardedSequence(t *testing.T) {
 	}
 }
 
+func TestSequenceGenerator(t *testing.T) {
+	good := vschemapb.SrvVSchema{
+		Keyspaces: map[string]*vschemapb.Keyspace{
+			"sharded": {
+				Sharded: true,
+				Vindexes: map[string]*vschemapb.Vindex{
+					"hash": {
+						Type: "hash",
+					},
+				},
+				Tables: map[string]*vschemapb.Table{
+					"t1": {
+						ColumnVindexes: []*vschemapb.ColumnVindex{{
+							Column: "c1",
+							Name:   "hash",
+						}},
+						AutoIncrement: &vschemapb.AutoIncrement{
+							Column:    "c1",
+							Generator: "snowflake",
+						},
+					},
+				},
+			},
+		},
+	}
+	got := BuildVSchema(&good)
+	require.NoError(t, got.Keyspaces["sharded"].Error)
+	ai := got.Keyspaces["sharded"].Tables["t1"].AutoIncrement
+	require.NotNil(t, ai)
+	assert.Equal(t, "c1", ai.Column.String())
+	assert.Nil(t, ai.Sequence)
+	assert.Equal(t, "snowflake", ai.Generator.String())
+}
+
+func TestBadSequenceGenerator(t *testing.T) {
+	testcases := []struct {
+		name string
+		ai   *vschemapb.AutoIncrement
+		want string
+	}{{
+		name: "unknown generator",
+		ai:   &vschemapb.AutoIncrement{Column: "c1", Generator: "absent"},
+		want: `invalid auto_increment generator for table t1: sequence generator "absent" not found`,
+	}, {
+		name: "sequence and generator",
+		ai:   &vschemapb.AutoIncrement{Column: "c1", Sequence: "seq", Generator: "snowflake"},
+		want: "auto_increment for table t1 cannot specify both a sequence and a generator",
+	}, {
+		name: "bad param",
+		ai:   &vschemapb.AutoIncrement{Column: "c1", Generator: "snowflake", Params: map[string]string{"epoch": "abc"}},
+		want: `invalid auto_increment generator for table t1: snowflake: invalid epoch "abc"`,
+	}}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateKeyspace(&vschemapb.Keyspace{
+				Tables: map[string]*vschemapb.Table{
+					"t1": {AutoIncrement: tc.ai},
+				},
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.want)
+		})
+	}
+}
+
 func TestFindTable(t *testing.T) {
 	input := vschemapb.SrvVSchema{
 		Keyspaces: map[string]*vschemapb.Keyspace{
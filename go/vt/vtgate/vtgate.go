@@ -44,9 +44,15 @@ import (
 	"vitess.io/vitess/go/vt/sidecardb"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/srvtopo"
+	"vitess.io/vitess/go/vt/topo"
 	"vitess.io/vitess/go/vt/topo/topoproto"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/audit"
 	"vitess.io/vitess/go/vt/vtgate/planbuilder/plancontext"
+	"vitess.io/vitess/go/vt/vtgate/querydigest"
+	"vitess.io/vitess/go/vt/vtgate/querylimiter"
+	"vitess.io/vitess/go/vt/vtgate/queryrules"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
 	"vitess.io/vitess/go/vt/vtgate/vtgateservice"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
@@ -112,6 +118,25 @@ var (
 	queryLogBufferSize = 10
 
 	messageStreamGracePeriod = 30 * time.Second
+
+	// snowflakeNodeID is the node id embedded in values produced by snowflake sequence generators.
+	snowflakeNodeID = -1
+
+	// processlistAdminUsers can see and kill the connections of all the users.
+	processlistAdminUsers []string
+
+	// transactionReplay replays the transactions lost in a failover, if they
+	// have at most transactionReplayMaxStatements statements and
+	// transactionReplayMaxBytes bytes of SQL.
+	transactionReplay              bool
+	transactionReplayMaxStatements = 20
+	transactionReplayMaxBytes      = 64 * 1024
+
+	// twopcRecoveryInterval enables the recovery of the distributed
+	// transactions older than twopcRecoveryAbandonAge.
+	twopcRecoveryInterval    time.Duration
+	twopcRecoveryAbandonAge  = 5 * time.Minute
+	twopcRecoveryConcurrency = 10
 )
 
 func registerFlags(fs *pflag.FlagSet) {
@@ -147,12 +172,40 @@ func registerFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&queryLogBufferSize, "querylog-buffer-size", queryLogBufferSize, "Maximum number of buffered query logs before throttling log output")
 	fs.DurationVar(&messageStreamGracePeriod, "message_stream_grace_period", messageStreamGracePeriod, "the amount of time to give for a vttablet to resume if it ends a message stream, usually because of a reparent.")
 	fs.BoolVar(&enableViews, "enable-views", enableViews, "Enable views support in vtgate.")
+	fs.IntVar(&snowflakeNodeID, "snowflake-node-id", snowflakeNodeID, "Node id (0-1023) embedded in values produced by snowflake auto-increment generators. Must be unique across vtgates. Required to use snowflake generators.")
+	fs.StringSliceVar(&processlistAdminUsers, "processlist_admin_users", processlistAdminUsers, "Users that can see and KILL the MySQL connections of all the users in SHOW PROCESSLIST. The other users only see and kill their own connections.")
+	fs.BoolVar(&transactionReplay, "transaction_replay", transactionReplay, "Journal the statements of the transactions of the MySQL protocol sessions, and replay a transaction lost in a primary failover on the new primary once the buffering of the failover ends. The replay is aborted if a statement returns a different result.")
+	fs.IntVar(&transactionReplayMaxStatements, "transaction_replay_max_statements", transactionReplayMaxStatements, "Maximum number of statements of a transaction replayed by --transaction_replay")
+	fs.IntVar(&transactionReplayMaxBytes, "transaction_replay_max_bytes", transactionReplayMaxBytes, "Maximum size in bytes of the SQL of the statements of a transaction replayed by --transaction_replay")
+	fs.DurationVar(&twopcRecoveryInterval, "twopc_recovery_interval", twopcRecoveryInterval, "Interval at which vtgate resolves the 2PC transactions abandoned by their coordinator. 0 disables the recovery.")
+	fs.DurationVar(&twopcRecoveryAbandonAge, "twopc_recovery_abandon_age", twopcRecoveryAbandonAge, "Age after which an unresolved 2PC transaction is considered abandoned by its coordinator and resolved by --twopc_recovery_interval")
+	fs.IntVar(&twopcRecoveryConcurrency, "twopc_recovery_concurrency", twopcRecoveryConcurrency, "Maximum number of abandoned 2PC transactions resolved at the same time by --twopc_recovery_interval")
 }
 func init() {
 	servenv.OnParseFor("vtgate", registerFlags)
 	servenv.OnParseFor("vtcombo", registerFlags)
 }
 
+// checkSnowflakeGenerators returns an error if a table of the cell's
+// SrvVSchema uses a snowflake generator, which needs --snowflake-node-id.
+func checkSnowflakeGenerators(ctx context.Context, ts *topo.Server, cell string) error {
+	srvVSchema, err := ts.GetSrvVSchema(ctx, cell)
+	if err != nil {
+		if topo.IsErrType(err, topo.NoNode) {
+			return nil
+		}
+		return err
+	}
+	for ksName, ks := range srvVSchema.Keyspaces {
+		for tblName, tbl := range ks.Tables {
+			if tbl.AutoIncrement.GetGenerator() == "snowflake" {
+				return fmt.Errorf("table %s.%s uses a snowflake generator, but --snowflake-node-id is not set", ksName, tblName)
+			}
+		}
+	}
+	return nil
+}
+
 func getTxMode() vtgatepb.TransactionMode {
 	switch strings.ToLower(transactionMode) {
 	case "single":
@@ -255,6 +308,10 @@ func Init(
 	if _, err := schema.ParseDDLStrategy(defaultDDLStrategy); err != nil {
 		log.Fatalf("Invalid value for -ddl_strategy: %v", err.Error())
 	}
+	queryLimiter, err := querylimiter.New(querylimiter.NewConfigFromFlags())
+	if err != nil {
+		log.Fatalf("Invalid query limits: %v", err.Error())
+	}
 	tc := NewTxConn(gw, getTxMode())
 	// ScatterConn depends on TxConn to perform forced rollbacks.
 	sc := NewScatterConn("VttabletCall", tc, gw)
@@ -266,6 +323,13 @@ func Init(
 	if err != nil {
 		log.Fatalf("Unable to get Topo server: %v", err)
 	}
+	if snowflakeNodeID >= 0 {
+		if err := vindexes.SetSnowflakeNodeID(int64(snowflakeNodeID)); err != nil {
+			log.Fatalf("Invalid value for --snowflake-node-id: %v", err.Error())
+		}
+	} else if err := checkSnowflakeGenerators(ctx, ts, cell); err != nil {
+		log.Fatalf("Invalid value for --snowflake-node-id: %v", err.Error())
+	}
 	// Create a global cache to use for lookups of the sidecar database
 	// identifier in use by each keyspace.
 	_, created := sidecardb.NewIdentifierCache(func(ctx context.Context, keyspace string) (string, error) {
@@ -307,6 +371,18 @@ func Init(
 		noScatter,
 		pv,
 	)
+	executor.queryLimiter = queryLimiter
+	executor.queryDigests = querydigest.NewTableFromFlags()
+	if transactionReplay {
+		executor.txReplay = newTxReplayer(transactionReplayMaxStatements, transactionReplayMaxBytes)
+	}
+	executor.auditLogger, err = audit.NewLoggerFromFlags()
+	if err != nil {
+		log.Fatalf("Unable to start the audit log: %v", err)
+	}
+	if err := queryrules.StartFromFlags(ctx, ts, executor.queryRules); err != nil {
+		log.Fatalf("Unable to load the query rules: %v", err)
+	}
 
 	// connect the schema tracker with the vschema manager
 	if enableSchemaChangeSignal {
@@ -339,6 +415,11 @@ func Init(
 		logStreamExecute: logutil.NewThrottledLogger("StreamExecute", 5*time.Second),
 	}
 
+	var recovery *txRecovery
+	if twopcRecoveryInterval > 0 {
+		recovery = newTxRecovery(srvResolver, tc, twopcRecoveryInterval, twopcRecoveryAbandonAge, twopcRecoveryConcurrency)
+	}
+
 	_ = stats.NewRates("QPSByOperation", stats.CounterForDimension(rpcVTGate.timings, "Operation"), 15, 1*time.Minute)
 	_ = stats.NewRates("QPSByKeyspace", stats.CounterForDimension(rpcVTGate.timings, "Keyspace"), 15, 1*time.Minute)
 	_ = stats.NewRates("QPSByDbType", stats.CounterForDimension(rpcVTGate.timings, "DbType"), 15*60/5, 5*time.Second)
@@ -355,11 +436,17 @@ func Init(
 		if st != nil && enableSchemaChangeSignal {
 			st.Start()
 		}
+		if recovery != nil {
+			recovery.Open()
+		}
 	})
 	servenv.OnTerm(func() {
 		if st != nil && enableSchemaChangeSignal {
 			st.Stop()
 		}
+		if recovery != nil {
+			recovery.Close()
+		}
 	})
 	rpcVTGate.registerDebugHealthHandler()
 	rpcVTGate.registerDebugEnvHandler()
@@ -0,0 +1,486 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgateconn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+// DebeziumRecord is a change event in the JSON format of the Debezium
+// connectors. Key and Value are JSON documents: Key holds the primary key
+// of the row, and Value the change event envelope. Each delete is followed
+// by a tombstone, a record of the same key with a null Value.
+type DebeziumRecord struct {
+	Topic string          `json:"topic"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// DebeziumSink receives the records of a Debezium stream.
+type DebeziumSink interface {
+	// Write writes the records of one or more transactions.
+	Write(records []*DebeziumRecord) error
+	// Flush makes the records written so far durable. The position of
+	// the stream is checkpointed once Flush returns.
+	Flush() error
+	// Close flushes and releases the sink.
+	Close() error
+}
+
+// DebeziumCheckpointer stores the position of a Debezium stream.
+type DebeziumCheckpointer interface {
+	// Load returns the stored position, or nil if there is none.
+	Load() (*binlogdatapb.VGtid, error)
+	// Save stores the position.
+	Save(vgtid *binlogdatapb.VGtid) error
+}
+
+// DebeziumStreamOptions are the options of a Debezium stream.
+type DebeziumStreamOptions struct {
+	// Name is the logical name of the source, which prefixes the topics
+	// of the records.
+	Name       string
+	TabletType topodatapb.TabletType
+	// VGtid is the position to start from, if there is no checkpoint.
+	// A shard with an empty gtid is copied before its changes are
+	// streamed.
+	VGtid  *binlogdatapb.VGtid
+	Filter *binlogdatapb.Filter
+	Flags  *vtgatepb.VStreamFlags
+	Sink   DebeziumSink
+	// Checkpointer, if set, stores the position of the stream.
+	Checkpointer DebeziumCheckpointer
+}
+
+// DebeziumStream streams the events of a VStream to a sink, as Debezium
+// records. The position of the stream is checkpointed after the records of
+// every batch of transactions are flushed to the sink, and the stream
+// resumes from the checkpoint: records may be delivered twice after a
+// restart, but none is lost.
+func (conn *VTGateConn) DebeziumStream(ctx context.Context, opts *DebeziumStreamOptions) error {
+	vgtid := opts.VGtid
+	if opts.Checkpointer != nil {
+		saved, err := opts.Checkpointer.Load()
+		if err != nil {
+			return err
+		}
+		if saved != nil {
+			vgtid = saved
+		}
+	}
+	reader, err := conn.VStream(ctx, opts.TabletType, vgtid, opts.Filter, opts.Flags)
+	if err != nil {
+		return err
+	}
+	converter := NewDebeziumConverter(opts.Name, vgtid)
+	for {
+		events, err := reader.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// VTGate sends whole transactions, so the records of a batch
+		// are flushed along with its last position.
+		var records []*DebeziumRecord
+		var checkpoint *binlogdatapb.VGtid
+		for _, event := range events {
+			eventRecords, err := converter.Convert(event)
+			if err != nil {
+				return err
+			}
+			records = append(records, eventRecords...)
+			if event.Type == binlogdatapb.VEventType_VGTID {
+				checkpoint = event.Vgtid
+			}
+		}
+		if len(records) > 0 {
+			if err := opts.Sink.Write(records); err != nil {
+				return err
+			}
+		}
+		if checkpoint == nil {
+			continue
+		}
+		if err := opts.Sink.Flush(); err != nil {
+			return err
+		}
+		if opts.Checkpointer != nil {
+			if err := opts.Checkpointer.Save(checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// The Debezium operation codes.
+const (
+	debeziumOpCreate = "c"
+	debeziumOpUpdate = "u"
+	debeziumOpDelete = "d"
+	debeziumOpRead   = "r"
+)
+
+// debeziumSource is the source block of a Debezium record.
+type debeziumSource struct {
+	Connector string `json:"connector"`
+	Name      string `json:"name"`
+	TsMs      int64  `json:"ts_ms"`
+	Snapshot  string `json:"snapshot"`
+	Db        string `json:"db"`
+	Keyspace  string `json:"keyspace"`
+	Table     string `json:"table,omitempty"`
+	Shard     string `json:"shard"`
+	Vgtid     string `json:"vgtid"`
+}
+
+// debeziumEnvelope is the value of a Debezium data change record.
+type debeziumEnvelope struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+	Source *debeziumSource `json:"source"`
+	Op     string          `json:"op"`
+	TsMs   int64           `json:"ts_ms"`
+}
+
+// debeziumSchemaChange is the value of a Debezium schema change record.
+type debeziumSchemaChange struct {
+	Source       *debeziumSource `json:"source"`
+	DatabaseName string          `json:"databaseName"`
+	Ddl          string          `json:"ddl"`
+	TsMs         int64           `json:"ts_ms"`
+}
+
+// vgtidPosition is the representation of a shard position in the vgtid
+// of the source block, as in the Debezium Vitess connector.
+type vgtidPosition struct {
+	Keyspace string `json:"keyspace"`
+	Shard    string `json:"shard"`
+	Gtid     string `json:"gtid"`
+}
+
+// DebeziumConverter converts the events of a VStream to Debezium records.
+// Data change records are sent to the topic <name>.<keyspace>.<table>, and
+// schema change records to the topic <name>.
+type DebeziumConverter struct {
+	name string
+	// fields are the fields of the tables, by keyspace, shard and table.
+	fields map[string][]*querypb.Field
+	vgtid  string
+	// copying tells whether the rows of a keyspace or a shard are being
+	// copied. Their records are then snapshot reads.
+	copying map[string]bool
+	now     func() time.Time
+}
+
+// NewDebeziumConverter returns a converter for a VStream which starts at
+// the given position.
+func NewDebeziumConverter(name string, vgtid *binlogdatapb.VGtid) *DebeziumConverter {
+	dc := &DebeziumConverter{
+		name:    name,
+		fields:  make(map[string][]*querypb.Field),
+		copying: make(map[string]bool),
+		now:     time.Now,
+	}
+	dc.setVGtid(vgtid)
+	for _, sgtid := range vgtid.GetShardGtids() {
+		dc.copying[shardKey(sgtid.Keyspace, sgtid.Shard)] = sgtid.Gtid == "" || len(sgtid.TablePKs) > 0
+	}
+	return dc
+}
+
+// Convert returns the records of an event.
+func (dc *DebeziumConverter) Convert(event *binlogdatapb.VEvent) ([]*DebeziumRecord, error) {
+	switch event.Type {
+	case binlogdatapb.VEventType_VGTID:
+		dc.setVGtid(event.Vgtid)
+	case binlogdatapb.VEventType_COPY_COMPLETED:
+		if event.Keyspace == "" {
+			// The copy of all the shards is completed.
+			for key := range dc.copying {
+				dc.copying[key] = false
+			}
+			return nil, nil
+		}
+		dc.copying[shardKey(event.Keyspace, event.Shard)] = false
+	case binlogdatapb.VEventType_FIELD:
+		keyspace, shard := eventShard(event, event.FieldEvent.Keyspace, event.FieldEvent.Shard)
+		table := tableName(keyspace, event.FieldEvent.TableName)
+		dc.fields[tableKey(keyspace, shard, table)] = event.FieldEvent.Fields
+	case binlogdatapb.VEventType_ROW:
+		return dc.convertRows(event)
+	case binlogdatapb.VEventType_DDL:
+		return dc.convertDDL(event)
+	}
+	return nil, nil
+}
+
+func (dc *DebeziumConverter) convertRows(event *binlogdatapb.VEvent) ([]*DebeziumRecord, error) {
+	keyspace, shard := eventShard(event, event.RowEvent.Keyspace, event.RowEvent.Shard)
+	table := tableName(keyspace, event.RowEvent.TableName)
+	fields, ok := dc.fields[tableKey(keyspace, shard, table)]
+	if !ok {
+		return nil, fmt.Errorf("no fields for table %s.%s in shard %s", keyspace, table, shard)
+	}
+	source := dc.source(event, keyspace, shard, table)
+	topic := dc.name + "." + keyspace + "." + table
+	var records []*DebeziumRecord
+	// record adds the record of a change, and a tombstone after a delete so
+	// that the rows deleted can be compacted away.
+	record := func(op string, before, after *querypb.Row) error {
+		envelope := &debeziumEnvelope{
+			Source: source,
+			Op:     op,
+			TsMs:   dc.tsMs(event),
+		}
+		var err error
+		if envelope.Before, err = debeziumRow(fields, before, false); err != nil {
+			return err
+		}
+		if envelope.After, err = debeziumRow(fields, after, false); err != nil {
+			return err
+		}
+		keyRow := after
+		if op == debeziumOpDelete {
+			keyRow = before
+		}
+		key, err := debeziumRow(fields, keyRow, true)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(envelope)
+		if err != nil {
+			return err
+		}
+		records = append(records, &DebeziumRecord{
+			Topic: topic,
+			Key:   key,
+			Value: value,
+		})
+		if op == debeziumOpDelete && key != nil {
+			records = append(records, &DebeziumRecord{
+				Topic: topic,
+				Key:   key,
+			})
+		}
+		return nil
+	}
+	for _, change := range event.RowEvent.RowChanges {
+		var err error
+		switch {
+		case source.Snapshot == "true":
+			err = record(debeziumOpRead, change.Before, change.After)
+		case change.Before == nil:
+			err = record(debeziumOpCreate, nil, change.After)
+		case change.After == nil:
+			err = record(debeziumOpDelete, change.Before, nil)
+		default:
+			var keyChanged bool
+			if keyChanged, err = primaryKeyChanged(fields, change); err != nil {
+				return nil, err
+			}
+			if !keyChanged {
+				err = record(debeziumOpUpdate, change.Before, change.After)
+				break
+			}
+			// As with the Debezium connectors, an update which changes the
+			// primary key is a delete of the old key and a create of the
+			// new one, for the consumers which key the rows on it.
+			if err = record(debeziumOpDelete, change.Before, nil); err == nil {
+				err = record(debeziumOpCreate, nil, change.After)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// primaryKeyChanged returns true if an update changes the primary key of the
+// row.
+func primaryKeyChanged(fields []*querypb.Field, change *binlogdatapb.RowChange) (bool, error) {
+	before, err := debeziumRow(fields, change.Before, true)
+	if err != nil {
+		return false, err
+	}
+	after, err := debeziumRow(fields, change.After, true)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(before, after), nil
+}
+
+func (dc *DebeziumConverter) convertDDL(event *binlogdatapb.VEvent) ([]*DebeziumRecord, error) {
+	key, err := json.Marshal(map[string]string{"databaseName": event.Keyspace})
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(&debeziumSchemaChange{
+		Source:       dc.source(event, event.Keyspace, event.Shard, ""),
+		DatabaseName: event.Keyspace,
+		Ddl:          event.Statement,
+		TsMs:         dc.tsMs(event),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*DebeziumRecord{{
+		Topic: dc.name,
+		Key:   key,
+		Value: value,
+	}}, nil
+}
+
+func (dc *DebeziumConverter) source(event *binlogdatapb.VEvent, keyspace, shard, table string) *debeziumSource {
+	snapshot := "false"
+	if dc.isCopying(keyspace, shard) {
+		snapshot = "true"
+	}
+	return &debeziumSource{
+		Connector: "vitess",
+		Name:      dc.name,
+		TsMs:      event.Timestamp * 1000,
+		Snapshot:  snapshot,
+		Db:        keyspace,
+		Keyspace:  keyspace,
+		Table:     table,
+		Shard:     shard,
+		Vgtid:     dc.vgtid,
+	}
+}
+
+func (dc *DebeziumConverter) isCopying(keyspace, shard string) bool {
+	if copying, ok := dc.copying[shardKey(keyspace, shard)]; ok {
+		return copying
+	}
+	// The shards of a keyspace streamed as a whole.
+	return dc.copying[shardKey(keyspace, "")]
+}
+
+// tsMs returns the time at which the event was processed.
+func (dc *DebeziumConverter) tsMs(event *binlogdatapb.VEvent) int64 {
+	if event.CurrentTime != 0 {
+		return event.CurrentTime / int64(time.Millisecond)
+	}
+	return dc.now().UnixMilli()
+}
+
+func (dc *DebeziumConverter) setVGtid(vgtid *binlogdatapb.VGtid) {
+	positions := make([]vgtidPosition, 0, len(vgtid.GetShardGtids()))
+	for _, sgtid := range vgtid.GetShardGtids() {
+		positions = append(positions, vgtidPosition{
+			Keyspace: sgtid.Keyspace,
+			Shard:    sgtid.Shard,
+			Gtid:     sgtid.Gtid,
+		})
+	}
+	b, _ := json.Marshal(positions)
+	dc.vgtid = string(b)
+}
+
+// debeziumRow returns the JSON document of a row, with its columns in the
+// order of the table. If keyOnly is set, only the primary key columns are
+// included.
+func debeziumRow(fields []*querypb.Field, row *querypb.Row, keyOnly bool) (json.RawMessage, error) {
+	if row == nil {
+		return nil, nil
+	}
+	vals := sqltypes.MakeRowTrusted(fields, row)
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	separator := ""
+	for i, field := range fields {
+		if keyOnly && field.Flags&uint32(querypb.MySqlFlag_PRI_KEY_FLAG) == 0 {
+			continue
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := debeziumValue(vals[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(separator)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+		separator = ","
+	}
+	if keyOnly && separator == "" {
+		// The table has no primary key.
+		return nil, nil
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// debeziumValue returns the JSON value of a column. As in the default
+// configuration of the Debezium connectors, binary values are base64
+// encoded, and decimals are strings.
+func debeziumValue(v sqltypes.Value) ([]byte, error) {
+	switch {
+	case v.IsNull():
+		return []byte("null"), nil
+	case v.IsIntegral(), v.IsFloat():
+		return v.Raw(), nil
+	case v.IsBinary(), v.Type() == sqltypes.Bit:
+		return json.Marshal(v.Raw())
+	default:
+		return json.Marshal(v.ToString())
+	}
+}
+
+// eventShard returns the keyspace and the shard of an event, which are set
+// either on the event or on its row or field event.
+func eventShard(event *binlogdatapb.VEvent, keyspace, shard string) (string, string) {
+	if keyspace == "" {
+		keyspace = event.Keyspace
+	}
+	if shard == "" {
+		shard = event.Shard
+	}
+	return keyspace, shard
+}
+
+// tableName strips the keyspace that VTGate prefixes table names with.
+func tableName(keyspace, name string) string {
+	return strings.TrimPrefix(name, keyspace+".")
+}
+
+func shardKey(keyspace, shard string) string {
+	return keyspace + "/" + shard
+}
+
+func tableKey(keyspace, shard, table string) string {
+	return keyspace + "/" + shard + "/" + table
+}
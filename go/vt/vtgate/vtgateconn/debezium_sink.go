@@ -0,0 +1,219 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgateconn
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+// debeziumWriterSink writes the records to a writer, one JSON document per
+// line.
+type debeziumWriterSink struct {
+	w *bufio.Writer
+}
+
+// NewDebeziumWriterSink returns a sink which writes the records to w, one
+// JSON document per line.
+func NewDebeziumWriterSink(w io.Writer) DebeziumSink {
+	return &debeziumWriterSink{w: bufio.NewWriter(w)}
+}
+
+// Write is part of the DebeziumSink interface.
+func (sink *debeziumWriterSink) Write(records []*DebeziumRecord) error {
+	_, err := writeDebeziumRecords(sink.w, records)
+	return err
+}
+
+// Flush is part of the DebeziumSink interface.
+func (sink *debeziumWriterSink) Flush() error {
+	return sink.w.Flush()
+}
+
+// Close is part of the DebeziumSink interface.
+func (sink *debeziumWriterSink) Close() error {
+	return sink.w.Flush()
+}
+
+// writeDebeziumRecords writes the records as JSON lines, and returns the
+// number of bytes written.
+func writeDebeziumRecords(w *bufio.Writer, records []*DebeziumRecord) (int64, error) {
+	var written int64
+	for _, record := range records {
+		b, err := json.Marshal(record)
+		if err != nil {
+			return written, err
+		}
+		b = append(b, '\n')
+		n, err := w.Write(b)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// debeziumFileSink writes the records to rotating files.
+type debeziumFileSink struct {
+	dir      string
+	maxBytes int64
+	seq      int
+
+	file    *os.File
+	w       *bufio.Writer
+	written int64
+}
+
+// NewDebeziumFileSink returns a sink which writes the records to files of
+// dir, one JSON document per line. The files are named after their
+// sequence number, and a new file is started once the current one reaches
+// maxBytes. The records of a transaction are always written to the same
+// file. The sequence continues after the files already in dir.
+func NewDebeziumFileSink(dir string, maxBytes int64) (DebeziumSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "debezium-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sink := &debeziumFileSink{
+		dir:      dir,
+		maxBytes: maxBytes,
+	}
+	for _, name := range names {
+		var seq int
+		if _, err := fmt.Sscanf(filepath.Base(name), "debezium-%d.json", &seq); err == nil && seq > sink.seq {
+			sink.seq = seq
+		}
+	}
+	return sink, nil
+}
+
+// Write is part of the DebeziumSink interface.
+func (sink *debeziumFileSink) Write(records []*DebeziumRecord) error {
+	if sink.file == nil {
+		sink.seq++
+		file, err := os.OpenFile(filepath.Join(sink.dir, fmt.Sprintf("debezium-%06d.json", sink.seq)), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		sink.file = file
+		sink.w = bufio.NewWriter(file)
+		sink.written = 0
+	}
+	n, err := writeDebeziumRecords(sink.w, records)
+	sink.written += n
+	if err != nil {
+		return err
+	}
+	if sink.maxBytes > 0 && sink.written >= sink.maxBytes {
+		return sink.closeFile()
+	}
+	return nil
+}
+
+// Flush is part of the DebeziumSink interface.
+func (sink *debeziumFileSink) Flush() error {
+	if sink.file == nil {
+		return nil
+	}
+	if err := sink.w.Flush(); err != nil {
+		return err
+	}
+	return sink.file.Sync()
+}
+
+// Close is part of the DebeziumSink interface.
+func (sink *debeziumFileSink) Close() error {
+	if sink.file == nil {
+		return nil
+	}
+	return sink.closeFile()
+}
+
+func (sink *debeziumFileSink) closeFile() error {
+	if err := sink.Flush(); err != nil {
+		return err
+	}
+	err := sink.file.Close()
+	sink.file = nil
+	sink.w = nil
+	return err
+}
+
+// debeziumFileCheckpointer stores the position of a stream in a local file.
+type debeziumFileCheckpointer struct {
+	path string
+}
+
+// NewDebeziumFileCheckpointer returns a checkpointer which stores the
+// position of a stream in the file at path. The file is replaced
+// atomically.
+func NewDebeziumFileCheckpointer(path string) DebeziumCheckpointer {
+	return &debeziumFileCheckpointer{path: path}
+}
+
+// Load is part of the DebeziumCheckpointer interface.
+func (cp *debeziumFileCheckpointer) Load() (*binlogdatapb.VGtid, error) {
+	b, err := os.ReadFile(cp.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	vgtid := &binlogdatapb.VGtid{}
+	if err := protojson.Unmarshal(b, vgtid); err != nil {
+		return nil, fmt.Errorf("cannot parse checkpoint %s: %v", cp.path, err)
+	}
+	return vgtid, nil
+}
+
+// Save is part of the DebeziumCheckpointer interface.
+func (cp *debeziumFileCheckpointer) Save(vgtid *binlogdatapb.VGtid) error {
+	b, err := protojson.Marshal(vgtid)
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(b); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
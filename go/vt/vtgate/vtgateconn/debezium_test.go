@@ -0,0 +1,184 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vtgateconn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"vitess.io/vitess/go/sqltypes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtgatepb "vitess.io/vitess/go/vt/proto/vtgate"
+)
+
+type fakeVStreamImpl struct {
+	Impl
+	vgtid   *binlogdatapb.VGtid
+	batches [][]*binlogdatapb.VEvent
+}
+
+func (impl *fakeVStreamImpl) VStream(ctx context.Context, tabletType topodatapb.TabletType, vgtid *binlogdatapb.VGtid, filter *binlogdatapb.Filter, flags *vtgatepb.VStreamFlags) (VStreamReader, error) {
+	impl.vgtid = vgtid
+	return impl, nil
+}
+
+func (impl *fakeVStreamImpl) Recv() ([]*binlogdatapb.VEvent, error) {
+	if len(impl.batches) == 0 {
+		return nil, io.EOF
+	}
+	batch := impl.batches[0]
+	impl.batches = impl.batches[1:]
+	return batch, nil
+}
+
+func debeziumTestVGtid(gtid string, tablePKs ...*binlogdatapb.TableLastPK) *binlogdatapb.VGtid {
+	return &binlogdatapb.VGtid{
+		ShardGtids: []*binlogdatapb.ShardGtid{{
+			Keyspace: "ks",
+			Shard:    "-80",
+			Gtid:     gtid,
+			TablePKs: tablePKs,
+		}},
+	}
+}
+
+func TestDebeziumStream(t *testing.T) {
+	fields := []*querypb.Field{
+		{Name: "id", Type: querypb.Type_INT64, Flags: uint32(querypb.MySqlFlag_PRI_KEY_FLAG)},
+		{Name: "name", Type: querypb.Type_VARCHAR},
+		{Name: "data", Type: querypb.Type_VARBINARY},
+		{Name: "price", Type: querypb.Type_DECIMAL},
+	}
+	row := func(id int64, name string) *querypb.Row {
+		return sqltypes.RowToProto3([]sqltypes.Value{
+			sqltypes.NewInt64(id),
+			sqltypes.NewVarChar(name),
+			sqltypes.NewVarBinary("\x01\x02"),
+			sqltypes.NewDecimal("1.50"),
+		})
+	}
+	impl := &fakeVStreamImpl{
+		batches: [][]*binlogdatapb.VEvent{{
+			{Type: binlogdatapb.VEventType_BEGIN},
+			{Type: binlogdatapb.VEventType_FIELD, FieldEvent: &binlogdatapb.FieldEvent{TableName: "ks.product", Fields: fields, Keyspace: "ks", Shard: "-80"}},
+			{Type: binlogdatapb.VEventType_ROW, Timestamp: 1, CurrentTime: 2000000, RowEvent: &binlogdatapb.RowEvent{
+				TableName: "ks.product", Keyspace: "ks", Shard: "-80",
+				RowChanges: []*binlogdatapb.RowChange{{After: row(1, "a")}},
+			}},
+			{Type: binlogdatapb.VEventType_VGTID, Vgtid: debeziumTestVGtid("", &binlogdatapb.TableLastPK{TableName: "product"})},
+			{Type: binlogdatapb.VEventType_COMMIT},
+		}, {
+			{Type: binlogdatapb.VEventType_COPY_COMPLETED, Keyspace: "ks", Shard: "-80"},
+			{Type: binlogdatapb.VEventType_COPY_COMPLETED},
+		}, {
+			{Type: binlogdatapb.VEventType_BEGIN},
+			{Type: binlogdatapb.VEventType_ROW, Timestamp: 3, CurrentTime: 4000000, RowEvent: &binlogdatapb.RowEvent{
+				TableName: "ks.product", Keyspace: "ks", Shard: "-80",
+				RowChanges: []*binlogdatapb.RowChange{
+					{Before: row(1, "a"), After: row(1, "b")},
+					// The primary key changes.
+					{Before: row(1, "b"), After: row(2, "b")},
+					{Before: row(2, "b")},
+				},
+			}},
+			{Type: binlogdatapb.VEventType_VGTID, Vgtid: debeziumTestVGtid("MySQL56/x:1-3")},
+			{Type: binlogdatapb.VEventType_COMMIT},
+		}, {
+			{Type: binlogdatapb.VEventType_VGTID, Vgtid: debeziumTestVGtid("MySQL56/x:1-4")},
+			{Type: binlogdatapb.VEventType_DDL, Timestamp: 5, CurrentTime: 6000000, Keyspace: "ks", Shard: "-80", Statement: "alter table product add column c int"},
+		}},
+	}
+	conn := &VTGateConn{impl: impl}
+
+	var out bytes.Buffer
+	checkpoint := NewDebeziumFileCheckpointer(filepath.Join(t.TempDir(), "checkpoint.json"))
+	err := conn.DebeziumStream(context.Background(), &DebeziumStreamOptions{
+		Name:         "dbserver",
+		VGtid:        debeziumTestVGtid(""),
+		Sink:         NewDebeziumWriterSink(&out),
+		Checkpointer: checkpoint,
+	})
+	require.NoError(t, err)
+
+	want := []string{
+		`{"topic":"dbserver.ks.product","key":{"id":1},"value":{"before":null,"after":{"id":1,"name":"a","data":"AQI=","price":"1.50"},"source":{"connector":"vitess","name":"dbserver","ts_ms":1000,"snapshot":"true","db":"ks","keyspace":"ks","table":"product","shard":"-80","vgtid":"[{\"keyspace\":\"ks\",\"shard\":\"-80\",\"gtid\":\"\"}]"},"op":"r","ts_ms":2}}`,
+		`{"topic":"dbserver.ks.product","key":{"id":1},"value":{"before":{"id":1,"name":"a","data":"AQI=","price":"1.50"},"after":{"id":1,"name":"b","data":"AQI=","price":"1.50"},"source":{"connector":"vitess","name":"dbserver","ts_ms":3000,"snapshot":"false","db":"ks","keyspace":"ks","table":"product","shard":"-80","vgtid":"[{\"keyspace\":\"ks\",\"shard\":\"-80\",\"gtid\":\"\"}]"},"op":"u","ts_ms":4}}`,
+		`{"topic":"dbserver.ks.product","key":{"id":1},"value":{"before":{"id":1,"name":"b","data":"AQI=","price":"1.50"},"after":null,"source":{"connector":"vitess","name":"dbserver","ts_ms":3000,"snapshot":"false","db":"ks","keyspace":"ks","table":"product","shard":"-80","vgtid":"[{\"keyspace\":\"ks\",\"shard\":\"-80\",\"gtid\":\"\"}]"},"op":"d","ts_ms":4}}`,
+		`{"topic":"dbserver.ks.product","key":{"id":1},"value":null}`,
+		`{"topic":"dbserver.ks.product","key":{"id":2},"value":{"before":null,"after":{"id":2,"name":"b","data":"AQI=","price":"1.50"},"source":{"connector":"vitess","name":"dbserver","ts_ms":3000,"snapshot":"false","db":"ks","keyspace":"ks","table":"product","shard":"-80","vgtid":"[{\"keyspace\":\"ks\",\"shard\":\"-80\",\"gtid\":\"\"}]"},"op":"c","ts_ms":4}}`,
+		`{"topic":"dbserver.ks.product","key":{"id":2},"value":{"before":{"id":2,"name":"b","data":"AQI=","price":"1.50"},"after":null,"source":{"connector":"vitess","name":"dbserver","ts_ms":3000,"snapshot":"false","db":"ks","keyspace":"ks","table":"product","shard":"-80","vgtid":"[{\"keyspace\":\"ks\",\"shard\":\"-80\",\"gtid\":\"\"}]"},"op":"d","ts_ms":4}}`,
+		`{"topic":"dbserver.ks.product","key":{"id":2},"value":null}`,
+		`{"topic":"dbserver","key":{"databaseName":"ks"},"value":{"source":{"connector":"vitess","name":"dbserver","ts_ms":5000,"snapshot":"false","db":"ks","keyspace":"ks","shard":"-80","vgtid":"[{\"keyspace\":\"ks\",\"shard\":\"-80\",\"gtid\":\"MySQL56/x:1-4\"}]"},"databaseName":"ks","ddl":"alter table product add column c int","ts_ms":6}}`,
+	}
+	assert.Equal(t, want, strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"))
+
+	// The stream resumes from the checkpoint.
+	saved, err := checkpoint.Load()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(debeziumTestVGtid("MySQL56/x:1-4"), saved), "checkpoint: %v", saved)
+
+	err = conn.DebeziumStream(context.Background(), &DebeziumStreamOptions{
+		Name:         "dbserver",
+		VGtid:        debeziumTestVGtid(""),
+		Sink:         NewDebeziumWriterSink(&out),
+		Checkpointer: checkpoint,
+	})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(saved, impl.vgtid), "vgtid: %v", impl.vgtid)
+}
+
+func TestDebeziumFileSink(t *testing.T) {
+	dir := t.TempDir()
+	record := &DebeziumRecord{Topic: "t", Key: []byte(`{"id":1}`), Value: []byte(`{}`)}
+	line := `{"topic":"t","key":{"id":1},"value":{}}` + "\n"
+
+	sink, err := NewDebeziumFileSink(dir, int64(2*len(line)))
+	require.NoError(t, err)
+	require.NoError(t, sink.Write([]*DebeziumRecord{record}))
+	require.NoError(t, sink.Flush())
+	require.NoError(t, sink.Write([]*DebeziumRecord{record, record}))
+	require.NoError(t, sink.Write([]*DebeziumRecord{record}))
+	require.NoError(t, sink.Close())
+
+	// A new sink continues the sequence of the files.
+	sink, err = NewDebeziumFileSink(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write([]*DebeziumRecord{record}))
+	require.NoError(t, sink.Close())
+
+	for name, want := range map[string]string{
+		"debezium-000001.json": strings.Repeat(line, 3),
+		"debezium-000002.json": line,
+		"debezium-000003.json": line,
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got), name)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 
 	"context"
+	"time"
 
 	"vitess.io/vitess/go/sqltypes"
 
@@ -76,6 +77,10 @@ type QueryService interface {
 	// ReadTransaction returns the metadata for the specified dtid.
 	ReadTransaction(ctx context.Context, target *querypb.Target, dtid string) (metadata *querypb.TransactionMetadata, err error)
 
+	// UnresolvedTransactions returns the 2pc transactions older than
+	// abandonAge which the tablet is the metadata manager of.
+	UnresolvedTransactions(ctx context.Context, target *querypb.Target, abandonAge time.Duration) (transactions []*querypb.TransactionMetadata, err error)
+
 	// Execute for query execution
 	Execute(ctx context.Context, target *querypb.Target, sql string, bindVariables map[string]*querypb.BindVariable, transactionID, reservedID int64, options *querypb.ExecuteOptions) (*sqltypes.Result, error)
 	// StreamExecute for query execution with streaming
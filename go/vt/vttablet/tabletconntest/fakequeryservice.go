@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"vitess.io/vitess/go/vt/vttablet/queryservice"
 
@@ -358,6 +359,24 @@ func (f *FakeQueryService) ReadTransaction(ctx context.Context, target *querypb.
 	return Metadata, nil
 }
 
+// UnresolvedTransactions is part of the queryservice.QueryService interface
+func (f *FakeQueryService) UnresolvedTransactions(ctx context.Context, target *querypb.Target, abandonAge time.Duration) (transactions []*querypb.TransactionMetadata, err error) {
+	if f.HasError {
+		return nil, f.TabletError
+	}
+	if f.Panics {
+		panic(fmt.Errorf("test-triggered panic"))
+	}
+	f.checkTargetCallerID(ctx, "UnresolvedTransactions", target)
+	if abandonAge != AbandonAge {
+		f.t.Errorf("UnresolvedTransactions: invalid abandonAge: got %v expected %v", abandonAge, AbandonAge)
+	}
+	return []*querypb.TransactionMetadata{Metadata}, nil
+}
+
+// AbandonAge is a test abandon age.
+const AbandonAge = 10 * time.Second
+
 // ExecuteQuery is a fake test query.
 const ExecuteQuery = "executeQuery"
 
@@ -270,6 +270,16 @@ func newMigrationSource() *migrationSource {
 	return &migrationSource{shardStreamer: &shardStreamer{}}
 }
 
+// sourceTopoServer returns the topo server of the sources. For
+// Mount+Migrate, the source tablets will be in a different Vitess cluster
+// with its own TopoServer.
+func (ct *controller) sourceTopoServer(ctx context.Context) (*topo.Server, error) {
+	if ct.externalCluster == "" {
+		return ct.ts, nil
+	}
+	return ct.ts.OpenExternalVitessClusterServer(ctx, ct.externalCluster)
+}
+
 func (ct *controller) validate() error {
 	// TODO: check if vreplication workflow has errors, what else?
 	return nil
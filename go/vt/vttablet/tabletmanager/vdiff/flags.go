@@ -0,0 +1,36 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vdiff
+
+import (
+	"github.com/spf13/pflag"
+
+	"vitess.io/vitess/go/vt/servenv"
+)
+
+// checksumChunkSize is the number of rows of the chunks which are
+// checksummed when a VDiff is run with --checksum.
+var checksumChunkSize = 100000
+
+func registerVDiffFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&checksumChunkSize, "vdiff-checksum-chunk-size", checksumChunkSize, "Number of rows of the primary key ranges whose checksums are compared when a VDiff is run with --checksum. Only the rows of the ranges whose checksums differ are streamed and compared.")
+}
+
+func init() {
+	servenv.OnParseFor("vtcombo", registerVDiffFlags)
+	servenv.OnParseFor("vttablet", registerVDiffFlags)
+}
@@ -42,6 +42,13 @@ type DiffReport struct {
 	ExtraRowsSource int64
 	ExtraRowsTarget int64
 
+	// chunk counts, when the table is diffed using checksums
+	ChunksCompared   int64 `json:",omitempty"`
+	MismatchedChunks int64 `json:",omitempty"`
+	// ChecksumFallback is why the table was diffed row by row, when the
+	// diff was asked to use checksums
+	ChecksumFallback string `json:",omitempty"`
+
 	// actual data for a few sample rows
 	ExtraRowsSourceDiffs []*RowDiff      `json:"ExtraRowsSourceSample,omitempty"`
 	ExtraRowsTargetDiffs []*RowDiff      `json:"ExtraRowsTargetSample,omitempty"`
@@ -0,0 +1,645 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vdiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/prototext"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/key"
+	"vitess.io/vitess/go/vt/log"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+// chunkChecksum is the checksum of the rows of a chunk of a table.
+type chunkChecksum struct {
+	rows     int64
+	checksum uint64
+}
+
+// checksumPlan has the queries used to checksum a table chunk by chunk.
+type checksumPlan struct {
+	// sourceTable and targetTable are the tables the checksums are
+	// computed on, on the source and on the target.
+	sourceTable sqlparser.TableName
+	targetTable sqlparser.TableName
+	// columns are the columns which are checksummed, which have the
+	// same names on both sides.
+	columns []string
+	// pkColumns are the primary key columns, which the chunks are
+	// ranges of.
+	pkColumns []string
+	// keyRange is the in_keyrange filter of the source query, if any.
+	// MySQL cannot evaluate it, so the sources return the checksum of
+	// each row of the chunk, and only the rows within the key range are
+	// combined.
+	keyRange *keyRangeFilter
+}
+
+// keyRangeFilter selects the rows whose keyspace id, computed by the vindex
+// from the vindex columns, is within the key range.
+type keyRangeFilter struct {
+	vindex   vindexes.Vindex
+	columns  []string
+	keyRange *topodatapb.KeyRange
+}
+
+// buildChecksumPlan returns the plan used to checksum the table, or an error
+// explaining why the table can only be diffed row by row. A table can be
+// checksummed when its rows are copied as is: the filter must select plain
+// columns from a single table, without any grouping or time zone
+// conversion, and its only WHERE clause can be an in_keyrange.
+func (td *tableDiffer) buildChecksumPlan(ctx context.Context) (*checksumPlan, error) {
+	tp := td.tablePlan
+	if len(tp.table.PrimaryKeyColumns) == 0 {
+		return nil, fmt.Errorf("table has no primary key")
+	}
+	if td.wd.ct.sourceTimeZone != "" {
+		return nil, fmt.Errorf("datetime columns are converted to the source time zone")
+	}
+	statement, err := sqlparser.Parse(tp.sourceQuery)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := statement.(*sqlparser.Select)
+	if !ok {
+		return nil, fmt.Errorf("unexpected: %v", sqlparser.String(statement))
+	}
+	if len(sel.GroupBy) != 0 || sel.Having != nil || len(tp.aggregates) != 0 {
+		return nil, fmt.Errorf("the filter %s does not select the rows as is", td.sourceQuery)
+	}
+	if len(sel.From) != 1 {
+		return nil, fmt.Errorf("the filter %s selects from more than one table", td.sourceQuery)
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, fmt.Errorf("the filter %s does not select from a table", td.sourceQuery)
+	}
+	sourceTable, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok {
+		return nil, fmt.Errorf("the filter %s does not select from a table", td.sourceQuery)
+	}
+	cp := &checksumPlan{
+		sourceTable: sourceTable,
+		targetTable: sqlparser.TableName{
+			Name:      sqlparser.NewIdentifierCS(tp.table.Name),
+			Qualifier: sqlparser.NewIdentifierCS(tp.dbName),
+		},
+		pkColumns: tp.table.PrimaryKeyColumns,
+	}
+	if sel.Where != nil {
+		if cp.keyRange, err = td.buildKeyRangeFilter(ctx, sel.Where.Expr, sourceTable); err != nil {
+			return nil, err
+		}
+	}
+	for _, selExpr := range sel.SelectExprs {
+		expr, ok := selExpr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(selExpr))
+		}
+		col, ok := expr.Expr.(*sqlparser.ColName)
+		if !ok || (!expr.As.IsEmpty() && !expr.As.Equal(col.Name)) {
+			return nil, fmt.Errorf("the filter %s transforms the column %s", td.sourceQuery, sqlparser.String(expr))
+		}
+		cp.columns = append(cp.columns, col.Name.String())
+	}
+	return cp, nil
+}
+
+// buildKeyRangeFilter returns the filter of the in_keyrange of the source
+// query, which is either in_keyrange('-80'), which uses the primary vindex of
+// the table, or in_keyrange(col, 'vindex', '-80'), as in the row streamer.
+// The vindex must not need to look up the keyspace ids.
+func (td *tableDiffer) buildKeyRangeFilter(ctx context.Context, where sqlparser.Expr, sourceTable sqlparser.TableName) (*keyRangeFilter, error) {
+	funcExpr, ok := where.(*sqlparser.FuncExpr)
+	if !ok || !funcExpr.Name.EqualString("in_keyrange") {
+		return nil, fmt.Errorf("the filter %s does not select the rows as is", td.sourceQuery)
+	}
+	exprs := funcExpr.Exprs
+	krf := &keyRangeFilter{}
+	switch {
+	case len(exprs) == 1:
+		ks, err := td.sourceKeyspaceSchema(ctx, td.wd.ct.sourceKeyspace)
+		if err != nil {
+			return nil, err
+		}
+		table := ks.Tables[sourceTable.Name.String()]
+		if table == nil || len(table.ColumnVindexes) == 0 {
+			return nil, fmt.Errorf("table %s has no primary vindex in keyspace %s", sourceTable.Name.String(), td.wd.ct.sourceKeyspace)
+		}
+		krf.vindex = table.ColumnVindexes[0].Vindex
+		for _, col := range table.ColumnVindexes[0].Columns {
+			krf.columns = append(krf.columns, col.String())
+		}
+	case len(exprs) >= 3:
+		for _, expr := range exprs[:len(exprs)-2] {
+			aexpr, ok := expr.(*sqlparser.AliasedExpr)
+			if !ok {
+				return nil, fmt.Errorf("unexpected in_keyrange parameter: %v", sqlparser.String(expr))
+			}
+			col, ok := aexpr.Expr.(*sqlparser.ColName)
+			if !ok || !col.Qualifier.IsEmpty() {
+				return nil, fmt.Errorf("unexpected in_keyrange parameter: %v", sqlparser.String(expr))
+			}
+			krf.columns = append(krf.columns, col.Name.String())
+		}
+		vtype, err := literalString(exprs[len(exprs)-2])
+		if err != nil {
+			return nil, err
+		}
+		if krf.vindex, err = td.findOrCreateVindex(ctx, vtype); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected in_keyrange parameters: %v", sqlparser.String(exprs))
+	}
+	if krf.vindex.NeedsVCursor() {
+		return nil, fmt.Errorf("the vindex of the filter %s needs to look up the keyspace ids", td.sourceQuery)
+	}
+	kr, err := literalString(exprs[len(exprs)-1])
+	if err != nil {
+		return nil, err
+	}
+	keyRanges, err := key.ParseShardingSpec(kr)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyRanges) != 1 {
+		return nil, fmt.Errorf("unexpected in_keyrange parameter: %v", kr)
+	}
+	krf.keyRange = keyRanges[0]
+	return krf, nil
+}
+
+// findOrCreateVindex returns the vindex of an in_keyrange, which is either a
+// vindex of the source keyspace, a vindex of another keyspace qualified by
+// its name, or a vindex type, as in the row streamer.
+func (td *tableDiffer) findOrCreateVindex(ctx context.Context, qualifiedName string) (vindexes.Vindex, error) {
+	keyspace, name := td.wd.ct.sourceKeyspace, qualifiedName
+	if i := strings.IndexByte(qualifiedName, '.'); i >= 0 {
+		keyspace, name = qualifiedName[:i], qualifiedName[i+1:]
+	}
+	ks, err := td.sourceKeyspaceSchema(ctx, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if vindex := ks.Vindexes[name]; vindex != nil {
+		return vindex, nil
+	}
+	if keyspace != td.wd.ct.sourceKeyspace {
+		return nil, fmt.Errorf("vindex %v not found", qualifiedName)
+	}
+	return vindexes.CreateVindex(name, name, map[string]string{})
+}
+
+// sourceKeyspaceSchema returns the vschema of a keyspace of the sources.
+func (td *tableDiffer) sourceKeyspaceSchema(ctx context.Context, keyspace string) (*vindexes.KeyspaceSchema, error) {
+	ts, err := td.wd.ct.sourceTopoServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vschema, err := ts.GetVSchema(ctx, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	return vindexes.BuildKeyspaceSchema(vschema, keyspace)
+}
+
+// checksumDiff diffs the table chunk by chunk. The chunks are ranges of
+// the primary key of at most --vdiff-checksum-chunk-size rows on the target.
+// The rows of each chunk are checksummed on the sources and on the target,
+// and only the chunks whose checksums differ are diffed row by row, at a
+// consistent snapshot. The checksums are computed while the workflow is
+// running, so a chunk with in-flight changes may be diffed row by row even
+// though it matches.
+// The position of the diff is saved after every chunk, so that it resumes
+// from the last chunk compared.
+func (td *tableDiffer) checksumDiff(ctx context.Context, cp *checksumPlan, rowsToCompare int64, debug, onlyPks bool, maxExtraRowsToCompare int64) (*DiffReport, error) {
+	dbClient := td.wd.ct.dbClientFactory()
+	if err := dbClient.Connect(); err != nil {
+		return nil, err
+	}
+	defer dbClient.Close()
+
+	_, dr, err := td.getTableState(dbClient)
+	if err != nil {
+		return nil, err
+	}
+	if err := td.selectTablets(ctx, td.wd.opts.PickerOptions.SourceCell, td.wd.opts.PickerOptions.TabletTypes); err != nil {
+		return nil, err
+	}
+
+	// The chunks which differ are diffed with queries bounded to the chunk,
+	// starting after the previous chunk.
+	sourceQuery, targetQuery, lastPK := td.tablePlan.sourceQuery, td.tablePlan.targetQuery, td.lastPK
+	defer func() {
+		td.tablePlan.sourceQuery, td.tablePlan.targetQuery, td.lastPK = sourceQuery, targetQuery, lastPK
+	}()
+
+	start := td.lastPK
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, vterrors.Errorf(vtrpcpb.Code_CANCELED, "context has expired")
+		default:
+		}
+		if rowsToCompare <= 0 {
+			log.Infof("Stopping vdiff, specified limit reached")
+			return dr, nil
+		}
+
+		end, err := td.nextChunkEnd(dbClient, cp, start)
+		if err != nil {
+			return nil, err
+		}
+		sourceChecksum, err := td.sourceChecksum(ctx, cp, start, end)
+		if err != nil {
+			return nil, err
+		}
+		targetChecksum, err := td.targetChecksum(dbClient, cp, start, end)
+		if err != nil {
+			return nil, err
+		}
+		dr.ChunksCompared++
+		if *sourceChecksum == *targetChecksum {
+			dr.ProcessedRows += targetChecksum.rows
+			dr.MatchingRows += targetChecksum.rows
+			rowsToCompare -= targetChecksum.rows
+		} else {
+			log.Infof("Checksum mismatch for a chunk of table %s for vdiff %s, diffing its rows", td.table.Name, td.wd.ct.uuid)
+			dr.MismatchedChunks++
+			// The row diff reads its report back from the table.
+			if err := td.updateChunkProgress(dbClient, dr, start); err != nil {
+				return nil, err
+			}
+			if td.tablePlan.sourceQuery, err = chunkQuery(sourceQuery, cp, end); err != nil {
+				return nil, err
+			}
+			if td.tablePlan.targetQuery, err = chunkQuery(targetQuery, cp, end); err != nil {
+				return nil, err
+			}
+			td.lastPK = start
+			if err := td.initialize(ctx); err != nil {
+				return nil, err
+			}
+			processedRows := dr.ProcessedRows
+			if dr, err = td.diff(ctx, rowsToCompare, debug, onlyPks, maxExtraRowsToCompare); err != nil {
+				return nil, err
+			}
+			if dr.ProcessedRows-processedRows >= rowsToCompare {
+				// The limit was reached within the chunk, and the diff
+				// saved its position.
+				return dr, nil
+			}
+			rowsToCompare -= dr.ProcessedRows - processedRows
+		}
+		if end == nil {
+			return dr, nil
+		}
+		if err := td.updateChunkProgress(dbClient, dr, end); err != nil {
+			return nil, err
+		}
+		start = end
+	}
+}
+
+// nextChunkEnd returns the primary key of the last row of the chunk which
+// starts after start, or nil if the chunk is the last one of the table.
+func (td *tableDiffer) nextChunkEnd(dbClient binlogplayer.DBClient, cp *checksumPlan, start *querypb.QueryResult) (*querypb.QueryResult, error) {
+	buf := sqlparser.NewTrackedBuffer(nil)
+	buf.Myprintf("select ")
+	writeColumnList(buf, cp.pkColumns)
+	buf.Myprintf(" from %v", cp.targetTable)
+	if start != nil {
+		buf.Myprintf(" where ")
+		writePKRange(buf, cp.pkColumns, start, true)
+	}
+	buf.Myprintf(" order by ")
+	writeColumnList(buf, cp.pkColumns)
+	buf.Myprintf(" limit %d, 1", checksumChunkSize-1)
+	qr, err := dbClient.ExecuteFetch(buf.String(), 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Rows) == 0 {
+		return nil, nil
+	}
+	end := &querypb.QueryResult{
+		Rows: []*querypb.Row{sqltypes.RowToProto3(qr.Rows[0])},
+	}
+	for _, field := range qr.Fields {
+		end.Fields = append(end.Fields, &querypb.Field{Name: field.Name, Type: field.Type})
+	}
+	return end, nil
+}
+
+// sourceChecksum returns the checksum of the rows of the chunk on all the
+// sources. The checksums of the sources are combined, as the rows of a
+// chunk can be spread over several source shards.
+func (td *tableDiffer) sourceChecksum(ctx context.Context, cp *checksumPlan, start, end *querypb.QueryResult) (*chunkChecksum, error) {
+	query := chunkChecksumQuery(cp, cp.sourceTable, start, end)
+	var mu sync.Mutex
+	total := &chunkChecksum{}
+	if err := td.forEachSource(func(source *migrationSource) error {
+		var cs *chunkChecksum
+		if cp.keyRange != nil {
+			var err error
+			if cs, err = td.sourceKeyRangeChecksum(ctx, source, cp, start, end); err != nil {
+				return err
+			}
+		} else {
+			qr, err := td.wd.ct.tmc.ExecuteFetchAsApp(ctx, source.tablet, true, &tabletmanagerdatapb.ExecuteFetchAsAppRequest{
+				Query:   []byte(query),
+				MaxRows: 1,
+			})
+			if err != nil {
+				return vterrors.Wrapf(err, "checksum on tablet %v", source.tablet.Alias)
+			}
+			if cs, err = parseChunkChecksum(sqltypes.Proto3ToResult(qr)); err != nil {
+				return err
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		total.rows += cs.rows
+		total.checksum ^= cs.checksum
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+// sourceKeyRangeChecksum returns the checksum of the rows of the chunk on a
+// source which are within the key range of the plan. The checksums of the
+// rows of the chunk are read in pages of --vdiff-checksum-chunk-size rows,
+// as the source can have more rows than the target in the chunk.
+func (td *tableDiffer) sourceKeyRangeChecksum(ctx context.Context, source *migrationSource, cp *checksumPlan, start, end *querypb.QueryResult) (*chunkChecksum, error) {
+	cs := &chunkChecksum{}
+	for from := start; ; {
+		qr, err := td.wd.ct.tmc.ExecuteFetchAsApp(ctx, source.tablet, true, &tabletmanagerdatapb.ExecuteFetchAsAppRequest{
+			Query:   []byte(keyRangeChecksumQuery(cp, from, end)),
+			MaxRows: uint64(checksumChunkSize),
+		})
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "checksum on tablet %v", source.tablet.Alias)
+		}
+		result := sqltypes.Proto3ToResult(qr)
+		if err := cp.keyRange.add(ctx, cs, result.Rows); err != nil {
+			return nil, err
+		}
+		if len(result.Rows) < checksumChunkSize {
+			return cs, nil
+		}
+		// The primary key of the last row, which the next page starts
+		// after, is at the end of the rows.
+		pkStart := len(result.Fields) - len(cp.pkColumns)
+		from = sqltypes.ResultToProto3(&sqltypes.Result{
+			Fields: result.Fields[pkStart:],
+			Rows:   [][]sqltypes.Value{result.Rows[len(result.Rows)-1][pkStart:]},
+		})
+	}
+}
+
+// add adds the rows whose keyspace id is within the key range to the
+// checksum. The rows start with the vindex columns, followed by the checksum
+// of the row.
+func (krf *keyRangeFilter) add(ctx context.Context, cs *chunkChecksum, rows [][]sqltypes.Value) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	ids := make([][]sqltypes.Value, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row[:len(krf.columns)])
+	}
+	destinations, err := vindexes.Map(ctx, krf.vindex, nil, ids)
+	if err != nil {
+		return err
+	}
+	for i, dest := range destinations {
+		ksid, ok := dest.(key.DestinationKeyspaceID)
+		if !ok || len(ksid) == 0 {
+			return fmt.Errorf("could not map %v to a keyspace id, got destination %v", ids[i], dest)
+		}
+		if !key.KeyRangeContains(krf.keyRange, ksid) {
+			continue
+		}
+		checksum, err := rows[i][len(krf.columns)].ToUint64()
+		if err != nil {
+			return err
+		}
+		cs.rows++
+		cs.checksum ^= checksum
+	}
+	return nil
+}
+
+// targetChecksum returns the checksum of the rows of the chunk on the target.
+func (td *tableDiffer) targetChecksum(dbClient binlogplayer.DBClient, cp *checksumPlan, start, end *querypb.QueryResult) (*chunkChecksum, error) {
+	qr, err := dbClient.ExecuteFetch(chunkChecksumQuery(cp, cp.targetTable, start, end), 1)
+	if err != nil {
+		return nil, err
+	}
+	return parseChunkChecksum(qr)
+}
+
+// updateChunkProgress saves the report and the position of the diff, which
+// is the primary key of the last row of the last chunk compared.
+func (td *tableDiffer) updateChunkProgress(dbClient binlogplayer.DBClient, dr *DiffReport, lastPK *querypb.QueryResult) error {
+	rpt, err := json.Marshal(dr)
+	if err != nil {
+		return err
+	}
+	var query string
+	if lastPK != nil {
+		buf, err := prototext.Marshal(lastPK)
+		if err != nil {
+			return err
+		}
+		query = fmt.Sprintf(sqlUpdateTableProgress, dr.ProcessedRows, encodeString(string(buf)), encodeString(string(rpt)), td.wd.ct.id, encodeString(td.table.Name))
+	} else {
+		query = fmt.Sprintf(sqlUpdateTableNoProgress, dr.ProcessedRows, encodeString(string(rpt)), td.wd.ct.id, encodeString(td.table.Name))
+	}
+	if _, err := dbClient.ExecuteFetch(query, 1); err != nil {
+		return err
+	}
+	return nil
+}
+
+// chunkChecksumQuery returns the query computing the checksum of the rows of
+// a chunk. The checksum is the BIT_XOR of the CRC32 of the rows, which does
+// not depend on their order and can be combined across shards.
+func chunkChecksumQuery(cp *checksumPlan, table sqlparser.TableName, start, end *querypb.QueryResult) string {
+	buf := sqlparser.NewTrackedBuffer(nil)
+	buf.Myprintf("select count(*) as row_count, bit_xor(")
+	writeRowChecksum(buf, cp)
+	buf.Myprintf(") as checksum from %v", table)
+	writeChunkRange(buf, cp, start, end)
+	return buf.String()
+}
+
+// keyRangeChecksumQuery returns the query reading, in primary key order, the
+// checksums of at most --vdiff-checksum-chunk-size rows of the chunk after
+// from, with the vindex columns of the key range filter and the primary key.
+func keyRangeChecksumQuery(cp *checksumPlan, from, end *querypb.QueryResult) string {
+	buf := sqlparser.NewTrackedBuffer(nil)
+	buf.Myprintf("select ")
+	writeColumnList(buf, cp.keyRange.columns)
+	buf.Myprintf(", ")
+	writeRowChecksum(buf, cp)
+	buf.Myprintf(" as checksum, ")
+	writeColumnList(buf, cp.pkColumns)
+	buf.Myprintf(" from %v", cp.sourceTable)
+	writeChunkRange(buf, cp, from, end)
+	buf.Myprintf(" order by ")
+	writeColumnList(buf, cp.pkColumns)
+	buf.Myprintf(" limit %d", checksumChunkSize)
+	return buf.String()
+}
+
+// writeRowChecksum writes the CRC32 of a row. NULL values are told apart
+// from empty ones by the trailing ISNULL flags.
+func writeRowChecksum(buf *sqlparser.TrackedBuffer, cp *checksumPlan) {
+	buf.Myprintf("crc32(concat_ws('#', ")
+	writeColumnList(buf, cp.columns)
+	buf.Myprintf(", concat(")
+	for i, col := range cp.columns {
+		if i > 0 {
+			buf.Myprintf(", ")
+		}
+		buf.Myprintf("isnull(%v)", sqlparser.NewIdentifierCI(col))
+	}
+	buf.Myprintf(")))")
+}
+
+// writeChunkRange writes the condition selecting the rows after start and up
+// to and including end, where a nil bound does not limit the range.
+func writeChunkRange(buf *sqlparser.TrackedBuffer, cp *checksumPlan, start, end *querypb.QueryResult) {
+	prefix := " where "
+	if start != nil {
+		buf.Myprintf("%s(", prefix)
+		writePKRange(buf, cp.pkColumns, start, true)
+		buf.Myprintf(")")
+		prefix = " and "
+	}
+	if end != nil {
+		buf.Myprintf("%s(", prefix)
+		writePKRange(buf, cp.pkColumns, end, false)
+		buf.Myprintf(")")
+	}
+}
+
+// chunkQuery bounds the query to the rows up to the end of the chunk. The
+// start of the chunk is applied by the row streamer, as the last pk.
+func chunkQuery(query string, cp *checksumPlan, end *querypb.QueryResult) (string, error) {
+	if end == nil {
+		return query, nil
+	}
+	statement, err := sqlparser.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	sel, ok := statement.(*sqlparser.Select)
+	if !ok {
+		return "", fmt.Errorf("unexpected: %v", sqlparser.String(statement))
+	}
+	buf := sqlparser.NewTrackedBuffer(nil)
+	writePKRange(buf, cp.pkColumns, end, false)
+	expr, err := sqlparser.ParseExpr(buf.String())
+	if err != nil {
+		return "", err
+	}
+	sel.AddWhere(expr)
+	return sqlparser.String(sel), nil
+}
+
+// writePKRange writes the condition selecting the rows after the primary
+// key if after is set, and the rows up to and including it otherwise. As in
+// the row streamer, composite keys are expanded into (c1 = 1 and c2 > 2) or
+// (c1 > 1), because MySQL does not use the index for a tuple inequality.
+func writePKRange(buf *sqlparser.TrackedBuffer, pkColumns []string, pk *querypb.QueryResult, after bool) {
+	vals := sqltypes.Proto3ToResult(pk).Rows[0]
+	prefix := ""
+	for lastcol := len(pkColumns) - 1; lastcol >= 0; lastcol-- {
+		buf.Myprintf("%s(", prefix)
+		prefix = " or "
+		for i, col := range pkColumns[:lastcol] {
+			buf.Myprintf("%v = ", sqlparser.NewIdentifierCI(col))
+			vals[i].EncodeSQL(buf)
+			buf.Myprintf(" and ")
+		}
+		op := ">"
+		switch {
+		case !after && lastcol == len(pkColumns)-1:
+			op = "<="
+		case !after:
+			op = "<"
+		}
+		buf.Myprintf("%v %s ", sqlparser.NewIdentifierCI(pkColumns[lastcol]), op)
+		vals[lastcol].EncodeSQL(buf)
+		buf.Myprintf(")")
+	}
+}
+
+func writeColumnList(buf *sqlparser.TrackedBuffer, columns []string) {
+	for i, col := range columns {
+		if i > 0 {
+			buf.Myprintf(", ")
+		}
+		buf.Myprintf("%v", sqlparser.NewIdentifierCI(col))
+	}
+}
+
+func literalString(expr sqlparser.SelectExpr) (string, error) {
+	aexpr, ok := expr.(*sqlparser.AliasedExpr)
+	if !ok {
+		return "", fmt.Errorf("unsupported: %v", sqlparser.String(expr))
+	}
+	val, ok := aexpr.Expr.(*sqlparser.Literal)
+	if !ok || val.Type != sqlparser.StrVal {
+		return "", fmt.Errorf("unsupported: %v", sqlparser.String(expr))
+	}
+	return val.Val, nil
+}
+
+func parseChunkChecksum(qr *sqltypes.Result) (*chunkChecksum, error) {
+	if qr == nil || len(qr.Rows) != 1 || len(qr.Rows[0]) != 2 {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected checksum result: %+v", qr)
+	}
+	rows, err := qr.Rows[0][0].ToInt64()
+	if err != nil {
+		return nil, err
+	}
+	checksum, err := qr.Rows[0][1].ToUint64()
+	if err != nil {
+		return nil, err
+	}
+	return &chunkChecksum{rows: rows, checksum: checksum}, nil
+}
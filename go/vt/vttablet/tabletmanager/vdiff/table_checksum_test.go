@@ -0,0 +1,224 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vdiff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/key"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+	"vitess.io/vitess/go/vt/vtgate/engine"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+)
+
+func TestBuildChecksumPlan(t *testing.T) {
+	ctx := context.Background()
+	table := &tabletmanagerdatapb.TableDefinition{
+		Name:              "t1",
+		Columns:           []string{"c1", "c2", "c3"},
+		PrimaryKeyColumns: []string{"c1", "c2"},
+	}
+	ts := memorytopo.NewServer("cell1")
+	require.NoError(t, ts.CreateKeyspace(ctx, "sourceks", &topodatapb.Keyspace{}))
+	require.NoError(t, ts.SaveVSchema(ctx, "sourceks", &vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash": {Type: "hash"},
+			"lkp": {
+				Type:   "lookup_unique",
+				Params: map[string]string{"table": "lkp", "from": "c2", "to": "keyspace_id"},
+			},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"t1": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "c1", Name: "hash"}}},
+		},
+	}))
+	hash, err := vindexes.CreateVindex("hash", "hash", nil)
+	require.NoError(t, err)
+	keyRange := func(spec string) *topodatapb.KeyRange {
+		kr, err := key.ParseShardingSpec(spec)
+		require.NoError(t, err)
+		return kr[0]
+	}
+	testcases := []struct {
+		sourceQuery    string
+		aggregates     []*engine.AggregateParams
+		sourceTimeZone string
+		want           *checksumPlan
+		wantErr        string
+	}{{
+		sourceQuery: "select c1, c2, c3 from t1 order by c1 asc, c2 asc",
+		want: &checksumPlan{
+			sourceTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1")},
+			targetTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1"), Qualifier: sqlparser.NewIdentifierCS(vdiffDBName)},
+			columns:     []string{"c1", "c2", "c3"},
+			pkColumns:   []string{"c1", "c2"},
+		},
+	}, {
+		sourceQuery: "select c1, c2, c3 as c3 from src order by c1 asc, c2 asc",
+		want: &checksumPlan{
+			sourceTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("src")},
+			targetTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1"), Qualifier: sqlparser.NewIdentifierCS(vdiffDBName)},
+			columns:     []string{"c1", "c2", "c3"},
+			pkColumns:   []string{"c1", "c2"},
+		},
+	}, {
+		sourceQuery: "select c1, c2, c3 from t1 where in_keyrange('-80') order by c1 asc, c2 asc",
+		want: &checksumPlan{
+			sourceTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1")},
+			targetTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1"), Qualifier: sqlparser.NewIdentifierCS(vdiffDBName)},
+			columns:     []string{"c1", "c2", "c3"},
+			pkColumns:   []string{"c1", "c2"},
+			keyRange:    &keyRangeFilter{vindex: hash, columns: []string{"c1"}, keyRange: keyRange("-80")},
+		},
+	}, {
+		sourceQuery: "select c1, c2, c3 from t1 where in_keyrange(c2, 'hash', '80-') order by c1 asc, c2 asc",
+		want: &checksumPlan{
+			sourceTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1")},
+			targetTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1"), Qualifier: sqlparser.NewIdentifierCS(vdiffDBName)},
+			columns:     []string{"c1", "c2", "c3"},
+			pkColumns:   []string{"c1", "c2"},
+			keyRange:    &keyRangeFilter{vindex: hash, columns: []string{"c2"}, keyRange: keyRange("80-")},
+		},
+	}, {
+		sourceQuery: "select c1, c2, c3 from t1 where in_keyrange(c2, 'lkp', '80-') order by c1 asc, c2 asc",
+		wantErr:     "needs to look up the keyspace ids",
+	}, {
+		sourceQuery: "select c1, c2, c3 from t1 where c1 = 1 order by c1 asc, c2 asc",
+		wantErr:     "does not select the rows as is",
+	}, {
+		sourceQuery: "select c1, c2, c3 from t1 where in_keyrange('-80') and c1 = 1 order by c1 asc, c2 asc",
+		wantErr:     "does not select the rows as is",
+	}, {
+		sourceQuery: "select c1, c2, c3 + 1 as c3 from t1 order by c1 asc, c2 asc",
+		wantErr:     "transforms the column",
+	}, {
+		sourceQuery: "select c1, c2, c4 as c3 from t1 order by c1 asc, c2 asc",
+		wantErr:     "transforms the column",
+	}, {
+		sourceQuery: "select c1, c2, count(*) as c3 from t1 group by c1, c2 order by c1 asc, c2 asc",
+		aggregates:  []*engine.AggregateParams{{Col: 2}},
+		wantErr:     "does not select the rows as is",
+	}, {
+		sourceQuery:    "select c1, c2, c3 from t1 order by c1 asc, c2 asc",
+		sourceTimeZone: "US/Pacific",
+		wantErr:        "source time zone",
+	}}
+	for _, tcase := range testcases {
+		t.Run(tcase.sourceQuery, func(t *testing.T) {
+			td := &tableDiffer{
+				wd:          &workflowDiffer{ct: &controller{ts: ts, sourceKeyspace: "sourceks", sourceTimeZone: tcase.sourceTimeZone}},
+				sourceQuery: tcase.sourceQuery,
+				tablePlan: &tablePlan{
+					sourceQuery: tcase.sourceQuery,
+					dbName:      vdiffDBName,
+					table:       table,
+					aggregates:  tcase.aggregates,
+				},
+			}
+			cp, err := td.buildChecksumPlan(ctx)
+			if tcase.wantErr != "" {
+				require.ErrorContains(t, err, tcase.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tcase.want, cp)
+		})
+	}
+}
+
+func TestChunkQueries(t *testing.T) {
+	cp := &checksumPlan{
+		sourceTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1")},
+		targetTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1"), Qualifier: sqlparser.NewIdentifierCS(vdiffDBName)},
+		columns:     []string{"c1", "c2", "c3"},
+		pkColumns:   []string{"c1", "c2"},
+	}
+	pk := func(c1 int64, c2 string) *querypb.QueryResult {
+		return sqltypes.ResultToProto3(sqltypes.MakeTestResult(sqltypes.MakeTestFields("c1|c2", "int64|varchar"),
+			sqltypes.NewInt64(c1).ToString()+"|"+c2))
+	}
+	start, end := pk(1, "a"), pk(5, "b")
+
+	assert.Equal(t,
+		"select count(*) as row_count, bit_xor(crc32(concat_ws('#', c1, c2, c3, concat(isnull(c1), isnull(c2), isnull(c3))))) as checksum from t1",
+		chunkChecksumQuery(cp, cp.sourceTable, nil, nil))
+	assert.Equal(t,
+		"select count(*) as row_count, bit_xor(crc32(concat_ws('#', c1, c2, c3, concat(isnull(c1), isnull(c2), isnull(c3))))) as checksum from t1"+
+			" where ((c1 = 1 and c2 > 'a') or (c1 > 1)) and ((c1 = 5 and c2 <= 'b') or (c1 < 5))",
+		chunkChecksumQuery(cp, cp.sourceTable, start, end))
+	assert.Equal(t,
+		"select count(*) as row_count, bit_xor(crc32(concat_ws('#', c1, c2, c3, concat(isnull(c1), isnull(c2), isnull(c3))))) as checksum from vttest.t1"+
+			" where ((c1 = 1 and c2 > 'a') or (c1 > 1))",
+		chunkChecksumQuery(cp, cp.targetTable, start, nil))
+
+	query, err := chunkQuery("select c1, c2, c3 from t1 order by c1 asc, c2 asc", cp, end)
+	require.NoError(t, err)
+	assert.Equal(t, "select c1, c2, c3 from t1 where c1 = 5 and c2 <= 'b' or c1 < 5 order by c1 asc, c2 asc", query)
+	query, err = chunkQuery("select c1, c2, c3 from t1 order by c1 asc, c2 asc", cp, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "select c1, c2, c3 from t1 order by c1 asc, c2 asc", query)
+}
+
+func TestKeyRangeChecksum(t *testing.T) {
+	hash, err := vindexes.CreateVindex("hash", "hash", nil)
+	require.NoError(t, err)
+	kr, err := key.ParseShardingSpec("-80")
+	require.NoError(t, err)
+	cp := &checksumPlan{
+		sourceTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1")},
+		targetTable: sqlparser.TableName{Name: sqlparser.NewIdentifierCS("t1"), Qualifier: sqlparser.NewIdentifierCS(vdiffDBName)},
+		columns:     []string{"c1", "c2", "c3"},
+		pkColumns:   []string{"c1", "c2"},
+		keyRange:    &keyRangeFilter{vindex: hash, columns: []string{"c1"}, keyRange: kr[0]},
+	}
+	start := sqltypes.ResultToProto3(sqltypes.MakeTestResult(sqltypes.MakeTestFields("c1|c2", "int64|varchar"), "1|a"))
+
+	assert.Equal(t,
+		"select c1, crc32(concat_ws('#', c1, c2, c3, concat(isnull(c1), isnull(c2), isnull(c3)))) as checksum, c1, c2 from t1"+
+			" where ((c1 = 1 and c2 > 'a') or (c1 > 1)) order by c1, c2 limit 100000",
+		keyRangeChecksumQuery(cp, start, nil))
+
+	// The rows of the chunk on the source are read with their vindex
+	// column, and only the ones which map to -80 are checksummed: the
+	// keyspace ids of 1 and 4 are in 80-, and the ones of 2 and 3 in -80.
+	rows := sqltypes.MakeTestResult(sqltypes.MakeTestFields("c1|checksum|c1|c2", "int64|uint64|int64|varchar"),
+		"1|11|1|a",
+		"2|22|2|b",
+		"3|33|3|c",
+		"4|44|4|d",
+	).Rows
+	cs := &chunkChecksum{}
+	require.NoError(t, cp.keyRange.add(context.Background(), cs, rows))
+	assert.Equal(t, &chunkChecksum{rows: 2, checksum: 22 ^ 33}, cs)
+
+	// The rows of a differing chunk are streamed with the key range.
+	end := sqltypes.ResultToProto3(sqltypes.MakeTestResult(sqltypes.MakeTestFields("c1|c2", "int64|varchar"), "5|b"))
+	query, err := chunkQuery("select c1, c2, c3 from t1 where in_keyrange('-80') order by c1 asc, c2 asc", cp, end)
+	require.NoError(t, err)
+	assert.Equal(t, "select c1, c2, c3 from t1 where in_keyrange('-80') and (c1 = 5 and c2 <= 'b' or c1 < 5) order by c1 asc, c2 asc", query)
+}
@@ -204,15 +204,9 @@ func (td *tableDiffer) selectTablets(ctx context.Context, cell, tabletTypes stri
 	ct := td.wd.ct
 	var err1, err2 error
 
-	// For Mount+Migrate, the source tablets will be in a different
-	// Vitess cluster with its own TopoServer.
-	sourceTopoServer := ct.ts
-	if ct.externalCluster != "" {
-		extTS, err := ct.ts.OpenExternalVitessClusterServer(ctx, ct.externalCluster)
-		if err != nil {
-			return err
-		}
-		sourceTopoServer = extTS
+	sourceTopoServer, err := ct.sourceTopoServer(ctx)
+	if err != nil {
+		return err
 	}
 	wg.Add(1)
 	go func() {
@@ -442,27 +436,10 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 	// We need to continue were we left off when appropriate. This can be an
 	// auto-retry on error, or a manual retry via the resume command.
 	// Otherwise the existing state will be empty and we start from scratch.
-	query := fmt.Sprintf(sqlGetVDiffTable, td.wd.ct.id, encodeString(td.table.Name))
-	cs, err := dbClient.ExecuteFetch(query, -1)
+	mismatch, dr, err := td.getTableState(dbClient)
 	if err != nil {
 		return nil, err
 	}
-	if len(cs.Rows) == 0 {
-		return nil, fmt.Errorf("no state found for vdiff table %s for vdiff_id %d on tablet %v",
-			td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
-	} else if len(cs.Rows) > 1 {
-		return nil, fmt.Errorf("invalid state found for vdiff table %s (multiple records) for vdiff_id %d on tablet %v",
-			td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
-	}
-	curState := cs.Named().Row()
-	mismatch := curState.AsBool("mismatch", false)
-	dr := &DiffReport{}
-	if rpt := curState.AsBytes("report", []byte("{}")); json.Valid(rpt) {
-		if err = json.Unmarshal(rpt, dr); err != nil {
-			return nil, err
-		}
-	}
-	dr.TableName = td.table.Name
 
 	sourceExecutor := newPrimitiveExecutor(ctx, td.sourcePrimitive, "source")
 	targetExecutor := newPrimitiveExecutor(ctx, td.targetPrimitive, "target")
@@ -618,6 +595,33 @@ func (td *tableDiffer) diff(ctx context.Context, rowsToCompare int64, debug, onl
 	}
 }
 
+// getTableState returns the mismatch flag and the diff report saved for the
+// table by a previous run, if any.
+func (td *tableDiffer) getTableState(dbClient binlogplayer.DBClient) (bool, *DiffReport, error) {
+	query := fmt.Sprintf(sqlGetVDiffTable, td.wd.ct.id, encodeString(td.table.Name))
+	cs, err := dbClient.ExecuteFetch(query, -1)
+	if err != nil {
+		return false, nil, err
+	}
+	if len(cs.Rows) == 0 {
+		return false, nil, fmt.Errorf("no state found for vdiff table %s for vdiff_id %d on tablet %v",
+			td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
+	} else if len(cs.Rows) > 1 {
+		return false, nil, fmt.Errorf("invalid state found for vdiff table %s (multiple records) for vdiff_id %d on tablet %v",
+			td.table.Name, td.wd.ct.id, td.wd.ct.vde.thisTablet.Alias)
+	}
+	curState := cs.Named().Row()
+	mismatch := curState.AsBool("mismatch", false)
+	dr := &DiffReport{}
+	if rpt := curState.AsBytes("report", []byte("{}")); json.Valid(rpt) {
+		if err = json.Unmarshal(rpt, dr); err != nil {
+			return false, nil, err
+		}
+	}
+	dr.TableName = td.table.Name
+	return mismatch, dr, nil
+}
+
 func (td *tableDiffer) compare(sourceRow, targetRow []sqltypes.Value, cols []compareColInfo, compareOnlyNonPKs bool) (int, error) {
 	for _, col := range cols {
 		if col.isPK && compareOnlyNonPKs {
@@ -0,0 +1,461 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"vitess.io/vitess/go/sqlescape"
+	"vitess.io/vitess/go/vt/schemadiff"
+	"vitess.io/vitess/go/vt/sqlparser"
+
+	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
+)
+
+// The TRANSLATE on_ddl mode applies the DDLs of the source tables to the
+// target tables of a workflow. The filter of the rule of a target table maps
+// the source columns to the target columns: a "select *" filter maps every
+// column to the target column of the same name, and an explicit projection
+// maps the columns it selects as is to their alias. A DDL is translated
+// through that mapping, validated against the current definition of the
+// target table with schemadiff, and skipped if it does not change it. A DDL
+// that would break the filter, such as dropping a column it uses in an
+// expression, stops the workflow with the list of the reasons why.
+
+// ddlTarget is a target table of a source table changed by a DDL.
+type ddlTarget struct {
+	// name is the name of the target table.
+	name string
+	// query is the filter of the rule of the target table.
+	query string
+	// createTable is the current definition of the target table.
+	createTable string
+}
+
+// ddlIncompatibleError lists why a DDL of a source table cannot be applied
+// to its target tables.
+type ddlIncompatibleError struct {
+	reasons []string
+}
+
+func (e *ddlIncompatibleError) Error() string {
+	return strings.Join(e.reasons, "; ")
+}
+
+func (e *ddlIncompatibleError) add(table, format string, args ...any) {
+	e.reasons = append(e.reasons, fmt.Sprintf("table %s: ", table)+fmt.Sprintf(format, args...))
+}
+
+// ddlColumnMapping maps the columns of a source table to those of a target
+// table.
+type ddlColumnMapping struct {
+	// star is set for a "select *" filter.
+	star bool
+	// filtered is set if the filter has a where clause.
+	filtered bool
+	// columns maps the lowered name of the source columns selected as is to
+	// the name of their target column.
+	columns map[string]sqlparser.IdentifierCI
+	// references maps the lowered name of the source columns the filter
+	// otherwise uses to where it uses them.
+	references map[string]string
+}
+
+func newDDLColumnMapping(query string) (*ddlColumnMapping, error) {
+	sel, _, err := analyzeSelectFrom(query)
+	if err != nil {
+		return nil, err
+	}
+	m := &ddlColumnMapping{
+		filtered:   sel.Where != nil,
+		columns:    make(map[string]sqlparser.IdentifierCI),
+		references: make(map[string]string),
+	}
+	addReferences := func(node sqlparser.SQLNode, where string) {
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+			if col, ok := node.(*sqlparser.ColName); ok {
+				if _, ok := m.references[col.Name.Lowered()]; !ok {
+					m.references[col.Name.Lowered()] = where
+				}
+			}
+			return true, nil
+		}, node)
+	}
+	for _, expr := range sel.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			m.star = true
+		case *sqlparser.AliasedExpr:
+			if col, ok := expr.Expr.(*sqlparser.ColName); ok {
+				target := col.Name
+				if !expr.As.IsEmpty() {
+					target = expr.As
+				}
+				m.columns[col.Name.Lowered()] = target
+				continue
+			}
+			addReferences(expr.Expr, fmt.Sprintf("expression %s", sqlparser.String(expr)))
+		}
+	}
+	if sel.Where != nil {
+		addReferences(sel.Where.Expr, "where clause")
+	}
+	for _, expr := range sel.GroupBy {
+		addReferences(expr, "group by clause")
+	}
+	return m, nil
+}
+
+// target returns the target column of a source column, if the source column
+// is copied as is.
+func (m *ddlColumnMapping) target(col sqlparser.IdentifierCI) (sqlparser.IdentifierCI, bool) {
+	if m.star {
+		return col, true
+	}
+	target, ok := m.columns[col.Lowered()]
+	return target, ok
+}
+
+// translateDDL returns the statements that apply a DDL of the source to the
+// target tables of the workflow.
+func (vp *vplayer) translateDDL(ctx context.Context, ddl string) ([]string, error) {
+	stmt, err := sqlparser.Parse(ddl)
+	if err != nil {
+		return nil, &ddlIncompatibleError{reasons: []string{fmt.Sprintf("cannot parse the DDL: %v", err)}}
+	}
+	var targets []*ddlTarget
+	for _, sourceTable := range ddlSourceTables(stmt) {
+		for targetName, tablePlan := range vp.replicatorPlan.TargetTables {
+			if tablePlan.SendRule.Match != sourceTable {
+				continue
+			}
+			rule, err := MatchTable(targetName, vp.vr.source.Filter)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, &ddlTarget{name: targetName, query: filterQuery(targetName, rule.Filter)})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].name < targets[j].name
+	})
+
+	tables := make([]string, 0, len(targets))
+	for _, target := range targets {
+		tables = append(tables, target.name)
+	}
+	schema, err := vp.vr.mysqld.GetSchema(ctx, vp.vr.dbClient.DBName(), &tabletmanagerdatapb.GetSchemaRequest{Tables: tables})
+	if err != nil {
+		return nil, err
+	}
+	createTables := make(map[string]string)
+	for _, td := range schema.TableDefinitions {
+		createTables[td.Name] = td.Schema
+	}
+	for _, target := range targets {
+		createTable, ok := createTables[target.name]
+		if !ok {
+			return nil, fmt.Errorf("table %s not found in schema", target.name)
+		}
+		target.createTable = createTable
+	}
+	return translateDDL(stmt, targets)
+}
+
+// reloadTargetTables rebuilds the plans of the target tables once their
+// definition changed. The plans of their source tables are rebuilt from the
+// field events the source sends after the DDL.
+func (vp *vplayer) reloadTargetTables(ctx context.Context) error {
+	colInfoMap, err := vp.vr.buildColInfoMap(ctx)
+	if err != nil {
+		return err
+	}
+	vp.vr.colInfoMap = colInfoMap
+	plan, err := buildReplicatorPlan(vp.vr.source, vp.vr.colInfoMap, vp.copyState, vp.vr.stats)
+	if err != nil {
+		return err
+	}
+	vp.replicatorPlan = plan
+	return nil
+}
+
+// ddlSourceTables returns the tables a DDL changes.
+func ddlSourceTables(stmt sqlparser.Statement) []string {
+	var tables []string
+	switch stmt := stmt.(type) {
+	case *sqlparser.AlterTable:
+		tables = append(tables, stmt.Table.Name.String())
+	case *sqlparser.TruncateTable:
+		tables = append(tables, stmt.Table.Name.String())
+	case *sqlparser.DropTable:
+		for _, table := range stmt.FromTables {
+			tables = append(tables, table.Name.String())
+		}
+	case *sqlparser.RenameTable:
+		for _, pair := range stmt.TablePairs {
+			tables = append(tables, pair.FromTable.Name.String())
+		}
+	}
+	return tables
+}
+
+// translateDDL returns the statements that apply a DDL of a source table to
+// its target tables. A DDL that does not change the target tables, such as
+// the addition of a column an explicit projection does not select,
+// translates into no statement. A *ddlIncompatibleError is returned if the
+// DDL cannot be applied to some target table.
+func translateDDL(stmt sqlparser.Statement, targets []*ddlTarget) ([]string, error) {
+	var queries []string
+	incompatible := &ddlIncompatibleError{}
+	for _, target := range targets {
+		mapping, err := newDDLColumnMapping(target.query)
+		if err != nil {
+			return nil, err
+		}
+		switch stmt := stmt.(type) {
+		case *sqlparser.AlterTable:
+			query, err := translateAlterTable(stmt, target, mapping, incompatible)
+			if err != nil {
+				return nil, err
+			}
+			if query != "" {
+				queries = append(queries, query)
+			}
+		case *sqlparser.TruncateTable:
+			if !mapping.star || mapping.filtered {
+				incompatible.add(target.name, "the source table is truncated, but the filter does not copy all its rows as is")
+				continue
+			}
+			queries = append(queries, fmt.Sprintf("truncate table %s", sqlescape.EscapeID(target.name)))
+		case *sqlparser.DropTable:
+			incompatible.add(target.name, "the source table is dropped")
+		case *sqlparser.RenameTable:
+			incompatible.add(target.name, "the source table is renamed")
+		}
+	}
+	if len(incompatible.reasons) > 0 {
+		return nil, incompatible
+	}
+	return queries, nil
+}
+
+// translateAlterTable returns the ALTER TABLE statement that applies an
+// ALTER TABLE of a source table to a target table, or an empty string if it
+// does not change the target table.
+func translateAlterTable(alter *sqlparser.AlterTable, target *ddlTarget, mapping *ddlColumnMapping, incompatible *ddlIncompatibleError) (string, error) {
+	stmt, err := sqlparser.ParseStrictDDL(target.createTable)
+	if err != nil {
+		return "", err
+	}
+	createTable, ok := stmt.(*sqlparser.CreateTable)
+	if !ok {
+		return "", fmt.Errorf("unexpected definition of table %s: %s", target.name, target.createTable)
+	}
+	from, err := schemadiff.NewCreateTableEntity(createTable)
+	if err != nil {
+		return "", err
+	}
+	hasIndex := func(name sqlparser.IdentifierCI, primary bool) bool {
+		for _, index := range from.TableSpec.Indexes {
+			if (primary && index.Info.Primary) || (!primary && index.Info.Name.Equal(name)) {
+				return true
+			}
+		}
+		return false
+	}
+	after := func(col *sqlparser.ColName) *sqlparser.ColName {
+		if col == nil {
+			return nil
+		}
+		if name, ok := mapping.target(col.Name); ok {
+			return &sqlparser.ColName{Name: name}
+		}
+		// The column the source column is placed after is not copied, so
+		// the target column keeps its place.
+		return nil
+	}
+
+	// The statement executed on the target can differ from the one
+	// schemadiff validates, which only supports the options its own diffs
+	// use.
+	var executed, validated []sqlparser.AlterOption
+	var renamedIndexes []*sqlparser.RenameIndex
+	add := func(opt sqlparser.AlterOption) {
+		executed = append(executed, opt)
+		validated = append(validated, sqlparser.CloneAlterOption(opt))
+	}
+	renameColumn := func(oldName, newName sqlparser.IdentifierCI) bool {
+		if where, ok := mapping.references[oldName.Lowered()]; ok {
+			incompatible.add(target.name, "column %s is renamed to %s, but the %s of the filter uses it", oldName.String(), newName.String(), where)
+			return false
+		}
+		if !mapping.star {
+			if _, ok := mapping.columns[oldName.Lowered()]; ok {
+				incompatible.add(target.name, "column %s is renamed to %s, but the filter selects it by name", oldName.String(), newName.String())
+			}
+			return false
+		}
+		return true
+	}
+	for _, opt := range alter.AlterOptions {
+		switch opt := opt.(type) {
+		case *sqlparser.AddColumns:
+			if !mapping.star {
+				// The filter does not select the new columns.
+				continue
+			}
+			for i, col := range opt.Columns {
+				addColumn := &sqlparser.AddColumns{Columns: []*sqlparser.ColumnDefinition{col}}
+				if i == 0 {
+					addColumn.First = opt.First
+					addColumn.After = after(opt.After)
+				} else {
+					addColumn.After = &sqlparser.ColName{Name: opt.Columns[i-1].Name}
+				}
+				add(addColumn)
+			}
+		case *sqlparser.DropColumn:
+			if where, ok := mapping.references[opt.Name.Name.Lowered()]; ok {
+				incompatible.add(target.name, "column %s is dropped, but the %s of the filter uses it", opt.Name.Name.String(), where)
+				continue
+			}
+			if name, ok := mapping.target(opt.Name.Name); ok {
+				add(&sqlparser.DropColumn{Name: &sqlparser.ColName{Name: name}})
+			}
+		case *sqlparser.ModifyColumn:
+			if name, ok := mapping.target(opt.NewColDefinition.Name); ok {
+				col := sqlparser.CloneRefOfColumnDefinition(opt.NewColDefinition)
+				col.Name = name
+				add(&sqlparser.ModifyColumn{NewColDefinition: col, First: opt.First, After: after(opt.After)})
+			}
+		case *sqlparser.ChangeColumn:
+			oldName, newName := opt.OldColumn.Name, opt.NewColDefinition.Name
+			if oldName.Equal(newName) {
+				if name, ok := mapping.target(oldName); ok {
+					col := sqlparser.CloneRefOfColumnDefinition(opt.NewColDefinition)
+					col.Name = name
+					add(&sqlparser.ModifyColumn{NewColDefinition: col, First: opt.First, After: after(opt.After)})
+				}
+				continue
+			}
+			if !renameColumn(oldName, newName) {
+				continue
+			}
+			executed = append(executed, &sqlparser.ChangeColumn{
+				OldColumn:        &sqlparser.ColName{Name: oldName},
+				NewColDefinition: sqlparser.CloneRefOfColumnDefinition(opt.NewColDefinition),
+				First:            opt.First,
+				After:            after(opt.After),
+			})
+			col := sqlparser.CloneRefOfColumnDefinition(opt.NewColDefinition)
+			col.Name = oldName
+			validated = append(validated,
+				&sqlparser.ModifyColumn{NewColDefinition: col, First: opt.First, After: after(opt.After)},
+				&sqlparser.RenameColumn{OldName: &sqlparser.ColName{Name: oldName}, NewName: &sqlparser.ColName{Name: newName}},
+			)
+		case *sqlparser.RenameColumn:
+			if renameColumn(opt.OldName.Name, opt.NewName.Name) {
+				add(&sqlparser.RenameColumn{OldName: &sqlparser.ColName{Name: opt.OldName.Name}, NewName: &sqlparser.ColName{Name: opt.NewName.Name}})
+			}
+		case *sqlparser.AlterColumn:
+			if name, ok := mapping.target(opt.Column.Name); ok {
+				alterColumn := sqlparser.CloneRefOfAlterColumn(opt)
+				alterColumn.Column = &sqlparser.ColName{Name: name}
+				add(alterColumn)
+			}
+		case *sqlparser.AddIndexDefinition:
+			index := sqlparser.CloneRefOfIndexDefinition(opt.IndexDefinition)
+			copied := true
+			for _, col := range index.Columns {
+				name, ok := mapping.target(col.Column)
+				if col.Expression != nil || !ok {
+					copied = false
+					break
+				}
+				col.Column = name
+			}
+			// An index on columns the target table does not have as is
+			// cannot be added to it.
+			if copied {
+				add(&sqlparser.AddIndexDefinition{IndexDefinition: index})
+			}
+		case *sqlparser.DropKey:
+			switch opt.Type {
+			case sqlparser.PrimaryKeyType, sqlparser.NormalKeyType:
+				if hasIndex(opt.Name, opt.Type == sqlparser.PrimaryKeyType) {
+					add(sqlparser.CloneRefOfDropKey(opt))
+				}
+			default:
+				// Foreign keys and check constraints of the source are not
+				// necessarily those of the target.
+			}
+		case *sqlparser.RenameIndex:
+			if hasIndex(opt.OldName, false) {
+				executed = append(executed, sqlparser.CloneRefOfRenameIndex(opt))
+				renamedIndexes = append(renamedIndexes, opt)
+			}
+		case *sqlparser.AlterIndex:
+			if hasIndex(opt.Name, false) {
+				add(sqlparser.CloneRefOfAlterIndex(opt))
+			}
+		case *sqlparser.AddConstraintDefinition, *sqlparser.AlterCheck:
+			// Constraints reference the columns of the source table, and are
+			// not necessarily those of the target.
+		case sqlparser.TableOptions:
+			if mapping.star {
+				add(sqlparser.CloneTableOptions(opt))
+			}
+		case sqlparser.AlgorithmValue, *sqlparser.LockOption, *sqlparser.Force, *sqlparser.Validation:
+			// How the source table is altered does not matter.
+		default:
+			incompatible.add(target.name, "unsupported alter option %s", sqlparser.String(opt))
+		}
+	}
+	if len(executed) == 0 {
+		return "", nil
+	}
+
+	to, err := from.ApplyAlterTable(&sqlparser.AlterTable{Table: createTable.Table, AlterOptions: validated})
+	if err != nil {
+		incompatible.add(target.name, "%v", err)
+		return "", nil
+	}
+	for _, rename := range renamedIndexes {
+		for _, index := range to.TableSpec.Indexes {
+			if index.Info.Name.Equal(rename.OldName) {
+				index.Info.Name = rename.NewName
+			}
+		}
+	}
+	diff, err := from.TableDiff(to, &schemadiff.DiffHints{})
+	if err != nil {
+		return "", err
+	}
+	if diff.IsEmpty() {
+		return "", nil
+	}
+	return sqlparser.String(&sqlparser.AlterTable{
+		Table:        sqlparser.TableName{Name: sqlparser.NewIdentifierCS(target.name)},
+		AlterOptions: executed,
+	}), nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+func TestTranslateDDL(t *testing.T) {
+	moveTablesTarget := func() *ddlTarget {
+		return &ddlTarget{
+			name:        "t1",
+			query:       "select * from t1",
+			createTable: "create table t1 (id int, nick varchar(10), primary key (id))",
+		}
+	}
+	renamingTarget := func() *ddlTarget {
+		return &ddlTarget{
+			name:        "t2",
+			query:       "select id, nick as handle from t1",
+			createTable: "create table t2 (id int, handle varchar(10), primary key (id))",
+		}
+	}
+	materializeTarget := func() *ddlTarget {
+		return &ddlTarget{
+			name:        "sales",
+			query:       "select sku, count(*) as orders, sum(price) as revenue from corder where kind = 'retail' group by sku",
+			createTable: "create table sales (sku varchar(32), orders bigint, revenue decimal(10,2), primary key (sku))",
+		}
+	}
+
+	tcs := []struct {
+		name         string
+		ddl          string
+		target       *ddlTarget
+		want         []string
+		incompatible string
+	}{
+		{
+			name:   "add column",
+			ddl:    "alter table t1 add column email varchar(64)",
+			target: moveTablesTarget(),
+			want:   []string{"alter table t1 add column email varchar(64)"},
+		},
+		{
+			name:   "add several columns",
+			ddl:    "alter table t1 add column (a int, b int)",
+			target: moveTablesTarget(),
+			want:   []string{"alter table t1 add column a int, add column b int after a"},
+		},
+		{
+			name:   "rename column",
+			ddl:    "alter table t1 change column nick handle varchar(20)",
+			target: moveTablesTarget(),
+			want:   []string{"alter table t1 change column nick handle varchar(20)"},
+		},
+		{
+			name:   "no-op",
+			ddl:    "alter table t1 modify column nick varchar(10)",
+			target: moveTablesTarget(),
+		},
+		{
+			name:   "truncate",
+			ddl:    "truncate table t1",
+			target: moveTablesTarget(),
+			want:   []string{"truncate table `t1`"},
+		},
+		{
+			name:         "drop table",
+			ddl:          "drop table t1",
+			target:       moveTablesTarget(),
+			incompatible: "table t1: the source table is dropped",
+		},
+		{
+			name:   "modify aliased column",
+			ddl:    "alter table t1 modify column nick varchar(20)",
+			target: renamingTarget(),
+			want:   []string{"alter table t2 modify column handle varchar(20)"},
+		},
+		{
+			name:   "add column not selected",
+			ddl:    "alter table t1 add column email varchar(64)",
+			target: renamingTarget(),
+		},
+		{
+			name:         "rename selected column",
+			ddl:          "alter table t1 rename column nick to handle",
+			target:       renamingTarget(),
+			incompatible: "table t2: column nick is renamed to handle, but the filter selects it by name",
+		},
+		{
+			name:   "modify group by column",
+			ddl:    "alter table corder modify column sku varchar(64)",
+			target: materializeTarget(),
+			want:   []string{"alter table sales modify column sku varchar(64)"},
+		},
+		{
+			name:   "add index",
+			ddl:    "alter table corder add index sku_idx (sku)",
+			target: materializeTarget(),
+			want:   []string{"alter table sales add index sku_idx (sku)"},
+		},
+		{
+			name:   "add index on column not selected",
+			ddl:    "alter table corder add index kind_idx (kind)",
+			target: materializeTarget(),
+		},
+		{
+			name:         "drop aggregated column",
+			ddl:          "alter table corder drop column price",
+			target:       materializeTarget(),
+			incompatible: "table sales: column price is dropped, but the expression sum(price) as revenue of the filter uses it",
+		},
+		{
+			name:         "rename filtered column",
+			ddl:          "alter table corder rename column kind to category",
+			target:       materializeTarget(),
+			incompatible: "table sales: column kind is renamed to category, but the where clause of the filter uses it",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			stmt, err := sqlparser.Parse(tc.ddl)
+			require.NoError(t, err)
+			got, err := translateDDL(stmt, []*ddlTarget{tc.target})
+			if tc.incompatible != "" {
+				var incompatible *ddlIncompatibleError
+				require.True(t, errors.As(err, &incompatible), "unexpected error: %v", err)
+				assert.EqualError(t, err, tc.incompatible)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestDDLSourceTables(t *testing.T) {
+	for ddl, want := range map[string][]string{
+		"alter table t1 add column c int":      {"t1"},
+		"truncate table t1":                    {"t1"},
+		"drop table t1, t2":                    {"t1", "t2"},
+		"rename table t1 to t3, t2 to t4":      {"t1", "t2"},
+		"create table t5 (id int primary key)": nil,
+	} {
+		stmt, err := sqlparser.Parse(ddl)
+		require.NoError(t, err)
+		assert.Equal(t, want, ddlSourceTables(stmt), ddl)
+	}
+}
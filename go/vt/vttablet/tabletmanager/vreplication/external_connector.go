@@ -17,10 +17,12 @@ limitations under the License.
 package vreplication
 
 import (
+	"strings"
 	"sync"
 
 	"context"
 
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/dbconfigs"
 	"vitess.io/vitess/go/vt/grpcclient"
@@ -86,6 +88,15 @@ func (ec *externalConnector) Get(name string) (*mysqlConnector, error) {
 	if config.DB == nil {
 		return nil, vterrors.Errorf(vtrpcpb.Code_NOT_FOUND, "external mysqlConnector %v not found", name)
 	}
+	if config.DB.Flavor == "" {
+		flavor, err := detectSourceFlavor(context.Background(), config.DB.DbaWithDB())
+		if err != nil {
+			return nil, vterrors.Wrapf(err, "external mysqlConnector: %v", name)
+		}
+		if flavor != "" {
+			config.DB = config.DB.CloneWithFlavor(flavor)
+		}
+	}
 	c := &mysqlConnector{}
 	c.env = tabletenv.NewEnv(config, name)
 	c.se = schema.NewEngine(c.env)
@@ -104,6 +115,38 @@ func (ec *externalConnector) Get(name string) (*mysqlConnector, error) {
 	return c, nil
 }
 
+// detectSourceFlavor returns the flavor to replicate an external source with
+// when its configuration does not specify one. A MySQL source without GTIDs,
+// including MySQL 5.5, is replicated by binary log file and position, with
+// the FilePos flavor. A MariaDB source and a MySQL source with GTIDs keep the
+// flavor of the server, which is detected from the handshake of every
+// connection.
+func detectSourceFlavor(ctx context.Context, cp dbconfigs.Connector) (string, error) {
+	conn, err := cp.Connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if conn.IsMariaDB() {
+		return "", nil
+	}
+	qr, err := conn.ExecuteFetch("select @@global.gtid_mode", 1, false)
+	if err != nil {
+		// MySQL 5.5 and earlier have no GTIDs.
+		if sqlErr, ok := err.(*mysql.SQLError); ok && sqlErr.Number() == mysql.ERUnknownSystemVariable {
+			return mysql.FilePosFlavorID, nil
+		}
+		return "", err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+		return "", vterrors.Errorf(vtrpcpb.Code_INTERNAL, "unexpected result for gtid_mode: %v", qr.Rows)
+	}
+	if !strings.EqualFold(qr.Rows[0][0].ToString(), "ON") {
+		return mysql.FilePosFlavorID, nil
+	}
+	return "", nil
+}
+
 //-----------------------------------------------------------
 
 type mysqlConnector struct {
@@ -17,13 +17,20 @@ limitations under the License.
 package vreplication
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	"vitess.io/vitess/go/vt/servenv"
 	qh "vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication/queryhistory"
 )
 
@@ -170,6 +177,109 @@ func TestExternalConnectorPlay(t *testing.T) {
 	}, pos)
 }
 
+func TestExternalConnectorFilePos(t *testing.T) {
+	execStatements(t, []string{
+		"create table tab1(id int, val varbinary(128), primary key(id))",
+		fmt.Sprintf("create table %s.tab1(id int, val varbinary(128), primary key(id))", vrepldb),
+		"insert into tab1 values(1, 'a')",
+	})
+	defer execStatements(t, []string{
+		"drop table tab1",
+		fmt.Sprintf("drop table %s.tab1", vrepldb),
+	})
+
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "tab1",
+			Filter: "",
+		}},
+	}
+	bls := &binlogdatapb.BinlogSource{
+		ExternalMysql: "extfp",
+		Filter:        filter,
+	}
+	cancel := startExternalVReplication(t, bls, "")
+	expectDBClientAndVreplicationQueries(t, []string{
+		"begin",
+		"insert into tab1(id,val) values (1,'a')",
+		"/insert into _vt.copy_state",
+		"commit",
+		"/delete cs, pca from _vt.copy_state as cs left join _vt.post_copy_action as pca on cs.vrepl_id=pca.vrepl_id and cs.table_name=pca.table_name",
+		"/update _vt.vreplication set state='Running'",
+	}, "")
+
+	// The stream follows the source into a new binary log.
+	execStatements(t, []string{
+		"flush binary logs",
+		"insert into tab1 values(2, 'b')",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"insert into tab1(id,val) values (2,'b')",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	qr, err := env.Mysqld.FetchSuperQuery(context.Background(), "select pos from _vt.vreplication")
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	pos := qr.Rows[0][0].ToString()
+	require.True(t, strings.HasPrefix(pos, mysql.FilePosFlavorID+"/"), "unexpected position %s", pos)
+	cancel()
+
+	// A stream restarted from the recorded binary log file and position
+	// replicates the rows written while it was stopped, in the binary logs
+	// rotated since.
+	execStatements(t, []string{
+		"flush binary logs",
+		"insert into tab1 values(3, 'c')",
+	})
+	cancel = startExternalVReplication(t, bls, pos)
+	defer cancel()
+	expectDBClientAndVreplicationQueries(t, []string{
+		"begin",
+		"insert into tab1(id,val) values (3,'c')",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	}, pos)
+	expectData(t, "tab1", [][]string{
+		{"1", "a"},
+		{"2", "b"},
+		{"3", "c"},
+	})
+}
+
+func TestDetectSourceFlavor(t *testing.T) {
+	ctx := context.Background()
+	db := fakesqldb.New(t)
+	defer db.Close()
+
+	db.AddQuery("select @@global.gtid_mode", sqltypes.MakeTestResult(sqltypes.MakeTestFields("@@global.gtid_mode", "varchar"), "ON"))
+	flavor, err := detectSourceFlavor(ctx, db.ConnParams())
+	require.NoError(t, err)
+	assert.Equal(t, "", flavor)
+
+	db.AddQuery("select @@global.gtid_mode", sqltypes.MakeTestResult(sqltypes.MakeTestFields("@@global.gtid_mode", "varchar"), "OFF_PERMISSIVE"))
+	flavor, err = detectSourceFlavor(ctx, db.ConnParams())
+	require.NoError(t, err)
+	assert.Equal(t, mysql.FilePosFlavorID, flavor)
+
+	db.AddRejectedQuery("select @@global.gtid_mode", mysql.NewSQLError(mysql.ERUnknownSystemVariable, mysql.SSUnknownSQLState, "Unknown system variable 'gtid_mode'"))
+	flavor, err = detectSourceFlavor(ctx, db.ConnParams())
+	require.NoError(t, err)
+	assert.Equal(t, mysql.FilePosFlavorID, flavor)
+
+	// A MariaDB source keeps its flavor, and its GTIDs.
+	version := servenv.MySQLServerVersion()
+	servenv.SetMySQLServerVersionForTest("10.6.12-MariaDB-log")
+	defer servenv.SetMySQLServerVersionForTest(version)
+	mariadb := fakesqldb.New(t)
+	defer mariadb.Close()
+	flavor, err = detectSourceFlavor(ctx, mariadb.ConnParams())
+	require.NoError(t, err)
+	assert.Equal(t, "", flavor)
+	assert.Zero(t, mariadb.GetQueryCalledNum("select @@global.gtid_mode"))
+}
+
 func expectDBClientAndVreplicationQueries(t *testing.T, queries []string, pos string) {
 	t.Helper()
 	vrepQueries := getExpectedVreplicationQueries(t, pos)
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"fmt"
+	"strings"
+
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+)
+
+// ValidateFilterLocality checks that a filter reading a source keyspace
+// with more than one shard only recomputes rows from a single shard. The
+// stream of every source shard reads the source tables of its own shard
+// only, so the tablet cannot check this itself:
+//   - the groups of MIN, MAX, AVG and COUNT(DISTINCT) aggregates are
+//     recomputed from the rows of the source shard of the stream, and the
+//     group by must include the primary vindex columns of the table, for
+//     the rows of a group to be on one shard.
+//   - the rows of a join key are recomputed from the rows of the source
+//     shard of the stream, and the join key must be the primary vindex
+//     column of both tables, with the same vindex.
+func ValidateFilterLocality(filter string, sourceVSchema *vindexes.KeyspaceSchema) error {
+	statement, err := sqlparser.Parse(filter)
+	if err != nil {
+		return err
+	}
+	sel, ok := statement.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return nil
+	}
+	jp, err := analyzeJoin("", &binlogdatapb.Rule{Filter: filter})
+	if err != nil {
+		return err
+	}
+	if jp != nil {
+		var vindexName string
+		for _, side := range jp.sides {
+			cv, err := primaryVindex(sourceVSchema, side.table.String())
+			if err != nil {
+				return err
+			}
+			if len(cv.Columns) != 1 || !cv.Columns[0].Equal(side.columns[0]) || (vindexName != "" && cv.Name != vindexName) {
+				return fmt.Errorf("the join key of a source keyspace with more than one shard must be the primary vindex column of both tables, with the same vindex: %v", sqlparser.String(sel))
+			}
+			vindexName = cv.Name
+		}
+		return nil
+	}
+
+	var recomputed bool
+	for _, selExpr := range sel.SelectExprs {
+		aliased, ok := selExpr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		if aggr, ok := aliased.Expr.(sqlparser.AggrFunc); ok {
+			switch strings.ToLower(aggr.AggrName()) {
+			case "min", "max", "avg":
+				recomputed = true
+			case "count":
+				recomputed = recomputed || aggr.IsDistinct()
+			}
+		}
+	}
+	if !recomputed {
+		return nil
+	}
+	node, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil
+	}
+	table := sqlparser.GetTableName(node.Expr).String()
+	cv, err := primaryVindex(sourceVSchema, table)
+	if err != nil {
+		return err
+	}
+	for _, col := range cv.Columns {
+		grouped := false
+		for _, expr := range sel.GroupBy {
+			if colName, ok := expr.(*sqlparser.ColName); ok && colName.Name.Equal(col) {
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			return fmt.Errorf("min, max, avg and count(distinct) of a source keyspace with more than one shard require the primary vindex column %v of table %s to be grouped", sqlparser.String(col), table)
+		}
+	}
+	return nil
+}
+
+// primaryVindex returns the primary vindex of a table of a sharded source
+// keyspace.
+func primaryVindex(sourceVSchema *vindexes.KeyspaceSchema, table string) (*vindexes.ColumnVindex, error) {
+	t := sourceVSchema.Tables[table]
+	if t == nil || len(t.ColumnVindexes) == 0 {
+		return nil, fmt.Errorf("table %s has no primary vindex in the vschema of the source keyspace", table)
+	}
+	return t.ColumnVindexes[0], nil
+}
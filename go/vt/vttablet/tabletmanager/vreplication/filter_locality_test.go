@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/vt/vtgate/vindexes"
+
+	vschemapb "vitess.io/vitess/go/vt/proto/vschema"
+)
+
+func TestValidateFilterLocality(t *testing.T) {
+	sourceVSchema, err := vindexes.BuildKeyspaceSchema(&vschemapb.Keyspace{
+		Sharded: true,
+		Vindexes: map[string]*vschemapb.Vindex{
+			"hash":   {Type: "hash"},
+			"xxhash": {Type: "xxhash"},
+		},
+		Tables: map[string]*vschemapb.Table{
+			"a": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "k", Name: "hash"}}},
+			"b": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "k", Name: "hash"}}},
+			"c": {ColumnVindexes: []*vschemapb.ColumnVindex{{Column: "k", Name: "xxhash"}}},
+		},
+	}, "ks")
+	require.NoError(t, err)
+
+	testcases := []struct {
+		filter string
+		err    string
+	}{{
+		filter: "select * from a",
+	}, {
+		filter: "select x, count(*) as c, sum(y) as s from a group by x",
+	}, {
+		filter: "select k, x, min(y) as mn, count(distinct y) as cd from a group by k, x",
+	}, {
+		filter: "select x, max(y) as mx from a group by x",
+		err:    "min, max, avg and count(distinct) of a source keyspace with more than one shard require the primary vindex column k of table a to be grouped",
+	}, {
+		filter: "select x, count(distinct y) as cd from a group by x",
+		err:    "min, max, avg and count(distinct) of a source keyspace with more than one shard require the primary vindex column k of table a to be grouped",
+	}, {
+		filter: "select x, avg(y) as av from t group by x",
+		err:    "table t has no primary vindex in the vschema of the source keyspace",
+	}, {
+		filter: "select a.id, a.k, b.val from a join b on a.k = b.k",
+	}, {
+		filter: "select a.id, a.x, b.val from a join b on a.x = b.k",
+		err:    "the join key of a source keyspace with more than one shard must be the primary vindex column of both tables, with the same vindex: select a.id, a.x, b.val from a join b on a.x = b.k",
+	}, {
+		filter: "select a.id, a.k, c.val from a join c on a.k = c.k",
+		err:    "the join key of a source keyspace with more than one shard must be the primary vindex column of both tables, with the same vindex: select a.id, a.k, c.val from a join c on a.k = c.k",
+	}}
+	for _, tcase := range testcases {
+		t.Run(tcase.filter, func(t *testing.T) {
+			err := ValidateFilterLocality(tcase.filter, sourceVSchema)
+			if tcase.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tcase.err)
+		})
+	}
+}
+
+func TestValidateJoin(t *testing.T) {
+	assert.NoError(t, ValidateJoin("select a.id, a.k, b.val from a join b on a.k = b.k where a.x = 1 and in_keyrange(b.k, 'ks.hash', '-80')"))
+	assert.NoError(t, ValidateJoin("select id from a where x = 1"))
+	assert.EqualError(t, ValidateJoin("select a.id, a.k from a join b on a.k = b.k where a.x = b.y"),
+		"a condition of a join can only reference the columns of one table: a.x = b.y")
+}
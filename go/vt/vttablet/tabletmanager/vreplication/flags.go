@@ -40,6 +40,8 @@ var (
 
 	vreplicationStoreCompressedGTID   = false
 	vreplicationParallelInsertWorkers = 1
+	vreplicationParallelApplyWorkers  = 1
+	vreplicationParallelTableCopies   = 1
 )
 
 func registerVReplicationFlags(fs *pflag.FlagSet) {
@@ -70,6 +72,8 @@ func registerVReplicationFlags(fs *pflag.FlagSet) {
 	fs.Duration("vreplication_healthcheck_timeout", 1*time.Minute, "healthcheck retry delay")
 
 	fs.IntVar(&vreplicationParallelInsertWorkers, "vreplication-parallel-insert-workers", vreplicationParallelInsertWorkers, "Number of parallel insertion workers to use during copy phase. Set <= 1 to disable parallelism, or > 1 to enable concurrent insertion during copy phase.")
+	fs.IntVar(&vreplicationParallelApplyWorkers, "vreplication-parallel-apply-workers", vreplicationParallelApplyWorkers, "Number of parallel apply workers to use during the replication phase. Set <= 1 to disable parallelism, or > 1 to apply the transactions which do not change the same rows concurrently.")
+	fs.IntVar(&vreplicationParallelTableCopies, "vreplication-parallel-table-copies", vreplicationParallelTableCopies, "Number of tables to copy concurrently per stream during copy phase. Set <= 1 to disable parallelism, or > 1 to copy several tables at once, each from its own snapshot.")
 }
 
 func init() {
@@ -153,8 +153,9 @@ func setup() (func(), int) {
 		return nil, 1
 	}
 	externalConfig := map[string]*dbconfigs.DBConfigs{
-		"exta": env.Dbcfgs,
-		"extb": env.Dbcfgs,
+		"exta":  env.Dbcfgs,
+		"extb":  env.Dbcfgs,
+		"extfp": env.Dbcfgs.CloneWithFlavor(mysql.FilePosFlavorID),
 	}
 	playerEngine = NewTestEngine(env.TopoServ, env.Cells[0], env.Mysqld, realDBClientFactory, realDBClientFactory, vrepldb, externalConfig)
 	playerEngine.Open(context.Background())
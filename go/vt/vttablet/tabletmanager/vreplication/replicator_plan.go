@@ -70,6 +70,13 @@ func (rp *ReplicatorPlan) buildExecutionPlan(fieldEvent *binlogdatapb.FieldEvent
 		// Unreachable code.
 		return nil, fmt.Errorf("plan not found for %s", fieldEvent.TableName)
 	}
+	// The rows of a join are read by the position of their columns,
+	// which the plan already knows.
+	if prelim.Join != nil {
+		tplanv := *prelim
+		tplanv.Fields = fieldEvent.Fields
+		return &tplanv, nil
+	}
 	// If Insert is initialized, then it means that we knew the column
 	// names and have already built most of the plan.
 	if prelim.Insert != nil {
@@ -209,6 +216,16 @@ type TablePlan struct {
 	FieldsToSkip            map[string]bool
 	ConvertCharset          map[string](*binlogdatapb.CharsetConversion)
 	HasExtraSourcePkColumns bool
+	// IdempotentInserts is set by the vplayer of the copy phase when tables
+	// are copied concurrently. The rows it inserts may then already have been
+	// copied from a later snapshot, so they are deleted beforehand.
+	IdempotentInserts bool
+	// Aggregates is set if the table has MIN, MAX, AVG or COUNT(DISTINCT)
+	// aggregates, which are recomputed from the source table.
+	Aggregates *aggregatePlan
+	// Join is set if the filter is a join of two source tables, whose
+	// rows are recomputed from the source tables.
+	Join *joinPlan
 
 	TablePlanBuilder *tablePlanBuilder
 	// PartialInserts is a dynamically generated cache of insert ParsedQueries, which update only some columns.
@@ -406,6 +423,9 @@ func (tp *TablePlan) applyChange(rowChange *binlogdatapb.RowChange, executor fun
 		if tp.isOutsidePKRange(bindvars, before, after, "insert") {
 			return nil, nil
 		}
+		if err := tp.deleteBeforeInsert(bindvars, executor); err != nil {
+			return nil, err
+		}
 		if tp.isPartial(rowChange) {
 			ins, err := tp.getPartialInsertQuery(rowChange.DataColumns)
 			if err != nil {
@@ -442,12 +462,37 @@ func (tp *TablePlan) applyChange(rowChange *binlogdatapb.RowChange, executor fun
 		if tp.isOutsidePKRange(bindvars, before, after, "insert") {
 			return nil, nil
 		}
+		if err := tp.deleteBeforeInsert(bindvars, executor); err != nil {
+			return nil, err
+		}
 		return execParsedQuery(tp.Insert, bindvars, executor)
 	}
 	// Unreachable.
 	return nil, nil
 }
 
+// deleteBeforeInsert deletes the row about to be inserted, if the plan
+// has IdempotentInserts.
+func (tp *TablePlan) deleteBeforeInsert(bindvars map[string]*querypb.BindVariable, executor func(string) (*sqltypes.Result, error)) error {
+	if !tp.IdempotentInserts || tp.Delete == nil {
+		return nil
+	}
+	deleteBindvars := make(map[string]*querypb.BindVariable, len(bindvars))
+	for k, v := range bindvars {
+		if name, ok := strings.CutPrefix(k, "a_"); ok {
+			deleteBindvars["b_"+name] = v
+		}
+	}
+	_, err := execParsedQuery(tp.Delete, deleteBindvars, executor)
+	return err
+}
+
+// isInsertNormal returns true if the rows of the table are copied as is,
+// without any aggregation.
+func (tp *TablePlan) isInsertNormal() bool {
+	return tp.TablePlanBuilder == nil || tp.TablePlanBuilder.onInsert == insertNormal
+}
+
 func getQuery(pq *sqlparser.ParsedQuery, bindvars map[string]*querypb.BindVariable) (string, error) {
 	sql, err := pq.GenerateQuery(bindvars, nil)
 	if err != nil {
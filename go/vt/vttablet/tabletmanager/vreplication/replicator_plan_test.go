@@ -17,6 +17,7 @@ limitations under the License.
 package vreplication
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 	"testing"
@@ -24,9 +25,12 @@ import (
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/mysql/collations"
 	"vitess.io/vitess/go/sqltypes"
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
 )
 
 type TestReplicatorPlan struct {
@@ -316,6 +320,58 @@ func TestBuildPlayerPlan(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		// recomputed aggregates
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select c1, min(c2) as mn, max(c2) as mx, avg(c3) as av, count(distinct c3) as cd from t2 group by c1",
+			}},
+		},
+		plan: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t2",
+					Filter: "select c1, c2, c2, c3, c3 from t2",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"t2": {
+					TargetName:   "t1",
+					SendRule:     "t2",
+					PKReferences: []string{"c1"},
+					InsertFront:  "insert into t1(c1,mn,mx,av,cd)",
+					InsertValues: "(:a_c1,:a_c2,:a_c2,:a_c3,if(:a_c3 is null, 0, 1))",
+					InsertOnDup:  "on duplicate key update mn=least(ifnull(mn, values(mn)), ifnull(values(mn), mn)), mx=greatest(ifnull(mx, values(mx)), ifnull(values(mx), mx)), av=av, cd=cd",
+					Insert:       "insert into t1(c1,mn,mx,av,cd) values (:a_c1,:a_c2,:a_c2,:a_c3,if(:a_c3 is null, 0, 1)) on duplicate key update mn=least(ifnull(mn, values(mn)), ifnull(values(mn), mn)), mx=greatest(ifnull(mx, values(mx)), ifnull(values(mx), mx)), av=av, cd=cd",
+					Update:       "update t1 set mn=mn, mx=mx, av=av, cd=cd where c1=:b_c1",
+					Delete:       "update t1 set mn=mn, mx=mx, av=av, cd=cd where c1=:b_c1",
+				},
+			},
+		},
+		planpk: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "t2",
+					Filter: "select c1, c2, c2, c3, c3, pk1, pk2 from t2",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"t2": {
+					TargetName:   "t1",
+					SendRule:     "t2",
+					PKReferences: []string{"c1", "pk1", "pk2"},
+					InsertFront:  "insert into t1(c1,mn,mx,av,cd)",
+					InsertValues: "(:a_c1,:a_c2,:a_c2,:a_c3,if(:a_c3 is null, 0, 1))",
+					InsertOnDup:  "on duplicate key update mn=least(ifnull(mn, values(mn)), ifnull(values(mn), mn)), mx=greatest(ifnull(mx, values(mx)), ifnull(values(mx), mx)), av=av, cd=cd",
+					Insert:       "insert into t1(c1,mn,mx,av,cd) select :a_c1, :a_c2, :a_c2, :a_c3, if(:a_c3 is null, 0, 1) from dual where (:a_pk1,:a_pk2) <= (1,'aaa') on duplicate key update mn=least(ifnull(mn, values(mn)), ifnull(values(mn), mn)), mx=greatest(ifnull(mx, values(mx)), ifnull(values(mx), mx)), av=av, cd=cd",
+					Update:       "update t1 set mn=mn, mx=mx, av=av, cd=cd where c1=:b_c1 and (:b_pk1,:b_pk2) <= (1,'aaa')",
+					Delete:       "update t1 set mn=mn, mx=mx, av=av, cd=cd where c1=:b_c1 and (:b_pk1,:b_pk2) <= (1,'aaa')",
+				},
+			},
+		},
 	}, {
 		input: &binlogdatapb.Filter{
 			Rules: []*binlogdatapb.Rule{{
@@ -612,7 +668,34 @@ func TestBuildPlayerPlan(t *testing.T) {
 				Filter: "select count(c1) as c from t1",
 			}},
 		},
-		err: "only count(*) is supported: count(c1)",
+		err: "only count(*) and count(distinct) are supported: count(c1)",
+	}, {
+		// no sum(distinct)
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select c1, sum(distinct c2) as c from t1 group by c1",
+			}},
+		},
+		err: "unexpected: sum(distinct c2)",
+	}, {
+		// min requires the primary key to be grouped
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select c1, min(c2) as c from t1",
+			}},
+		},
+		err: "min, max, avg and count(distinct) require the primary key column c1 to be a grouped column",
+	}, {
+		// no complex expr in max
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select c1, max(a + b) as c from t1 group by c1",
+			}},
+		},
+		err: "unexpected: max(a + b)",
 	}, {
 		// no sum(*)
 		input: &binlogdatapb.Filter{
@@ -667,6 +750,94 @@ func TestBuildPlayerPlan(t *testing.T) {
 			}},
 		},
 		err: "group by expression is not allowed to reference an aggregate expression: a",
+	}, {
+		// join
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select a.id, a.k, b.val as v from a join b on a.k = b.k where a.x = 1 and b.val != 'z'",
+			}},
+		},
+		plan: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "a",
+					Filter: "select k, id from a where (x = 1)",
+				}, {
+					Match:  "b",
+					Filter: "select k, val from b where (val != 'z')",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"a": {
+					TargetName: "t1",
+					SendRule:   "a",
+				},
+				"b": {
+					TargetName: "t1",
+					SendRule:   "b",
+				},
+			},
+		},
+		planpk: &TestReplicatorPlan{
+			VStreamFilter: &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{
+					Match:  "a",
+					Filter: "select k, id from a where (x = 1)",
+				}, {
+					Match:  "b",
+					Filter: "select k, val from b where (val != 'z')",
+				}},
+			},
+			TargetTables: []string{"t1"},
+			TablePlans: map[string]*TestTablePlan{
+				"a": {
+					TargetName: "t1",
+					SendRule:   "a",
+				},
+				"b": {
+					TargetName: "t1",
+					SendRule:   "b",
+				},
+			},
+		},
+	}, {
+		// no outer join
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select a.k, b.val from a left join b on a.k = b.k",
+			}},
+		},
+		err: "only inner joins with an on condition are supported: select a.k, b.val from a left join b on a.k = b.k",
+	}, {
+		// the join key must be selected
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select a.id, b.val from a join b on a.k = b.k",
+			}},
+		},
+		err: "the join key must be selected: select a.id, b.val from a join b on a.k = b.k",
+	}, {
+		// a condition cannot reference both tables
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select a.k, b.val from a join b on a.k = b.k where a.x = b.y",
+			}},
+		},
+		err: "a condition of a join can only reference the columns of one table: a.x = b.y",
+	}, {
+		// columns of a join must be qualified
+		input: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match:  "t1",
+				Filter: "select a.k, val from a join b on a.k = b.k",
+			}},
+		},
+		err: "column val must be qualified by one of the joined tables",
 	}}
 
 	PrimaryKeyInfos := map[string][]*ColumnInfo{
@@ -772,3 +943,152 @@ func TestBuildPlayerPlanExclude(t *testing.T) {
 	wantPlan, _ := json.Marshal(want)
 	assert.Equal(t, string(gotPlan), string(wantPlan))
 }
+
+// fakeRowsStreamer sends the same rows to every VStreamRows request, or
+// the rows of the table of the request if tableRows is set.
+type fakeRowsStreamer struct {
+	VStreamerClient
+	queries   []string
+	rows      *binlogdatapb.VStreamRowsResponse
+	tableRows map[string]*binlogdatapb.VStreamRowsResponse
+}
+
+func (frs *fakeRowsStreamer) VStreamRows(ctx context.Context, query string, lastpk *querypb.QueryResult, send func(*binlogdatapb.VStreamRowsResponse) error) error {
+	frs.queries = append(frs.queries, query)
+	for table, rows := range frs.tableRows {
+		if strings.Contains(query, " from "+table+" ") {
+			return send(rows)
+		}
+	}
+	return send(frs.rows)
+}
+
+func TestRecomputeAggregates(t *testing.T) {
+	PrimaryKeyInfos := map[string][]*ColumnInfo{
+		"t1": {&ColumnInfo{Name: "c1", IsPK: true}},
+	}
+	input := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "t1",
+			Filter: "select c1, min(c2) as mn, max(c2) as mx, avg(c3) as av, count(distinct c3) as cd, count(*) as rc from t2 where c3 > 0 group by c1",
+		}},
+	}
+	plan, err := buildReplicatorPlan(getSource(input), PrimaryKeyInfos, nil, binlogplayer.NewStats())
+	require.NoError(t, err)
+
+	fields := sqltypes.MakeTestFields("c1|c2|c2|c3|c3", "int64|varchar|varchar|int64|int64")
+	for _, field := range fields {
+		if field.Type == querypb.Type_VARCHAR {
+			field.Charset = uint32(collations.Default())
+		}
+	}
+	tplan, err := plan.buildExecutionPlan(&binlogdatapb.FieldEvent{TableName: "t2", Fields: fields})
+	require.NoError(t, err)
+	require.NotNil(t, tplan.Aggregates)
+
+	groups, err := tplan.newAggregateGroups()
+	require.NoError(t, err)
+	changes := []*binlogdatapb.RowChange{{
+		// Inserts recompute the groups of AVG and COUNT(DISTINCT).
+		After: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("b"), sqltypes.NewVarChar("b"), sqltypes.NewInt64(10), sqltypes.NewInt64(10)}),
+	}, {
+		Before: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(2), sqltypes.NewVarChar("x"), sqltypes.NewVarChar("x"), sqltypes.NULL, sqltypes.NULL}),
+		After:  sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("A"), sqltypes.NewVarChar("A"), sqltypes.NewInt64(20), sqltypes.NewInt64(20)}),
+	}}
+	for _, change := range changes {
+		require.NoError(t, groups.addRowChange(tplan, change))
+	}
+
+	streamer := &fakeRowsStreamer{
+		rows: &binlogdatapb.VStreamRowsResponse{
+			Fields: fields,
+			Rows: sqltypes.RowsToProto3([][]sqltypes.Value{
+				{sqltypes.NewInt64(1), sqltypes.NewVarChar("b"), sqltypes.NewVarChar("b"), sqltypes.NewInt64(10), sqltypes.NewInt64(10)},
+				{sqltypes.NewInt64(1), sqltypes.NewVarChar("A"), sqltypes.NewVarChar("A"), sqltypes.NewInt64(20), sqltypes.NewInt64(20)},
+				{sqltypes.NewInt64(1), sqltypes.NULL, sqltypes.NULL, sqltypes.NewInt64(20), sqltypes.NewInt64(20)},
+				// Rows of other groups are ignored.
+				{sqltypes.NewInt64(3), sqltypes.NewVarChar("z"), sqltypes.NewVarChar("z"), sqltypes.NewInt64(30), sqltypes.NewInt64(30)},
+			}),
+		},
+	}
+	var queries []string
+	err = tplan.recomputeAggregates(context.Background(), streamer, groups, func(sql string) (*sqltypes.Result, error) {
+		queries = append(queries, sql)
+		return &sqltypes.Result{}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"select c1, c2, c2, c3, c3 from t2 where (c3 > 0) and ((c1 <=> 1) or (c1 <=> 2))",
+	}, streamer.queries)
+	assert.Equal(t, []string{
+		"update t1 set mn='A', mx='b', av=if(3 = 0, null, 50 / 3), cd=2 where c1=1",
+		"update t1 set mn=null, mx=null, av=if(0 = 0, null, null / 0), cd=0 where c1=2",
+	}, queries)
+}
+
+func TestRecomputeJoin(t *testing.T) {
+	PrimaryKeyInfos := map[string][]*ColumnInfo{
+		"t1": {&ColumnInfo{Name: "id", IsPK: true}},
+	}
+	input := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "t1",
+			Filter: "select a.id, a.k, b.val as v from a join b on a.k = b.k where a.x = 1",
+		}},
+	}
+	plan, err := buildReplicatorPlan(getSource(input), PrimaryKeyInfos, nil, binlogplayer.NewStats())
+	require.NoError(t, err)
+
+	aFields := sqltypes.MakeTestFields("k|id", "int64|int64")
+	bFields := sqltypes.MakeTestFields("k|val", "int64|varbinary")
+	tplan, err := plan.buildExecutionPlan(&binlogdatapb.FieldEvent{TableName: "b", Fields: bFields})
+	require.NoError(t, err)
+	require.NotNil(t, tplan.Join)
+
+	keys := tplan.newJoinKeys()
+	changes := []*binlogdatapb.RowChange{{
+		Before: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarBinary("x")}),
+		After:  sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(2), sqltypes.NewVarBinary("x")}),
+	}, {
+		// The same key is only recomputed once, and NULL keys join no row.
+		After: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarBinary("y")}),
+	}, {
+		After: sqltypes.RowToProto3([]sqltypes.Value{sqltypes.NULL, sqltypes.NewVarBinary("z")}),
+	}}
+	for _, change := range changes {
+		require.NoError(t, keys.addRowChange(tplan, change))
+	}
+
+	streamer := &fakeRowsStreamer{
+		tableRows: map[string]*binlogdatapb.VStreamRowsResponse{
+			"a": {
+				Fields: aFields,
+				Rows: sqltypes.RowsToProto3([][]sqltypes.Value{
+					{sqltypes.NewInt64(1), sqltypes.NewInt64(10)},
+					{sqltypes.NewInt64(2), sqltypes.NewInt64(20)},
+					{sqltypes.NewInt64(1), sqltypes.NewInt64(11)},
+				}),
+			},
+			"b": {
+				Fields: bFields,
+				Rows: sqltypes.RowsToProto3([][]sqltypes.Value{
+					{sqltypes.NewInt64(1), sqltypes.NewVarBinary("y")},
+				}),
+			},
+		},
+	}
+	var queries []string
+	err = tplan.Join.recompute(context.Background(), streamer, keys, func(sql string) (*sqltypes.Result, error) {
+		queries = append(queries, sql)
+		return &sqltypes.Result{}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"select k, id from a where (x = 1) and k in (1, 2)",
+		"select k, val from b where k in (1, 2)",
+	}, streamer.queries)
+	assert.Equal(t, []string{
+		"delete from t1 where k in (1, 2)",
+		"insert into t1(id, k, v) values (10, 1, 'y'), (11, 1, 'y')",
+	}, queries)
+}
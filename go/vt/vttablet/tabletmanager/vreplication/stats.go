@@ -398,6 +398,18 @@ func (st *vrStats) register() {
 			}
 			return result
 		})
+
+	stats.NewGaugesFuncWithMultiLabels(
+		"VReplicationCopyRowsPerSecond",
+		"vreplication rows copied per second of copy phase per stream",
+		[]string{"source_keyspace", "source_shard", "workflow", "counts"},
+		st.copyRowsPerSecond)
+
+	stats.NewGaugesFuncWithMultiLabels(
+		"VReplicationTableCopyRowsPerSecond",
+		"vreplication rows copied per second of copy phase per table per stream",
+		[]string{"source_keyspace", "source_shard", "workflow", "counts", "table"},
+		st.tableCopyRowsPerSecond)
 	stats.NewCountersFuncWithMultiLabels(
 		"VReplicationPartialQueryCount",
 		"count of partial queries per stream",
@@ -449,6 +461,49 @@ func (st *vrStats) maxReplicationLagSeconds() int64 {
 	return max
 }
 
+// copyRowsPerSecond returns the rate at which each stream copies rows, over
+// the time it has spent in the copy phase.
+func (st *vrStats) copyRowsPerSecond() map[string]int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	result := make(map[string]int64, len(st.controllers))
+	for _, ct := range st.controllers {
+		copyTiming, ok := ct.blpStats.PhaseTimings.Histograms()["copy"]
+		if !ok {
+			continue
+		}
+		result[ct.source.Keyspace+"."+ct.source.Shard+"."+ct.workflow+"."+fmt.Sprintf("%v", ct.id)] = rowsPerSecond(ct.blpStats.CopyRowCount.Get(), copyTiming.Total())
+	}
+	return result
+}
+
+// tableCopyRowsPerSecond returns the rate at which each stream copies the rows
+// of each table, over the time it has spent copying that table.
+func (st *vrStats) tableCopyRowsPerSecond() map[string]int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	result := make(map[string]int64, len(st.controllers))
+	for _, ct := range st.controllers {
+		timings := ct.blpStats.TableCopyTimings.Histograms()
+		for table, count := range ct.blpStats.TableCopyRowCounts.Counts() {
+			timing, ok := timings[table]
+			if table == "" || !ok {
+				continue
+			}
+			result[ct.source.Keyspace+"."+ct.source.Shard+"."+ct.workflow+"."+fmt.Sprintf("%v", ct.id)+"."+table] = rowsPerSecond(count, timing.Total())
+		}
+	}
+	return result
+}
+
+// rowsPerSecond returns the rate of rows over the given number of nanoseconds.
+func rowsPerSecond(rows, elapsed int64) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(rows) / time.Duration(elapsed).Seconds())
+}
+
 func (st *vrStats) status() *EngineStatus {
 	st.mu.Lock()
 	defer st.mu.Unlock()
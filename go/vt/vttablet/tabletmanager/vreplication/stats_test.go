@@ -165,3 +165,34 @@ func TestVReplicationStats(t *testing.T) {
 	blpStats.RecordHeartbeat(tm)
 	require.Equal(t, tm, blpStats.Heartbeat())
 }
+
+func TestVReplicationCopyRowsPerSecond(t *testing.T) {
+	blpStats := binlogplayer.NewStats()
+
+	testStats := &vrStats{}
+	testStats.isOpen = true
+	testStats.controllers = map[int32]*controller{
+		1: {
+			id:       1,
+			workflow: "wf",
+			source: &binlogdata.BinlogSource{
+				Keyspace: "ks",
+				Shard:    "0",
+			},
+			blpStats: blpStats,
+			done:     make(chan struct{}),
+		},
+	}
+	require.Empty(t, testStats.copyRowsPerSecond())
+	require.Empty(t, testStats.tableCopyRowsPerSecond())
+
+	blpStats.PhaseTimings.Add("copy", 4*time.Second)
+	blpStats.CopyRowCount.Add(1000)
+	blpStats.TableCopyTimings.Add("t1", 2*time.Second)
+	blpStats.TableCopyRowCounts.Add("t1", 600)
+	blpStats.TableCopyTimings.Add("t2", time.Second)
+	blpStats.TableCopyRowCounts.Add("t2", 400)
+
+	require.Equal(t, map[string]int64{"ks.0.wf.1": 250}, testStats.copyRowsPerSecond())
+	require.Equal(t, map[string]int64{"ks.0.wf.1.t1": 300, "ks.0.wf.1.t2": 400}, testStats.tableCopyRowsPerSecond())
+}
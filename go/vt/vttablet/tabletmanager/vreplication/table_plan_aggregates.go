@@ -0,0 +1,399 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+
+	"vitess.io/vitess/go/mysql/collations"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/evalengine"
+	"vitess.io/vitess/go/vt/vthash"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// MIN and MAX cannot be maintained incrementally when a row is updated or
+// deleted, and neither can AVG and COUNT(DISTINCT) when a row is inserted.
+// The values of these aggregates are instead recomputed from the rows of
+// the source table, for every group affected by a row change. The rows are
+// read with VStreamRows, which reads the source at or after the position of
+// the event being applied. Every later change of the group triggers another
+// recomputation, so the values converge to those of the source.
+// The incrementally maintained aggregates, COUNT(*) and SUM, are never
+// touched by a recomputation.
+
+// aggregatePlan is the plan to recompute the aggregates of a table.
+type aggregatePlan struct {
+	// from and where are the FROM and WHERE clauses of the filter rule.
+	from  sqlparser.TableExprs
+	where *sqlparser.Where
+	// groupColumns are the source columns of the grouped primary key
+	// of the target table.
+	groupColumns []sqlparser.IdentifierCI
+	columns      []*aggregateColumn
+	// recomputeOnInsert is set if an aggregate must also be recomputed
+	// when a row is inserted.
+	recomputeOnInsert bool
+	// update sets the recomputed aggregates of a group. The values of the
+	// group columns are bound as 'b_' bind variables, and the aggregates
+	// as 'r_' bind variables.
+	update *sqlparser.ParsedQuery
+}
+
+// aggregateColumn is a recomputed aggregate of the target table.
+type aggregateColumn struct {
+	name      sqlparser.IdentifierCI
+	operation operation
+	source    sqlparser.IdentifierCI
+}
+
+// analyzeAggregates validates the plans which have recomputed aggregates.
+// Their groups must be identified by the primary key of the target table,
+// in order to be recomputed. The rows of a group must also be on the source
+// shard of the stream, which is only known when the workflow is created:
+// see ValidateFilterLocality.
+func (tpb *tablePlanBuilder) analyzeAggregates() error {
+	var recomputed bool
+	for _, cexpr := range tpb.colExprs {
+		if cexpr.operation.isRecomputed() {
+			recomputed = true
+			break
+		}
+	}
+	if !recomputed {
+		return nil
+	}
+	if len(tpb.pkCols) == 0 {
+		return fmt.Errorf("min, max, avg and count(distinct) require the primary key of table %v to be grouped", tpb.name)
+	}
+	for _, cexpr := range tpb.pkCols {
+		if _, ok := cexpr.expr.(*sqlparser.ColName); !ok || !cexpr.isGrouped {
+			return fmt.Errorf("min, max, avg and count(distinct) require the primary key column %v to be a grouped column", cexpr.colName)
+		}
+	}
+	return nil
+}
+
+// generateAggregatePlan returns the plan to recompute the aggregates of the
+// table, or nil if it has none.
+func (tpb *tablePlanBuilder) generateAggregatePlan() *aggregatePlan {
+	ap := &aggregatePlan{
+		from:  tpb.sendSelect.From,
+		where: tpb.sendSelect.Where,
+	}
+	bvf := &bindvarFormatter{}
+	buf := sqlparser.NewTrackedBuffer(bvf.formatter)
+	buf.Myprintf("update %v set ", tpb.name)
+	separator := ""
+	for _, cexpr := range tpb.colExprs {
+		if !cexpr.operation.isRecomputed() || tpb.isColumnGenerated(cexpr.colName) {
+			continue
+		}
+		col := &aggregateColumn{
+			name:      cexpr.colName,
+			operation: cexpr.operation,
+			source:    cexpr.expr.(*sqlparser.ColName).Name,
+		}
+		ap.columns = append(ap.columns, col)
+		buf.Myprintf("%s%v=", separator, cexpr.colName)
+		separator = ", "
+		switch cexpr.operation {
+		case opMin, opMax, opCountDistinct:
+			buf.WriteArg(":", "r_"+cexpr.colName.String())
+		case opAvg:
+			// The division is left to MySQL, for the result to have the
+			// precision of AVG.
+			buf.WriteString("if(")
+			buf.WriteArg(":", "r_"+cexpr.colName.String()+"_count")
+			buf.WriteString(" = 0, null, ")
+			buf.WriteArg(":", "r_"+cexpr.colName.String()+"_sum")
+			buf.WriteString(" / ")
+			buf.WriteArg(":", "r_"+cexpr.colName.String()+"_count")
+			buf.WriteString(")")
+		}
+		if cexpr.operation == opAvg || cexpr.operation == opCountDistinct {
+			ap.recomputeOnInsert = true
+		}
+	}
+	if len(ap.columns) == 0 {
+		return nil
+	}
+	buf.WriteString(" where ")
+	bvf.mode = bvBefore
+	separator = ""
+	for _, cexpr := range tpb.pkCols {
+		buf.Myprintf("%s%v=%v", separator, cexpr.colName, cexpr.expr)
+		separator = " and "
+		ap.groupColumns = append(ap.groupColumns, cexpr.expr.(*sqlparser.ColName).Name)
+	}
+	ap.update = buf.ParsedQuery()
+	return ap
+}
+
+// aggregateGroups is the set of groups whose aggregates are to be
+// recomputed.
+type aggregateGroups struct {
+	// indices are the indices of the group columns in the rows sent
+	// by the source.
+	indices []int
+	// fields are the fields of the group columns.
+	fields []*querypb.Field
+	hasher vthash.Hasher
+	keys   map[vthash.Hash]int
+	values [][]sqltypes.Value
+}
+
+// newAggregateGroups returns an empty set of the groups of the table plan.
+func (tp *TablePlan) newAggregateGroups() (*aggregateGroups, error) {
+	groups := &aggregateGroups{
+		hasher: vthash.New(),
+		keys:   make(map[vthash.Hash]int),
+	}
+	for _, col := range tp.Aggregates.groupColumns {
+		index := -1
+		for i, field := range tp.Fields {
+			if col.EqualString(field.Name) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("group column %v of table %s not found in fields", col, tp.TargetName)
+		}
+		groups.indices = append(groups.indices, index)
+		groups.fields = append(groups.fields, tp.Fields[index])
+	}
+	return groups, nil
+}
+
+// addRowChange adds the groups whose aggregates are affected by the row
+// change.
+func (groups *aggregateGroups) addRowChange(tp *TablePlan, rowChange *binlogdatapb.RowChange) error {
+	if rowChange.Before != nil {
+		if err := groups.addRow(sqltypes.MakeRowTrusted(tp.Fields, rowChange.Before)); err != nil {
+			return err
+		}
+	}
+	if rowChange.After != nil && (rowChange.Before != nil || tp.Aggregates.recomputeOnInsert) {
+		if err := groups.addRow(sqltypes.MakeRowTrusted(tp.Fields, rowChange.After)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRow adds the group of a row sent by the source.
+func (groups *aggregateGroups) addRow(row []sqltypes.Value) error {
+	group := make([]sqltypes.Value, 0, len(groups.indices))
+	for _, index := range groups.indices {
+		group = append(group, row[index])
+	}
+	key, err := groups.hash(group)
+	if err != nil {
+		return err
+	}
+	if _, ok := groups.keys[key]; ok {
+		return nil
+	}
+	groups.keys[key] = len(groups.values)
+	groups.values = append(groups.values, group)
+	return nil
+}
+
+// find returns the index of the group of the given values.
+func (groups *aggregateGroups) find(group []sqltypes.Value) (int, bool, error) {
+	key, err := groups.hash(group)
+	if err != nil {
+		return 0, false, err
+	}
+	i, ok := groups.keys[key]
+	return i, ok, nil
+}
+
+// hash returns a key which is the same for the values which MySQL
+// considers equal in a group by.
+func (groups *aggregateGroups) hash(group []sqltypes.Value) (vthash.Hash, error) {
+	groups.hasher.Reset()
+	for i, v := range group {
+		if err := hashGroupValue(&groups.hasher, v, groups.fields[i]); err != nil {
+			return vthash.Hash{}, err
+		}
+	}
+	return groups.hasher.Sum128(), nil
+}
+
+func hashGroupValue(hasher *vthash.Hasher, v sqltypes.Value, field *querypb.Field) error {
+	collation := collations.ID(field.Charset)
+	switch {
+	case sqltypes.IsNumber(field.Type), sqltypes.IsBinary(field.Type),
+		sqltypes.IsText(field.Type) && collation.Get() != nil:
+		return evalengine.NullsafeHashcode128(hasher, v, collation, field.Type)
+	case v.IsNull():
+		hasher.Write16(0)
+	default:
+		// Other values are sent by the source in their canonical form.
+		hasher.Write16(1)
+		hasher.Write(v.Raw())
+	}
+	return nil
+}
+
+// aggregateState is the running value of a recomputed aggregate.
+type aggregateState struct {
+	value    sqltypes.Value
+	count    int64
+	distinct map[vthash.Hash]bool
+}
+
+// add adds the value of a row of the group to the aggregate.
+func (state *aggregateState) add(col *aggregateColumn, v sqltypes.Value, field *querypb.Field, hasher *vthash.Hasher) error {
+	if v.IsNull() {
+		return nil
+	}
+	var err error
+	switch col.operation {
+	case opMin:
+		state.value, err = evalengine.Min(state.value, v, collations.ID(field.Charset))
+	case opMax:
+		state.value, err = evalengine.Max(state.value, v, collations.ID(field.Charset))
+	case opAvg:
+		sumType := sqltypes.Decimal
+		if sqltypes.IsFloat(field.Type) {
+			sumType = sqltypes.Float64
+		}
+		state.value, err = evalengine.NullSafeAdd(state.value, v, sumType)
+		state.count++
+	case opCountDistinct:
+		hasher.Reset()
+		if err := hashGroupValue(hasher, v, field); err != nil {
+			return err
+		}
+		if state.distinct == nil {
+			state.distinct = make(map[vthash.Hash]bool)
+		}
+		state.distinct[hasher.Sum128()] = true
+	}
+	return err
+}
+
+// bind sets the bind variables of the aggregate for the update of its
+// group.
+func (state *aggregateState) bind(col *aggregateColumn, bindvars map[string]*querypb.BindVariable) {
+	name := "r_" + col.name.String()
+	switch col.operation {
+	case opMin, opMax:
+		bindvars[name] = sqltypes.ValueBindVariable(state.value)
+	case opAvg:
+		bindvars[name+"_sum"] = sqltypes.ValueBindVariable(state.value)
+		bindvars[name+"_count"] = sqltypes.Int64BindVariable(state.count)
+	case opCountDistinct:
+		bindvars[name] = sqltypes.Int64BindVariable(int64(len(state.distinct)))
+	}
+}
+
+// recomputeQuery returns the query which reads the rows of the groups from
+// the source table.
+func (ap *aggregatePlan) recomputeQuery(groups *aggregateGroups) string {
+	buf := sqlparser.NewTrackedBuffer(nil)
+	buf.WriteString("select ")
+	separator := ""
+	for _, col := range ap.groupColumns {
+		buf.Myprintf("%s%v", separator, col)
+		separator = ", "
+	}
+	for _, col := range ap.columns {
+		buf.Myprintf("%s%v", separator, col.source)
+	}
+	buf.Myprintf(" from %v where ", ap.from)
+	if ap.where != nil {
+		buf.Myprintf("(%v) and ", ap.where.Expr)
+	}
+	buf.WriteString("(")
+	for i, group := range groups.values {
+		if i > 0 {
+			buf.WriteString(" or ")
+		}
+		buf.WriteString("(")
+		for j, col := range ap.groupColumns {
+			if j > 0 {
+				buf.WriteString(" and ")
+			}
+			buf.Myprintf("%v <=> ", col)
+			group[j].EncodeSQL(buf)
+		}
+		buf.WriteString(")")
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// recomputeAggregates recomputes the aggregates of the groups from the rows
+// of the source table, and updates them in the target table.
+func (tp *TablePlan) recomputeAggregates(ctx context.Context, vsClient VStreamerClient, groups *aggregateGroups, executor func(string) (*sqltypes.Result, error)) error {
+	if len(groups.values) == 0 {
+		return nil
+	}
+	ap := tp.Aggregates
+	states := make([][]aggregateState, len(groups.values))
+	for i := range states {
+		states[i] = make([]aggregateState, len(ap.columns))
+	}
+	ngroup := len(ap.groupColumns)
+	hasher := vthash.New()
+	var fields []*querypb.Field
+	err := vsClient.VStreamRows(ctx, ap.recomputeQuery(groups), nil, func(rows *binlogdatapb.VStreamRowsResponse) error {
+		if len(rows.Fields) > 0 {
+			fields = rows.Fields
+		}
+		for _, row := range rows.Rows {
+			vals := sqltypes.MakeRowTrusted(fields, row)
+			i, ok, err := groups.find(vals[:ngroup])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			for j, col := range ap.columns {
+				if err := states[i][j].add(col, vals[ngroup+j], fields[ngroup+j], &hasher); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, group := range groups.values {
+		bindvars := make(map[string]*querypb.BindVariable, ngroup+len(ap.columns)+1)
+		for j, col := range ap.groupColumns {
+			bindvars["b_"+col.String()] = sqltypes.ValueBindVariable(group[j])
+		}
+		for j, col := range ap.columns {
+			states[i][j].bind(col, bindvars)
+		}
+		if _, err := execParsedQuery(ap.update, bindvars, executor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -66,6 +66,8 @@ type colExpr struct {
 	// operation==opExpr: full expression is set
 	// operation==opCount: nothing is set.
 	// operation==opSum: for 'sum(a)', expr is set to 'a'.
+	// operation==opMin, opMax, opAvg, opCountDistinct: for 'min(a)',
+	// 'max(a)', 'avg(a)' and 'count(distinct a)', expr is set to 'a'.
 	operation operation
 	// expr stores the expected field name from vstreamer and dictates
 	// the generated bindvar names, like a_col or b_col.
@@ -87,8 +89,23 @@ const (
 	opExpr = operation(iota)
 	opCount
 	opSum
+	opMin
+	opMax
+	opAvg
+	opCountDistinct
 )
 
+// isRecomputed returns true if the value of the aggregate cannot be
+// maintained incrementally, and is recomputed from the rows of the source
+// table when they change.
+func (op operation) isRecomputed() bool {
+	switch op {
+	case opMin, opMax, opAvg, opCountDistinct:
+		return true
+	}
+	return false
+}
+
 // insertType describes the type of insert statement to generate.
 // Please refer to TestBuildPlayerPlan for examples.
 type insertType int
@@ -152,6 +169,25 @@ func buildReplicatorPlan(source *binlogdatapb.BinlogSource, colInfoMap map[strin
 		if !ok {
 			return nil, fmt.Errorf("table %s not found in schema", tableName)
 		}
+		jp, err := analyzeJoin(tableName, rule)
+		if err != nil {
+			return nil, err
+		}
+		if jp != nil {
+			// The rows of a join are recomputed from the source tables,
+			// and the lastpk of its copy is not needed to filter the
+			// events of the copy phase.
+			tablePlans := jp.tablePlans(stats)
+			for _, tablePlan := range tablePlans {
+				if dup, ok := plan.TablePlans[tablePlan.SendRule.Match]; ok {
+					return nil, fmt.Errorf("more than one target for source table %s: %s and %s", tablePlan.SendRule.Match, dup.TargetName, tableName)
+				}
+				plan.VStreamFilter.Rules = append(plan.VStreamFilter.Rules, tablePlan.SendRule)
+				plan.TablePlans[tablePlan.SendRule.Match] = tablePlan
+			}
+			plan.TargetTables[tableName] = tablePlans[0]
+			continue
+		}
 		tablePlan, err := buildTablePlan(tableName, rule, colInfos, lastpk, stats, source)
 		if err != nil {
 			return nil, err
@@ -194,21 +230,10 @@ func MatchTable(tableName string, filter *binlogdatapb.Filter) (*binlogdatapb.Ru
 func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*ColumnInfo, lastpk *sqltypes.Result,
 	stats *binlogplayer.Stats, source *binlogdatapb.BinlogSource) (*TablePlan, error) {
 
-	filter := rule.Filter
-	query := filter
-	// generate equivalent select statement if filter is empty or a keyrange.
-	switch {
-	case filter == "":
-		buf := sqlparser.NewTrackedBuffer(nil)
-		buf.Myprintf("select * from %v", sqlparser.NewIdentifierCS(tableName))
-		query = buf.String()
-	case key.IsValidKeyRange(filter):
-		buf := sqlparser.NewTrackedBuffer(nil)
-		buf.Myprintf("select * from %v where in_keyrange(%v)", sqlparser.NewIdentifierCS(tableName), sqlparser.NewStrLiteral(filter))
-		query = buf.String()
-	case filter == ExcludeStr:
+	if rule.Filter == ExcludeStr {
 		return nil, nil
 	}
+	query := filterQuery(tableName, rule.Filter)
 	sel, fromTable, err := analyzeSelectFrom(query)
 	if err != nil {
 		return nil, err
@@ -284,6 +309,9 @@ func buildTablePlan(tableName string, rule *binlogdatapb.Rule, colInfos []*Colum
 	if err := tpb.analyzePK(pkColsInfo); err != nil {
 		return nil, err
 	}
+	if err := tpb.analyzeAggregates(); err != nil {
+		return nil, err
+	}
 
 	sourceKeyTargetColumnNames, err := textutil.SplitUnescape(rule.SourceUniqueKeyTargetColumns, ",")
 	if err != nil {
@@ -366,7 +394,24 @@ func (tpb *tablePlanBuilder) generate() *TablePlan {
 		TablePlanBuilder:        tpb,
 		PartialInserts:          make(map[string]*sqlparser.ParsedQuery, 0),
 		PartialUpdates:          make(map[string]*sqlparser.ParsedQuery, 0),
+		Aggregates:              tpb.generateAggregatePlan(),
+	}
+}
+
+// filterQuery returns the select statement equivalent to the filter of a
+// rule matching tableName, which may also be empty or a keyrange.
+func filterQuery(tableName, filter string) string {
+	switch {
+	case filter == "":
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("select * from %v", sqlparser.NewIdentifierCS(tableName))
+		return buf.String()
+	case key.IsValidKeyRange(filter):
+		buf := sqlparser.NewTrackedBuffer(nil)
+		buf.Myprintf("select * from %v where in_keyrange(%v)", sqlparser.NewIdentifierCS(tableName), sqlparser.NewStrLiteral(filter))
+		return buf.String()
 	}
+	return filter
 }
 
 func analyzeSelectFrom(query string) (sel *sqlparser.Select, from string, err error) {
@@ -448,33 +493,45 @@ func (tpb *tablePlanBuilder) analyzeExpr(selExpr sqlparser.SelectExpr) (*colExpr
 		}
 	}
 	if expr, ok := aliased.Expr.(sqlparser.AggrFunc); ok {
-		if expr.IsDistinct() {
+		fname := strings.ToLower(expr.AggrName())
+		if expr.IsDistinct() && fname != "count" {
 			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
 		}
-		switch fname := strings.ToLower(expr.AggrName()); fname {
+		switch fname {
 		case "count":
-			if _, ok := expr.(*sqlparser.CountStar); !ok {
-				return nil, fmt.Errorf("only count(*) is supported: %v", sqlparser.String(expr))
+			if _, ok := expr.(*sqlparser.CountStar); ok {
+				cexpr.operation = opCount
+				return cexpr, nil
 			}
-			cexpr.operation = opCount
-			return cexpr, nil
-		case "sum":
-			if len(expr.GetArgs()) != 1 {
-				return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
-			}
-			innerCol, ok := expr.GetArg().(*sqlparser.ColName)
-			if !ok {
-				return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
-			}
-			if !innerCol.Qualifier.IsEmpty() {
-				return nil, fmt.Errorf("unsupported qualifier for column: %v", sqlparser.String(innerCol))
+			if !expr.IsDistinct() {
+				return nil, fmt.Errorf("only count(*) and count(distinct) are supported: %v", sqlparser.String(expr))
 			}
+			cexpr.operation = opCountDistinct
+		case "sum":
 			cexpr.operation = opSum
-			cexpr.expr = innerCol
-			tpb.addCol(innerCol.Name)
-			cexpr.references[innerCol.Name.String()] = true
-			return cexpr, nil
+		case "min":
+			cexpr.operation = opMin
+		case "max":
+			cexpr.operation = opMax
+		case "avg":
+			cexpr.operation = opAvg
+		default:
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
+		}
+		if len(expr.GetArgs()) != 1 {
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
+		}
+		innerCol, ok := expr.GetArg().(*sqlparser.ColName)
+		if !ok {
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
 		}
+		if !innerCol.Qualifier.IsEmpty() {
+			return nil, fmt.Errorf("unsupported qualifier for column: %v", sqlparser.String(innerCol))
+		}
+		cexpr.expr = innerCol
+		tpb.addCol(innerCol.Name)
+		cexpr.references[innerCol.Name.String()] = true
+		return cexpr, nil
 	}
 	err := sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
 		switch node := node.(type) {
@@ -698,6 +755,10 @@ func (tpb *tablePlanBuilder) generateValuesPart(buf *sqlparser.TrackedBuffer, bv
 		case opSum:
 			// NULL values must be treated as 0 for SUM.
 			buf.Myprintf("ifnull(%v, 0)", cexpr.expr)
+		case opMin, opMax, opAvg:
+			buf.Myprintf("%v", cexpr.expr)
+		case opCountDistinct:
+			buf.Myprintf("if(%v is null, 0, 1)", cexpr.expr)
 		}
 	}
 	buf.Myprintf(")")
@@ -721,6 +782,10 @@ func (tpb *tablePlanBuilder) generateSelectPart(buf *sqlparser.TrackedBuffer, bv
 			buf.WriteString("1")
 		case opSum:
 			buf.Myprintf("ifnull(%v, 0)", cexpr.expr)
+		case opMin, opMax, opAvg:
+			buf.Myprintf("%v", cexpr.expr)
+		case opCountDistinct:
+			buf.Myprintf("if(%v is null, 0, 1)", cexpr.expr)
 		}
 	}
 	buf.WriteString(" from dual where ")
@@ -758,6 +823,13 @@ func (tpb *tablePlanBuilder) generateOnDupPart(buf *sqlparser.TrackedBuffer) *sq
 		case opSum:
 			buf.Myprintf("%v", cexpr.colName)
 			buf.Myprintf("+ifnull(values(%v), 0)", cexpr.colName)
+		case opMin:
+			buf.Myprintf("least(ifnull(%v, values(%v)), ifnull(values(%v), %v))", cexpr.colName, cexpr.colName, cexpr.colName, cexpr.colName)
+		case opMax:
+			buf.Myprintf("greatest(ifnull(%v, values(%v)), ifnull(values(%v), %v))", cexpr.colName, cexpr.colName, cexpr.colName, cexpr.colName)
+		case opAvg, opCountDistinct:
+			// Recomputed from the source table after the insert.
+			buf.Myprintf("%v", cexpr.colName)
 		}
 	}
 	return buf.ParsedQuery()
@@ -809,6 +881,9 @@ func (tpb *tablePlanBuilder) generateUpdateStatement() *sqlparser.ParsedQuery {
 			buf.Myprintf("-ifnull(%v, 0)", cexpr.expr)
 			bvf.mode = bvAfter
 			buf.Myprintf("+ifnull(%v, 0)", cexpr.expr)
+		case opMin, opMax, opAvg, opCountDistinct:
+			// Recomputed from the source table after the update.
+			buf.Myprintf("%v", cexpr.colName)
 		}
 	}
 	tpb.generateWhere(buf, bvf)
@@ -839,6 +914,9 @@ func (tpb *tablePlanBuilder) generateDeleteStatement() *sqlparser.ParsedQuery {
 				buf.Myprintf("%v-1", cexpr.colName)
 			case opSum:
 				buf.Myprintf("%v-ifnull(%v, 0)", cexpr.colName, cexpr.expr)
+			case opMin, opMax, opAvg, opCountDistinct:
+				// Recomputed from the source table after the delete.
+				buf.Myprintf("%v", cexpr.colName)
 			}
 		}
 		tpb.generateWhere(buf, bvf)
@@ -0,0 +1,471 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vthash"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/vstreamer"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// A join filter, like
+//   select a.id, a.k, b.name from a join b on a.k = b.k where a.x = 1
+// materializes the inner equi-join of two source tables. The tables are
+// streamed separately, so the joined rows cannot be computed from the row
+// events alone. The rows of the join keys changed by an event are instead
+// recomputed: they are deleted from the target table, and the rows of both
+// source tables with the same keys are read with VStreamRows, joined, and
+// inserted again. The target table must have a column holding the join key.
+// Every later change of a key recomputes its rows again, so the target
+// converges to the join of the source tables.
+// The conjuncts of the WHERE clause are sent to the vstreamer of the table
+// whose columns they reference, and the join key of both tables must be on
+// the same source shard, which ValidateFilterLocality checks.
+
+// joinPlan is the plan of a join filter. It is shared by the table plans of
+// both source tables.
+type joinPlan struct {
+	target sqlparser.IdentifierCS
+	// sides are the joined tables, in the order of the FROM clause.
+	// The target table is copied from the first one.
+	sides [2]*joinSide
+	// keyColumn is the column of the target table holding the join key.
+	keyColumn sqlparser.IdentifierCI
+	// columns are the columns of the target table, and projections
+	// the source column of each of them.
+	columns     []sqlparser.IdentifierCI
+	projections []joinProjection
+}
+
+// joinSide is one of the tables of a join.
+type joinSide struct {
+	table sqlparser.IdentifierCS
+	// qualifier is the name the filter refers to the table by.
+	qualifier sqlparser.IdentifierCS
+	// columns are the columns read from the table. The first one is the
+	// join key.
+	columns []sqlparser.IdentifierCI
+	where   sqlparser.Expr
+}
+
+// joinProjection is the index of a column in the columns of a side.
+type joinProjection struct {
+	side  int
+	index int
+}
+
+// analyzeJoin returns the plan of the filter of a rule if it is a join, or
+// nil if it reads a single table.
+func analyzeJoin(tableName string, rule *binlogdatapb.Rule) (*joinPlan, error) {
+	if rule.Filter == ExcludeStr {
+		return nil, nil
+	}
+	statement, err := sqlparser.Parse(filterQuery(tableName, rule.Filter))
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := statement.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 {
+		return nil, nil
+	}
+	join, ok := sel.From[0].(*sqlparser.JoinTableExpr)
+	if !ok {
+		return nil, nil
+	}
+	if join.Join != sqlparser.NormalJoinType || join.Condition == nil || join.Condition.On == nil {
+		return nil, fmt.Errorf("only inner joins with an on condition are supported: %v", sqlparser.String(sel))
+	}
+	if sel.Distinct || sel.GroupBy != nil || sel.Having != nil || sel.OrderBy != nil || sel.Limit != nil {
+		return nil, fmt.Errorf("unsupported clause in join: %v", sqlparser.String(sel))
+	}
+	jp := &joinPlan{target: sqlparser.NewIdentifierCS(tableName)}
+	for i, expr := range []sqlparser.TableExpr{join.LeftExpr, join.RightExpr} {
+		aliased, ok := expr.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
+		}
+		table := sqlparser.GetTableName(aliased.Expr)
+		if table.IsEmpty() {
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(expr))
+		}
+		side := &joinSide{table: table, qualifier: table}
+		if !aliased.As.IsEmpty() {
+			side.qualifier = aliased.As
+		}
+		jp.sides[i] = side
+	}
+	if jp.sides[0].table == jp.sides[1].table {
+		return nil, fmt.Errorf("a table cannot be joined with itself: %v", sqlparser.String(sel))
+	}
+
+	// The join keys are the first columns of their tables.
+	on, ok := join.Condition.On.(*sqlparser.ComparisonExpr)
+	if !ok || on.Operator != sqlparser.EqualOp {
+		return nil, fmt.Errorf("the join condition must be an equality of columns: %v", sqlparser.String(join.Condition.On))
+	}
+	var keys [2]bool
+	for _, expr := range []sqlparser.Expr{on.Left, on.Right} {
+		col, ok := expr.(*sqlparser.ColName)
+		if !ok {
+			return nil, fmt.Errorf("the join condition must be an equality of columns: %v", sqlparser.String(join.Condition.On))
+		}
+		i, err := jp.sideOf(col)
+		if err != nil {
+			return nil, err
+		}
+		if keys[i] {
+			return nil, fmt.Errorf("the join condition must compare the columns of both tables: %v", sqlparser.String(join.Condition.On))
+		}
+		keys[i] = true
+		jp.sides[i].addColumn(col.Name)
+	}
+
+	for _, selExpr := range sel.SelectExprs {
+		aliased, ok := selExpr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, fmt.Errorf("unexpected: %v", sqlparser.String(selExpr))
+		}
+		col, ok := aliased.Expr.(*sqlparser.ColName)
+		if !ok {
+			return nil, fmt.Errorf("only columns can be selected from a join: %v", sqlparser.String(selExpr))
+		}
+		i, err := jp.sideOf(col)
+		if err != nil {
+			return nil, err
+		}
+		name := aliased.As
+		if name.IsEmpty() {
+			name = col.Name
+		}
+		index := jp.sides[i].addColumn(col.Name)
+		if index == 0 && jp.keyColumn.IsEmpty() {
+			jp.keyColumn = name
+		}
+		jp.columns = append(jp.columns, name)
+		jp.projections = append(jp.projections, joinProjection{side: i, index: index})
+	}
+	if jp.keyColumn.IsEmpty() {
+		return nil, fmt.Errorf("the join key must be selected: %v", sqlparser.String(sel))
+	}
+
+	if sel.Where != nil {
+		for _, expr := range sqlparser.SplitAndExpression(nil, sel.Where.Expr) {
+			i, err := jp.sideOfExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			jp.sides[i].where = sqlparser.AndExpressions(jp.sides[i].where, unqualifyColumns(expr))
+		}
+	}
+	return jp, nil
+}
+
+// ValidateJoin checks that a join filter is supported, and that the
+// conditions sent to the vstreamers of its tables can be evaluated by them,
+// before the workflow using it is created.
+func ValidateJoin(filter string) error {
+	jp, err := analyzeJoin("", &binlogdatapb.Rule{Filter: filter})
+	if err != nil || jp == nil {
+		return err
+	}
+	for _, side := range jp.sides {
+		if err := vstreamer.ValidateWhere(sqlparser.NewWhere(sqlparser.WhereClause, side.where)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sideOf returns the index of the table of a column.
+func (jp *joinPlan) sideOf(col *sqlparser.ColName) (int, error) {
+	if col.Qualifier.Qualifier.IsEmpty() {
+		for i, side := range jp.sides {
+			if col.Qualifier.Name == side.qualifier {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("column %v must be qualified by one of the joined tables", sqlparser.String(col))
+}
+
+// sideOfExpr returns the index of the table whose columns the expression
+// references. An expression without columns is evaluated on the first
+// table.
+func (jp *joinPlan) sideOfExpr(expr sqlparser.Expr) (int, error) {
+	side := -1
+	err := sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		col, ok := node.(*sqlparser.ColName)
+		if !ok {
+			return true, nil
+		}
+		i, err := jp.sideOf(col)
+		if err != nil {
+			return false, err
+		}
+		if side >= 0 && side != i {
+			return false, fmt.Errorf("a condition of a join can only reference the columns of one table: %v", sqlparser.String(expr))
+		}
+		side = i
+		return true, nil
+	}, expr)
+	if err != nil {
+		return 0, err
+	}
+	if side < 0 {
+		side = 0
+	}
+	return side, nil
+}
+
+// unqualifyColumns returns a copy of the expression whose columns are not
+// qualified by their table, for the vstreamer of the table.
+func unqualifyColumns(expr sqlparser.Expr) sqlparser.Expr {
+	expr = sqlparser.CloneExpr(expr)
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			col.Qualifier = sqlparser.TableName{}
+		}
+		return true, nil
+	}, expr)
+	return expr
+}
+
+// addColumn adds a column to the columns read from the table, unless it is
+// already read, and returns its index.
+func (side *joinSide) addColumn(col sqlparser.IdentifierCI) int {
+	for i, c := range side.columns {
+		if c.Equal(col) {
+			return i
+		}
+	}
+	side.columns = append(side.columns, col)
+	return len(side.columns) - 1
+}
+
+// selectQuery returns the query reading the rows of the table. If keys is
+// not nil, only the rows of these join keys are read.
+func (side *joinSide) selectQuery(keys []sqltypes.Value) string {
+	buf := sqlparser.NewTrackedBuffer(nil)
+	buf.WriteString("select ")
+	separator := ""
+	for _, col := range side.columns {
+		buf.Myprintf("%s%v", separator, col)
+		separator = ", "
+	}
+	buf.Myprintf(" from %v", side.table)
+	separator = " where "
+	if side.where != nil {
+		buf.Myprintf("%s(%v)", separator, side.where)
+		separator = " and "
+	}
+	if keys != nil {
+		buf.Myprintf("%s%v in ", separator, side.columns[0])
+		encodeValues(buf, keys)
+	}
+	return buf.String()
+}
+
+// encodeValues writes a parenthesized list of values.
+func encodeValues(buf *sqlparser.TrackedBuffer, values []sqltypes.Value) {
+	buf.WriteString("(")
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		v.EncodeSQL(buf)
+	}
+	buf.WriteString(")")
+}
+
+// tablePlans returns the table plans of the joined tables, in the order of
+// the FROM clause.
+func (jp *joinPlan) tablePlans(stats *binlogplayer.Stats) []*TablePlan {
+	plans := make([]*TablePlan, 0, len(jp.sides))
+	for _, side := range jp.sides {
+		plans = append(plans, &TablePlan{
+			TargetName: jp.target.String(),
+			SendRule: &binlogdatapb.Rule{
+				Match:  side.table.String(),
+				Filter: side.selectQuery(nil),
+			},
+			Stats: stats,
+			Join:  jp,
+		})
+	}
+	return plans
+}
+
+// joinKeys is the set of join keys whose rows are to be recomputed.
+type joinKeys struct {
+	// field is the field of the join key in the rows sent by the source.
+	field  *querypb.Field
+	hasher vthash.Hasher
+	seen   map[vthash.Hash]bool
+	values []sqltypes.Value
+}
+
+// newJoinKeys returns an empty set of the join keys of the rows of one of
+// the joined tables.
+func (tp *TablePlan) newJoinKeys() *joinKeys {
+	return &joinKeys{
+		field:  tp.Fields[0],
+		hasher: vthash.New(),
+		seen:   make(map[vthash.Hash]bool),
+	}
+}
+
+// addRowChange adds the join keys of the rows before and after the row
+// change.
+func (keys *joinKeys) addRowChange(tp *TablePlan, rowChange *binlogdatapb.RowChange) error {
+	if rowChange.Before != nil {
+		if err := keys.addRow(sqltypes.MakeRowTrusted(tp.Fields, rowChange.Before)); err != nil {
+			return err
+		}
+	}
+	if rowChange.After != nil {
+		if err := keys.addRow(sqltypes.MakeRowTrusted(tp.Fields, rowChange.After)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addRow adds the join key of a row sent by the source. A NULL key joins no
+// row, and is skipped.
+func (keys *joinKeys) addRow(row []sqltypes.Value) error {
+	v := row[0]
+	if v.IsNull() {
+		return nil
+	}
+	keys.hasher.Reset()
+	if err := hashGroupValue(&keys.hasher, v, keys.field); err != nil {
+		return err
+	}
+	key := keys.hasher.Sum128()
+	if keys.seen[key] {
+		return nil
+	}
+	keys.seen[key] = true
+	keys.values = append(keys.values, v)
+	return nil
+}
+
+// recompute replaces the rows of the join keys in the target table with
+// the join of the rows of the source tables.
+func (jp *joinPlan) recompute(ctx context.Context, vsClient VStreamerClient, keys *joinKeys, executor func(string) (*sqltypes.Result, error)) error {
+	if len(keys.values) == 0 {
+		return nil
+	}
+	var (
+		fields [2][]*querypb.Field
+		rows   [2][][]sqltypes.Value
+	)
+	for i, side := range jp.sides {
+		err := vsClient.VStreamRows(ctx, side.selectQuery(keys.values), nil, func(resp *binlogdatapb.VStreamRowsResponse) error {
+			if len(resp.Fields) > 0 {
+				fields[i] = resp.Fields
+			}
+			for _, row := range resp.Rows {
+				rows[i] = append(rows[i], sqltypes.MakeRowTrusted(fields[i], row))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	buf := sqlparser.NewTrackedBuffer(nil)
+	buf.Myprintf("delete from %v where %v in ", jp.target, jp.keyColumn)
+	encodeValues(buf, keys.values)
+	if _, err := executor(buf.String()); err != nil {
+		return err
+	}
+	if len(rows[0]) == 0 || len(rows[1]) == 0 {
+		return nil
+	}
+
+	// The keys are compared as the key of the first table.
+	keyField := fields[0][0]
+	hasher := vthash.New()
+	hashKey := func(v sqltypes.Value) (vthash.Hash, error) {
+		hasher.Reset()
+		if err := hashGroupValue(&hasher, v, keyField); err != nil {
+			return vthash.Hash{}, err
+		}
+		return hasher.Sum128(), nil
+	}
+	matches := make(map[vthash.Hash][]int, len(rows[1]))
+	for j, row := range rows[1] {
+		if row[0].IsNull() {
+			continue
+		}
+		key, err := hashKey(row[0])
+		if err != nil {
+			return err
+		}
+		matches[key] = append(matches[key], j)
+	}
+
+	buf = sqlparser.NewTrackedBuffer(nil)
+	buf.Myprintf("insert into %v(", jp.target)
+	for i, col := range jp.columns {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.Myprintf("%v", col)
+	}
+	buf.WriteString(") values ")
+	joined := 0
+	for _, left := range rows[0] {
+		if left[0].IsNull() {
+			continue
+		}
+		key, err := hashKey(left[0])
+		if err != nil {
+			return err
+		}
+		for _, j := range matches[key] {
+			row := [2][]sqltypes.Value{left, rows[1][j]}
+			if joined > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString("(")
+			for i, proj := range jp.projections {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				row[proj.side][proj.index].EncodeSQL(buf)
+			}
+			buf.WriteString(")")
+			joined++
+		}
+	}
+	if joined == 0 {
+		return nil
+	}
+	_, err := executor(buf.String())
+	return err
+}
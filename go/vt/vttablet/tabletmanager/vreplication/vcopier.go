@@ -23,6 +23,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/protobuf/encoding/prototext"
@@ -44,6 +45,9 @@ import (
 type vcopier struct {
 	vr               *vreplicator
 	throttlerAppName string
+	// dbClientMu serializes the use of the connection of the vreplicator
+	// by the tables copied concurrently.
+	dbClientMu sync.Mutex
 }
 
 // vcopierCopyTask stores the args and lifecycle hooks of a copy task.
@@ -134,6 +138,7 @@ type vcopierCopyWorker struct {
 	copyStateInsert *sqlparser.ParsedQuery
 	isOpen          bool
 	pkfields        []*querypb.Field
+	sourceVStreamer VStreamerClient
 	sqlbuffer       bytes2.Buffer
 	tablePlan       *TablePlan
 }
@@ -203,10 +208,12 @@ func newVCopierCopyWorkQueue(
 func newVCopierCopyWorker(
 	closeDbClient bool,
 	vdbClient *vdbClient,
+	sourceVStreamer VStreamerClient,
 ) *vcopierCopyWorker {
 	return &vcopierCopyWorker{
-		closeDbClient: closeDbClient,
-		vdbClient:     vdbClient,
+		closeDbClient:   closeDbClient,
+		vdbClient:       vdbClient,
+		sourceVStreamer: sourceVStreamer,
 	}
 }
 
@@ -292,34 +299,52 @@ func (vc *vcopier) initTablesForCopy(ctx context.Context) error {
 // primary key that was copied. A nil Result means that nothing has been copied.
 // A table that was fully copied is removed from copyState.
 func (vc *vcopier) copyNext(ctx context.Context, settings binlogplayer.VRSettings) error {
-	qr, err := vc.vr.dbClient.Execute(fmt.Sprintf("select table_name, lastpk from _vt.copy_state where vrepl_id = %d and id in (select max(id) from _vt.copy_state group by vrepl_id, table_name)", vc.vr.id))
+	tables, copyState, err := vc.readCopyState()
 	if err != nil {
 		return err
 	}
-	var tableToCopy string
+	if len(copyState) == 0 {
+		return fmt.Errorf("unexpected: there are no tables to copy")
+	}
+	if err := vc.catchup(ctx, copyState); err != nil {
+		return err
+	}
+	if vreplicationParallelTableCopies > 1 && len(tables) > 1 {
+		tablesToCopy, err := vc.tablesToCopyConcurrently(tables, vreplicationParallelTableCopies)
+		if err != nil {
+			return err
+		}
+		if len(tablesToCopy) > 1 {
+			return vc.copyTables(ctx, tablesToCopy, copyState)
+		}
+	}
+	return vc.copyTable(ctx, tables[0], copyState)
+}
+
+// readCopyState returns the tables left to copy, in the order of copy_state,
+// and their last copied primary key. A nil Result means that nothing has been
+// copied yet.
+func (vc *vcopier) readCopyState() ([]string, map[string]*sqltypes.Result, error) {
+	qr, err := vc.vr.dbClient.Execute(fmt.Sprintf("select table_name, lastpk from _vt.copy_state where vrepl_id = %d and id in (select max(id) from _vt.copy_state group by vrepl_id, table_name)", vc.vr.id))
+	if err != nil {
+		return nil, nil, err
+	}
+	var tables []string
 	copyState := make(map[string]*sqltypes.Result)
 	for _, row := range qr.Rows {
 		tableName := row[0].ToString()
 		lastpk := row[1].ToString()
-		if tableToCopy == "" {
-			tableToCopy = tableName
-		}
+		tables = append(tables, tableName)
 		copyState[tableName] = nil
 		if lastpk != "" {
 			var r querypb.QueryResult
 			if err := prototext.Unmarshal([]byte(lastpk), &r); err != nil {
-				return err
+				return nil, nil, err
 			}
 			copyState[tableName] = sqltypes.Proto3ToResult(&r)
 		}
 	}
-	if len(copyState) == 0 {
-		return fmt.Errorf("unexpected: there are no tables to copy")
-	}
-	if err := vc.catchup(ctx, copyState); err != nil {
-		return err
-	}
-	return vc.copyTable(ctx, tableToCopy, copyState)
+	return tables, copyState, nil
 }
 
 // catchup replays events to the subset of the tables that have been copied
@@ -378,6 +403,23 @@ func (vc *vcopier) catchup(ctx context.Context, copyState map[string]*sqltypes.R
 // committed with the lastpk. This allows for consistent resumability.
 func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState map[string]*sqltypes.Result) error {
 	defer vc.vr.dbClient.Rollback()
+
+	finished, err := vc.copyTableRows(ctx, tableName, copyState, false, func(ctx context.Context, gtid string) error {
+		return vc.fastForward(ctx, copyState, gtid)
+	})
+	if err != nil || !finished {
+		return err
+	}
+	return vc.finishTable(ctx, tableName)
+}
+
+// copyTableRows copies the next set of rows of a table, as described by
+// copyTable. The target is brought to the position of the snapshot of the
+// rows with snapshot, before any row is copied. copyTableRows returns true
+// if the table was fully copied. If concurrent is set, the table is copied
+// concurrently with other tables, so the rows are copied with connections
+// of their own.
+func (vc *vcopier) copyTableRows(ctx context.Context, tableName string, copyState map[string]*sqltypes.Result, concurrent bool, snapshot func(ctx context.Context, gtid string) error) (bool, error) {
 	defer vc.vr.stats.PhaseTimings.Record("copy", time.Now())
 	defer vc.vr.stats.CopyLoopCount.Add(1)
 
@@ -385,12 +427,12 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 
 	plan, err := buildReplicatorPlan(vc.vr.source, vc.vr.colInfoMap, nil, vc.vr.stats)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	initialPlan, ok := plan.TargetTables[tableName]
 	if !ok {
-		return fmt.Errorf("plan not found for table: %s, current plans are: %#v", tableName, plan.TargetTables)
+		return false, fmt.Errorf("plan not found for table: %s, current plans are: %#v", tableName, plan.TargetTables)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, copyPhaseDuration)
@@ -407,7 +449,7 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 	defer copyStateGCTicker.Stop()
 
 	parallelism := int(math.Max(1, float64(vreplicationParallelInsertWorkers)))
-	copyWorkerFactory := vc.newCopyWorkerFactory(parallelism)
+	copyWorkerFactory := vc.newCopyWorkerFactory(parallelism, concurrent)
 	copyWorkQueue := vc.newCopyWorkQueue(parallelism, copyWorkerFactory)
 	defer copyWorkQueue.close()
 
@@ -426,7 +468,9 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 			select {
 			case <-rowsCopiedTicker.C:
 				update := binlogplayer.GenerateUpdateRowsCopied(vc.vr.id, vc.vr.stats.CopyRowCount.Get())
+				vc.dbClientMu.Lock()
 				_, _ = vc.vr.dbClient.Execute(update)
+				vc.dbClientMu.Unlock()
 			case <-ctx.Done():
 				return io.EOF
 			default:
@@ -457,25 +501,31 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 			default:
 			}
 			if rows.Throttled {
+				vc.dbClientMu.Lock()
 				_ = vc.vr.updateTimeThrottled(RowStreamerComponentName)
+				vc.dbClientMu.Unlock()
 				return nil
 			}
 			if rows.Heartbeat {
+				vc.dbClientMu.Lock()
 				_ = vc.vr.updateHeartbeatTime(time.Now().Unix())
+				vc.dbClientMu.Unlock()
 				return nil
 			}
 			// verify throttler is happy, otherwise keep looping
 			if vc.vr.vre.throttlerClient.ThrottleCheckOKOrWaitAppName(ctx, vc.throttlerAppName) {
 				break // out of 'for' loop
 			} else { // we're throttled
+				vc.dbClientMu.Lock()
 				_ = vc.vr.updateTimeThrottled(VCopierComponentName)
+				vc.dbClientMu.Unlock()
 			}
 		}
 		if !copyWorkQueue.isOpen {
 			if len(rows.Fields) == 0 {
 				return fmt.Errorf("expecting field event first, got: %v", rows)
 			}
-			if err := vc.fastForward(ctx, copyState, rows.Gtid); err != nil {
+			if err := snapshot(ctx, rows.Gtid); err != nil {
 				return err
 			}
 			fieldEvent := &binlogdatapb.FieldEvent{
@@ -501,7 +551,7 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 
 		// Clone rows, since pointer values will change while async work is
 		// happening. Can skip this when there's no parallelism.
-		if parallelism > 1 {
+		if parallelism > 1 || concurrent {
 			rows = proto.Clone(rows).(*binlogdatapb.VStreamRowsResponse)
 		}
 
@@ -617,7 +667,7 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 	if len(terrs) > 0 {
 		terr := vterrors.Aggregate(terrs)
 		log.Warningf("task error in workflow %s: %v", vc.vr.WorkflowName, terr)
-		return vterrors.Wrapf(terr, "task error")
+		return false, vterrors.Wrapf(terr, "task error")
 	}
 
 	// Get the last committed pk into a loggable form.
@@ -626,7 +676,7 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 		Rows:   []*querypb.Row{lastpk},
 	})
 	if merr != nil {
-		return fmt.Errorf("failed to marshal pk fields and value into query result: %s", merr.Error())
+		return false, fmt.Errorf("failed to marshal pk fields and value into query result: %s", merr.Error())
 	}
 	lastpkbv := map[string]*querypb.BindVariable{
 		"lastpk": {
@@ -641,19 +691,24 @@ func (vc *vcopier) copyTable(ctx context.Context, tableName string, copyState ma
 	select {
 	case <-ctx.Done():
 		log.Infof("Copy of %v stopped at lastpk: %v", tableName, lastpkbv)
-		return nil
+		return false, nil
 	default:
 	}
 	if serr != nil {
-		return serr
+		return false, serr
 	}
 
-	// Perform any post copy actions
+	log.Infof("Copy of %v finished at lastpk: %v", tableName, lastpkbv)
+	return true, nil
+}
+
+// finishTable performs the post copy actions of a table which was fully
+// copied, and deletes its copy state.
+func (vc *vcopier) finishTable(ctx context.Context, tableName string) error {
 	if err := vc.vr.execPostCopyActions(ctx, tableName); err != nil {
 		return vterrors.Wrapf(err, "failed to execute post copy actions for table %q", tableName)
 	}
 
-	log.Infof("Copy of %v finished at lastpk: %v", tableName, lastpkbv)
 	buf := sqlparser.NewTrackedBuffer(nil)
 	buf.Myprintf(
 		"delete cs, pca from _vt.%s as cs left join _vt.%s as pca on cs.vrepl_id=pca.vrepl_id and cs.table_name=pca.table_name where cs.vrepl_id=%d and cs.table_name=%s",
@@ -693,8 +748,8 @@ func (vc *vcopier) newCopyWorkQueue(
 	return newVCopierCopyWorkQueue(concurrent, parallelism, workerFactory)
 }
 
-func (vc *vcopier) newCopyWorkerFactory(parallelism int) func(context.Context) (*vcopierCopyWorker, error) {
-	if parallelism > 1 {
+func (vc *vcopier) newCopyWorkerFactory(parallelism int, concurrent bool) func(context.Context) (*vcopierCopyWorker, error) {
+	if parallelism > 1 || concurrent {
 		return func(ctx context.Context) (*vcopierCopyWorker, error) {
 			dbClient, err := vc.vr.newClientConnection(ctx)
 			if err != nil {
@@ -703,6 +758,7 @@ func (vc *vcopier) newCopyWorkerFactory(parallelism int) func(context.Context) (
 			return newVCopierCopyWorker(
 				true, /* close db client */
 				dbClient,
+				vc.vr.sourceVStreamer,
 			), nil
 		}
 	}
@@ -710,6 +766,7 @@ func (vc *vcopier) newCopyWorkerFactory(parallelism int) func(context.Context) (
 		return newVCopierCopyWorker(
 			false, /* close db client */
 			vc.vr.dbClient,
+			vc.vr.sourceVStreamer,
 		), nil
 	}
 }
@@ -1141,13 +1198,39 @@ func (vbc *vcopierCopyWorker) insertCopyState(ctx context.Context, lastpk *query
 }
 
 func (vbc *vcopierCopyWorker) insertRows(ctx context.Context, rows []*querypb.Row) (*sqltypes.Result, error) {
-	return vbc.tablePlan.applyBulkInsert(
-		&vbc.sqlbuffer,
-		rows,
-		func(sql string) (*sqltypes.Result, error) {
-			return vbc.vdbClient.ExecuteWithRetry(ctx, sql)
-		},
-	)
+	executor := func(sql string) (*sqltypes.Result, error) {
+		return vbc.vdbClient.ExecuteWithRetry(ctx, sql)
+	}
+	if vbc.tablePlan.Join != nil {
+		// The rows of a join are recomputed for the join keys of the
+		// copied rows of its first table.
+		keys := vbc.tablePlan.newJoinKeys()
+		for _, row := range rows {
+			if err := keys.addRow(sqltypes.MakeRowTrusted(vbc.tablePlan.Fields, row)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, vbc.tablePlan.Join.recompute(ctx, vbc.sourceVStreamer, keys, executor)
+	}
+	qr, err := vbc.tablePlan.applyBulkInsert(&vbc.sqlbuffer, rows, executor)
+	if err != nil || vbc.tablePlan.Aggregates == nil || !vbc.tablePlan.Aggregates.recomputeOnInsert {
+		return qr, err
+	}
+	// AVG and COUNT(DISTINCT) cannot be computed from the inserted rows
+	// alone, and are recomputed for all the groups of the batch.
+	groups, err := vbc.tablePlan.newAggregateGroups()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if err := groups.addRow(sqltypes.MakeRowTrusted(vbc.tablePlan.Fields, row)); err != nil {
+			return nil, err
+		}
+	}
+	if err := vbc.tablePlan.recomputeAggregates(ctx, vbc.sourceVStreamer, groups, executor); err != nil {
+		return nil, err
+	}
+	return qr, nil
 }
 
 // open the vcopierCopyWorker. The provided arguments are used to generate
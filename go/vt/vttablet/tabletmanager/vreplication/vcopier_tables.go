@@ -0,0 +1,255 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/vterrors"
+
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
+)
+
+// Tables copied concurrently each have their own rowstreamer snapshot, at
+// different positions of the source. The target is fast-forwarded to the
+// earliest of them before any row is copied, so the rows copied from a later
+// snapshot already contain some of the events that the next vplayer replays.
+// Those events are applied idempotently: in the copy phase, the vplayer
+// deletes the rows it inserts beforehand, while the updates and deletes of
+// row based events naturally converge to the state of the later snapshot.
+// At the end of the round, the target is fast-forwarded to the latest
+// snapshot, with the inserts into the fully copied tables still idempotent,
+// and only then are those tables removed from copy_state.
+//
+// Replaying an insert onto a row that moved to another primary key can
+// fail on a secondary unique key, and the aggregations of a Materialize are
+// not idempotent. Such tables are copied on their own.
+
+// tableSnapshot is the position of the snapshot of a table copied
+// concurrently, or the error which prevented it to be taken.
+type tableSnapshot struct {
+	tableName string
+	gtid      string
+	err       error
+}
+
+// tablesToCopyConcurrently returns up to parallelism tables to copy in the
+// next round, starting with the first table left to copy.
+func (vc *vcopier) tablesToCopyConcurrently(tables []string, parallelism int) ([]string, error) {
+	plan, err := buildReplicatorPlan(vc.vr.source, vc.vr.colInfoMap, nil, vc.vr.stats)
+	if err != nil {
+		return nil, err
+	}
+	uniqueKeys, err := vc.tablesWithSecondaryUniqueKeys(tables)
+	if err != nil {
+		return nil, err
+	}
+	var tablesToCopy []string
+	for _, tableName := range tables {
+		tplan := plan.TargetTables[tableName]
+		if tplan == nil || !tplan.isInsertNormal() || uniqueKeys[tableName] {
+			if len(tablesToCopy) == 0 {
+				// The first table must be copied on its own.
+				return []string{tableName}, nil
+			}
+			continue
+		}
+		tablesToCopy = append(tablesToCopy, tableName)
+		if len(tablesToCopy) == parallelism {
+			break
+		}
+	}
+	return tablesToCopy, nil
+}
+
+// tablesWithSecondaryUniqueKeys returns the target tables which have a
+// unique key other than their primary key.
+func (vc *vcopier) tablesWithSecondaryUniqueKeys(tables []string) (map[string]bool, error) {
+	var names []string
+	for _, tableName := range tables {
+		names = append(names, encodeString(tableName))
+	}
+	query := fmt.Sprintf("select distinct table_name from information_schema.statistics where table_schema = %s and non_unique = 0 and index_name != 'PRIMARY' and table_name in (%s)",
+		encodeString(vc.vr.dbClient.DBName()), strings.Join(names, ", "))
+	qr, err := vc.vr.dbClient.Execute(query)
+	if err != nil {
+		return nil, err
+	}
+	uniqueKeys := make(map[string]bool)
+	for _, row := range qr.Rows {
+		uniqueKeys[row[0].ToString()] = true
+	}
+	return uniqueKeys, nil
+}
+
+// copyTables copies the next set of rows of several tables concurrently.
+func (vc *vcopier) copyTables(ctx context.Context, tables []string, copyState map[string]*sqltypes.Result) error {
+	defer vc.vr.dbClient.Rollback()
+
+	log.Infof("Copying tables %v concurrently", tables)
+
+	copyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	snapshots := make(chan tableSnapshot, len(tables))
+	release := make(chan struct{})
+	finished := make([]bool, len(tables))
+	errs := make([]error, len(tables))
+	var wg sync.WaitGroup
+	for i, tableName := range tables {
+		wg.Add(1)
+		go func(i int, tableName string) {
+			defer wg.Done()
+			var once sync.Once
+			report := func(gtid string, err error) {
+				once.Do(func() {
+					snapshots <- tableSnapshot{tableName: tableName, gtid: gtid, err: err}
+				})
+			}
+			finished[i], errs[i] = vc.copyTableRows(copyCtx, tableName, copyState, true, func(ctx context.Context, gtid string) error {
+				report(gtid, nil)
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return io.EOF
+				}
+			})
+			// The copy may have ended before the snapshot.
+			report("", errs[i])
+		}(i, tableName)
+	}
+
+	var positions []mysql.Position
+	var err error
+	for range tables {
+		snapshot := <-snapshots
+		switch {
+		case snapshot.err != nil:
+			err = snapshot.err
+		case snapshot.gtid != "":
+			pos, perr := mysql.DecodePosition(snapshot.gtid)
+			if perr != nil {
+				err = perr
+				continue
+			}
+			positions = append(positions, pos)
+		}
+	}
+	var first, last mysql.Position
+	if err == nil && len(positions) > 0 {
+		first, last, err = snapshotBounds(positions)
+	}
+	if err == nil && len(positions) > 0 {
+		vc.dbClientMu.Lock()
+		err = vc.fastForward(ctx, copyState, mysql.EncodePosition(first))
+		vc.dbClientMu.Unlock()
+	}
+	if err != nil {
+		cancel()
+		wg.Wait()
+		return err
+	}
+	close(release)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	default:
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	// Bring the rows copied from the earlier snapshots to the latest one.
+	// The fully copied tables get all their events.
+	if !first.Equal(last) {
+		_, ffState, err := vc.readCopyState()
+		if err != nil {
+			return err
+		}
+		copied := make(map[string]bool)
+		for i, tableName := range tables {
+			if finished[i] {
+				delete(ffState, tableName)
+				copied[tableName] = true
+			}
+		}
+		if err := vc.fastForwardCopied(ctx, ffState, copied, last); err != nil {
+			return err
+		}
+	}
+	for i, tableName := range tables {
+		if !finished[i] {
+			continue
+		}
+		if err := vc.finishTable(ctx, tableName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fastForwardCopied replays the events up to pos, like fastForward. The
+// inserts into the tables fully copied in the round are idempotent too, as
+// their rows may have been copied from a snapshot later than the events.
+func (vc *vcopier) fastForwardCopied(ctx context.Context, copyState map[string]*sqltypes.Result, copied map[string]bool, pos mysql.Position) error {
+	defer vc.vr.stats.PhaseTimings.Record("fastforward", time.Now())
+	settings, err := binlogplayer.ReadVRSettings(vc.vr.dbClient, vc.vr.id)
+	if err != nil {
+		return err
+	}
+	vp := newVPlayer(vc.vr, settings, copyState, pos, "fastforward")
+	vp.copiedTables = copied
+	return vp.play(ctx)
+}
+
+// snapshotBounds returns the earliest and the latest of the positions of
+// the snapshots of the tables copied concurrently.
+func snapshotBounds(positions []mysql.Position) (first, last mysql.Position, err error) {
+	first, last = positions[0], positions[0]
+	for _, pos := range positions[1:] {
+		switch {
+		case first.AtLeast(pos):
+			first = pos
+		case !pos.AtLeast(first):
+			return first, last, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "snapshot positions %v and %v are not ordered", first, pos)
+		}
+		switch {
+		case pos.AtLeast(last):
+			last = pos
+		case !last.AtLeast(pos):
+			return first, last, vterrors.Errorf(vtrpcpb.Code_FAILED_PRECONDITION, "snapshot positions %v and %v are not ordered", last, pos)
+		}
+	}
+	return first, last, nil
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -661,6 +662,130 @@ func testPlayerCopyTables(t *testing.T) {
 
 }
 
+// TestPlayerCopyTablesConcurrently ensures that tables copied concurrently
+// converge to the source.
+func TestPlayerCopyTablesConcurrently(t *testing.T) {
+	defer func(saved int) { vreplicationParallelTableCopies = saved }(vreplicationParallelTableCopies)
+	vreplicationParallelTableCopies = 2
+	testVcopierTestCases(t, testPlayerCopyTablesConcurrently, commonVcopierTestCases())
+}
+
+func testPlayerCopyTablesConcurrently(t *testing.T) {
+	defer deleteTablet(addTablet(100))
+
+	execStatements(t, []string{
+		"create table src1(id int, val varbinary(128), primary key(id))",
+		"insert into src1 values(1, 'aaa'), (2, 'bbb')",
+		fmt.Sprintf("create table %s.dst1(id int, val varbinary(128), primary key(id))", vrepldb),
+		"create table src2(id int, val varbinary(128), primary key(id))",
+		"insert into src2 values(1, 'ccc'), (2, 'ddd')",
+		fmt.Sprintf("create table %s.dst2(id int, val varbinary(128), primary key(id))", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table src1",
+		fmt.Sprintf("drop table %s.dst1", vrepldb),
+		"drop table src2",
+		fmt.Sprintf("drop table %s.dst2", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	// Write to both tables once the snapshot of the first table copied is
+	// taken, and before the snapshot of the second one. The events of the
+	// writes are then replayed onto the rows of the second table which were
+	// copied with them, until its copy is fast-forwarded to its snapshot.
+	var (
+		mu          sync.Mutex
+		streams     int
+		snapshotted = make(chan struct{})
+		once        sync.Once
+	)
+	vstreamRowsSendHook = func(ctx context.Context) {
+		once.Do(func() { close(snapshotted) })
+	}
+	vstreamRowsHook = func(ctx context.Context) {
+		mu.Lock()
+		streams++
+		second := streams == 2
+		mu.Unlock()
+		if !second {
+			return
+		}
+		select {
+		case <-snapshotted:
+		case <-ctx.Done():
+			return
+		}
+		execStatements(t, []string{
+			"insert into src1 values(3, 'eee')",
+			"insert into src2 values(3, 'fff')",
+			"update src1 set val='aab' where id=1",
+			"update src2 set val='ccd' where id=1",
+		})
+	}
+	defer func() {
+		vstreamRowsHook = nil
+		vstreamRowsSendHook = nil
+	}()
+
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "dst1",
+			Filter: "select * from src1",
+		}, {
+			Match:  "dst2",
+			Filter: "select * from src2",
+		}},
+	}
+
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter:   filter,
+		OnDdl:    binlogdatapb.OnDDLAction_IGNORE,
+	}
+	query := binlogplayer.CreateVReplicationState("test", bls, "", binlogplayer.VReplicationInit, playerEngine.dbName, 0, 0)
+	qr, err := playerEngine.Exec(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		query := fmt.Sprintf("delete from _vt.vreplication where id = %d", qr.InsertID)
+		if _, err := playerEngine.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+		expectDeleteQueries(t)
+	}()
+
+	// The queries of the tables are interleaved, so only check that both
+	// were copied before the Running state, without failing the stream.
+	copied := make(map[string]bool)
+	for q := range globalDBQueries {
+		require.NotContains(t, q, "set message=", "the stream failed")
+		if strings.HasPrefix(q, "insert into dst1(") {
+			copied["dst1"] = true
+		}
+		if strings.HasPrefix(q, "insert into dst2(") {
+			copied["dst2"] = true
+		}
+		if strings.HasPrefix(q, "update _vt.vreplication set state='Running'") {
+			break
+		}
+	}
+	require.Equal(t, map[string]bool{"dst1": true, "dst2": true}, copied)
+	expectData(t, "dst1", [][]string{
+		{"1", "aab"},
+		{"2", "bbb"},
+		{"3", "eee"},
+	})
+	expectData(t, "dst2", [][]string{
+		{"1", "ccd"},
+		{"2", "ddd"},
+		{"3", "fff"},
+	})
+	// The table whose snapshot was taken after the writes copied their row.
+	validateCopyRowCountStat(t, 5)
+}
+
 // TestPlayerCopyBigTable ensures the copy-catchup back-and-forth loop works correctly.
 func TestPlayerCopyBigTable(t *testing.T) {
 	testVcopierTestCases(t, testPlayerCopyBigTable, commonVcopierTestCases())
@@ -42,6 +42,10 @@ type vplayer struct {
 	stopPos   mysql.Position
 	saveStop  bool
 	copyState map[string]*sqltypes.Result
+	// copiedTables are the tables fully copied in a round of concurrent
+	// copies, which are not in copyState anymore but whose rows may still
+	// come from a later snapshot than the events replayed.
+	copiedTables map[string]bool
 
 	replicatorPlan *ReplicatorPlan
 	tablePlans     map[string]*TablePlan
@@ -220,19 +224,44 @@ func (vp *vplayer) applyRowEvent(ctx context.Context, rowEvent *binlogdatapb.Row
 	if tplan == nil {
 		return fmt.Errorf("unexpected event on table %s", rowEvent.TableName)
 	}
+	executor := func(sql string) (*sqltypes.Result, error) {
+		stats := NewVrLogStats("ROWCHANGE")
+		start := time.Now()
+		qr, err := vp.vr.dbClient.ExecuteWithRetry(ctx, sql)
+		vp.vr.stats.QueryCount.Add(vp.phase, 1)
+		vp.vr.stats.QueryTimings.Record(vp.phase, start)
+		stats.Send(sql)
+		return qr, err
+	}
+	if tplan.Join != nil {
+		keys := tplan.newJoinKeys()
+		for _, change := range rowEvent.RowChanges {
+			if err := keys.addRowChange(tplan, change); err != nil {
+				return err
+			}
+		}
+		return tplan.Join.recompute(ctx, vp.vr.sourceVStreamer, keys, executor)
+	}
+	var groups *aggregateGroups
+	if tplan.Aggregates != nil {
+		var err error
+		if groups, err = tplan.newAggregateGroups(); err != nil {
+			return err
+		}
+	}
 	for _, change := range rowEvent.RowChanges {
-		_, err := tplan.applyChange(change, func(sql string) (*sqltypes.Result, error) {
-			stats := NewVrLogStats("ROWCHANGE")
-			start := time.Now()
-			qr, err := vp.vr.dbClient.ExecuteWithRetry(ctx, sql)
-			vp.vr.stats.QueryCount.Add(vp.phase, 1)
-			vp.vr.stats.QueryTimings.Record(vp.phase, start)
-			stats.Send(sql)
-			return qr, err
-		})
+		_, err := tplan.applyChange(change, executor)
 		if err != nil {
 			return err
 		}
+		if groups != nil {
+			if err := groups.addRowChange(tplan, change); err != nil {
+				return err
+			}
+		}
+	}
+	if groups != nil {
+		return tplan.recomputeAggregates(ctx, vp.vr.sourceVStreamer, groups, executor)
 	}
 	return nil
 }
@@ -329,6 +358,15 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 	// can estimate this value more accurately.
 	defer vp.vr.stats.ReplicationLagSeconds.Store(math.MaxInt64)
 	defer vp.vr.stats.VReplicationLags.Add(strconv.Itoa(int(vp.vr.id)), math.MaxInt64)
+
+	var pa *parallelApplier
+	if vp.useParallelApplier() {
+		var err error
+		if pa, err = newParallelApplier(ctx, vp, vreplicationParallelApplyWorkers); err != nil {
+			return err
+		}
+		defer pa.close()
+	}
 	var sbm int64 = -1
 	for {
 		if ctx.Err() != nil {
@@ -374,6 +412,12 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 					vp.timeOffsetNs = time.Now().UnixNano() - event.CurrentTime
 					sbm = event.CurrentTime/1e9 - event.Timestamp
 				}
+				if pa != nil {
+					if err := pa.applyEvent(ctx, event); err != nil {
+						return vp.applyEventsError(err)
+					}
+					continue
+				}
 				mustSave := false
 				switch event.Type {
 				case binlogdatapb.VEventType_COMMIT:
@@ -394,14 +438,17 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 					}
 				}
 				if err := vp.applyEvent(ctx, event, mustSave); err != nil {
-					if err != io.EOF {
-						vp.vr.stats.ErrorCounts.Add([]string{"Apply"}, 1)
-						log.Errorf("Error applying event: %s", err.Error())
-					}
-					return err
+					return vp.applyEventsError(err)
 				}
 			}
 		}
+		if pa != nil {
+			// The position of the transactions applied in parallel is
+			// handed over to the vplayer once they are all committed.
+			if err := pa.drain(ctx); err != nil {
+				return vp.applyEventsError(err)
+			}
+		}
 
 		if sbm >= 0 {
 			vp.vr.stats.ReplicationLagSeconds.Store(sbm)
@@ -411,6 +458,15 @@ func (vp *vplayer) applyEvents(ctx context.Context, relay *relayLog) error {
 	}
 }
 
+// applyEventsError records an error of applyEvents, other than io.EOF.
+func (vp *vplayer) applyEventsError(err error) error {
+	if err != io.EOF {
+		vp.vr.stats.ErrorCounts.Add([]string{"Apply"}, 1)
+		log.Errorf("Error applying event: %s", err.Error())
+	}
+	return err
+}
+
 func hasAnotherCommit(items [][]*binlogdatapb.VEvent, i, j int) bool {
 	for i < len(items) {
 		for j < len(items[i]) {
@@ -475,6 +531,9 @@ func (vp *vplayer) applyEvent(ctx context.Context, event *binlogdatapb.VEvent, m
 		if err != nil {
 			return err
 		}
+		if _, ok := vp.copyState[tplan.TargetName]; (ok || vp.copiedTables[tplan.TargetName]) && vreplicationParallelTableCopies > 1 {
+			tplan.IdempotentInserts = tplan.isInsertNormal()
+		}
 		vp.tablePlans[event.FieldEvent.TableName] = tplan
 		stats.Send(fmt.Sprintf("%v", event.FieldEvent))
 
@@ -578,6 +637,46 @@ func (vp *vplayer) applyEvent(ctx context.Context, event *binlogdatapb.VEvent, m
 			if posReached {
 				return io.EOF
 			}
+		case binlogdatapb.OnDDLAction_TRANSLATE:
+			queries, err := vp.translateDDL(ctx, event.Statement)
+			var incompatible *ddlIncompatibleError
+			switch {
+			case errors.As(err, &incompatible):
+				if err := vp.vr.dbClient.Begin(); err != nil {
+					return err
+				}
+				if _, err := vp.updatePos(event.Timestamp); err != nil {
+					return err
+				}
+				if err := vp.vr.setState(binlogplayer.BlpStopped, fmt.Sprintf("Stopped at DDL %s: %v", event.Statement, incompatible)); err != nil {
+					return err
+				}
+				if err := vp.vr.dbClient.Commit(); err != nil {
+					return err
+				}
+				return io.EOF
+			case err != nil:
+				return err
+			}
+			// As with EXEC, the position is saved once the DDLs are applied.
+			for _, query := range queries {
+				if _, err := vp.vr.dbClient.ExecuteWithRetry(ctx, query); err != nil {
+					return err
+				}
+				stats.Send(query)
+			}
+			if len(queries) > 0 {
+				if err := vp.reloadTargetTables(ctx); err != nil {
+					return err
+				}
+			}
+			posReached, err := vp.updatePos(event.Timestamp)
+			if err != nil {
+				return err
+			}
+			if posReached {
+				return io.EOF
+			}
 		}
 	case binlogdatapb.VEventType_JOURNAL:
 		if vp.vr.dbClient.InTransaction {
@@ -37,6 +37,7 @@ import (
 	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/dbconnpool"
 	"vitess.io/vitess/go/vt/log"
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
 	qh "vitess.io/vitess/go/vt/vttablet/tabletmanager/vreplication/queryhistory"
@@ -1460,6 +1461,168 @@ func TestPlayerRowMove(t *testing.T) {
 	validateQueryCountStat(t, "replicate", 5)
 }
 
+func TestPlayerRecomputedAggregates(t *testing.T) {
+	defer deleteTablet(addTablet(100))
+
+	execStatements(t, []string{
+		"create table src(id int, val1 int, val2 int, primary key(id))",
+		fmt.Sprintf("create table %s.dst(val1 int, mn int, mx int, av decimal(14,4), cd int, rcount int, primary key(val1))", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table src",
+		fmt.Sprintf("drop table %s.dst", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "dst",
+			Filter: "select val1, min(val2) as mn, max(val2) as mx, avg(val2) as av, count(distinct val2) as cd, count(*) as rcount from src group by val1",
+		}},
+	}
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter:   filter,
+		OnDdl:    binlogdatapb.OnDDLAction_IGNORE,
+	}
+	cancel, _ := startVReplication(t, bls, "")
+	defer cancel()
+
+	execStatements(t, []string{
+		"insert into src values(1, 1, 1), (2, 1, 3), (3, 1, 3)",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"/insert into dst\\(val1,mn,mx,av,cd,rcount\\) values \\(1,1,1,1,if\\(1 is null, 0, 1\\),1\\)",
+		"/insert into dst\\(val1,mn,mx,av,cd,rcount\\) values \\(1,3,3,3,if\\(3 is null, 0, 1\\),1\\)",
+		"/insert into dst\\(val1,mn,mx,av,cd,rcount\\) values \\(1,3,3,3,if\\(3 is null, 0, 1\\),1\\)",
+		"/update dst set mn=1, mx=3, av=.*, cd=2 where val1=1",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{
+		{"1", "1", "3", "2.3333", "2", "3"},
+	})
+
+	execStatements(t, []string{
+		"delete from src where id=1",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"update dst set mn=mn, mx=mx, av=av, cd=cd, rcount=rcount-1 where val1=1",
+		"/update dst set mn=3, mx=3, av=.*, cd=1 where val1=1",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{
+		{"1", "3", "3", "3.0000", "1", "2"},
+	})
+
+	execStatements(t, []string{
+		"update src set val1=2 where id=2",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"update dst set mn=mn, mx=mx, av=av, cd=cd, rcount=rcount-1 where val1=1",
+		"/insert into dst\\(val1,mn,mx,av,cd,rcount\\) values \\(2,3,3,3,if\\(3 is null, 0, 1\\),1\\)",
+		"/update dst set mn=3, mx=3, av=.*, cd=1 where val1=1",
+		"/update dst set mn=3, mx=3, av=.*, cd=1 where val1=2",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{
+		{"1", "3", "3", "3.0000", "1", "1"},
+		{"2", "3", "3", "3.0000", "1", "1"},
+	})
+}
+
+func TestPlayerJoin(t *testing.T) {
+	defer deleteTablet(addTablet(100))
+
+	execStatements(t, []string{
+		"create table src1(id int, k int, x int, primary key(id))",
+		"create table src2(k int, val varbinary(10), primary key(k))",
+		fmt.Sprintf("create table %s.dst(id int, k int, val varbinary(10), primary key(id))", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table src1",
+		"drop table src2",
+		fmt.Sprintf("drop table %s.dst", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match:  "dst",
+			Filter: "select src1.id, src1.k, src2.val from src1 join src2 on src1.k = src2.k where src1.x = 1",
+		}},
+	}
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter:   filter,
+		OnDdl:    binlogdatapb.OnDDLAction_IGNORE,
+	}
+	cancel, _ := startVReplication(t, bls, "")
+	defer cancel()
+
+	execStatements(t, []string{
+		"insert into src2 values(1, 'one')",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"delete from dst where k in (1)",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{})
+
+	execStatements(t, []string{
+		"insert into src1 values(1, 1, 1), (2, 1, 0), (3, 1, 1)",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"delete from dst where k in (1)",
+		"insert into dst(id, k, val) values (1, 1, 'one'), (3, 1, 'one')",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{
+		{"1", "1", "one"},
+		{"3", "1", "one"},
+	})
+
+	execStatements(t, []string{
+		"update src2 set val='uno' where k=1",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"delete from dst where k in (1)",
+		"insert into dst(id, k, val) values (1, 1, 'uno'), (3, 1, 'uno')",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{
+		{"1", "1", "uno"},
+		{"3", "1", "uno"},
+	})
+
+	execStatements(t, []string{
+		"update src1 set k=2 where id=3",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"delete from dst where k in (1, 2)",
+		"insert into dst(id, k, val) values (1, 1, 'uno')",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+	expectData(t, "dst", [][]string{
+		{"1", "1", "uno"},
+	})
+}
+
 func TestPlayerTypes(t *testing.T) {
 	defer deleteTablet(addTablet(100))
 	execStatements(t, []string{
@@ -2360,6 +2523,249 @@ func TestPlayerBatching(t *testing.T) {
 	))
 }
 
+func TestPlayerParallelApply(t *testing.T) {
+	defer deleteTablet(addTablet(100))
+
+	savedWorkers := vreplicationParallelApplyWorkers
+	defer func() { vreplicationParallelApplyWorkers = savedWorkers }()
+	vreplicationParallelApplyWorkers = 4
+
+	execStatements(t, []string{
+		"create table t1(id int, val varbinary(128), primary key(id))",
+		fmt.Sprintf("create table %s.t1(id int, val varbinary(128), primary key(id))", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table t1",
+		fmt.Sprintf("drop table %s.t1", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	filter := &binlogdatapb.Filter{
+		Rules: []*binlogdatapb.Rule{{
+			Match: "/.*",
+		}},
+	}
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter:   filter,
+		OnDdl:    binlogdatapb.OnDDLAction_IGNORE,
+	}
+	cancel, _ := startVReplication(t, bls, "")
+	defer cancel()
+
+	// The first transaction comes with the FIELD event of t1,
+	// so it is applied serially.
+	execStatements(t, []string{
+		"insert into t1 values(1, 'aaa')",
+	})
+	expectDBClientQueries(t, qh.Expect(
+		"begin",
+		"insert into t1(id,val) values (1,'aaa')",
+		"/update _vt.vreplication set pos=",
+		"commit",
+	))
+
+	execStatements(t, []string{
+		"insert into t1 values(2, 'bbb')",
+		"update t1 set val='ccc' where id=1",
+		"insert into t1 values(3, 'ddd')",
+		"update t1 set val='eee' where id=1",
+	})
+	// The inserts can be applied in any order, but the
+	// updates of the same row must be applied in order.
+	want := map[string]bool{
+		"insert into t1(id,val) values (2,'bbb')": true,
+		"update t1 set val='ccc' where id=1":      true,
+		"insert into t1(id,val) values (3,'ddd')": true,
+		"update t1 set val='eee' where id=1":      true,
+	}
+	var updates []string
+	for len(want) > 0 {
+		select {
+		case got := <-globalDBQueries:
+			if !want[got] {
+				continue
+			}
+			delete(want, got)
+			if strings.HasPrefix(got, "update t1") {
+				updates = append(updates, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("queries not received: %v", want)
+		}
+	}
+	require.Equal(t, []string{
+		"update t1 set val='ccc' where id=1",
+		"update t1 set val='eee' where id=1",
+	}, updates)
+	expectData(t, "t1", [][]string{
+		{"1", "eee"},
+		{"2", "bbb"},
+		{"3", "ddd"},
+	})
+}
+
+func TestPlayerParallelApplyFallback(t *testing.T) {
+	defer deleteTablet(addTablet(100))
+
+	savedWorkers := vreplicationParallelApplyWorkers
+	defer func() { vreplicationParallelApplyWorkers = savedWorkers }()
+	vreplicationParallelApplyWorkers = 4
+	// The locks of the test must not time out.
+	savedTimeout := parallelApplyLockWaitTimeout
+	defer func() { parallelApplyLockWaitTimeout = savedTimeout }()
+	parallelApplyLockWaitTimeout = 5
+
+	execStatements(t, []string{
+		"create table t1(id int, uk int, primary key(id))",
+		fmt.Sprintf("create table %s.t1(id int, uk int, primary key(id))", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table t1",
+		fmt.Sprintf("drop table %s.t1", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "/.*",
+			}},
+		},
+		OnDdl: binlogdatapb.OnDDLAction_IGNORE,
+	}
+	cancel, _ := startVReplication(t, bls, "")
+	defer cancel()
+
+	execStatements(t, []string{
+		"insert into t1 values(1, 1), (3, 3), (4, 4)",
+	})
+	expectData(t, "t1", [][]string{
+		{"1", "1"},
+		{"3", "3"},
+		{"4", "4"},
+	})
+	// The parallel applier does not know of a unique key added once it
+	// started, so it misses the conflicts on it.
+	execStatements(t, []string{
+		fmt.Sprintf("alter table %s.t1 add unique key uk(uk)", vrepldb),
+	})
+
+	// Lock the rows 3 and 4 on the target, so that the update of the row 1
+	// waits while the insert which reuses its unique key value is applied.
+	lock := func(id int) *dbconnpool.DBConnection {
+		conn, err := env.Mysqld.GetDbaConnection(context.Background())
+		require.NoError(t, err)
+		for _, query := range []string{"begin", fmt.Sprintf("select * from %s.t1 where id = %d for update", vrepldb, id)} {
+			_, err := conn.ExecuteFetch(query, 1, false)
+			require.NoError(t, err)
+		}
+		return conn
+	}
+	conn3 := lock(3)
+	defer conn3.Close()
+	conn4 := lock(4)
+	defer conn4.Close()
+	execStatements(t, []string{
+		"update t1 set uk = 30 where id = 3",
+		"begin",
+		"update t1 set uk = 40 where id = 4",
+		"update t1 set uk = 10 where id = 1",
+		"commit",
+		"insert into t1 values(2, 1)",
+	})
+	time.Sleep(500 * time.Millisecond)
+	_, err := conn3.ExecuteFetch("rollback", 1, false)
+	require.NoError(t, err)
+	time.Sleep(500 * time.Millisecond)
+	_, err = conn4.ExecuteFetch("rollback", 1, false)
+	require.NoError(t, err)
+
+	// The insert fails on a duplicate key when applied out of order, and is
+	// applied again serially, without failing the stream.
+	inserts := 0
+	for inserts < 2 {
+		select {
+		case got := <-globalDBQueries:
+			require.NotContains(t, got, "set message=", "the stream failed")
+			if got == "insert into t1(id,uk) values (2,1)" {
+				inserts++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the insert was applied %d times", inserts)
+		}
+	}
+	expectData(t, "t1", [][]string{
+		{"1", "10"},
+		{"2", "1"},
+		{"3", "30"},
+		{"4", "40"},
+	})
+}
+
+func TestPlayerParallelApplyForeignKeys(t *testing.T) {
+	defer deleteTablet(addTablet(100))
+
+	savedWorkers := vreplicationParallelApplyWorkers
+	defer func() { vreplicationParallelApplyWorkers = savedWorkers }()
+	vreplicationParallelApplyWorkers = 4
+
+	execStatements(t, []string{
+		"create table parent(id int, primary key(id))",
+		"create table child(id int, parent_id int, primary key(id), foreign key (parent_id) references parent(id) on delete cascade)",
+		fmt.Sprintf("create table %s.parent(id int, primary key(id))", vrepldb),
+		fmt.Sprintf("create table %s.child(id int, parent_id int, primary key(id), foreign key (parent_id) references parent(id) on delete cascade)", vrepldb),
+	})
+	defer execStatements(t, []string{
+		"drop table child",
+		"drop table parent",
+		fmt.Sprintf("drop table %s.child", vrepldb),
+		fmt.Sprintf("drop table %s.parent", vrepldb),
+	})
+	env.SchemaEngine.Reload(context.Background())
+
+	bls := &binlogdatapb.BinlogSource{
+		Keyspace: env.KeyspaceName,
+		Shard:    env.ShardName,
+		Filter: &binlogdatapb.Filter{
+			Rules: []*binlogdatapb.Rule{{
+				Match: "/.*",
+			}},
+		},
+		OnDdl: binlogdatapb.OnDDLAction_IGNORE,
+	}
+	cancel, _ := startVReplication(t, bls, "")
+	defer cancel()
+
+	execStatements(t, []string{
+		"insert into parent values(1), (2)",
+		"insert into child values(1, 1), (2, 2)",
+	})
+	expectData(t, "child", [][]string{
+		{"1", "1"},
+		{"2", "2"},
+	})
+
+	// The binlogs do not have the rows the delete cascades to, so the
+	// target must cascade it too.
+	execStatements(t, []string{
+		"insert into parent values(3)",
+		"insert into child values(3, 3)",
+		"delete from parent where id = 1",
+	})
+	expectData(t, "parent", [][]string{
+		{"2"},
+		{"3"},
+	})
+	expectData(t, "child", [][]string{
+		{"2", "2"},
+		{"3", "3"},
+	})
+}
+
 func TestPlayerRelayLogMaxSize(t *testing.T) {
 	defer deleteTablet(addTablet(100))
 
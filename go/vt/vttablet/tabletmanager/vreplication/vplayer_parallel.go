@@ -0,0 +1,563 @@
+/*
+Copyright 2023 The Vitess Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vreplication
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/binlog/binlogplayer"
+	"vitess.io/vitess/go/vt/log"
+
+	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+)
+
+// parallelApplyLockWaitTimeout is the innodb_lock_wait_timeout, in seconds,
+// of the connections of the parallelApplier.
+var parallelApplyLockWaitTimeout = 1
+
+// parallelApplier applies the transactions of the relay log concurrently,
+// on its own connections to the target, when they do not change the same
+// rows. Conflicts are detected on the primary key and the unique keys of the
+// target tables: a transaction is only applied once the earlier transactions
+// changing the same rows or unique key values are committed.
+//
+// Transactions are still committed in the order of the source, each one
+// along with the update of the position in _vt.vreplication, so the saved
+// position is always consistent with the applied transactions.
+//
+// A transaction is applied by the vplayer itself, after all the scheduled
+// transactions are committed, if it contains anything else than row events
+// on tables with a primary key: statements, FIELD events, or partial row
+// images. So are the DDL, OTHER and JOURNAL events. If a conflict is missed,
+// for instance on a unique key added after the applier started, a
+// transaction applied before the previous one was committed may fail, on a
+// duplicate key or a lock wait timeout among others. The workers wait for
+// locks for parallelApplyLockWaitTimeout only, and whenever such a
+// transaction fails, or any transaction fails on a lock error, the
+// transactions not yet committed are applied again by the vplayer.
+//
+// The changes that foreign keys cascade to other rows are not in the
+// binlogs, so conflicts cannot be detected on them: the transactions
+// changing a table that has or is referenced by a foreign key are applied
+// by the vplayer too.
+type parallelApplier struct {
+	vp *vplayer
+
+	// uniqueKeys are the secondary unique keys of the target tables, by
+	// table.
+	uniqueKeys map[string][]*uniqueKey
+	// fkTables are the target tables that have or are referenced by a
+	// foreign key.
+	fkTables map[string]bool
+
+	conns   []*vdbClient
+	workers chan *vdbClient
+	wg      sync.WaitGroup
+
+	// txn is the transaction being read from the relay log.
+	txn *parallelTxn
+	// serial is set while the rest of a transaction is applied by the vplayer.
+	serial bool
+
+	// scheduled are the transactions being applied, in commit order.
+	scheduled []*parallelTxn
+	// rows maps the rows changed by the scheduled transactions to the last
+	// transaction changing them.
+	rows map[string]*parallelTxn
+
+	// completed is set when a transaction was read since the last drain.
+	// pos is then its position, and unsavedEvent its commit if it was empty.
+	completed    bool
+	pos          mysql.Position
+	unsavedEvent *binlogdatapb.VEvent
+}
+
+// parallelTxn is a transaction of the relay log.
+type parallelTxn struct {
+	// events are all the events of the transaction, for it to be applied
+	// again by the vplayer.
+	events []*binlogdatapb.VEvent
+	rows   []*parallelRowEvent
+	keys   []string
+	pos    mysql.Position
+	commit *binlogdatapb.VEvent
+
+	// prev is the transaction to commit before this one, and deps the
+	// transactions changing the same rows, to commit before applying it.
+	prev *parallelTxn
+	deps []*parallelTxn
+
+	// done is closed once the transaction is committed, or failed.
+	done chan struct{}
+	err  error
+	// outOfOrder is set if the transaction was applied before the previous
+	// one was committed.
+	outOfOrder bool
+}
+
+// uniqueKey is a secondary unique key of a target table.
+type uniqueKey struct {
+	name    string
+	columns []string
+}
+
+type parallelRowEvent struct {
+	tplan    *TablePlan
+	rowEvent *binlogdatapb.RowEvent
+}
+
+// useParallelApplier returns true if the vplayer must apply its events with
+// a parallelApplier. The copy, catchup and fast forward phases are applied
+// serially.
+func (vp *vplayer) useParallelApplier() bool {
+	return vreplicationParallelApplyWorkers > 1 && vp.stopPos.IsZero() && len(vp.copyState) == 0
+}
+
+func newParallelApplier(ctx context.Context, vp *vplayer, workers int) (*parallelApplier, error) {
+	pa := &parallelApplier{
+		vp:      vp,
+		workers: make(chan *vdbClient, workers),
+		rows:    make(map[string]*parallelTxn),
+	}
+	for i := 0; i < workers; i++ {
+		dbClient, err := vp.vr.newClientConnection(ctx)
+		if err != nil {
+			pa.close()
+			return nil, fmt.Errorf("failed to create new db client: %s", err.Error())
+		}
+		pa.conns = append(pa.conns, dbClient)
+		// Same session settings as the connection of the controller after
+		// the copy, but for the lock wait timeout, so that a missed conflict
+		// between two transactions fails fast.
+		for _, query := range []string{
+			"set @@session.time_zone = '+00:00'",
+			"set names 'binary'",
+			fmt.Sprintf("set foreign_key_checks=%d;", vp.vr.originalFKCheckSetting),
+			fmt.Sprintf("set @@session.innodb_lock_wait_timeout = %d", parallelApplyLockWaitTimeout),
+		} {
+			if _, err := dbClient.Execute(query); err != nil {
+				pa.close()
+				return nil, err
+			}
+		}
+		pa.workers <- dbClient
+	}
+	uniqueKeys, err := pa.loadUniqueKeys()
+	if err != nil {
+		pa.close()
+		return nil, err
+	}
+	pa.uniqueKeys = uniqueKeys
+	fkTables, err := pa.loadFKTables()
+	if err != nil {
+		pa.close()
+		return nil, err
+	}
+	pa.fkTables = fkTables
+	return pa, nil
+}
+
+// loadFKTables reads the target tables that have or are referenced by a
+// foreign key.
+func (pa *parallelApplier) loadFKTables() (map[string]bool, error) {
+	query := fmt.Sprintf("select table_name, referenced_table_name from information_schema.referential_constraints where constraint_schema = %s",
+		encodeString(pa.vp.vr.dbClient.DBName()))
+	qr, err := pa.conns[0].Execute(query)
+	if err != nil {
+		return nil, err
+	}
+	fkTables := make(map[string]bool)
+	for _, row := range qr.Rows {
+		fkTables[row[0].ToString()] = true
+		fkTables[row[1].ToString()] = true
+	}
+	return fkTables, nil
+}
+
+// loadUniqueKeys reads the secondary unique keys of the target tables.
+func (pa *parallelApplier) loadUniqueKeys() (map[string][]*uniqueKey, error) {
+	query := fmt.Sprintf("select table_name, index_name, column_name from information_schema.statistics where table_schema = %s and non_unique = 0 and index_name != 'PRIMARY' order by table_name, index_name, seq_in_index",
+		encodeString(pa.vp.vr.dbClient.DBName()))
+	qr, err := pa.conns[0].Execute(query)
+	if err != nil {
+		return nil, err
+	}
+	uniqueKeys := make(map[string][]*uniqueKey)
+	for _, row := range qr.Rows {
+		tableName, indexName, columnName := row[0].ToString(), row[1].ToString(), row[2].ToString()
+		keys := uniqueKeys[tableName]
+		if len(keys) == 0 || keys[len(keys)-1].name != indexName {
+			keys = append(keys, &uniqueKey{name: indexName})
+			uniqueKeys[tableName] = keys
+		}
+		uk := keys[len(keys)-1]
+		uk.columns = append(uk.columns, columnName)
+	}
+	return uniqueKeys, nil
+}
+
+// close waits for the workers and closes their connections.
+func (pa *parallelApplier) close() {
+	pa.wg.Wait()
+	for _, dbClient := range pa.conns {
+		_ = dbClient.Rollback()
+		dbClient.Close()
+	}
+}
+
+// applyEvent reads an event of the relay log. Events of transactions that
+// can be applied in parallel are held until the commit of the transaction,
+// which schedules it. The other events are applied by the vplayer.
+func (pa *parallelApplier) applyEvent(ctx context.Context, event *binlogdatapb.VEvent) error {
+	if pa.serial {
+		return pa.applySerial(ctx, event)
+	}
+	if event.Type == binlogdatapb.VEventType_HEARTBEAT {
+		return pa.vp.applyEvent(ctx, event, false)
+	}
+	if pa.txn == nil {
+		switch event.Type {
+		case binlogdatapb.VEventType_GTID, binlogdatapb.VEventType_BEGIN:
+			pa.txn = &parallelTxn{done: make(chan struct{})}
+		default:
+			return pa.fallback(ctx, event)
+		}
+	}
+	txn := pa.txn
+	switch event.Type {
+	case binlogdatapb.VEventType_GTID:
+		pos, err := binlogplayer.DecodePosition(event.Gtid)
+		if err != nil {
+			return err
+		}
+		txn.pos = pos
+	case binlogdatapb.VEventType_BEGIN:
+	case binlogdatapb.VEventType_ROW:
+		tplan, keys, ok := pa.rowKeys(event.RowEvent)
+		if !ok {
+			return pa.fallback(ctx, event)
+		}
+		txn.rows = append(txn.rows, &parallelRowEvent{tplan: tplan, rowEvent: event.RowEvent})
+		txn.keys = append(txn.keys, keys...)
+	case binlogdatapb.VEventType_COMMIT:
+		txn.events = append(txn.events, event)
+		txn.commit = event
+		pa.txn = nil
+		pa.completed = true
+		pa.pos = txn.pos
+		if len(txn.rows) == 0 {
+			// Empty transactions are only saved on inactivity, as by the vplayer.
+			pa.unsavedEvent = event
+			return nil
+		}
+		pa.unsavedEvent = nil
+		pa.schedule(ctx, txn)
+		return nil
+	default:
+		return pa.fallback(ctx, event)
+	}
+	txn.events = append(txn.events, event)
+	return nil
+}
+
+// fallback applies the current transaction with the vplayer, once the
+// scheduled transactions are committed.
+func (pa *parallelApplier) fallback(ctx context.Context, event *binlogdatapb.VEvent) error {
+	if err := pa.drain(ctx); err != nil {
+		return err
+	}
+	var events []*binlogdatapb.VEvent
+	if pa.txn != nil {
+		events = pa.txn.events
+		pa.txn = nil
+	}
+	pa.serial = true
+	for _, ev := range events {
+		if err := pa.vp.applyEvent(ctx, ev, false); err != nil {
+			return err
+		}
+	}
+	return pa.applySerial(ctx, event)
+}
+
+// applySerial applies an event with the vplayer, until the end of the
+// current transaction.
+func (pa *parallelApplier) applySerial(ctx context.Context, event *binlogdatapb.VEvent) error {
+	if err := pa.vp.applyEvent(ctx, event, false); err != nil {
+		return err
+	}
+	switch event.Type {
+	case binlogdatapb.VEventType_COMMIT, binlogdatapb.VEventType_DDL, binlogdatapb.VEventType_OTHER, binlogdatapb.VEventType_JOURNAL:
+		pa.serial = false
+	}
+	return nil
+}
+
+// rowKeys returns the plan of the table of a row event, and the keys of
+// the rows it changes: their primary key and unique key values. It returns
+// false if the event cannot be applied in parallel.
+func (pa *parallelApplier) rowKeys(rowEvent *binlogdatapb.RowEvent) (*TablePlan, []string, bool) {
+	tplan := pa.vp.tablePlans[rowEvent.TableName]
+	if tplan == nil || len(tplan.PKReferences) == 0 {
+		return nil, nil, false
+	}
+	if tplan.Aggregates != nil || tplan.Join != nil {
+		// Recomputed aggregates and joins read the source tables, and
+		// are only applied serially.
+		return nil, nil, false
+	}
+	if pa.fkTables[tplan.TargetName] {
+		return nil, nil, false
+	}
+	// The prefixes of the keys of the rows, and the indexes of their fields.
+	prefixes := []string{tplan.TargetName}
+	pkIndexes, ok := fieldIndexes(tplan, tplan.PKReferences)
+	if !ok {
+		return nil, nil, false
+	}
+	indexes := [][]int{pkIndexes}
+	for _, uk := range pa.uniqueKeys[tplan.TargetName] {
+		// The unique keys on columns the source does not have under the
+		// same name cannot be checked.
+		ukIndexes, ok := fieldIndexes(tplan, uk.columns)
+		if !ok {
+			return nil, nil, false
+		}
+		prefixes = append(prefixes, tplan.TargetName+"/"+uk.name)
+		indexes = append(indexes, ukIndexes)
+	}
+	var keys []string
+	for _, change := range rowEvent.RowChanges {
+		if tplan.isPartial(change) {
+			return nil, nil, false
+		}
+		for _, row := range []*querypb.Row{change.Before, change.After} {
+			if row == nil {
+				continue
+			}
+			vals := sqltypes.MakeRowTrusted(tplan.Fields, row)
+			for i, prefix := range prefixes {
+				keys = append(keys, rowKey(prefix, indexes[i], vals))
+			}
+		}
+	}
+	return tplan, keys, true
+}
+
+// fieldIndexes returns the indexes of the given columns in the fields of a
+// table plan. It returns false if one of them is not a field.
+func fieldIndexes(tplan *TablePlan, columns []string) ([]int, bool) {
+	indexes := make([]int, 0, len(columns))
+	for _, column := range columns {
+		index := -1
+		for i, field := range tplan.Fields {
+			if strings.EqualFold(field.Name, column) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, false
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, true
+}
+
+// rowKey returns the key of a row, as the values of the given fields after
+// the prefix of the key. Text values are compared case insensitively and
+// without their trailing spaces, like with most collations: detecting too
+// many conflicts is harmless.
+func rowKey(prefix string, indexes []int, vals []sqltypes.Value) string {
+	var key strings.Builder
+	key.WriteString(prefix)
+	for _, index := range indexes {
+		raw := vals[index].Raw()
+		if vals[index].IsText() {
+			raw = bytes.ToLower(bytes.TrimRight(raw, " "))
+		}
+		fmt.Fprintf(&key, ":%d:", len(raw))
+		key.Write(raw)
+	}
+	return key.String()
+}
+
+// schedule starts to apply a transaction, once a worker is available.
+func (pa *parallelApplier) schedule(ctx context.Context, txn *parallelTxn) {
+	for _, key := range txn.keys {
+		if dep := pa.rows[key]; dep != nil && dep != txn && !containsTxn(txn.deps, dep) {
+			txn.deps = append(txn.deps, dep)
+		}
+		pa.rows[key] = txn
+	}
+	if n := len(pa.scheduled); n > 0 {
+		txn.prev = pa.scheduled[n-1]
+	}
+	pa.scheduled = append(pa.scheduled, txn)
+
+	var dbClient *vdbClient
+	select {
+	case dbClient = <-pa.workers:
+	case <-ctx.Done():
+		txn.err = io.EOF
+		close(txn.done)
+		return
+	}
+	pa.wg.Add(1)
+	go func() {
+		defer pa.wg.Done()
+		txn.err = pa.apply(ctx, dbClient, txn)
+		close(txn.done)
+		pa.workers <- dbClient
+	}()
+}
+
+func containsTxn(txns []*parallelTxn, txn *parallelTxn) bool {
+	for _, t := range txns {
+		if t == txn {
+			return true
+		}
+	}
+	return false
+}
+
+// apply applies a transaction on a worker connection, and commits it with
+// its position after the previous transaction.
+func (pa *parallelApplier) apply(ctx context.Context, dbClient *vdbClient, txn *parallelTxn) (err error) {
+	defer func() {
+		if err != nil {
+			_ = dbClient.Rollback()
+		}
+	}()
+	for _, dep := range txn.deps {
+		if err := waitTxn(ctx, dep); err != nil {
+			return err
+		}
+	}
+	if txn.prev != nil {
+		select {
+		case <-txn.prev.done:
+		default:
+			txn.outOfOrder = true
+		}
+	}
+	vr := pa.vp.vr
+	if err := dbClient.Begin(); err != nil {
+		return err
+	}
+	for _, row := range txn.rows {
+		for _, change := range row.rowEvent.RowChanges {
+			_, err := row.tplan.applyChange(change, func(sql string) (*sqltypes.Result, error) {
+				stats := NewVrLogStats("ROWCHANGE")
+				start := time.Now()
+				qr, err := dbClient.Execute(sql)
+				vr.stats.QueryCount.Add(pa.vp.phase, 1)
+				vr.stats.QueryTimings.Record(pa.vp.phase, start)
+				stats.Send(sql)
+				return qr, err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if txn.prev != nil {
+		if err := waitTxn(ctx, txn.prev); err != nil {
+			return err
+		}
+	}
+	update := binlogplayer.GenerateUpdatePos(vr.id, txn.pos, time.Now().Unix(), txn.commit.Timestamp, vr.stats.CopyRowCount.Get(), vreplicationStoreCompressedGTID)
+	if _, err := dbClient.Execute(update); err != nil {
+		return fmt.Errorf("error %v updating position", err)
+	}
+	return dbClient.Commit()
+}
+
+func waitTxn(ctx context.Context, txn *parallelTxn) error {
+	select {
+	case <-txn.done:
+		return txn.err
+	case <-ctx.Done():
+		return io.EOF
+	}
+}
+
+// drain waits for the scheduled transactions to be committed, and hands
+// the position reached over to the vplayer. If a transaction failed on a
+// lock error, or failed after it was applied out of order, it and the ones
+// after it are applied again by the vplayer.
+func (pa *parallelApplier) drain(ctx context.Context) error {
+	vp := pa.vp
+	scheduled := pa.scheduled
+	pa.scheduled = nil
+	pa.rows = make(map[string]*parallelTxn)
+
+	var committed *parallelTxn
+	failed := -1
+	for i, txn := range scheduled {
+		<-txn.done
+		if txn.err != nil && failed < 0 {
+			failed = i
+		}
+		if failed < 0 {
+			committed = txn
+		}
+	}
+	if committed != nil {
+		vp.pos = committed.pos
+		vp.unsavedEvent = nil
+		vp.timeLastSaved = time.Now()
+		vp.numAccumulatedHeartbeats = 0
+		vp.vr.stats.SetLastPosition(committed.pos)
+	}
+	if failed >= 0 {
+		err := scheduled[failed].err
+		if !isLockError(err) && !scheduled[failed].outOfOrder {
+			return err
+		}
+		log.Infof("Parallel apply of stream %v failed, applying %d transactions serially: %v", vp.vr.id, len(scheduled)-failed, err)
+		for _, txn := range scheduled[failed:] {
+			for _, event := range txn.events {
+				if err := vp.applyEvent(ctx, event, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if pa.completed {
+		pa.completed = false
+		vp.pos = pa.pos
+		if pa.unsavedEvent != nil {
+			vp.unsavedEvent = pa.unsavedEvent
+		}
+	}
+	return nil
+}
+
+func isLockError(err error) bool {
+	sqlErr, ok := err.(*mysql.SQLError)
+	return ok && (sqlErr.Number() == mysql.ERLockDeadlock || sqlErr.Number() == mysql.ERLockWaitTimeout)
+}
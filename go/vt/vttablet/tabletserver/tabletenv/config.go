@@ -215,6 +215,7 @@ func registerTabletEnvFlags(fs *pflag.FlagSet) {
 var (
 	queryLogHandlerOnce sync.Once
 	txLogHandlerOnce    sync.Once
+	slowQueryLogOnce    sync.Once
 )
 
 // Init must be called after flag.Parse, and before doing any other operations.
@@ -289,6 +290,12 @@ func Init() {
 			TxLogger.ServeLogs(txLogHandler, streamlog.GetFormatter(TxLogger))
 		})
 	}
+
+	slowQueryLogOnce.Do(func() {
+		if _, err := streamlog.LogSlowQueriesFromFlags(StatsLogger); err != nil {
+			log.Exitf("Cannot open the slow query log: %v", err)
+		}
+	})
 }
 
 // TabletConfig contains all the configuration for query service
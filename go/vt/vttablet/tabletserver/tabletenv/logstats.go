@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -174,6 +175,40 @@ func (stats *LogStats) CallInfo() (string, string) {
 	return ci.Text(), ci.Username()
 }
 
+// SlowLogEntry returns the entry of the query in the slow query log.
+func (stats *LogStats) SlowLogEntry() *streamlog.SlowLogEntry {
+	entry := &streamlog.SlowLogEntry{
+		Time:         stats.EndTime,
+		User:         stats.ImmediateCaller(),
+		QueryTime:    stats.TotalTime(),
+		LockTime:     stats.WaitingForConnection,
+		HasLockTime:  true,
+		RowsSent:     uint64(len(stats.Rows)),
+		RowsAffected: uint64(stats.RowsAffected),
+		Fields: []streamlog.SlowLogField{
+			{Name: "method", Value: stats.Method},
+			{Name: "plan_type", Value: stats.PlanType},
+			{Name: "queries", Value: strconv.Itoa(stats.NumberOfQueries)},
+			{Name: "query_sources", Value: stats.FmtQuerySources()},
+			{Name: "mysql_time", Value: fmt.Sprintf("%.6f", stats.MysqlResponseTime.Seconds())},
+			{Name: "transaction_id", Value: strconv.FormatInt(stats.TransactionID, 10)},
+			{Name: "reserved_id", Value: strconv.FormatInt(stats.ReservedID, 10)},
+		},
+		SQL: stats.OriginalSQL,
+	}
+	if ci, ok := callinfo.FromContext(stats.Ctx); ok {
+		entry.Host = ci.RemoteAddr()
+	}
+	if stats.Target != nil {
+		entry.Database = stats.Target.Keyspace
+		entry.Fields = append(entry.Fields,
+			streamlog.SlowLogField{Name: "shard", Value: stats.Target.Shard},
+			streamlog.SlowLogField{Name: "tablet_type", Value: stats.Target.TabletType.String()},
+		)
+	}
+	return entry
+}
+
 // Logf formats the log record to the given writer, either as
 // tab-separated list of logged fields or as JSON.
 func (stats *LogStats) Logf(w io.Writer, params url.Values) error {
@@ -33,6 +33,7 @@ import (
 	"vitess.io/vitess/go/vt/callinfo"
 	"vitess.io/vitess/go/vt/callinfo/fakecallinfo"
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 )
 
 func TestLogStats(t *testing.T) {
@@ -255,3 +256,32 @@ func TestLogStatsCallInfo(t *testing.T) {
 		t.Fatalf("expected to get username: %s, but got: %s", username, user)
 	}
 }
+
+func TestLogStatsSlowLogEntry(t *testing.T) {
+	callInfo := &fakecallinfo.FakeCallInfo{Remote: "10.0.0.1:51234"}
+	logStats := NewLogStats(callinfo.NewContext(context.Background(), callInfo), "Execute")
+	logStats.Target = &querypb.Target{Keyspace: "ks", Shard: "-80", TabletType: topodatapb.TabletType_PRIMARY}
+	logStats.PlanType = "Select"
+	logStats.OriginalSQL = "select * from user where id = :id"
+	logStats.NumberOfQueries = 1
+	logStats.QuerySources |= QuerySourceMySQL
+	logStats.StartTime = time.Date(2017, time.January, 1, 1, 2, 3, 0, time.UTC)
+	logStats.EndTime = time.Date(2017, time.January, 1, 1, 2, 4, 0, time.UTC)
+	logStats.WaitingForConnection = 250 * time.Millisecond
+	logStats.Rows = [][]sqltypes.Value{{sqltypes.NewVarBinary("a")}}
+
+	var b bytes.Buffer
+	if err := logStats.SlowLogEntry().Format(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Time: 2017-01-01T01:02:04.000000Z\n" +
+		"# User@Host: [] @  [10.0.0.1]\n" +
+		"# Query_time: 1.000000  Lock_time: 0.250000  Rows_sent: 1  Rows_examined: 0  Rows_affected: 0\n" +
+		"# Vitess_method: Execute  Vitess_plan_type: Select  Vitess_queries: 1  Vitess_query_sources: mysql  Vitess_mysql_time: 0.000000  Vitess_transaction_id: 0  Vitess_reserved_id: 0  Vitess_shard: -80  Vitess_tablet_type: PRIMARY\n" +
+		"use ks;\n" +
+		"SET timestamp=1483232524;\n" +
+		"select * from user where id = :id;\n"
+	if got := b.String(); got != want {
+		t.Errorf("SlowLogEntry().Format() = %q, want %q", got, want)
+	}
+}
@@ -409,6 +409,68 @@ func TestTabletServerReadTransaction(t *testing.T) {
 	utils.MustMatch(t, want, got, "ReadTransaction")
 }
 
+func TestTabletServerUnresolvedTransactions(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer tsv.StopService()
+	defer db.Close()
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	db.AddQueryPattern("select t\\.dtid, t\\.state, t\\.time_created, p\\.keyspace, p\\.shard from _vt\\.dt_state t join _vt\\.dt_participant p on t\\.dtid = p\\.dtid where t\\.time_created < .*", &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Type: sqltypes.VarBinary},
+			{Type: sqltypes.Int64},
+			{Type: sqltypes.Int64},
+			{Type: sqltypes.VarBinary},
+			{Type: sqltypes.VarBinary},
+		},
+		Rows: [][]sqltypes.Value{{
+			sqltypes.NewVarBinary("aa"),
+			sqltypes.NewInt64(int64(querypb.TransactionState_COMMIT)),
+			sqltypes.NewInt64(1),
+			sqltypes.NewVarBinary("test1"),
+			sqltypes.NewVarBinary("0"),
+		}, {
+			sqltypes.NewVarBinary("aa"),
+			sqltypes.NewInt64(int64(querypb.TransactionState_COMMIT)),
+			sqltypes.NewInt64(1),
+			sqltypes.NewVarBinary("test2"),
+			sqltypes.NewVarBinary("1"),
+		}, {
+			sqltypes.NewVarBinary("bb"),
+			sqltypes.NewInt64(int64(querypb.TransactionState_PREPARE)),
+			sqltypes.NewInt64(2),
+			sqltypes.NewVarBinary("test1"),
+			sqltypes.NewVarBinary("0"),
+		}},
+	})
+	got, err := tsv.UnresolvedTransactions(ctx, &target, time.Minute)
+	require.NoError(t, err)
+	want := []*querypb.TransactionMetadata{{
+		Dtid:        "aa",
+		State:       querypb.TransactionState_COMMIT,
+		TimeCreated: 1,
+		Participants: []*querypb.Target{{
+			Keyspace:   "test1",
+			Shard:      "0",
+			TabletType: topodatapb.TabletType_PRIMARY,
+		}, {
+			Keyspace:   "test2",
+			Shard:      "1",
+			TabletType: topodatapb.TabletType_PRIMARY,
+		}},
+	}, {
+		Dtid:        "bb",
+		State:       querypb.TransactionState_PREPARE,
+		TimeCreated: 2,
+		Participants: []*querypb.Target{{
+			Keyspace:   "test1",
+			Shard:      "0",
+			TabletType: topodatapb.TabletType_PRIMARY,
+		}},
+	}}
+	utils.MustMatch(t, want, got, "UnresolvedTransactions")
+}
+
 func TestTabletServerConcludeTransaction(t *testing.T) {
 	_, tsv, db := newTestTxExecutor(t)
 	defer tsv.StopService()
@@ -243,6 +243,15 @@ func (txe *TxExecutor) ReadTransaction(dtid string) (*querypb.TransactionMetadat
 	return txe.te.twoPC.ReadTransaction(txe.ctx, dtid)
 }
 
+// UnresolvedTransactions returns the distributed transactions older than
+// abandonAge.
+func (txe *TxExecutor) UnresolvedTransactions(abandonAge time.Duration) ([]*querypb.TransactionMetadata, error) {
+	if !txe.te.twopcEnabled {
+		return nil, vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "2pc is not enabled")
+	}
+	return txe.te.twoPC.UnresolvedTransactions(txe.ctx, time.Now().Add(-abandonAge))
+}
+
 // ReadTwopcInflight returns info about all in-flight 2pc transactions.
 func (txe *TxExecutor) ReadTwopcInflight() (distributed []*tx.DistributedTx, prepared, failed []*tx.PreparedTx, err error) {
 	if !txe.te.twopcEnabled {
@@ -59,6 +59,10 @@ type Plan struct {
 	// Filters is the list of filters to be applied to the columns
 	// of the table.
 	Filters []Filter
+
+	// hasExpressions is set if any of the Filters or ColExprs must
+	// be evaluated by the evalengine.
+	hasExpressions bool
 }
 
 // Opcode enumerates the operators supported in a where clause
@@ -79,6 +83,9 @@ const (
 	GreaterThanEqual
 	// NotEqual is used to filter a comparable column if != specific value
 	NotEqual
+	// Expression is used to filter a row on an arbitrary predicate,
+	// evaluated by the evalengine
+	Expression
 )
 
 // Filter contains opcodes for filtering.
@@ -87,6 +94,13 @@ type Filter struct {
 	ColNum int
 	Value  sqltypes.Value
 
+	// Expr is the predicate of an Expression filter. Its columns
+	// reference the columns of the table.
+	Expr evalengine.Expr
+	// Predicate is the original expression of an Expression filter.
+	// The rowstreamer pushes it down to MySQL.
+	Predicate sqlparser.Expr
+
 	// Parameters for VindexMatch.
 	// Vindex, VindexColumns and KeyRange, if set, will be used
 	// to filter the row.
@@ -112,6 +126,11 @@ type ColExpr struct {
 	Field *querypb.Field
 
 	FixedValue sqltypes.Value
+
+	// Expr, if set, is evaluated against the row to generate the value.
+	// If so, ColNum is ignored. Its columns reference the columns of
+	// the table.
+	Expr evalengine.Expr
 }
 
 // Table contains the metadata for a table.
@@ -214,8 +233,22 @@ func (plan *Plan) filter(values, result []sqltypes.Value, charsets []collations.
 	if len(result) != len(plan.ColExprs) {
 		return false, fmt.Errorf("expected %d values in result slice", len(plan.ColExprs))
 	}
+	var env *evalengine.ExpressionEnv
+	if plan.hasExpressions {
+		env = evalengine.EmptyExpressionEnv()
+		env.Row = values
+	}
 	for _, filter := range plan.Filters {
 		switch filter.Opcode {
+		case Expression:
+			// Like MySQL, a predicate that evaluates to NULL does not match.
+			res, err := env.Evaluate(filter.Expr)
+			if err != nil {
+				return false, err
+			}
+			if !res.ToBoolean() {
+				return false, nil
+			}
 		case VindexMatch:
 			ksid, err := getKeyspaceID(values, filter.Vindex, filter.VindexColumns, plan.Table.Fields)
 			if err != nil {
@@ -235,6 +268,14 @@ func (plan *Plan) filter(values, result []sqltypes.Value, charsets []collations.
 		}
 	}
 	for i, colExpr := range plan.ColExprs {
+		if colExpr.Expr != nil {
+			res, err := env.Evaluate(colExpr.Expr)
+			if err != nil {
+				return false, err
+			}
+			result[i] = res.Value()
+			continue
+		}
 		if colExpr.ColNum == -1 {
 			result[i] = colExpr.FixedValue
 			continue
@@ -504,54 +545,139 @@ func (plan *Plan) analyzeWhere(vschema *localVSchema, where *sqlparser.Where) er
 	}
 	exprs := splitAndExpression(nil, where.Expr)
 	for _, expr := range exprs {
-		switch expr := expr.(type) {
-		case *sqlparser.ComparisonExpr:
-			opcode, err := getOpcode(expr)
-			if err != nil {
+		if funcExpr, ok := expr.(*sqlparser.FuncExpr); ok && funcExpr.Name.EqualString("in_keyrange") {
+			if err := plan.analyzeInKeyRange(vschema, funcExpr.Exprs); err != nil {
 				return err
 			}
-			qualifiedName, ok := expr.Left.(*sqlparser.ColName)
-			if !ok {
-				return fmt.Errorf("unexpected: %v", sqlparser.String(expr))
-			}
-			if !qualifiedName.Qualifier.IsEmpty() {
-				return fmt.Errorf("unsupported qualifier for column: %v", sqlparser.String(qualifiedName))
-			}
-			colnum, err := findColumn(plan.Table, qualifiedName.Name)
-			if err != nil {
+			continue
+		}
+		if comparison, ok := expr.(*sqlparser.ComparisonExpr); ok && plan.isColumnComparison(comparison) {
+			if err := plan.analyzeColumnComparison(comparison); err != nil {
 				return err
 			}
-			val, ok := expr.Right.(*sqlparser.Literal)
-			if !ok {
-				return fmt.Errorf("unexpected: %v", sqlparser.String(expr))
-			}
-			//StrVal is varbinary, we do not support varchar since we would have to implement all collation types
-			if val.Type != sqlparser.IntVal && val.Type != sqlparser.StrVal {
-				return fmt.Errorf("unexpected: %v", sqlparser.String(expr))
+			continue
+		}
+		// Any other constraint is evaluated by the evalengine.
+		pred, err := plan.translateExpr(expr)
+		if err != nil {
+			return vterrors.Wrapf(err, "unsupported constraint")
+		}
+		plan.Filters = append(plan.Filters, Filter{
+			Opcode:    Expression,
+			Expr:      pred,
+			Predicate: expr,
+		})
+		plan.hasExpressions = true
+	}
+	return nil
+}
+
+// isColumnComparison returns true if the comparison is between an integral
+// column and an integer literal, or a binary column and a string literal.
+// These are compared without the evalengine, with the same results.
+func (plan *Plan) isColumnComparison(comparison *sqlparser.ComparisonExpr) bool {
+	if _, err := getOpcode(comparison); err != nil {
+		return false
+	}
+	qualifiedName, ok := comparison.Left.(*sqlparser.ColName)
+	if !ok || !qualifiedName.Qualifier.IsEmpty() {
+		return false
+	}
+	colnum := plan.Table.FindColumn(qualifiedName.Name)
+	if colnum == -1 {
+		return false
+	}
+	val, ok := comparison.Right.(*sqlparser.Literal)
+	if !ok {
+		return false
+	}
+	typ := plan.Table.Fields[colnum].Type
+	switch val.Type {
+	case sqlparser.IntVal:
+		return sqltypes.IsIntegral(typ)
+	case sqlparser.StrVal:
+		//StrVal is varbinary, we do not support varchar since we would have to implement all collation types
+		return sqltypes.IsBinary(typ)
+	}
+	return false
+}
+
+func (plan *Plan) analyzeColumnComparison(comparison *sqlparser.ComparisonExpr) error {
+	opcode, err := getOpcode(comparison)
+	if err != nil {
+		return err
+	}
+	colnum, err := findColumn(plan.Table, comparison.Left.(*sqlparser.ColName).Name)
+	if err != nil {
+		return err
+	}
+	pv, err := evalengine.Translate(comparison.Right, nil)
+	if err != nil {
+		return err
+	}
+	env := evalengine.EmptyExpressionEnv()
+	resolved, err := env.Evaluate(pv)
+	if err != nil {
+		return err
+	}
+	plan.Filters = append(plan.Filters, Filter{
+		Opcode: opcode,
+		ColNum: colnum,
+		Value:  resolved.Value(),
+	})
+	return nil
+}
+
+// translateExpr translates an expression of the filter for the evalengine.
+// The columns are resolved to their position in the table, and compared with
+// their own collation.
+func (plan *Plan) translateExpr(expr sqlparser.Expr) (evalengine.Expr, error) {
+	return evalengine.Translate(expr, &evalengine.Config{
+		ResolveColumn: func(col *sqlparser.ColName) (int, error) {
+			if !col.Qualifier.IsEmpty() {
+				return 0, fmt.Errorf("unsupported qualifier for column: %v", sqlparser.String(col))
 			}
-			pv, err := evalengine.Translate(val, nil)
-			if err != nil {
-				return err
+			return findColumn(plan.Table, col.Name)
+		},
+		ResolveType: func(expr sqlparser.Expr) (sqltypes.Type, collations.ID, bool) {
+			col, ok := expr.(*sqlparser.ColName)
+			if !ok {
+				return 0, 0, false
 			}
-			env := evalengine.EmptyExpressionEnv()
-			resolved, err := env.Evaluate(pv)
+			colnum, err := findColumn(plan.Table, col.Name)
 			if err != nil {
-				return err
-			}
-			plan.Filters = append(plan.Filters, Filter{
-				Opcode: opcode,
-				ColNum: colnum,
-				Value:  resolved.Value(),
-			})
-		case *sqlparser.FuncExpr:
-			if !expr.Name.EqualString("in_keyrange") {
-				return fmt.Errorf("unsupported constraint: %v", sqlparser.String(expr))
+				return 0, 0, false
 			}
-			if err := plan.analyzeInKeyRange(vschema, expr.Exprs); err != nil {
-				return err
+			field := plan.Table.Fields[colnum]
+			return field.Type, collations.ID(field.Charset), true
+		},
+	})
+}
+
+// ValidateWhere checks that the where clause of a filter can be evaluated
+// by the vstreamer, before the workflow using it is created. The columns of
+// the table are not known at that point, so they are only resolved when the
+// stream starts.
+func ValidateWhere(where *sqlparser.Where) error {
+	if where == nil {
+		return nil
+	}
+	var columns []string
+	cfg := &evalengine.Config{
+		ResolveColumn: func(col *sqlparser.ColName) (int, error) {
+			if !col.Qualifier.IsEmpty() {
+				return 0, fmt.Errorf("unsupported qualifier for column: %v", sqlparser.String(col))
 			}
-		default:
-			return fmt.Errorf("unsupported constraint: %v", sqlparser.String(expr))
+			columns = append(columns, col.Name.String())
+			return len(columns) - 1, nil
+		},
+	}
+	for _, expr := range splitAndExpression(nil, where.Expr) {
+		if funcExpr, ok := expr.(*sqlparser.FuncExpr); ok && funcExpr.Name.EqualString("in_keyrange") {
+			continue
+		}
+		if _, err := evalengine.Translate(expr, cfg); err != nil {
+			return vterrors.Wrapf(err, "unsupported constraint")
 		}
 	}
 	return nil
@@ -673,7 +799,7 @@ func (plan *Plan) analyzeExpr(vschema *localVSchema, selExpr sqlparser.SelectExp
 				Field:  field,
 			}, nil
 		default:
-			return ColExpr{}, fmt.Errorf("unsupported function: %v", sqlparser.String(inner))
+			return plan.analyzeExpression(aliased)
 		}
 	case *sqlparser.Literal:
 		//allow only intval 1
@@ -707,11 +833,37 @@ func (plan *Plan) analyzeExpr(vschema *localVSchema, selExpr sqlparser.SelectExp
 			Field:  field,
 		}, nil
 	default:
-		log.Infof("Unsupported expression: %v", inner)
-		return ColExpr{}, fmt.Errorf("unsupported: %v", sqlparser.String(aliased.Expr))
+		return plan.analyzeExpression(aliased)
 	}
 }
 
+// analyzeExpression builds a column expression which is evaluated by the
+// evalengine. The column is named after its alias, or the expression itself.
+func (plan *Plan) analyzeExpression(aliased *sqlparser.AliasedExpr) (ColExpr, error) {
+	expr, err := plan.translateExpr(aliased.Expr)
+	if err != nil {
+		log.Infof("Unsupported expression: %v", aliased.Expr)
+		return ColExpr{}, vterrors.Wrapf(err, "unsupported")
+	}
+	typ, err := evalengine.EmptyExpressionEnv().TypeOf(expr, plan.Table.Fields)
+	if err != nil {
+		return ColExpr{}, vterrors.Wrapf(err, "unsupported")
+	}
+	name := aliased.As.String()
+	if name == "" {
+		name = sqlparser.String(aliased.Expr)
+	}
+	plan.hasExpressions = true
+	return ColExpr{
+		ColNum: -1,
+		Field: &querypb.Field{
+			Name: name,
+			Type: typ,
+		},
+		Expr: expr,
+	}, nil
+}
+
 // analyzeInKeyRange allows the following constructs: "in_keyrange('-80')",
 // "in_keyrange(col, 'hash', '-80')", "in_keyrange(col, 'local_vindex', '-80')", or
 // "in_keyrange(col, 'ks.external_vindex', '-80')".
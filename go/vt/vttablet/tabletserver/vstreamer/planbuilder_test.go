@@ -32,6 +32,7 @@ import (
 	"vitess.io/vitess/go/json2"
 	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vtgate/vindexes"
 
 	binlogdatapb "vitess.io/vitess/go/vt/proto/binlogdata"
@@ -497,7 +498,7 @@ func TestPlanBuilder(t *testing.T) {
 	}, {
 		inTable: t1,
 		inRule:  &binlogdatapb.Rule{Match: "t1", Filter: "select id, val from t1 where max(id)"},
-		outErr:  `unsupported constraint: max(id)`,
+		outErr:  `unsupported constraint: expr cannot be translated, not supported: max(id)`,
 	}, {
 		inTable: t1,
 		inRule:  &binlogdatapb.Rule{Match: "t1", Filter: "select id, val from t1 where in_keyrange(id)"},
@@ -537,8 +538,8 @@ func TestPlanBuilder(t *testing.T) {
 		outErr:  `unsupported function: max(val)`,
 	}, {
 		inTable: t1,
-		inRule:  &binlogdatapb.Rule{Match: "t1", Filter: "select id+1, val from t1"},
-		outErr:  `unsupported: id + 1`,
+		inRule:  &binlogdatapb.Rule{Match: "t1", Filter: "select none+1, val from t1"},
+		outErr:  "unsupported: column `none` not found in table t1",
 	}, {
 		inTable: t1,
 		inRule:  &binlogdatapb.Rule{Match: "t1", Filter: "select t1.id, val from t1"},
@@ -663,6 +664,131 @@ func TestPlanBuilderFilterComparison(t *testing.T) {
 	}
 }
 
+func TestPlanBuilderFilterExpression(t *testing.T) {
+	t1 := &Table{
+		Name: "t1",
+		Fields: []*querypb.Field{{
+			Name: "id",
+			Type: sqltypes.Int64,
+		}, {
+			Name:    "status",
+			Type:    sqltypes.VarChar,
+			Charset: uint32(collations.CollationUtf8mb4ID),
+		}, {
+			Name: "created_at",
+			Type: sqltypes.Datetime,
+		}},
+	}
+	row := func(id int64, status, createdAt string) []sqltypes.Value {
+		values := []sqltypes.Value{sqltypes.NewInt64(id), sqltypes.NULL, sqltypes.NULL}
+		if status != "" {
+			values[1] = sqltypes.NewVarChar(status)
+		}
+		if createdAt != "" {
+			values[2] = sqltypes.MakeTrusted(sqltypes.Datetime, []byte(createdAt))
+		}
+		return values
+	}
+	testcases := []struct {
+		name     string
+		inFilter string
+		inRow    []sqltypes.Value
+		outRow   []sqltypes.Value
+		outErr   string
+	}{{
+		name:     "in-and-greater",
+		inFilter: "select id from t1 where status in ('new', 'paid') and created_at > '2023-01-01'",
+		inRow:    row(1, "paid", "2023-02-01 00:00:00"),
+		outRow:   []sqltypes.Value{sqltypes.NewInt64(1)},
+	}, {
+		name:     "in-no-match",
+		inFilter: "select id from t1 where status in ('new', 'paid') and created_at > '2023-01-01'",
+		inRow:    row(1, "shipped", "2023-02-01 00:00:00"),
+	}, {
+		name:     "greater-no-match",
+		inFilter: "select id from t1 where status in ('new', 'paid') and created_at > '2023-01-01'",
+		inRow:    row(1, "paid", "2022-12-01 00:00:00"),
+	}, {
+		name:     "null-does-not-match",
+		inFilter: "select id from t1 where status != 'new'",
+		inRow:    row(1, "", ""),
+	}, {
+		name:     "is-null",
+		inFilter: "select id from t1 where status is null or id > 2",
+		inRow:    row(1, "", ""),
+		outRow:   []sqltypes.Value{sqltypes.NewInt64(1)},
+	}, {
+		name:     "column-collation",
+		inFilter: "select id from t1 where status = 'PAID'",
+		inRow:    row(1, "paid", ""),
+		outRow:   []sqltypes.Value{sqltypes.NewInt64(1)},
+	}, {
+		name:     "projection",
+		inFilter: "select id, id + 1 as next_id, concat(status, '!') from t1",
+		inRow:    row(1, "paid", ""),
+		outRow:   []sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewInt64(2), sqltypes.NewVarChar("paid!")},
+	}, {
+		name:     "unknown-column",
+		inFilter: "select id from t1 where none in (1, 2)",
+		outErr:   "unsupported constraint: column `none` not found in table t1",
+	}, {
+		name:     "qualifier",
+		inFilter: "select id from t1 where t1.id in (1, 2)",
+		outErr:   "unsupported constraint: unsupported qualifier for column: t1.id",
+	}}
+	for _, tcase := range testcases {
+		t.Run(tcase.name, func(t *testing.T) {
+			plan, err := buildPlan(t1, testLocalVSchema, &binlogdatapb.Filter{
+				Rules: []*binlogdatapb.Rule{{Match: "t1", Filter: tcase.inFilter}},
+			})
+			if tcase.outErr != "" {
+				assert.Nil(t, plan)
+				assert.EqualError(t, err, tcase.outErr)
+				return
+			}
+			require.NoError(t, err)
+			result := make([]sqltypes.Value, len(plan.ColExprs))
+			ok, err := plan.filter(tcase.inRow, result, make([]collations.ID, len(tcase.inRow)))
+			require.NoError(t, err)
+			if tcase.outRow == nil {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, tcase.outRow, result)
+		})
+	}
+}
+
+func TestValidateWhere(t *testing.T) {
+	testcases := []struct {
+		in     string
+		outErr string
+	}{{
+		in: "select * from t1 where in_keyrange(id, 'hash', '-80') and status in ('new', 'paid') and created_at > '2023-01-01'",
+	}, {
+		in: "select * from t1 where id = 1",
+	}, {
+		in:     "select * from t1 where max(id) > 1",
+		outErr: "unsupported constraint: expr cannot be translated, not supported: max(id)",
+	}, {
+		in:     "select * from t1 where t1.id > 1",
+		outErr: "unsupported constraint: unsupported qualifier for column: t1.id",
+	}}
+	for _, tcase := range testcases {
+		t.Run(tcase.in, func(t *testing.T) {
+			stmt, err := sqlparser.Parse(tcase.in)
+			require.NoError(t, err)
+			err = ValidateWhere(stmt.(*sqlparser.Select).Where)
+			if tcase.outErr != "" {
+				assert.EqualError(t, err, tcase.outErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
 func TestCompare(t *testing.T) {
 	type testcase struct {
 		opcode                   Opcode
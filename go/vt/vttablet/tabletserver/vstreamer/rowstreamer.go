@@ -240,11 +240,26 @@ func (rs *rowStreamer) buildSelect() (string, error) {
 		prefix = ", "
 	}
 	buf.Myprintf(" from %v", sqlparser.NewIdentifierCS(rs.plan.Table.Name))
+	// Expression filters are pushed down to MySQL, so that only the
+	// matching rows are read. They are still evaluated on the rows sent.
+	prefix = " where "
+	var predicates bool
+	for _, filter := range rs.plan.Filters {
+		if filter.Opcode != Expression {
+			continue
+		}
+		buf.Myprintf("%s(%v)", prefix, filter.Predicate)
+		prefix = " and "
+		predicates = true
+	}
 	if len(rs.lastpk) != 0 {
 		if len(rs.lastpk) != len(rs.pkColumns) {
 			return "", fmt.Errorf("primary key values don't match length: %v vs %v", rs.lastpk, rs.pkColumns)
 		}
-		buf.WriteString(" where ")
+		buf.WriteString(prefix)
+		if predicates {
+			buf.WriteString("(")
+		}
 		prefix := ""
 		// This loop handles the case for composite pks. For example,
 		// if lastpk was (1,2), the where clause would be:
@@ -263,6 +278,9 @@ func (rs *rowStreamer) buildSelect() (string, error) {
 			rs.lastpk[lastcol].EncodeSQL(buf)
 			buf.Myprintf(")")
 		}
+		if predicates {
+			buf.WriteString(")")
+		}
 	}
 	buf.Myprintf(" order by ", sqlparser.NewIdentifierCS(rs.plan.Table.Name))
 	prefix = ""
@@ -297,6 +297,29 @@ func TestStreamRowsFilterInt(t *testing.T) {
 	require.Less(t, int64(0), engine.vstreamerPacketSize.Get())
 }
 
+func TestStreamRowsFilterExpression(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	execStatements(t, []string{
+		"create table t1(id1 int, id2 int, val varbinary(128), primary key(id1))",
+		"insert into t1 values (1, 100, 'aaa'), (2, 200, 'bbb'), (3, 200, 'ccc'), (4, 100, 'ddd'), (5, 200, 'eee')",
+	})
+
+	defer execStatements(t, []string{
+		"drop table t1",
+	})
+	engine.se.Reload(context.Background())
+
+	wantStream := []string{
+		`fields:{name:"id1" type:INT32 table:"t1" org_table:"t1" database:"vttest" org_name:"id1" column_length:11 charset:63} fields:{name:"val" type:VARBINARY table:"t1" org_table:"t1" database:"vttest" org_name:"val" column_length:128 charset:63} pkfields:{name:"id1" type:INT32}`,
+		`rows:{lengths:1 lengths:3 values:"4ddd"} lastpk:{lengths:1 values:"4"}`,
+	}
+	wantQuery := "select id1, id2, val from t1 where (id2 + 1 = 101) and ((id1 > 1)) order by id1"
+	checkStream(t, "select id1, val from t1 where id2 + 1 = 101", []sqltypes.Value{sqltypes.NewInt64(1)}, wantQuery, wantStream)
+}
+
 func TestStreamRowsFilterVarBinary(t *testing.T) {
 	if testing.Short() {
 		t.Skip()
@@ -66,6 +66,26 @@ func snapshotConnect(ctx context.Context, cp dbconfigs.Connector) (*snapshotConn
 // startSnapshot starts a streaming query with a snapshot view of the specified table.
 // It returns the GTID set from the time when the snapshot was taken.
 func (conn *snapshotConn) streamWithSnapshot(ctx context.Context, table, query string) (gtid string, rotatedLog bool, err error) {
+	if conn.usesFilePos() || conn.IsMariaDB() {
+		// A file:pos source has no GTID auto positioning overhead to limit, and
+		// session_track_gtids can only report MySQL56 GTIDs. Always take the
+		// LOCK-based snapshot, which reads the binary log coordinates, or the
+		// MariaDB GTIDs of all the domains, while the table is locked.
+		gtid, err = conn.startSnapshot(ctx, table)
+	} else {
+		gtid, rotatedLog, err = conn.startGTIDSnapshot(ctx, table, query)
+	}
+	if err != nil {
+		return "", rotatedLog, err
+	}
+	if err := conn.ExecuteStreamFetch(query); err != nil {
+		return "", rotatedLog, err
+	}
+	return gtid, rotatedLog, nil
+}
+
+// startGTIDSnapshot starts a snapshot of the specified table on a GTID based source.
+func (conn *snapshotConn) startGTIDSnapshot(ctx context.Context, table, query string) (gtid string, rotatedLog bool, err error) {
 	// Rotate the binary log if needed to limit the GTID auto positioning overhead.
 	// This may be needed as the currently open binary log (which can be up to 1G in
 	// size by default) will need to be scanned and empty events will be streamed for
@@ -90,13 +110,7 @@ func (conn *snapshotConn) streamWithSnapshot(ctx context.Context, table, query s
 		// session_track_gtids = START_GTID supported. Get a transaction with consistent GTID without LOCKing tables.
 		gtid, err = conn.startSnapshotWithConsistentGTID(ctx)
 	}
-	if err != nil {
-		return "", rotatedLog, err
-	}
-	if err := conn.ExecuteStreamFetch(query); err != nil {
-		return "", rotatedLog, err
-	}
-	return gtid, rotatedLog, nil
+	return gtid, rotatedLog, err
 }
 
 // snapshot performs the snapshotting.
@@ -165,6 +179,16 @@ func (conn *snapshotConn) startSnapshotWithConsistentGTID(ctx context.Context) (
 	return mysql.EncodePosition(mpos), nil
 }
 
+// usesFilePos returns true if the source is configured with the FilePos
+// flavor, i.e. it is tracked by binary log file and position instead of GTID.
+func (conn *snapshotConn) usesFilePos() bool {
+	params, err := conn.cp.MysqlParams()
+	if err != nil {
+		return false
+	}
+	return params.Flavor == mysql.FilePosFlavorID
+}
+
 // Close rollsback any open transactions and closes the connection.
 func (conn *snapshotConn) Close() {
 	_, _ = conn.ExecuteFetch("rollback", 1, false)
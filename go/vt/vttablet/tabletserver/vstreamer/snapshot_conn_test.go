@@ -23,7 +23,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/mysql/fakesqldb"
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/dbconfigs"
+	"vitess.io/vitess/go/vt/servenv"
 )
 
 func TestStartSnapshot(t *testing.T) {
@@ -59,3 +63,67 @@ func TestStartSnapshot(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, wantqr, qr)
 }
+
+func TestStreamWithSnapshotFilePos(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	execStatements(t, []string{
+		"create table t1(id int, val varbinary(128), primary key(id))",
+		"insert into t1 values (1, 'aaa')",
+	})
+	defer execStatements(t, []string{
+		"drop table t1",
+	})
+
+	params, err := env.Dbcfgs.AppWithDB().MysqlParams()
+	require.NoError(t, err)
+	filePosParams := *params
+	filePosParams.Flavor = mysql.FilePosFlavorID
+	cp := dbconfigs.NewTestDBConfigs(filePosParams, filePosParams, filePosParams.DbName).AppWithDB()
+
+	ctx := context.Background()
+	conn, err := snapshotConnect(ctx, cp)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	gtid, rotatedLog, err := conn.streamWithSnapshot(ctx, "t1", "select * from t1")
+	require.NoError(t, err)
+	assert.False(t, rotatedLog)
+	pos, err := mysql.DecodePosition(gtid)
+	require.NoError(t, err)
+	assert.True(t, pos.MatchesFlavor(mysql.FilePosFlavorID), "unexpected position %s", gtid)
+}
+
+func TestStreamWithSnapshotMariaDB(t *testing.T) {
+	version := servenv.MySQLServerVersion()
+	servenv.SetMySQLServerVersionForTest("10.6.12-MariaDB-log")
+	defer servenv.SetMySQLServerVersionForTest(version)
+	db := fakesqldb.New(t)
+	defer db.Close()
+
+	// The snapshot of a MariaDB source is LOCK-based, and reads the GTIDs of
+	// all the domains.
+	db.AddQuery("lock tables t1 read", &sqltypes.Result{})
+	db.AddQuery("SELECT @@GLOBAL.gtid_binlog_pos", sqltypes.MakeTestResult(sqltypes.MakeTestFields("gtid_binlog_pos", "varchar"), "0-1-100,1-2-50"))
+	db.AddQuery("unlock tables", &sqltypes.Result{})
+	db.AddQuery("set transaction isolation level repeatable read", &sqltypes.Result{})
+	db.AddQuery("start transaction with consistent snapshot", &sqltypes.Result{})
+	db.AddQuery("set @@session.time_zone = '+00:00'", &sqltypes.Result{})
+	db.AddQuery("select * from t1", sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int32"), "1"))
+	db.AddQuery("rollback", &sqltypes.Result{})
+
+	ctx := context.Background()
+	conn, err := snapshotConnect(ctx, db.ConnParams())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.True(t, conn.IsMariaDB())
+
+	gtid, rotatedLog, err := conn.streamWithSnapshot(ctx, "t1", "select * from t1")
+	require.NoError(t, err)
+	conn.CloseResult()
+	assert.False(t, rotatedLog)
+	assert.Equal(t, "MariaDB/0-1-100,1-2-50", gtid)
+	assert.Zero(t, db.GetQueryCalledNum("set session session_track_gtids = START_GTID"))
+}
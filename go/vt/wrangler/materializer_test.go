@@ -2431,6 +2431,25 @@ func TestMaterializerNotASelect(t *testing.T) {
 	require.EqualError(t, err, "unrecognized statement: update t1 set val=1")
 }
 
+func TestMaterializerUnsupportedWhere(t *testing.T) {
+	ms := &vtctldatapb.MaterializeSettings{
+		Workflow:       "workflow",
+		SourceKeyspace: "sourceks",
+		TargetKeyspace: "targetks",
+		TableSettings: []*vtctldatapb.TableMaterializeSettings{{
+			TargetTable:      "t1",
+			SourceExpression: "select * from t1 where max(id) > 1",
+			CreateDdl:        "t1ddl",
+		}},
+	}
+	env := newTestMaterializerEnv(t, ms, []string{"0"}, []string{"0"})
+	defer env.close()
+
+	env.tmc.expectVRQuery(200, mzSelectFrozenQuery, &sqltypes.Result{})
+	err := env.wr.Materialize(context.Background(), ms)
+	require.EqualError(t, err, "unsupported constraint: expr cannot be translated, not supported: max(id)")
+}
+
 func TestMaterializerNoGoodVindex(t *testing.T) {
 	ms := &vtctldatapb.MaterializeSettings{
 		Workflow:       "workflow",
@@ -94,6 +94,7 @@ var (
 	parallel         = flag.Int("parallel", 1, "number of tests to run in parallel")
 	skipBuild        = flag.Bool("skip-build", false, "skip running 'make build'. Assumes pre-existing binaries exist")
 	partialKeyspace  = flag.Bool("partial-keyspace", false, "add a second keyspace for sharded tests and mark first shard as moved to this keyspace in the shard routing rules")
+	buildTag         = flag.String("build-tag", "", "build tag passed to 'make build' as EXTRA_BUILD_TAGS, e.g. debug2PC")
 	// `go run test.go --dry-run --skip-build` to quickly test this file and see what tests will run
 	dryRun      = flag.Bool("dry-run", false, "For each test to be run, it will output the test attributes, but NOT run the tests. Useful while debugging changes to test.go (this file)")
 	remoteStats = flag.String("remote-stats", "", "url to send remote stats")
@@ -430,7 +431,9 @@ func main() {
 	} else {
 		// Since we're sharing the working dir, do the build once for all tests.
 		log.Printf("Running make build...")
-		if out, err := exec.Command("make", "build").CombinedOutput(); err != nil {
+		cmd := exec.Command("make", "build")
+		cmd.Env = append(os.Environ(), "EXTRA_BUILD_TAGS="+*buildTag)
+		if out, err := cmd.CombinedOutput(); err != nil {
 			log.Fatalf("make build failed: %v\n%s", err, out)
 		}
 	}
@@ -152,6 +152,7 @@ type clusterTest struct {
 	LimitResourceUsage                 bool
 	EnableBinlogTransactionCompression bool
 	PartialKeyspace                    bool
+	BuildTag                           string
 }
 
 type selfHostedTest struct {
@@ -358,6 +359,10 @@ func generateClusterWorkflows(list []string, tpl string) {
 			if strings.Contains(test.Shard, "partial_keyspace") {
 				test.PartialKeyspace = true
 			}
+			if cluster == "vtgate_transaction" {
+				// The 2PC tests fail the commits of vtgate at every step.
+				test.BuildTag = "debug2PC"
+			}
 
 			workflowPath := fmt.Sprintf("%s/cluster_endtoend_%s%s.yml", workflowConfigDir, cluster, mysqlVersionIndicator)
 			templateFileName := tpl
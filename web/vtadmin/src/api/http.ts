@@ -840,3 +840,61 @@ export const validateVersionShard = async (params: ValidateVersionShardParams) =
 
     return vtctldata.ValidateVersionShardResponse.create(result);
 };
+
+export interface FetchTransactionsParams {
+    clusterID: string;
+    keyspace: string;
+    // The age in seconds after which a transaction is considered abandoned by
+    // its coordinator. All the unresolved transactions are returned if unset.
+    abandonAge?: number;
+}
+
+export const fetchTransactions = async ({ clusterID, keyspace, abandonAge }: FetchTransactionsParams) => {
+    const req = new URLSearchParams();
+    if (typeof abandonAge === 'number') {
+        req.append('abandon_age', abandonAge.toString());
+    }
+
+    const { result } = await vtfetch(`/api/transactions/${clusterID}/${keyspace}?${req}`);
+
+    const err = vtctldata.GetUnresolvedTransactionsResponse.verify(result);
+    if (err) throw Error(err);
+
+    return vtctldata.GetUnresolvedTransactionsResponse.create(result);
+};
+
+export interface TransactionParams {
+    clusterID: string;
+    dtid: string;
+}
+
+export const resolveTransaction = async ({ clusterID, dtid }: TransactionParams) => {
+    const { result } = await vtfetch(`/api/transaction/${clusterID}/${encodeURIComponent(dtid)}/resolve`, {
+        method: 'put',
+    });
+
+    const err = vtctldata.ResolveTransactionResponse.verify(result);
+    if (err) throw Error(err);
+
+    return vtctldata.ResolveTransactionResponse.create(result);
+};
+
+export interface ConcludeTransactionParams extends TransactionParams {
+    // force concludes the transaction even though it is not resolved.
+    force?: boolean;
+}
+
+export const concludeTransaction = async ({ clusterID, dtid, force }: ConcludeTransactionParams) => {
+    const req = new URLSearchParams();
+    req.append('force', String(!!force));
+
+    const { result } = await vtfetch(
+        `/api/transaction/${clusterID}/${encodeURIComponent(dtid)}/conclude?${req.toString()}`,
+        { method: 'put' }
+    );
+
+    const err = vtctldata.ConcludeTransactionResponse.verify(result);
+    if (err) throw Error(err);
+
+    return vtctldata.ConcludeTransactionResponse.create(result);
+};
@@ -39,6 +39,7 @@ import { SnackbarContainer } from './Snackbar';
 import { isReadOnlyMode } from '../util/env';
 import { CreateKeyspace } from './routes/createKeyspace/CreateKeyspace';
 import { Topology } from './routes/topology/Topology';
+import { Transactions } from './routes/Transactions';
 import { ClusterTopology } from './routes/topology/ClusterTopology';
 
 export const App = () => {
@@ -97,6 +98,10 @@ export const App = () => {
                             <Tablet />
                         </Route>
 
+                        <Route path="/twopcz">
+                            <Transactions />
+                        </Route>
+
                         <Route path="/vtctlds">
                             <Vtctlds />
                         </Route>
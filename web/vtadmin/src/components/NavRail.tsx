@@ -74,6 +74,9 @@ export const NavRail = () => {
                     <li>
                         <NavRailLink icon={Icons.topology} text="Topology" to="/topology" />
                     </li>
+                    <li>
+                        <NavRailLink icon={Icons.checkSuccess} text="Transactions" to="/twopcz" />
+                    </li>
                 </ul>
             </div>
         </div>
@@ -0,0 +1,100 @@
+/**
+ * Copyright 2023 The Vitess Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import React from 'react';
+import { orderBy } from 'lodash-es';
+
+import { useKeyspaces, useTransactions } from '../../hooks/api';
+import { useDocumentTitle } from '../../hooks/useDocumentTitle';
+import { query, vtadmin as pb } from '../../proto/vtadmin';
+import { isReadOnlyMode } from '../../util/env';
+import { DataTable } from '../dataTable/DataTable';
+import { Select } from '../inputs/Select';
+import { ContentContainer } from '../layout/ContentContainer';
+import { WorkspaceHeader } from '../layout/WorkspaceHeader';
+import { WorkspaceTitle } from '../layout/WorkspaceTitle';
+import { QueryLoadingPlaceholder } from '../placeholders/QueryLoadingPlaceholder';
+import TransactionRow from './transactions/TransactionRow';
+
+const COLUMNS = ['Transaction', 'State', 'Created', 'Participants'];
+
+/**
+ * Transactions lists the unresolved distributed transactions of a keyspace,
+ * like the /twopcz page of vttablet, and resolves or concludes them.
+ */
+export const Transactions = () => {
+    useDocumentTitle('Transactions');
+
+    const { data: keyspaces = [] } = useKeyspaces();
+
+    const [clusterID, updateCluster] = React.useState<string | null | undefined>(null);
+    const [keyspaceName, updateKeyspace] = React.useState<string | null | undefined>(null);
+
+    const selectedKeyspace =
+        clusterID && keyspaceName
+            ? keyspaces?.find((k) => k.cluster?.id === clusterID && k.keyspace?.name === keyspaceName)
+            : null;
+
+    const transactionsQuery = useTransactions(
+        { clusterID: clusterID || '', keyspace: keyspaceName || '' },
+        { enabled: !!clusterID && !!keyspaceName }
+    );
+
+    const rows = React.useMemo(() => {
+        return orderBy(transactionsQuery.data?.transactions || [], ['time_created', 'dtid']);
+    }, [transactionsQuery.data]);
+
+    const onChangeKeyspace = (selectedKeyspace: pb.Keyspace | null | undefined) => {
+        updateCluster(selectedKeyspace?.cluster?.id);
+        updateKeyspace(selectedKeyspace?.keyspace?.name);
+    };
+
+    const renderRows = (rows: query.ITransactionMetadata[]) =>
+        rows.map((transaction) => (
+            <TransactionRow clusterID={clusterID || ''} key={transaction.dtid} transaction={transaction} />
+        ));
+
+    return (
+        <div>
+            <WorkspaceHeader>
+                <WorkspaceTitle>Transactions</WorkspaceTitle>
+            </WorkspaceHeader>
+            <ContentContainer>
+                <div className="max-w-screen-sm mb-8">
+                    <Select
+                        itemToString={(keyspace) => keyspace?.keyspace?.name || ''}
+                        items={orderBy(keyspaces, ['keyspace.name', 'cluster.id'])}
+                        label="Keyspace"
+                        onChange={onChangeKeyspace}
+                        placeholder="Choose a keyspace"
+                        renderItem={(keyspace) => `${keyspace?.keyspace?.name} (${keyspace?.cluster?.id})`}
+                        selectedItem={selectedKeyspace || null}
+                    />
+                </div>
+                {selectedKeyspace && (
+                    <>
+                        <DataTable
+                            columns={isReadOnlyMode() ? COLUMNS : [...COLUMNS, 'Actions']}
+                            data={rows}
+                            renderRows={renderRows}
+                        />
+                        <QueryLoadingPlaceholder query={transactionsQuery} />
+                    </>
+                )}
+            </ContentContainer>
+        </div>
+    );
+};
@@ -0,0 +1,125 @@
+/**
+ * Copyright 2023 The Vitess Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import React, { useState } from 'react';
+import { useQueryClient } from 'react-query';
+
+import { useConcludeTransaction, useResolveTransaction } from '../../../hooks/api';
+import { query } from '../../../proto/vtadmin';
+import { formatDateTime, formatRelativeTime } from '../../../util/time';
+import { DataCell } from '../../dataTable/DataCell';
+import Dialog from '../../dialog/Dialog';
+import { ShardLink } from '../../links/ShardLink';
+import { ReadOnlyGate } from '../../ReadOnlyGate';
+import { success, warn } from '../../Snackbar';
+
+interface Props {
+    clusterID: string;
+    transaction: query.ITransactionMetadata;
+}
+
+export const TransactionRow = ({ clusterID, transaction }: Props) => {
+    const [isConcludeOpen, setIsConcludeOpen] = useState(false);
+    const queryClient = useQueryClient();
+
+    const dtid = transaction.dtid || '';
+    // time_created is in nanoseconds.
+    const created = Math.floor(Number(transaction.time_created) / 1e9);
+
+    const resolveMutation = useResolveTransaction(
+        { clusterID, dtid },
+        {
+            onSuccess: () => {
+                success(`Successfully resolved transaction ${dtid}`);
+                queryClient.invalidateQueries('transactions');
+            },
+            onError: (error) => warn(`There was an error resolving transaction ${dtid}: ${error}`),
+        }
+    );
+
+    // The dialog asks for confirmation, so the transaction is concluded even
+    // though it is not resolved.
+    const concludeMutation = useConcludeTransaction(
+        { clusterID, dtid, force: true },
+        {
+            onSuccess: () => {
+                setIsConcludeOpen(false);
+                success(`Successfully concluded transaction ${dtid}`);
+                queryClient.invalidateQueries('transactions');
+            },
+            onError: (error) => warn(`There was an error concluding transaction ${dtid}: ${error}`),
+        }
+    );
+
+    return (
+        <tr>
+            <DataCell>{dtid}</DataCell>
+            <DataCell>{query.TransactionState[transaction.state || 0]}</DataCell>
+            <DataCell>
+                {formatDateTime(created)}
+                <div className="text-sm text-secondary">{formatRelativeTime(created)}</div>
+            </DataCell>
+            <DataCell>
+                {(transaction.participants || []).map((p) => (
+                    <div key={`${p.keyspace}/${p.shard}`}>
+                        <ShardLink clusterID={clusterID} keyspace={p.keyspace} shard={p.shard}>
+                            {p.keyspace}/{p.shard}
+                        </ShardLink>
+                    </div>
+                ))}
+            </DataCell>
+            <ReadOnlyGate>
+                <DataCell className="whitespace-nowrap">
+                    <button
+                        className="btn btn-secondary btn-sm"
+                        disabled={resolveMutation.isLoading}
+                        onClick={() => resolveMutation.mutate()}
+                        type="button"
+                    >
+                        Resolve
+                    </button>
+                    <button
+                        className="btn btn-secondary btn-sm btn-danger ml-2"
+                        onClick={() => setIsConcludeOpen(true)}
+                        type="button"
+                    >
+                        Conclude
+                    </button>
+                    <Dialog
+                        isOpen={isConcludeOpen}
+                        confirmText="Conclude"
+                        cancelText="Cancel"
+                        loading={concludeMutation.isLoading}
+                        loadingText="Concluding"
+                        onConfirm={() => concludeMutation.mutate()}
+                        onCancel={() => setIsConcludeOpen(false)}
+                        onClose={() => setIsConcludeOpen(false)}
+                        title="Conclude transaction"
+                        className="min-w-[400px]"
+                    >
+                        <div className="my-4">
+                            Concluding deletes the metadata of transaction <code>{dtid}</code> without committing
+                            or rolling it back on its participants. Resolve it instead, unless it was already
+                            resolved by hand.
+                        </div>
+                    </Dialog>
+                </DataCell>
+            </ReadOnlyGate>
+        </tr>
+    );
+};
+
+export default TransactionRow;
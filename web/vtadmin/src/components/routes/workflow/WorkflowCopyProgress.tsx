@@ -0,0 +1,82 @@
+/**
+ * Copyright 2023 The Vitess Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import { useMemo } from 'react';
+
+import { useWorkflow } from '../../../hooks/api';
+import { formatBytes } from '../../../util/formatBytes';
+import { formatCopyETA, formatPercentage, getCopyProgressTables } from '../../../util/workflows';
+import { DataCell } from '../../dataTable/DataCell';
+import { DataTable } from '../../dataTable/DataTable';
+
+interface Props {
+    clusterID: string;
+    keyspace: string;
+    name: string;
+}
+
+const COLUMNS = ['Table', 'Rows', 'Size'];
+
+export const WorkflowCopyProgress = ({ clusterID, keyspace, name }: Props) => {
+    const { data } = useWorkflow({ clusterID, keyspace, name });
+    const progress = data?.workflow?.copy_progress;
+
+    const tables = useMemo(() => getCopyProgressTables(data), [data]);
+
+    if (!progress) {
+        return null;
+    }
+
+    const renderRows = (rows: typeof tables) => {
+        return rows.map((row) => (
+            <tr key={row.table}>
+                <DataCell className="font-bold">{row.table}</DataCell>
+                <DataCell>
+                    {Number(row.rows_copied || 0).toLocaleString()} / {Number(row.rows_total || 0).toLocaleString()}
+                    <div className="text-sm text-secondary">{formatPercentage(row.rows_percentage)}</div>
+                </DataCell>
+                <DataCell>
+                    {formatBytes(row.bytes_copied) || '0 B'} / {formatBytes(row.bytes_total) || '0 B'}
+                    <div className="text-sm text-secondary">{formatPercentage(row.bytes_percentage)}</div>
+                </DataCell>
+            </tr>
+        ));
+    };
+
+    const eta = formatCopyETA(progress);
+
+    return (
+        <div className="my-12">
+            <h3 className="mt-24 mb-8">Copy Progress</h3>
+            <div>
+                {Number(progress.rows_copied || 0).toLocaleString()} of about{' '}
+                {Number(progress.rows_total || 0).toLocaleString()} rows copied (
+                {formatPercentage(progress.rows_percentage)}), at{' '}
+                {Math.round(progress.rows_per_second || 0).toLocaleString()} rows per second.
+                {eta && <span className="text-secondary"> Estimated to complete {eta}.</span>}
+            </div>
+            <div className="my-12">
+                <DataTable
+                    columns={COLUMNS}
+                    data={tables}
+                    pageSize={1000}
+                    renderRows={renderRows}
+                    title="Tables being copied"
+                />
+            </div>
+        </div>
+    );
+};
@@ -29,6 +29,7 @@ import { StreamStatePip } from '../../pips/StreamStatePip';
 import { WorkflowStreamsLagChart } from '../../charts/WorkflowStreamsLagChart';
 import { ShardLink } from '../../links/ShardLink';
 import { env } from '../../../util/env';
+import { WorkflowCopyProgress } from './WorkflowCopyProgress';
 
 interface Props {
     clusterID: string;
@@ -114,6 +115,8 @@ export const WorkflowStreams = ({ clusterID, keyspace, name }: Props) => {
                 </>
             )}
 
+            <WorkflowCopyProgress clusterID={clusterID} keyspace={keyspace} name={name} />
+
             <h3 className="mt-24 mb-8">Streams</h3>
             {/* TODO(doeg): add a protobuf enum for this (https://github.com/vitessio/vitess/projects/12#card-60190340) */}
             {['Error', 'Copying', 'Running', 'Stopped'].map((streamState) => {
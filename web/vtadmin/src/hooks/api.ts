@@ -78,6 +78,10 @@ import {
     GetFullStatusParams,
     validateVersionShard,
     ValidateVersionShardParams,
+    fetchTransactions,
+    FetchTransactionsParams,
+    resolveTransaction,
+    concludeTransaction,
 } from '../api/http';
 import { vtadmin as pb, vtctldata } from '../proto/vtadmin';
 import { formatAlias } from '../util/tablets';
@@ -619,3 +623,38 @@ export const useValidateVersionShard = (
         return validateVersionShard(params);
     }, options);
 };
+
+/**
+ * useTransactions is a query hook that fetches the unresolved distributed
+ * transactions of a keyspace.
+ */
+export const useTransactions = (
+    params: FetchTransactionsParams,
+    options?: UseQueryOptions<vtctldata.GetUnresolvedTransactionsResponse, Error> | undefined
+) => useQuery(['transactions', params], () => fetchTransactions(params), options);
+
+/**
+ * useResolveTransaction is a mutate hook that commits or rolls back a
+ * distributed transaction, depending on the decision of its coordinator.
+ */
+export const useResolveTransaction = (
+    params: Parameters<typeof resolveTransaction>[0],
+    options?: UseMutationOptions<Awaited<ReturnType<typeof resolveTransaction>>, Error>
+) => {
+    return useMutation<Awaited<ReturnType<typeof resolveTransaction>>, Error>(() => {
+        return resolveTransaction(params);
+    }, options);
+};
+
+/**
+ * useConcludeTransaction is a mutate hook that deletes the metadata of a
+ * distributed transaction without resolving it.
+ */
+export const useConcludeTransaction = (
+    params: Parameters<typeof concludeTransaction>[0],
+    options?: UseMutationOptions<Awaited<ReturnType<typeof concludeTransaction>>, Error>
+) => {
+    return useMutation<Awaited<ReturnType<typeof concludeTransaction>>, Error>(() => {
+        return concludeTransaction(params);
+    }, options);
+};
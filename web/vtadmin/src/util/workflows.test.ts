@@ -14,8 +14,8 @@
  * limitations under the License.
  */
 import { vtadmin as pb } from '../proto/vtadmin';
-import { getStream, getStreams, getStreamTablets } from './workflows';
-import { describe, expect } from 'vitest';
+import { formatCopyETA, getCopyProgressTables, getStream, getStreams, getStreamTablets } from './workflows';
+import { describe, expect, it } from 'vitest';
 
 describe('getStreams', () => {
     const tests: {
@@ -241,3 +241,38 @@ describe('getStreamTablets', () => {
         }
     );
 });
+
+describe('getCopyProgressTables', () => {
+    it('should return the tables being copied, ordered by name', () => {
+        const workflow = pb.Workflow.create({
+            workflow: {
+                copy_progress: {
+                    tables: {
+                        t2: { rows_copied: 50, rows_total: 200, rows_percentage: 25 },
+                        t1: { rows_copied: 300, rows_total: 600, rows_percentage: 50 },
+                    },
+                },
+            },
+        });
+        expect(getCopyProgressTables(workflow).map((t) => [t.table, Number(t.rows_copied)])).toEqual([
+            ['t1', 300],
+            ['t2', 50],
+        ]);
+    });
+
+    it('should handle a workflow that is not copying', () => {
+        expect(getCopyProgressTables(pb.Workflow.create())).toEqual([]);
+        expect(getCopyProgressTables(null)).toEqual([]);
+    });
+});
+
+describe('formatCopyETA', () => {
+    it('should format the ETA relative to now', () => {
+        expect(formatCopyETA({ eta: { seconds: 120 } }, 1000)).not.toBeNull();
+    });
+
+    it('should handle an unknown ETA', () => {
+        expect(formatCopyETA({ rows_copied: 10 })).toBeNull();
+        expect(formatCopyETA(null)).toBeNull();
+    });
+});
@@ -13,8 +13,11 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
+import { orderBy } from 'lodash-es';
+
 import { vtctldata, vtadmin as pb } from '../proto/vtadmin';
 import { formatAlias } from './tablets';
+import { formatRelativeTime } from './time';
 
 /**
  * getStreams returns a flat list of streams across all keyspaces/shards in the workflow.
@@ -94,3 +97,32 @@ export const getStreamTablets = <W extends pb.IWorkflow>(workflow: W | null | un
 
     return [...aliases];
 };
+
+/**
+ * getCopyProgressTables returns the progress of the tables that the workflow
+ * is still copying, ordered by table name.
+ */
+export const getCopyProgressTables = <W extends pb.IWorkflow>(
+    workflow: W | null | undefined
+): (vtctldata.Workflow.ITableCopyProgress & { table: string })[] => {
+    const tables = workflow?.workflow?.copy_progress?.tables || {};
+    return orderBy(Object.entries(tables).map(([table, progress]) => ({ table, ...progress })), 'table');
+};
+
+export const formatPercentage = (percentage: number | null | undefined): string => {
+    return `${(percentage || 0).toFixed(1)}%`;
+};
+
+/**
+ * formatCopyETA returns when the copy phase is estimated to complete, relative
+ * to now, or null if the rate of the copy is not known yet.
+ */
+export const formatCopyETA = (
+    progress: vtctldata.Workflow.ICopyProgress | null | undefined,
+    now: number = Date.now() / 1000
+): string | null => {
+    if (!progress?.eta) {
+        return null;
+    }
+    return formatRelativeTime(Math.round(now + Number(progress.eta.seconds || 0)));
+};